@@ -0,0 +1,287 @@
+// Package bankingtest provides fakes of the banking interfaces for the unit
+// tests of code that depends on them: a clock that moves only when told, a
+// bank in memory that fails operations on cue, rates set by hand and a
+// notifier that records what it was told.
+package bankingtest
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// Epoch is the time a FakeClock made by NewInMemoryBank starts at.
+var Epoch = time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// FakeClock is a models.Clock that stands still until set or advanced. It
+// is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a clock stopped at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set stops the clock at now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock on by d and returns the new time.
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// FakeRateProvider is a models.RateProvider and models.ExchangeRateProvider
+// answering with the rates set on it, and Err, when set, instead. It
+// counts the lookups made of it. It is safe for concurrent use.
+type FakeRateProvider struct {
+	mu        sync.Mutex
+	reference map[string]money.Rate
+	exchange  map[string]float64
+	err       error
+	lookups   int
+}
+
+// NewFakeRateProvider returns a provider without any rates.
+func NewFakeRateProvider() *FakeRateProvider {
+	return &FakeRateProvider{reference: make(map[string]money.Rate), exchange: make(map[string]float64)}
+}
+
+// SetReferenceRate sets the reference rate name.
+func (p *FakeRateProvider) SetReferenceRate(name string, rate money.Rate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reference[name] = rate
+}
+
+// SetExchangeRate sets how much quote one base buys; the inverse pair is
+// answered from it.
+func (p *FakeRateProvider) SetExchangeRate(base, quote string, rate float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exchange[base+"/"+quote] = rate
+}
+
+// SetError makes every lookup fail with err until it is set to nil.
+func (p *FakeRateProvider) SetError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Lookups is how many rates were asked for.
+func (p *FakeRateProvider) Lookups() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lookups
+}
+
+func (p *FakeRateProvider) ReferenceRate(name string) (money.Rate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lookups++
+	if p.err != nil {
+		return 0, p.err
+	}
+	return models.StaticRates(p.reference).ReferenceRate(name)
+}
+
+func (p *FakeRateProvider) ExchangeRate(base, quote string) (models.ExchangeRate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lookups++
+	if p.err != nil {
+		return models.ExchangeRate{}, p.err
+	}
+	if base == quote {
+		return models.ExchangeRate{Base: base, Quote: quote, Rate: 1}, nil
+	}
+	if rate, ok := p.exchange[base+"/"+quote]; ok {
+		return models.ExchangeRate{Base: base, Quote: quote, Rate: rate}, nil
+	}
+	if rate, ok := p.exchange[quote+"/"+base]; ok {
+		return models.ExchangeRate{Base: quote, Quote: base, Rate: rate}.Inverse(), nil
+	}
+	return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s", models.ErrNoExchangeRate, base, quote)
+}
+
+// RecordingNotifier records the events and alerts it is handed, in order.
+// Its Record method subscribes to an EventBus and Notify is a notify
+// callback for models.NewAlerts. It is safe for concurrent use.
+type RecordingNotifier struct {
+	mu     sync.Mutex
+	events []models.Event
+	alerts []models.Alert
+}
+
+// Record records e.
+func (n *RecordingNotifier) Record(e models.Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+}
+
+// Notify records a.
+func (n *RecordingNotifier) Notify(a models.Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, a)
+}
+
+// Events returns the events recorded, of the types given or of every type.
+func (n *RecordingNotifier) Events(types ...models.EventType) []models.Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var events []models.Event
+	for _, e := range n.events {
+		if len(types) == 0 || slices.Contains(types, e.Type) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Alerts returns the alerts recorded.
+func (n *RecordingNotifier) Alerts() []models.Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]models.Alert(nil), n.alerts...)
+}
+
+// Reset forgets what was recorded.
+func (n *RecordingNotifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events, n.alerts = nil, nil
+}
+
+// InMemoryBank is a models.Bank kept in memory on a FakeClock and a
+// FakeRateProvider, whose events a RecordingNotifier records, and whose
+// deposits, withdrawals and transfers fail when scripted to with Fail.
+type InMemoryBank struct {
+	*models.Bank
+	Clock    *FakeClock
+	Rates    *FakeRateProvider
+	Notifier *RecordingNotifier
+
+	mu       sync.Mutex
+	failures []*failure
+}
+
+// failure is a scripted failure: ops matching it fail with err, all of them
+// while times is below zero, otherwise the next times.
+type failure struct {
+	match Op
+	err   error
+	times int
+}
+
+// Op matches the operations a scripted failure fails: those of Kind, out of
+// Account, for a transfer, and to To, with the empty fields matching any.
+type Op struct {
+	Kind    models.OpKind
+	Account string
+	To      string
+}
+
+func (m Op) matches(op models.Op) bool {
+	return (m.Kind == "" || m.Kind == op.Kind) && (m.Account == "" || m.Account == op.Account) && (m.To == "" || m.To == op.To)
+}
+
+// NewInMemoryBank returns an empty bank in USD with its clock at Epoch,
+// silencing the bank's account messages.
+func NewInMemoryBank() *InMemoryBank {
+	models.SetOutput(io.Discard)
+	b := &InMemoryBank{Bank: models.NewBank(), Clock: NewFakeClock(Epoch), Rates: NewFakeRateProvider(), Notifier: &RecordingNotifier{}}
+	b.Bank.Clock = b.Clock
+	b.Bank.Rates = b.Rates
+	b.Bank.FX = b.Rates
+	b.Bank.Currency = "USD"
+	b.Events.Subscribe(b.Notifier.Record)
+	b.Use(b.inject)
+	return b
+}
+
+// Fail makes the next times operations matching op fail with err, before
+// they reach the ledger, or every one when times is below zero. Failures
+// are tried in the order they were scripted.
+func (b *InMemoryBank) Fail(op Op, err error, times int) {
+	if times == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = append(b.failures, &failure{match: op, err: err, times: times})
+}
+
+// ClearFailures drops every scripted failure.
+func (b *InMemoryBank) ClearFailures() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = nil
+}
+
+func (b *InMemoryBank) inject(next models.Operation) models.Operation {
+	return func(op models.Op) error {
+		b.mu.Lock()
+		for i, f := range b.failures {
+			if !f.match.matches(op) {
+				continue
+			}
+			if f.times > 0 {
+				if f.times--; f.times == 0 {
+					b.failures = append(b.failures[:i:i], b.failures[i+1:]...)
+				}
+			}
+			b.mu.Unlock()
+			return f.err
+		}
+		b.mu.Unlock()
+		return next(op)
+	}
+}
+
+// OpenChecking opens a checking account with an opening balance, failing
+// the test it is called from, through t, when it cannot.
+func (b *InMemoryBank) OpenChecking(t TB, number string, balance float64) {
+	t.Helper()
+	if err := b.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: number, Balance: balance}}); err != nil {
+		t.Fatalf("opening %s: %v", number, err)
+	}
+}
+
+// OpenSavings opens a savings account with an opening balance, failing the
+// test it is called from, through t, when it cannot.
+func (b *InMemoryBank) OpenSavings(t TB, number string, balance float64) {
+	t.Helper()
+	if err := b.Open(&models.SavingsAccount{Account: models.Account{AccountNumber: number, Balance: balance}}); err != nil {
+		t.Fatalf("opening %s: %v", number, err)
+	}
+}
+
+// TB is the part of testing.TB the helpers use, so that the package does
+// not import testing into the binaries of its users.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
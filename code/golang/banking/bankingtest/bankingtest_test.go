@@ -0,0 +1,72 @@
+package bankingtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// TestInMemoryBank scripts failures of a bank's operations and checks they
+// fail as scripted, leave the ledger alone and are then used up, while the
+// notifier records the entries that were posted on the fake clock.
+func TestInMemoryBank(t *testing.T) {
+	b := NewInMemoryBank()
+	b.OpenChecking(t, "C1", 100)
+	b.OpenSavings(t, "S1", 0)
+
+	down := banking.New(banking.CodeUnavailable, "core banking is down")
+	b.Fail(Op{Kind: models.OpTransfer, To: "S1"}, down, 2)
+	b.Fail(Op{Account: "C1"}, models.ErrInsufficientFunds, -1)
+	for i := 0; i < 2; i++ {
+		if err := b.Transfer("C1", "S1", 10); !errors.Is(err, down) {
+			t.Fatalf("transfer %d: %v, want the scripted failure", i+1, err)
+		}
+	}
+	if err := b.Withdraw("C1", 10); !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Fatalf("withdrawal: %v", err)
+	}
+	if err := b.Deposit("S1", 5); err != nil {
+		t.Fatalf("unscripted deposit: %v", err)
+	}
+	b.ClearFailures()
+	b.Clock.Advance(time.Hour)
+	if err := b.Transfer("C1", "S1", 10); err != nil {
+		t.Fatalf("transfer after clearing: %v", err)
+	}
+	if balance, _ := b.Balance("S1"); balance != 15 {
+		t.Errorf("savings balance %v, want 15", balance)
+	}
+	posted := b.Notifier.Events(models.EventTransactionPosted)
+	if len(posted) != 3 {
+		t.Fatalf("%d entries posted, want 3: %+v", len(posted), posted)
+	}
+	if last := posted[len(posted)-1]; !last.Time.Equal(Epoch.Add(time.Hour)) {
+		t.Errorf("last entry at %v, want an hour after %v", last.Time, Epoch)
+	}
+}
+
+func TestFakeRateProvider(t *testing.T) {
+	p := NewFakeRateProvider()
+	p.SetReferenceRate("base", money.Percent(4))
+	p.SetExchangeRate("EUR", "USD", 1.25)
+	if rate, err := p.ReferenceRate("base"); err != nil || rate != money.Percent(4) {
+		t.Errorf("base rate %v, %v", rate, err)
+	}
+	if rate, err := p.ExchangeRate("USD", "EUR"); err != nil || rate.Rate != 0.8 {
+		t.Errorf("USD/EUR %v, %v", rate, err)
+	}
+	if _, err := p.ExchangeRate("GBP", "USD"); !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("unknown pair: %v", err)
+	}
+	p.SetError(models.ErrNoExchangeRate)
+	if _, err := p.ReferenceRate("base"); err == nil {
+		t.Error("no error while failing")
+	}
+	if p.Lookups() != 4 {
+		t.Errorf("%d lookups, want 4", p.Lookups())
+	}
+}
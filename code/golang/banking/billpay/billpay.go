@@ -0,0 +1,315 @@
+// Package billpay pays bills from customers' accounts: billers are
+// registered once, bills are recorded with their amount and due date, and
+// scheduled bills are paid on a business day early enough to land by the due
+// date. Bills with a pay-when-funds instruction that find too little in the
+// account wait for a deposit to be paid.
+package billpay
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+// Biller is a company customers pay bills to, such as a utility. Payments go
+// to its Account with the bank.
+type Biller struct {
+	ID      string
+	Name    string
+	Account string
+}
+
+type BillStatus string
+
+const (
+	BillOpen      BillStatus = "open"
+	BillScheduled BillStatus = "scheduled"
+	BillPaid      BillStatus = "paid"
+	// BillWaiting is a bill whose payment found too little in the account
+	// and waits for a deposit under its pay-when-funds instruction.
+	BillWaiting BillStatus = "waiting"
+	// BillCancelled is one that waited in vain.
+	BillCancelled BillStatus = "cancelled"
+)
+
+// DefaultFundsWindow is how long a bill waits for funds under a
+// pay-when-funds instruction unless it says.
+const DefaultFundsWindow = 72 * time.Hour
+
+// Bill is an amount a customer owes a biller by Due. A scheduled bill is paid
+// on PayOn. Once paid, Entry is the sequence of the payment in the ledger of
+// Account and PaidAt its time. LastError is why the last attempt to pay
+// failed; failed payments are retried by the next Process.
+//
+// WhenFunds is the window of the bill's pay-when-funds instruction, see
+// PayWhenFunds: a payment that fails for insufficient funds waits, until
+// WaitUntil, for a deposit into Account and is retried when one arrives.
+type Bill struct {
+	ID        string
+	Biller    string
+	Account   string
+	Amount    float64
+	Due       time.Time
+	Status    BillStatus
+	PayOn     time.Time
+	Entry     int
+	PaidAt    time.Time
+	LastError string
+	WhenFunds time.Duration
+	WaitUntil time.Time
+}
+
+var (
+	ErrBillerNotFound = banking.New(banking.CodeNotFound, "biller not found")
+	ErrBillerExists   = banking.New(banking.CodeConflict, "biller already registered")
+	ErrBillNotFound   = banking.New(banking.CodeNotFound, "bill not found")
+	ErrBillPaid       = banking.New(banking.CodeConflict, "bill already paid")
+)
+
+// Service keeps billers and bills and pays scheduled bills. LeadDays is how
+// many business days before the due date payments are made.
+type Service struct {
+	bank     *models.Bank
+	LeadDays int
+
+	mu      sync.Mutex
+	billers map[string]Biller
+	bills   map[string]*Bill
+	nextID  int
+	// paying are the bills being paid, which no one else pays meanwhile.
+	paying map[string]bool
+}
+
+// New returns a service that pays bills from the accounts of bank. It
+// watches bank's deposits for the bills waiting for funds.
+func New(bank *models.Bank) *Service {
+	s := &Service{bank: bank, billers: make(map[string]Biller), bills: make(map[string]*Bill), paying: make(map[string]bool)}
+	bank.Events.Subscribe(s.fundsArrived)
+	return s
+}
+
+func (s *Service) RegisterBiller(b Biller) error {
+	if _, err := s.bank.Account(b.Account); err != nil {
+		return fmt.Errorf("biller %s: %w", b.ID, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.billers[b.ID]; ok {
+		return ErrBillerExists
+	}
+	s.billers[b.ID] = b
+	return nil
+}
+
+// AddBill records an open bill and returns it with its ID assigned.
+func (s *Service) AddBill(bill Bill) (Bill, error) {
+	if bill.Amount <= 0 {
+		return Bill{}, models.ErrInvalidAmount
+	}
+	if _, err := s.bank.Account(bill.Account); err != nil {
+		return Bill{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.billers[bill.Biller]; !ok {
+		return Bill{}, ErrBillerNotFound
+	}
+	s.nextID++
+	bill.ID = fmt.Sprintf("bill-%d", s.nextID)
+	bill.Status = BillOpen
+	bill.PayOn, bill.Entry, bill.PaidAt, bill.LastError, bill.WaitUntil = time.Time{}, 0, time.Time{}, "", time.Time{}
+	s.bills[bill.ID] = &bill
+	return bill, nil
+}
+
+func (s *Service) Bill(id string) (Bill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bill, ok := s.bills[id]
+	if !ok {
+		return Bill{}, ErrBillNotFound
+	}
+	return *bill, nil
+}
+
+// Bills returns the bills of an account, or every bill for an empty account,
+// ordered by due date.
+func (s *Service) Bills(account string) []Bill {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var bills []Bill
+	for _, bill := range s.bills {
+		if account == "" || s.current(bill.Account) == account {
+			bills = append(bills, *bill)
+		}
+	}
+	sort.Slice(bills, func(i, j int) bool {
+		if !bills[i].Due.Equal(bills[j].Due) {
+			return bills[i].Due.Before(bills[j].Due)
+		}
+		return bills[i].ID < bills[j].ID
+	})
+	return bills
+}
+
+// current is the number an account of a bill or biller is known by now,
+// which differs from the one given when the account was renumbered since.
+func (s *Service) current(number string) string {
+	if r, ok := s.bank.Redirect(number); ok {
+		return r.To
+	}
+	return number
+}
+
+// Schedule arranges for a bill to be paid LeadDays business days before its
+// due date, or on the last business day before it when the due date is not a
+// business day.
+func (s *Service) Schedule(id string) (Bill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bill, ok := s.bills[id]
+	if !ok {
+		return Bill{}, ErrBillNotFound
+	}
+	if bill.Status == BillPaid {
+		return Bill{}, ErrBillPaid
+	}
+	bill.PayOn = s.bank.Calendar.AddBusinessDays(bill.Due, -s.LeadDays)
+	bill.Status = BillScheduled
+	return *bill, nil
+}
+
+// PayWhenFunds gives a bill a pay-when-funds instruction: when its
+// scheduled payment fails for insufficient funds, it waits for window,
+// DefaultFundsWindow when zero, for a deposit into its account to be paid,
+// and is cancelled and its customers told if none arrives that pays it.
+func (s *Service) PayWhenFunds(id string, window time.Duration) (Bill, error) {
+	if window < 0 {
+		return Bill{}, banking.New(banking.CodeInvalidArgument, "window must not be negative")
+	}
+	if window == 0 {
+		window = DefaultFundsWindow
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bill, ok := s.bills[id]
+	if !ok {
+		return Bill{}, ErrBillNotFound
+	}
+	if bill.Status == BillPaid {
+		return Bill{}, ErrBillPaid
+	}
+	bill.WhenFunds = window
+	return *bill, nil
+}
+
+// Process pays every scheduled bill whose pay date has come by now and
+// returns the bills it paid. It cancels the bills that waited for funds
+// until now in vain, and tells their customers.
+func (s *Service) Process(now time.Time) []Bill {
+	s.mu.Lock()
+	var due, expired []*Bill
+	for _, bill := range s.bills {
+		switch {
+		case s.paying[bill.ID]:
+		case bill.Status == BillScheduled && !now.Before(bill.PayOn):
+			due = append(due, bill)
+		case bill.Status == BillWaiting && !now.Before(bill.WaitUntil):
+			bill.Status = BillCancelled
+			expired = append(expired, bill)
+		}
+	}
+	cancelled := make([]Bill, len(expired))
+	for i, bill := range expired {
+		cancelled[i] = *bill
+	}
+	s.mu.Unlock()
+	for _, bill := range cancelled {
+		s.notifyCancelled(bill)
+	}
+	return s.pay(due, now)
+}
+
+// pay pays bills, which are not being paid already, and returns those it
+// paid. Those refused for insufficient funds with a pay-when-funds
+// instruction wait for a deposit from now on.
+func (s *Service) pay(due []*Bill, now time.Time) []Bill {
+	if len(due) == 0 {
+		return nil
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	s.mu.Lock()
+	requests := make([]models.TransferRequest, len(due))
+	for i, bill := range due {
+		s.paying[bill.ID] = true
+		requests[i] = models.TransferRequest{
+			From: s.current(bill.Account), To: s.current(s.billers[bill.Biller].Account), Amount: bill.Amount, Category: "bills",
+			Metadata: map[string]string{"bill.id": bill.ID, "bill.biller": bill.Biller},
+		}
+	}
+	s.mu.Unlock()
+
+	results := s.bank.TransferBatch(requests)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paid []Bill
+	for i, result := range results {
+		bill := due[i]
+		delete(s.paying, bill.ID)
+		if result.Err != nil {
+			bill.LastError = result.Err.Error()
+			if bill.WhenFunds > 0 && bill.Status == BillScheduled && banking.CodeOf(result.Err) == banking.CodeInsufficientFunds {
+				bill.Status, bill.WaitUntil = BillWaiting, now.Add(bill.WhenFunds)
+			}
+			continue
+		}
+		bill.Status = BillPaid
+		bill.Entry = result.Withdrawal.Sequence
+		bill.PaidAt = result.Withdrawal.Time
+		bill.LastError = ""
+		bill.WaitUntil = time.Time{}
+		paid = append(paid, *bill)
+	}
+	return paid
+}
+
+// fundsArrived pays the bills waiting for funds in an account a deposit
+// was posted to.
+func (s *Service) fundsArrived(e models.Event) {
+	if e.Type != models.EventTransactionPosted || e.Transaction == nil || !e.Transaction.Type.IsCredit() {
+		return
+	}
+	s.mu.Lock()
+	var waiting []*Bill
+	for _, bill := range s.bills {
+		if bill.Status == BillWaiting && !s.paying[bill.ID] && e.Time.Before(bill.WaitUntil) && s.current(bill.Account) == e.AccountNumber {
+			waiting = append(waiting, bill)
+		}
+	}
+	s.mu.Unlock()
+	s.pay(waiting, e.Time)
+}
+
+// notifyCancelled tells the customers of the account of a bill that it was
+// cancelled for want of funds.
+func (s *Service) notifyCancelled(bill Bill) {
+	number := s.current(bill.Account)
+	holders, err := s.bank.Holders(number)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	biller := s.billers[bill.Biller].Name
+	s.mu.Unlock()
+	for _, h := range holders {
+		s.bank.SendMessage(models.Message{Customer: h.Customer, Kind: models.MessageNotice, Account: number, Key: "bill-cancelled:" + bill.ID,
+			Subject: fmt.Sprintf("Bill to %s cancelled", biller),
+			Body: fmt.Sprintf("Your bill %s of %.2f to %s was not paid: %s had too little in it, and no deposit by %s covered it.",
+				bill.ID, bill.Amount, biller, number, bill.WaitUntil.Format("2006-01-02 15:04"))})
+	}
+}
@@ -0,0 +1,159 @@
+package billpay
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gsolano/banking/bankingtest"
+	"gsolano/banking/models"
+)
+
+// TestPayWhenFunds checks that a bill with a pay-when-funds instruction
+// waits when its payment finds too little, is paid by the deposit that
+// covers it, and that one no deposit covers is cancelled with a message to
+// its customer when its window ends.
+func TestPayWhenFunds(t *testing.T) {
+	bank := bankingtest.NewInMemoryBank()
+	bank.Open(&models.SavingsAccount{Account: models.Account{AccountNumber: "S1"}})
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "B1"}})
+	bank.AddCustomer(&models.Customer{ID: "c1", Name: "Ada", Accounts: []string{"S1"}})
+	if err := bank.Deposit("S1", 20); err != nil {
+		t.Fatal(err)
+	}
+	s := New(bank.Bank)
+	if err := s.RegisterBiller(Biller{ID: "power", Name: "Power Co", Account: "B1"}); err != nil {
+		t.Fatal(err)
+	}
+	due := bank.Clock.Now().AddDate(0, 0, 7)
+	small, _ := s.AddBill(Bill{Biller: "power", Account: "S1", Amount: 50, Due: due})
+	large, _ := s.AddBill(Bill{Biller: "power", Account: "S1", Amount: 500, Due: due})
+	for _, bill := range []Bill{small, large} {
+		if _, err := s.PayWhenFunds(bill.ID, 48*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Schedule(bill.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bank.Clock.Set(due)
+	if paid := s.Process(due); len(paid) != 0 {
+		t.Fatalf("paid %+v without the funds", paid)
+	}
+	if bill, _ := s.Bill(small.ID); bill.Status != BillWaiting || !bill.WaitUntil.Equal(due.Add(48*time.Hour)) || bill.LastError == "" {
+		t.Fatalf("bill after the failed payment %+v", bill)
+	}
+	bank.Clock.Advance(time.Hour)
+	if err := bank.Deposit("S1", 10); err != nil {
+		t.Fatal(err)
+	}
+	if bill, _ := s.Bill(small.ID); bill.Status != BillWaiting {
+		t.Errorf("bill after a deposit too small %+v", bill)
+	}
+	bank.Clock.Advance(time.Hour)
+	if err := bank.Deposit("S1", 30); err != nil {
+		t.Fatal(err)
+	}
+	if bill, _ := s.Bill(small.ID); bill.Status != BillPaid || bill.Entry == 0 {
+		t.Errorf("bill after the deposit that covers it %+v", bill)
+	}
+
+	end := due.Add(48 * time.Hour)
+	bank.Clock.Set(end)
+	s.Process(end)
+	if bill, _ := s.Bill(large.ID); bill.Status != BillCancelled {
+		t.Errorf("bill after its window %+v", bill)
+	}
+	if err := bank.Deposit("S1", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if bill, _ := s.Bill(large.ID); bill.Status != BillCancelled {
+		t.Errorf("cancelled bill after a deposit %+v", bill)
+	}
+	messages, _, err := bank.Messages("c1", false)
+	if err != nil || len(messages) != 1 || messages[0].Account != "S1" || messages[0].Subject != "Bill to Power Co cancelled" {
+		t.Errorf("messages %+v, %v", messages, err)
+	}
+}
+
+// TestScheduleBills schedules bills due on a business day and on a Sunday
+// and checks they are paid on the right day, linked to their entry in the
+// ledger, and that paid bills, unknown billers and billers registered twice
+// are refused.
+func TestScheduleBills(t *testing.T) {
+	bank := bankingtest.NewInMemoryBank()
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}})
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "B1"}})
+	if err := bank.Deposit("C1", 1000); err != nil {
+		t.Fatal(err)
+	}
+	s := New(bank.Bank)
+	s.LeadDays = 2
+	if err := s.RegisterBiller(Biller{ID: "power", Name: "Power Co", Account: "B1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterBiller(Biller{ID: "power", Name: "Power Co", Account: "B1"}); !errors.Is(err, ErrBillerExists) {
+		t.Errorf("registering twice: %v, want ErrBillerExists", err)
+	}
+	if _, err := s.AddBill(Bill{Biller: "water", Account: "C1", Amount: 10, Due: time.Now()}); !errors.Is(err, ErrBillerNotFound) {
+		t.Errorf("billing an unknown biller: %v, want ErrBillerNotFound", err)
+	}
+
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	// Due on Sunday the 15th, two business days before Friday the 13th is
+	// Wednesday the 11th; due on Monday the 9th, it is Thursday the 5th.
+	sunday, _ := s.AddBill(Bill{Biller: "power", Account: "C1", Amount: 80, Due: day("2026-03-15")})
+	monday, _ := s.AddBill(Bill{Biller: "power", Account: "C1", Amount: 120, Due: day("2026-03-09")})
+	for _, tt := range []struct {
+		bill Bill
+		want string
+	}{{sunday, "2026-03-11"}, {monday, "2026-03-05"}} {
+		bill, err := s.Schedule(tt.bill.ID)
+		if err != nil || !bill.PayOn.Equal(day(tt.want)) || bill.Status != BillScheduled {
+			t.Errorf("scheduling %s due %s: %+v, %v, want paid on %s", bill.ID, tt.bill.Due.Format(time.DateOnly), bill, err, tt.want)
+		}
+	}
+	if bills := s.Bills("C1"); len(bills) != 2 || bills[0].ID != monday.ID {
+		t.Errorf("bills of C1 %+v, want the one due Monday first", bills)
+	}
+
+	for _, tt := range []struct {
+		now  string
+		want []string
+	}{
+		{"2026-03-04", nil},
+		{"2026-03-05", []string{monday.ID}},
+		{"2026-03-06", nil},
+		{"2026-03-10", nil},
+		{"2026-03-11", []string{sunday.ID}},
+	} {
+		var ids []string
+		for _, bill := range s.Process(day(tt.now)) {
+			ids = append(ids, bill.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("processing on %s paid %v, want %v", tt.now, ids, tt.want)
+		}
+	}
+
+	bill, _ := s.Bill(monday.ID)
+	account, _ := bank.Account("C1")
+	entry := account.History()[bill.Entry-1]
+	if bill.Status != BillPaid || entry.Amount != 120 || entry.Metadata["bill.id"] != monday.ID {
+		t.Errorf("bill %+v links to entry %+v, want the payment of 120", bill, entry)
+	}
+	if balance, _ := bank.Balance("B1"); balance != 200 {
+		t.Errorf("biller balance %.2f, want 200.00", balance)
+	}
+	if _, err := s.Schedule(monday.ID); !errors.Is(err, ErrBillPaid) {
+		t.Errorf("scheduling a paid bill: %v, want ErrBillPaid", err)
+	}
+}
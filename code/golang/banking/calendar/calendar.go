@@ -0,0 +1,128 @@
+// Package calendar knows which days banks are open, so value dates and due
+// dates can be moved to business days.
+package calendar
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoBusinessDays is returned by SetWeekend for a weekend of every day,
+// which would leave the business-day searches nothing to find.
+var ErrNoBusinessDays = errors.New("calendar: a weekend of every day of the week leaves no business days")
+
+// Calendar is a business-day calendar: every day is a business day except
+// weekend days and holidays. It is safe for concurrent use.
+type Calendar struct {
+	mu       sync.RWMutex
+	weekend  map[time.Weekday]bool
+	holidays map[date]string
+}
+
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) date {
+	y, m, d := t.Date()
+	return date{y, m, d}
+}
+
+// New returns a calendar with Saturday and Sunday as the weekend and no
+// holidays.
+func New() *Calendar {
+	return &Calendar{
+		weekend:  map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		holidays: make(map[date]string),
+	}
+}
+
+// SetWeekend replaces the days of the weekend. It leaves the weekend as it
+// was and returns ErrNoBusinessDays if days are all seven.
+func (c *Calendar) SetWeekend(days ...time.Weekday) error {
+	weekend := make(map[time.Weekday]bool, len(days))
+	for _, d := range days {
+		weekend[d] = true
+	}
+	open := false
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		open = open || !weekend[d]
+	}
+	if !open {
+		return ErrNoBusinessDays
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.weekend = weekend
+	return nil
+}
+
+// AddHoliday marks the date of day as a holiday called name.
+func (c *Calendar) AddHoliday(day time.Time, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holidays[dateOf(day)] = name
+}
+
+// Holiday returns the name of the holiday on the date of t, if there is one.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	name, ok := c.holidays[dateOf(t)]
+	return name, ok
+}
+
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, holiday := c.holidays[dateOf(t)]
+	return !holiday && !c.weekend[t.Weekday()]
+}
+
+// StartOfDay returns midnight at the start of t's date, in t's location.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// NextBusinessDay returns the start of the first business day on or after
+// the date of t.
+func (c *Calendar) NextBusinessDay(t time.Time) time.Time {
+	day := StartOfDay(t)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// PreviousBusinessDay returns the start of the last business day on or
+// before the date of t.
+func (c *Calendar) PreviousBusinessDay(t time.Time) time.Time {
+	day := StartOfDay(t)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// AddBusinessDays returns the start of the business day n business days
+// after t, as in T+n settlement. T+0 is t itself when it is a business day
+// and the next business day otherwise. A negative n counts back from the last
+// business day on or before t.
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	if n < 0 {
+		day := c.PreviousBusinessDay(t)
+		for ; n < 0; n++ {
+			day = c.PreviousBusinessDay(day.AddDate(0, 0, -1))
+		}
+		return day
+	}
+	day := c.NextBusinessDay(t)
+	for ; n > 0; n-- {
+		day = c.NextBusinessDay(day.AddDate(0, 0, 1))
+	}
+	return day
+}
@@ -0,0 +1,70 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// TestBusinessDays checks T+n over weekends and holidays, counting back,
+// and a weekend moved to Friday and Saturday.
+func TestBusinessDays(t *testing.T) {
+	c := New()
+	c.AddHoliday(day(t, "2026-01-19"), "Martin Luther King Jr. Day")
+	tests := []struct {
+		from string
+		n    int
+		want string
+	}{
+		{"2026-01-14", 0, "2026-01-14"},
+		{"2026-01-14", 1, "2026-01-15"},
+		{"2026-01-16", 1, "2026-01-20"},
+		{"2026-01-17", 0, "2026-01-20"},
+		{"2026-01-15", 2, "2026-01-20"},
+		{"2026-01-20", -1, "2026-01-16"},
+		{"2026-01-18", -1, "2026-01-15"},
+	}
+	for _, tt := range tests {
+		if got := c.AddBusinessDays(day(t, tt.from), tt.n); !got.Equal(day(t, tt.want)) {
+			t.Errorf("%s T%+d = %s, want %s", tt.from, tt.n, got.Format(time.DateOnly), tt.want)
+		}
+	}
+	if name, ok := c.Holiday(day(t, "2026-01-19").Add(15 * time.Hour)); !ok || name != "Martin Luther King Jr. Day" {
+		t.Errorf("holiday = %q, %v", name, ok)
+	}
+
+	if err := c.SetWeekend(time.Friday, time.Saturday); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsBusinessDay(day(t, "2026-01-18")) || c.IsBusinessDay(day(t, "2026-01-16")) {
+		t.Error("Sunday is not a business day or Friday is after moving the weekend")
+	}
+}
+
+// TestSetWeekendEveryDay checks that a weekend of the whole week is refused
+// and leaves the weekend as it was, so the searches for business days end.
+func TestSetWeekendEveryDay(t *testing.T) {
+	c := New()
+	all := []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	if err := c.SetWeekend(all...); !errors.Is(err, ErrNoBusinessDays) {
+		t.Fatalf("weekend of every day: %v, want %v", err, ErrNoBusinessDays)
+	}
+	if !c.IsBusinessDay(day(t, "2026-01-12")) {
+		t.Error("the refused weekend closed Monday")
+	}
+	if err := c.SetWeekend(append(all[1:], time.Monday)...); err != nil {
+		t.Errorf("weekend of six days with a repeat: %v", err)
+	}
+	if got := c.NextBusinessDay(day(t, "2026-01-13")); !got.Equal(day(t, "2026-01-18")) {
+		t.Errorf("next business day with only Sunday open = %s, want 2026-01-18", got.Format(time.DateOnly))
+	}
+}
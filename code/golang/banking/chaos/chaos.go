@@ -0,0 +1,295 @@
+// Package chaos injects faults into the bank's dependencies so that saving,
+// the outbox relay and time-dependent jobs can be tested under failure:
+// store writes that fail, a webhook endpoint that is down, and a skewed
+// clock. Faults are off until switched on, and are only wired in by tests
+// and by bankserver with chaos.enabled, for sandboxes; never in production.
+package chaos
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+	"gsolano/banking/store"
+)
+
+// ErrInjected is the error of an injected fault. It is retryable, as the
+// failures it stands in for are.
+var (
+	ErrInjected     = banking.New(banking.CodeUnavailable, "injected fault")
+	ErrUnknownPoint = banking.New(banking.CodeInvalidArgument, "unknown fault point")
+)
+
+// Point is a place a fault can be injected.
+type Point string
+
+const (
+	// StoreWrite fails saves of accounts, customers and lifecycles.
+	StoreWrite Point = "store_write"
+	// Webhook fails requests sent through Transport, as if the endpoint
+	// the outbox relay posts events to were down.
+	Webhook Point = "webhook"
+)
+
+// Points lists every Point.
+var Points = []Point{StoreWrite, Webhook}
+
+// Valid reports whether p is one of Points.
+func (p Point) Valid() bool {
+	return p == StoreWrite || p == Webhook
+}
+
+// Faults are the faults switched on, and the skew of clocks made with
+// Clock. The zero value injects none; it is safe for concurrent use.
+type Faults struct {
+	mu sync.Mutex
+	// failing maps a point to how many more calls fail there, or to -1
+	// when every call fails until it is switched off.
+	failing map[Point]int
+	skew    time.Duration
+}
+
+// State is what is injected, as Set takes it and State returns it. Failing
+// maps each point switched on to how many more calls fail there, 0 for
+// every call until it is switched off.
+type State struct {
+	Failing map[Point]int
+	Skew    time.Duration
+}
+
+// Fail makes every call at p fail until Clear.
+func (f *Faults) Fail(p Point) {
+	f.FailNext(p, -1)
+}
+
+// FailNext makes the next n calls at p fail, and those after succeed; n
+// below zero fails every call until Clear and n of zero switches p off.
+func (f *Faults) FailNext(p Point, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing == nil {
+		f.failing = make(map[Point]int)
+	}
+	if n == 0 {
+		delete(f.failing, p)
+		return
+	}
+	f.failing[p] = n
+}
+
+// Clear switches p off.
+func (f *Faults) Clear(p Point) {
+	f.FailNext(p, 0)
+}
+
+// Skew sets how far ahead of the clocks they wrap, or behind when negative,
+// clocks made with Clock run.
+func (f *Faults) Skew(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.skew = d
+}
+
+// Reset switches every fault off.
+func (f *Faults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing, f.skew = nil, 0
+}
+
+// State returns what is injected.
+func (f *Faults) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := State{Failing: make(map[Point]int, len(f.failing)), Skew: f.skew}
+	for p, n := range f.failing {
+		s.Failing[p] = max(n, 0)
+	}
+	return s
+}
+
+// Set replaces what is injected with s.
+func (f *Faults) Set(s State) error {
+	var invalid []string
+	for p := range s.Failing {
+		if !p.Valid() {
+			invalid = append(invalid, string(p))
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("%w %v (want one of %v)", ErrUnknownPoint, invalid, Points)
+	}
+	f.Reset()
+	for p, n := range s.Failing {
+		if n == 0 {
+			n = -1
+		}
+		f.FailNext(p, n)
+	}
+	f.Skew(s.Skew)
+	return nil
+}
+
+// Check returns ErrInjected when the call at p is to fail, counting it
+// against the calls left to fail there.
+func (f *Faults) Check(p Point) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, ok := f.failing[p]
+	if !ok {
+		return nil
+	}
+	if n > 0 {
+		if n == 1 {
+			delete(f.failing, p)
+		} else {
+			f.failing[p] = n - 1
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrInjected, p)
+}
+
+func (f *Faults) now(c models.Clock) time.Time {
+	f.mu.Lock()
+	skew := f.skew
+	f.mu.Unlock()
+	if c == nil {
+		return time.Now().Add(skew)
+	}
+	return c.Now().Add(skew)
+}
+
+// Clock returns c, the wall clock when nil, skewed by the Skew of f.
+func (f *Faults) Clock(c models.Clock) models.Clock {
+	return skewedClock{f, c}
+}
+
+type skewedClock struct {
+	faults *Faults
+	clock  models.Clock
+}
+
+func (c skewedClock) Now() time.Time {
+	return c.faults.now(c.clock)
+}
+
+// Transport returns rt, http.DefaultTransport when nil, failing requests
+// at Webhook before they are sent.
+func (f *Faults) Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		if err := f.Check(Webhook); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), err)
+		}
+		return rt.RoundTrip(req)
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt(req)
+}
+
+// Store returns s failing its writes at StoreWrite. Reads always succeed,
+// and a store with an outbox keeps it, so that a fault keeps events from
+// being written along with the entries that failed to save.
+func (f *Faults) Store(s store.Store) store.Store {
+	fs := &faultyStore{Store: s, faults: f}
+	if o, ok := s.(store.Outbox); ok {
+		return &faultyOutboxStore{faultyStore: fs, Outbox: o}
+	}
+	return fs
+}
+
+type faultyStore struct {
+	store.Store
+	faults *Faults
+}
+
+type faultyOutboxStore struct {
+	*faultyStore
+	store.Outbox
+}
+
+func (s *faultyStore) SaveAccount(account models.BankAccount) error {
+	if err := s.faults.Check(StoreWrite); err != nil {
+		return err
+	}
+	return s.Store.SaveAccount(account)
+}
+
+func (s *faultyStore) SaveCustomer(customer *models.Customer) error {
+	if err := s.faults.Check(StoreWrite); err != nil {
+		return err
+	}
+	return s.Store.SaveCustomer(customer)
+}
+
+// SaveAccounts saves accounts in one batch when the store can, so that a
+// fault fails the whole batch as a failed database transaction would.
+func (s *faultyStore) SaveAccounts(accounts []models.BankAccount) error {
+	if err := s.faults.Check(StoreWrite); err != nil {
+		return err
+	}
+	if b, ok := s.Store.(interface {
+		SaveAccounts([]models.BankAccount) error
+	}); ok {
+		return b.SaveAccounts(accounts)
+	}
+	for _, account := range accounts {
+		if err := s.Store.SaveAccount(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lifecycles and SaveLifecycles pass through to stores that keep when
+// accounts were closed and deleted; others have none to keep.
+func (s *faultyStore) Lifecycles() (map[string]models.Lifecycle, error) {
+	if ls, ok := s.Store.(interface {
+		Lifecycles() (map[string]models.Lifecycle, error)
+	}); ok {
+		return ls.Lifecycles()
+	}
+	return nil, nil
+}
+
+func (s *faultyStore) SaveLifecycles(lifecycles map[string]models.Lifecycle) error {
+	if err := s.faults.Check(StoreWrite); err != nil {
+		return err
+	}
+	if ls, ok := s.Store.(interface {
+		SaveLifecycles(map[string]models.Lifecycle) error
+	}); ok {
+		return ls.SaveLifecycles(lifecycles)
+	}
+	return nil
+}
+
+// Snapshot and SaveSnapshot pass through to stores that keep a snapshot of
+// the bank; others have none to keep.
+func (s *faultyStore) Snapshot() ([]byte, error) {
+	if ss, ok := s.Store.(interface{ Snapshot() ([]byte, error) }); ok {
+		return ss.Snapshot()
+	}
+	return nil, nil
+}
+
+func (s *faultyStore) SaveSnapshot(snapshot []byte) error {
+	if err := s.faults.Check(StoreWrite); err != nil {
+		return err
+	}
+	if ss, ok := s.Store.(interface{ SaveSnapshot([]byte) error }); ok {
+		return ss.SaveSnapshot(snapshot)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+	"gsolano/banking/store"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// TestStoreWriteFault fails saves to a store with an outbox and checks that
+// a failed save writes neither entries nor their events, and that the next
+// save writes each of them once.
+func TestStoreWriteFault(t *testing.T) {
+	models.SetOutput(io.Discard)
+	faults := &Faults{}
+	sqlite, err := store.OpenSQLite(filepath.Join(t.TempDir(), "bank.db"), store.WithOutbox())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	st := faults.Store(sqlite)
+	outbox, ok := st.(store.Outbox)
+	if !ok {
+		t.Fatal("the faulty store hides the outbox")
+	}
+
+	bank := models.NewBank()
+	if err := bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range []float64{100, 50} {
+		if err := bank.Deposit("C1", amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := func(want int) {
+		t.Helper()
+		events, err := outbox.PendingEvents(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != want {
+			t.Fatalf("%d events pending, want %d", len(events), want)
+		}
+	}
+
+	faults.FailNext(StoreWrite, 1)
+	err = store.Save(st, bank)
+	if !errors.Is(err, ErrInjected) || !banking.IsRetryable(err) {
+		t.Fatalf("save under a fault: %v, want a retryable injected fault", err)
+	}
+	pending(0)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("save after the fault: %v", err)
+	}
+	pending(2)
+
+	faults.Fail(StoreWrite)
+	if err := bank.Deposit("C1", 25); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.Save(st, bank); !errors.Is(err, ErrInjected) {
+			t.Fatalf("save %d while failing: %v", i+1, err)
+		}
+	}
+	faults.Clear(StoreWrite)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatal(err)
+	}
+	pending(3)
+
+	loaded := models.NewBank()
+	if err := store.Load(st, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := loaded.Balance("C1"); balance != 175 {
+		t.Errorf("loaded balance %v, want 175", balance)
+	}
+}
+
+// TestWebhookDown relays an outbox to an endpoint that is down for a while
+// and checks that no event is lost, repeated or delivered out of order.
+func TestWebhookDown(t *testing.T) {
+	var mu sync.Mutex
+	var received []int64
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e store.OutboxEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, e.ID)
+		mu.Unlock()
+	}))
+	defer endpoint.Close()
+
+	faults := &Faults{}
+	client := &http.Client{Transport: faults.Transport(nil)}
+	post := func(e store.OutboxEvent) error {
+		data, _ := json.Marshal(e)
+		resp, err := client.Post(endpoint.URL, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("event %d: %s", e.ID, resp.Status)
+		}
+		return nil
+	}
+	outbox := &memoryOutbox{}
+	for id := int64(1); id <= 5; id++ {
+		outbox.events = append(outbox.events, store.OutboxEvent{ID: id, Type: models.EventTransactionPosted, Account: "C1"})
+	}
+
+	faults.Fail(Webhook)
+	if n, err := store.Relay(outbox, post); n != 0 || !errors.Is(err, ErrInjected) {
+		t.Fatalf("relay while down: %d published, %v", n, err)
+	}
+	// It comes back after two more attempts; each fails at the first
+	// event and publishes nothing after it.
+	faults.FailNext(Webhook, 2)
+	for i := 0; i < 2; i++ {
+		if n, err := store.Relay(outbox, post); n != 0 || err == nil {
+			t.Fatalf("relay %d: %d published, %v", i+1, n, err)
+		}
+	}
+	if n, err := store.Relay(outbox, post); n != 5 || err != nil {
+		t.Fatalf("relay once up: %d published, %v", n, err)
+	}
+	if fmt.Sprint(received) != "[1 2 3 4 5]" {
+		t.Errorf("received %v", received)
+	}
+}
+
+// TestClockSkew runs the bank's clock behind and checks entries take the
+// skewed time.
+func TestClockSkew(t *testing.T) {
+	models.SetOutput(io.Discard)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	faults := &Faults{}
+	bank := models.NewBank()
+	bank.Clock = faults.Clock(fixedClock(now))
+	if err := bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := faults.Set(State{Skew: -36 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.Deposit("C1", 10); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := bank.Account("C1")
+	if got := account.History()[0].Time; !got.Equal(now.Add(-36 * time.Hour)) {
+		t.Errorf("entry at %v, want 36 hours before %v", got, now)
+	}
+	if err := faults.Set(State{Failing: map[Point]int{"disk": 1}}); !errors.Is(err, ErrUnknownPoint) {
+		t.Errorf("unknown point: %v", err)
+	}
+	if got := faults.State(); got.Skew != -36*time.Hour {
+		t.Errorf("a rejected Set changed the state to %+v", got)
+	}
+}
+
+// memoryOutbox is an outbox of events held in memory.
+type memoryOutbox struct {
+	events []store.OutboxEvent
+}
+
+func (o *memoryOutbox) PendingEvents(limit int) ([]store.OutboxEvent, error) {
+	return o.events[:min(limit, len(o.events))], nil
+}
+
+func (o *memoryOutbox) MarkPublished(ids ...int64) error {
+	for _, id := range ids {
+		for i, e := range o.events {
+			if e.ID == id {
+				o.events = append(o.events[:i:i], o.events[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
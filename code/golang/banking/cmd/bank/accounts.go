@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsolano/banking/config"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/store"
+	"gsolano/banking/validate"
+)
+
+func init() {
+	register(command{
+		name:    "accounts",
+		summary: "list, delete, restore and renumber accounts, or open them in bulk from a CSV file",
+		run:     runAccounts,
+	})
+}
+
+// defaultPurchaseRate is the rate of imported cards without a rate column.
+var defaultPurchaseRate = money.Percent(20)
+
+// importRow is an account to open, read from one line of the CSV file.
+type importRow struct {
+	line      int
+	account   models.BankAccount
+	owner     string
+	ownerName string
+	deposit   float64
+}
+
+const accountsUsage = `usage: bank accounts list [-config file] [-include-deleted]
+       bank accounts delete|restore [-config file] number
+       bank accounts renumber [-config file] number new-number
+       bank accounts import [-config file] [-dry-run] accounts.csv`
+
+func runAccounts(args []string) error {
+	if len(args) == 0 {
+		return usageError(accountsUsage)
+	}
+	switch args[0] {
+	case "import":
+		return runImport(args)
+	case "list":
+		return runListAccounts(args)
+	case "delete", "restore":
+		return runDeleteAccount(args)
+	case "renumber":
+		return runRenumber(args)
+	default:
+		return usageError(accountsUsage)
+	}
+}
+
+// loadStore loads the bank kept in the configured store, which must not be
+// the memory store, and returns it with the open store.
+func loadStore(path string) (*models.Bank, store.Store, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Store.Driver == "memory" {
+		return nil, nil, errors.New("store.driver: the memory store holds no accounts between runs")
+	}
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	if cfg.Archive.DeleteGrace > 0 {
+		bank.DeleteGrace = cfg.Archive.DeleteGrace
+	}
+	if cfg.Opening.Timeout > 0 {
+		bank.ApplicationTimeout = cfg.Opening.Timeout
+	}
+	if cfg.Inbox.Retention > 0 {
+		bank.MessageRetention = cfg.Inbox.Retention
+	}
+	if cfg.Sessions.TTL > 0 {
+		bank.SessionTTL = cfg.Sessions.TTL
+	}
+	if cfg.Sessions.StepUpWindow > 0 {
+		bank.StepUpWindow = cfg.Sessions.StepUpWindow
+	}
+	bank.StepUpAmount = cfg.Sessions.StepUpAmount
+	bank.DormancyMonths = cfg.Dormancy.Months
+	bank.EscheatMonths = cfg.Dormancy.EscheatMonths
+	bank.Reserves = cfg.Reserves.Ratios()
+	bank.IDs = cfg.IDGenerator()
+	bank.Location, _ = cfg.Location()
+	bank.ReceiptKey = []byte(cfg.ReceiptKey)
+	bank.SecretKey = []byte(cfg.SecretKey)
+	st, err := openStore(cfg.Store)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := store.Load(st, bank); err != nil {
+		st.Close()
+		return nil, nil, err
+	}
+	return bank, st, nil
+}
+
+// runListAccounts lists the accounts of the store. Deleted accounts are
+// left out unless -include-deleted asks for them, for admins reviewing
+// what can still be restored.
+func runListAccounts(args []string) error {
+	fs := newFlagSet("accounts list")
+	path := configFlag(fs)
+	includeDeleted := fs.Bool("include-deleted", false, "also list soft-deleted accounts")
+	fs.Parse(args[1:])
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	t := newTable("number", "kind", "balance", "status", "since")
+	for _, account := range bank.ListAccounts(*includeDeleted) {
+		status, since := "open", time.Time{}
+		if l := bank.Lifecycle(account.Number()); !l.Deleted.IsZero() {
+			status, since = "deleted", l.Deleted
+		} else if !l.Closed.IsZero() {
+			status, since = "closed", l.Closed
+		}
+		t.add(account.Number(), models.KindOf(account), account.CheckBalance(), status, since)
+	}
+	return t.print(outputTable)
+}
+
+// runDeleteAccount soft-deletes or restores an account and saves the store.
+func runDeleteAccount(args []string) error {
+	fs := newFlagSet("accounts " + args[0])
+	path := configFlag(fs)
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		return usageError(accountsUsage)
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	change, done := bank.DeleteAccount, "deleted"
+	if args[0] == "restore" {
+		change, done = bank.RestoreAccount, "restored"
+	}
+	if err := change(fs.Arg(0)); err != nil {
+		return err
+	}
+	if err := store.Save(st, bank); err != nil {
+		return err
+	}
+	note("%s account %s", done, fs.Arg(0))
+	return nil
+}
+
+// runRenumber gives an account a new number and saves the store, which
+// keeps a redirect from the old one.
+func runRenumber(args []string) error {
+	fs := newFlagSet("accounts renumber")
+	path := configFlag(fs)
+	fs.Parse(args[1:])
+	if fs.NArg() != 2 {
+		return usageError(accountsUsage)
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	number, err := bank.Resolve(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if _, err := bank.RenumberAccount(number, fs.Arg(1)); err != nil {
+		return err
+	}
+	if err := store.Save(st, bank); err != nil {
+		return err
+	}
+	note("renumbered account %s to %s", number, fs.Arg(1))
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := newFlagSet("accounts import")
+	path := configFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "validate the file and show what would be opened without opening anything")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		return usageError(accountsUsage)
+	}
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		return err
+	}
+	if cfg.Store.Driver == "memory" && !*dryRun {
+		return errors.New("store.driver: accounts imported into the memory store would be lost")
+	}
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	var st store.Store
+	if cfg.Store.Driver != "memory" {
+		if st, err = openStore(cfg.Store); err != nil {
+			return err
+		}
+		defer st.Close()
+		if err := store.Load(st, bank); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, problems := readAccountsCSV(f, bank, cfg)
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return fmt.Errorf("%d invalid rows; nothing was opened", len(problems))
+	}
+
+	if *dryRun {
+		t := newTable("line", "kind", "number", "owner", "deposit")
+		for _, row := range rows {
+			t.add(row.line, models.KindOf(row.account), row.account.Number(), row.owner, row.deposit)
+		}
+		if err := t.print(outputTable); err != nil {
+			return err
+		}
+		note("%d accounts valid; nothing was opened", len(rows))
+		return nil
+	}
+	for _, row := range rows {
+		if err := openRow(bank, row); err != nil {
+			return fmt.Errorf("line %d: %w", row.line, err)
+		}
+	}
+	if err := store.Save(st, bank); err != nil {
+		return err
+	}
+	note("opened %d accounts", len(rows))
+	return nil
+}
+
+// readAccountsCSV reads the accounts to open. The file has a header naming
+// its columns: number, type (savings, checking or credit_card) and owner, a
+// customer ID, are required; deposit, owner_name, rate, overdraft_limit and
+// credit_limit are optional. Every row is checked, against the bank and
+// the rows before it, and every problem is returned.
+func readAccountsCSV(r io.Reader, bank *models.Bank, cfg config.Config) ([]importRow, []string) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, []string{"header: " + err.Error()}
+	}
+	col := make(map[string]int)
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"number", "type", "owner"} {
+		if _, ok := col[required]; !ok {
+			return nil, []string{"header: no " + required + " column"}
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	amount := func(record []string, name string, dflt float64) (float64, error) {
+		text := field(record, name)
+		if text == "" {
+			return dflt, nil
+		}
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil || !(v >= 0 && v <= models.MaxAmount) {
+			return 0, fmt.Errorf("%s: %q is not an amount between 0 and %.0f", name, text, models.MaxAmount)
+		}
+		return v, nil
+	}
+
+	var rows []importRow
+	var problems []string
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, problems
+		}
+		if err != nil {
+			return nil, append(problems, err.Error())
+		}
+		var errs []string
+		report := func(err error) {
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		row := importRow{line: line, owner: field(record, "owner"), ownerName: field(record, "owner_name")}
+		number := field(record, "number")
+		var v validate.Validator
+		v.AccountNumber("number", number)
+		switch _, err := bank.Account(number); {
+		case v.Err() != nil:
+			report(v.Err())
+		case seen[number] > 0:
+			errs = append(errs, fmt.Sprintf("account %s already appears on line %d", number, seen[number]))
+		case err == nil || errors.Is(err, models.ErrAccountArchived):
+			errs = append(errs, fmt.Sprintf("account %s already exists", number))
+		}
+		seen[number] = line
+		if row.owner == "" {
+			errs = append(errs, "owner is required")
+		}
+		row.deposit, err = amount(record, "deposit", 0)
+		report(err)
+		if err == nil && row.deposit > 0 {
+			var v validate.Validator
+			v.Amount("deposit", row.deposit, "")
+			report(v.Err())
+		}
+		overdraft, err := amount(record, "overdraft_limit", cfg.Limits.Overdraft)
+		report(err)
+		creditLimit, err := amount(record, "credit_limit", 0)
+		report(err)
+		rate := cfg.Interest.SavingsRate
+		if text := field(record, "rate"); text != "" {
+			if err := rate.UnmarshalText([]byte(text)); err != nil {
+				errs = append(errs, fmt.Sprintf("rate: %q is not a rate", text))
+			}
+		}
+
+		account := models.Account{AccountNumber: number}
+		switch kind := strings.ToLower(field(record, "type")); kind {
+		case "savings":
+			row.account = &models.SavingsAccount{Account: account, InterestRate: rate}
+		case "checking":
+			row.account = &models.CheckingAccount{Account: account, OverdraftLimit: overdraft}
+		case "credit_card":
+			if creditLimit <= 0 {
+				errs = append(errs, "credit_limit is required for credit cards")
+			}
+			if row.deposit > 0 {
+				errs = append(errs, "credit cards cannot be opened with a deposit")
+			}
+			card := &models.CreditCardAccount{Account: account, CreditLimit: creditLimit, PurchaseRate: defaultPurchaseRate}
+			if field(record, "rate") != "" {
+				card.PurchaseRate = rate
+			}
+			row.account = card
+		case "loan":
+			errs = append(errs, "loans are opened after a credit check, with POST /api/customers/{id}/loans")
+		default:
+			errs = append(errs, fmt.Sprintf("type: %q is not savings, checking or credit_card", kind))
+		}
+
+		if len(errs) > 0 {
+			problems = append(problems, fmt.Sprintf("line %d: %s", line, strings.Join(errs, "; ")))
+			continue
+		}
+		rows = append(rows, row)
+	}
+}
+
+// openRow opens the account of a row for its owner, adding the owner when
+// they are not a customer yet, and pays in the initial deposit.
+func openRow(bank *models.Bank, row importRow) error {
+	if _, err := bank.Customer(row.owner); errors.Is(err, models.ErrCustomerNotFound) {
+		name := row.ownerName
+		if name == "" {
+			name = row.owner
+		}
+		if err := bank.AddCustomer(&models.Customer{ID: row.owner, Name: name}); err != nil {
+			return err
+		}
+	}
+	if err := bank.OpenFor(row.owner, row.account); err != nil {
+		return err
+	}
+	if row.deposit > 0 {
+		return bank.Deposit(row.account.Number(), row.deposit, models.WithDescription("initial deposit"))
+	}
+	return nil
+}
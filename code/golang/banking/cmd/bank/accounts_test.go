@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gsolano/banking/config"
+	"gsolano/banking/models"
+)
+
+// FuzzReadAccountsCSV checks that no file, however malformed, makes the
+// importer panic, open anything while reading, or accept a row that then
+// fails to open or leaves a balance that is not a number.
+func FuzzReadAccountsCSV(f *testing.F) {
+	f.Add("number,type,owner,owner_name,deposit,rate,overdraft_limit,credit_limit\nS1,savings,c1,Ada,1000,3%,,\nC1,checking,c1,,250,,500,\nK1,credit_card,c2,Bob,,,,3000\n")
+	f.Add("number,type,owner,deposit\nS1,savings,c1,NaN\nS1,loan,,\n,checking,c1,-4\n")
+	f.Add("number,type,owner,deposit\nX1,savings,c9,1e308\nX2,credit_card,c9,\n")
+	f.Add("number,type,owner,deposit\nN1,savings,c1,NaN\nN2,checking,c1,2e15\n")
+	f.Add("type,owner\nsavings,c1\n")
+	f.Add("number,type,owner,rate\n12345,savings,c1,1/0\n")
+	f.Add("\"")
+	f.Fuzz(func(t *testing.T, file string) {
+		models.SetOutput(io.Discard)
+		bank := models.NewBank()
+		bank.AddCustomer(&models.Customer{ID: "c1", Name: "Existing"})
+		bank.OpenFor("c1", &models.SavingsAccount{Account: models.Account{AccountNumber: "12345"}})
+
+		rows, problems := readAccountsCSV(strings.NewReader(file), bank, config.Default())
+		if n := len(bank.Accounts()); n != 1 {
+			t.Fatalf("reading the file opened %d accounts", n-1)
+		}
+		if len(problems) > 0 {
+			return
+		}
+		for _, row := range rows {
+			if err := openRow(bank, row); err != nil {
+				t.Fatalf("line %d was valid but did not open: %v", row.line, err)
+			}
+			balance, _ := bank.Balance(row.account.Number())
+			if math.IsNaN(balance) || math.IsInf(balance, 0) {
+				t.Fatalf("line %d opened %s with balance %v", row.line, row.account.Number(), balance)
+			}
+		}
+	})
+}
+
+// TestImportAccounts imports a file into a JSON store and checks the
+// accounts, owners and deposits opened, then imports a file with a bad row
+// and checks that nothing of it was opened.
+func TestImportAccounts(t *testing.T) {
+	defer func(w io.Writer) { stdout = w }(stdout)
+	stdout = io.Discard
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bank.yaml")
+	if err := os.WriteFile(path, []byte("store:\n  driver: json\n  dsn: "+filepath.Join(dir, "bank.json")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	good := write("good.csv", "number,type,owner,owner_name,deposit,rate,overdraft_limit,credit_limit\n"+
+		"S1,savings,c1,Ada,1000,3%,,\nC1,checking,c1,,250,,500,\nK1,credit_card,c2,Bob,,,,3000\n")
+	if err := runImport([]string{"import", "-config", path, good}); err != nil {
+		t.Fatal(err)
+	}
+	bad := write("bad.csv", "number,type,owner,deposit\nS2,savings,c1,10\nS1,savings,c1,\nL1,loan,c1,\n")
+	if err := runImport([]string{"import", "-config", path, bad}); err == nil || !strings.Contains(err.Error(), "2 invalid rows") {
+		t.Errorf("importing a file with bad rows: %v, want 2 invalid rows", err)
+	}
+
+	bank, st, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if n := len(bank.Accounts()); n != 3 {
+		t.Errorf("store has %d accounts, want 3", n)
+	}
+	for number, want := range map[string]float64{"S1": 1000, "C1": 250, "K1": 0} {
+		if got, _ := bank.Balance(number); got != want {
+			t.Errorf("%s balance = %.2f, want %.2f", number, got, want)
+		}
+	}
+	if c, err := bank.Customer("c2"); err != nil || c.Name != "Bob" || len(c.Accounts) != 1 {
+		t.Errorf("customer c2 = %+v, %v, want Bob owning K1", c, err)
+	}
+	if c, _ := bank.Account("C1"); c.(*models.CheckingAccount).OverdraftLimit != 500 {
+		t.Errorf("C1 overdraft limit %.2f, want 500.00", c.(*models.CheckingAccount).OverdraftLimit)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	register(command{
+		name:    "books",
+		summary: "print the trial balance and balance sheet of the bank's own books",
+		run:     runBooks,
+	})
+}
+
+// runBooks prints the trial balance and balance sheet of the store in each
+// currency, and fails when the books do not balance, which means a ledger
+// is not what its entries add up to.
+func runBooks(args []string) error {
+	fs := newFlagSet("books")
+	path := configFlag(fs)
+	at := fs.String("at", "", "last day included, 2006-01-02; every entry by default")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usageError("usage: bank books [-config file] [-at date]")
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	var asOf time.Time
+	if *at != "" {
+		day, err := time.ParseInLocation(time.DateOnly, *at, time.Local)
+		if err != nil {
+			return errors.New("-at: " + err.Error())
+		}
+		asOf = day.AddDate(0, 0, 1)
+	}
+
+	// The lines go to a table too, for -output json and csv.
+	var text bytes.Buffer
+	tw := tabwriter.NewWriter(&text, 0, 0, 2, ' ', 0)
+	t := newTable("report", "currency", "code", "name", "debit", "credit", "balance")
+	unbalanced := 0
+	for _, tb := range bank.TrialBalance(asOf) {
+		fmt.Fprintf(tw, "trial balance %s\tdebit\tcredit\n", tb.Currency)
+		for _, l := range tb.Lines {
+			fmt.Fprintf(tw, "%s %s\t%.2f\t%.2f\n", l.Code, l.Name, l.Debit, l.Credit)
+			t.add("trial_balance", tb.Currency, l.Code, l.Name, l.Debit, l.Credit, l.Debit-l.Credit)
+		}
+		fmt.Fprintf(tw, "total\t%.2f\t%.2f\n\n", tb.Debits, tb.Credits)
+		if !tb.Balanced {
+			unbalanced++
+		}
+	}
+	for _, bs := range bank.BalanceSheet(asOf) {
+		fmt.Fprintf(tw, "balance sheet %s\t\t\n", bs.Currency)
+		for _, l := range append(append(bs.Assets, bs.Liabilities...), bs.Equity...) {
+			fmt.Fprintf(tw, "%s %s\t%.2f\t\n", l.Code, l.Name, l.Balance)
+			t.add("balance_sheet", bs.Currency, l.Code, l.Name, 0.0, 0.0, l.Balance)
+		}
+		fmt.Fprintf(tw, "earnings\t%.2f\t\n", bs.Earnings)
+		fmt.Fprintf(tw, "assets\t%.2f\t\n", bs.TotalAssets)
+		fmt.Fprintf(tw, "liabilities and equity\t%.2f\t\n\n", bs.TotalLiabilities+bs.TotalEquity)
+		if !bs.Balanced {
+			unbalanced++
+		}
+	}
+	tw.Flush()
+	if machine() {
+		err = t.print(outputTable)
+	} else {
+		_, err = stdout.Write(text.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+	if unbalanced > 0 {
+		return fmt.Errorf("the books do not balance in %d reports", unbalanced)
+	}
+	return nil
+}
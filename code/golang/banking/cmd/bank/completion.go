@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+func init() {
+	register(command{
+		name:    "completion",
+		summary: "print the shell completion script for bash, zsh or fish",
+		run:     runCompletion,
+	})
+	register(command{
+		name:    "__complete",
+		summary: "print the completions of a command line, for the completion scripts",
+		run:     runComplete,
+	})
+}
+
+// subcommands are the subcommands of the commands that dispatch on their
+// first argument.
+var subcommands = map[string][]string{
+	"accounts": {"delete", "import", "list", "renumber", "restore"},
+	"config":   {"show", "validate"},
+}
+
+// Completion scripts ask bank __complete for the words that can follow the
+// line typed so far, and fall back to file names when it has none.
+var completionScripts = map[string]string{
+	"bash": `_bank() {
+	local IFS=$'\n'
+	COMPREPLY=($(bank __complete "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _bank bank
+`,
+	"zsh": `#compdef bank
+_bank() {
+	local -a completions
+	completions=("${(@f)$(bank __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n ${completions[1]} ]]; then
+		compadd -a completions
+	else
+		_files
+	fi
+}
+compdef _bank bank
+`,
+	"fish": `function __bank_complete
+	bank __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c bank -a '(__bank_complete)'
+`,
+}
+
+func runCompletion(args []string) error {
+	if len(args) != 1 || completionScripts[args[0]] == "" {
+		return usageError("usage: bank completion bash|zsh|fish")
+	}
+	_, err := io.WriteString(stdout, completionScripts[args[0]])
+	return err
+}
+
+// runComplete prints the words that complete the last argument, given the
+// ones before it: commands, subcommands, flags or account numbers.
+func runComplete(args []string) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	words, current := args[:len(args)-1], args[len(args)-1]
+	for _, c := range completions(words, current) {
+		if strings.HasPrefix(c, current) {
+			fmt.Fprintln(stdout, c)
+		}
+	}
+	return nil
+}
+
+func completions(words []string, current string) []string {
+	if len(words) == 0 {
+		var names []string
+		for name := range commands {
+			if !strings.HasPrefix(name, "__") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	line := words[:1]
+	if subs := subcommands[words[0]]; subs != nil {
+		if len(words) == 1 {
+			return subs
+		}
+		line = words[:2]
+	}
+	if strings.HasPrefix(current, "-") {
+		return flagsOf(line)
+	}
+	if words[0] == "transfer" || (words[0] == "accounts" && (words[1] == "delete" || words[1] == "restore" || words[1] == "renumber")) {
+		return accountNumbers()
+	}
+	return nil
+}
+
+var (
+	// describing is set while flagsOf runs a command for its flags, and
+	// described is the flag set it made.
+	describing bool
+	described  *flag.FlagSet
+)
+
+// flagsOf returns the flags of a command line such as "accounts list". It
+// runs the command with -h, which every command parses before it does
+// anything else, and reads the flags off the flag set it made.
+func flagsOf(line []string) (flags []string) {
+	c, ok := commands[line[0]]
+	if !ok {
+		return nil
+	}
+	describing, described = true, nil
+	defer func() {
+		describing = false
+		p := recover()
+		if e, ok := p.(error); p != nil && (!ok || !errors.Is(e, flag.ErrHelp)) {
+			panic(p)
+		}
+		if described == nil {
+			return
+		}
+		described.VisitAll(func(f *flag.Flag) {
+			flags = append(flags, "-"+f.Name)
+		})
+	}()
+	c.run(append(line[1:], "-h"))
+	return nil
+}
+
+// accountNumbers returns the numbers of the accounts of the configured
+// store, none when it cannot be loaded.
+func accountNumbers() []string {
+	bank, st, err := loadStore(os.Getenv("BANK_CONFIG"))
+	if err != nil {
+		return nil
+	}
+	defer st.Close()
+	var numbers []string
+	for _, account := range bank.ListAccounts(false) {
+		numbers = append(numbers, account.Number())
+	}
+	sort.Strings(numbers)
+	return numbers
+}
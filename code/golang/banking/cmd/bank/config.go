@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"gsolano/banking/config"
+	"gsolano/banking/store"
+	_ "gsolano/banking/store/postgres"
+)
+
+func init() {
+	register(command{
+		name:    "config",
+		summary: "validate or show the effective configuration",
+		run:     runConfig,
+	})
+}
+
+// configFlag adds the -config flag shared by commands that need settings.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", os.Getenv("BANK_CONFIG"), "path to a YAML or TOML config file (default $BANK_CONFIG)")
+}
+
+// openStore opens the configured store with its batch, pool and outbox
+// settings. Saves from the CLI must write the outbox too: an entry saved
+// without it never gets an event.
+func openStore(cfg config.Store) (store.Store, error) {
+	opts := []store.Option{store.WithBatchSize(cfg.BatchSize),
+		store.WithPool(store.Pool{MaxConns: cfg.MaxConns, MinConns: cfg.MinConns, MaxConnIdleTime: cfg.MaxConnIdleTime})}
+	if cfg.OutboxURL != "" {
+		opts = append(opts, store.WithOutbox())
+	}
+	return store.Open(cfg.Driver, cfg.DSN, opts...)
+}
+
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: bank config validate|show [-config file]")
+	}
+	fs := newFlagSet("config " + args[0])
+	path := configFlag(fs)
+	fs.Parse(args[1:])
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "validate":
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		note("configuration is valid")
+		return nil
+	case "show":
+		if format == outputJSON {
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(cfg)
+		}
+		enc := yaml.NewEncoder(stdout)
+		enc.SetIndent(2)
+		return enc.Encode(cfg)
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
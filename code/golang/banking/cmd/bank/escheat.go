@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+func init() {
+	register(command{
+		name:    "escheat",
+		summary: "export the long-dormant balances due as unclaimed property as CSV",
+		run:     runEscheat,
+	})
+}
+
+// runEscheat writes the escheatment report of the store to standard output,
+// one row per account, for filing with the state.
+func runEscheat(args []string) error {
+	fs := newFlagSet("escheat")
+	path := configFlag(fs)
+	at := fs.String("at", "", "date the balances are reported at, 2006-01-02; today by default")
+	months := fs.Int("months", 0, "months without activity after which balances are due; dormancy.escheat_months by default")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usageError("usage: bank escheat [-config file] [-at date] [-months n]")
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if *months > 0 {
+		bank.EscheatMonths = *months
+	}
+	if bank.EscheatMonths == 0 {
+		return errors.New("dormancy.escheat_months: not set; pass -months")
+	}
+	when := time.Now()
+	if *at != "" {
+		if when, err = time.ParseInLocation(time.DateOnly, *at, time.Local); err != nil {
+			return errors.New("-at: " + err.Error())
+		}
+	}
+
+	t := newTable("number", "customers", "balance", "currency", "last_activity", "dormant_since")
+	for _, e := range bank.Escheatment(when) {
+		var dormant time.Time
+		if e.DormantSince != nil {
+			dormant = *e.DormantSince
+		}
+		t.add(e.Number, e.Customers, e.Balance, e.Currency, e.LastActivity, dormant)
+	}
+	return t.print(outputCSV)
+}
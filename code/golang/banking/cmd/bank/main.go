@@ -0,0 +1,128 @@
+// Command bank is the command-line interface to the bank.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gsolano/banking"
+)
+
+// command is a subcommand of bank. Commands with subcommands dispatch on
+// their first argument themselves.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(c command) {
+	commands[c.name] = c
+}
+
+// interactive is set while the REPL runs commands: a bad flag then fails
+// the command instead of ending the session.
+var interactive bool
+
+// newFlagSet returns the flag set of a command, with the -output and -quiet
+// flags every command takes.
+func newFlagSet(name string) *flag.FlagSet {
+	handling := flag.ExitOnError
+	if interactive || describing {
+		handling = flag.PanicOnError
+	}
+	fs := flag.NewFlagSet(name, handling)
+	fs.Var(&format, "output", "format of results: table, json or csv")
+	fs.BoolVar(&quiet, "quiet", quiet, "print results only, without messages")
+	if describing {
+		fs.SetOutput(io.Discard)
+		described = fs
+	}
+	return fs
+}
+
+// usageError is the usage of a command, returned for arguments it cannot
+// make sense of.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// exitCodes are the exit codes of errors by class, so that scripts can tell
+// them apart. 1 is any other failure and 2 a command used wrongly.
+var exitCodes = []struct {
+	code banking.Code
+	exit int
+}{
+	{banking.CodeInvalidArgument, 3},
+	{banking.CodeNotFound, 4},
+	{banking.CodeConflict, 5},
+	{banking.CodeInsufficientFunds, 6},
+	{banking.CodeLimitExceeded, 7},
+	{banking.CodeAccountFrozen, 8},
+	{banking.CodePermissionDenied, 9},
+	{banking.CodeFeatureDisabled, 10},
+	{banking.CodeUnavailable, 11},
+	{banking.CodeRateLimited, 12},
+	{banking.CodeUnauthenticated, 13},
+}
+
+func exitCode(err error) int {
+	if errors.As(err, new(usageError)) {
+		return 2
+	}
+	code := banking.CodeOf(err)
+	for _, c := range exitCodes {
+		if c.code == code {
+			return c.exit
+		}
+	}
+	return 1
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: bank <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "__") {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "every command takes -output table|json|csv and -quiet.")
+	fmt.Fprintln(os.Stderr, "exit codes: 0 done, 1 failed, 2 usage")
+	for _, c := range exitCodes {
+		fmt.Fprintf(os.Stderr, "  %-10d %s\n", c.exit, c.code)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		usage()
+		os.Exit(2)
+	}
+	c, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "bank: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := c.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "bank %s: %v\n", c.name, err)
+		os.Exit(exitCode(err))
+	}
+}
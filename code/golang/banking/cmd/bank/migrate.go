@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"gsolano/banking/store"
+)
+
+func init() {
+	register(command{
+		name:    "migrate",
+		summary: "copy all customers and accounts from one store backend to another",
+		run:     runMigrate,
+	})
+}
+
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate")
+	backends := strings.Join(store.Backends, ", ")
+	from := fs.String("from", "json", "source backend ("+backends+")")
+	to := fs.String("to", "sqlite", "target backend ("+backends+")")
+	source := fs.String("source", "bank.json", "path of the source store")
+	target := fs.String("target", "bank.db", "path of the target store, which must be empty")
+	fs.Parse(args)
+	if *from == *to && *source == *target {
+		return errors.New("source and target are the same store")
+	}
+	// Opening a store creates it, which would hide a mistyped source path.
+	if _, err := os.Stat(*source); err != nil {
+		return err
+	}
+
+	src, err := store.Open(*from, *source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := store.Open(*to, *target)
+	if err != nil {
+		return err
+	}
+	report, err := store.Migrate(src, dst)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	note("migrated %d customers, %d accounts and %d transactions from %s to %s; balances and transaction counts verified\n",
+		report.Customers, report.Accounts, report.Transactions, *source, *target)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+	"gsolano/banking/store"
+)
+
+// TestMigrate copies a JSON store into a new SQLite one, checking what is
+// reported and loaded from the target, and that a missing source, the same
+// store on both sides and a target that is not empty are refused.
+func TestMigrate(t *testing.T) {
+	models.SetOutput(io.Discard)
+	defer func(w io.Writer) { stdout = w }(stdout)
+	var out strings.Builder
+	stdout = &out
+	dir := t.TempDir()
+	source, target := filepath.Join(dir, "bank.json"), filepath.Join(dir, "bank.db")
+	st, err := store.Open("json", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank := models.NewBank()
+	bank.AddCustomer(&models.Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}})
+	bank.Open(&models.SavingsAccount{Account: models.Account{AccountNumber: "S1"}})
+	bank.Deposit("C1", 100)
+	bank.Transfer("C1", "S1", 40)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	args := []string{"-from", "json", "-source", source, "-to", "sqlite", "-target", target}
+	if err := runMigrate(args); err != nil {
+		t.Fatal(err)
+	}
+	if want := "migrated 1 customers, 2 accounts and 3 transactions"; !strings.Contains(out.String(), want) {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+	dst, err := store.Open("sqlite", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := models.NewBank()
+	err = store.Load(dst, loaded)
+	dst.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for number, want := range map[string]float64{"C1": 60, "S1": 40} {
+		if got, _ := loaded.Balance(number); got != want {
+			t.Errorf("%s balance in the target = %.2f, want %.2f", number, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want error
+	}{
+		{args, store.ErrNotEmpty},
+		{[]string{"-source", filepath.Join(dir, "missing.json"), "-target", filepath.Join(dir, "other.db")}, fs.ErrNotExist},
+	} {
+		if err := runMigrate(tt.args); !errors.Is(err, tt.want) {
+			t.Errorf("migrate %q: %v, want %v", tt.args, err, tt.want)
+		}
+	}
+	if err := runMigrate([]string{"-to", "json", "-source", source, "-target", source}); err == nil {
+		t.Error("migrating a store onto itself succeeded")
+	}
+}
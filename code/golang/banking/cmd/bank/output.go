@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// outputFormat is the -output of a command. Empty is the command's own
+// default, table for most.
+type outputFormat string
+
+const (
+	outputTable outputFormat = "table"
+	outputJSON  outputFormat = "json"
+	outputCSV   outputFormat = "csv"
+)
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(s string) error {
+	switch outputFormat(s) {
+	case outputTable, outputJSON, outputCSV:
+		*f = outputFormat(s)
+		return nil
+	}
+	return fmt.Errorf("%q is not table, json or csv", s)
+}
+
+var (
+	// format and quiet are the -output and -quiet of the command running.
+	format outputFormat
+	quiet  bool
+	// stdout is where results go, a variable for tests.
+	stdout io.Writer = os.Stdout
+)
+
+// machine reports whether results are for programs rather than people.
+func machine() bool {
+	return format == outputJSON || format == outputCSV
+}
+
+// note prints a message for people, such as what a command did. -quiet
+// leaves messages out, and with JSON or CSV results they go to standard
+// error so that standard output can be parsed.
+func note(msg string, args ...any) {
+	if quiet {
+		return
+	}
+	w := stdout
+	if machine() {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, msg+"\n", args...)
+}
+
+// table is the result of a command as rows of values under named columns.
+// Tables print aligned, as a CSV file with a header, or as a JSON array of
+// objects keyed by column.
+type table struct {
+	columns []string
+	rows    [][]any
+	// right aligns the columns of a printed table right, for numbers.
+	right bool
+}
+
+func newTable(columns ...string) *table {
+	return &table{columns: columns}
+}
+
+func (t *table) add(values ...any) {
+	t.rows = append(t.rows, values)
+}
+
+// print writes the table in the -output format, or in def when none was
+// asked for.
+func (t *table) print(def outputFormat) error {
+	f := format
+	if f == "" {
+		f = def
+	}
+	switch f {
+	case outputJSON:
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i, row := range t.rows {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n  {")
+			for j, v := range row {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+				key, _ := json.Marshal(t.columns[j])
+				value, err := json.Marshal(jsonValue(v))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(&buf, "%s: %s", key, value)
+			}
+			buf.WriteString("}")
+		}
+		if len(t.rows) > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("]\n")
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	case outputCSV:
+		w := csv.NewWriter(stdout)
+		w.Write(t.columns)
+		for _, row := range t.rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				record[i] = text(v)
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		var flags uint
+		if t.right {
+			flags = tabwriter.AlignRight
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', flags)
+		fmt.Fprintln(tw, strings.Join(t.columns, "\t")+"\t")
+		for _, row := range t.rows {
+			for _, v := range row {
+				fmt.Fprint(tw, text(v), "\t")
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	}
+}
+
+// text is how a value is written in a table or CSV file: amounts to the
+// cent and times as dates.
+func text(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.DateOnly)
+	case []string:
+		return strings.Join(v, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jsonValue is how a value is written in JSON: zero times as null.
+func jsonValue(v any) any {
+	if t, ok := v.(time.Time); ok && t.IsZero() {
+		return nil
+	}
+	return v
+}
+
+// report is a result that writes itself as text, such as a simulation.
+type report interface {
+	WriteText(w io.Writer) error
+}
+
+// printReport writes a report as text, or as JSON with -output json.
+func printReport(r report) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case outputCSV:
+		return usageError("-output csv: this command writes a report, not a table; use json or table")
+	}
+	return r.WriteText(stdout)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// TestExitCode checks each class of error exits with its own code.
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{errors.New("boom"), 1},
+		{usageError("usage: bank books"), 2},
+		{fmt.Errorf("line 3: %w", models.ErrInvalidAmount), 3},
+		{models.ErrAccountNotFound, 4},
+		{fmt.Errorf("transfer: %w", models.ErrInsufficientFunds), 6},
+	} {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("exit code of %q is %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+// TestTablePrint prints one table in each format.
+func TestTablePrint(t *testing.T) {
+	defer func(w io.Writer) { format, stdout = "", w }(stdout)
+	tb := newTable("number", "balance", "since")
+	tb.add("C1", 12.5, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	tb.add("C,2", -3.0, time.Time{})
+	for f, want := range map[outputFormat]string{
+		outputTable: "number  balance  since       \nC1      12.50    2026-03-01  \nC,2     -3.00                \n",
+		outputCSV:   "number,balance,since\nC1,12.50,2026-03-01\n\"C,2\",-3.00,\n",
+		outputJSON:  "[\n  {\"number\": \"C1\", \"balance\": 12.5, \"since\": \"2026-03-01T00:00:00Z\"},\n  {\"number\": \"C,2\", \"balance\": -3, \"since\": null}\n]\n",
+	} {
+		var out strings.Builder
+		format, stdout = f, &out
+		if err := tb.print(outputTable); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want {
+			t.Errorf("-output %s printed\n%q, want\n%q", f, out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"gsolano/banking/money"
+	"gsolano/banking/projection"
+)
+
+func init() {
+	register(command{
+		name:    "project",
+		summary: "project savings growth under random rates and deposits",
+		run:     runProject,
+	})
+}
+
+func runProject(args []string) error {
+	fs := newFlagSet("project")
+	var a projection.Assumptions
+	a.Rate, a.RateVolatility = money.Percent(4), money.Percent(1)
+	fs.Float64Var(&a.Balance, "balance", 0, "starting balance")
+	fs.IntVar(&a.Years, "years", 10, "years to project")
+	fs.TextVar(&a.Rate, "rate", a.Rate, "long-run annual interest rate")
+	fs.TextVar(&a.RateVolatility, "rate-volatility", a.RateVolatility, "how much the rate moves in a year")
+	fs.Float64Var(&a.MonthlyDeposit, "monthly", 0, "monthly deposit")
+	fs.Float64Var(&a.DepositVolatility, "deposit-volatility", 0.1, "how much deposits vary, as a fraction")
+	fs.Float64Var(&a.SkipProbability, "skip", 0.05, "chance of missing a month's deposit")
+	fs.IntVar(&a.Runs, "runs", projection.DefaultRuns, "number of simulated paths")
+	fs.Uint64Var(&a.Seed, "seed", 1, "random seed; the same seed gives the same projection")
+	fs.Parse(args)
+
+	bands, err := projection.Project(a)
+	if err != nil {
+		return err
+	}
+	t := newTable("year", "paid_in")
+	t.right = true
+	for _, p := range projection.Percentiles {
+		t.columns = append(t.columns, fmt.Sprintf("p%d", p))
+	}
+	for _, b := range bands {
+		row := []any{b.Year, b.Deposited}
+		for _, v := range b.Values {
+			row = append(row, v)
+		}
+		t.add(row...)
+	}
+	return t.print(outputTable)
+}
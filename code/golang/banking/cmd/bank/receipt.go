@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+func init() {
+	register(command{
+		name:    "receipt",
+		summary: "show the receipt of an operation by its reference, or verify a saved one",
+		run:     runReceipt,
+	})
+}
+
+const receiptUsage = "usage: bank receipt [-config file] [-o file] reference | bank receipt [-config file] -verify file"
+
+// runReceipt prints the receipt a reference names, signed with the
+// configured receipt_key, or saves it with -o. With -verify it checks a
+// receipt saved as JSON, - for standard input, instead.
+func runReceipt(args []string) error {
+	fs := newFlagSet("receipt")
+	path := configFlag(fs)
+	verify := fs.String("verify", "", "JSON receipt file to verify, - for standard input")
+	out := fs.String("o", "", "save the receipt as JSON to this file, signed with signing.key")
+	fs.Parse(args)
+	if (*verify == "") == (fs.NArg() == 0) || fs.NArg() > 1 {
+		return usageError(receiptUsage)
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if *verify != "" {
+		var r models.Receipt
+		if err := readReceipt(*verify, &r); err != nil {
+			return err
+		}
+		if err := bank.VerifyReceipt(r); err != nil {
+			return err
+		}
+		note("receipt %s is valid", r.Reference)
+		return nil
+	}
+	r, err := bank.Receipt(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		return export(*path, *out, append(data, '\n'))
+	}
+	return printReport(receiptReport{r})
+}
+
+func readReceipt(name string, r *models.Receipt) error {
+	in := os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if err := json.NewDecoder(in).Decode(r); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// receiptReport prints a receipt as text; as JSON it is the receipt itself,
+// which -verify reads back.
+type receiptReport struct {
+	models.Receipt
+}
+
+func (r receiptReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Reference\t%s\n", r.Reference)
+	fmt.Fprintf(tw, "Kind\t%s\n", r.Kind)
+	fmt.Fprintf(tw, "Time\t%s\n", r.Time.Format(time.RFC3339))
+	if r.From != "" {
+		fmt.Fprintf(tw, "From\t%s\n", r.From)
+	}
+	if r.To != "" {
+		fmt.Fprintf(tw, "To\t%s\n", r.To)
+	}
+	fmt.Fprintf(tw, "Amount\t%s\n", withCurrency(r.Amount, r.Currency))
+	if r.CreditCurrency != "" {
+		fmt.Fprintf(tw, "Credited\t%s\n", withCurrency(r.Credit, r.CreditCurrency))
+	}
+	if r.Fee > 0 {
+		fmt.Fprintf(tw, "Fee\t%s\n", withCurrency(r.Fee, r.Currency))
+	}
+	if r.Description != "" {
+		fmt.Fprintf(tw, "Memo\t%s\n", r.Description)
+	}
+	numbers := make([]string, 0, len(r.Balances))
+	for number := range r.Balances {
+		numbers = append(numbers, number)
+	}
+	sort.Strings(numbers)
+	for _, number := range numbers {
+		fmt.Fprintf(tw, "Balance of %s\t%.2f\n", number, r.Balances[number])
+	}
+	if r.Signature != "" {
+		fmt.Fprintf(tw, "Signature\t%s\n", r.Signature)
+	}
+	return tw.Flush()
+}
+
+func withCurrency(amount float64, currency string) string {
+	return strings.TrimSpace(fmt.Sprintf("%.2f %s", amount, currency))
+}
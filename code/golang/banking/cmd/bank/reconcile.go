@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gsolano/banking/config"
+	"gsolano/banking/models"
+	"gsolano/banking/reconcile"
+	"gsolano/banking/store"
+)
+
+func init() {
+	register(command{
+		name:    "reconcile",
+		summary: "match an account's ledger against an external CSV statement",
+		run:     runReconcile,
+	})
+}
+
+func runReconcile(args []string) error {
+	fs := newFlagSet("reconcile")
+	path := configFlag(fs)
+	account := fs.String("account", "", "number of the account to reconcile")
+	amount := fs.Float64("amount-tolerance", reconcile.DefaultTolerance.Amount, "largest difference in amount matched automatically")
+	days := fs.Int("day-tolerance", reconcile.DefaultTolerance.Days, "most days apart matched automatically")
+	fs.Parse(args)
+	if *account == "" || fs.NArg() != 1 {
+		return usageError("usage: bank reconcile -account number [-config file] statement.csv")
+	}
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		return err
+	}
+	if cfg.Store.Driver == "memory" {
+		return errors.New("store.driver: the memory store has no ledgers to reconcile")
+	}
+	st, err := openStore(cfg.Store)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	bank := models.NewBank()
+	if err := store.Load(st, bank); err != nil {
+		return err
+	}
+	ledger, err := bank.History(*account)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	statement, err := reconcile.ReadCSV(f)
+	if err != nil {
+		return err
+	}
+
+	report := reconcile.Reconcile(ledger, statement, reconcile.Tolerance{Amount: *amount, Days: *days})
+	if err := printReport(report); err != nil {
+		return err
+	}
+	if !report.Reconciled() {
+		return fmt.Errorf("%d breaks", len(report.Breaks))
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"gsolano/banking/models"
+)
+
+func init() {
+	register(command{
+		name:    "repl",
+		summary: "an interactive shell for the other commands, with history and completion",
+		run:     runRepl,
+	})
+}
+
+const replHelp = `Type any bank command without "bank", such as "accounts list".
+  transfer     move money, asked for step by step
+  history      list earlier commands; !n runs the nth again
+  help         show this help
+  exit         leave the shell, as Ctrl-D does
+Tab completes commands, account numbers and nicknames.`
+
+// lineReader reads the lines of a session, showing prompt first.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// repl is a session of bank repl. Commands run as they do from the shell,
+// each loading and saving the store, so the session keeps no bank of its
+// own: bank is only what completion offers, loaded again after each
+// command.
+type repl struct {
+	path    string
+	in      lineReader
+	out     io.Writer
+	history []string
+	file    string
+	bank    *models.Bank
+}
+
+func runRepl(args []string) error {
+	fs := newFlagSet("repl")
+	path := configFlag(fs)
+	history := fs.String("history", defaultHistory(), "file to keep command history in, empty for none")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usageError("usage: bank repl [-config file] [-history file]")
+	}
+	// Commands read -config from BANK_CONFIG when not given one.
+	if *path != "" {
+		os.Setenv("BANK_CONFIG", *path)
+	}
+	r := &repl{path: *path, out: os.Stdout, file: *history}
+	r.loadHistory()
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "")
+		t.AutoCompleteCallback = r.complete
+		r.in = &terminalReader{fd: fd, t: t}
+	} else {
+		r.in = &scanReader{s: bufio.NewScanner(os.Stdin), out: os.Stdout}
+	}
+	return r.loop()
+}
+
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bank_history")
+}
+
+// loop runs lines until exit or the end of the input.
+func (r *repl) loop() error {
+	interactive = true
+	defer func() { interactive = false }()
+	for {
+		line, err := r.in.ReadLine("bank> ")
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(r.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "!") {
+			n, err := strconv.Atoi(line[1:])
+			if err != nil || n < 1 || n > len(r.history) {
+				fmt.Fprintf(r.out, "no command %s in history\n", line)
+				continue
+			}
+			line = r.history[n-1]
+			fmt.Fprintln(r.out, line)
+		}
+		if line == "" {
+			continue
+		}
+		r.record(line)
+		args, err := splitLine(line)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			fmt.Fprintln(r.out, replHelp)
+			continue
+		case "history":
+			for i, h := range r.history {
+				fmt.Fprintf(r.out, "%5d  %s\n", i+1, h)
+			}
+			continue
+		case "transfer":
+			if len(args) == 1 {
+				if args, err = r.transferWizard(); err != nil || args == nil {
+					if err != nil {
+						fmt.Fprintln(r.out, err)
+					}
+					continue
+				}
+			}
+		}
+		if err := r.run(args); err != nil {
+			fmt.Fprintf(os.Stderr, "bank %s: %v\n", args[0], err)
+		}
+	}
+}
+
+// run runs a command of the CLI. A flag the command rejects fails it
+// rather than ending the session.
+func (r *repl) run(args []string) (err error) {
+	c, ok := commands[args[0]]
+	if !ok || c.name == "repl" {
+		return errors.New("unknown command; type help")
+	}
+	r.bank = nil
+	// -output and -quiet last for one command.
+	defer func(f outputFormat, q bool) { format, quiet = f, q }(format, quiet)
+	defer func() {
+		if p := recover(); p != nil {
+			e, ok := p.(error)
+			if !ok {
+				panic(p)
+			}
+			if !errors.Is(e, flag.ErrHelp) {
+				err = e
+			}
+		}
+	}()
+	return c.run(args[1:])
+}
+
+// transferWizard asks for the accounts and amount of a transfer and for it
+// to be confirmed, and returns the transfer command that makes it, or nil
+// when cancelled. Accounts and amounts are asked for again until valid.
+func (r *repl) transferWizard() ([]string, error) {
+	bank := r.completionBank()
+	if bank == nil {
+		return nil, errors.New("the store cannot be loaded; use transfer from to amount")
+	}
+	account := func(prompt string) (string, error) {
+		for {
+			ref, err := r.in.ReadLine(prompt)
+			// Completion quotes nicknames with spaces.
+			ref = strings.Trim(strings.TrimSpace(ref), `"`)
+			if err != nil || ref == "" {
+				return "", err
+			}
+			number, err := bank.Resolve(ref)
+			if err == nil {
+				_, err = bank.Account(number)
+			}
+			if err == nil {
+				return number, nil
+			}
+			fmt.Fprintf(r.out, "%s: %v\n", ref, err)
+		}
+	}
+	from, err := account("From account: ")
+	if err != nil || from == "" {
+		return nil, err
+	}
+	to, err := account("To account: ")
+	if err != nil || to == "" {
+		return nil, err
+	}
+	var amount string
+	for {
+		if amount, err = r.in.ReadLine("Amount: "); err != nil {
+			return nil, err
+		}
+		amount = strings.TrimSpace(amount)
+		if n, err := strconv.ParseFloat(amount, 64); err == nil && n > 0 {
+			break
+		}
+		fmt.Fprintf(r.out, "%q is not a positive number\n", amount)
+	}
+	memo, err := r.in.ReadLine("Memo (optional): ")
+	if err != nil {
+		return nil, err
+	}
+	answer, err := r.in.ReadLine(fmt.Sprintf("Transfer %s from %s to %s? [y/N] ", amount, from, to))
+	if err != nil {
+		return nil, err
+	}
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		fmt.Fprintln(r.out, "cancelled")
+		return nil, nil
+	}
+	args := []string{"transfer"}
+	if memo = strings.TrimSpace(memo); memo != "" {
+		args = append(args, "-memo", memo)
+	}
+	return append(args, from, to, amount), nil
+}
+
+// completionBank returns the bank completion offers the accounts of, nil
+// when the store cannot be loaded.
+func (r *repl) completionBank() *models.Bank {
+	if r.bank == nil {
+		bank, st, err := loadStore(r.path)
+		if err != nil {
+			return nil
+		}
+		st.Close()
+		r.bank = bank
+	}
+	return r.bank
+}
+
+// candidates are the words completion offers: commands first, then the
+// numbers and nicknames of accounts.
+func (r *repl) candidates(first bool) []string {
+	var words []string
+	if first {
+		for name := range commands {
+			if name != "repl" {
+				words = append(words, name)
+			}
+		}
+		words = append(words, "exit", "help", "history")
+		sort.Strings(words)
+		return words
+	}
+	bank := r.completionBank()
+	if bank == nil {
+		return nil
+	}
+	for _, account := range bank.ListAccounts(false) {
+		words = append(words, account.Number())
+		if alias, _ := bank.Alias(account.Number()); alias.Nickname != "" {
+			words = append(words, quote(alias.Nickname))
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// complete is the terminal's completion on Tab: the word before the cursor
+// is completed to the one candidate it starts, or as far as all the
+// candidates it starts agree.
+func (r *repl) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := wordStart(line[:pos])
+	word := line[start:pos]
+	var matches []string
+	for _, c := range r.candidates(strings.TrimSpace(line[:start]) == "") {
+		if strings.HasPrefix(c, word) || strings.HasPrefix(c, `"`+word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	completion := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if len(matches) == 1 {
+		completion += " "
+	}
+	if len(completion) <= len(word) {
+		return "", 0, false
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// wordStart returns where the last word of a line starts, a quoted one
+// counted as one word.
+func wordStart(line string) int {
+	start, quoted := 0, false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			start = i + 1
+		}
+	}
+	return start
+}
+
+// splitLine splits a line into words at spaces; double quotes keep a
+// nickname with spaces one word.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted, inWord := false, false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted, inWord = !quoted, true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func quote(word string) string {
+	if strings.ContainsAny(word, " \t") {
+		return `"` + word + `"`
+	}
+	return word
+}
+
+// loadHistory reads the history of earlier sessions.
+func (r *repl) loadHistory() {
+	if r.file == "" {
+		return
+	}
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+}
+
+// record adds a line to the history and to the history file, unless it
+// repeats the line before.
+func (r *repl) record(line string) {
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if r.file == "" {
+		return
+	}
+	f, err := os.OpenFile(r.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// terminalReader reads lines from a terminal, in raw mode only while it
+// reads so that commands print as they do from the shell. The terminal
+// keeps the lines of the session for the arrow keys.
+type terminalReader struct {
+	fd int
+	t  *term.Terminal
+}
+
+func (tr *terminalReader) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(tr.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(tr.fd, state)
+	tr.t.SetPrompt(prompt)
+	return tr.t.ReadLine()
+}
+
+// scanReader reads lines from a file or pipe, for scripts.
+type scanReader struct {
+	s   *bufio.Scanner
+	out io.Writer
+}
+
+func (sr *scanReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(sr.out, prompt)
+	if !sr.s.Scan() {
+		if err := sr.s.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return sr.s.Text(), nil
+}
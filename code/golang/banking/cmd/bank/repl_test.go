@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+	"gsolano/banking/store"
+)
+
+// TestReplTransferWizard runs a scripted session against a JSON store: a
+// transfer asked for step by step, with an unknown account and amount on
+// the way, and one the history starts again and that is then cancelled.
+func TestReplTransferWizard(t *testing.T) {
+	models.SetOutput(io.Discard)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bank.yaml")
+	dsn := filepath.Join(dir, "bank.json")
+	if err := os.WriteFile(path, []byte("store:\n  driver: json\n  dsn: "+dsn+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BANK_CONFIG", path)
+	_, st, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank := models.NewBank()
+	for _, number := range []string{"C1", "C2"} {
+		bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: number}})
+	}
+	bank.Deposit("C1", 100)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	script := "transfer\nC1\nC9\nC2\nlots\n40\nrent\ny\n!1\nC1\nC2\n10\n\nn\nexit\n"
+	var out strings.Builder
+	r := &repl{path: path, in: &scanReader{s: bufio.NewScanner(strings.NewReader(script)), out: &out}, out: &out}
+	if err := r.loop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`C9: `, `"lots" is not a positive number`, "Transfer 40 from C1 to C2? [y/N]", "cancelled"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("session output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if len(r.history) != 2 || r.history[0] != "transfer" || r.history[1] != "exit" {
+		t.Errorf("history %q, want transfer and exit", r.history)
+	}
+
+	bank, st, err = loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	from, _ := bank.Account("C1")
+	to, _ := bank.Account("C2")
+	if from.CheckBalance() != 60 || to.CheckBalance() != 40 {
+		t.Errorf("balances %.2f and %.2f, want 60 and 40", from.CheckBalance(), to.CheckBalance())
+	}
+	if got := to.History()[0].Description; got != "rent" {
+		t.Errorf("memo %q, want rent", got)
+	}
+}
+
+// TestReplComplete completes commands, account numbers and a quoted
+// nickname.
+func TestReplComplete(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	for _, number := range []string{"C100", "C200", "S1"} {
+		bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: number}})
+	}
+	bank.SetAlias("S1", models.Alias{Nickname: "Rainy day"})
+	r := &repl{bank: bank}
+	for _, tc := range []struct{ line, want string }{
+		{"trans", "transfer "},
+		{"transfer C", "transfer C"},
+		{"transfer C1", "transfer C100 "},
+		{"transfer C100 Ra", `transfer C100 "Rainy day" `},
+		{`transfer "Rai`, `transfer "Rainy day" `},
+	} {
+		got, _, ok := r.complete(tc.line, len(tc.line), '\t')
+		if !ok {
+			got = tc.line
+		}
+		if got != tc.want {
+			t.Errorf("completing %q gave %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"gsolano/banking/config"
+	"gsolano/banking/models"
+	"gsolano/banking/seed"
+	"gsolano/banking/store"
+)
+
+func init() {
+	register(command{
+		name:    "seed",
+		summary: "fill a store or snapshot file with fake customers and months of transactions",
+		run:     runSeed,
+	})
+}
+
+func runSeed(args []string) error {
+	fs := newFlagSet("seed")
+	path := configFlag(fs)
+	var o seed.Options
+	fs.IntVar(&o.Customers, "customers", 20, "number of customers to create")
+	fs.IntVar(&o.Months, "months", 6, "months of transactions, up to today")
+	fs.Uint64Var(&o.Seed, "seed", 1, "random seed; the same seed gives the same data")
+	out := fs.String("o", "", "write a snapshot to this file, for tests, instead of the configured store")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usageError("usage: bank seed [-config file] [-customers n] [-months n] [-seed n] [-o snapshot.json]")
+	}
+	models.SetOutput(io.Discard)
+
+	bank := models.NewBank()
+	var st store.Store
+	if *out == "" {
+		cfg, err := config.Load(*path)
+		if err != nil {
+			return err
+		}
+		if cfg.Store.Driver == "memory" {
+			return errors.New("store.driver: data seeded into the memory store would be lost; use -o")
+		}
+		if st, err = openStore(cfg.Store); err != nil {
+			return err
+		}
+		defer st.Close()
+		if err := store.Load(st, bank); err != nil {
+			return err
+		}
+	}
+
+	report, err := seed.Generate(bank, o)
+	if err != nil {
+		return err
+	}
+	if st != nil {
+		err = store.Save(st, bank)
+	} else {
+		err = writeSnapshot(bank, *path, *out)
+	}
+	if err != nil {
+		return err
+	}
+	note("created %d customers, %d accounts and %d transactions", report.Customers, report.Accounts, report.Transactions)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"gsolano/banking/store"
+)
+
+func init() {
+	register(command{
+		name:    "settle",
+		summary: "post the transfers booked with transfer -settle-days that are due",
+		run:     runSettle,
+	})
+}
+
+// runSettle posts the pending transfers of the store due by a date and saves
+// it. Transfers that fail to post stay pending and are reported.
+func runSettle(args []string) error {
+	fs := newFlagSet("settle")
+	path := configFlag(fs)
+	at := fs.String("at", "", "date the transfers are settled at, 2006-01-02; today by default")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usageError("usage: bank settle [-config file] [-at date]")
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	when := time.Now()
+	if *at != "" {
+		if when, err = time.ParseInLocation(time.DateOnly, *at, time.Local); err != nil {
+			return errors.New("-at: " + err.Error())
+		}
+	}
+	settled, settleErr := bank.Settle(when)
+	if err := store.Save(st, bank); err != nil {
+		return err
+	}
+	for _, pt := range settled {
+		note("settled pending transfer %d: %.2f from %s to %s", pt.ID, pt.Amount, pt.From, pt.To)
+	}
+	return settleErr
+}
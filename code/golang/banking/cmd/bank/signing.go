@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"gsolano/banking/config"
+	"gsolano/banking/models"
+	"gsolano/banking/signing"
+)
+
+func init() {
+	register(command{
+		name:    "keygen",
+		summary: "make the Ed25519 key pair exports are signed with",
+		run:     runKeygen,
+	})
+	register(command{
+		name:    "verify",
+		summary: "check that an exported statement, receipt or snapshot was signed by the bank and not altered",
+		run:     runVerify,
+	})
+	register(command{
+		name:    "snapshot",
+		summary: "export the store as a snapshot file, signed with signing.key",
+		run:     runSnapshot,
+	})
+}
+
+// runKeygen writes a new key pair to name.key, readable by its owner only,
+// and name.pub, to hand out to whoever verifies.
+func runKeygen(args []string) error {
+	fs := newFlagSet("keygen")
+	name := fs.String("o", "bank", "write the keys to this name with .key and .pub added")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return usageError("usage: bank keygen [-o name]")
+	}
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := signing.MarshalPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	pubPEM, err := signing.MarshalPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(*name + ".key"); err == nil {
+		return fmt.Errorf("%s.key exists; remove it first to replace the key", *name)
+	}
+	if err := os.WriteFile(*name+".key", keyPEM, 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*name+".pub", pubPEM, 0o644); err != nil {
+		return err
+	}
+	note("wrote %s.key and %s.pub; set signing.key to %s.key", *name, *name, *name)
+	return nil
+}
+
+// runVerify checks a file against its signature, file.sig by default or a
+// Signature-Ed25519 header saved from the API, with the public key of
+// -key or of the configured signing settings.
+func runVerify(args []string) error {
+	fs := newFlagSet("verify")
+	path := configFlag(fs)
+	keyFile := fs.String("key", "", "PEM file of the public key; signing.public_key or signing.key by default")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("usage: bank verify [-config file] [-key file] file [signature-file]")
+	}
+	var key ed25519.PublicKey
+	var err error
+	if *keyFile != "" {
+		key, err = signing.LoadPublicKey(*keyFile)
+	} else {
+		var cfg config.Config
+		if cfg, err = config.Load(*path); err == nil {
+			key, err = cfg.VerifyingKey()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return usageError("verify: no public key; pass -key or set signing.public_key")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	sigFile := fs.Arg(0) + signing.Extension
+	if fs.NArg() == 2 {
+		sigFile = fs.Arg(1)
+	}
+	sig, err := os.ReadFile(sigFile)
+	if err != nil {
+		return err
+	}
+	if err := signing.Verify(key, data, string(sig)); err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	note("%s: signature is valid", fs.Arg(0))
+	return nil
+}
+
+// runSnapshot writes the bank kept in the store to a snapshot file, which
+// the json store reads and Restore loads.
+func runSnapshot(args []string) error {
+	fs := newFlagSet("snapshot")
+	path := configFlag(fs)
+	out := fs.String("o", "", "file to write the snapshot to")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *out == "" {
+		return usageError("usage: bank snapshot [-config file] -o file")
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if err := writeSnapshot(bank, *path, *out); err != nil {
+		return err
+	}
+	note("wrote %d accounts to %s", len(bank.Accounts()), *out)
+	return nil
+}
+
+// writeSnapshot writes a snapshot of bank to out, signed with the
+// signing.key of the config at path when one is set.
+func writeSnapshot(bank *models.Bank, path, out string) error {
+	var buf bytes.Buffer
+	if err := bank.Snapshot(&buf); err != nil {
+		return err
+	}
+	return export(path, out, buf.Bytes())
+}
+
+// export writes an exported file and, when the config at path sets
+// signing.key, its signature next to it.
+func export(path, out string, data []byte) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	key, err := cfg.SigningKey()
+	if err != nil {
+		return errors.New("signing.key: " + err.Error())
+	}
+	if err := signing.WriteFile(out, data, key); err != nil {
+		return err
+	}
+	if key != nil {
+		note("signed %s in %s%s", out, out, signing.Extension)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"gsolano/banking/models"
+	"gsolano/banking/sim"
+)
+
+func init() {
+	register(command{
+		name:    "simulate",
+		summary: "run a YAML scenario on a simulated clock and print the report",
+		run:     runSimulate,
+	})
+}
+
+func runSimulate(args []string) error {
+	fs := newFlagSet("simulate")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return usageError("usage: bank simulate scenario.yaml")
+	}
+	// The report lists every event; the account messages would only repeat
+	// them.
+	models.SetOutput(io.Discard)
+	scenario, err := sim.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	report, err := sim.Run(scenario)
+	if err != nil {
+		return err
+	}
+	if err := printReport(report); err != nil {
+		return err
+	}
+	if !report.Passed() {
+		return fmt.Errorf("%d failures", len(report.Failures))
+	}
+	return nil
+}
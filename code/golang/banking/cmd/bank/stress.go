@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"gsolano/banking/models"
+	"gsolano/banking/sim"
+)
+
+func init() {
+	register(command{
+		name:    "stress",
+		summary: "play a YAML stress test on a copy of the bank and print the breaches",
+		run:     runStress,
+	})
+}
+
+// runStress plays a stress test on a copy of the store, which it leaves as
+// it was, and fails when an account or the bank breached a limit.
+func runStress(args []string) error {
+	fs := newFlagSet("stress")
+	path := configFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return usageError("usage: bank stress [-config file] test.yaml")
+	}
+	models.SetOutput(io.Discard)
+	test, err := sim.LoadStressTest(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	report, err := sim.Stress(bank, test)
+	if err != nil {
+		return err
+	}
+	if err := printReport(report); err != nil {
+		return err
+	}
+	if len(report.Breaches) > 0 {
+		return fmt.Errorf("%d breaches", len(report.Breaches))
+	}
+	return nil
+}
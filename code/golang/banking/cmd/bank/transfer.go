@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/store"
+)
+
+func init() {
+	register(command{
+		name:    "transfer",
+		summary: "move money between two accounts, named by number or nickname",
+		run:     runTransfer,
+	})
+}
+
+const transferUsage = "usage: bank transfer [-config file] [-memo text] [-settle-days n] from to amount"
+
+// runTransfer transfers between two accounts of the store and saves it. With
+// -settle-days it books the transfer instead, for bank settle to post.
+func runTransfer(args []string) error {
+	fs := newFlagSet("transfer")
+	path := configFlag(fs)
+	memo := fs.String("memo", "", "description of both entries")
+	days := fs.Int("settle-days", 0, "business days until the transfer settles; it posts at once by default")
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		return usageError(transferUsage)
+	}
+	amount, err := strconv.ParseFloat(fs.Arg(2), 64)
+	if err != nil || amount <= 0 {
+		return fmt.Errorf("amount: %q is not a positive number", fs.Arg(2))
+	}
+	bank, st, err := loadStore(*path)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	from, err := bank.Resolve(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	to, err := bank.Resolve(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	var opts []models.TxOption
+	if *memo != "" {
+		opts = append(opts, models.WithDescription(*memo))
+	}
+	if *days > 0 {
+		pt, err := bank.BookTransfer(from, to, amount, *days, opts...)
+		if err != nil {
+			return err
+		}
+		if err := store.Save(st, bank); err != nil {
+			return err
+		}
+		note("booked %.2f from %s to %s as pending transfer %d, settling on %s", amount, from, to, pt.ID, pt.SettlesOn.Format(time.DateOnly))
+		return nil
+	}
+	if err := bank.Transfer(from, to, amount, opts...); err != nil {
+		return err
+	}
+	if err := store.Save(st, bank); err != nil {
+		return err
+	}
+	ref := ""
+	if account, err := bank.Account(from); err == nil {
+		history := account.History()
+		ref = history[len(history)-1].Metadata[models.MetaTransferID]
+	}
+	note("transferred %.2f from %s to %s, receipt %s", amount, from, to, ref)
+	return nil
+}
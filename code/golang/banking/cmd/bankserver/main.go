@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gsolano/banking/chaos"
+	"gsolano/banking/config"
+	"gsolano/banking/grpcapi"
+	"gsolano/banking/i18n"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/policy"
+	"gsolano/banking/server"
+	"gsolano/banking/shared"
+	"gsolano/banking/shared/redis"
+	"gsolano/banking/store"
+	_ "gsolano/banking/store/postgres"
+)
+
+// saveInterval is how often the bank is saved to its store, besides on
+// shutdown, which for a Postgres store only catches up on the entries that
+// were not written as they were posted, archiveInterval how often closed accounts are checked
+// for archival, ended overrides recorded and stale applications abandoned,
+// and reviewInterval how often cards are billed and loans and cards checked
+// for missed payments. relayInterval is how often events in the store's
+// outbox are posted. writerTTL is how long the writer lock outlives a
+// replica that stopped renewing it.
+const (
+	saveInterval    = time.Minute
+	archiveInterval = time.Hour
+	reviewInterval  = 24 * time.Hour
+	relayInterval   = 10 * time.Second
+	writerTTL       = 30 * time.Second
+)
+
+func main() {
+	configPath := flag.String("config", os.Getenv("BANK_CONFIG"), "path to a YAML or TOML config file (default $BANK_CONFIG)")
+	addr := flag.String("addr", "", "address to listen on (overrides server.addr)")
+	grpcAddr := flag.String("grpc-addr", "", "address to serve gRPC on (overrides server.grpc_addr)")
+	token := flag.String("token", "", "token API clients must present (overrides server.token)")
+	logOps := flag.Bool("log-operations", false, "log every deposit, withdrawal and transfer")
+	demo := flag.Bool("demo", false, "serve the web dashboard and open demo accounts in an empty bank")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if *grpcAddr != "" {
+		cfg.Server.GRPCAddr = *grpcAddr
+	}
+	if *token != "" {
+		cfg.Server.Token = *token
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	locale, _ := i18n.Parse(cfg.Locale)
+	models.SetLocale(locale)
+
+	bank := models.NewBank()
+	bank.Tenant = cfg.Tenant
+	bank.Rounding, _ = money.ParseRounding(cfg.Rounding)
+	bank.IDs = cfg.IDGenerator()
+	bank.Location, _ = cfg.Location()
+	bank.ReceiptKey = []byte(cfg.ReceiptKey)
+	bank.SecretKey = []byte(cfg.SecretKey)
+	bank.Currency = cfg.FX.Currency
+	bank.FX = cfg.ExchangeRates()
+	bank.FXHistory = cfg.HistoricalRates()
+	bank.FXPricing = models.FXPricing{Margin: cfg.FX.Margin, Fee: cfg.FX.Fee}
+	bank.QuoteTTL = cfg.FX.QuoteTTL
+	if cfg.Archive.DeleteGrace > 0 {
+		bank.DeleteGrace = cfg.Archive.DeleteGrace
+	}
+	if cfg.Opening.Timeout > 0 {
+		bank.ApplicationTimeout = cfg.Opening.Timeout
+	}
+	if cfg.Inbox.Retention > 0 {
+		bank.MessageRetention = cfg.Inbox.Retention
+	}
+	if cfg.Sessions.TTL > 0 {
+		bank.SessionTTL = cfg.Sessions.TTL
+	}
+	if cfg.Sessions.StepUpWindow > 0 {
+		bank.StepUpWindow = cfg.Sessions.StepUpWindow
+	}
+	bank.StepUpAmount = cfg.Sessions.StepUpAmount
+	bank.Duplicates = cfg.Duplicates.Check()
+	bank.PayeeCheck = cfg.PayeeCheck.Mode
+	bank.DormancyMonths = cfg.Dormancy.Months
+	bank.EscheatMonths = cfg.Dormancy.EscheatMonths
+	bank.Reserves = cfg.Reserves.Ratios()
+	cfg.ApplyFeatures(bank.Flags)
+	cfg.ApplyAllocation(bank.Allocation)
+	cfg.ApplyProducts(bank.Products)
+	if *logOps {
+		bank.Use(models.LogOperations(log.Default()))
+	}
+	bank.Use(bank.AuthorizeHolders(), bank.ConfirmPayees(), bank.DetectDuplicates())
+	rules, _ := cfg.CompilePolicy()
+	enforcer := policy.NewEnforcer(bank, rules)
+	bank.Use(enforcer.Middleware())
+	go reloadPolicy(*configPath, enforcer)
+	var faults *chaos.Faults
+	if cfg.Chaos.Enabled {
+		log.Print("fault injection is enabled; this server is not fit for production")
+		faults = &chaos.Faults{}
+		bank.Clock = faults.Clock(bank.Clock)
+	}
+	// Validate has read the key.
+	signingKey, _ := cfg.SigningKey()
+	state := openShared(cfg.Shared)
+	lead(state)
+	if cfg.Store.Driver != "memory" {
+		persist(bank, cfg.Store, state, faults)
+	}
+	if cfg.Archive.Dir != "" {
+		archive, err := store.NewDirArchive(cfg.Archive.Dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bank.Archive = archive
+	}
+	if cfg.Archive.Retention > 0 {
+		go archiveClosed(bank, cfg.Archive.Retention, state)
+	}
+	go reviewDelinquency(bank, state)
+	go expireOverrides(bank, state)
+	go abandonApplications(bank, state)
+	go reviewDormancy(bank, state)
+	go publishLedgerRoots(bank, state)
+	go applyRateChanges(bank, state)
+	go notifyInboxes(bank, state)
+	go expireSessions(bank, state)
+	go payInstallments(bank, state)
+	go settleTransfers(bank, state)
+	if cfg.Inbox.WebhookURL != "" {
+		bank.Channels = append(bank.Channels, webhookChannel{url: cfg.Inbox.WebhookURL, client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	alerts := models.NewAlerts(bank, bank.NotifyAlert)
+	alerts.SetQuietHours(cfg.Alerts.QuietHours())
+	go flushAlerts(alerts)
+	if *demo && len(bank.Accounts()) == 0 {
+		bank.AddCustomer(&models.Customer{ID: "c1", Name: "Demo Customer"})
+		bank.OpenAccount("savings", "c1", "12345")
+		bank.Deposit("12345", 1000)
+		bank.OpenAccount("checking", "c1", "67890")
+		bank.Deposit("67890", 500)
+	}
+
+	if cfg.Server.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", cfg.Server.GRPCAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("bankserver serving gRPC on %s", cfg.Server.GRPCAddr)
+		srv := grpcapi.NewServer(bank, grpcapi.Authenticate(bank, cfg.Server.Token, state)...)
+		go func() { log.Fatal(srv.Serve(lis)) }()
+	}
+
+	log.Printf("bankserver listening on %s", cfg.Server.Addr)
+	opts := []server.Option{server.WithToken(cfg.Server.Token), server.WithLocale(locale),
+		server.WithShared(state), server.WithRateLimit(shared.Rate{Limit: cfg.Server.RateLimit, Burst: cfg.Server.RateBurst}), server.WithChaos(faults),
+		server.WithSigningKey(signingKey), server.WithAlerts(alerts)}
+	if *demo {
+		opts = append(opts, server.WithDashboard())
+	}
+	log.Fatal(http.ListenAndServe(cfg.Server.Addr, server.New(bank, opts...)))
+}
+
+// persist loads bank from the configured store and saves it back every
+// saveInterval and when the process is interrupted or terminated. A store
+// that writes through, such as Postgres, also writes each deposit,
+// withdrawal and transfer as it is made. With faults, its writes and the
+// outbox relay's posts fail when they say.
+func persist(bank *models.Bank, cfg config.Store, locks shared.Locker, faults *chaos.Faults) {
+	opts := []store.Option{store.WithBatchSize(cfg.BatchSize),
+		store.WithPool(store.Pool{MaxConns: cfg.MaxConns, MinConns: cfg.MinConns, MaxConnIdleTime: cfg.MaxConnIdleTime})}
+	if cfg.OutboxURL != "" {
+		opts = append(opts, store.WithOutbox())
+	}
+	st, err := store.Open(cfg.Driver, cfg.DSN, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if w, ok := st.(store.WriteThrough); ok {
+		bank.Use(w.WriteThrough(bank, func(err error) {
+			log.Printf("writing through to %s store: %v", cfg.Driver, err)
+		}))
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if faults != nil {
+		st = faults.Store(st)
+		client.Transport = faults.Transport(nil)
+	}
+	if o, ok := st.(store.Outbox); ok && cfg.OutboxURL != "" {
+		go relayOutbox(o, cfg.OutboxURL, client, locks)
+	}
+	if err := store.Load(st, bank); err != nil {
+		log.Fatalf("loading %s store %s: %v", cfg.Driver, cfg.DSN, err)
+	}
+	save := func() {
+		if err := store.Save(st, bank); err != nil {
+			log.Printf("saving %s store %s: %v", cfg.Driver, cfg.DSN, err)
+		}
+	}
+
+	go func() {
+		for range time.Tick(saveInterval) {
+			save()
+		}
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		save()
+		if err := st.Close(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}()
+}
+
+// openShared returns the state kept with the other replicas of the server.
+func openShared(cfg config.Shared) shared.Backend {
+	if cfg.Driver != "redis" {
+		return shared.NewMemory()
+	}
+	b, err := redis.Open(context.Background(), cfg.URL)
+	if err != nil {
+		log.Fatalf("connecting to redis %s: %v", cfg.URL, err)
+	}
+	return b
+}
+
+// lead waits until this replica holds the writer lock. Each replica keeps
+// the bank in its own memory and saves it to the store, so only one may
+// serve it: two would each overwrite the other's entries. Replicas sharing
+// the lock wait as standbys, serving nothing, and one takes over once the
+// writer stops renewing it. A writer that loses the lock exits at once, as
+// a standby may have loaded the bank since.
+func lead(locks shared.Locker) {
+	waiting := false
+	for {
+		lost, err := locks.Hold(context.Background(), "writer", writerTTL)
+		if err == nil {
+			go func() {
+				<-lost
+				log.Fatal("lost the writer lock to another replica")
+			}()
+			return
+		}
+		if !errors.Is(err, shared.ErrLocked) {
+			log.Printf("writer lock: %v", err)
+		} else if !waiting {
+			log.Print("another replica is the writer; waiting as a standby")
+			waiting = true
+		}
+		time.Sleep(writerTTL / 3)
+	}
+}
+
+// once runs job unless another replica has in the last nine tenths of
+// interval. The lock is left to expire rather than released, so that the
+// replicas' tickers, which are not in step, run the job once an interval
+// between them. A job whose lock cannot be checked is skipped: billing
+// twice is worse than billing late.
+func once(locks shared.Locker, name string, interval time.Duration, job func()) {
+	if _, err := locks.TryLock(context.Background(), name, interval*9/10); err != nil {
+		if !errors.Is(err, shared.ErrLocked) {
+			log.Printf("%s: %v", name, err)
+		}
+		return
+	}
+	job()
+}
+
+// relayOutbox posts the events of the outbox to url, as JSON with their ID
+// as Idempotency-Key, from one replica at a time. Delivery is at least
+// once: an event is posted again if it could not be marked published, so
+// the receiver should drop IDs it has seen.
+func relayOutbox(o store.Outbox, url string, client *http.Client, locks shared.Locker) {
+	post := func(e store.OutboxEvent) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", strconv.FormatInt(e.ID, 10))
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("event %d: %s answered %s", e.ID, url, resp.Status)
+		}
+		return nil
+	}
+	for range time.Tick(relayInterval) {
+		once(locks, "relay-outbox", relayInterval, func() {
+			if _, err := store.Relay(o, post); err != nil {
+				log.Printf("relaying outbox: %v", err)
+			}
+		})
+	}
+}
+
+// reloadPolicy replaces the policy of enforcer with the one in the config
+// file at path when the process gets SIGHUP, keeping the old one if the
+// file cannot be loaded or its policy is invalid.
+func reloadPolicy(path string, enforcer *policy.Enforcer) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		cfg, err := config.Load(path)
+		if err == nil {
+			var rules *policy.Policy
+			if rules, err = cfg.CompilePolicy(); err == nil {
+				enforcer.Set(rules)
+				log.Printf("reloaded policy of %d rules", len(cfg.Policy))
+				continue
+			}
+		}
+		log.Printf("reloading policy, keeping the old one: %v", err)
+	}
+}
+
+func reviewDelinquency(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(reviewInterval) {
+		once(locks, "review-delinquency", reviewInterval, func() {
+			if billed := bank.BillCards(); len(billed) > 0 {
+				log.Printf("billed cards %v", billed)
+			}
+			if report := bank.ReviewDelinquency(); len(report) > 0 {
+				log.Printf("%d loans and cards past due", len(report))
+			}
+			if charged := bank.AssessFees(); len(charged) > 0 {
+				log.Printf("charged product fees to %v", charged)
+			}
+		})
+	}
+}
+
+// expireOverrides records the end of traveler overrides once they are past,
+// so that their reverting shows in the event log.
+func expireOverrides(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "expire-overrides", archiveInterval, func() {
+			for _, o := range bank.ExpireOverrides() {
+				log.Printf("override %d of %s ended", o.ID, o.Account)
+			}
+		})
+	}
+}
+
+// abandonApplications abandons the applications to open accounts that have
+// waited in a step for longer than the bank's ApplicationTimeout.
+func abandonApplications(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "abandon-applications", archiveInterval, func() {
+			for _, app := range bank.AbandonStale() {
+				log.Printf("abandoned application %d of %s", app.ID, app.Customer)
+			}
+		})
+	}
+}
+
+// reviewDormancy marks dormant the accounts without customer activity for
+// the bank's DormancyMonths.
+func reviewDormancy(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "review-dormancy", archiveInterval, func() {
+			if marked := bank.ReviewDormancy(); len(marked) > 0 {
+				log.Printf("dormant accounts %v", marked)
+			}
+		})
+	}
+}
+
+// publishLedgerRoots publishes a Merkle root over the ledger every hour, for
+// customers to prove their entries against.
+func publishLedgerRoots(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "publish-ledger-root", archiveInterval, func() {
+			root := bank.PublishLedgerRoot()
+			log.Printf("ledger root %d: %s over %d entries", root.Sequence, root.Root, root.Size)
+		})
+	}
+}
+
+// applyRateChanges sends the notices of scheduled rate changes and applies
+// those that have taken effect.
+func applyRateChanges(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "apply-rate-changes", archiveInterval, func() {
+			noticed, applied := bank.ApplyRateChanges()
+			for _, c := range noticed {
+				log.Printf("rate change %d of %s to %s: notice sent", c.ID, c.Product, c.Rate)
+			}
+			for _, c := range applied {
+				log.Printf("rate change %d of %s to %s: applied to %d accounts", c.ID, c.Product, c.Rate, len(c.Accounts))
+			}
+		})
+	}
+}
+
+// notifyInboxes tells customers of their statements once their periods
+// close and of their rewards once a month, and drops the messages past the bank's MessageRetention.
+func notifyInboxes(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "notify-inboxes", archiveInterval, func() {
+			if sent := bank.NotifyStatements(); len(sent) > 0 {
+				log.Printf("sent %d statement notices", len(sent))
+			}
+			if sent := bank.NotifyRewardsStatements(); len(sent) > 0 {
+				log.Printf("sent %d rewards statements", len(sent))
+			}
+			if expired := bank.ExpireMessages(); expired > 0 {
+				log.Printf("expired %d messages", expired)
+			}
+		})
+	}
+}
+
+// expireSessions forgets the sessions that expired or were revoked.
+func expireSessions(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "expire-sessions", archiveInterval, func() {
+			if expired := bank.ExpireSessions(); expired > 0 {
+				log.Printf("forgot %d expired or revoked sessions", expired)
+			}
+		})
+	}
+}
+
+// payInstallments transfers the installments of installment plans as they
+// fall due, retrying those that failed.
+func payInstallments(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "pay-installments", archiveInterval, func() {
+			for _, p := range bank.PayInstallments() {
+				log.Printf("installment plan %s: %.2f of %.2f paid", p.ID, p.Paid, p.Amount)
+			}
+		})
+	}
+}
+
+// settleTransfers posts the booked transfers that are due. One that fails
+// stays pending and is tried again the next time round.
+func settleTransfers(bank *models.Bank, locks shared.Locker) {
+	for range time.Tick(archiveInterval) {
+		once(locks, "settle-transfers", archiveInterval, func() {
+			settled, err := bank.Settle(time.Now())
+			for _, pt := range settled {
+				log.Printf("pending transfer %d: %.2f settled from %s to %s", pt.ID, pt.Amount, pt.From, pt.To)
+			}
+			if err != nil {
+				log.Printf("settle transfers: %v", err)
+			}
+		})
+	}
+}
+
+// flushAlerts delivers the alerts held back over the quiet hours once they
+// end, even if no transaction comes to flush them. Alert rules live in each
+// server's memory, so every server flushes its own.
+func flushAlerts(alerts *models.Alerts) {
+	for now := range time.Tick(time.Minute) {
+		alerts.Flush(now)
+	}
+}
+
+// webhookChannel delivers inbox messages by posting them to url as JSON,
+// with their ID as Idempotency-Key.
+type webhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func (c webhookChannel) Name() string { return "webhook" }
+
+func (c webhookChannel) Deliver(m models.Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", m.ID)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("message %s: %s answered %s", m.ID, c.url, resp.Status)
+	}
+	return nil
+}
+
+func archiveClosed(bank *models.Bank, retention time.Duration, locks shared.Locker) {
+	for now := range time.Tick(archiveInterval) {
+		once(locks, "archive-closed", archiveInterval, func() {
+			archived, err := bank.ArchiveClosed(retention, now)
+			if len(archived) > 0 {
+				log.Printf("archived accounts %v", archived)
+			}
+			if err != nil {
+				log.Print(err)
+			}
+		})
+	}
+}
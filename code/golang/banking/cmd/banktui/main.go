@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gsolano/banking/config"
+	"gsolano/banking/i18n"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/store"
+	_ "gsolano/banking/store/postgres"
+)
+
+// refreshInterval is how often the dashboard redraws without an event, so
+// that what changes with time, such as settled transfers, shows.
+const (
+	feedSize        = 10
+	searchLimit     = 5
+	refreshInterval = 5 * time.Second
+)
+
+// dashboard renders the accounts of a bank and the latest transactions, and
+// reads deposit, withdraw and transfer forms from the terminal. It redraws
+// when the bank publishes an event and every refreshInterval while it waits
+// for a command, but not in the middle of a form. save, if set, saves the
+// bank after each operation.
+type dashboard struct {
+	bank   *models.Bank
+	index  *models.TextIndex
+	lines  <-chan string
+	redraw chan struct{}
+	save   func() error
+	locale i18n.Locale
+
+	mu     sync.Mutex
+	feed   []models.Event
+	status string
+}
+
+func newDashboard(bank *models.Bank, in io.Reader) *dashboard {
+	lines := make(chan string)
+	go func() {
+		s := bufio.NewScanner(in)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+		close(lines)
+	}()
+	d := &dashboard{bank: bank, index: models.NewTextIndex(bank), lines: lines, redraw: make(chan struct{}, 1), locale: i18n.FromEnv()}
+	bank.Events.Subscribe(d.onEvent)
+	return d
+}
+
+func (d *dashboard) onEvent(e models.Event) {
+	d.mu.Lock()
+	if e.Type == models.EventTransactionPosted {
+		d.feed = append(d.feed, e)
+		if len(d.feed) > feedSize {
+			d.feed = d.feed[len(d.feed)-feedSize:]
+		}
+	} else {
+		d.status = e.Message
+	}
+	d.mu.Unlock()
+	select {
+	case d.redraw <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dashboard) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Clear the screen and move the cursor home.
+	fmt.Print("\033[H\033[2J")
+	fmt.Println("=== Bank dashboard ===")
+	fmt.Println()
+	fmt.Printf("%-12s %14s  %s\n", "Account", "Balance", "Nickname")
+	for _, account := range d.bank.ListAccounts(false) {
+		balance, _ := d.bank.Balance(account.Number())
+		alias, _ := d.bank.Alias(account.Number())
+		fmt.Printf("%-12s %14s  %s\n", account.Number(), i18n.FormatAmount(d.locale, balance), strings.TrimSpace(alias.Emoji+" "+alias.Nickname))
+	}
+
+	fmt.Println()
+	fmt.Println("Latest transactions")
+	if len(d.feed) == 0 {
+		fmt.Println("  (none yet)")
+	}
+	for i := len(d.feed) - 1; i >= 0; i-- {
+		e := d.feed[i]
+		tx := e.Transaction
+		fmt.Printf("  %s  %-12s %-10s %12s  %s\n",
+			tx.Time.Format("15:04:05"), e.AccountNumber, tx.Type, i18n.FormatAmount(d.locale, tx.Amount), tx.Counterparty)
+	}
+
+	fmt.Println()
+	if d.status != "" {
+		fmt.Println(d.status)
+	}
+	fmt.Print("[d]eposit  [w]ithdraw  [t]ransfer  [s]earch  [r]efresh  [q]uit > ")
+}
+
+func (d *dashboard) setStatus(format string, args ...any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = fmt.Sprintf(format, args...)
+}
+
+// prompt asks for a single form field. It returns false once input is closed.
+func (d *dashboard) prompt(label string) (string, bool) {
+	fmt.Printf("%s: ", label)
+	line, ok := <-d.lines
+	return strings.TrimSpace(line), ok
+}
+
+// promptAccount asks for an account by number or nickname and returns its
+// number.
+func (d *dashboard) promptAccount(label string) (string, bool) {
+	ref, ok := d.prompt(label)
+	if !ok {
+		return "", false
+	}
+	number, err := d.bank.Resolve(ref)
+	if err != nil {
+		d.setStatus("Error: %v", err)
+		return "", false
+	}
+	return number, true
+}
+
+func (d *dashboard) promptAmount() (float64, bool) {
+	text, ok := d.prompt("Amount")
+	if !ok {
+		return 0, false
+	}
+	amount, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		d.setStatus("Invalid amount %q", text)
+		return 0, false
+	}
+	return amount, true
+}
+
+func (d *dashboard) deposit() {
+	number, ok := d.promptAccount("Account")
+	if !ok {
+		return
+	}
+	amount, ok := d.promptAmount()
+	if !ok {
+		return
+	}
+	d.report(d.bank.Deposit(number, amount), "Deposited %s into %s", i18n.FormatAmount(d.locale, amount), number)
+}
+
+func (d *dashboard) withdraw() {
+	number, ok := d.promptAccount("Account")
+	if !ok {
+		return
+	}
+	amount, ok := d.promptAmount()
+	if !ok {
+		return
+	}
+	category, ok := d.prompt("Category (optional)")
+	if !ok {
+		return
+	}
+	d.report(d.bank.Withdraw(number, amount, models.WithCategory(category)), "Withdrew %s from %s", i18n.FormatAmount(d.locale, amount), number)
+}
+
+func (d *dashboard) transfer() {
+	from, ok := d.promptAccount("From account")
+	if !ok {
+		return
+	}
+	to, ok := d.promptAccount("To account")
+	if !ok {
+		return
+	}
+	amount, ok := d.promptAmount()
+	if !ok {
+		return
+	}
+	d.report(d.bank.Transfer(from, to, amount), "Transferred %s from %s to %s", i18n.FormatAmount(d.locale, amount), from, to)
+}
+
+// search shows the payments whose description or counterparty best match
+// the words entered.
+func (d *dashboard) search() {
+	text, ok := d.prompt("Search payments")
+	if !ok || text == "" {
+		return
+	}
+	results := d.index.Search(text, searchLimit)
+	if len(results) == 0 {
+		d.setStatus("No payments match %q", text)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Payments matching %q", text)
+	for _, m := range results {
+		tx := m.Transaction
+		fmt.Fprintf(&b, "\n  %s  %-12s %12s  %-20s %s",
+			tx.Time.Format("2006-01-02"), m.Account, i18n.FormatAmount(d.locale, tx.Amount), tx.Counterparty, tx.Description)
+	}
+	d.setStatus("%s", b.String())
+}
+
+func (d *dashboard) report(err error, format string, args ...any) {
+	if err == nil && d.save != nil {
+		if err = d.save(); err != nil {
+			err = fmt.Errorf("done, but saving the bank failed: %w", err)
+		}
+	}
+	if err != nil {
+		d.setStatus("Error: %v", err)
+		return
+	}
+	d.setStatus(format, args...)
+}
+
+func (d *dashboard) run() {
+	tick := time.NewTicker(refreshInterval)
+	defer tick.Stop()
+	for {
+		d.draw()
+		var line string
+		select {
+		case <-d.redraw:
+			continue
+		case <-tick.C:
+			continue
+		case l, ok := <-d.lines:
+			if !ok {
+				fmt.Println()
+				return
+			}
+			line = l
+		}
+		switch strings.TrimSpace(line) {
+		case "d":
+			d.deposit()
+		case "w":
+			d.withdraw()
+		case "t":
+			d.transfer()
+		case "s":
+			d.search()
+		case "r", "":
+		case "q":
+			return
+		default:
+			d.setStatus("Unknown command")
+		}
+	}
+}
+
+// main runs the dashboard on the bank kept in the configured store, saving
+// it after every operation and on quit. With the memory store, the default,
+// it opens two demo accounts instead. The store should not be one a
+// bankserver is serving at the same time, which would save over it.
+func main() {
+	configPath := flag.String("config", os.Getenv("BANK_CONFIG"), "path to a YAML or TOML config file (default $BANK_CONFIG)")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	// The dashboard redraws everything itself.
+	models.SetOutput(io.Discard)
+
+	bank := models.NewBank()
+	bank.IDs = cfg.IDGenerator()
+	bank.Location, _ = cfg.Location()
+	bank.Rounding, _ = money.ParseRounding(cfg.Rounding)
+	bank.ReceiptKey = []byte(cfg.ReceiptKey)
+	bank.SecretKey = []byte(cfg.SecretKey)
+	d := newDashboard(bank, os.Stdin)
+	if cfg.Store.Driver == "memory" {
+		bank.OpenAccount("savings", "", "12345")
+		bank.Deposit("12345", 1000)
+		bank.OpenAccount("checking", "", "67890")
+		bank.Deposit("67890", 500)
+		d.run()
+		return
+	}
+
+	st, err := openStore(cfg.Store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := store.Load(st, bank); err != nil {
+		log.Fatalf("loading %s store %s: %v", cfg.Store.Driver, cfg.Store.DSN, err)
+	}
+	d.save = func() error { return store.Save(st, bank) }
+	d.run()
+	err = errors.Join(d.save(), st.Close())
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// openStore opens the configured store with its batch, pool and outbox
+// settings, as bankserver does.
+func openStore(cfg config.Store) (store.Store, error) {
+	opts := []store.Option{store.WithBatchSize(cfg.BatchSize),
+		store.WithPool(store.Pool{MaxConns: cfg.MaxConns, MinConns: cfg.MinConns, MaxConnIdleTime: cfg.MaxConnIdleTime})}
+	if cfg.OutboxURL != "" {
+		opts = append(opts, store.WithOutbox())
+	}
+	return store.Open(cfg.Driver, cfg.DSN, opts...)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestDashboardForms runs a scripted session of deposit, withdraw and
+// transfer forms, one of them naming an unknown account, and checks the
+// balances, that the bank was saved after each operation that went through,
+// and that the feed and status show what happened.
+func TestDashboardForms(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	script := "d\n12345\n100\nw\n67890\n50\ngroceries\nt\n12345\n67890\n30\nt\n12345\nX9\nq\n"
+	d := newDashboard(bank, strings.NewReader(script))
+	saves := 0
+	d.save = func() error {
+		saves++
+		return nil
+	}
+	bank.OpenAccount("savings", "", "12345")
+	bank.Deposit("12345", 1000)
+	bank.OpenAccount("checking", "", "67890")
+	bank.Deposit("67890", 500)
+	d.run()
+
+	for number, want := range map[string]float64{"12345": 1070, "67890": 480} {
+		if got, _ := bank.Balance(number); got != want {
+			t.Errorf("%s balance = %.2f, want %.2f", number, got, want)
+		}
+	}
+	if saves != 3 {
+		t.Errorf("saved %d times, want 3", saves)
+	}
+	if len(d.feed) != 6 {
+		t.Errorf("feed has %d transactions, want 6", len(d.feed))
+	}
+	if !strings.HasPrefix(d.status, "Error:") || !strings.Contains(d.status, "not found") {
+		t.Errorf("status %q, want the unknown account X9 reported", d.status)
+	}
+}
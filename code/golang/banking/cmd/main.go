@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"gsolano/banking/models"
+	"gsolano/banking/money"
 )
 
 type Account = models.Account
@@ -11,21 +12,18 @@ type CheckingAccount = models.CheckingAccount
 type BankAccount = models.BankAccount
 
 func transfer(source BankAccount, target BankAccount, amount float64) bool {
-	amountBeforeWithdraw := source.CheckBalance()
-	source.Withdraw(amount)
-
-	if amountBeforeWithdraw > source.CheckBalance() {
-		target.Deposit(amount)
-		return true
+	if err := source.Withdraw(amount); err != nil {
+		return false
 	}
 
-	return false
+	target.Deposit(amount)
+	return true
 }
 
 func main() {
 	savings := &SavingsAccount{
 		Account:      Account{AccountNumber: "12345", Balance: 1000},
-		InterestRate: 5.0,
+		InterestRate: money.Percent(5),
 	}
 
 	checking := &CheckingAccount{
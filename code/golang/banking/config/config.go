@@ -0,0 +1,675 @@
+// Package config loads the settings of the bank binaries from a YAML or TOML
+// file, with environment variables taking precedence over the file.
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"gsolano/banking/fx"
+	"gsolano/banking/i18n"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/policy"
+	"gsolano/banking/signing"
+)
+
+type Config struct {
+	// Tenant names the institution this process runs as; it selects the
+	// per-tenant feature flags below.
+	Tenant string `yaml:"tenant,omitempty" toml:"tenant,omitempty" env:"BANK_TENANT"`
+	// Locale is the language of messages and the format of amounts.
+	Locale string `yaml:"locale" toml:"locale" env:"BANK_LOCALE"`
+	// Rounding is how interest, conversions and fees are rounded to the
+	// currency's minor unit: half_even, half_up or floor.
+	Rounding string `yaml:"rounding" toml:"rounding" env:"BANK_ROUNDING"`
+	// IDs is how ledger entries, transfers, quotes, invitations and
+	// idempotency records are identified: uuidv7, the default, or
+	// sequential, short numbers that start over with the process and so
+	// only suit the memory store.
+	IDs string `yaml:"ids,omitempty" toml:"ids,omitempty" env:"BANK_IDS"`
+	// TimeZone is the IANA name of the zone ledger entries are stamped in
+	// and statement periods and days are counted in, such as
+	// "America/New_York", for accounts without a zone of their own. Empty
+	// keeps the zone of the host.
+	TimeZone string `yaml:"timezone,omitempty" toml:"timezone,omitempty" env:"BANK_TIMEZONE"`
+	// ReceiptKey is the secret receipts are signed with, so that they can
+	// be verified later; without one they are unsigned.
+	ReceiptKey string `yaml:"receipt_key,omitempty" toml:"receipt_key,omitempty" env:"BANK_RECEIPT_KEY"`
+	// SecretKey encrypts the customers' authenticator app secrets where
+	// the bank is saved; without one they are not saved, and customers
+	// enroll their apps again after a restart.
+	SecretKey string   `yaml:"secret_key,omitempty" toml:"secret_key,omitempty" env:"BANK_SECRET_KEY"`
+	Server    Server   `yaml:"server" toml:"server"`
+	Store     Store    `yaml:"store" toml:"store"`
+	Shared    Shared   `yaml:"shared" toml:"shared"`
+	Archive   Archive  `yaml:"archive" toml:"archive"`
+	Opening   Opening  `yaml:"opening" toml:"opening"`
+	Inbox     Inbox    `yaml:"inbox" toml:"inbox"`
+	Alerts    Alerts   `yaml:"alerts,omitempty" toml:"alerts,omitempty"`
+	Sessions  Sessions `yaml:"sessions" toml:"sessions"`
+	Dormancy  Dormancy `yaml:"dormancy" toml:"dormancy"`
+	Reserves  Reserves `yaml:"reserves" toml:"reserves"`
+	Chaos     Chaos    `yaml:"chaos,omitempty" toml:"chaos,omitempty"`
+	Signing   Signing  `yaml:"signing,omitempty" toml:"signing,omitempty"`
+	Fees      Fees     `yaml:"fees" toml:"fees"`
+	Interest  Interest `yaml:"interest" toml:"interest"`
+	Limits    Limits   `yaml:"limits" toml:"limits"`
+	FX        FX       `yaml:"fx" toml:"fx"`
+	// Allocation is the order payments into each credit product, loan or
+	// credit_card, pay off fees, interest and principal, such as
+	// [interest, fees, principal]. Products left out keep their default.
+	Allocation map[string][]string `yaml:"allocation,omitempty" toml:"allocation,omitempty"`
+	// Products adds account products to the catalog, or replaces the
+	// built-in savings and checking ones, by code.
+	Products map[string]Product `yaml:"products,omitempty" toml:"products,omitempty"`
+	// Policy lists rules checked before every deposit, withdrawal and
+	// transfer, see package policy. bankserver reloads them on SIGHUP.
+	Policy []PolicyRule `yaml:"policy,omitempty" toml:"policy,omitempty"`
+	// Duplicates catches transfers and payments that repeat one just made.
+	Duplicates Duplicates `yaml:"duplicates,omitempty" toml:"duplicates,omitempty"`
+	// PayeeCheck checks the payee names transfers give against the
+	// holders of the accounts they go to.
+	PayeeCheck PayeeCheck `yaml:"payee_check,omitempty" toml:"payee_check,omitempty"`
+
+	// Features switches feature flags on or off for every tenant and
+	// Tenants overrides them per tenant, see models.FeatureFlags.
+	Features map[string]bool   `yaml:"features,omitempty" toml:"features,omitempty"`
+	Tenants  map[string]Tenant `yaml:"tenants,omitempty" toml:"tenants,omitempty"`
+}
+
+type Tenant struct {
+	Features map[string]bool `yaml:"features" toml:"features"`
+}
+
+type Server struct {
+	Addr     string `yaml:"addr" toml:"addr" env:"BANK_SERVER_ADDR"`
+	GRPCAddr string `yaml:"grpc_addr" toml:"grpc_addr" env:"BANK_SERVER_GRPC_ADDR"`
+	Token    string `yaml:"token" toml:"token" env:"BANK_SERVER_TOKEN"`
+	// RateLimit is how many API requests a second each client may make,
+	// in bursts of up to RateBurst; 0 does not limit.
+	RateLimit float64 `yaml:"rate_limit,omitempty" toml:"rate_limit,omitempty" env:"BANK_SERVER_RATE_LIMIT"`
+	RateBurst int     `yaml:"rate_burst,omitempty" toml:"rate_burst,omitempty" env:"BANK_SERVER_RATE_BURST"`
+}
+
+// Shared selects where replicas of bankserver keep idempotency keys,
+// rate-limit buckets and locks: "memory", for a single replica, or "redis"
+// with the URL of the server, such as redis://cache:6379/0.
+type Shared struct {
+	Driver string `yaml:"driver" toml:"driver" env:"BANK_SHARED_DRIVER"`
+	URL    string `yaml:"url,omitempty" toml:"url,omitempty" env:"BANK_SHARED_URL"`
+}
+
+// Store selects where the bank keeps its data. Driver "memory" needs no DSN;
+// for "json" and "sqlite" the DSN is the path of the file. BatchSize is how
+// many accounts the sqlite store saves per database transaction and ledger
+// entries per INSERT; 0 keeps the default of 500.
+type Store struct {
+	Driver    string `yaml:"driver" toml:"driver" env:"BANK_STORE_DRIVER"`
+	DSN       string `yaml:"dsn" toml:"dsn" env:"BANK_STORE_DSN"`
+	BatchSize int    `yaml:"batch_size,omitempty" toml:"batch_size,omitempty" env:"BANK_STORE_BATCH_SIZE"`
+	// MaxConns, MinConns and MaxConnIdleTime size the connection pool of
+	// the postgres driver; zero keeps the driver's defaults.
+	MaxConns        int           `yaml:"max_conns,omitempty" toml:"max_conns,omitempty" env:"BANK_STORE_MAX_CONNS"`
+	MinConns        int           `yaml:"min_conns,omitempty" toml:"min_conns,omitempty" env:"BANK_STORE_MIN_CONNS"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time,omitempty" toml:"max_conn_idle_time,omitempty" env:"BANK_STORE_MAX_CONN_IDLE_TIME"`
+	// OutboxURL, for the sqlite and postgres drivers, makes every save
+	// write an event for each new ledger entry to an outbox in the same
+	// database transaction, and bankserver POST them to this URL.
+	OutboxURL string `yaml:"outbox_url,omitempty" toml:"outbox_url,omitempty" env:"BANK_STORE_OUTBOX_URL"`
+}
+
+// Archive moves accounts closed for longer than Retention, such as "2160h",
+// out of the bank. With Dir set their ledgers are kept as compressed files
+// there, otherwise in memory. A zero Retention never archives. DeleteGrace
+// is how long a soft-deleted account can be restored, 720h when zero.
+type Archive struct {
+	Retention   time.Duration `yaml:"retention" toml:"retention" env:"BANK_ARCHIVE_RETENTION"`
+	Dir         string        `yaml:"dir" toml:"dir" env:"BANK_ARCHIVE_DIR"`
+	DeleteGrace time.Duration `yaml:"delete_grace,omitempty" toml:"delete_grace,omitempty" env:"BANK_ARCHIVE_DELETE_GRACE"`
+}
+
+// Opening sets how long an application to open an account may wait in a
+// step, such as for its KYC checks or first deposit, before it is
+// abandoned; 720h when zero.
+type Opening struct {
+	Timeout time.Duration `yaml:"timeout,omitempty" toml:"timeout,omitempty" env:"BANK_OPENING_TIMEOUT"`
+}
+
+// Inbox sets how long messages stay in customers' inboxes, 4320h when
+// zero, and WebhookURL, when set, is posted every message sent, as JSON,
+// for a notifier to deliver by email, SMS or push.
+type Inbox struct {
+	Retention  time.Duration `yaml:"retention,omitempty" toml:"retention,omitempty" env:"BANK_INBOX_RETENTION"`
+	WebhookURL string        `yaml:"webhook_url,omitempty" toml:"webhook_url,omitempty" env:"BANK_INBOX_WEBHOOK_URL"`
+}
+
+// Alerts holds back the alerts of accounts' alert rules from QuietStart
+// until QuietEnd, in whole hours of the day, and delivers them after. The
+// hours may wrap around midnight; equal hours hold nothing back.
+type Alerts struct {
+	QuietStart int `yaml:"quiet_start,omitempty" toml:"quiet_start,omitempty" env:"BANK_ALERTS_QUIET_START"`
+	QuietEnd   int `yaml:"quiet_end,omitempty" toml:"quiet_end,omitempty" env:"BANK_ALERTS_QUIET_END"`
+}
+
+// QuietHours returns the quiet hours of a.
+func (a Alerts) QuietHours() models.QuietHours {
+	return models.QuietHours{Start: a.QuietStart, End: a.QuietEnd}
+}
+
+// Sessions sets how long customers' sessions last, 720h when zero, and how
+// long after authenticating they may do high-risk operations, such as
+// raising limits, before they must step up again; 5m when zero.
+// StepUpAmount is the most a session may transfer without stepping up, 0
+// for any amount.
+type Sessions struct {
+	TTL          time.Duration `yaml:"ttl,omitempty" toml:"ttl,omitempty" env:"BANK_SESSIONS_TTL"`
+	StepUpWindow time.Duration `yaml:"step_up_window,omitempty" toml:"step_up_window,omitempty" env:"BANK_SESSIONS_STEP_UP_WINDOW"`
+	StepUpAmount float64       `yaml:"step_up_amount,omitempty" toml:"step_up_amount,omitempty" env:"BANK_SESSIONS_STEP_UP_AMOUNT"`
+}
+
+// Duplicates are transfers and payments of the same amount to the same
+// payee out of an account as one made within Window, 1h when zero. Mode
+// warn rejects them until they are confirmed, block rejects them unless
+// the bank confirms, and "" lets them through.
+type Duplicates struct {
+	Mode   models.DuplicateMode `yaml:"mode,omitempty" toml:"mode,omitempty" env:"BANK_DUPLICATES_MODE"`
+	Window time.Duration        `yaml:"window,omitempty" toml:"window,omitempty" env:"BANK_DUPLICATES_WINDOW"`
+}
+
+// Check returns the duplicates as the bank takes them.
+func (d Duplicates) Check() models.DuplicateCheck {
+	return models.DuplicateCheck{Mode: d.Mode, Window: d.Window}
+}
+
+// PayeeCheck is what happens to transfers whose payee name is a close or
+// no match for the holders of the account: Mode warn rejects them until
+// they are confirmed, block unless the bank confirms, and "" lets them
+// through.
+type PayeeCheck struct {
+	Mode models.PayeeMode `yaml:"mode,omitempty" toml:"mode,omitempty" env:"BANK_PAYEE_CHECK_MODE"`
+}
+
+// Dormancy marks deposit accounts dormant after Months months without a
+// deposit or withdrawal by their customers, and reports their balances for
+// escheatment after EscheatMonths. Zero never does either.
+type Dormancy struct {
+	Months        int `yaml:"months,omitempty" toml:"months,omitempty" env:"BANK_DORMANCY_MONTHS"`
+	EscheatMonths int `yaml:"escheat_months,omitempty" toml:"escheat_months,omitempty" env:"BANK_DORMANCY_ESCHEAT_MONTHS"`
+}
+
+// Reserves are the ratios the reserve report simulates a regulator holding
+// the bank to, see models.ReserveRatios: the share of deposits held as
+// cash, the least capital against risk-weighted assets, the weight of loans
+// and the capital paid in.
+type Reserves struct {
+	Ratio          money.Rate `yaml:"ratio" toml:"ratio" env:"BANK_RESERVES_RATIO"`
+	CapitalRatio   money.Rate `yaml:"capital_ratio" toml:"capital_ratio" env:"BANK_RESERVES_CAPITAL_RATIO"`
+	LoanRiskWeight money.Rate `yaml:"loan_risk_weight" toml:"loan_risk_weight" env:"BANK_RESERVES_LOAN_RISK_WEIGHT"`
+	PaidInCapital  float64    `yaml:"paid_in_capital,omitempty" toml:"paid_in_capital,omitempty" env:"BANK_RESERVES_PAID_IN_CAPITAL"`
+}
+
+// Ratios returns the reserves as the bank takes them.
+func (r Reserves) Ratios() models.ReserveRatios {
+	return models.ReserveRatios{Reserve: r.Ratio, Capital: r.CapitalRatio, LoanRiskWeight: r.LoanRiskWeight, PaidInCapital: r.PaidInCapital}
+}
+
+// Chaos, with Enabled, lets API clients inject faults through /api/chaos:
+// failing store writes, a webhook endpoint that is down and a skewed clock,
+// see package chaos. It is for sandboxes and resilience tests only.
+type Chaos struct {
+	Enabled bool `yaml:"enabled,omitempty" toml:"enabled,omitempty" env:"BANK_CHAOS_ENABLED"`
+}
+
+// Signing is the key pair exported statements, receipts and snapshots are
+// signed and verified with, see package signing. Key is the PEM file of the
+// private key, and PublicKey that of the public key, for machines that only
+// verify; without either nothing is signed.
+type Signing struct {
+	Key       string `yaml:"key,omitempty" toml:"key,omitempty" env:"BANK_SIGNING_KEY"`
+	PublicKey string `yaml:"public_key,omitempty" toml:"public_key,omitempty" env:"BANK_SIGNING_PUBLIC_KEY"`
+}
+
+// Fees are flat amounts charged per operation.
+type Fees struct {
+	Withdrawal float64 `yaml:"withdrawal" toml:"withdrawal" env:"BANK_FEES_WITHDRAWAL"`
+	Transfer   float64 `yaml:"transfer" toml:"transfer" env:"BANK_FEES_TRANSFER"`
+	Overdraft  float64 `yaml:"overdraft" toml:"overdraft" env:"BANK_FEES_OVERDRAFT"`
+}
+
+// Interest holds the default rates of newly opened accounts, written as
+// "5%" or "125bps".
+type Interest struct {
+	SavingsRate money.Rate `yaml:"savings_rate" toml:"savings_rate" env:"BANK_INTEREST_SAVINGS_RATE"`
+}
+
+// Product is an account product, see models.Product. Kind is savings or
+// checking.
+type Product struct {
+	Name            string     `yaml:"name" toml:"name"`
+	Kind            string     `yaml:"kind" toml:"kind"`
+	InterestRate    money.Rate `yaml:"interest_rate,omitempty" toml:"interest_rate,omitempty"`
+	MonthlyFee      float64    `yaml:"monthly_fee,omitempty" toml:"monthly_fee,omitempty"`
+	WithdrawalFee   float64    `yaml:"withdrawal_fee,omitempty" toml:"withdrawal_fee,omitempty"`
+	TransferFee     float64    `yaml:"transfer_fee,omitempty" toml:"transfer_fee,omitempty"`
+	OverdraftLimit  float64    `yaml:"overdraft_limit,omitempty" toml:"overdraft_limit,omitempty"`
+	OverdraftFee    float64    `yaml:"overdraft_fee,omitempty" toml:"overdraft_fee,omitempty"`
+	PerTransaction  float64    `yaml:"per_transaction,omitempty" toml:"per_transaction,omitempty"`
+	DailyWithdrawal float64    `yaml:"daily_withdrawal,omitempty" toml:"daily_withdrawal,omitempty"`
+	Currency        string     `yaml:"currency,omitempty" toml:"currency,omitempty"`
+	MinimumDeposit  float64    `yaml:"minimum_deposit,omitempty" toml:"minimum_deposit,omitempty"`
+	// DayCount is the day-count convention of a savings product, ACT/365,
+	// ACT/360 or 30/360; without one it earns its rate once per period.
+	DayCount money.DayCount `yaml:"day_count,omitempty" toml:"day_count,omitempty"`
+}
+
+// FX sets the bank's currency, that of accounts whose product names none,
+// and where the rates of transfers between currencies come from: Feed, an
+// ECB-style feed such as fx.ECBDaily, asked at most every Refresh (1h when
+// zero) per pair and trusted for MaxAge (96h when zero), falling back to the
+// fixed Rates, keyed by pair such as EUR/USD. Margin is taken off the rate
+// of such transfers and Fee charged for each, at the rate of a transfer
+// quote, which locks for QuoteTTL (30s when zero). History, a feed of past
+// rates such as fx.ECBHistory fetched daily, values accounts on past days,
+// falling back to Rates.
+type FX struct {
+	Currency string             `yaml:"currency" toml:"currency" env:"BANK_CURRENCY"`
+	Feed     string             `yaml:"feed,omitempty" toml:"feed,omitempty" env:"BANK_FX_FEED"`
+	History  string             `yaml:"history,omitempty" toml:"history,omitempty" env:"BANK_FX_HISTORY"`
+	Refresh  time.Duration      `yaml:"refresh,omitempty" toml:"refresh,omitempty" env:"BANK_FX_REFRESH"`
+	MaxAge   time.Duration      `yaml:"max_age,omitempty" toml:"max_age,omitempty" env:"BANK_FX_MAX_AGE"`
+	Rates    map[string]float64 `yaml:"rates,omitempty" toml:"rates,omitempty"`
+	Margin   money.Rate         `yaml:"margin,omitempty" toml:"margin,omitempty" env:"BANK_FX_MARGIN"`
+	Fee      float64            `yaml:"fee,omitempty" toml:"fee,omitempty" env:"BANK_FX_FEE"`
+	QuoteTTL time.Duration      `yaml:"quote_ttl,omitempty" toml:"quote_ttl,omitempty" env:"BANK_FX_QUOTE_TTL"`
+}
+
+// PolicyRule denies the operations When is true for, an expression such as
+// amount > 5000 && kind == "withdrawal", giving Message as the reason.
+type PolicyRule struct {
+	Name    string `yaml:"name" toml:"name"`
+	When    string `yaml:"when" toml:"when"`
+	Message string `yaml:"message,omitempty" toml:"message,omitempty"`
+}
+
+// Limits cap customer operations; zero means unlimited. Overdraft is the
+// default overdraft limit of new checking accounts.
+type Limits struct {
+	Overdraft       float64 `yaml:"overdraft" toml:"overdraft" env:"BANK_LIMITS_OVERDRAFT"`
+	PerTransaction  float64 `yaml:"per_transaction" toml:"per_transaction" env:"BANK_LIMITS_PER_TRANSACTION"`
+	DailyWithdrawal float64 `yaml:"daily_withdrawal" toml:"daily_withdrawal" env:"BANK_LIMITS_DAILY_WITHDRAWAL"`
+}
+
+var drivers = map[string]bool{"memory": true, "json": true, "sqlite": true, "postgres": true}
+
+// products maps the credit products of the allocation section to their
+// models.KindOf names.
+var products = map[string]string{"loan": "Loan", "credit_card": "CreditCard"}
+
+func Default() Config {
+	return Config{
+		Locale:   string(i18n.Default),
+		Rounding: money.HalfEven.String(),
+		Server:   Server{Addr: ":8080"},
+		Store:    Store{Driver: "memory"},
+		Shared:   Shared{Driver: "memory"},
+		Interest: Interest{SavingsRate: money.Percent(5)},
+		Limits:   Limits{Overdraft: 200},
+		Reserves: Reserves{Ratio: models.DefaultReserveRatios.Reserve, CapitalRatio: models.DefaultReserveRatios.Capital,
+			LoanRiskWeight: models.DefaultReserveRatios.LoanRiskWeight},
+	}
+}
+
+// Load reads the file at path, if any, on top of the defaults and then
+// applies environment overrides. The format is chosen by the extension:
+// .yaml, .yml or .toml. The result is not validated.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".yaml", ".yml":
+			dec := yaml.NewDecoder(bytes.NewReader(data))
+			dec.KnownFields(true)
+			// An empty file decodes to io.EOF and leaves the defaults.
+			if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+				return cfg, fmt.Errorf("%s: %w", path, err)
+			}
+		case ".toml":
+			md, err := toml.Decode(string(data), &cfg)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", path, err)
+			}
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				return cfg, fmt.Errorf("%s: unknown setting %s", path, undecoded[0])
+			}
+		default:
+			return cfg, fmt.Errorf("%s: unsupported config format %q", path, ext)
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides every field with an env tag whose variable is set.
+func applyEnv(v reflect.Value, lookup func(string) (string, bool)) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, sf := v.Field(i), v.Type().Field(i)
+		if sf.Type.Kind() == reflect.Struct {
+			if err := applyEnv(field, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		name := sf.Tag.Get("env")
+		value, ok := lookup(name)
+		if name == "" || !ok {
+			continue
+		}
+		if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			continue
+		}
+		if sf.Type == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a duration", name, value)
+			}
+			field.SetInt(int64(d))
+			continue
+		}
+		switch sf.Type.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", name, value)
+			}
+			field.SetFloat(f)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a whole number", name, value)
+			}
+			field.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not true or false", name, value)
+			}
+			field.SetBool(b)
+		}
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, ok := i18n.Parse(c.Locale)
+	check(ok, "locale: unsupported locale %q", c.Locale)
+	_, err := money.ParseRounding(c.Rounding)
+	check(err == nil, "rounding: %v", err)
+	check(c.IDs == "" || c.IDs == "uuidv7" || c.IDs == "sequential", "ids: must be uuidv7 or sequential")
+	check(c.IDs != "sequential" || c.Store.Driver == "memory", "ids: sequential IDs repeat after a restart; use them with the memory store only")
+	_, err = c.Location()
+	check(err == nil, "timezone: %v", err)
+	_, err = c.SigningKey()
+	check(err == nil, "signing.key: %v", err)
+	_, err = c.VerifyingKey()
+	check(err == nil, "signing.public_key: %v", err)
+	check(validAddr(c.Server.Addr), "server.addr: %q is not a host:port address", c.Server.Addr)
+	check(c.Server.GRPCAddr == "" || validAddr(c.Server.GRPCAddr), "server.grpc_addr: %q is not a host:port address", c.Server.GRPCAddr)
+	check(c.Server.GRPCAddr == "" || c.Server.GRPCAddr != c.Server.Addr, "server.grpc_addr: must differ from server.addr")
+	check(drivers[c.Store.Driver], "store.driver: unknown driver %q", c.Store.Driver)
+	check(c.Store.Driver == "memory" || c.Store.DSN != "", "store.dsn: required for driver %q", c.Store.Driver)
+	check(c.Store.BatchSize >= 0, "store.batch_size: must not be negative")
+	check(c.Store.MaxConns >= 0, "store.max_conns: must not be negative")
+	check(c.Store.MinConns >= 0, "store.min_conns: must not be negative")
+	check(c.Store.MaxConns == 0 || c.Store.MinConns <= c.Store.MaxConns, "store.min_conns: must not exceed max_conns")
+	check(c.Store.MaxConnIdleTime >= 0, "store.max_conn_idle_time: must not be negative")
+	check(c.Store.OutboxURL == "" || c.Store.Driver == "sqlite" || c.Store.Driver == "postgres", "store.outbox_url: needs driver sqlite or postgres")
+	check(c.Store.OutboxURL == "" || validURL(c.Store.OutboxURL), "store.outbox_url: %q is not an http or https URL", c.Store.OutboxURL)
+
+	check(c.Server.RateLimit >= 0, "server.rate_limit: must not be negative")
+	check(c.Server.RateLimit == 0 || c.Server.RateBurst >= 1, "server.rate_burst: must be at least 1 with a rate limit")
+	check(c.Shared.Driver == "memory" || c.Shared.Driver == "redis", "shared.driver: unknown driver %q", c.Shared.Driver)
+	check(c.Shared.Driver != "redis" || c.Shared.URL != "", "shared.url: required for driver redis")
+
+	check(c.Archive.Retention >= 0, "archive.retention: must not be negative")
+	check(c.Archive.DeleteGrace >= 0, "archive.delete_grace: must not be negative")
+	check(c.Opening.Timeout >= 0, "opening.timeout: must not be negative")
+	check(c.Inbox.Retention >= 0, "inbox.retention: must not be negative")
+	check(c.Sessions.TTL >= 0, "sessions.ttl: must not be negative")
+	check(c.Sessions.StepUpWindow >= 0, "sessions.step_up_window: must not be negative")
+	check(c.Sessions.StepUpAmount >= 0, "sessions.step_up_amount: must not be negative")
+	check(c.Duplicates.Mode == models.DuplicatesOff || c.Duplicates.Mode == models.DuplicatesWarn || c.Duplicates.Mode == models.DuplicatesBlock,
+		"duplicates.mode: %q is not warn or block", c.Duplicates.Mode)
+	check(c.Duplicates.Window >= 0, "duplicates.window: must not be negative")
+	check(c.PayeeCheck.Mode == models.PayeesOff || c.PayeeCheck.Mode == models.PayeesWarn || c.PayeeCheck.Mode == models.PayeesBlock,
+		"payee_check.mode: %q is not warn or block", c.PayeeCheck.Mode)
+	check(c.Inbox.WebhookURL == "" || validURL(c.Inbox.WebhookURL), "inbox.webhook_url: %q is not an http or https URL", c.Inbox.WebhookURL)
+	check(c.Alerts.QuietHours().Valid(), "alerts.quiet_start, alerts.quiet_end: must be hours from 0 to 23")
+	check(c.Reserves.Ratio >= 0 && c.Reserves.Ratio <= money.Percent100, "reserves.ratio: must be between 0 and 100")
+	check(c.Reserves.CapitalRatio >= 0 && c.Reserves.CapitalRatio <= money.Percent100, "reserves.capital_ratio: must be between 0 and 100")
+	check(c.Reserves.LoanRiskWeight >= 0, "reserves.loan_risk_weight: must not be negative")
+	check(c.Reserves.PaidInCapital >= 0, "reserves.paid_in_capital: must not be negative")
+	check(c.Dormancy.Months >= 0, "dormancy.months: must not be negative")
+	check(c.Dormancy.EscheatMonths >= 0, "dormancy.escheat_months: must not be negative")
+	check(c.Dormancy.EscheatMonths == 0 || c.Dormancy.EscheatMonths >= c.Dormancy.Months, "dormancy.escheat_months: must not be less than dormancy.months")
+	check(c.Fees.Withdrawal >= 0, "fees.withdrawal: must not be negative")
+	check(c.Fees.Transfer >= 0, "fees.transfer: must not be negative")
+	check(c.Fees.Overdraft >= 0, "fees.overdraft: must not be negative")
+	check(c.Interest.SavingsRate >= 0 && c.Interest.SavingsRate <= money.Percent100, "interest.savings_rate: must be between 0 and 100")
+	check(c.Limits.Overdraft >= 0, "limits.overdraft: must not be negative")
+	check(c.Limits.PerTransaction >= 0, "limits.per_transaction: must not be negative")
+	check(c.Limits.DailyWithdrawal >= 0, "limits.daily_withdrawal: must not be negative")
+	check(c.Limits.PerTransaction == 0 || c.Limits.DailyWithdrawal == 0 || c.Limits.PerTransaction <= c.Limits.DailyWithdrawal,
+		"limits.per_transaction: must not exceed limits.daily_withdrawal")
+
+	if err := c.ApplyFeatures(models.NewFeatureFlags()); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.ApplyAllocation(make(map[string]models.AllocationOrder)); err != nil {
+		errs = append(errs, err)
+	}
+	check(c.FX.Currency == "" || models.ValidCurrency(c.FX.Currency), "fx.currency: %q is not a three letter ISO 4217 code", c.FX.Currency)
+	check(c.FX.Feed == "" || validURL(c.FX.Feed), "fx.feed: %q is not an http or https URL", c.FX.Feed)
+	check(c.FX.History == "" || validURL(c.FX.History), "fx.history: %q is not an http or https URL", c.FX.History)
+	check(c.FX.Refresh >= 0, "fx.refresh: must not be negative")
+	check(c.FX.MaxAge >= 0, "fx.max_age: must not be negative")
+	check(c.FX.Margin >= 0 && c.FX.Margin < money.Percent100, "fx.margin: must be at least 0 and below 100")
+	check(c.FX.Fee >= 0, "fx.fee: must not be negative")
+	check(c.FX.QuoteTTL >= 0, "fx.quote_ttl: must not be negative")
+	for pair, rate := range c.FX.Rates {
+		_, _, err := fx.ParsePair(pair)
+		check(err == nil, "fx.rates: %v", err)
+		check(rate > 0, "fx.rates.%s: must be positive", pair)
+	}
+
+	if err := c.ApplyProducts(models.NewCatalog()); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.CompilePolicy(); err != nil {
+		errs = append(errs, fmt.Errorf("policy: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ApplyFeatures configures flags with the global and per-tenant features.
+func (c Config) ApplyFeatures(flags *models.FeatureFlags) error {
+	if err := flags.Apply("", c.Features); err != nil {
+		return fmt.Errorf("features: %w", err)
+	}
+	for name, tenant := range c.Tenants {
+		if err := flags.Apply(name, tenant.Features); err != nil {
+			return fmt.Errorf("tenants.%s.features: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyAllocation sets the configured payment allocation orders in orders,
+// such as models.Bank.Allocation.
+func (c Config) ApplyAllocation(orders map[string]models.AllocationOrder) error {
+	var errs []error
+	for product, names := range c.Allocation {
+		kind, ok := products[product]
+		if !ok {
+			errs = append(errs, fmt.Errorf("allocation.%s: unknown product, want loan or credit_card", product))
+			continue
+		}
+		order, err := models.ParseAllocationOrder(names)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allocation.%s: %w", product, err))
+			continue
+		}
+		orders[kind] = order
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyProducts defines the products of the catalog, such as
+// models.Bank.Products: the built-in savings and checking products with the
+// interest, limits and fee settings, then the configured products.
+func (c Config) ApplyProducts(catalog *models.Catalog) error {
+	limits := models.ProductLimits{PerTransaction: c.Limits.PerTransaction, DailyWithdrawal: c.Limits.DailyWithdrawal}
+	catalog.Define(models.Product{Code: "savings", Name: "Savings", Kind: models.ProductSavings,
+		InterestRate: c.Interest.SavingsRate, WithdrawalFee: c.Fees.Withdrawal, TransferFee: c.Fees.Transfer, Limits: limits})
+	catalog.Define(models.Product{Code: "checking", Name: "Checking", Kind: models.ProductChecking,
+		WithdrawalFee: c.Fees.Withdrawal, TransferFee: c.Fees.Transfer,
+		Overdraft: models.OverdraftPolicy{Limit: c.Limits.Overdraft, Fee: c.Fees.Overdraft}, Limits: limits})
+	var errs []error
+	for code, p := range c.Products {
+		err := catalog.Define(models.Product{
+			Code: code, Name: p.Name, Kind: models.ProductKind(p.Kind),
+			InterestRate: p.InterestRate, MonthlyFee: p.MonthlyFee,
+			WithdrawalFee: p.WithdrawalFee, TransferFee: p.TransferFee,
+			Overdraft: models.OverdraftPolicy{Limit: p.OverdraftLimit, Fee: p.OverdraftFee},
+			Limits:    models.ProductLimits{PerTransaction: p.PerTransaction, DailyWithdrawal: p.DailyWithdrawal},
+			Currency:  p.Currency, MinimumDeposit: p.MinimumDeposit, DayCount: p.DayCount,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("products.%s: %w", code, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IDGenerator returns the generator of IDs the ids setting names.
+func (c Config) IDGenerator() models.IDGenerator {
+	if c.IDs == "sequential" {
+		return &models.SequentialIDs{}
+	}
+	return &models.UUIDv7{}
+}
+
+// Location returns the zone the timezone setting names, nil when it is
+// empty.
+func (c Config) Location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// SigningKey returns the key signing.key names, nil when it is empty.
+func (c Config) SigningKey() (ed25519.PrivateKey, error) {
+	if c.Signing.Key == "" {
+		return nil, nil
+	}
+	return signing.LoadPrivateKey(c.Signing.Key)
+}
+
+// VerifyingKey returns the key signing.public_key names, or else the public
+// half of signing.key, nil when both are empty.
+func (c Config) VerifyingKey() (ed25519.PublicKey, error) {
+	path := c.Signing.PublicKey
+	if path == "" {
+		path = c.Signing.Key
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return signing.LoadPublicKey(path)
+}
+
+// ExchangeRates returns the provider of exchange rates the FX settings
+// describe.
+func (c Config) ExchangeRates() models.ExchangeRateProvider {
+	table := fx.Table(c.FX.Rates)
+	if c.FX.Feed == "" {
+		return table
+	}
+	refresh, maxAge := c.FX.Refresh, c.FX.MaxAge
+	if refresh == 0 {
+		refresh = time.Hour
+	}
+	if maxAge == 0 {
+		maxAge = 96 * time.Hour
+	}
+	return fx.Chain(fx.MaxAge(fx.NewCache(fx.NewFeed(c.FX.Feed), refresh), maxAge), table)
+}
+
+// HistoricalRates returns the provider of past exchange rates the FX
+// settings describe.
+func (c Config) HistoricalRates() models.HistoricalRates {
+	table := fx.Table(c.FX.Rates)
+	if c.FX.History == "" {
+		return table
+	}
+	return fx.ChainHistory(fx.NewHistory(fx.NewFeed(c.FX.History), 24*time.Hour), table)
+}
+
+// CompilePolicy compiles the policy rules.
+func (c Config) CompilePolicy() (*policy.Policy, error) {
+	rules := make([]policy.Rule, len(c.Policy))
+	for i, r := range c.Policy {
+		rules[i] = policy.Rule{Name: r.Name, When: r.When, Message: r.Message}
+	}
+	return policy.Compile(rules)
+}
+
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
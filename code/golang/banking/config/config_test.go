@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// TestLoadPrecedence checks that a file overrides the defaults and the
+// environment overrides both, for YAML and TOML files.
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		env                 map[string]string
+		got                 func(Config) any
+		want                any
+	}{
+		{name: "default", got: func(c Config) any { return c.Server.Addr }, want: ":8080"},
+		{name: "yaml", file: "bank.yaml", content: "server:\n  addr: \":9000\"\n",
+			got: func(c Config) any { return c.Server.Addr }, want: ":9000"},
+		{name: "toml", file: "bank.toml", content: "[server]\naddr = \":9000\"\n",
+			got: func(c Config) any { return c.Server.Addr }, want: ":9000"},
+		{name: "env over yaml", file: "bank.yml", content: "server:\n  addr: \":9000\"\n", env: map[string]string{"BANK_SERVER_ADDR": ":9100"},
+			got: func(c Config) any { return c.Server.Addr }, want: ":9100"},
+		{name: "env over default", env: map[string]string{"BANK_STORE_DRIVER": "json"},
+			got: func(c Config) any { return c.Store.Driver }, want: "json"},
+		{name: "empty env", file: "bank.yaml", content: "server:\n  token: secret\n", env: map[string]string{"BANK_SERVER_TOKEN": ""},
+			got: func(c Config) any { return c.Server.Token }, want: ""},
+		{name: "empty file", file: "bank.yaml", got: func(c Config) any { return c.Locale }, want: Default().Locale},
+		{name: "rate", file: "bank.toml", content: "[interest]\nsavings_rate = \"2%\"\n", env: map[string]string{"BANK_INTEREST_SAVINGS_RATE": "125bps"},
+			got: func(c Config) any { return c.Interest.SavingsRate }, want: money.Rate(125)},
+		{name: "duration", file: "bank.yaml", content: "sessions:\n  ttl: 1h\n", env: map[string]string{"BANK_SESSIONS_TTL": "90m"},
+			got: func(c Config) any { return c.Sessions.TTL }, want: 90 * time.Minute},
+		{name: "number", env: map[string]string{"BANK_LIMITS_OVERDRAFT": "50.5"},
+			got: func(c Config) any { return c.Limits.Overdraft }, want: 50.5},
+		{name: "whole number", file: "bank.yaml", content: "store:\n  batch_size: 10\n", env: map[string]string{"BANK_STORE_BATCH_SIZE": "20"},
+			got: func(c Config) any { return c.Store.BatchSize }, want: 20},
+		{name: "bool", env: map[string]string{"BANK_CHAOS_ENABLED": "true"},
+			got: func(c Config) any { return c.Chaos.Enabled }, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			var path string
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.got(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLoadErrors checks that unknown settings, unknown formats and
+// environment values of the wrong type are refused with the name of what
+// is wrong.
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		env                 map[string]string
+		want                string
+	}{
+		{name: "unknown yaml setting", file: "bank.yaml", content: "server:\n  adress: \":9000\"\n", want: "adress"},
+		{name: "unknown toml setting", file: "bank.toml", content: "[server]\nadress = \":9000\"\n", want: "unknown setting server.adress"},
+		{name: "format", file: "bank.json", content: "{}", want: `unsupported config format ".json"`},
+		{name: "number", env: map[string]string{"BANK_FEES_TRANSFER": "cheap"}, want: `BANK_FEES_TRANSFER: "cheap" is not a number`},
+		{name: "whole number", env: map[string]string{"BANK_DORMANCY_MONTHS": "1.5"}, want: `BANK_DORMANCY_MONTHS: "1.5" is not a whole number`},
+		{name: "bool", env: map[string]string{"BANK_CHAOS_ENABLED": "yes"}, want: `BANK_CHAOS_ENABLED: "yes" is not true or false`},
+		{name: "duration", env: map[string]string{"BANK_SESSIONS_TTL": "soon"}, want: `BANK_SESSIONS_TTL: "soon" is not a duration`},
+		{name: "rate", env: map[string]string{"BANK_FX_MARGIN": "a lot"}, want: "BANK_FX_MARGIN: invalid rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			path := filepath.Join(t.TempDir(), "bank.yaml")
+			if tt.file != "" {
+				path = filepath.Join(filepath.Dir(path), tt.file)
+			}
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidate checks that the defaults and the example file are valid and
+// that each invalid setting is reported under its name.
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("defaults: %v", err)
+	}
+	example, err := Load("example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := example.Validate(); err != nil {
+		t.Errorf("example.yaml: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string
+	}{
+		{"locale", func(c *Config) { c.Locale = "xx" }, `locale: unsupported locale "xx"`},
+		{"rounding", func(c *Config) { c.Rounding = "sideways" }, "rounding:"},
+		{"ids", func(c *Config) { c.IDs = "random" }, "ids: must be uuidv7 or sequential"},
+		{"sequential ids", func(c *Config) { c.IDs, c.Store.Driver, c.Store.DSN = "sequential", "json", "bank.json" }, "ids: sequential IDs repeat"},
+		{"timezone", func(c *Config) { c.TimeZone = "Mars/Olympus" }, "timezone:"},
+		{"addr", func(c *Config) { c.Server.Addr = "8080" }, `server.addr: "8080" is not a host:port address`},
+		{"same grpc addr", func(c *Config) { c.Server.GRPCAddr = c.Server.Addr }, "server.grpc_addr: must differ"},
+		{"driver", func(c *Config) { c.Store.Driver = "mongo" }, `store.driver: unknown driver "mongo"`},
+		{"dsn", func(c *Config) { c.Store.Driver = "postgres" }, `store.dsn: required for driver "postgres"`},
+		{"pool", func(c *Config) { c.Store.MinConns, c.Store.MaxConns = 5, 2 }, "store.min_conns: must not exceed max_conns"},
+		{"outbox", func(c *Config) { c.Store.OutboxURL = "http://relay" }, "store.outbox_url: needs driver sqlite or postgres"},
+		{"rate burst", func(c *Config) { c.Server.RateLimit = 5 }, "server.rate_burst: must be at least 1"},
+		{"redis", func(c *Config) { c.Shared.Driver = "redis" }, "shared.url: required"},
+		{"webhook", func(c *Config) { c.Inbox.WebhookURL = "ftp://inbox" }, `inbox.webhook_url: "ftp://inbox" is not an http or https URL`},
+		{"quiet hours", func(c *Config) { c.Alerts.QuietStart, c.Alerts.QuietEnd = 22, 24 }, "alerts.quiet_start, alerts.quiet_end: must be hours"},
+		{"escheat", func(c *Config) { c.Dormancy.Months, c.Dormancy.EscheatMonths = 12, 6 }, "dormancy.escheat_months: must not be less"},
+		{"fee", func(c *Config) { c.Fees.Transfer = -1 }, "fees.transfer: must not be negative"},
+		{"savings rate", func(c *Config) { c.Interest.SavingsRate = money.Percent(101) }, "interest.savings_rate: must be between 0 and 100"},
+		{"limits", func(c *Config) { c.Limits.PerTransaction, c.Limits.DailyWithdrawal = 500, 100 }, "limits.per_transaction: must not exceed"},
+		{"currency", func(c *Config) { c.FX.Currency = "euro" }, `fx.currency: "euro" is not a three letter`},
+		{"fx margin", func(c *Config) { c.FX.Margin = money.Percent100 }, "fx.margin: must be at least 0 and below 100"},
+		{"fx rate", func(c *Config) { c.FX.Rates = map[string]float64{"EUR/USD": 0} }, "fx.rates.EUR/USD: must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	cfg := Default()
+	cfg.Locale, cfg.Fees.Overdraft = "xx", -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "locale:") || !strings.Contains(err.Error(), "fees.overdraft:") {
+		t.Errorf("Validate = %v, want both errors", err)
+	}
+}
+
+// TestApplyAllocation checks that allocation orders are set by product and
+// that unknown products and malformed orders are reported under their
+// name.
+func TestApplyAllocation(t *testing.T) {
+	for _, tt := range []struct {
+		allocation map[string][]string
+		want       map[string]models.AllocationOrder
+		err        string
+	}{
+		{
+			allocation: map[string][]string{"loan": {"fees", "interest", "principal"}, "credit_card": {"interest", "principal", "fees"}},
+			want: map[string]models.AllocationOrder{
+				"Loan":       {models.ComponentFees, models.ComponentInterest, models.ComponentPrincipal},
+				"CreditCard": {models.ComponentInterest, models.ComponentPrincipal, models.ComponentFees},
+			},
+		},
+		{allocation: map[string][]string{"mortgage": {"fees", "interest", "principal"}}, want: map[string]models.AllocationOrder{}, err: "allocation.mortgage: unknown product"},
+		{allocation: map[string][]string{"loan": {"fees"}}, want: map[string]models.AllocationOrder{}, err: "allocation.loan: invalid allocation order"},
+	} {
+		c := Default()
+		c.Allocation = tt.allocation
+		orders := make(map[string]models.AllocationOrder)
+		err := c.ApplyAllocation(orders)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%v: ApplyAllocation = %v, want %q", tt.allocation, err, tt.err)
+		}
+		if !reflect.DeepEqual(orders, tt.want) {
+			t.Errorf("%v: orders = %v, want %v", tt.allocation, orders, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package banking holds what is shared by every layer of the bank: the error
+// model that the domain returns and the HTTP, gRPC and CLI layers translate.
+package banking
+
+import "errors"
+
+// Code classifies an error for machines: API clients branch on it and each
+// transport maps it to its own status codes.
+type Code string
+
+const (
+	CodeInvalidArgument   Code = "invalid_argument"
+	CodeNotFound          Code = "not_found"
+	CodeConflict          Code = "conflict"
+	CodeInsufficientFunds Code = "insufficient_funds"
+	CodeLimitExceeded     Code = "limit_exceeded"
+	CodeAccountFrozen     Code = "account_frozen"
+	CodePermissionDenied  Code = "permission_denied"
+	CodeFeatureDisabled   Code = "feature_disabled"
+	CodeUnavailable       Code = "unavailable"
+	CodeRateLimited       Code = "rate_limited"
+	CodeUnauthenticated   Code = "unauthenticated"
+	CodeInternal          Code = "internal"
+)
+
+// Error is an error with a machine-readable code. Retryable tells clients
+// whether the same request may succeed later without changes. Fields lists
+// what is wrong with each field of an invalid input, see package validate.
+type Error struct {
+	Code      Code
+	Message   string
+	Retryable bool
+	Fields    []FieldError
+}
+
+// FieldError is what is wrong with one field of an input.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New returns an Error. Only CodeUnavailable and CodeRateLimited errors are
+// retryable; build an Error directly for other retryable cases, such as a
+// lost write race.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Retryable: code == CodeUnavailable || code == CodeRateLimited}
+}
+
+// FieldsOf returns the field errors of the first Error in err's chain.
+func FieldsOf(err error) []FieldError {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Fields
+	}
+	return nil
+}
+
+// CodeOf returns the code of the first Error in err's chain, CodeInternal if
+// there is none, or "" for a nil error.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// IsRetryable reports whether err is an Error marked retryable.
+func IsRetryable(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Retryable
+}
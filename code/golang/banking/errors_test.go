@@ -0,0 +1,39 @@
+package banking
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// TestCodeOf checks the code, retryability and field errors found in
+// chains of wrapped errors, and what errors without an Error in their
+// chain give.
+func TestCodeOf(t *testing.T) {
+	fields := []FieldError{{Field: "amount", Message: "must be positive"}}
+	for _, tt := range []struct {
+		err       error
+		code      Code
+		retryable bool
+		fields    []FieldError
+	}{
+		{nil, "", false, nil},
+		{errors.New("disk full"), CodeInternal, false, nil},
+		{New(CodeNotFound, "account not found"), CodeNotFound, false, nil},
+		{fmt.Errorf("account C1: %w", New(CodeUnavailable, "store down")), CodeUnavailable, true, nil},
+		{fmt.Errorf("retry: %w", New(CodeRateLimited, "slow down")), CodeRateLimited, true, nil},
+		{&Error{Code: CodeConflict, Message: "lost a race", Retryable: true}, CodeConflict, true, nil},
+		{errors.Join(errors.New("first"), &Error{Code: CodeInvalidArgument, Message: "bad input", Fields: fields}), CodeInvalidArgument, false, fields},
+	} {
+		if got := CodeOf(tt.err); got != tt.code {
+			t.Errorf("CodeOf(%v) = %q, want %q", tt.err, got, tt.code)
+		}
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.retryable)
+		}
+		if got := FieldsOf(tt.err); !slices.Equal(got, tt.fields) {
+			t.Errorf("FieldsOf(%v) = %v, want %v", tt.err, got, tt.fields)
+		}
+	}
+}
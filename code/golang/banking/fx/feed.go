@@ -0,0 +1,114 @@
+package fx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// The feeds of the European Central Bank's euro reference rates: the latest
+// day's, and every day's since 1999.
+const (
+	ECBDaily   = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	ECBHistory = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+// Feed is a provider of the rates an ECB-style XML feed publishes at URL: for
+// each day, the price of one unit of Base in other currencies. Rates between
+// two of those currencies are crossed through Base. A Feed fetches URL every
+// time it is asked, so it is normally wrapped in a Cache.
+type Feed struct {
+	URL    string
+	Base   string
+	Client *http.Client
+}
+
+// NewFeed returns a Feed of euro rates at url, such as ECBDaily.
+func NewFeed(url string) *Feed {
+	return &Feed{URL: url, Base: "EUR", Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Day is the rates a feed published for a day, in units per unit of its
+// base.
+type Day struct {
+	Date  time.Time
+	Rates map[string]float64
+}
+
+// Rate returns the rate of base in quote on the day, crossed through the
+// feed's base, feedBase.
+func (d Day) Rate(feedBase, base, quote string) (models.ExchangeRate, error) {
+	per := func(c string) (float64, bool) {
+		if c == feedBase {
+			return 1, true
+		}
+		r, ok := d.Rates[c]
+		return r, ok && r > 0
+	}
+	b, okBase := per(base)
+	q, okQuote := per(quote)
+	if !okBase || !okQuote {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s on %s", models.ErrNoExchangeRate, base, quote, d.Date.Format(time.DateOnly))
+	}
+	return models.ExchangeRate{Base: base, Quote: quote, Rate: q / b, AsOf: d.Date}, nil
+}
+
+// envelope is the document of a feed: a Cube of Cubes with a time, each of
+// Cubes with a currency and rate.
+type envelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// Fetch returns the days the feed publishes, latest first.
+func (f *Feed) Fetch() ([]Day, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(f.URL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching rates: %s answered %s", f.URL, resp.Status)
+	}
+	var doc envelope
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("reading rates of %s: %w", f.URL, err)
+	}
+	days := make([]Day, 0, len(doc.Days))
+	for _, d := range doc.Days {
+		date, err := time.Parse(time.DateOnly, d.Time)
+		if err != nil {
+			return nil, fmt.Errorf("reading rates of %s: %w", f.URL, err)
+		}
+		day := Day{Date: date, Rates: make(map[string]float64, len(d.Rates))}
+		for _, r := range d.Rates {
+			day.Rates[r.Currency] = r.Rate
+		}
+		days = append(days, day)
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("reading rates of %s: no rates published", f.URL)
+	}
+	return days, nil
+}
+
+// ExchangeRate returns the latest rate the feed publishes for a pair.
+func (f *Feed) ExchangeRate(base, quote string) (models.ExchangeRate, error) {
+	days, err := f.Fetch()
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	return days[0].Rate(f.Base, base, quote)
+}
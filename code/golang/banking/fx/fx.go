@@ -0,0 +1,136 @@
+// Package fx provides the exchange rates of transfers between currencies: a
+// fixed Table, the reference rates an ECB-style Feed publishes, and the
+// Cache, MaxAge and Chain wrappers that combine them, such as
+//
+//	fx.Chain(fx.MaxAge(fx.NewCache(fx.NewFeed(fx.ECBDaily), time.Hour), 96*time.Hour), table)
+//
+// which asks the feed at most once an hour per pair and falls back to the
+// table when the feed's rates are missing or more than four days old.
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var ErrStale = banking.New(banking.CodeUnavailable, "exchange rate is stale")
+
+// Table is a fixed table of rates keyed by pair, such as "EUR/USD" for the
+// price of a euro in dollars, for demos and rates set by hand. It also
+// answers for the inverse of each pair.
+type Table map[string]float64
+
+func (t Table) ExchangeRate(base, quote string) (models.ExchangeRate, error) {
+	if rate, ok := t[base+"/"+quote]; ok {
+		return models.ExchangeRate{Base: base, Quote: quote, Rate: rate}, nil
+	}
+	if rate, ok := t[quote+"/"+base]; ok && rate != 0 {
+		return models.ExchangeRate{Base: quote, Quote: base, Rate: rate}.Inverse(), nil
+	}
+	return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s", models.ErrNoExchangeRate, base, quote)
+}
+
+// ParsePair splits a pair of a Table, such as "EUR/USD".
+func ParsePair(pair string) (base, quote string, err error) {
+	base, quote, ok := strings.Cut(pair, "/")
+	if !ok || !models.ValidCurrency(base) || !models.ValidCurrency(quote) || base == quote {
+		return "", "", fmt.Errorf("%q is not a currency pair such as EUR/USD", pair)
+	}
+	return base, quote, nil
+}
+
+// Cache remembers the rates of a provider for ttl per pair. A rate that
+// cannot be refreshed is served as it was, so that MaxAge, not the
+// provider's outage, decides when it is too old to use.
+type Cache struct {
+	provider models.ExchangeRateProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	rates map[string]cached
+}
+
+type cached struct {
+	rate    models.ExchangeRate
+	fetched time.Time
+}
+
+// NewCache returns a Cache of provider's rates.
+func NewCache(provider models.ExchangeRateProvider, ttl time.Duration) *Cache {
+	return &Cache{provider: provider, ttl: ttl, now: time.Now, rates: make(map[string]cached)}
+}
+
+func (c *Cache) ExchangeRate(base, quote string) (models.ExchangeRate, error) {
+	pair := base + "/" + quote
+	c.mu.Lock()
+	entry, ok := c.rates[pair]
+	c.mu.Unlock()
+	if ok && c.now().Sub(entry.fetched) < c.ttl {
+		return entry.rate, nil
+	}
+	rate, err := c.provider.ExchangeRate(base, quote)
+	if err != nil {
+		if ok {
+			return entry.rate, nil
+		}
+		return models.ExchangeRate{}, err
+	}
+	c.mu.Lock()
+	c.rates[pair] = cached{rate, c.now()}
+	c.mu.Unlock()
+	return rate, nil
+}
+
+// MaxAge returns a provider that refuses provider's rates published more
+// than age ago with ErrStale. Rates of no date, such as a Table's, are never
+// stale.
+func MaxAge(provider models.ExchangeRateProvider, age time.Duration) models.ExchangeRateProvider {
+	return maxAge{provider, age, time.Now}
+}
+
+type maxAge struct {
+	provider models.ExchangeRateProvider
+	age      time.Duration
+	now      func() time.Time
+}
+
+func (m maxAge) ExchangeRate(base, quote string) (models.ExchangeRate, error) {
+	rate, err := m.provider.ExchangeRate(base, quote)
+	if err != nil {
+		return rate, err
+	}
+	if !rate.AsOf.IsZero() && m.now().Sub(rate.AsOf) > m.age {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s of %s", ErrStale, base, quote, rate.AsOf.Format(time.DateOnly))
+	}
+	return rate, nil
+}
+
+// Chain returns a provider that asks providers in order and returns the
+// first rate one of them has, or all of their errors.
+func Chain(providers ...models.ExchangeRateProvider) models.ExchangeRateProvider {
+	return chain(providers)
+}
+
+type chain []models.ExchangeRateProvider
+
+func (c chain) ExchangeRate(base, quote string) (models.ExchangeRate, error) {
+	if len(c) == 0 {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s", models.ErrNoExchangeRate, base, quote)
+	}
+	var errs []error
+	for _, p := range c {
+		rate, err := p.ExchangeRate(base, quote)
+		if err == nil {
+			return rate, nil
+		}
+		errs = append(errs, err)
+	}
+	return models.ExchangeRate{}, errors.Join(errs...)
+}
@@ -0,0 +1,248 @@
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+const feedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2026-03-13">
+			<Cube currency="USD" rate="1.10"/>
+			<Cube currency="GBP" rate="0.88"/>
+		</Cube>
+		<Cube time="2026-03-12">
+			<Cube currency="USD" rate="1.00"/>
+			<Cube currency="GBP" rate="0.80"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+// feedServer serves body with status and counts the requests it gets.
+func feedServer(t *testing.T, status int, body string) (*Feed, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewFeed(srv.URL), &calls
+}
+
+// fakeClock is a time tests move by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestFeed checks the latest day's rates, crossed through the euro, and the
+// errors of a feed that is down, malformed or lacks a currency.
+func TestFeed(t *testing.T) {
+	feed, _ := feedServer(t, http.StatusOK, feedXML)
+	tests := []struct {
+		base, quote string
+		want        float64
+	}{
+		{"EUR", "USD", 1.10},
+		{"USD", "EUR", 1 / 1.10},
+		{"USD", "GBP", 0.88 / 1.10},
+		{"EUR", "EUR", 1},
+	}
+	for _, tt := range tests {
+		rate, err := feed.ExchangeRate(tt.base, tt.quote)
+		if err != nil {
+			t.Fatalf("%s/%s: %v", tt.base, tt.quote, err)
+		}
+		if !near(rate.Rate, tt.want) || !rate.AsOf.Equal(date(t, "2026-03-13")) {
+			t.Errorf("%s/%s = %v as of %s, want %v as of 2026-03-13", tt.base, tt.quote, rate.Rate, rate.AsOf, tt.want)
+		}
+	}
+	if _, err := feed.ExchangeRate("EUR", "JPY"); !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("EUR/JPY = %v, want ErrNoExchangeRate", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"down", http.StatusServiceUnavailable, ""},
+		{"malformed", http.StatusOK, "<Cube><Cube time="},
+		{"empty", http.StatusOK, "<Envelope/>"},
+		{"bad date", http.StatusOK, `<Envelope><Cube><Cube time="13/03/2026"/></Cube></Envelope>`},
+	} {
+		f, _ := feedServer(t, tt.status, tt.body)
+		if _, err := f.ExchangeRate("EUR", "USD"); err == nil {
+			t.Errorf("%s feed: no error", tt.name)
+		}
+	}
+}
+
+// TestCache checks that a rate is fetched once per ttl and that the last
+// rate is served while the provider is down.
+func TestCache(t *testing.T) {
+	feed, calls := feedServer(t, http.StatusOK, feedXML)
+	clock := &fakeClock{now: date(t, "2026-03-13").Add(18 * time.Hour)}
+	cache := NewCache(feed, time.Hour)
+	cache.now = clock.Now
+
+	steps := []struct {
+		after time.Duration
+		calls int32
+	}{
+		{0, 1},
+		{30 * time.Minute, 1},
+		{31 * time.Minute, 2},
+	}
+	for _, step := range steps {
+		clock.now = clock.now.Add(step.after)
+		if _, err := cache.ExchangeRate("EUR", "USD"); err != nil {
+			t.Fatal(err)
+		}
+		if got := calls.Load(); got != step.calls {
+			t.Errorf("after %s: %d fetches, want %d", step.after, got, step.calls)
+		}
+	}
+
+	feed.Client = &http.Client{Transport: failing{}}
+	clock.now = clock.now.Add(2 * time.Hour)
+	rate, err := cache.ExchangeRate("EUR", "USD")
+	if err != nil || !near(rate.Rate, 1.10) {
+		t.Errorf("rate while the feed is down = %v, %v, want the cached 1.10", rate.Rate, err)
+	}
+	if _, err := cache.ExchangeRate("EUR", "GBP"); err == nil {
+		t.Error("a pair never fetched was served while the feed is down")
+	}
+}
+
+// failing is a transport whose every request fails.
+type failing struct{}
+
+func (failing) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+// TestMaxAge checks that rates older than the age are refused as stale and
+// that undated rates never are.
+func TestMaxAge(t *testing.T) {
+	feed, _ := feedServer(t, http.StatusOK, feedXML)
+	tests := []struct {
+		now   string
+		stale bool
+	}{
+		{"2026-03-14", false},
+		{"2026-03-17", false},
+		{"2026-03-18", true},
+	}
+	for _, tt := range tests {
+		m := maxAge{feed, 96 * time.Hour, (&fakeClock{now: date(t, tt.now)}).Now}
+		_, err := m.ExchangeRate("EUR", "USD")
+		if stale := errors.Is(err, ErrStale); stale != tt.stale {
+			t.Errorf("on %s: err = %v, want stale %v", tt.now, err, tt.stale)
+		}
+		if tt.stale && banking.CodeOf(err) != banking.CodeUnavailable {
+			t.Errorf("on %s: code = %v, want unavailable", tt.now, banking.CodeOf(err))
+		}
+	}
+
+	m := maxAge{Table{"EUR/USD": 1.2}, time.Hour, (&fakeClock{now: date(t, "2099-01-01")}).Now}
+	if _, err := m.ExchangeRate("EUR", "USD"); err != nil {
+		t.Errorf("table rate refused: %v", err)
+	}
+}
+
+// TestChain checks the fallback from a stale or missing rate to the next
+// provider, and that all errors are returned when none has the rate.
+func TestChain(t *testing.T) {
+	feed, _ := feedServer(t, http.StatusOK, feedXML)
+	stale := maxAge{feed, 96 * time.Hour, (&fakeClock{now: date(t, "2026-04-01")}).Now}
+	fresh := maxAge{feed, 96 * time.Hour, (&fakeClock{now: date(t, "2026-03-14")}).Now}
+	table := Table{"EUR/USD": 1.25}
+	tests := []struct {
+		name      string
+		providers []models.ExchangeRateProvider
+		want      float64
+	}{
+		{"fresh feed first", []models.ExchangeRateProvider{fresh, table}, 1.10},
+		{"stale feed falls back", []models.ExchangeRateProvider{stale, table}, 1.25},
+		{"inverse from table", []models.ExchangeRateProvider{Table{"USD/EUR": 0.8}}, 1.25},
+	}
+	for _, tt := range tests {
+		rate, err := Chain(tt.providers...).ExchangeRate("EUR", "USD")
+		if err != nil || !near(rate.Rate, tt.want) {
+			t.Errorf("%s: %v, %v, want %v", tt.name, rate.Rate, err, tt.want)
+		}
+	}
+
+	_, err := Chain(stale, Table{}).ExchangeRate("EUR", "USD")
+	if !errors.Is(err, ErrStale) || !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("no provider has the rate: %v, want both errors", err)
+	}
+	if _, err := Chain().ExchangeRate("EUR", "USD"); !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("empty chain: %v", err)
+	}
+}
+
+// TestHistory checks rates by day, carried over days without rates, and
+// that the feed is fetched again only once refresh has passed.
+func TestHistory(t *testing.T) {
+	feed, calls := feedServer(t, http.StatusOK, feedXML)
+	clock := &fakeClock{now: date(t, "2026-03-14")}
+	h := NewHistory(feed, 24*time.Hour)
+	h.now = clock.Now
+
+	tests := []struct {
+		day  string
+		want float64
+	}{
+		{"2026-03-12", 1.00},
+		{"2026-03-13", 1.10},
+		{"2026-03-15", 1.10},
+	}
+	for _, tt := range tests {
+		rate, err := h.ExchangeRateOn("EUR", "USD", date(t, tt.day).Add(15*time.Hour))
+		if err != nil || !near(rate.Rate, tt.want) {
+			t.Errorf("on %s: %v, %v, want %v", tt.day, rate.Rate, err, tt.want)
+		}
+	}
+	if _, err := h.ExchangeRateOn("EUR", "USD", date(t, "2026-03-11")); !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("before the first day: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d fetches, want 1", got)
+	}
+	clock.now = clock.now.Add(25 * time.Hour)
+	h.ExchangeRateOn("EUR", "USD", date(t, "2026-03-13"))
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d fetches after refresh, want 2", got)
+	}
+}
@@ -0,0 +1,91 @@
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// ExchangeRateOn returns the rate of the table, the same on every day.
+func (t Table) ExchangeRateOn(base, quote string, _ time.Time) (models.ExchangeRate, error) {
+	return t.ExchangeRate(base, quote)
+}
+
+// History supplies past rates from the days a feed publishes, such as
+// ECBHistory, fetched again once refresh has passed. A day without rates,
+// such as a weekend, has those of the latest day before it.
+type History struct {
+	feed    *Feed
+	refresh time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	days    []Day // oldest first
+	fetched time.Time
+}
+
+// NewHistory returns a History of feed's days.
+func NewHistory(feed *Feed, refresh time.Duration) *History {
+	return &History{feed: feed, refresh: refresh, now: time.Now}
+}
+
+func (h *History) ExchangeRateOn(base, quote string, day time.Time) (models.ExchangeRate, error) {
+	days, err := h.load()
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	// The first day after day, in the feed's dates, which are midnight UTC.
+	y, m, d := day.Date()
+	after := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	i := sort.Search(len(days), func(i int) bool { return !days[i].Date.Before(after) })
+	if i == 0 {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s on %s, before the feed's first day", models.ErrNoExchangeRate, base, quote, day.Format(time.DateOnly))
+	}
+	return days[i-1].Rate(h.feed.Base, base, quote)
+}
+
+// load returns the feed's days, fetching them when they are older than
+// refresh. A feed that cannot be fetched leaves the days it had.
+func (h *History) load() ([]Day, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.days != nil && h.now().Sub(h.fetched) < h.refresh {
+		return h.days, nil
+	}
+	days, err := h.feed.Fetch()
+	if err != nil {
+		if h.days != nil {
+			return h.days, nil
+		}
+		return nil, err
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	h.days, h.fetched = days, h.now()
+	return days, nil
+}
+
+// ChainHistory is Chain for historical rates.
+func ChainHistory(providers ...models.HistoricalRates) models.HistoricalRates {
+	return historyChain(providers)
+}
+
+type historyChain []models.HistoricalRates
+
+func (c historyChain) ExchangeRateOn(base, quote string, day time.Time) (models.ExchangeRate, error) {
+	if len(c) == 0 {
+		return models.ExchangeRate{}, fmt.Errorf("%w: %s/%s", models.ErrNoExchangeRate, base, quote)
+	}
+	var errs []error
+	for _, p := range c {
+		rate, err := p.ExchangeRateOn(base, quote, day)
+		if err == nil {
+			return rate, nil
+		}
+		errs = append(errs, err)
+	}
+	return models.ExchangeRate{}, errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+// Package golden compares what statement and report renderers write with
+// files checked in under testdata, so a change of format shows up as a diff
+// in review instead of breaking whoever reads the output. Run the tests
+// with -update to rewrite the files after a deliberate change:
+//
+//	go test ./reconcile -update
+//
+// It is meant to be imported by tests only: it registers the -update flag.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the output of the tests")
+
+// Path is where the golden file called name is kept: testdata/name, in the
+// directory of the package under test.
+func Path(name string) string {
+	return filepath.Join("testdata", name)
+}
+
+// Assert fails t unless got is the content of the golden file called name,
+// for example "savings.txt"; with -update it writes got to the file instead.
+func Assert(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := Path(name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (-want +got); run go test -update if the change is intended:\n%s", path, Diff(string(want), string(got)))
+	}
+}
+
+// AssertJSON is Assert for the indented JSON encoding of v, as the API
+// writes it.
+func AssertJSON(t testing.TB, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, name, append(got, '\n'))
+}
+
+// Diff returns the lines of want and got that differ, prefixed with - and +
+// and with the lines they share around them prefixed with a space.
+func Diff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, "  %s\n", a[i])
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,12 @@
+package golden
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	want := "matched 2\nledger 10.00\nstatement 10.00\n"
+	got := "matched 2\nledger 10.00\nstatement 12.00\nbreaks 1\n"
+	diff := Diff(want, got)
+	if expected := "  matched 2\n  ledger 10.00\n- statement 10.00\n+ statement 12.00\n+ breaks 1\n  \n"; diff != expected {
+		t.Errorf("Diff =\n%s\nwant\n%s", diff, expected)
+	}
+}
@@ -0,0 +1,318 @@
+// Package graphql is a small GraphQL executor covering what the bank's API
+// needs: queries and mutations with arguments, variables, aliases, fragments
+// and the @include/@skip directives. Schemas are plain Go values holding
+// resolver functions; there is no type checking beyond unknown fields and
+// missing required variables, and no introspection. Documents with fragment
+// cycles or nested deeper than MaxDepth are rejected before they run.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Object is a GraphQL object type.
+type Object struct {
+	Name   string
+	Fields Fields
+}
+
+type Fields map[string]*Field
+
+// Field describes how to resolve one field of an object. Type is the object
+// type of the result, nil for scalars. Resolve receives the value of the
+// parent object and the field's arguments; lists of objects are returned as
+// slices.
+type Field struct {
+	Type    *Object
+	Resolve func(source any, args Args) (any, error)
+}
+
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+}
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type Result struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Execute parses and runs req against the schema. Resolver errors are
+// reported in Result.Errors with the path of the failing field, whose value
+// becomes null.
+func (s *Schema) Execute(req Request) Result {
+	doc, err := parse(req.Query)
+	if err == nil {
+		err = doc.validate()
+	}
+	if err != nil {
+		return Result{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return Result{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	root := s.Query
+	if op.kind == "mutation" {
+		root = s.Mutation
+	}
+	if root == nil {
+		return Result{Errors: []Error{{Message: fmt.Sprintf("schema does not support %ss", op.kind)}}}
+	}
+
+	e := &executor{doc: doc, vars: make(map[string]any)}
+	for _, def := range op.variables {
+		if v, ok := req.Variables[def.name]; ok {
+			e.vars[def.name] = v
+		} else if def.defaultVal != nil {
+			e.vars[def.name] = e.resolve(def.defaultVal)
+		} else if def.nonNull {
+			return Result{Errors: []Error{{Message: fmt.Sprintf("variable $%s is required", def.name)}}}
+		}
+	}
+
+	data := e.object(root, nil, op.selections, nil)
+	return Result{Data: data, Errors: e.errors}
+}
+
+// IsMutation reports whether req runs a mutation. A request that does not
+// parse is not one; Execute reports its error.
+func (req Request) IsMutation() bool {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return false
+	}
+	op, err := doc.operation(req.OperationName)
+	return err == nil && op.kind == "mutation"
+}
+
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) != 1 {
+			return nil, fmt.Errorf("operationName is required when a document has %d operations", len(d.operations))
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type executor struct {
+	doc    *document
+	vars   map[string]any
+	errors []Error
+}
+
+func (e *executor) fail(path []any, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: append([]any(nil), path...)})
+}
+
+// object resolves selections against source, an instance of typ.
+func (e *executor) object(typ *Object, source any, selections []selection, path []any) *orderedMap {
+	result := &orderedMap{}
+	for _, f := range e.collect(typ, selections) {
+		key := f.name
+		if f.alias != "" {
+			key = f.alias
+		}
+		fieldPath := append(path, key)
+
+		if f.name == "__typename" {
+			result.set(key, typ.Name)
+			continue
+		}
+		def, ok := typ.Fields[f.name]
+		if !ok {
+			e.fail(fieldPath, fmt.Errorf("type %s has no field %q", typ.Name, f.name))
+			result.set(key, nil)
+			continue
+		}
+
+		value, err := def.Resolve(source, e.args(f.args))
+		if err != nil {
+			e.fail(fieldPath, err)
+			result.set(key, nil)
+			continue
+		}
+		result.set(key, e.complete(def, f, value, fieldPath))
+	}
+	return result
+}
+
+// complete applies a field's sub-selection to its resolved value.
+func (e *executor) complete(def *Field, f *field, value any, path []any) any {
+	if def.Type == nil {
+		return value
+	}
+	if len(f.selections) == 0 {
+		e.fail(path, fmt.Errorf("field %q of type %s must have a selection", f.name, def.Type.Name))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if v.Kind() == reflect.Slice {
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = e.object(def.Type, v.Index(i).Interface(), f.selections, append(path, i))
+		}
+		return list
+	}
+	return e.object(def.Type, value, f.selections, path)
+}
+
+// collect flattens fragments and drops fields excluded by directives.
+func (e *executor) collect(typ *Object, selections []selection) []*field {
+	var fields []*field
+	for _, sel := range selections {
+		switch s := sel.(type) {
+		case *field:
+			if e.included(s.directives) {
+				fields = append(fields, s)
+			}
+		case *fragmentSpread:
+			f, ok := e.doc.fragments[s.name]
+			if ok && e.included(s.directives) && f.typeName == typ.Name {
+				fields = append(fields, e.collect(typ, f.selections)...)
+			}
+		case *inlineFragment:
+			if e.included(s.directives) && (s.typeName == "" || s.typeName == typ.Name) {
+				fields = append(fields, e.collect(typ, s.selections)...)
+			}
+		}
+	}
+	return fields
+}
+
+func (e *executor) included(directives []directive) bool {
+	for _, d := range directives {
+		cond, _ := e.resolve(d.args["if"]).(bool)
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *executor) args(literal map[string]value) Args {
+	args := make(Args, len(literal))
+	for name, v := range literal {
+		args[name] = e.resolve(v)
+	}
+	return args
+}
+
+// resolve substitutes variables in v and converts it to plain Go values.
+func (e *executor) resolve(v value) any {
+	switch v := v.(type) {
+	case variableRef:
+		return e.vars[string(v)]
+	case enumValue:
+		return string(v)
+	case listValue:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = e.resolve(item)
+		}
+		return list
+	case objectValue:
+		obj := make(map[string]any, len(v))
+		for name, item := range v {
+			obj[name] = e.resolve(item)
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+// Args holds the arguments of a field. Numbers may be int when written in
+// the query or float64 when passed as JSON variables, so use the accessors.
+type Args map[string]any
+
+func (a Args) String(name string) (string, bool) {
+	s, ok := a[name].(string)
+	return s, ok
+}
+
+func (a Args) Float(name string) (float64, bool) {
+	switch n := a[name].(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func (a Args) Int(name string) (int, bool) {
+	switch n := a[name].(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), n == float64(int(n))
+	}
+	return 0, false
+}
+
+func (a Args) Bool(name string) (bool, bool) {
+	b, ok := a[name].(bool)
+	return b, ok
+}
+
+// orderedMap keeps response keys in selection order, as the spec requires.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
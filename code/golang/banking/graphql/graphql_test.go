@@ -0,0 +1,190 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testAccount struct {
+	Number  string
+	Balance float64
+	Owner   *testCustomer
+}
+
+type testCustomer struct {
+	Name     string
+	Accounts []*testAccount
+}
+
+// testSchema is a bank of one customer with two accounts, whose owner field
+// leads back to the customer, so queries may nest as deeply as they like.
+func testSchema() *Schema {
+	ada := &testCustomer{Name: "Ada"}
+	ada.Accounts = []*testAccount{{Number: "1", Balance: 10, Owner: ada}, {Number: "2", Balance: 20.5, Owner: ada}}
+	account := &Object{Name: "Account"}
+	customer := &Object{Name: "Customer"}
+	account.Fields = Fields{
+		"number":  {Resolve: func(src any, _ Args) (any, error) { return src.(*testAccount).Number, nil }},
+		"balance": {Resolve: func(src any, _ Args) (any, error) { return src.(*testAccount).Balance, nil }},
+		"owner":   {Type: customer, Resolve: func(src any, _ Args) (any, error) { return src.(*testAccount).Owner, nil }},
+	}
+	customer.Fields = Fields{
+		"name":     {Resolve: func(src any, _ Args) (any, error) { return src.(*testCustomer).Name, nil }},
+		"accounts": {Type: account, Resolve: func(src any, _ Args) (any, error) { return src.(*testCustomer).Accounts, nil }},
+	}
+	query := &Object{Name: "Query", Fields: Fields{
+		"customer": {Type: customer, Resolve: func(any, Args) (any, error) { return ada, nil }},
+		"account": {Type: account, Resolve: func(_ any, args Args) (any, error) {
+			number, _ := args.String("number")
+			for _, a := range ada.Accounts {
+				if a.Number == number {
+					return a, nil
+				}
+			}
+			return nil, errors.New("account not found")
+		}},
+		"echo": {Resolve: func(_ any, args Args) (any, error) { return args["value"], nil }},
+	}}
+	return &Schema{Query: query}
+}
+
+func run(t *testing.T, req Request) (string, []Error) {
+	t.Helper()
+	result := testSchema().Execute(req)
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), result.Errors
+}
+
+// TestExecute checks aliases, arguments, variables and their defaults,
+// fragments, inline fragments, directives, lists and resolver errors.
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+		errs []string
+	}{
+		{name: "nested lists", req: Request{Query: `{ customer { name accounts { number balance } } }`},
+			want: `{"customer":{"name":"Ada","accounts":[{"number":"1","balance":10},{"number":"2","balance":20.5}]}}`},
+		{name: "aliases and arguments", req: Request{Query: `{ a: account(number: "1") { number } b: account(number: "2") { balance } }`},
+			want: `{"a":{"number":"1"},"b":{"balance":20.5}}`},
+		{name: "variables", req: Request{Query: `query Q($n: String!) { account(number: $n) { number } }`, Variables: map[string]any{"n": "2"}},
+			want: `{"account":{"number":"2"}}`},
+		{name: "default variable", req: Request{Query: `query($n: String = "1") { account(number: $n) { number } }`},
+			want: `{"account":{"number":"1"}}`},
+		{name: "literal values", req: Request{Query: `{ echo(value: {list: [1, 2.5, "aA", true, null, RED]}) }`},
+			want: `{"echo":{"list":[1,2.5,"aA",true,null,"RED"]}}`},
+		{name: "fragments", req: Request{Query: `{ customer { ...C } } fragment C on Customer { name accounts { ...A } } fragment A on Account { number }`},
+			want: `{"customer":{"name":"Ada","accounts":[{"number":"1"},{"number":"2"}]}}`},
+		{name: "inline fragments and typename", req: Request{Query: `{ account(number: "1") { __typename ... on Account { number } ... on Customer { name } } }`},
+			want: `{"account":{"__typename":"Account","number":"1"}}`},
+		{name: "directives", req: Request{Query: `query($yes: Boolean!) { customer { name @skip(if: $yes) accounts @include(if: $yes) { number } } }`, Variables: map[string]any{"yes": true}},
+			want: `{"customer":{"accounts":[{"number":"1"},{"number":"2"}]}}`},
+		{name: "resolver error", req: Request{Query: `{ account(number: "9") { number } customer { name } }`},
+			want: `{"account":null,"customer":{"name":"Ada"}}`, errs: []string{"account not found"}},
+		{name: "unknown field", req: Request{Query: `{ customer { age } }`},
+			want: `{"customer":{"age":null}}`, errs: []string{`type Customer has no field "age"`}},
+		{name: "missing selection", req: Request{Query: `{ customer }`},
+			want: `{"customer":null}`, errs: []string{"must have a selection"}},
+		{name: "missing variable", req: Request{Query: `query($n: String!) { account(number: $n) { number } }`},
+			want: `null`, errs: []string{"variable $n is required"}},
+		{name: "operation name", req: Request{Query: `query A { customer { name } } query B { echo(value: 1) }`, OperationName: "B"},
+			want: `{"echo":1}`},
+		{name: "operation name required", req: Request{Query: `query A { customer { name } } query B { echo(value: 1) }`},
+			want: `null`, errs: []string{"operationName is required"}},
+		{name: "no mutations", req: Request{Query: `mutation { echo(value: 1) }`},
+			want: `null`, errs: []string{"schema does not support mutations"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := run(t, tt.req)
+			if got != tt.want {
+				t.Errorf("data = %s, want %s", got, tt.want)
+			}
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("errors = %v, want %v", errs, tt.errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Message, tt.errs[i]) {
+					t.Errorf("error %d = %q, want it to contain %q", i, err.Message, tt.errs[i])
+				}
+			}
+		})
+	}
+}
+
+// TestResolverErrorPath checks that an error names the path of the field
+// that failed, through aliases and list indexes.
+func TestResolverErrorPath(t *testing.T) {
+	schema := testSchema()
+	schema.Query.Fields["customer"].Type.Fields["name"].Resolve = func(any, Args) (any, error) { return nil, errors.New("boom") }
+	result := schema.Execute(Request{Query: `{ customer { accounts { owner { who: name } } } }`})
+	if len(result.Errors) != 2 {
+		t.Fatalf("errors = %v, want one per account", result.Errors)
+	}
+	path, _ := json.Marshal(result.Errors[1].Path)
+	if string(path) != `["customer","accounts",1,"owner","who"]` {
+		t.Errorf("path = %s", path)
+	}
+}
+
+// TestRejectedDocuments checks that malformed queries, fragment cycles,
+// unknown fragments and queries nested too deep fail with an error, not a
+// crash.
+func TestRejectedDocuments(t *testing.T) {
+	deep := strings.Repeat("{ customer ", 30) + "{ name }" + strings.Repeat(" }", 30)
+	// F0 spreads F1 two levels down, F1 spreads F2 and so on.
+	deepFragments := "{ customer { ...F0 } }"
+	for i := 0; i < 15; i++ {
+		next := "name"
+		if i < 14 {
+			next = "accounts { owner { ...F" + strconv.Itoa(i+1) + " } }"
+		}
+		deepFragments += " fragment F" + strconv.Itoa(i) + " on Customer { " + next + " }"
+	}
+	tests := []struct {
+		name, query, want string
+	}{
+		{"empty", ``, "operationName is required"},
+		{"unclosed", `{ customer { name }`, "unexpected end of query"},
+		{"bad character", "{ customer { name ^ } }", `syntax error on line 1: unexpected character '^'`},
+		{"line numbers", "{\n customer {\n name(:) } }", "syntax error on line 3"},
+		{"unterminated string", `{ echo(value: "abc) }`, "unterminated string"},
+		{"bad unicode", `{ echo(value: "\u12") }`, "invalid unicode escape"},
+		{"bad integer", `{ echo(value: 99999999999999999999) }`, "invalid integer"},
+		{"variable in default", `query($a: Int = $b) { echo(value: $a) }`, "variables are not allowed here"},
+		{"fragment without on", `fragment F Customer { name }`, `expected "on"`},
+		{"self spread", `query { ...F } fragment F on Query { echo(value: 1) ...F }`, `fragment "F" spreads itself`},
+		{"mutual spread", `{ customer { ...A } } fragment A on Customer { accounts { owner { ...B } } } fragment B on Customer { ...A }`, "spreads itself"},
+		{"unused cycle", `{ customer { name } } fragment A on Customer { ...A }`, "spreads itself"},
+		{"unknown fragment", `{ customer { ...Missing } }`, `unknown fragment "Missing"`},
+		{"deep selections", deep, "nested more than 20 levels deep"},
+		{"deep through fragments", deepFragments, "more than 20"},
+		{"deep values", `{ echo(value: ` + strings.Repeat("[", 100) + strings.Repeat("]", 100) + `) }`, "nested more than 20 levels deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := testSchema().Execute(Request{Query: tt.query})
+			if result.Data != nil || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, tt.want) {
+				t.Errorf("result = %+v, want an error containing %q", result, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzExecute checks that no query crashes the parser or the executor.
+func FuzzExecute(f *testing.F) {
+	f.Add(`{ customer { name accounts { number owner { name } } } }`)
+	f.Add(`query($n: String = "1") { a: account(number: $n) { ...A @include(if: true) } } fragment A on Account { number }`)
+	f.Add(`{ ...F } fragment F on Query { ...F }`)
+	f.Add(`{ echo(value: [{a: "A", b: -1.5e3}]) }`)
+	f.Fuzz(func(t *testing.T, query string) {
+		testSchema().Execute(Request{Query: query})
+	})
+}
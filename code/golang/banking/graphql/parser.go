@@ -0,0 +1,484 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // "query" or "mutation"
+	name       string
+	variables  []variableDefinition
+	selections []selection
+}
+
+type variableDefinition struct {
+	name       string
+	nonNull    bool
+	defaultVal value
+}
+
+type fragment struct {
+	name       string
+	typeName   string
+	selections []selection
+}
+
+// selection is a *field, a *fragmentSpread or an *inlineFragment.
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       map[string]value
+	directives []directive
+	selections []selection
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []directive
+}
+
+type inlineFragment struct {
+	typeName   string
+	directives []directive
+	selections []selection
+}
+
+type directive struct {
+	name string
+	args map[string]value
+}
+
+// value is a literal or a variable reference in a query.
+type value interface{}
+
+type variableRef string
+
+type enumValue string
+
+type objectValue map[string]value
+
+type listValue []value
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+	// depth is how deeply the selection sets and values being parsed
+	// nest, kept under MaxDepth.
+	depth int
+}
+
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if perr, ok := r.(syntaxError); ok {
+				err = perr
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p := &parser{src: src}
+	p.next()
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.is(tokenPunct, "{"):
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: p.selectionSet()})
+		case p.is(tokenName, "query"), p.is(tokenName, "mutation"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.is(tokenName, "fragment"):
+			f := p.fragment()
+			doc.fragments[f.name] = f
+		default:
+			p.fail("unexpected %q", p.tok.text)
+		}
+	}
+	return doc, nil
+}
+
+type syntaxError struct {
+	msg string
+}
+
+func (e syntaxError) Error() string { return e.msg }
+
+func (p *parser) fail(format string, args ...any) {
+	line := 1 + strings.Count(p.src[:p.tok.pos], "\n")
+	panic(syntaxError{fmt.Sprintf("syntax error on line %d: %s", line, fmt.Sprintf(format, args...))})
+}
+
+// nest enters a selection set or a list or object value, failing past
+// MaxDepth; the func it returns leaves it.
+func (p *parser) nest() func() {
+	if p.depth++; p.depth > MaxDepth {
+		p.fail("nested more than %d levels deep", MaxDepth)
+	}
+	return func() { p.depth-- }
+}
+
+func (p *parser) is(kind tokenKind, text string) bool {
+	return p.tok.kind == kind && p.tok.text == text
+}
+
+func (p *parser) expect(text string) {
+	if p.tok.kind != tokenPunct || p.tok.text != text {
+		p.fail("expected %q, found %q", text, p.tok.text)
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.fail("expected a name, found %q", p.tok.text)
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.name()}
+	if p.tok.kind == tokenName {
+		op.name = p.name()
+	}
+	if p.is(tokenPunct, "(") {
+		p.next()
+		for !p.is(tokenPunct, ")") {
+			p.expect("$")
+			def := variableDefinition{name: p.name()}
+			p.expect(":")
+			def.nonNull = p.typeRef()
+			if p.is(tokenPunct, "=") {
+				p.next()
+				def.defaultVal = p.value(true)
+			}
+			op.variables = append(op.variables, def)
+		}
+		p.next()
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+// typeRef skips over a type reference and reports whether it is non-null.
+// Values are not type checked beyond required variables being present.
+func (p *parser) typeRef() bool {
+	if p.is(tokenPunct, "[") {
+		p.next()
+		p.typeRef()
+		p.expect("]")
+	} else {
+		p.name()
+	}
+	if p.is(tokenPunct, "!") {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) fragment() *fragment {
+	p.next()
+	f := &fragment{name: p.name()}
+	if !p.is(tokenName, "on") {
+		p.fail("expected \"on\", found %q", p.tok.text)
+	}
+	p.next()
+	f.typeName = p.name()
+	p.directives()
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []selection {
+	defer p.nest()()
+	p.expect("{")
+	var selections []selection
+	for !p.is(tokenPunct, "}") {
+		if p.tok.kind == tokenEOF {
+			p.fail("unexpected end of query")
+		}
+		selections = append(selections, p.selection())
+	}
+	p.next()
+	return selections
+}
+
+func (p *parser) selection() selection {
+	if p.is(tokenPunct, "...") {
+		p.next()
+		if p.tok.kind == tokenName && p.tok.text != "on" {
+			return &fragmentSpread{name: p.name(), directives: p.directives()}
+		}
+		inline := &inlineFragment{}
+		if p.is(tokenName, "on") {
+			p.next()
+			inline.typeName = p.name()
+		}
+		inline.directives = p.directives()
+		inline.selections = p.selectionSet()
+		return inline
+	}
+
+	f := &field{name: p.name()}
+	if p.is(tokenPunct, ":") {
+		p.next()
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments()
+	f.directives = p.directives()
+	if p.is(tokenPunct, "{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments() map[string]value {
+	if !p.is(tokenPunct, "(") {
+		return nil
+	}
+	p.next()
+	args := make(map[string]value)
+	for !p.is(tokenPunct, ")") {
+		name := p.name()
+		p.expect(":")
+		args[name] = p.value(false)
+	}
+	p.next()
+	return args
+}
+
+func (p *parser) directives() []directive {
+	var directives []directive
+	for p.is(tokenPunct, "@") {
+		p.next()
+		directives = append(directives, directive{name: p.name(), args: p.arguments()})
+	}
+	return directives
+}
+
+func (p *parser) value(constant bool) value {
+	tok := p.tok
+	switch tok.kind {
+	case tokenPunct:
+		switch tok.text {
+		case "$":
+			if constant {
+				p.fail("variables are not allowed here")
+			}
+			p.next()
+			return variableRef(p.name())
+		case "[":
+			defer p.nest()()
+			p.next()
+			list := listValue{}
+			for !p.is(tokenPunct, "]") {
+				list = append(list, p.value(constant))
+			}
+			p.next()
+			return list
+		case "{":
+			defer p.nest()()
+			p.next()
+			obj := objectValue{}
+			for !p.is(tokenPunct, "}") {
+				name := p.name()
+				p.expect(":")
+				obj[name] = p.value(constant)
+			}
+			p.next()
+			return obj
+		}
+	case tokenInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			p.fail("invalid integer %q", tok.text)
+		}
+		return int(n)
+	case tokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.fail("invalid number %q", tok.text)
+		}
+		return f
+	case tokenString:
+		p.next()
+		return tok.text
+	case tokenName:
+		p.next()
+		switch tok.text {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(tok.text)
+	}
+	p.fail("unexpected %q", tok.text)
+	return nil
+}
+
+// next advances to the next token, skipping whitespace, commas and comments.
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokenPunct, text: "...", pos: start}
+	case strings.IndexByte("!$()[]{}:=@|", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenPunct, text: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, text: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.number(start)
+	case c == '"':
+		p.string(start)
+	default:
+		p.tok = token{kind: tokenPunct, text: string(c), pos: start}
+		p.fail("unexpected character %q", c)
+	}
+}
+
+func (p *parser) number(start int) {
+	kind := tokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokenFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok = token{kind: kind, text: p.src[start:p.pos], pos: start}
+}
+
+func (p *parser) string(start int) {
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.tok = token{kind: tokenString, pos: start}
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		p.pos++
+		if p.pos >= len(p.src) {
+			continue
+		}
+		switch esc := p.src[p.pos]; esc {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if p.pos+5 > len(p.src) {
+				p.tok = token{kind: tokenString, pos: start}
+				p.fail("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(p.src[p.pos+1:p.pos+5], 16, 32)
+			if err != nil {
+				p.tok = token{kind: tokenString, pos: start}
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			b.WriteByte(esc)
+		}
+		p.pos++
+	}
+	p.tok = token{kind: tokenString, text: b.String(), pos: start}
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
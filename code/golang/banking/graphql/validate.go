@@ -0,0 +1,85 @@
+package graphql
+
+import "fmt"
+
+// MaxDepth is how deeply the fields of a query may nest, fragments
+// included, so that no query can exhaust the stack of the parser or the
+// executor.
+const MaxDepth = 20
+
+// validate rejects documents the executor could not run to completion:
+// spreads of unknown fragments, fragments that spread themselves, directly
+// or through others, and operations nested deeper than MaxDepth.
+func (d *document) validate() error {
+	v := &validator{doc: d, depths: make(map[string]int), visiting: make(map[string]bool)}
+	for _, f := range d.fragments {
+		if _, err := v.fragmentDepth(f.name); err != nil {
+			return err
+		}
+	}
+	for _, op := range d.operations {
+		depth, err := v.depth(op.selections)
+		if err != nil {
+			return err
+		}
+		if depth > MaxDepth {
+			return fmt.Errorf("query is nested %d levels deep, more than %d", depth, MaxDepth)
+		}
+	}
+	return nil
+}
+
+type validator struct {
+	doc *document
+	// depths are the depths of the fragments validated, and visiting
+	// those being validated, whose spreads would make a cycle.
+	depths   map[string]int
+	visiting map[string]bool
+}
+
+// depth is how deeply selections nest, fragments expanded.
+func (v *validator) depth(selections []selection) (int, error) {
+	deepest := 0
+	for _, sel := range selections {
+		var depth int
+		var err error
+		switch s := sel.(type) {
+		case *field:
+			depth, err = v.depth(s.selections)
+			depth++
+		case *fragmentSpread:
+			depth, err = v.fragmentDepth(s.name)
+		case *inlineFragment:
+			depth, err = v.depth(s.selections)
+		}
+		if err != nil {
+			return 0, err
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest, nil
+}
+
+func (v *validator) fragmentDepth(name string) (int, error) {
+	if depth, ok := v.depths[name]; ok {
+		return depth, nil
+	}
+	f, ok := v.doc.fragments[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown fragment %q", name)
+	}
+	if v.visiting[name] {
+		return 0, fmt.Errorf("fragment %q spreads itself", name)
+	}
+	v.visiting[name] = true
+	depth, err := v.depth(f.selections)
+	delete(v.visiting, name)
+	if err != nil {
+		return 0, err
+	}
+	if depth > MaxDepth {
+		return 0, fmt.Errorf("fragment %q is nested %d levels deep, more than %d", name, depth, MaxDepth)
+	}
+	v.depths[name] = depth
+	return depth, nil
+}
@@ -0,0 +1,121 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+	"gsolano/banking/shared"
+)
+
+var (
+	errUnauthenticated = banking.New(banking.CodeUnauthenticated, "missing or wrong bearer token")
+	errRateLimited     = banking.New(banking.CodeRateLimited, "too many requests")
+	errClientKey       = banking.New(banking.CodePermissionDenied, "API keys of third-party clients only read under their consents, over REST")
+)
+
+// Authenticate returns the options that make a server require token, or
+// one of the bank's API keys, as a bearer token in the authorization
+// metadata of each call; without a token, calls without one are let
+// through too. A key's scope must allow the method's permission in
+// methodPermissions. Calls with a key count toward its usage and are held to its rate limit,
+// if it has one, through limiter. Keys of third-party clients are refused,
+// as there are no consented reads over gRPC.
+func Authenticate(bank *models.Bank, token string, limiter shared.Limiter) []grpc.ServerOption {
+	a := &authenticator{bank: bank, token: token, limiter: limiter}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(a.unary), grpc.ChainStreamInterceptor(a.stream)}
+}
+
+// methodPermissions is the permission each method needs of an API key's
+// scope, by its full name. A method missing here needs admin, as a REST
+// route that changes something does.
+var methodPermissions = map[string]models.Permission{
+	"/" + serviceName + "/GetBalance":         models.PermissionView,
+	"/" + serviceName + "/StreamTransactions": models.PermissionView,
+	"/" + serviceName + "/ListAccounts":       models.PermissionView,
+	"/" + serviceName + "/ListTransactions":   models.PermissionView,
+}
+
+type authenticator struct {
+	bank    *models.Bank
+	token   string
+	limiter shared.Limiter
+}
+
+func (a *authenticator) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var resp any
+	err := a.call(ctx, info.FullMethod, func() error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (a *authenticator) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return a.call(ss.Context(), info.FullMethod, func() error { return handler(srv, ss) })
+}
+
+// call authenticates the call of ctx to method and makes it, recording its
+// outcome against its API key if it has one.
+func (a *authenticator) call(ctx context.Context, method string, do func() error) error {
+	var bearer string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			bearer = strings.TrimPrefix(values[0], "Bearer ")
+		}
+	}
+	if !strings.HasPrefix(bearer, models.APIKeyPrefix) {
+		if a.token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(a.token)) != 1 {
+			return statusError(errUnauthenticated)
+		}
+		return do()
+	}
+	key, err := a.bank.APIKeyByToken(bearer)
+	if err != nil {
+		return statusError(err)
+	}
+	if key.Client != "" {
+		a.bank.RecordAPIKeyUse(key.ID, peerIP(ctx), true, false)
+		return statusError(errClientKey)
+	}
+	need, ok := methodPermissions[method]
+	if !ok {
+		need = models.PermissionAdmin
+	}
+	if !key.Scope.Allows(need) {
+		a.bank.RecordAPIKeyUse(key.ID, peerIP(ctx), true, false)
+		return statusError(banking.New(banking.CodePermissionDenied, "API key of "+string(key.Scope)+" scope may not call "+method))
+	}
+	if key.RateLimit > 0 {
+		ok, _, err := a.limiter.Allow(ctx, "key:"+key.ID, shared.Rate{Limit: key.RateLimit, Burst: key.RateBurst})
+		if err == nil && !ok {
+			a.bank.RecordAPIKeyUse(key.ID, peerIP(ctx), true, true)
+			return statusError(errRateLimited)
+		}
+	}
+	err = do()
+	a.bank.RecordAPIKeyUse(key.ID, peerIP(ctx), err != nil && !errors.Is(err, context.Canceled), false)
+	return err
+}
+
+// peerIP is the address a call came from, without its port.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return ip
+}
@@ -0,0 +1,69 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Client calls the Bank service of a bankserver.
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{conn: conn}
+}
+
+// WithBearer authenticates the calls made with ctx by token, the server's
+// or an API key.
+func WithBearer(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func (c *Client) GetBalance(ctx context.Context, account string) (*GetBalanceResponse, error) {
+	resp := new(GetBalanceResponse)
+	err := c.conn.Invoke(ctx, "/"+serviceName+"/GetBalance", &GetBalanceRequest{Account: account}, resp, grpc.ForceCodec(codec{}))
+	return resp, err
+}
+
+func (c *Client) ListAccounts(ctx context.Context, req *ListAccountsRequest) (*ListAccountsResponse, error) {
+	resp := new(ListAccountsResponse)
+	err := c.conn.Invoke(ctx, "/"+serviceName+"/ListAccounts", req, resp, grpc.ForceCodec(codec{}))
+	return resp, err
+}
+
+func (c *Client) ListTransactions(ctx context.Context, req *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	resp := new(ListTransactionsResponse)
+	err := c.conn.Invoke(ctx, "/"+serviceName+"/ListTransactions", req, resp, grpc.ForceCodec(codec{}))
+	return resp, err
+}
+
+// TransactionStream receives the entries of a StreamTransactions call.
+type TransactionStream struct {
+	stream grpc.ClientStream
+}
+
+func (s *TransactionStream) Recv() (*Transaction, error) {
+	tx := new(Transaction)
+	if err := s.stream.RecvMsg(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (c *Client) StreamTransactions(ctx context.Context, req *StreamTransactionsRequest) (*TransactionStream, error) {
+	desc := &serviceDesc.Streams[0]
+	stream, err := c.conn.NewStream(ctx, desc, "/"+serviceName+"/"+desc.StreamName, grpc.ForceCodec(codec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &TransactionStream{stream: stream}, nil
+}
@@ -0,0 +1,170 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"gsolano/banking/models"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// TestMessages checks that every message decodes back to what was encoded,
+// empty or with every field set.
+func TestMessages(t *testing.T) {
+	at := time.Date(2026, 3, 14, 15, 9, 26, 535897932, time.UTC)
+	tests := []struct {
+		name string
+		in   message
+		out  message
+	}{
+		{"GetBalanceRequest", &GetBalanceRequest{Account: "C-1"}, new(GetBalanceRequest)},
+		{"GetBalanceResponse", &GetBalanceResponse{Account: "C-1", Balance: -12.5}, new(GetBalanceResponse)},
+		{"Account", &Account{Number: "C-1", Kind: "checking", Balance: 100, Available: 250.75}, new(Account)},
+		{"ListAccountsRequest", &ListAccountsRequest{PageSize: 20, PageToken: "abc"}, new(ListAccountsRequest)},
+		{"ListAccountsResponse", &ListAccountsResponse{
+			Accounts:      []*Account{{Number: "C-1", Balance: 1}, {}, {Number: "S-2", Kind: "savings"}},
+			NextPageToken: "next",
+		}, new(ListAccountsResponse)},
+		{"ListTransactionsRequest", &ListTransactionsRequest{Account: "C-1", PageSize: 5, PageToken: "p"}, new(ListTransactionsRequest)},
+		{"ListTransactionsResponse", &ListTransactionsResponse{
+			Transactions:  []*Transaction{{Sequence: 1, Type: "deposit", Amount: 10, Time: at}, {}},
+			NextPageToken: "n",
+		}, new(ListTransactionsResponse)},
+		{"StreamTransactionsRequest", &StreamTransactionsRequest{Account: "C-1", Since: at}, new(StreamTransactionsRequest)},
+		{"StreamTransactionsRequest before 1970", &StreamTransactionsRequest{Since: time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC)}, new(StreamTransactionsRequest)},
+		{"Transaction", &Transaction{
+			Sequence: 42, Type: "transfer_out", Amount: 99.99, Counterparty: "S-2",
+			Category: "rent", Time: at, Live: true,
+		}, new(Transaction)},
+		{"empty Transaction", &Transaction{}, new(Transaction)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.out.unmarshal(tt.in.marshal()); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(tt.out, tt.in) {
+				t.Errorf("got %+v, want %+v", tt.out, tt.in)
+			}
+		})
+	}
+}
+
+// TestUnmarshalSkipsUnknownFields checks that fields a newer peer added are
+// skipped rather than refused, and that truncated input is an error.
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	b := (&GetBalanceResponse{Account: "C-1", Balance: 3}).marshal()
+	b = appendString(b, 99, "from the future")
+	got := new(GetBalanceResponse)
+	if err := got.unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.Account != "C-1" || got.Balance != 3 {
+		t.Errorf("got %+v", got)
+	}
+	if err := new(GetBalanceResponse).unmarshal(b[:len(b)-3]); err == nil {
+		t.Error("truncated message decoded without error")
+	}
+}
+
+// dial serves bank on an in-memory listener and returns a client of it.
+func dial(t *testing.T, bank *models.Bank) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(bank)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+// TestStreamTransactions checks that a stream replays the ledger from its
+// start time, then tails new entries marked live, and ends when the client
+// cancels.
+func TestStreamTransactions(t *testing.T) {
+	models.SetOutput(io.Discard)
+	clock := &testClock{now: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	bank := models.NewBank()
+	bank.Clock = clock
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C-1"}})
+	for _, amount := range []float64{10, 20, 30} {
+		if err := bank.Deposit("C-1", amount); err != nil {
+			t.Fatal(err)
+		}
+		clock.now = clock.now.Add(24 * time.Hour)
+	}
+	client := dial(t, bank)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.StreamTransactions(ctx, &StreamTransactionsRequest{Account: "C-1", Since: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func(seq int64, amount float64, live bool) {
+		t.Helper()
+		tx, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Sequence != seq || tx.Amount != amount || tx.Live != live {
+			t.Fatalf("got #%d %.2f live %v, want #%d %.2f live %v", tx.Sequence, tx.Amount, tx.Live, seq, amount, live)
+		}
+	}
+	recv(2, 20, false)
+	recv(3, 30, false)
+
+	if err := bank.Deposit("C-1", 40); err != nil {
+		t.Fatal(err)
+	}
+	recv(4, 40, true)
+
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("Recv after cancel = %v, want Canceled", err)
+	}
+}
+
+// TestGetBalance checks a unary call over the wire and that a bank error
+// keeps its code.
+func TestGetBalance(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C-1"}})
+	bank.Deposit("C-1", 12.5)
+	client := dial(t, bank)
+
+	resp, err := client.GetBalance(context.Background(), "C-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Account != "C-1" || resp.Balance != 12.5 {
+		t.Errorf("GetBalance = %+v", resp)
+	}
+	if _, err := client.GetBalance(context.Background(), "nope"); status.Code(err) != codes.NotFound {
+		t.Errorf("GetBalance of an unknown account = %v, want NotFound", err)
+	}
+}
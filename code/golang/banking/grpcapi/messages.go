@@ -0,0 +1,386 @@
+package grpcapi
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of proto/bank.proto. They encode themselves with protowire so
+// the service speaks standard protobuf without generated code.
+
+type GetBalanceRequest struct {
+	Account string
+}
+
+type GetBalanceResponse struct {
+	Account string
+	Balance float64
+}
+
+type Account struct {
+	Number    string
+	Kind      string
+	Balance   float64
+	Available float64
+}
+
+type ListAccountsRequest struct {
+	PageSize  int32
+	PageToken string
+}
+
+type ListAccountsResponse struct {
+	Accounts      []*Account
+	NextPageToken string
+}
+
+type ListTransactionsRequest struct {
+	Account   string
+	PageSize  int32
+	PageToken string
+}
+
+type ListTransactionsResponse struct {
+	Transactions  []*Transaction
+	NextPageToken string
+}
+
+type StreamTransactionsRequest struct {
+	Account string
+	Since   time.Time
+}
+
+type Transaction struct {
+	Sequence     int64
+	Type         string
+	Amount       float64
+	Counterparty string
+	Category     string
+	Time         time.Time
+	Live         bool
+}
+
+type message interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+func (m *GetBalanceRequest) marshal() []byte {
+	return appendString(nil, 1, m.Account)
+}
+
+func (m *GetBalanceRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == 1 && typ == protowire.BytesType {
+			return consumeString(b, &m.Account)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *GetBalanceResponse) marshal() []byte {
+	b := appendString(nil, 1, m.Account)
+	return appendDouble(b, 2, m.Balance)
+}
+
+func (m *GetBalanceResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &m.Account)
+		case num == 2 && typ == protowire.Fixed64Type:
+			return consumeDouble(b, &m.Balance)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *Account) marshal() []byte {
+	b := appendString(nil, 1, m.Number)
+	b = appendString(b, 2, m.Kind)
+	b = appendDouble(b, 3, m.Balance)
+	return appendDouble(b, 4, m.Available)
+}
+
+func (m *Account) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &m.Number)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &m.Kind)
+		case num == 3 && typ == protowire.Fixed64Type:
+			return consumeDouble(b, &m.Balance)
+		case num == 4 && typ == protowire.Fixed64Type:
+			return consumeDouble(b, &m.Available)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *ListAccountsRequest) marshal() []byte {
+	b := appendInt32(nil, 1, m.PageSize)
+	return appendString(b, 2, m.PageToken)
+}
+
+func (m *ListAccountsRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			return consumeInt32(b, &m.PageSize)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &m.PageToken)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *ListAccountsResponse) marshal() []byte {
+	var b []byte
+	for _, a := range m.Accounts {
+		b = appendMessage(b, 1, a)
+	}
+	return appendString(b, 2, m.NextPageToken)
+}
+
+func (m *ListAccountsResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			a := new(Account)
+			m.Accounts = append(m.Accounts, a)
+			return consumeMessage(b, a)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &m.NextPageToken)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *ListTransactionsRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Account)
+	b = appendInt32(b, 2, m.PageSize)
+	return appendString(b, 3, m.PageToken)
+}
+
+func (m *ListTransactionsRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &m.Account)
+		case num == 2 && typ == protowire.VarintType:
+			return consumeInt32(b, &m.PageSize)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &m.PageToken)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *ListTransactionsResponse) marshal() []byte {
+	var b []byte
+	for _, tx := range m.Transactions {
+		b = appendMessage(b, 1, tx)
+	}
+	return appendString(b, 2, m.NextPageToken)
+}
+
+func (m *ListTransactionsResponse) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			tx := new(Transaction)
+			m.Transactions = append(m.Transactions, tx)
+			return consumeMessage(b, tx)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &m.NextPageToken)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *StreamTransactionsRequest) marshal() []byte {
+	b := appendString(nil, 1, m.Account)
+	return appendTimestamp(b, 2, m.Since)
+}
+
+func (m *StreamTransactionsRequest) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &m.Account)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeTimestamp(b, &m.Since)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+func (m *Transaction) marshal() []byte {
+	var b []byte
+	if m.Sequence != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.Sequence))
+	}
+	b = appendString(b, 2, m.Type)
+	b = appendDouble(b, 3, m.Amount)
+	b = appendString(b, 4, m.Counterparty)
+	b = appendString(b, 5, m.Category)
+	b = appendTimestamp(b, 6, m.Time)
+	if m.Live {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+func (m *Transaction) unmarshal(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.Sequence = int64(v)
+			return n, protowire.ParseError(n)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &m.Type)
+		case num == 3 && typ == protowire.Fixed64Type:
+			return consumeDouble(b, &m.Amount)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, &m.Counterparty)
+		case num == 5 && typ == protowire.BytesType:
+			return consumeString(b, &m.Category)
+		case num == 6 && typ == protowire.BytesType:
+			return consumeTimestamp(b, &m.Time)
+		case num == 7 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.Live = v != 0
+			return n, protowire.ParseError(n)
+		}
+		return skip(num, typ, b)
+	})
+}
+
+// fields walks the fields of an encoded message. field consumes the value of
+// one field and returns the number of bytes it used.
+func fields(b []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func skip(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	n := protowire.ConsumeFieldValue(num, typ, b)
+	return n, protowire.ParseError(n)
+}
+
+// Proto3 omits fields holding their zero value.
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func consumeString(b []byte, s *string) (int, error) {
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n, protowire.ParseError(n)
+}
+
+func appendInt32(b []byte, num protowire.Number, n int32) []byte {
+	if n == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(n))
+}
+
+func consumeInt32(b []byte, n *int32) (int, error) {
+	v, size := protowire.ConsumeVarint(b)
+	*n = int32(v)
+	return size, protowire.ParseError(size)
+}
+
+// appendMessage encodes an embedded message. Unlike scalars, empty messages
+// are written: they may be elements of a repeated field.
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshal())
+}
+
+func consumeMessage(b []byte, m message) (int, error) {
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	return n, m.unmarshal(v)
+}
+
+func appendDouble(b []byte, num protowire.Number, f float64) []byte {
+	if f == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(f))
+}
+
+func consumeDouble(b []byte, f *float64) (int, error) {
+	v, n := protowire.ConsumeFixed64(b)
+	*f = math.Float64frombits(v)
+	return n, protowire.ParseError(n)
+}
+
+// appendTimestamp encodes t as a google.protobuf.Timestamp.
+func appendTimestamp(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	var ts []byte
+	if s := t.Unix(); s != 0 {
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(s))
+	}
+	if ns := t.Nanosecond(); ns != 0 {
+		ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(ns))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, ts)
+}
+
+func consumeTimestamp(b []byte, t *time.Time) (int, error) {
+	ts, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	var seconds, nanos int64
+	err := fields(ts, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ == protowire.VarintType && (num == 1 || num == 2) {
+			v, n := protowire.ConsumeVarint(b)
+			if num == 1 {
+				seconds = int64(v)
+			} else {
+				nanos = int64(v)
+			}
+			return n, protowire.ParseError(n)
+		}
+		return skip(num, typ, b)
+	})
+	*t = time.Unix(seconds, nanos).UTC()
+	return n, err
+}
@@ -0,0 +1,269 @@
+// Package grpcapi serves the Bank service of proto/bank.proto over gRPC.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+const serviceName = "bank.v1.Bank"
+
+// streamBuffer is how many live entries may queue up for a slow consumer
+// before its stream is ended with ResourceExhausted.
+const streamBuffer = 256
+
+// codec marshals the hand-written messages of this package. It is forced on
+// the server and clients created here so it does not replace the global
+// protobuf codec of other services in the same process.
+type codec struct{}
+
+func (codec) Name() string { return "proto" }
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("grpcapi: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("grpcapi: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+// NewServer returns a gRPC server with the Bank service registered.
+func NewServer(bank *models.Bank, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append(opts, grpc.ForceServerCodec(codec{}))...)
+	s.RegisterService(&serviceDesc, &service{bank: bank})
+	return s
+}
+
+type service struct {
+	bank *models.Bank
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("GetBalance", func(s *service, ctx context.Context, req *GetBalanceRequest) (message, error) {
+			return s.GetBalance(ctx, req)
+		}),
+		unary("ListAccounts", func(s *service, ctx context.Context, req *ListAccountsRequest) (message, error) {
+			return s.ListAccounts(ctx, req)
+		}),
+		unary("ListTransactions", func(s *service, ctx context.Context, req *ListTransactionsRequest) (message, error) {
+			return s.ListTransactions(ctx, req)
+		}),
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "StreamTransactions",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			req := new(StreamTransactionsRequest)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return srv.(*service).StreamTransactions(req, stream)
+		},
+	}},
+	Metadata: "proto/bank.proto",
+}
+
+// unary describes a unary method whose request type is Req.
+func unary[Req any, PReq interface {
+	*Req
+	message
+}](name string, call func(*service, context.Context, PReq) (message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := PReq(new(Req))
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return call(srv.(*service), ctx, req.(PReq))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// resolve returns the number of the account a request names by number or
+// nickname. Unknown references are returned as they are, for the bank to
+// report.
+func (s *service) resolve(ref string) (string, error) {
+	number, err := s.bank.Resolve(ref)
+	if err != nil {
+		if errors.Is(err, models.ErrAmbiguousAlias) {
+			return "", statusError(err)
+		}
+		return ref, nil
+	}
+	return number, nil
+}
+
+func (s *service) GetBalance(ctx context.Context, req *GetBalanceRequest) (*GetBalanceResponse, error) {
+	account, err := s.resolve(req.Account)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.bank.Balance(account)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &GetBalanceResponse{Account: account, Balance: balance}, nil
+}
+
+func (s *service) ListAccounts(ctx context.Context, req *ListAccountsRequest) (*ListAccountsResponse, error) {
+	page, err := s.bank.AccountsPage(models.PageRequest{Cursor: req.PageToken, Size: int(req.PageSize)}, false)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &ListAccountsResponse{NextPageToken: page.NextCursor}
+	for _, account := range page.Items {
+		balance, _ := s.bank.Balance(account.Number())
+		available, _ := s.bank.Available(account.Number())
+		resp.Accounts = append(resp.Accounts, &Account{
+			Number: account.Number(), Kind: models.KindOf(account), Balance: balance, Available: available,
+		})
+	}
+	return resp, nil
+}
+
+func (s *service) ListTransactions(ctx context.Context, req *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	account, err := s.resolve(req.Account)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.bank.HistoryPage(account, models.PageRequest{Cursor: req.PageToken, Size: int(req.PageSize)})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &ListTransactionsResponse{NextPageToken: page.NextCursor}
+	for _, tx := range page.Items {
+		resp.Transactions = append(resp.Transactions, toProto(tx, false))
+	}
+	return resp, nil
+}
+
+// StreamTransactions replays the ledger from req.Since and then tails new
+// entries. It subscribes before reading the ledger and skips live entries
+// already replayed, so nothing is missed or sent twice.
+func (s *service) StreamTransactions(req *StreamTransactionsRequest, stream grpc.ServerStream) error {
+	account, err := s.resolve(req.Account)
+	if err != nil {
+		return err
+	}
+	live := make(chan models.Transaction, streamBuffer)
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+	unsubscribe := s.bank.Events.Subscribe(func(e models.Event) {
+		if e.Type != models.EventTransactionPosted || e.AccountNumber != account || e.Transaction == nil {
+			return
+		}
+		select {
+		case live <- *e.Transaction:
+		default:
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	})
+	defer unsubscribe()
+
+	history, err := s.bank.History(account)
+	if err != nil {
+		return statusError(err)
+	}
+	last := 0
+	for _, tx := range history {
+		last = tx.Sequence
+		if tx.Time.Before(req.Since) {
+			continue
+		}
+		if err := stream.SendMsg(toProto(tx, false)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case tx := <-live:
+			if tx.Sequence <= last {
+				continue
+			}
+			last = tx.Sequence
+			if err := stream.SendMsg(toProto(tx, true)); err != nil {
+				return err
+			}
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "consumer too slow, resume from the last received entry")
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func toProto(tx models.Transaction, live bool) *Transaction {
+	return &Transaction{
+		Sequence:     int64(tx.Sequence),
+		Type:         string(tx.Type),
+		Amount:       tx.Amount,
+		Counterparty: tx.Counterparty,
+		Category:     tx.Category,
+		Time:         tx.Time,
+		Live:         live,
+	}
+}
+
+// grpcCodes maps error codes to gRPC status codes.
+var grpcCodes = map[banking.Code]codes.Code{
+	banking.CodeInvalidArgument:   codes.InvalidArgument,
+	banking.CodeNotFound:          codes.NotFound,
+	banking.CodeConflict:          codes.AlreadyExists,
+	banking.CodeInsufficientFunds: codes.FailedPrecondition,
+	banking.CodeLimitExceeded:     codes.ResourceExhausted,
+	banking.CodeAccountFrozen:     codes.PermissionDenied,
+	banking.CodePermissionDenied:  codes.PermissionDenied,
+	banking.CodeFeatureDisabled:   codes.PermissionDenied,
+	banking.CodeUnavailable:       codes.Unavailable,
+	banking.CodeRateLimited:       codes.ResourceExhausted,
+	banking.CodeUnauthenticated:   codes.Unauthenticated,
+	banking.CodeInternal:          codes.Internal,
+}
+
+func statusError(err error) error {
+	code, ok := grpcCodes[banking.CodeOf(err)]
+	if !ok {
+		code = codes.Internal
+	}
+	st := status.New(code, err.Error())
+	if fields := banking.FieldsOf(err); len(fields) > 0 {
+		details := &errdetails.BadRequest{}
+		for _, f := range fields {
+			details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		if detailed, err := st.WithDetails(details); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
@@ -0,0 +1,57 @@
+package i18n
+
+type MessageID string
+
+const (
+	MsgDepositNotPositive         MessageID = "deposit_not_positive"
+	MsgWithdrawalNotPositive      MessageID = "withdrawal_not_positive"
+	MsgInsufficientFunds          MessageID = "insufficient_funds"
+	MsgInsufficientFundsOverdraft MessageID = "insufficient_funds_overdraft"
+	MsgDeposited                  MessageID = "deposited"
+	MsgWithdrew                   MessageID = "withdrew"
+	MsgAppliedInterest            MessageID = "applied_interest"
+	MsgChargedInterest            MessageID = "charged_interest"
+)
+
+var catalog = map[Locale]map[MessageID]string{
+	English: {
+		MsgDepositNotPositive:         "Deposit amount must be positive.",
+		MsgWithdrawalNotPositive:      "Withdrawal amount must be positive.",
+		MsgInsufficientFunds:          "Insufficient funds.",
+		MsgInsufficientFundsOverdraft: "Insufficient funds, even with overdraft.",
+		MsgDeposited:                  "Deposited: %s",
+		MsgWithdrew:                   "Withdrew: %s",
+		MsgAppliedInterest:            "Applied interest: %s",
+		MsgChargedInterest:            "Charged interest: %s",
+	},
+	Spanish: {
+		MsgDepositNotPositive:         "El monto del depósito debe ser positivo.",
+		MsgWithdrawalNotPositive:      "El monto del retiro debe ser positivo.",
+		MsgInsufficientFunds:          "Fondos insuficientes.",
+		MsgInsufficientFundsOverdraft: "Fondos insuficientes, incluso con sobregiro.",
+		MsgDeposited:                  "Depositado: %s",
+		MsgWithdrew:                   "Retirado: %s",
+		MsgAppliedInterest:            "Interés aplicado: %s",
+		MsgChargedInterest:            "Interés cobrado: %s",
+	},
+	German: {
+		MsgDepositNotPositive:         "Der Einzahlungsbetrag muss positiv sein.",
+		MsgWithdrawalNotPositive:      "Der Abhebungsbetrag muss positiv sein.",
+		MsgInsufficientFunds:          "Unzureichende Deckung.",
+		MsgInsufficientFundsOverdraft: "Unzureichende Deckung, auch mit Dispositionskredit.",
+		MsgDeposited:                  "Eingezahlt: %s",
+		MsgWithdrew:                   "Abgehoben: %s",
+		MsgAppliedInterest:            "Zinsen gutgeschrieben: %s",
+		MsgChargedInterest:            "Zinsen belastet: %s",
+	},
+	French: {
+		MsgDepositNotPositive:         "Le montant du dépôt doit être positif.",
+		MsgWithdrawalNotPositive:      "Le montant du retrait doit être positif.",
+		MsgInsufficientFunds:          "Fonds insuffisants.",
+		MsgInsufficientFundsOverdraft: "Fonds insuffisants, même avec le découvert.",
+		MsgDeposited:                  "Déposé : %s",
+		MsgWithdrew:                   "Retiré : %s",
+		MsgAppliedInterest:            "Intérêts appliqués : %s",
+		MsgChargedInterest:            "Intérêts prélevés : %s",
+	},
+}
@@ -0,0 +1,206 @@
+// Package i18n holds the translations of user-facing messages and formats
+// amounts the way each supported locale writes them.
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gsolano/banking/money"
+)
+
+type Locale string
+
+const (
+	English Locale = "en"
+	Spanish Locale = "es"
+	German  Locale = "de"
+	French  Locale = "fr"
+)
+
+var Default = English
+
+// Locales lists the supported locales.
+func Locales() []Locale {
+	return []Locale{English, Spanish, German, French}
+}
+
+// Parse maps a locale name such as "es", "de-AT" or "fr_FR.UTF-8" to a
+// supported locale.
+func Parse(name string) (Locale, bool) {
+	name = strings.ToLower(name)
+	if i := strings.IndexAny(name, "-_."); i >= 0 {
+		name = name[:i]
+	}
+	for _, l := range Locales() {
+		if string(l) == name {
+			return l, true
+		}
+	}
+	return Default, false
+}
+
+// FromEnv picks the locale from $BANK_LOCALE, then $LANG, falling back to
+// the default.
+func FromEnv() Locale {
+	for _, name := range []string{"BANK_LOCALE", "LC_ALL", "LANG"} {
+		if l, ok := Parse(os.Getenv(name)); ok {
+			return l
+		}
+	}
+	return Default
+}
+
+// T returns the message id in locale, formatted with args. Messages missing
+// from a locale fall back to English.
+func T(locale Locale, id MessageID, args ...any) string {
+	format, ok := catalog[locale][id]
+	if !ok {
+		format = catalog[English][id]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+type numberFormat struct {
+	group   string
+	decimal string
+	// symbolAfter places the currency symbol after the number, separated by
+	// a space, as in "1.234,50 €".
+	symbolAfter bool
+}
+
+var numberFormats = map[Locale]numberFormat{
+	English: {group: ",", decimal: "."},
+	Spanish: {group: ".", decimal: ",", symbolAfter: true},
+	German:  {group: ".", decimal: ",", symbolAfter: true},
+	French:  {group: " ", decimal: ",", symbolAfter: true},
+}
+
+var symbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"MXN": "MX$",
+	"CHF": "CHF",
+}
+
+// FormatAmount writes amount with two decimals and the locale's separators:
+// 1,234.50 in English, 1.234,50 in German.
+func FormatAmount(locale Locale, amount float64) string {
+	return formatNumber(numberFormats[locale], amount, 2)
+}
+
+// FormatMoney is FormatAmount with the currency symbol placed as the locale
+// expects and the currency's number of decimals. Unknown currency codes are
+// written as the code.
+func FormatMoney(locale Locale, amount float64, code string) string {
+	f, ok := numberFormats[locale]
+	if !ok {
+		f = numberFormats[English]
+	}
+	symbol, ok := symbols[strings.ToUpper(code)]
+	if !ok {
+		symbol = strings.ToUpper(code)
+	}
+	minor := money.MinorUnits(code)
+
+	number := formatNumber(f, math.Abs(amount), minor)
+	sign := ""
+	if amount < 0 && number != formatNumber(f, 0, minor) {
+		sign = "-"
+	}
+	if f.symbolAfter {
+		return sign + number + " " + symbol
+	}
+	if isCode(symbol) {
+		return sign + symbol + " " + number
+	}
+	return sign + symbol + number
+}
+
+// isCode reports whether a symbol is a letter code like CHF, which needs a
+// space before the number.
+func isCode(symbol string) bool {
+	for _, r := range symbol {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func formatNumber(f numberFormat, amount float64, decimals int) string {
+	if f.decimal == "" {
+		f = numberFormats[English]
+	}
+	text := strconv.FormatFloat(math.Abs(amount), 'f', decimals, 64)
+	whole, frac, _ := strings.Cut(text, ".")
+
+	var b strings.Builder
+	if amount < 0 && strings.Trim(text, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(digit)
+	}
+	if frac != "" {
+		b.WriteString(f.decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// Printer writes translated messages for one locale. It is safe for
+// concurrent use.
+type Printer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	locale Locale
+}
+
+func NewPrinter(w io.Writer, locale Locale) *Printer {
+	return &Printer{w: w, locale: locale}
+}
+
+func (p *Printer) Locale() Locale {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.locale
+}
+
+func (p *Printer) SetLocale(locale Locale) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.locale = locale
+}
+
+func (p *Printer) SetOutput(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.w = w
+}
+
+// Println writes message id on its own line. Float arguments are amounts and
+// are formatted for the locale, so messages take them as %s.
+func (p *Printer) Println(id MessageID, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, arg := range args {
+		if amount, ok := arg.(float64); ok {
+			args[i] = FormatAmount(p.locale, amount)
+		}
+	}
+	fmt.Fprintln(p.w, T(p.locale, id, args...))
+}
@@ -0,0 +1,159 @@
+package i18n
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestParse checks that locale names with regions and encodings map to the
+// supported locale of their language.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Locale
+		ok   bool
+	}{
+		{"en", English, true},
+		{"ES", Spanish, true},
+		{"de-AT", German, true},
+		{"fr_FR.UTF-8", French, true},
+		{"it_IT", Default, false},
+		{"", Default, false},
+		{"C", Default, false},
+	}
+	for _, tt := range tests {
+		if got, ok := Parse(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("Parse(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestFromEnv checks the order the environment variables are read in.
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		bank, all, lang string
+		want            Locale
+	}{
+		{"", "", "", Default},
+		{"", "", "de_DE.UTF-8", German},
+		{"", "fr_FR", "de_DE", French},
+		{"es", "fr_FR", "de_DE", Spanish},
+		{"xx", "C", "fr", French},
+	}
+	for _, tt := range tests {
+		t.Setenv("BANK_LOCALE", tt.bank)
+		t.Setenv("LC_ALL", tt.all)
+		t.Setenv("LANG", tt.lang)
+		if got := FromEnv(); got != tt.want {
+			t.Errorf("FromEnv() with %q, %q, %q = %q, want %q", tt.bank, tt.all, tt.lang, got, tt.want)
+		}
+	}
+}
+
+// TestCatalog checks that every locale translates every message English
+// has, with the same arguments.
+func TestCatalog(t *testing.T) {
+	for _, l := range Locales() {
+		for id, english := range catalog[English] {
+			msg, ok := catalog[l][id]
+			if !ok {
+				t.Errorf("%s: %s is not translated", l, id)
+				continue
+			}
+			if strings.Count(msg, "%") != strings.Count(english, "%") {
+				t.Errorf("%s: %s %q takes other arguments than %q", l, id, msg, english)
+			}
+		}
+	}
+}
+
+// TestT checks translation, formatting and the fall back to English.
+func TestT(t *testing.T) {
+	tests := []struct {
+		locale Locale
+		id     MessageID
+		args   []any
+		want   string
+	}{
+		{English, MsgInsufficientFunds, nil, "Insufficient funds."},
+		{Spanish, MsgInsufficientFunds, nil, "Fondos insuficientes."},
+		{German, MsgDeposited, []any{"10,00"}, "Eingezahlt: 10,00"},
+		{"it", MsgWithdrew, []any{"5.00"}, "Withdrew: 5.00"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.locale, tt.id, tt.args...); got != tt.want {
+			t.Errorf("T(%s, %s) = %q, want %q", tt.locale, tt.id, got, tt.want)
+		}
+	}
+}
+
+// TestFormatAmount checks grouping, decimal separators and signs.
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		locale Locale
+		amount float64
+		want   string
+	}{
+		{English, 1234.5, "1,234.50"},
+		{English, -1234567.5, "-1,234,567.50"},
+		{English, 999, "999.00"},
+		{German, 999.999, "1.000,00"},
+		{Spanish, 1000, "1.000,00"},
+		{French, 1234567.89, "1\u202f234\u202f567,89"},
+		{French, 0, "0,00"},
+		{English, -0.004, "0.00"},
+		{"it", 1234.5, "1,234.50"},
+	}
+	for _, tt := range tests {
+		if got := FormatAmount(tt.locale, tt.amount); got != tt.want {
+			t.Errorf("FormatAmount(%s, %v) = %q, want %q", tt.locale, tt.amount, got, tt.want)
+		}
+	}
+}
+
+// TestFormatMoney checks where each locale puts the symbol and the sign,
+// and the decimals of each currency. Symbols are kept with their number by
+// a no-break space.
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		locale Locale
+		amount float64
+		code   string
+		want   string
+	}{
+		{English, 1234.5, "USD", "$1,234.50"},
+		{English, -42, "usd", "-$42.00"},
+		{German, 1234.5, "EUR", "1.234,50\u00a0€"},
+		{French, -1234567.891, "EUR", "-1\u202f234\u202f567,89\u00a0€"},
+		{Spanish, -0.001, "USD", "0,00\u00a0$"},
+		{English, -42, "CHF", "-CHF\u00a042.00"},
+		{English, 1500, "JPY", "¥1,500"},
+		{English, 1, "KWD", "KWD\u00a01.000"},
+		{English, 5, "xyz", "XYZ\u00a05.00"},
+		{"it", 1, "GBP", "£1.00"},
+	}
+	for _, tt := range tests {
+		if got := FormatMoney(tt.locale, tt.amount, tt.code); got != tt.want {
+			t.Errorf("FormatMoney(%s, %v, %s) = %q, want %q", tt.locale, tt.amount, tt.code, got, tt.want)
+		}
+	}
+}
+
+// TestPrinter checks that amounts are formatted for the printer's locale
+// and that the locale can change.
+func TestPrinter(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPrinter(&out, German)
+	p.Println(MsgDeposited, 1234.5)
+	p.SetLocale(French)
+	p.Println(MsgWithdrew, 20.0)
+	p.Println(MsgInsufficientFunds)
+	want := "Eingezahlt: 1.234,50\nRetiré : 20,00\nFonds insuffisants.\n"
+	if out.String() != want {
+		t.Errorf("printed %q, want %q", out.String(), want)
+	}
+	if p.Locale() != French {
+		t.Errorf("Locale() = %s", p.Locale())
+	}
+}
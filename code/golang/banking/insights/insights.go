@@ -0,0 +1,290 @@
+// Package insights explains where an account's money went in a month: how
+// its spending changed from the month before, in total and by category, who
+// it went to most, and which payments stand out from the account's usual
+// ones. Each finding is an Insight with a message ready to show, for the
+// dashboard and for notifications alike.
+package insights
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+type Kind string
+
+const (
+	// KindSpendChange compares the spending of the month with the month
+	// before, in total when Category is empty.
+	KindSpendChange Kind = "spend_change"
+	// KindTopCounterparty is one of the counterparties most was paid to.
+	KindTopCounterparty Kind = "top_counterparty"
+	// KindUnusualSpend is a payment much larger than the account's usual
+	// ones, to the same counterparty or, for a new one, to any.
+	KindUnusualSpend Kind = "unusual_spend"
+)
+
+// Insight is one finding of a Report. Amount is what was spent in the month,
+// or the payment for KindUnusualSpend; Previous is what it is compared with,
+// the month before's spending or the usual payment, and Change how much more
+// Amount is, in percent.
+type Insight struct {
+	Kind         Kind    `json:"kind"`
+	Message      string  `json:"message"`
+	Category     string  `json:"category,omitempty"`
+	Counterparty string  `json:"counterparty,omitempty"`
+	Amount       float64 `json:"amount"`
+	Previous     float64 `json:"previous"`
+	Change       float64 `json:"change,omitempty"`
+	Count        int     `json:"count,omitempty"`
+	Sequence     int     `json:"sequence,omitempty"`
+}
+
+// Report holds the insights of an account's month, in the order of Kind's
+// constants and, within a kind, most significant first.
+type Report struct {
+	Month         string    `json:"month"`
+	Spend         float64   `json:"spend"`
+	PreviousSpend float64   `json:"previous_spend"`
+	Insights      []Insight `json:"insights"`
+}
+
+const (
+	DefaultTop       = 3
+	DefaultBaseline  = 3
+	DefaultFactor    = 3
+	DefaultMinChange = 25
+)
+
+// Options tune a Report. Zero fields take the defaults.
+type Options struct {
+	// Top is how many counterparties are reported.
+	Top int
+	// Baseline is how many months before the month usual payments are
+	// learnt from, and Factor how many times larger than usual a payment
+	// must be to stand out.
+	Baseline int
+	Factor   float64
+	// MinChange is the smallest change of a category's spending, in
+	// percent, worth an insight. The total's is always reported.
+	MinChange float64
+	// Amounts are rounded to the minor unit of Currency with Rounding.
+	Currency string
+	Rounding money.Rounding
+}
+
+func (o Options) withDefaults() Options {
+	if o.Top <= 0 {
+		o.Top = DefaultTop
+	}
+	if o.Baseline <= 0 {
+		o.Baseline = DefaultBaseline
+	}
+	if o.Factor <= 1 {
+		o.Factor = DefaultFactor
+	}
+	if o.MinChange <= 0 {
+		o.MinChange = DefaultMinChange
+	}
+	return o
+}
+
+// Analyze reports the insights of the month of month in the ledger history
+// of an account. Spending is its withdrawals.
+func Analyze(history []models.Transaction, month time.Time, opts Options) Report {
+	opts = opts.withDefaults()
+	round := func(x float64) float64 { return money.Round(x, opts.Currency, opts.Rounding) }
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	end := start.AddDate(0, 1, 0)
+	previous := start.AddDate(0, -1, 0)
+	baseline := start.AddDate(0, -opts.Baseline, 0)
+
+	var current, before, usual []models.Transaction
+	for _, tx := range history {
+		if tx.Type != models.TransactionWithdrawal {
+			continue
+		}
+		switch t := tx.Time; {
+		case !t.Before(start) && t.Before(end):
+			current = append(current, tx)
+		case !t.Before(baseline) && t.Before(start):
+			usual = append(usual, tx)
+			if !t.Before(previous) {
+				before = append(before, tx)
+			}
+		}
+	}
+
+	report := Report{Month: start.Format("2006-01"), Spend: round(total(current)), PreviousSpend: round(total(before)), Insights: []Insight{}}
+	report.Insights = append(report.Insights, spendChanges(current, before, previous, opts, round)...)
+	report.Insights = append(report.Insights, topCounterparties(current, before, opts, round)...)
+	report.Insights = append(report.Insights, unusualSpend(current, usual, opts, round)...)
+	return report
+}
+
+func spendChanges(current, before []models.Transaction, previous time.Time, opts Options, round func(float64) float64) []Insight {
+	since := previous.Format("January")
+	overall := change(Insight{Kind: KindSpendChange, Amount: round(total(current)), Previous: round(total(before))})
+	switch {
+	case overall.Previous == 0:
+		overall.Message = fmt.Sprintf("You spent %.2f, and nothing in %s", overall.Amount, since)
+	case overall.Change >= 0:
+		overall.Message = fmt.Sprintf("You spent %.2f, %.0f%% more than in %s", overall.Amount, overall.Change, since)
+	default:
+		overall.Message = fmt.Sprintf("You spent %.2f, %.0f%% less than in %s", overall.Amount, -overall.Change, since)
+	}
+
+	now, then := byKey(current, category), byKey(before, category)
+	var categories []Insight
+	for _, c := range union(now, then) {
+		if c == "" {
+			continue
+		}
+		in := change(Insight{Kind: KindSpendChange, Category: c, Amount: round(now[c].amount), Previous: round(then[c].amount)})
+		switch {
+		case in.Previous == 0:
+			in.Message = fmt.Sprintf("New spending on %s: %.2f", c, in.Amount)
+		case in.Amount == 0:
+			in.Message = fmt.Sprintf("Nothing spent on %s, against %.2f in %s", c, in.Previous, since)
+		case math.Abs(in.Change) < opts.MinChange:
+			continue
+		case in.Change > 0:
+			in.Message = fmt.Sprintf("Spending on %s up %.0f%% to %.2f", c, in.Change, in.Amount)
+		default:
+			in.Message = fmt.Sprintf("Spending on %s down %.0f%% to %.2f", c, -in.Change, in.Amount)
+		}
+		categories = append(categories, in)
+	}
+	slices.SortStableFunc(categories, func(a, b Insight) int {
+		return cmp.Compare(math.Abs(b.Amount-b.Previous), math.Abs(a.Amount-a.Previous))
+	})
+	return append([]Insight{overall}, categories...)
+}
+
+func topCounterparties(current, before []models.Transaction, opts Options, round func(float64) float64) []Insight {
+	now, then := byKey(current, counterparty), byKey(before, counterparty)
+	var top []Insight
+	for name, s := range now {
+		if name == "" {
+			continue
+		}
+		in := change(Insight{Kind: KindTopCounterparty, Counterparty: name, Amount: round(s.amount), Previous: round(then[name].amount), Count: s.count})
+		in.Message = fmt.Sprintf("%.2f to %s in %s", in.Amount, name, payments(s.count))
+		top = append(top, in)
+	}
+	slices.SortFunc(top, func(a, b Insight) int {
+		return cmp.Or(cmp.Compare(b.Amount, a.Amount), cmp.Compare(a.Counterparty, b.Counterparty))
+	})
+	return top[:min(len(top), opts.Top)]
+}
+
+func unusualSpend(current, usual []models.Transaction, opts Options, round func(float64) float64) []Insight {
+	if len(usual) == 0 {
+		// Nothing is unusual for an account without a history.
+		return nil
+	}
+	usualTo := byKey(usual, counterparty)
+	amounts := make([]float64, len(usual))
+	for i, tx := range usual {
+		amounts[i] = tx.Amount
+	}
+	typical := median(amounts)
+
+	var unusual []Insight
+	for _, tx := range current {
+		in := Insight{Kind: KindUnusualSpend, Category: tx.Category, Counterparty: tx.Counterparty, Amount: tx.Amount, Sequence: tx.Sequence}
+		to := tx.Counterparty
+		if to == "" {
+			to = "an unknown party"
+		}
+		s, known := usualTo[tx.Counterparty]
+		known = known && tx.Counterparty != ""
+		in.Previous = round(typical)
+		if known {
+			in.Previous = round(s.amount / float64(s.count))
+		}
+		if in.Previous <= 0 || tx.Amount <= opts.Factor*in.Previous {
+			continue
+		}
+		in.Message = fmt.Sprintf("%.2f to %s is %.1f times your usual payment", tx.Amount, to, tx.Amount/in.Previous)
+		if known {
+			in.Message += " to them"
+		}
+		unusual = append(unusual, change(in))
+	}
+	slices.SortStableFunc(unusual, func(a, b Insight) int { return cmp.Compare(b.Change, a.Change) })
+	return unusual
+}
+
+// change sets the change of in from Previous to Amount, in percent, for the
+// comparisons that have one.
+func change(in Insight) Insight {
+	if in.Previous != 0 {
+		in.Change = math.Round((in.Amount-in.Previous)/in.Previous*1000) / 10
+	}
+	return in
+}
+
+type spend struct {
+	amount float64
+	count  int
+}
+
+func category(tx models.Transaction) string     { return tx.Category }
+func counterparty(tx models.Transaction) string { return tx.Counterparty }
+
+func byKey(txs []models.Transaction, key func(models.Transaction) string) map[string]spend {
+	m := make(map[string]spend)
+	for _, tx := range txs {
+		s := m[key(tx)]
+		s.amount += tx.Amount
+		s.count++
+		m[key(tx)] = s
+	}
+	return m
+}
+
+// union returns the keys of both maps, sorted.
+func union(a, b map[string]spend) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func total(txs []models.Transaction) float64 {
+	var sum float64
+	for _, tx := range txs {
+		sum += tx.Amount
+	}
+	return sum
+}
+
+func median(xs []float64) float64 {
+	xs = slices.Clone(xs)
+	slices.Sort(xs)
+	n := len(xs)
+	if n%2 == 1 {
+		return xs[n/2]
+	}
+	return (xs[n/2-1] + xs[n/2]) / 2
+}
+
+func payments(n int) string {
+	if n == 1 {
+		return "1 payment"
+	}
+	return fmt.Sprintf("%d payments", n)
+}
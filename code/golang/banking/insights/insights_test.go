@@ -0,0 +1,123 @@
+package insights
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// withdrawal is a payment on day, such as "2026-03-05".
+func withdrawal(t *testing.T, day string, amount float64, category, counterparty string) models.Transaction {
+	t.Helper()
+	at, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.Transaction{Type: models.TransactionWithdrawal, Amount: amount, Category: category, Counterparty: counterparty, Time: at}
+}
+
+// TestAnalyze checks the insights of March 2026 for histories that spend
+// more, less and nothing, and how the options narrow them down.
+func TestAnalyze(t *testing.T) {
+	march := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	more := []models.Transaction{
+		withdrawal(t, "2026-01-10", 60, "groceries", "Shop"),
+		withdrawal(t, "2026-02-03", 50, "groceries", "Shop"),
+		withdrawal(t, "2026-02-17", 50, "groceries", "Shop"),
+		withdrawal(t, "2026-02-01", 1000, "rent", "Landlord"),
+		withdrawal(t, "2026-03-01", 1000, "rent", "Landlord"),
+		withdrawal(t, "2026-03-04", 50, "groceries", "Shop"),
+		withdrawal(t, "2026-03-20", 150, "groceries", "Shop"),
+		withdrawal(t, "2026-03-22", 300, "travel", "Airline"),
+		{Type: models.TransactionDeposit, Amount: 5000, Time: march},
+		withdrawal(t, "2026-04-01", 999, "rent", "Landlord"),
+	}
+	less := []models.Transaction{
+		withdrawal(t, "2026-02-03", 100, "groceries", "Shop"),
+		withdrawal(t, "2026-02-05", 200, "rent", "Landlord"),
+		withdrawal(t, "2026-03-09", 40, "groceries", "Shop"),
+	}
+
+	tests := []struct {
+		name    string
+		history []models.Transaction
+		opts    Options
+		spend   float64
+		want    []string
+	}{
+		{"more", more, Options{}, 1500, []string{
+			"You spent 1500.00, 36% more than in February",
+			"New spending on travel: 300.00",
+			"Spending on groceries up 100% to 200.00",
+			"1000.00 to Landlord in 1 payment",
+			"300.00 to Airline in 1 payment",
+			"200.00 to Shop in 2 payments",
+			"300.00 to Airline is 5.5 times your usual payment",
+		}},
+		{"fewer insights", more, Options{Top: 1, MinChange: 150, Factor: 6}, 1500, []string{
+			"You spent 1500.00, 36% more than in February",
+			"New spending on travel: 300.00",
+			"1000.00 to Landlord in 1 payment",
+		}},
+		{"less", less, Options{}, 40, []string{
+			"You spent 40.00, 87% less than in February",
+			"Nothing spent on rent, against 200.00 in February",
+			"Spending on groceries down 60% to 40.00",
+			"40.00 to Shop in 1 payment",
+		}},
+		{"nothing", nil, Options{}, 0, []string{
+			"You spent 0.00, and nothing in February",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Analyze(tt.history, march, tt.opts)
+			if report.Month != "2026-03" || report.Spend != tt.spend {
+				t.Errorf("month %s spend %.2f, want 2026-03 spend %.2f", report.Month, report.Spend, tt.spend)
+			}
+			got := make([]string, len(report.Insights))
+			for i, in := range report.Insights {
+				got[i] = in.Message
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("insights:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestUnusualSpend checks that a payment is compared with the usual one to
+// the same counterparty when there is one, and with the median of all
+// payments otherwise.
+func TestUnusualSpend(t *testing.T) {
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := []models.Transaction{
+		withdrawal(t, "2026-02-01", 10, "", "Cafe"),
+		withdrawal(t, "2026-02-02", 500, "", "Garage"),
+		withdrawal(t, "2026-02-03", 20, "", ""),
+		withdrawal(t, "2026-03-02", 40, "", "Cafe"),
+		withdrawal(t, "2026-03-03", 900, "", "Garage"),
+		withdrawal(t, "2026-03-04", 100, "", ""),
+	}
+	var got []Insight
+	for _, in := range Analyze(history, march, Options{}).Insights {
+		if in.Kind == KindUnusualSpend {
+			got = append(got, in)
+		}
+	}
+	want := []Insight{
+		{Counterparty: "", Amount: 100, Previous: 20, Change: 400},
+		{Counterparty: "Cafe", Amount: 40, Previous: 10, Change: 300},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unusual payments = %+v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Counterparty != w.Counterparty || g.Amount != w.Amount || g.Previous != w.Previous || g.Change != w.Change {
+			t.Errorf("unusual payment %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
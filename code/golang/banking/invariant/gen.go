@@ -0,0 +1,179 @@
+package invariant
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// OpKind is what an Op does.
+type OpKind string
+
+const (
+	OpDeposit  OpKind = "deposit"
+	OpWithdraw OpKind = "withdraw"
+	OpTransfer OpKind = "transfer"
+)
+
+// Op is one operation on a bank: a deposit into To, a withdrawal from From
+// or a transfer between them.
+type Op struct {
+	Kind   OpKind
+	From   string
+	To     string
+	Amount float64
+}
+
+func (o Op) String() string {
+	switch o.Kind {
+	case OpDeposit:
+		return fmt.Sprintf("deposit %.2f into %s", o.Amount, o.To)
+	case OpWithdraw:
+		return fmt.Sprintf("withdraw %.2f from %s", o.Amount, o.From)
+	default:
+		return fmt.Sprintf("transfer %.2f from %s to %s", o.Amount, o.From, o.To)
+	}
+}
+
+// Apply runs o on bank and returns the money it paid into the bank from
+// outside, negative for a withdrawal, and its error. Operations that fail
+// must change nothing, so they pay in nothing.
+func (o Op) Apply(bank *models.Bank) (float64, error) {
+	var err error
+	external := 0.0
+	switch o.Kind {
+	case OpDeposit:
+		err, external = bank.Deposit(o.To, o.Amount), o.Amount
+	case OpWithdraw:
+		err, external = bank.Withdraw(o.From, o.Amount), -o.Amount
+	default:
+		err = bank.Transfer(o.From, o.To, o.Amount)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return external, nil
+}
+
+// Run applies ops to bank one by one and checks every invariant after each.
+// It stops at the first operation that breaks one and returns the
+// operations run up to it and the violations; errors returned by the bank,
+// such as insufficient funds, are expected and ignored.
+func Run(bank *models.Bank, ops []Op) ([]Op, []Violation) {
+	base := Take(bank)
+	external := 0.0
+	for i, op := range ops {
+		paid, _ := op.Apply(bank)
+		external += paid
+		if violations := Check(bank, base, external); len(violations) > 0 {
+			return ops[:i+1], violations
+		}
+	}
+	return ops, nil
+}
+
+// Scenario is a random set of empty accounts and operations on them. It
+// implements quick.Generator, so a property can take one as an argument:
+//
+//	quick.Check(func(s invariant.Scenario) bool { return s.Holds() }, nil)
+type Scenario struct {
+	Accounts []models.BankAccount
+	Ops      []Op
+}
+
+// Generate returns a random Scenario with up to size operations.
+func (Scenario) Generate(r *rand.Rand, size int) reflect.Value {
+	accounts := GenAccounts(r, 2+r.Intn(4))
+	numbers := make([]string, len(accounts))
+	for i, a := range accounts {
+		numbers[i] = a.Number()
+	}
+	return reflect.ValueOf(Scenario{Accounts: accounts, Ops: GenOps(r, size, numbers)})
+}
+
+// Bank opens the scenario's accounts in a new bank.
+func (s Scenario) Bank() *models.Bank {
+	bank := models.NewBank()
+	for _, a := range s.Accounts {
+		bank.Open(a)
+	}
+	return bank
+}
+
+// Violations runs the scenario in a new bank and returns what it broke.
+func (s Scenario) Violations() []Violation {
+	_, violations := Run(s.Bank(), s.Ops)
+	return violations
+}
+
+// Holds reports whether the scenario breaks no invariant.
+func (s Scenario) Holds() bool {
+	return len(s.Violations()) == 0
+}
+
+// GenAccounts returns n empty accounts of every kind that moves money
+// freely: savings, checking with and without an overdraft, and credit cards.
+// Loans are left out as they cannot be opened empty.
+func GenAccounts(r *rand.Rand, n int) []models.BankAccount {
+	accounts := make([]models.BankAccount, n)
+	for i := range accounts {
+		account := models.Account{AccountNumber: fmt.Sprintf("A%03d", i+1)}
+		switch r.Intn(4) {
+		case 0:
+			accounts[i] = &models.SavingsAccount{Account: account, InterestRate: money.Percent(float64(r.Intn(600)) / 100)}
+		case 1:
+			accounts[i] = &models.CheckingAccount{Account: account}
+		case 2:
+			accounts[i] = &models.CheckingAccount{Account: account, OverdraftLimit: float64(r.Intn(50)) * 10}
+		default:
+			accounts[i] = &models.CreditCardAccount{Account: account, CreditLimit: float64(1+r.Intn(50)) * 100, PurchaseRate: money.Percent(19.9)}
+		}
+	}
+	return accounts
+}
+
+// GenOps returns n random operations on the accounts numbered numbers,
+// with the occasional unknown account and invalid amount to check that
+// failures change nothing.
+func GenOps(r *rand.Rand, n int, numbers []string) []Op {
+	account := func() string {
+		if r.Intn(20) == 0 {
+			return "unknown"
+		}
+		return numbers[r.Intn(len(numbers))]
+	}
+	ops := make([]Op, n)
+	for i := range ops {
+		op := Op{From: account(), To: account(), Amount: GenAmount(r)}
+		switch r.Intn(3) {
+		case 0:
+			op.Kind = OpDeposit
+		case 1:
+			op.Kind = OpWithdraw
+		default:
+			op.Kind = OpTransfer
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// GenAmount returns a random amount: usually whole cents of everyday size,
+// sometimes large, sub-cent, zero or negative.
+func GenAmount(r *rand.Rand) float64 {
+	switch r.Intn(20) {
+	case 0:
+		return 0
+	case 1:
+		return -float64(1+r.Intn(10000)) / 100
+	case 2:
+		return float64(r.Intn(1000)) / 1000
+	case 3:
+		return float64(r.Intn(100000000)) / 100
+	default:
+		return float64(1+r.Intn(50000)) / 100
+	}
+}
@@ -0,0 +1,114 @@
+// Package invariant checks the properties a bank keeps whatever it is asked
+// to do: money is neither created nor destroyed by moving it between
+// accounts, no balance goes further below zero than its overdraft or credit
+// limit allows, and every ledger adds up to its balance. It also generates
+// random accounts and operations to check them with, for testing/quick or
+// any property-based testing library, so code that extends the bank can be
+// fuzzed against the same invariants.
+package invariant
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gsolano/banking/models"
+)
+
+// tolerance absorbs floating point noise in sums of amounts.
+const tolerance = 0.005
+
+// The invariants a Violation can name.
+const (
+	Conservation = "conservation"
+	Overdraft    = "overdraft"
+	Ledger       = "ledger"
+)
+
+// Violation is an invariant found broken, on Account when it is about one.
+type Violation struct {
+	Invariant string
+	Account   string
+	Detail    string
+}
+
+func (v Violation) String() string {
+	if v.Account == "" {
+		return v.Invariant + ": " + v.Detail
+	}
+	return fmt.Sprintf("%s: account %s: %s", v.Invariant, v.Account, v.Detail)
+}
+
+// Baseline is the state of a bank that later checks compare against: every
+// account's balance and ledger length, and the money held in all of them.
+type Baseline struct {
+	balances map[string]float64
+	ledgers  map[string]int
+	total    float64
+}
+
+// Take records the baseline of bank. Accounts opened after it must start
+// with a zero balance, as their ledger is checked from zero.
+func Take(bank *models.Bank) Baseline {
+	base := Baseline{balances: make(map[string]float64), ledgers: make(map[string]int)}
+	for _, account := range bank.Accounts() {
+		number := account.Number()
+		balance, _ := bank.Balance(number)
+		history, _ := bank.History(number)
+		base.balances[number] = balance
+		base.ledgers[number] = len(history)
+		base.total += balance
+	}
+	return base
+}
+
+// Check checks every invariant of bank against base. external is the money
+// paid into the bank from outside, less the money paid out, since base was
+// taken: the deposits and withdrawals that succeeded. Interest and fees the
+// bank posted itself are accounted for from the ledgers.
+func Check(bank *models.Bank, base Baseline, external float64) []Violation {
+	var violations []Violation
+	total, posted := 0.0, 0.0
+	for _, account := range bank.Accounts() {
+		number := account.Number()
+		balance, _ := bank.Balance(number)
+		history, _ := bank.History(number)
+		total += balance
+
+		sum := base.balances[number]
+		for i, tx := range history {
+			if tx.Sequence != i+1 {
+				violations = append(violations, Violation{Ledger, number,
+					fmt.Sprintf("entry %d has sequence %d", i+1, tx.Sequence)})
+			}
+			if i < base.ledgers[number] {
+				continue
+			}
+			amount := tx.Amount
+			if !tx.Type.IsCredit() {
+				amount = -amount
+			}
+			sum += amount
+			if tx.Type != models.TransactionDeposit && tx.Type != models.TransactionWithdrawal {
+				posted += amount
+			}
+		}
+		if math.Abs(sum-balance) > tolerance {
+			violations = append(violations, Violation{Ledger, number,
+				fmt.Sprintf("ledger adds up to %.2f but the balance is %.2f", sum, balance)})
+		}
+
+		limit, _ := bank.OverdraftLimit(number)
+		if balance < -limit-tolerance {
+			violations = append(violations, Violation{Overdraft, number,
+				fmt.Sprintf("balance %.2f is below the limit of %.2f", balance, -limit)})
+		}
+	}
+
+	if want := base.total + external + posted; math.Abs(total-want) > tolerance {
+		violations = append(violations, Violation{Invariant: Conservation,
+			Detail: fmt.Sprintf("the bank holds %.2f but %.2f was paid in or posted, from %.2f", total, external+posted, base.total)})
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Account < violations[j].Account })
+	return violations
+}
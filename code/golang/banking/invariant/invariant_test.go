@@ -0,0 +1,94 @@
+package invariant
+
+import (
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"gsolano/banking/models"
+)
+
+// TestScenariosHold runs random scenarios against a bank and checks every
+// invariant after each operation, logging the operations up to the first that
+// breaks one.
+func TestScenariosHold(t *testing.T) {
+	models.SetOutput(io.Discard)
+	property := func(s Scenario) bool {
+		ran, violations := Run(s.Bank(), s.Ops)
+		if len(violations) == 0 {
+			return true
+		}
+		steps := make([]string, len(ran))
+		for i, op := range ran {
+			steps[i] = op.String()
+		}
+		t.Logf("after %s:\n%v", strings.Join(steps, ", "), violations)
+		return false
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCheck checks that Check names each broken invariant: money unaccounted
+// for and a balance below its limit.
+func TestCheck(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C"}, OverdraftLimit: 100})
+	base := Take(bank)
+	paid, err := Op{Kind: OpDeposit, To: "C", Amount: 50}.Apply(bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		external float64
+		want     []string
+	}{
+		{"accounted", paid, nil},
+		{"unaccounted", 0, []string{Conservation}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range Check(bank, base, tt.external) {
+				got = append(got, v.Invariant)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Check = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A baseline that misremembers the balance leaves the ledger short.
+	base.balances["C"] = 10
+	violations := Check(bank, base, paid)
+	if len(violations) == 0 || violations[0].Invariant != Ledger || violations[0].Account != "C" {
+		t.Errorf("Check with a wrong baseline = %v, want a ledger violation on C", violations)
+	}
+}
+
+// TestGenOps checks that generated operations only name the accounts given
+// or the unknown one.
+func TestGenOps(t *testing.T) {
+	property := func(seed int64) bool {
+		s := Scenario{}.Generate(rand.New(rand.NewSource(seed)), 50).Interface().(Scenario)
+		known := map[string]bool{"unknown": true}
+		for _, a := range s.Accounts {
+			known[a.Number()] = true
+		}
+		for _, op := range s.Ops {
+			if !known[op.From] || !known[op.To] {
+				return false
+			}
+		}
+		return len(s.Ops) == 50
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Fatal(err)
+	}
+}
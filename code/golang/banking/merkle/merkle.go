@@ -0,0 +1,92 @@
+// Package merkle builds Merkle trees as certificate transparency logs do
+// (RFC 9162): a root hash that commits to a list of leaves, and inclusion
+// proofs that one leaf is in the tree of a root. A proof is a few hashes,
+// one per level, so a leaf can be checked against a published root without
+// the other leaves. Leaves and inner nodes are hashed with different
+// prefixes, so that no inner node passes for a leaf.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// LeafHash is the hash of a leaf's data in the tree.
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root is the root hash of the tree of leaves, given by their LeafHash.
+// The root of no leaves is the hash of nothing.
+func Root(leaves [][]byte) []byte {
+	switch n := len(leaves); n {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	default:
+		k := split(n)
+		return nodeHash(Root(leaves[:k]), Root(leaves[k:]))
+	}
+}
+
+// Proof is the inclusion proof of the leaf at index: the hashes of the
+// subtrees it is combined with on the way to the root, lowest first.
+func Proof(leaves [][]byte, index int) [][]byte {
+	n := len(leaves)
+	if n <= 1 || index < 0 || index >= n {
+		return nil
+	}
+	k := split(n)
+	if index < k {
+		return append(Proof(leaves[:k], index), Root(leaves[k:]))
+	}
+	return append(Proof(leaves[k:], index-k), Root(leaves[:k]))
+}
+
+// Verify reports whether proof shows that leaf, a LeafHash, is the leaf at
+// index of a tree of size leaves with the given root.
+func Verify(root, leaf []byte, index, size int, proof [][]byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
+
+// split is the largest power of two smaller than n, where the tree of n
+// leaves divides into its left and right subtrees.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
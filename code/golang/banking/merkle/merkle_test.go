@@ -0,0 +1,47 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestProofs checks every leaf of trees of 1 to 20 leaves verifies against
+// the root, and not at another index or with another leaf.
+func TestProofs(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, LeafHash([]byte(fmt.Sprintf("entry %d", i))))
+		}
+		root := Root(leaves)
+		for i := range leaves {
+			proof := Proof(leaves, i)
+			if !Verify(root, leaves[i], i, n, proof) {
+				t.Fatalf("leaf %d of %d does not verify", i, n)
+			}
+			if n > 1 && Verify(root, leaves[(i+1)%n], i, n, proof) {
+				t.Fatalf("leaf %d of %d verifies with leaf %d", i, n, (i+1)%n)
+			}
+			if n > 1 && Verify(root, leaves[i], (i+1)%n, n, proof) {
+				t.Fatalf("leaf %d of %d verifies at index %d", i, n, (i+1)%n)
+			}
+		}
+	}
+}
+
+// TestRootCommits checks that changing, adding or reordering a leaf
+// changes the root.
+func TestRootCommits(t *testing.T) {
+	a, b, c := LeafHash([]byte("a")), LeafHash([]byte("b")), LeafHash([]byte("c"))
+	root := Root([][]byte{a, b, c})
+	for _, leaves := range [][][]byte{{a, b}, {a, c, b}, {a, b, c, c}, {a, b, LeafHash([]byte("d"))}} {
+		if bytes.Equal(Root(leaves), root) {
+			t.Errorf("%d leaves have the root of a, b, c", len(leaves))
+		}
+	}
+	// The data of a leaf made of two hashes is not the node of those two.
+	if bytes.Equal(LeafHash(append(append([]byte{}, a...), b...)), nodeHash(a, b)) {
+		t.Error("a leaf passes for an inner node")
+	}
+}
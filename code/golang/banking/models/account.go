@@ -1,34 +1,115 @@
 package models
 
-import "fmt"
+import (
+	"time"
 
+	"gsolano/banking/i18n"
+	"gsolano/banking/validate"
+)
+
+// Account is the ledger every kind of account embeds. Product is the code
+// of the catalog product it was opened with, if any, and Currency the
+// currency it is kept in when not the bank's.
 type Account struct {
 	AccountNumber string
 	Balance       float64
+	Transactions  []Transaction
+	Product       string
+	Currency      string
+
+	// stamp gives its entries their IDs and zone, the bank's stamp once
+	// opened.
+	stamp func(*Transaction)
 }
 
-func (a *Account) Deposit(amount float64) {
-	if amount <= 0 {
-		fmt.Println("Deposit amount must be positive.")
-		return
-	}
-	a.Balance += amount
-	fmt.Printf("Deposited: %.2f\n", amount)
+func (a *Account) Deposit(amount float64) error {
+	return a.DepositFrom("", amount)
 }
 
-func (a *Account) Withdraw(amount float64) {
-	if amount <= 0 {
-		fmt.Println("Withdrawal amount must be positive.")
-		return
-	}
-	if amount > a.Balance {
-		fmt.Println("Insufficient funds.")
-		return
-	}
-	a.Balance -= amount
-	fmt.Printf("Withdrew: %.2f\n", amount)
+// DepositFrom deposits amount and records counterparty as its source.
+func (a *Account) DepositFrom(counterparty string, amount float64) error {
+	return a.Post(Transaction{Type: TransactionDeposit, Amount: amount, Counterparty: counterparty})
+}
+
+func (a *Account) Withdraw(amount float64) error {
+	return a.WithdrawTo("", amount)
+}
+
+// WithdrawTo withdraws amount and records counterparty as its destination.
+func (a *Account) WithdrawTo(counterparty string, amount float64) error {
+	return a.Post(Transaction{Type: TransactionWithdrawal, Amount: amount, Counterparty: counterparty})
+}
+
+// Post validates tx against the balance and appends it to the ledger.
+func (a *Account) Post(tx Transaction) error {
+	return a.post(tx, 0)
+}
+
+func (a *Account) ledger() *Account {
+	return a
 }
 
 func (a *Account) CheckBalance() float64 {
 	return a.Balance
 }
+
+func (a *Account) Number() string {
+	return a.AccountNumber
+}
+
+// History returns the account's ledger, oldest entry first.
+func (a *Account) History() []Transaction {
+	return a.Transactions
+}
+
+// MaxAmount is the largest amount a single entry may move, well within
+// what money counts exactly in minor units.
+const MaxAmount = validate.MaxAmount
+
+// validAmount reports whether amount can be posted: positive, finite and no
+// more than MaxAmount. NaN is none of these.
+func validAmount(amount float64) bool {
+	return amount > 0 && amount <= MaxAmount
+}
+
+// post applies tx allowing the balance to go down to -overdraft.
+func (a *Account) post(tx Transaction, overdraft float64) error {
+	if !validAmount(tx.Amount) {
+		if tx.Type.IsCredit() {
+			messages.Println(i18n.MsgDepositNotPositive)
+		} else {
+			messages.Println(i18n.MsgWithdrawalNotPositive)
+		}
+		return ErrInvalidAmount
+	}
+	if tx.Time.IsZero() {
+		tx.Time = time.Now()
+	}
+
+	switch tx.Type {
+	case TransactionDeposit, TransactionInterest:
+		a.Balance += tx.Amount
+		if tx.Type == TransactionDeposit {
+			messages.Println(i18n.MsgDeposited, tx.Amount)
+		}
+	case TransactionInterestCharge, TransactionFee:
+		a.Balance -= tx.Amount
+	case TransactionWithdrawal:
+		if tx.Amount > a.Balance+overdraft {
+			if overdraft > 0 {
+				messages.Println(i18n.MsgInsufficientFundsOverdraft)
+			} else {
+				messages.Println(i18n.MsgInsufficientFunds)
+			}
+			return ErrInsufficientFunds
+		}
+		a.Balance -= tx.Amount
+		messages.Println(i18n.MsgWithdrew, tx.Amount)
+	}
+	tx.Sequence = len(a.Transactions) + 1
+	if a.stamp != nil {
+		a.stamp(&tx)
+	}
+	a.Transactions = append(a.Transactions, tx)
+	return nil
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// FuzzAmounts posts deposits, withdrawals and a transfer of arbitrary
+// amounts: whatever is rejected must leave no trace, and what is accepted
+// must keep every balance finite and equal to its ledger.
+func FuzzAmounts(f *testing.F) {
+	f.Add(100.0, 30.0, 50.0)
+	f.Add(0.535, 0.005, 1e-9)
+	f.Add(-10.0, 0.0, 1e300)
+	f.Add(math.NaN(), math.Inf(1), math.Inf(-1))
+	f.Fuzz(func(t *testing.T, deposit, withdrawal, transfer float64) {
+		SetOutput(io.Discard)
+		b := NewBank()
+		b.Open(&SavingsAccount{Account: Account{AccountNumber: "S"}})
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: "C"}, OverdraftLimit: 100})
+		b.Open(&CreditCardAccount{Account: Account{AccountNumber: "K"}, CreditLimit: 500})
+
+		paidIn := 0.0
+		if b.Deposit("S", deposit) == nil {
+			paidIn += deposit
+		}
+		if b.Withdraw("C", withdrawal) == nil {
+			paidIn -= withdrawal
+		}
+		if b.Withdraw("K", withdrawal) == nil {
+			paidIn -= withdrawal
+		}
+		b.Transfer("S", "C", transfer)
+		b.Transfer("C", "K", transfer)
+
+		total := 0.0
+		for _, number := range []string{"S", "C", "K"} {
+			account, _ := b.Account(number)
+			balance := account.CheckBalance()
+			if math.IsNaN(balance) || math.IsInf(balance, 0) {
+				t.Fatalf("account %s has balance %v", number, balance)
+			}
+			if limit := -b.overdraftLimit(account); balance < limit-0.005 {
+				t.Errorf("account %s has balance %.2f, below its limit of %.2f", number, balance, limit)
+			}
+			sum := 0.0
+			for _, tx := range account.History() {
+				if tx.Type.IsCredit() {
+					sum += tx.Amount
+				} else {
+					sum -= tx.Amount
+				}
+			}
+			if math.Abs(sum-balance) > 1e-6*math.Max(1, math.Abs(balance)) {
+				t.Errorf("account %s: ledger adds up to %v but the balance is %v", number, sum, balance)
+			}
+			total += balance
+		}
+		if math.Abs(total-paidIn) > 1e-6*math.Max(1, math.Abs(paidIn)) {
+			t.Errorf("the bank holds %v but %v was paid in", total, paidIn)
+		}
+	})
+}
+
+// TestAccountCurrencyRounding checks that interest, what a loan owes and
+// cash back are rounded to the minor unit of the account's currency, whole
+// yen for JPY, rather than to the cent.
+func TestAccountCurrencyRounding(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1", Currency: "JPY"}, InterestRate: money.Percent(1)})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1", Currency: "JPY"}})
+	for _, number := range []string{"S1", "C1"} {
+		if err := b.Deposit(number, 1502); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.SetRewards("C1", RewardsProgram{Kind: RewardsCashBack, Rate: money.Percent(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ApplyInterest("S1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 1234); err != nil {
+		t.Fatal(err)
+	}
+	rewards, _ := b.Rewards("C1")
+	owed := outstandingOf([]Transaction{{Type: TransactionFee, Amount: 10.4}}, -1000, "JPY")
+
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"interest on 1502 at 1%", b.lastEntry("S1", func(tx Transaction) bool { return tx.Type == TransactionInterest }).Amount, 15},
+		{"fees owed", owed.Fees, 10},
+		{"principal owed", owed.Principal, 990},
+		{"cash back on 1234 at 2%", rewards.Balance, 24},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+// TestTravelerOverride sets an account's ATM limit and countries, relaxes
+// them with an override for a trip and checks withdrawals against what
+// applies before, during and after it, and that a cancelled override stops
+// applying at once.
+func TestTravelerOverride(t *testing.T) {
+	SetOutput(io.Discard)
+	day := date(t, "2026-03-02")
+	clock := &testClock{now: day.Add(9 * time.Hour)}
+	b := NewBank()
+	b.Clock = clock
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	if err := b.Deposit("C1", 5000); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetControls("C1", Controls{ATMDaily: 300, Countries: []string{"ES"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []Override{
+		{End: day.Add(48 * time.Hour)},
+		{End: day, ATMDaily: 500},
+		{Start: day.Add(48 * time.Hour), End: day.Add(24 * time.Hour), ATMDaily: 500},
+		{End: day.Add(48 * time.Hour), Countries: []string{"France"}},
+	} {
+		if _, err := b.Override("C1", o); !errors.Is(err, ErrInvalidControls) {
+			t.Errorf("override %+v: %v, want ErrInvalidControls", o, err)
+		}
+	}
+	trip, err := b.Override("C1", Override{Start: day.Add(10 * time.Hour), End: day.Add(72 * time.Hour), ATMDaily: 500, Countries: []string{"fr"}, Reason: "trip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		at      time.Duration
+		amount  float64
+		country string
+		atm     bool
+		want    error
+	}{
+		{at: 9 * time.Hour, amount: 200, country: "ES", atm: true},
+		{at: 9 * time.Hour, amount: 150, country: "ES", atm: true, want: ErrATMLimit},
+		{at: 9 * time.Hour, amount: 10, country: "FR", atm: true, want: ErrCountryNotAllowed},
+		{at: 9 * time.Hour, amount: 1000, country: "ES"},
+		// The trip raises the limit and allows France.
+		{at: 11 * time.Hour, amount: 150, country: "ES", atm: true},
+		{at: 12 * time.Hour, amount: 100, country: "FR", atm: true},
+		{at: 13 * time.Hour, amount: 60, country: "FR", atm: true, want: ErrATMLimit},
+		{at: 14 * time.Hour, amount: 50, country: "US", want: ErrCountryNotAllowed},
+		// And reverts by itself when it ends.
+		{at: 73 * time.Hour, amount: 10, country: "FR", atm: true, want: ErrCountryNotAllowed},
+		{at: 73 * time.Hour, amount: 301, country: "ES", atm: true, want: ErrATMLimit},
+	} {
+		clock.now = day.Add(tt.at)
+		var opts []TxOption
+		if tt.atm {
+			opts = append(opts, AtATM(tt.country))
+		} else {
+			opts = append(opts, WithMetadata(MetaCountry, tt.country))
+		}
+		if err := b.Withdraw("C1", tt.amount, opts...); !errors.Is(err, tt.want) {
+			t.Errorf("%s: withdrawing %.2f in %s: %v, want %v", clock.now.Format(time.DateTime), tt.amount, tt.country, err, tt.want)
+		}
+	}
+
+	for _, want := range [][]int{{trip.ID}, nil} {
+		var ids []int
+		for _, o := range b.ExpireOverrides() {
+			ids = append(ids, o.ID)
+		}
+		if !slices.Equal(ids, want) {
+			t.Errorf("expired %v, want %v", ids, want)
+		}
+	}
+	again, err := b.Override("C1", Override{End: clock.now.Add(24 * time.Hour), ATMDaily: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.CancelOverride("C1", again.ID+1); !errors.Is(err, ErrOverrideNotFound) {
+		t.Errorf("cancelling an unknown override: %v, want ErrOverrideNotFound", err)
+	}
+	if o, err := b.CancelOverride("C1", again.ID); err != nil || o.Ended == nil {
+		t.Fatalf("cancelling: %+v, %v", o, err)
+	}
+	usual, effective, overrides, err := b.Controls("C1", clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effective.ATMDaily != usual.ATMDaily || !slices.Equal(effective.Countries, usual.Countries) || len(overrides) != 2 {
+		t.Errorf("controls after cancelling %+v with %d overrides, want %+v with 2", effective, len(overrides), usual)
+	}
+}
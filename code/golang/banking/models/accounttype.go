@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+)
+
+var ErrUnknownAccountKind = banking.New(banking.CodeInvalidArgument, "unknown account kind")
+
+// Custom is implemented by accounts of a kind registered with
+// RegisterAccountType by a package outside models. They embed Account, which
+// keeps their ledger, and open with Bank.Open like any other account.
+type Custom interface {
+	BankAccount
+	// AccountKind names the kind, as returned by KindOf.
+	AccountKind() string
+	// MarshalState returns the state of the account that is not in its
+	// ledger, such as its terms, as JSON for the snapshot and the stores
+	// to save.
+	MarshalState() ([]byte, error)
+}
+
+// RestoreFunc rebuilds an account of a registered kind from its ledger and
+// the state MarshalState returned, which is empty if it returned none.
+type RestoreFunc func(ledger Account, state []byte) (Custom, error)
+
+// Accruer is implemented by custom accounts that accrue, such as interest
+// or rewards. Bank.ApplyInterest posts the entries Accrue returns for at.
+type Accruer interface {
+	Accrue(at time.Time) []Transaction
+}
+
+// FeeAssessor is implemented by custom accounts that charge fees of their
+// own. Bank.AssessFees posts the entries AssessFees returns for at, which
+// must leave out fees already in the ledger.
+type FeeAssessor interface {
+	AssessFees(at time.Time) []Transaction
+}
+
+// StatementLiner is implemented by custom accounts that add lines to their
+// statements, such as points earned in the period.
+type StatementLiner interface {
+	StatementLines(period Period) []StatementLine
+}
+
+// StatementLine is a line of a statement beyond its entries.
+type StatementLine struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+var accountTypes = struct {
+	sync.RWMutex
+	restore map[string]RestoreFunc
+}{restore: make(map[string]RestoreFunc)}
+
+// builtinKinds are the kinds KindOf returns for the accounts of models.
+var builtinKinds = map[string]bool{"Account": true, "Savings": true, "Checking": true, "Loan": true, "CreditCard": true}
+
+// RegisterAccountType makes a custom kind of account known to the snapshot
+// and the stores, which save its ledger and state and call restore to load
+// it. Packages of account types register them when initialized; a kind
+// registered twice, or named like a built-in one, panics.
+func RegisterAccountType(kind string, restore RestoreFunc) {
+	accountTypes.Lock()
+	defer accountTypes.Unlock()
+	if _, ok := accountTypes.restore[kind]; ok || builtinKinds[kind] || kind == "" {
+		panic("models: account kind " + kind + " registered twice")
+	}
+	accountTypes.restore[kind] = restore
+}
+
+// AccountKinds lists the custom kinds registered, in order.
+func AccountKinds() []string {
+	accountTypes.RLock()
+	defer accountTypes.RUnlock()
+	kinds := make([]string, 0, len(accountTypes.restore))
+	for kind := range accountTypes.restore {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// RestoreCustom rebuilds an account of a registered custom kind.
+func RestoreCustom(kind string, ledger Account, state []byte) (Custom, error) {
+	accountTypes.RLock()
+	restore, ok := accountTypes.restore[kind]
+	accountTypes.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAccountKind, kind)
+	}
+	account, err := restore(ledger, state)
+	if err != nil {
+		return nil, fmt.Errorf("account %s of kind %s: %w", ledger.AccountNumber, kind, err)
+	}
+	return account, nil
+}
+
+// asEntry is a TxOption that posts an entry a hook returned as it is, at
+// the time the bank stamps unless the entry has its own.
+func asEntry(entry Transaction) TxOption {
+	return func(tx *Transaction) {
+		at := tx.Time
+		*tx = entry
+		if tx.Time.IsZero() {
+			tx.Time = at
+		}
+	}
+}
+
+// postEntries posts the entries a hook returned for account, stopping at
+// the first that fails, and returns the events to publish. The caller holds
+// the account's lock or b.mu.
+func (b *Bank) postEntries(account BankAccount, entries []Transaction) ([]Event, error) {
+	var events []Event
+	for _, entry := range entries {
+		tx, posted, err := b.postLocked(account, entry.Type, entry.Amount, []TxOption{asEntry(entry)})
+		events = append(events, posted...)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, Event{Type: EventTransactionPosted, AccountNumber: account.Number(), Transaction: &tx, Time: tx.Time})
+	}
+	return events, nil
+}
+
+// accrue posts what an Accruer accrued.
+func (b *Bank) accrue(account BankAccount, accruer Accruer) error {
+	account, unlock, err := b.lockAccount(account.Number())
+	if err != nil {
+		return err
+	}
+	events, err := b.postEntries(account, accruer.Accrue(b.now()))
+	unlock()
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	return err
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrInvalidAlertRule  = banking.New(banking.CodeInvalidArgument, "invalid alert rule")
+	ErrAlertRuleNotFound = banking.New(banking.CodeNotFound, "alert rule not found")
+)
+
+type AlertKind string
+
+const (
+	// AlertLowBalance fires when the balance drops below Threshold.
+	AlertLowBalance AlertKind = "low_balance"
+	// AlertLargeTransaction fires for a single withdrawal above Threshold.
+	AlertLargeTransaction AlertKind = "large_transaction"
+	// AlertDailySpend fires when the withdrawals of one day add up to more
+	// than Threshold.
+	AlertDailySpend AlertKind = "daily_spend"
+)
+
+type AlertRule struct {
+	ID            string    `json:"id"`
+	AccountNumber string    `json:"account"`
+	Kind          AlertKind `json:"kind"`
+	Threshold     float64   `json:"threshold"`
+}
+
+func (r AlertRule) validate() error {
+	switch {
+	case r.AccountNumber == "":
+		return fmt.Errorf("%w: account is required", ErrInvalidAlertRule)
+	case r.Kind != AlertLowBalance && r.Kind != AlertLargeTransaction && r.Kind != AlertDailySpend:
+		return fmt.Errorf("%w: unknown kind %q, want low_balance, large_transaction or daily_spend", ErrInvalidAlertRule, r.Kind)
+	case r.Threshold < 0 || math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0):
+		return fmt.Errorf("%w: threshold must be a number not below 0", ErrInvalidAlertRule)
+	}
+	return nil
+}
+
+type Alert struct {
+	Rule    AlertRule `json:"rule"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// QuietHours is a daily window, in whole hours of the transaction's local
+// time, during which alerts are held back instead of delivered. The window
+// may wrap around midnight, e.g. 22 to 7. Start == End disables it.
+type QuietHours struct {
+	Start int
+	End   int
+}
+
+// Valid reports whether both ends are hours of the day.
+func (q QuietHours) Valid() bool {
+	return q.Start >= 0 && q.Start < 24 && q.End >= 0 && q.End < 24
+}
+
+func (q QuietHours) contains(t time.Time) bool {
+	if q.Start == q.End {
+		return false
+	}
+	h := t.Hour()
+	if q.Start < q.End {
+		return h >= q.Start && h < q.End
+	}
+	return h >= q.Start || h < q.End
+}
+
+// Alerts evaluates alert rules against the events of a bank and hands the
+// resulting alerts to a notify callback. A low-balance alert fires once until the
+// balance recovers and a daily-spend alert once per day, so a burst of
+// transactions does not flood the customer.
+type Alerts struct {
+	bank   *Bank
+	notify func(Alert)
+
+	mu      sync.Mutex
+	rules   []AlertRule
+	nextID  int
+	quiet   QuietHours
+	active  map[string]string
+	pending []Alert
+}
+
+// NewAlerts subscribes a rules engine to the events of bank.
+func NewAlerts(bank *Bank, notify func(Alert)) *Alerts {
+	a := &Alerts{bank: bank, notify: notify, active: make(map[string]string)}
+	bank.Events.Subscribe(a.handle)
+	return a
+}
+
+// AddRule registers rule and returns its ID, assigning one if it has none.
+func (a *Alerts) AddRule(rule AlertRule) (string, error) {
+	if err := rule.validate(); err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if rule.ID == "" {
+		a.nextID++
+		rule.ID = fmt.Sprintf("rule-%d", a.nextID)
+	}
+	a.rules = append(a.rules, rule)
+	return rule.ID, nil
+}
+
+// RemoveRule removes the rule with an ID from an account.
+func (a *Alerts) RemoveRule(number, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, rule := range a.rules {
+		if rule.ID == id && rule.AccountNumber == number {
+			a.rules = append(a.rules[:i], a.rules[i+1:]...)
+			delete(a.active, id)
+			return nil
+		}
+	}
+	return ErrAlertRuleNotFound
+}
+
+func (a *Alerts) Rules(number string) []AlertRule {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var rules []AlertRule
+	for _, rule := range a.rules {
+		if rule.AccountNumber == number {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func (a *Alerts) SetQuietHours(q QuietHours) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.quiet = q
+}
+
+// Flush delivers the alerts held back during quiet hours if now is outside
+// them. It is also called on every event, so held alerts go out with the
+// first activity after the quiet window.
+func (a *Alerts) Flush(now time.Time) {
+	a.mu.Lock()
+	if a.quiet.contains(now) {
+		a.mu.Unlock()
+		return
+	}
+	pending := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	for _, alert := range pending {
+		a.notify(alert)
+	}
+}
+
+func (a *Alerts) handle(e Event) {
+	if e.Type == EventAccountRenumbered {
+		a.renumber(e.AccountNumber)
+		return
+	}
+	if e.Type != EventTransactionPosted || e.Transaction == nil {
+		return
+	}
+	balance, err := a.bank.Balance(e.AccountNumber)
+	if err != nil {
+		return
+	}
+	history, err := a.bank.History(e.AccountNumber)
+	if err != nil {
+		return
+	}
+	tx := *e.Transaction
+
+	a.mu.Lock()
+	var fired []Alert
+	for _, rule := range a.rules {
+		if rule.AccountNumber != e.AccountNumber {
+			continue
+		}
+		if alert, ok := a.evaluate(rule, history, balance, tx); ok {
+			fired = append(fired, alert)
+		}
+	}
+	if a.quiet.contains(tx.Time) {
+		a.pending = append(a.pending, fired...)
+		fired = nil
+	}
+	a.mu.Unlock()
+
+	a.Flush(tx.Time)
+	for _, alert := range fired {
+		a.notify(alert)
+	}
+}
+
+// renumber moves the rules of a renumbered account to its new number.
+func (a *Alerts) renumber(from string) {
+	r, ok := a.bank.Redirect(from)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.rules {
+		if a.rules[i].AccountNumber == from {
+			a.rules[i].AccountNumber = r.To
+		}
+	}
+}
+
+// evaluate reports whether rule fires for tx. It must be called with a.mu
+// held since it updates the deduplication state.
+func (a *Alerts) evaluate(rule AlertRule, history []Transaction, balance float64, tx Transaction) (Alert, bool) {
+	alert := Alert{Rule: rule, Time: tx.Time}
+	switch rule.Kind {
+	case AlertLowBalance:
+		if balance >= rule.Threshold {
+			delete(a.active, rule.ID)
+			return alert, false
+		}
+		if _, ok := a.active[rule.ID]; ok {
+			return alert, false
+		}
+		a.active[rule.ID] = ""
+		alert.Message = fmt.Sprintf("Balance of account %s is %.2f, below %.2f", rule.AccountNumber, balance, rule.Threshold)
+		return alert, true
+
+	case AlertLargeTransaction:
+		if tx.Type != TransactionWithdrawal || tx.Amount <= rule.Threshold {
+			return alert, false
+		}
+		alert.Message = fmt.Sprintf("Withdrawal of %.2f from account %s is above %.2f", tx.Amount, rule.AccountNumber, rule.Threshold)
+		return alert, true
+
+	case AlertDailySpend:
+		if tx.Type != TransactionWithdrawal {
+			return alert, false
+		}
+		today := tx.Time.Format(time.DateOnly)
+		if a.active[rule.ID] == today {
+			return alert, false
+		}
+		var spent float64
+		for _, prior := range history {
+			if prior.Type == TransactionWithdrawal && prior.Time.In(tx.Time.Location()).Format(time.DateOnly) == today {
+				spent += prior.Amount
+			}
+		}
+		if spent <= rule.Threshold {
+			return alert, false
+		}
+		a.active[rule.ID] = today
+		alert.Message = fmt.Sprintf("Spending on account %s today is %.2f, above %.2f", rule.AccountNumber, spent, rule.Threshold)
+		return alert, true
+	}
+	return alert, false
+}
+
+// NotifyAlert puts an alert in the inboxes of the customers holding its
+// account. It is the notify callback bankserver gives NewAlerts.
+func (b *Bank) NotifyAlert(alert Alert) {
+	number := alert.Rule.AccountNumber
+	b.mu.RLock()
+	owners := b.ownersLocked(number)
+	b.mu.RUnlock()
+	for _, c := range owners {
+		b.SendMessage(Message{Customer: c.ID, Kind: MessageAlert, Account: number,
+			Subject: fmt.Sprintf("Alert on account %s", number), Body: alert.Message,
+			Key: fmt.Sprintf("alert:%s:%s", alert.Rule.ID, alert.Time.Format(time.RFC3339Nano))})
+	}
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestAlerts runs withdrawals and deposits past the thresholds of each kind
+// of rule, checking that a low balance alerts once until the balance
+// recovers, daily spending once a day, and that alerts fired in the quiet
+// hours wait for them to end and then reach the holder's inbox.
+func TestAlerts(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	var fired []AlertKind
+	alerts := NewAlerts(b, func(alert Alert) {
+		fired = append(fired, alert.Rule.Kind)
+		b.NotifyAlert(alert)
+	})
+	alerts.SetQuietHours(QuietHours{Start: 22, End: 7})
+	for _, rule := range []AlertRule{
+		{AccountNumber: "C1", Kind: AlertLowBalance, Threshold: 100},
+		{AccountNumber: "C1", Kind: AlertLargeTransaction, Threshold: 300},
+		{AccountNumber: "C1", Kind: AlertDailySpend, Threshold: 500},
+	} {
+		if _, err := alerts.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := date(t, "2026-03-02")
+	clock.now = day.Add(9 * time.Hour)
+	if err := b.Deposit("C1", 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		at       time.Duration
+		withdraw float64
+		deposit  float64
+		want     []AlertKind
+	}{
+		{at: 10 * time.Hour, withdraw: 200},
+		{at: 11 * time.Hour, withdraw: 350, want: []AlertKind{AlertLargeTransaction, AlertDailySpend}},
+		// The day's spending alerted already.
+		{at: 12 * time.Hour, withdraw: 400, want: []AlertKind{AlertLowBalance, AlertLargeTransaction}},
+		// So did the low balance, until it recovers.
+		{at: 13 * time.Hour, withdraw: 10},
+		{at: 14 * time.Hour, deposit: 500},
+		// Quiet hours hold these back until the first activity after 7.
+		{at: 23 * time.Hour, withdraw: 460},
+		{at: 30 * time.Hour, deposit: 5},
+		{at: 31 * time.Hour, deposit: 5, want: []AlertKind{AlertLowBalance, AlertLargeTransaction}},
+	} {
+		fired = nil
+		clock.now = day.Add(tt.at)
+		var err error
+		if tt.withdraw > 0 {
+			err = b.Withdraw("C1", tt.withdraw)
+		} else {
+			err = b.Deposit("C1", tt.deposit)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(fired, tt.want) {
+			t.Errorf("%s: alerts %v, want %v", clock.now.Format(time.DateTime), fired, tt.want)
+		}
+	}
+
+	messages, _, err := b.Messages("c1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 6 || messages[0].Kind != MessageAlert || messages[0].Account != "C1" {
+		t.Errorf("inbox = %+v, want 6 alerts about C1", messages)
+	}
+}
+
+// TestAlertRules checks that invalid rules are refused and that rules are
+// removed only from their own account.
+func TestAlertRules(t *testing.T) {
+	b := NewBank()
+	alerts := NewAlerts(b, func(Alert) {})
+	for _, rule := range []AlertRule{
+		{Kind: AlertLowBalance, Threshold: 100},
+		{AccountNumber: "C1", Kind: "birthday"},
+		{AccountNumber: "C1", Kind: AlertDailySpend, Threshold: -1},
+	} {
+		if _, err := alerts.AddRule(rule); !errors.Is(err, ErrInvalidAlertRule) {
+			t.Errorf("adding %+v: %v, want ErrInvalidAlertRule", rule, err)
+		}
+	}
+	id, err := alerts.AddRule(AlertRule{AccountNumber: "C1", Kind: AlertLowBalance, Threshold: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alerts.RemoveRule("C2", id); !errors.Is(err, ErrAlertRuleNotFound) {
+		t.Errorf("removing from another account: %v, want ErrAlertRuleNotFound", err)
+	}
+	if err := alerts.RemoveRule("C1", id); err != nil || len(alerts.Rules("C1")) != 0 {
+		t.Errorf("removing: %v, leaving %+v", err, alerts.Rules("C1"))
+	}
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrInvalidAlias   = banking.New(banking.CodeInvalidArgument, "invalid account nickname")
+	ErrAliasTaken     = banking.New(banking.CodeConflict, "nickname already used by another account")
+	ErrAmbiguousAlias = banking.New(banking.CodeInvalidArgument, "nickname names accounts of several customers")
+)
+
+// maxNickname is the longest nickname, in characters.
+const maxNickname = 40
+
+// Alias is how a customer names an account, such as "Rent account", and how
+// it is shown to them. Nicknames are unique among a customer's accounts,
+// ignoring case.
+type Alias struct {
+	Nickname string `json:"nickname"`
+	Color    string `json:"color,omitempty"`
+	Emoji    string `json:"emoji,omitempty"`
+}
+
+// SetAlias names an account. An alias without a nickname removes it.
+func (b *Bank) SetAlias(number string, alias Alias) error {
+	alias.Nickname = strings.TrimSpace(alias.Nickname)
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if alias.Nickname == "" {
+		delete(b.aliases, number)
+		return nil
+	}
+	switch {
+	case utf8.RuneCountInString(alias.Nickname) > maxNickname:
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidAlias, maxNickname)
+	case strings.Contains(alias.Nickname, ":"):
+		return fmt.Errorf("%w: %q contains a colon", ErrInvalidAlias, alias.Nickname)
+	case b.accounts.account(alias.Nickname) != nil:
+		return fmt.Errorf("%w: %q is an account number", ErrInvalidAlias, alias.Nickname)
+	case utf8.RuneCountInString(alias.Emoji) > 4:
+		return fmt.Errorf("%w: emoji %q is not a single emoji", ErrInvalidAlias, alias.Emoji)
+	}
+	for _, customer := range b.ownersLocked(number) {
+		for _, other := range customer.Accounts {
+			if other != number && strings.EqualFold(b.aliases[other].Nickname, alias.Nickname) {
+				return fmt.Errorf("%w: %s of customer %s is %q", ErrAliasTaken, other, customer.ID, b.aliases[other].Nickname)
+			}
+		}
+	}
+	b.aliases[number] = alias
+	return nil
+}
+
+// Alias returns the alias of an account, the zero Alias when it has none.
+func (b *Bank) Alias(number string) (Alias, error) {
+	if _, err := b.Account(number); err != nil {
+		return Alias{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.aliases[number], nil
+}
+
+// Resolve returns the number of the account ref names: an account number,
+// a nickname, or a nickname qualified by its customer as
+// "customer:nickname". An unqualified nickname must name a single account
+// across customers. The old number of a renumbered account names it by its
+// new one.
+func (b *Bank) Resolve(ref string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.accounts.account(ref) != nil {
+		return ref, nil
+	}
+	if _, ok := b.archived[ref]; ok {
+		return ref, nil
+	}
+	if r, ok := b.redirects[ref]; ok {
+		return r.To, nil
+	}
+	var candidates []string
+	if customerID, nickname, ok := strings.Cut(ref, ":"); ok {
+		customer := b.customers[customerID]
+		if customer == nil {
+			return "", ErrCustomerNotFound
+		}
+		candidates = customer.Accounts
+		ref = nickname
+	} else {
+		for number := range b.aliases {
+			candidates = append(candidates, number)
+		}
+	}
+	var found []string
+	for _, number := range candidates {
+		if alias, ok := b.aliases[number]; ok && strings.EqualFold(alias.Nickname, strings.TrimSpace(ref)) {
+			found = append(found, number)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", ErrAccountNotFound
+	case 1:
+		return found[0], nil
+	default:
+		sort.Strings(found)
+		return "", fmt.Errorf("%w: %q is %s; qualify it as customer:nickname", ErrAmbiguousAlias, ref, strings.Join(found, ", "))
+	}
+}
+
+// ownersLocked returns the customers that own an account. The caller holds
+// b.mu.
+func (b *Bank) ownersLocked(number string) []*Customer {
+	var owners []*Customer
+	for _, customer := range b.customers {
+		for _, n := range customer.Accounts {
+			if n == number {
+				owners = append(owners, customer)
+				break
+			}
+		}
+	}
+	return owners
+}
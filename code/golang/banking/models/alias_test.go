@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestResolveAlias names accounts of two customers and checks what
+// numbers, nicknames in any case and qualified nicknames resolve to, that
+// a nickname both customers use must be qualified, and the nicknames that
+// are refused.
+func TestResolveAlias(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	for _, number := range []string{"C1", "C2", "C3"} {
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: number}})
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1", "C2"}})
+	b.AddCustomer(&Customer{ID: "c2", Name: "Grace", Accounts: []string{"C3"}})
+	for number, nickname := range map[string]string{"C1": "Rent", "C2": " Holidays ", "C3": "rent"} {
+		if err := b.SetAlias(number, Alias{Nickname: nickname}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		ref  string
+		want string
+		err  error
+	}{
+		{ref: "C2", want: "C2"},
+		{ref: "holidays", want: "C2"},
+		{ref: "c1:RENT", want: "C1"},
+		{ref: "c2:rent", want: "C3"},
+		{ref: "rent", err: ErrAmbiguousAlias},
+		{ref: "c1:savings", err: ErrAccountNotFound},
+		{ref: "c9:rent", err: ErrCustomerNotFound},
+	} {
+		number, err := b.Resolve(tt.ref)
+		if number != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Resolve(%q) = %q, %v, want %q, %v", tt.ref, number, err, tt.want, tt.err)
+		}
+	}
+
+	for _, tt := range []struct {
+		number string
+		alias  Alias
+		err    error
+	}{
+		{"C2", Alias{Nickname: "RENT"}, ErrAliasTaken},
+		{"C2", Alias{Nickname: "C3"}, ErrInvalidAlias},
+		{"C2", Alias{Nickname: "a:b"}, ErrInvalidAlias},
+		{"C2", Alias{Nickname: strings.Repeat("x", maxNickname+1)}, ErrInvalidAlias},
+		{"C9", Alias{Nickname: "spare"}, ErrAccountNotFound},
+	} {
+		if err := b.SetAlias(tt.number, tt.alias); !errors.Is(err, tt.err) {
+			t.Errorf("naming %s %q: %v, want %v", tt.number, tt.alias.Nickname, err, tt.err)
+		}
+	}
+	if err := b.SetAlias("C1", Alias{}); err != nil {
+		t.Fatal(err)
+	}
+	if number, err := b.Resolve("rent"); err != nil || number != "C3" {
+		t.Errorf("once C1 lost its nickname, rent resolves to %q, %v, want C3", number, err)
+	}
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// Component is a part of what is owed on a loan or card.
+type Component string
+
+const (
+	ComponentFees      Component = "fees"
+	ComponentInterest  Component = "interest"
+	ComponentPrincipal Component = "principal"
+)
+
+// AllocationOrder is the order in which a payment pays off the components
+// of a debt. Whatever is left once all of them are paid goes to principal.
+type AllocationOrder []Component
+
+// DefaultAllocation is the order each product, named as by KindOf, starts
+// with: loans pay interest before fees, cards clear fees first.
+var DefaultAllocation = map[string]AllocationOrder{
+	"Loan":       {ComponentInterest, ComponentFees, ComponentPrincipal},
+	"CreditCard": {ComponentFees, ComponentInterest, ComponentPrincipal},
+}
+
+var ErrInvalidAllocation = banking.New(banking.CodeInvalidArgument, "invalid allocation order")
+
+// ParseAllocationOrder parses the names of the components of an order. Each
+// of fees, interest and principal must appear exactly once.
+func ParseAllocationOrder(names []string) (AllocationOrder, error) {
+	seen := make(map[Component]bool)
+	var order AllocationOrder
+	for _, name := range names {
+		c := Component(strings.ToLower(strings.TrimSpace(name)))
+		switch {
+		case c != ComponentFees && c != ComponentInterest && c != ComponentPrincipal:
+			return nil, fmt.Errorf("%w: unknown component %q", ErrInvalidAllocation, name)
+		case seen[c]:
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidAllocation, c)
+		}
+		seen[c] = true
+		order = append(order, c)
+	}
+	if len(order) != 3 {
+		return nil, fmt.Errorf("%w: want fees, interest and principal, got %v", ErrInvalidAllocation, names)
+	}
+	return order, nil
+}
+
+// allocationKey is the metadata key that names the component a payment leg
+// went to.
+const allocationKey = "allocation"
+
+// Outstanding is what is owed on a loan or card, by component.
+type Outstanding struct {
+	Fees      float64
+	Interest  float64
+	Principal float64
+}
+
+// outstanding works out the components from the ledger: fees and interest
+// charged less the payment legs allocated to them, and the rest of what is
+// owed as principal. Payments posted without an allocation count as
+// principal.
+func outstanding(account BankAccount) Outstanding {
+	return outstandingOf(account.History(), account.CheckBalance(), CurrencyOf(account))
+}
+
+// outstandingOf is outstanding for a ledger and the balance it left, in
+// currency.
+func outstandingOf(history []Transaction, balance float64, currency string) Outstanding {
+	var o Outstanding
+	for _, tx := range history {
+		switch {
+		case tx.Type == TransactionFee:
+			o.Fees += tx.Amount
+		case tx.Type == TransactionInterestCharge:
+			o.Interest += tx.Amount
+		case tx.Type == TransactionDeposit && tx.Metadata[allocationKey] == string(ComponentFees):
+			o.Fees -= tx.Amount
+		case tx.Type == TransactionDeposit && tx.Metadata[allocationKey] == string(ComponentInterest):
+			o.Interest -= tx.Amount
+		}
+	}
+	owed := math.Max(-balance, 0)
+	o.Fees = money.Round(math.Min(math.Max(o.Fees, 0), owed), currency, money.HalfEven)
+	o.Interest = money.Round(math.Min(math.Max(o.Interest, 0), owed-o.Fees), currency, money.HalfEven)
+	o.Principal = money.Round(owed-o.Fees-o.Interest, currency, money.HalfEven)
+	return o
+}
+
+func (o Outstanding) of(c Component) float64 {
+	switch c {
+	case ComponentFees:
+		return o.Fees
+	case ComponentInterest:
+		return o.Interest
+	default:
+		return o.Principal
+	}
+}
+
+// split divides a payment of amount into one leg per component it pays,
+// in order. What is left over once every component is paid, such as a
+// payment that takes a card into credit, goes to principal, so the legs add
+// up to amount exactly.
+func (order AllocationOrder) split(amount float64, owed Outstanding) []allocationLeg {
+	var legs []allocationLeg
+	principal := -1
+	left := amount
+	for _, c := range order {
+		part := math.Min(left, owed.of(c))
+		if part <= 0 {
+			continue
+		}
+		if c == ComponentPrincipal {
+			principal = len(legs)
+		}
+		legs = append(legs, allocationLeg{c, part})
+		left -= part
+	}
+	switch {
+	case left <= 1e-9:
+	case principal >= 0:
+		legs[principal].amount += left
+	default:
+		legs = append(legs, allocationLeg{ComponentPrincipal, left})
+	}
+	return legs
+}
+
+type allocationLeg struct {
+	component Component
+	amount    float64
+}
+
+// Outstanding returns what is owed on a loan or card by component.
+func (b *Bank) Outstanding(number string) (Outstanding, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return Outstanding{}, err
+	}
+	if _, ok := account.(creditLine); !ok {
+		return Outstanding{}, ErrNoSchedule
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return outstanding(account), nil
+}
+
+// postAllocated posts a payment into a loan or card as one deposit per
+// component under the product's allocation order, each leg marked with the
+// component it paid. It returns the last leg and the events of the others,
+// and undoes every leg if one fails. The caller holds b.mu.
+func (b *Bank) postAllocated(account BankAccount, tx Transaction, order AllocationOrder) (Transaction, []Event, error) {
+	legs := order.split(tx.Amount, outstanding(account))
+	if len(legs) == 0 {
+		legs = []allocationLeg{{ComponentPrincipal, tx.Amount}}
+	}
+	var undo ledgerSnapshot
+	if l, ok := account.(ledgered); ok {
+		a := l.ledger()
+		undo = ledgerSnapshot{a: {a.Balance, len(a.Transactions)}}
+	}
+	var events []Event
+	var last Transaction
+	for i, leg := range legs {
+		entry := tx
+		entry.Amount = leg.amount
+		entry.Metadata = make(map[string]string, len(tx.Metadata)+1)
+		for k, v := range tx.Metadata {
+			entry.Metadata[k] = v
+		}
+		entry.Metadata[allocationKey] = string(leg.component)
+		if err := account.Post(entry); err != nil {
+			undo.restore()
+			return tx, nil, err
+		}
+		history := account.History()
+		last = history[len(history)-1]
+		if i < len(legs)-1 {
+			posted := last
+			events = append(events, Event{Type: EventTransactionPosted, AccountNumber: account.Number(), Transaction: &posted, Time: posted.Time})
+		}
+	}
+	return last, events, nil
+}
+
+// allocation returns the order payments into account follow. The caller
+// holds b.mu.
+func (b *Bank) allocation(account BankAccount) AllocationOrder {
+	kind := KindOf(account)
+	if order, ok := b.Allocation[kind]; ok {
+		return order
+	}
+	return DefaultAllocation[kind]
+}
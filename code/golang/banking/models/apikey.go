@@ -0,0 +1,239 @@
+package models
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+)
+
+// APIKeyPrefix starts every API key, to tell them from other tokens, such
+// as for secret scanners.
+const APIKeyPrefix = "bk_"
+
+// DefaultRotationGrace is how long a rotated API key keeps working, for its
+// integration to switch to the new one, unless RotateAPIKey is given
+// another grace.
+const DefaultRotationGrace = 24 * time.Hour
+
+// apiKeyUsageDays is how many days of daily request counts a key keeps.
+const apiKeyUsageDays = 30
+
+var (
+	ErrAPIKeyNotFound = banking.New(banking.CodeNotFound, "API key not found")
+	ErrAPIKeyInvalid  = banking.New(banking.CodeUnauthenticated, "API key expired, revoked or unknown")
+	ErrInvalidAPIKey  = banking.New(banking.CodeInvalidArgument, "invalid API key")
+)
+
+// APIKey is a key a third party, such as an accounting integration, calls
+// the API with for the bank. Scope is what it may do: view only reads,
+// transact also moves money and admin may do anything. RateLimit, in
+// requests a second, and RateBurst replace the server's rate limit for the
+// key when set. Client names the third party the key is for, which
+// customers give consents to, see GiveConsent; keys rotated keep it. A key
+// ends at Expires, which rotating it sets, or when it is Revoked.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Client    string     `json:"client,omitempty"`
+	Scope     Permission `json:"scope"`
+	RateLimit float64    `json:"rate_limit,omitempty"`
+	RateBurst int        `json:"rate_burst,omitempty"`
+	Created   time.Time  `json:"created"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	Revoked   *time.Time `json:"revoked,omitempty"`
+	// RotatedTo is the ID of the key that replaced this one.
+	RotatedTo string      `json:"rotated_to,omitempty"`
+	Usage     APIKeyUsage `json:"usage"`
+	// token is the SHA-256 of the key; the key itself is only given out
+	// when it is issued.
+	token [sha256.Size]byte
+}
+
+// APIKeyUsage counts the requests made with a key: how many, how many
+// failed and how many of those its rate limit refused, and how many a day
+// over the last apiKeyUsageDays days, by date such as 2024-01-31.
+type APIKeyUsage struct {
+	Requests int64            `json:"requests"`
+	Failed   int64            `json:"failed"`
+	Limited  int64            `json:"limited"`
+	LastUsed *time.Time       `json:"last_used,omitempty"`
+	LastIP   string           `json:"last_ip,omitempty"`
+	Daily    map[string]int64 `json:"daily,omitempty"`
+}
+
+// active reports whether the key may be used at t.
+func (k *APIKey) active(t time.Time) bool {
+	return k.Revoked == nil && (k.Expires == nil || t.Before(*k.Expires))
+}
+
+func (k *APIKey) clone() APIKey {
+	c := *k
+	if k.Usage.Daily != nil {
+		c.Usage.Daily = make(map[string]int64, len(k.Usage.Daily))
+		for day, n := range k.Usage.Daily {
+			c.Usage.Daily[day] = n
+		}
+	}
+	return c
+}
+
+// IssueAPIKey issues a key with the name, client, scope and rate limit of k and
+// returns it and the key itself, which requests present as a bearer token.
+func (b *Bank) IssueAPIKey(k APIKey) (APIKey, string, error) {
+	if k.Name == "" {
+		return APIKey{}, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	if _, ok := permissionRanks[k.Scope]; !ok {
+		return APIKey{}, "", fmt.Errorf("%w: scope %q is not view, transact or admin", ErrInvalidAPIKey, k.Scope)
+	}
+	if k.RateLimit < 0 || k.RateLimit > 0 && k.RateBurst < 1 {
+		return APIKey{}, "", fmt.Errorf("%w: rate limit must not be negative, and needs a burst of at least 1", ErrInvalidAPIKey)
+	}
+	b.mu.Lock()
+	issued, secret, err := b.issueAPIKeyLocked(k)
+	b.mu.Unlock()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	b.Events.Publish(Event{Type: EventAPIKeyIssued, Time: issued.Created,
+		Message: fmt.Sprintf("API key %s (%s) issued with %s scope", issued.ID, issued.Name, issued.Scope)})
+	return issued, secret, nil
+}
+
+// issueAPIKeyLocked issues a key like k. The caller holds b.mu.
+func (b *Bank) issueAPIKeyLocked(k APIKey) (APIKey, string, error) {
+	secret, err := newToken()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secret = APIKeyPrefix + secret
+	key := &APIKey{ID: b.IDs.NewID(), Name: k.Name, Client: k.Client, Scope: k.Scope, RateLimit: k.RateLimit, RateBurst: k.RateBurst,
+		Created: b.now(), token: sha256.Sum256([]byte(secret))}
+	b.apiKeys[key.ID] = key
+	b.apiKeyIDs[key.token] = key.ID
+	return key.clone(), secret, nil
+}
+
+// APIKeyByToken returns the active key of a token.
+func (b *Bank) APIKeyByToken(token string) (APIKey, error) {
+	now := b.now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	k, ok := b.apiKeys[b.apiKeyIDs[sha256.Sum256([]byte(token))]]
+	if !ok || !k.active(now) {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	return k.clone(), nil
+}
+
+// APIKey returns a key and its usage.
+func (b *Bank) APIKey(id string) (APIKey, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	k, ok := b.apiKeys[id]
+	if !ok {
+		return APIKey{}, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	return k.clone(), nil
+}
+
+// APIKeys returns every key issued, revoked and expired ones included, the
+// newest first.
+func (b *Bank) APIKeys() []APIKey {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	keys := make([]APIKey, 0, len(b.apiKeys))
+	for _, k := range b.apiKeys {
+		keys = append(keys, k.clone())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].Created.Equal(keys[j].Created) {
+			return keys[i].Created.After(keys[j].Created)
+		}
+		return keys[i].ID > keys[j].ID
+	})
+	return keys
+}
+
+// RotateAPIKey replaces a key with a new one of the same name, client,
+// scope and rate limit, and returns it and the key itself. The old key keeps working
+// for grace, DefaultRotationGrace when zero, for its integration to switch.
+func (b *Bank) RotateAPIKey(id string, grace time.Duration) (APIKey, string, error) {
+	if grace < 0 {
+		return APIKey{}, "", fmt.Errorf("%w: grace must not be negative", ErrInvalidAPIKey)
+	}
+	if grace == 0 {
+		grace = DefaultRotationGrace
+	}
+	now := b.now()
+	b.mu.Lock()
+	old, ok := b.apiKeys[id]
+	if !ok || !old.active(now) {
+		b.mu.Unlock()
+		return APIKey{}, "", fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	issued, secret, err := b.issueAPIKeyLocked(*old)
+	if err != nil {
+		b.mu.Unlock()
+		return APIKey{}, "", err
+	}
+	if expires := now.Add(grace); old.Expires == nil || expires.Before(*old.Expires) {
+		old.Expires = &expires
+	}
+	old.RotatedTo = issued.ID
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventAPIKeyIssued, Time: now,
+		Message: fmt.Sprintf("API key %s (%s) rotated to %s", id, issued.Name, issued.ID)})
+	return issued, secret, nil
+}
+
+// RevokeAPIKey ends a key at once, such as one that leaked.
+func (b *Bank) RevokeAPIKey(id string) (APIKey, error) {
+	now := b.now()
+	b.mu.Lock()
+	k, ok := b.apiKeys[id]
+	if !ok || k.Revoked != nil {
+		b.mu.Unlock()
+		return APIKey{}, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	k.Revoked = &now
+	revoked := k.clone()
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventAPIKeyRevoked, Time: now,
+		Message: fmt.Sprintf("API key %s (%s) revoked", id, revoked.Name)})
+	return revoked, nil
+}
+
+// RecordAPIKeyUse counts a request made with a key from ip, and whether it
+// failed, or was limited by the key's rate limit.
+func (b *Bank) RecordAPIKeyUse(id, ip string, failed, limited bool) {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k, ok := b.apiKeys[id]
+	if !ok {
+		return
+	}
+	u := &k.Usage
+	u.Requests++
+	if failed || limited {
+		u.Failed++
+	}
+	if limited {
+		u.Limited++
+	}
+	u.LastUsed, u.LastIP = &now, ip
+	if u.Daily == nil {
+		u.Daily = make(map[string]int64)
+	}
+	u.Daily[now.Format(time.DateOnly)]++
+	oldest := now.AddDate(0, 0, -apiKeyUsageDays+1).Format(time.DateOnly)
+	for day := range u.Daily {
+		if day < oldest {
+			delete(u.Daily, day)
+		}
+	}
+}
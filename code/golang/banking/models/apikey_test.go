@@ -0,0 +1,75 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestAPIKeys issues a key, checks it is found by its secret through a
+// snapshot and counts its use, that a rotated key works until its grace
+// ends and that a revoked one stops at once.
+func TestAPIKeys(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	if _, _, err := b.IssueAPIKey(APIKey{Name: "ledger sync", Scope: "owner"}); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("unknown scope: %v, want %v", err, ErrInvalidAPIKey)
+	}
+	if _, _, err := b.IssueAPIKey(APIKey{Name: "ledger sync", Scope: PermissionView, RateLimit: 5}); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("rate limit without a burst: %v, want %v", err, ErrInvalidAPIKey)
+	}
+	key, secret, err := b.IssueAPIKey(APIKey{Name: "ledger sync", Scope: PermissionTransact, RateLimit: 5, RateBurst: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.RecordAPIKeyUse(key.ID, "10.0.0.1", false, false)
+	b.RecordAPIKeyUse(key.ID, "10.0.0.1", true, true)
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	restored.Clock = clock
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	found, err := restored.APIKeyByToken(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := found.Usage; found.ID != key.ID || found.Scope != PermissionTransact || u.Requests != 2 || u.Failed != 1 || u.Limited != 1 || u.Daily["2026-01-01"] != 2 {
+		t.Errorf("key of the secret %+v", found)
+	}
+
+	// Keys issued at once are listed by ID, which is not in order across
+	// banks.
+	clock.now = clock.now.Add(time.Minute)
+	rotated, rotatedSecret, err := restored.RotateAPIKey(key.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Scope != key.Scope || rotated.RateLimit != key.RateLimit || rotatedSecret == secret {
+		t.Errorf("rotated key %+v", rotated)
+	}
+	if _, err := restored.APIKeyByToken(secret); err != nil {
+		t.Errorf("old key within the grace: %v", err)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if _, err := restored.APIKeyByToken(secret); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("old key after the grace: %v, want %v", err, ErrAPIKeyInvalid)
+	}
+	if _, err := restored.RevokeAPIKey(rotated.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.APIKeyByToken(rotatedSecret); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("revoked key: %v, want %v", err, ErrAPIKeyInvalid)
+	}
+	if keys := restored.APIKeys(); len(keys) != 2 || keys[1].RotatedTo != rotated.ID {
+		t.Errorf("keys %+v, want the old one last, rotated to the new", keys)
+	}
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/validate"
+)
+
+// DefaultApplicationTimeout is how long an application may wait in a step
+// unless the bank's ApplicationTimeout says otherwise.
+const DefaultApplicationTimeout = 30 * 24 * time.Hour
+
+var (
+	ErrApplicationNotFound = banking.New(banking.CodeNotFound, "application not found")
+	ErrApplicationState    = banking.New(banking.CodeConflict, "application is not at that step")
+	ErrMinimumDeposit      = banking.New(banking.CodeInvalidArgument, "deposit below the product's minimum")
+)
+
+// ApplicationState is the step an application to open an account is at.
+type ApplicationState string
+
+const (
+	// ApplicationDraft is an application being filled in, before it is
+	// submitted for the know-your-customer checks of ApplicationKYC.
+	ApplicationDraft ApplicationState = "application"
+	ApplicationKYC   ApplicationState = "kyc"
+	// ApplicationFunding has passed the checks and waits for the first
+	// deposit, which opens the account and makes it ApplicationActive.
+	ApplicationFunding ApplicationState = "funding"
+	ApplicationActive  ApplicationState = "active"
+	// ApplicationRejected failed the checks, and ApplicationAbandoned was
+	// withdrawn or waited in a step for longer than the bank's
+	// ApplicationTimeout.
+	ApplicationRejected  ApplicationState = "rejected"
+	ApplicationAbandoned ApplicationState = "abandoned"
+)
+
+// nextStates lists the steps each step may move to.
+var nextStates = map[ApplicationState][]ApplicationState{
+	ApplicationDraft:   {ApplicationKYC, ApplicationAbandoned},
+	ApplicationKYC:     {ApplicationFunding, ApplicationRejected, ApplicationAbandoned},
+	ApplicationFunding: {ApplicationActive, ApplicationAbandoned},
+}
+
+// Application is a customer's application to open account Number as a
+// product of the catalog. It moves through its steps with
+// SubmitApplication, CompleteKYC and FundApplication, and Steps records
+// when it reached each.
+type Application struct {
+	ID       int               `json:"id"`
+	Customer string            `json:"customer"`
+	Product  string            `json:"product"`
+	Number   string            `json:"number"`
+	State    ApplicationState  `json:"state"`
+	Deposit  float64           `json:"deposit,omitempty"`
+	Steps    []ApplicationStep `json:"steps"`
+}
+
+// ApplicationStep is a step an application reached, and Note why, such as
+// the reason a check failed.
+type ApplicationStep struct {
+	State ApplicationState `json:"state"`
+	Time  time.Time        `json:"time"`
+	Note  string           `json:"note,omitempty"`
+}
+
+// Updated is when the application reached its current step.
+func (a Application) Updated() time.Time {
+	return a.Steps[len(a.Steps)-1].Time
+}
+
+// Done reports whether the application has reached a step it cannot leave.
+func (a Application) Done() bool {
+	return len(nextStates[a.State]) == 0
+}
+
+func (a Application) clone() Application {
+	a.Steps = slices.Clone(a.Steps)
+	return a
+}
+
+// TransitionHook is called before an application moves to step to. An
+// error stops the move and is returned to whoever asked for it, so hooks
+// can run checks of their own, such as a KYC provider's on ApplicationKYC.
+type TransitionHook func(app Application, to ApplicationState) error
+
+// OnTransition adds a hook run before every move of an application, in the
+// order they were added. Hooks run without the bank locked and may use it.
+func (b *Bank) OnTransition(hook TransitionHook) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.transitionHooks = append(b.transitionHooks, hook)
+}
+
+// Apply starts an application of a customer to open an account.
+func (b *Bank) Apply(customerID, productCode, number string) (Application, error) {
+	var v validate.Validator
+	v.Required("customer", customerID)
+	v.Required("product", productCode)
+	v.AccountNumber("number", number)
+	if err := v.Err(); err != nil {
+		return Application{}, err
+	}
+	if _, err := b.Customer(customerID); err != nil {
+		return Application{}, err
+	}
+	if _, err := b.Products.Product(productCode); err != nil {
+		return Application{}, err
+	}
+	if _, err := b.Account(number); err == nil {
+		return Application{}, ErrAccountExists
+	}
+	now := b.now()
+	b.mu.Lock()
+	for _, other := range b.applications {
+		if other.Number == number && !other.Done() {
+			b.mu.Unlock()
+			return Application{}, fmt.Errorf("%w: application %d is for %s", ErrAccountExists, other.ID, number)
+		}
+	}
+	b.nextApplication++
+	app := &Application{ID: b.nextApplication, Customer: customerID, Product: productCode, Number: number,
+		State: ApplicationDraft, Steps: []ApplicationStep{{State: ApplicationDraft, Time: now}}}
+	b.applications[app.ID] = app
+	b.mu.Unlock()
+	b.publishApplication(*app)
+	return app.clone(), nil
+}
+
+// Application returns an application by ID.
+func (b *Bank) Application(id int) (Application, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	app, ok := b.applications[id]
+	if !ok {
+		return Application{}, fmt.Errorf("%w: %d", ErrApplicationNotFound, id)
+	}
+	return app.clone(), nil
+}
+
+// Applications returns the applications of a customer, oldest first.
+func (b *Bank) Applications(customerID string) []Application {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var apps []Application
+	for _, app := range b.applications {
+		if app.Customer == customerID {
+			apps = append(apps, app.clone())
+		}
+	}
+	slices.SortFunc(apps, func(a, b Application) int { return a.ID - b.ID })
+	return apps
+}
+
+// SubmitApplication submits an application for the KYC checks.
+func (b *Bank) SubmitApplication(id int) (Application, error) {
+	return b.transition(id, ApplicationKYC, "", nil)
+}
+
+// CompleteKYC records the outcome of the KYC checks of an application:
+// passed, it waits for funding, otherwise it is rejected with note as the
+// reason.
+func (b *Bank) CompleteKYC(id int, passed bool, note string) (Application, error) {
+	if !passed {
+		return b.transition(id, ApplicationRejected, note, nil)
+	}
+	return b.transition(id, ApplicationFunding, note, nil)
+}
+
+// FundApplication opens the account of an application, for its customer
+// and as its product, and deposits amount, at least the product's
+// MinimumDeposit, into it as the opening deposit.
+func (b *Bank) FundApplication(id int, amount float64) (Application, error) {
+	if err := checkAmount(amount); err != nil {
+		return Application{}, err
+	}
+	return b.transition(id, ApplicationActive, "", func(app *Application) error {
+		p, err := b.Products.Product(app.Product)
+		if err != nil {
+			return err
+		}
+		if amount < p.MinimumDeposit {
+			return fmt.Errorf("%w: %s needs %.2f", ErrMinimumDeposit, p.Code, p.MinimumDeposit)
+		}
+		// An earlier attempt whose deposit failed may have opened it.
+		if account, err := b.Account(app.Number); err != nil {
+			if _, err := b.OpenAccount(app.Product, app.Customer, app.Number); err != nil {
+				return err
+			}
+		} else if ProductCodeOf(account) != app.Product || b.authorizeAdmin(app.Number, app.Customer) != nil {
+			return fmt.Errorf("%w: %s was opened otherwise", ErrAccountExists, app.Number)
+		}
+		app.Deposit = amount
+		return b.Deposit(app.Number, amount, WithDescription("Opening deposit"), WithMetadata("application", fmt.Sprint(app.ID)))
+	})
+}
+
+// AbandonApplication withdraws an application that is not done yet.
+func (b *Bank) AbandonApplication(id int, note string) (Application, error) {
+	return b.transition(id, ApplicationAbandoned, note, nil)
+}
+
+// AbandonStale abandons the applications that have waited in a step for
+// longer than the bank's ApplicationTimeout and returns them.
+func (b *Bank) AbandonStale() []Application {
+	cutoff := b.now().Add(-b.ApplicationTimeout)
+	b.mu.RLock()
+	var stale []int
+	for id, app := range b.applications {
+		if !app.Done() && app.Updated().Before(cutoff) {
+			stale = append(stale, id)
+		}
+	}
+	b.mu.RUnlock()
+	slices.Sort(stale)
+	var abandoned []Application
+	for _, id := range stale {
+		note := fmt.Sprintf("no progress for %s", b.ApplicationTimeout)
+		if app, err := b.transition(id, ApplicationAbandoned, note, nil); err == nil {
+			abandoned = append(abandoned, app)
+		}
+	}
+	return abandoned
+}
+
+// transition moves an application to step to, after the hooks and do, if
+// given, have succeeded. The application must not move in the meantime.
+func (b *Bank) transition(id int, to ApplicationState, note string, do func(app *Application) error) (Application, error) {
+	b.mu.RLock()
+	current, ok := b.applications[id]
+	var app Application
+	if ok {
+		app = current.clone()
+	}
+	hooks := slices.Clone(b.transitionHooks)
+	b.mu.RUnlock()
+	if !ok {
+		return Application{}, fmt.Errorf("%w: %d", ErrApplicationNotFound, id)
+	}
+	if !slices.Contains(nextStates[app.State], to) {
+		return Application{}, fmt.Errorf("%w: application %d is at %s and cannot move to %s", ErrApplicationState, id, app.State, to)
+	}
+	for _, hook := range hooks {
+		if err := hook(app.clone(), to); err != nil {
+			return Application{}, err
+		}
+	}
+
+	b.mu.Lock()
+	if current.State != app.State {
+		b.mu.Unlock()
+		return Application{}, fmt.Errorf("%w: application %d moved to %s meanwhile", ErrApplicationState, id, current.State)
+	}
+	// Claim the move so that no other one starts while do runs unlocked.
+	from := current.State
+	current.State = to
+	b.mu.Unlock()
+	if do != nil {
+		if err := do(&app); err != nil {
+			b.mu.Lock()
+			current.State = from
+			b.mu.Unlock()
+			return Application{}, err
+		}
+	}
+	b.mu.Lock()
+	current.Deposit = app.Deposit
+	current.Steps = append(current.Steps, ApplicationStep{State: to, Time: b.now(), Note: note})
+	app = current.clone()
+	b.mu.Unlock()
+	b.publishApplication(app)
+	return app, nil
+}
+
+func (b *Bank) publishApplication(app Application) {
+	message := fmt.Sprintf("Application %d of %s for a %s account: %s", app.ID, app.Customer, app.Product, app.State)
+	if note := app.Steps[len(app.Steps)-1].Note; note != "" {
+		message += " (" + note + ")"
+	}
+	b.Events.Publish(Event{Type: EventApplicationChanged, AccountNumber: app.Number, Time: app.Updated(), Message: message})
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// applicationBank returns a bank with customer c1 and a checking product
+// that must be opened with at least 50.
+func applicationBank(t *testing.T) (*Bank, *testClock) {
+	t.Helper()
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada"})
+	if err := b.Products.Define(Product{Code: "basic", Name: "Basic Checking", Kind: ProductChecking, MinimumDeposit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	return b, clock
+}
+
+// TestApplicationWorkflow takes an application through its steps, checking
+// that steps cannot be skipped, that funding below the minimum leaves it
+// waiting for funds and that funding opens the account for the customer.
+func TestApplicationWorkflow(t *testing.T) {
+	b, _ := applicationBank(t)
+	if _, err := b.Apply("c9", "basic", "C1"); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("application of an unknown customer: %v, want %v", err, ErrCustomerNotFound)
+	}
+	app, err := b.Apply("c1", "basic", "C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Apply("c1", "basic", "C1"); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("second application for C1: %v, want %v", err, ErrAccountExists)
+	}
+	if _, err := b.FundApplication(app.ID, 100); !errors.Is(err, ErrApplicationState) {
+		t.Errorf("funding a draft: %v, want %v", err, ErrApplicationState)
+	}
+	if _, err := b.SubmitApplication(app.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.CompleteKYC(app.ID, true, "documents checked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.FundApplication(app.ID, 20); !errors.Is(err, ErrMinimumDeposit) {
+		t.Errorf("funding below the minimum: %v, want %v", err, ErrMinimumDeposit)
+	}
+	if app, _ := b.Application(app.ID); app.State != ApplicationFunding {
+		t.Errorf("after a failed funding the application is %s, want %s", app.State, ApplicationFunding)
+	}
+	app, err = b.FundApplication(app.ID, 75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []ApplicationState{ApplicationDraft, ApplicationKYC, ApplicationFunding, ApplicationActive}
+	if app.State != ApplicationActive || app.Deposit != 75 || len(app.Steps) != len(states) || app.Steps[2].Note != "documents checked" {
+		t.Fatalf("funded application %+v", app)
+	}
+	for i, step := range app.Steps {
+		if step.State != states[i] {
+			t.Errorf("step %d is %s, want %s", i, step.State, states[i])
+		}
+	}
+	if balance, _ := b.Balance("C1"); balance != 75 {
+		t.Errorf("C1 balance = %.2f, want 75", balance)
+	}
+	if err := b.Authorize("C1", "c1", PermissionAdmin, ""); err != nil {
+		t.Errorf("c1 does not hold the account: %v", err)
+	}
+	if _, err := b.AbandonApplication(app.ID, "changed my mind"); !errors.Is(err, ErrApplicationState) {
+		t.Errorf("abandoning an active application: %v, want %v", err, ErrApplicationState)
+	}
+}
+
+// TestApplicationRejectedAndStale checks that failed checks reject an
+// application, that transition hooks can stop a move, and that
+// applications waiting too long are abandoned.
+func TestApplicationRejectedAndStale(t *testing.T) {
+	b, clock := applicationBank(t)
+	rejected, _ := b.Apply("c1", "basic", "C1")
+	b.SubmitApplication(rejected.ID)
+	if app, err := b.CompleteKYC(rejected.ID, false, "address not verified"); err != nil || app.State != ApplicationRejected || !app.Done() {
+		t.Errorf("failed checks = %+v, %v, want rejected", app, err)
+	}
+
+	refused := errors.New("provider unavailable")
+	b.OnTransition(func(app Application, to ApplicationState) error {
+		if to == ApplicationKYC && app.Number == "C2" {
+			return refused
+		}
+		return nil
+	})
+	held, _ := b.Apply("c1", "basic", "C2")
+	if _, err := b.SubmitApplication(held.ID); !errors.Is(err, refused) {
+		t.Errorf("submitting past a refusing hook: %v, want %v", err, refused)
+	}
+	fresh, _ := b.Apply("c1", "basic", "C3")
+
+	clock.now = clock.now.Add(DefaultApplicationTimeout - time.Hour)
+	b.SubmitApplication(fresh.ID)
+	clock.now = clock.now.Add(2 * time.Hour)
+	stale := b.AbandonStale()
+	if len(stale) != 1 || stale[0].ID != held.ID || stale[0].State != ApplicationAbandoned {
+		t.Errorf("abandoned %+v, want the application for C2 alone", stale)
+	}
+	if apps := b.Applications("c1"); len(apps) != 3 || apps[2].State != ApplicationKYC {
+		t.Errorf("applications of c1 = %+v", apps)
+	}
+}
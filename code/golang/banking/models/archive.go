@@ -0,0 +1,209 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrAccountClosed    = banking.New(banking.CodeConflict, "account closed")
+	ErrAccountArchived  = banking.New(banking.CodeNotFound, "account archived")
+	ErrBalanceNotZero   = banking.New(banking.CodeConflict, "balance must be zero to close the account")
+	ErrPendingTransfers = banking.New(banking.CodeConflict, "account has pending transfers")
+	ErrNotArchived      = banking.New(banking.CodeNotFound, "no archived account with that number")
+)
+
+// Archive is cold storage for the ledgers of archived accounts, kept as
+// opaque compressed blobs keyed by account number.
+type Archive interface {
+	Put(number string, blob []byte) error
+	// Get returns ErrNotArchived for an unknown number.
+	Get(number string) ([]byte, error)
+}
+
+// MemoryArchive is an Archive that keeps the blobs in memory.
+type MemoryArchive struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewMemoryArchive() *MemoryArchive {
+	return &MemoryArchive{blobs: make(map[string][]byte)}
+}
+
+func (a *MemoryArchive) Put(number string, blob []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.blobs[number] = blob
+	return nil
+}
+
+func (a *MemoryArchive) Get(number string) ([]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	blob, ok := a.blobs[number]
+	if !ok {
+		return nil, ErrNotArchived
+	}
+	return blob, nil
+}
+
+// ArchivedAccount is an account read back from the archive, with its full
+// ledger.
+type ArchivedAccount struct {
+	Account  BankAccount
+	Closed   time.Time
+	Archived time.Time
+}
+
+// archivedAccount is the JSON inside an archive blob.
+type archivedAccount struct {
+	accountSnapshot
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// CloseAccount closes an account with a zero balance and no pending
+// transfers. A closed account stays readable but takes no new entries, and
+// becomes eligible for ArchiveClosed.
+func (b *Bank) CloseAccount(number string) error {
+	account, err := b.Account(number)
+	if err != nil {
+		return err
+	}
+	now := b.now()
+	b.mu.Lock()
+	switch {
+	case !b.closed[number].IsZero():
+		err = ErrAccountClosed
+	case account.CheckBalance() != 0:
+		err = ErrBalanceNotZero
+	default:
+		for _, pt := range b.pending {
+			if pt.From == number || pt.To == number {
+				err = ErrPendingTransfers
+				break
+			}
+		}
+	}
+	if err == nil {
+		b.closed[number] = now
+	}
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	b.Events.Publish(Event{Type: EventAccountClosed, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("Account %s closed", number)})
+	return nil
+}
+
+// ClosedAt returns when an account was closed, or the zero time when it is
+// open.
+func (b *Bank) ClosedAt(number string) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed[number]
+}
+
+// ArchiveClosed moves every account closed for longer than retention into
+// the bank's Archive, compressed, and drops it from the bank. Archived
+// accounts no longer appear in listings and indexes, and their number cannot
+// be reused; GetArchivedAccount reads them back. It returns the numbers of
+// the accounts it archived, and stops at the first archive write that fails.
+func (b *Bank) ArchiveClosed(retention time.Duration, now time.Time) ([]string, error) {
+	cutoff := now.Add(-retention)
+	b.mu.Lock()
+	var due []archivedAccount
+	for number, closed := range b.closed {
+		// A deleted account can be restored until its grace period ends.
+		if deleted, ok := b.deleted[number]; ok && now.Before(deleted.Add(b.DeleteGrace)) {
+			continue
+		}
+		if closed.Before(cutoff) {
+			s, err := snapshotAccount(b.accounts.account(number))
+			if err != nil {
+				b.mu.Unlock()
+				return nil, fmt.Errorf("archiving account %s: %w", number, err)
+			}
+			a := archivedAccount{accountSnapshot: s, ArchivedAt: now}
+			a.Closed = &closed
+			due = append(due, a)
+		}
+	}
+	b.mu.Unlock()
+
+	var archived []string
+	for _, a := range due {
+		blob, err := compressAccount(a)
+		if err == nil {
+			err = b.Archive.Put(a.Number, blob)
+		}
+		if err != nil {
+			return archived, fmt.Errorf("archiving account %s: %w", a.Number, err)
+		}
+		// Closed accounts take no entries, so the blob is still current.
+		b.mu.Lock()
+		b.accounts.remove(a.Number)
+		delete(b.closed, a.Number)
+		delete(b.deleted, a.Number)
+		b.archived[a.Number] = now
+		b.mu.Unlock()
+		archived = append(archived, a.Number)
+		b.Events.Publish(Event{Type: EventAccountArchived, AccountNumber: a.Number, Time: now,
+			Message: fmt.Sprintf("Account %s archived", a.Number)})
+	}
+	return archived, nil
+}
+
+// GetArchivedAccount reads an archived account and its ledger back from the
+// archive.
+func (b *Bank) GetArchivedAccount(number string) (ArchivedAccount, error) {
+	blob, err := b.Archive.Get(number)
+	if err != nil {
+		return ArchivedAccount{}, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return ArchivedAccount{}, fmt.Errorf("reading archived account %s: %w", number, err)
+	}
+	var a archivedAccount
+	if err := json.NewDecoder(zr).Decode(&a); err != nil {
+		return ArchivedAccount{}, fmt.Errorf("reading archived account %s: %w", number, err)
+	}
+	account, err := restoreAccount(a.accountSnapshot)
+	if err != nil {
+		return ArchivedAccount{}, fmt.Errorf("reading archived account %s: %w", number, err)
+	}
+	archived := ArchivedAccount{Account: account, Archived: a.ArchivedAt}
+	if a.Closed != nil {
+		archived.Closed = *a.Closed
+	}
+	return archived, nil
+}
+
+func compressAccount(a archivedAccount) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(a); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkOpen returns ErrAccountClosed for a closed account. The caller holds
+// b.mu.
+func (b *Bank) checkOpen(number string) error {
+	if !b.closed[number].IsZero() {
+		return ErrAccountClosed
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// auditLogSize is how many operations a bank's AuditLog keeps.
+const auditLogSize = 100000
+
+// AuditEntry is an operation someone asked of the bank: Action, such as a
+// deposit or a freeze, on Account and To for a transfer, by Operator, the
+// member of staff, or by Customer, and its outcome, Status and Error. Reads
+// of third-party clients name the Client and the Consent they read under,
+// and the Customer who gave it.
+type AuditEntry struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator,omitempty"`
+	Customer string    `json:"customer,omitempty"`
+	Client   string    `json:"client,omitempty"`
+	Consent  int       `json:"consent,omitempty"`
+	Action   string    `json:"action"`
+	Account  string    `json:"account,omitempty"`
+	To       string    `json:"to,omitempty"`
+	Amount   float64   `json:"amount,omitempty"`
+	Status   int       `json:"status"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AuditQuery selects audit entries; zero fields select every entry. From
+// and To bound their time, To excluded, and MinAmount selects those that
+// moved at least that much.
+type AuditQuery struct {
+	Operator  string
+	Customer  string
+	Client    string
+	Consent   int
+	Action    string
+	Account   string
+	From      time.Time
+	To        time.Time
+	MinAmount float64
+}
+
+// Match reports whether q selects e. Account matches either side of a
+// transfer.
+func (q AuditQuery) Match(e AuditEntry) bool {
+	return (q.Operator == "" || e.Operator == q.Operator) && (q.Customer == "" || e.Customer == q.Customer) &&
+		(q.Client == "" || e.Client == q.Client) && (q.Consent == 0 || e.Consent == q.Consent) &&
+		(q.Action == "" || e.Action == q.Action) && (q.Account == "" || e.Account == q.Account || e.To == q.Account) &&
+		(q.From.IsZero() || !e.Time.Before(q.From)) && (q.To.IsZero() || e.Time.Before(q.To)) &&
+		(q.MinAmount == 0 || e.Amount >= q.MinAmount)
+}
+
+// AuditLog keeps the most recent operations asked of a bank, for admins to
+// query. Older entries are dropped once it is full, like those of an
+// EventLog.
+type AuditLog struct {
+	mu sync.Mutex
+	// entries is a ring once full: the oldest entry is at start.
+	entries  []AuditEntry
+	start    int
+	lastID   int64
+	capacity int
+}
+
+func NewAuditLog(capacity int) *AuditLog {
+	return &AuditLog{capacity: capacity}
+}
+
+// Record appends an entry, numbering it, and returns it as recorded.
+func (l *AuditLog) Record(e AuditEntry) AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastID++
+	e.ID = l.lastID
+	if len(l.entries) < l.capacity {
+		l.entries = append(l.entries, e)
+		return e
+	}
+	l.entries[l.start] = e
+	l.start = (l.start + 1) % len(l.entries)
+	return e
+}
+
+// Entries returns the entries q selects, oldest first.
+func (l *AuditLog) Entries(q AuditQuery) []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []AuditEntry{}
+	for _, part := range [][]AuditEntry{l.entries[l.start:], l.entries[:l.start]} {
+		for _, e := range part {
+			if q.Match(e) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries
+}
+
+// Page returns a page of the entries q selects, oldest first.
+func (l *AuditLog) Page(q AuditQuery, req PageRequest) (Page[AuditEntry], error) {
+	return paginate(l.Entries(q), req, func(e AuditEntry) string { return sequenceKey(int(e.ID)) })
+}
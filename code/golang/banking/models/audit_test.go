@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAuditLog checks that audit queries select by operator, amount and
+// time, page through the entries and keep only the newest once full.
+func TestAuditLog(t *testing.T) {
+	l := NewAuditLog(4)
+	start := date(t, "2026-03-01")
+	for i, e := range []AuditEntry{
+		{Operator: "teller-1", Action: "deposit", Account: "C1", Amount: 50},
+		{Operator: "teller-2", Action: "transfer", Account: "C1", To: "S1", Amount: 20000},
+		{Operator: "teller-1", Action: "freeze", Account: "C2"},
+		{Customer: "c1", Action: "transfer", Account: "S1", To: "C1", Amount: 15000},
+		{Operator: "teller-1", Action: "withdrawal", Account: "C1", Amount: 12000},
+	} {
+		e.Time = start.Add(time.Duration(i) * 24 * time.Hour)
+		l.Record(e)
+	}
+
+	// The deposit was dropped to make room.
+	if got := l.Entries(AuditQuery{Operator: "teller-1"}); len(got) != 2 || got[0].Action != "freeze" || got[1].ID != 5 {
+		t.Errorf("entries of teller-1 %+v", got)
+	}
+	if got := l.Entries(AuditQuery{Action: "transfer", MinAmount: 16000}); len(got) != 1 || got[0].Operator != "teller-2" {
+		t.Errorf("transfers of at least 16000 %+v", got)
+	}
+	if got := l.Entries(AuditQuery{Account: "C1", From: date(t, "2026-03-03"), To: date(t, "2026-03-05")}); len(got) != 1 || got[0].Customer != "c1" {
+		t.Errorf("operations on C1 on March 3rd and 4th %+v", got)
+	}
+
+	page, err := l.Page(AuditQuery{MinAmount: 10000}, PageRequest{Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || !page.HasMore {
+		t.Fatalf("first page %+v", page)
+	}
+	page, err = l.Page(AuditQuery{MinAmount: 10000}, PageRequest{Size: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.HasMore || page.Items[0].Action != "withdrawal" {
+		t.Errorf("second page %+v", page)
+	}
+}
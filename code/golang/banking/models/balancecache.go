@@ -0,0 +1,143 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BalanceVersion is the balance of an account together with the sequence
+// of the last ledger entry it includes.
+type BalanceVersion struct {
+	Balance  float64
+	Sequence int
+}
+
+// VersionedBalance returns the balance of an account and the sequence of
+// its last entry, read together.
+func (b *Bank) VersionedBalance(number string) (BalanceVersion, error) {
+	account, unlock, err := b.lockAccount(number)
+	if err != nil {
+		return BalanceVersion{}, err
+	}
+	defer unlock()
+	v := BalanceVersion{Balance: account.CheckBalance()}
+	if history := account.History(); len(history) > 0 {
+		v.Sequence = history[len(history)-1].Sequence
+	}
+	return v, nil
+}
+
+// BalanceCache serves account balances without locking the accounts, for
+// pages and APIs that read them far more often than they change. It follows
+// the bank's events: each entry posted is applied to the cached balance of
+// its account, and an entry that does not follow on from the cached one, as
+// when two posts publish out of order, drops the balance until it is next
+// read from the bank.
+//
+// Events are published before the call that posted the entry returns, so a
+// balance read from the cache after a deposit, withdrawal or transfer has
+// returned includes it: the cache never serves a balance older than the
+// last entry posted by a caller that then reads it. Restore, which changes
+// balances without ledger entries, drops every cached balance.
+type BalanceCache struct {
+	bank        *Bank
+	mu          sync.RWMutex
+	balances    map[string]cachedBalance
+	hits        atomic.Int64
+	misses      atomic.Int64
+	unsubscribe func()
+}
+
+// cachedBalance is a balance that can be served when valid. Otherwise
+// Sequence is the last entry seen posted, and a balance read from the bank
+// that is older must not be cached.
+type cachedBalance struct {
+	BalanceVersion
+	valid bool
+}
+
+func NewBalanceCache(bank *Bank) *BalanceCache {
+	c := &BalanceCache{bank: bank, balances: make(map[string]cachedBalance)}
+	c.unsubscribe = bank.Events.Subscribe(c.apply)
+	return c
+}
+
+func (c *BalanceCache) apply(e Event) {
+	switch {
+	case e.Type == EventTransactionPosted && e.Transaction != nil:
+		tx := e.Transaction
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		cached := c.balances[e.AccountNumber]
+		switch {
+		case cached.valid && tx.Sequence == cached.Sequence+1:
+			if tx.Type.IsCredit() {
+				cached.Balance += tx.Amount
+			} else {
+				cached.Balance -= tx.Amount
+			}
+			cached.Sequence = tx.Sequence
+		case tx.Sequence > cached.Sequence:
+			cached = cachedBalance{BalanceVersion: BalanceVersion{Sequence: tx.Sequence}}
+		default:
+			// An entry the cached balance already includes, published late.
+			return
+		}
+		c.balances[e.AccountNumber] = cached
+	case e.Type == EventAccountArchived || e.Type == EventAccountRenumbered:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.balances, e.AccountNumber)
+	case e.Type == EventBankRestored:
+		c.Reset()
+	}
+}
+
+// Balance returns the balance of an account, from the cache when it holds
+// it and from the bank otherwise.
+func (c *BalanceCache) Balance(number string) (float64, error) {
+	v, err := c.VersionedBalance(number)
+	return v.Balance, err
+}
+
+// VersionedBalance is Balance with the sequence of the last entry the
+// balance includes.
+func (c *BalanceCache) VersionedBalance(number string) (BalanceVersion, error) {
+	c.mu.RLock()
+	cached := c.balances[number]
+	c.mu.RUnlock()
+	if cached.valid {
+		c.hits.Add(1)
+		return cached.BalanceVersion, nil
+	}
+	c.misses.Add(1)
+	v, err := c.bank.VersionedBalance(number)
+	if err != nil {
+		return v, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// An entry posted after v was read may have been applied already.
+	if current := c.balances[number]; v.Sequence >= current.Sequence {
+		c.balances[number] = cachedBalance{BalanceVersion: v, valid: true}
+	}
+	return v, nil
+}
+
+// Stats returns how many balances were served from the cache and how many
+// were read from the bank.
+func (c *BalanceCache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Reset drops every cached balance.
+func (c *BalanceCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balances = make(map[string]cachedBalance)
+}
+
+// Close stops following the bank's events.
+func (c *BalanceCache) Close() {
+	c.unsubscribe()
+}
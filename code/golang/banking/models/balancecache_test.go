@@ -0,0 +1,108 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// TestBalanceCacheReadsOwnWrites posts to accounts that each have one
+// writer, which checks after every post that the cache serves the balance
+// it just made, while other goroutines read every account through the cache
+// and post to a shared account to publish events out of order.
+func TestBalanceCacheReadsOwnWrites(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	const writers = 8
+	for i := 0; i <= writers; i++ {
+		b.Open(&SavingsAccount{Account: Account{AccountNumber: fmt.Sprintf("W%d", i)}})
+	}
+	cache := NewBalanceCache(b)
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for w := 1; w <= writers; w++ {
+		wg.Add(1)
+		go func(number string, seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			want := 0.0
+			for i := 0; i < 300; i++ {
+				amount := float64(1+r.Intn(10000)) / 100
+				if r.Intn(3) == 0 && amount <= want {
+					if err := b.Withdraw(number, amount); err != nil {
+						t.Error(err)
+						return
+					}
+					want -= amount
+				} else {
+					b.Deposit(number, amount)
+					want += amount
+				}
+				if got, _ := cache.Balance(number); got != want {
+					t.Errorf("after post %d the cache has %s at %.2f, want %.2f", i+1, number, got, want)
+					return
+				}
+			}
+		}(fmt.Sprintf("W%d", w), int64(w))
+	}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 1000; i++ {
+				cache.Balance(fmt.Sprintf("W%d", r.Intn(writers+1)))
+				if i%4 == 0 {
+					b.Deposit("W0", 1)
+				}
+			}
+		}(int64(100 + g))
+	}
+	waitOrDeadlock(t, &wg)
+
+	for i := 0; i <= writers; i++ {
+		number := fmt.Sprintf("W%d", i)
+		got, _ := cache.VersionedBalance(number)
+		want, _ := b.VersionedBalance(number)
+		if got != want {
+			t.Errorf("%s: cache has %+v, bank has %+v", number, got, want)
+		}
+	}
+	if hits, _ := cache.Stats(); hits == 0 {
+		t.Error("no balance was served from the cache")
+	}
+}
+
+// TestBalanceCacheRestore checks that restoring a snapshot drops the cached
+// balances, which it changes without posting entries.
+func TestBalanceCacheRestore(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.Deposit("S1", 100)
+	var snapshot bytes.Buffer
+	if err := b.Snapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	b.Deposit("S1", 50)
+	cache := NewBalanceCache(b)
+	defer cache.Close()
+	if got, _ := cache.Balance("S1"); got != 150 {
+		t.Fatalf("cached balance = %.2f, want 150", got)
+	}
+
+	if err := b.Restore(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cache.Balance("S1"); got != 100 {
+		t.Errorf("balance after restoring = %.2f, want the snapshot's 100", got)
+	}
+	b.Deposit("S1", 5)
+	if got, _ := cache.Balance("S1"); got != 105 {
+		t.Errorf("balance after a deposit on the restored bank = %.2f, want 105", got)
+	}
+}
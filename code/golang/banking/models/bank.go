@@ -0,0 +1,549 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/calendar"
+	"gsolano/banking/money"
+	"gsolano/banking/validate"
+)
+
+// Bank keeps track of customers and their open accounts and is the entry
+// point for operations that go beyond a single account: transfers, budgets
+// and events. Products is the catalog OpenAccount opens accounts from.
+// Tenant names the institution for tenant-specific feature flags and Rates
+// supplies the reference rates of variable rate accounts. Rounding is how
+// interest is rounded to the cent. Currency is the currency of accounts
+// that do not name one and FX supplies the exchange rates of transfers
+// between currencies. Calendar decides the business days
+// transfers settle on. Log keeps the latest events published on Events.
+//
+// mu guards the bank's own state. Entries are posted holding its read lock
+// and the lock of the account posted to, so posts to different accounts go
+// ahead in parallel; holding mu itself excludes every post.
+type Bank struct {
+	mu        sync.RWMutex
+	accounts  *accountShards
+	customers map[string]*Customer
+	// pending transfers and the amounts they hold per source account.
+	pending     []*PendingTransfer
+	nextPending int
+	held        map[string]float64
+	// closed, soft-deleted and archived accounts and when they were closed,
+	// deleted or archived.
+	closed   map[string]time.Time
+	deleted  map[string]time.Time
+	archived map[string]time.Time
+	// dormant are the accounts ReviewDormancy marked dormant and when.
+	dormant map[string]time.Time
+	// estates are the estates of deceased customers, by customer, and
+	// frozen the customer each account frozen for one belongs to. settling
+	// is the customer whose estate SettleEstate is settling, holding b.mu.
+	estates  map[string]*Estate
+	frozen   map[string]string
+	settling string
+	// buckets is the delinquency bucket of each loan and card at the last
+	// review.
+	buckets map[string]DelinquencyBucket
+	// cycles are the statement cycles set for accounts.
+	cycles map[string]StatementCycle
+	// aliases are the nicknames customers gave accounts.
+	aliases map[string]Alias
+	// zones are the time zones set for accounts.
+	zones map[string]*time.Location
+	// controls are the controls of accounts and their overrides.
+	controls     map[string]accountControls
+	nextOverride int
+	// holders are the permissions of the holders of joint accounts that
+	// are not admins, and invitations the invitations to hold accounts not
+	// answered yet, by ID.
+	holders     map[string]map[string]Holder
+	invitations map[string]Invitation
+	// grants are the grants of delegated access, past ones included,
+	// oldest first.
+	grants    []Grant
+	nextGrant int
+	// applications are the applications to open accounts, by ID, and
+	// transitionHooks those OnTransition added.
+	applications    map[int]*Application
+	nextApplication int
+	transitionHooks []TransitionHook
+	// quotes are the transfer quotes not executed yet, by ID.
+	quotes map[string]TransferQuote
+	// ledgerRoots are the latest Merkle roots PublishLedgerRoot published,
+	// oldest first.
+	ledgerRoots []publishedRoot
+	// redirects are where renumbered accounts are now, by old number.
+	redirects map[string]Redirect
+	// rateChanges are the scheduled changes of product rates, past ones
+	// included, by ID.
+	rateChanges    []*RateChange
+	nextRateChange int
+	// messages are the customers' inboxes, oldest message first.
+	messages map[string][]*Message
+	// cases are the customers' support cases, by ID.
+	cases    map[int]*Case
+	nextCase int
+	// sessions are the customers' sessions by ID, and sessionIDs their
+	// IDs by the SHA-256 of their tokens.
+	sessions   map[string]*Session
+	sessionIDs map[[32]byte]string
+	// apiKeys are the API keys issued by ID, and apiKeyIDs their IDs by
+	// the SHA-256 of the keys.
+	apiKeys   map[string]*APIKey
+	apiKeyIDs map[[32]byte]string
+	// consents are the consents customers gave third-party clients, past
+	// ones included, by ID.
+	consents    map[int]*Consent
+	nextConsent int
+	// enrichments are what enrichment made of transactions, by their ID,
+	// and merchantRules the rules of the merchant rules enricher.
+	enrichments   map[string]*Enrichment
+	merchantRules []MerchantRule
+	// installments are the installment plans, ended ones included, by
+	// ID, and installmentRun serializes PayInstallments.
+	installments   map[string]*InstallmentPlan
+	installmentRun sync.Mutex
+	// rewards are the rewards of the accounts in a rewards program, by
+	// number.
+	rewards map[string]*Rewards
+	// totpApps are the customers' authenticator apps, see EnrollTOTP.
+	totpApps map[string]*authenticator
+	// middleware wraps deposits, withdrawals and transfers, composed into
+	// operate by Use.
+	middleware []Middleware
+	operate    Operation
+
+	Tenant   string
+	Events   *EventBus
+	Budgets  *Budgets
+	Products *Catalog
+	Flags    *FeatureFlags
+	Rates    RateProvider
+	Currency string
+	FX       ExchangeRateProvider
+	// FXHistory supplies the past rates of valuations, see ValueCustomer.
+	FXHistory HistoricalRates
+	// FXPricing is what transfers between currencies cost, and QuoteTTL
+	// how long a transfer quote locks its rate.
+	FXPricing FXPricing
+	QuoteTTL  time.Duration
+	Rounding  money.Rounding
+	Calendar  *calendar.Calendar
+	Log       *EventLog
+	Archive   Archive
+	Clock     Clock
+	// Audit keeps the operations asked of the bank through its API, by
+	// whom and with what outcome.
+	Audit *AuditLog
+	// Location is the time zone of accounts without one of their own, see
+	// SetTimeZone; nil keeps times in the zone of the clock.
+	Location *time.Location
+	// IDs makes the IDs of ledger entries, transfers, quotes and
+	// invitations, UUIDv7s by default.
+	IDs IDGenerator
+	// ReceiptKey signs receipts, see Receipt; without one they are
+	// unsigned.
+	ReceiptKey []byte
+	// SecretKey encrypts the customers' authenticator app secrets in
+	// snapshots; without one they are left out, and customers enroll
+	// their apps again after a restore.
+	SecretKey []byte
+	// DeleteGrace is how long a soft-deleted account can be restored.
+	DeleteGrace time.Duration
+	// ApplicationTimeout is how long an application to open an account may
+	// wait in a step before AbandonStale abandons it.
+	ApplicationTimeout time.Duration
+	// MessageRetention is how long messages stay in an inbox, see
+	// ExpireMessages, and Channels deliver every message sent besides.
+	MessageRetention time.Duration
+	Channels         []MessageChannel
+	// SessionTTL is how long sessions last and StepUpWindow how long after
+	// authenticating they may do high-risk operations.
+	SessionTTL   time.Duration
+	StepUpWindow time.Duration
+	// SecondFactors are those sessions may step up with, and StepUpAmount
+	// the most a session may transfer without stepping up, 0 for any.
+	SecondFactors []SecondFactor
+	StepUpAmount  float64
+	// Duplicates is how transfers and payments that repeat one just made
+	// are treated, see DetectDuplicates.
+	Duplicates DuplicateCheck
+	// PayeeCheck is how transfers to a payee whose name does not match
+	// are treated, see ConfirmPayees, and PayeeRegistry knows the names
+	// of accounts outside the bank.
+	PayeeCheck    PayeeMode
+	PayeeRegistry PayeeRegistry
+	// Enrichers make merchants of the counterparties of transactions
+	// posted, see Enrichment; the merchant rules one by default.
+	Enrichers []Enricher
+	// DormancyMonths is how many months without customer activity make a
+	// deposit account dormant, 0 to never mark one, and EscheatMonths how
+	// many make its balance due as unclaimed property.
+	DormancyMonths int
+	EscheatMonths  int
+	// Reserves are the ratios ReserveReport holds the books to.
+	Reserves ReserveRatios
+
+	DelinquencyPolicy DelinquencyPolicy
+	// Allocation is the order payments into each credit product, named as
+	// by KindOf, pay off fees, interest and principal.
+	Allocation map[string]AllocationOrder
+}
+
+func NewBank() *Bank {
+	b := &Bank{
+		accounts:     newAccountShards(),
+		customers:    make(map[string]*Customer),
+		held:         make(map[string]float64),
+		closed:       make(map[string]time.Time),
+		deleted:      make(map[string]time.Time),
+		archived:     make(map[string]time.Time),
+		dormant:      make(map[string]time.Time),
+		estates:      make(map[string]*Estate),
+		frozen:       make(map[string]string),
+		buckets:      make(map[string]DelinquencyBucket),
+		cycles:       make(map[string]StatementCycle),
+		aliases:      make(map[string]Alias),
+		zones:        make(map[string]*time.Location),
+		quotes:       make(map[string]TransferQuote),
+		controls:     make(map[string]accountControls),
+		holders:      make(map[string]map[string]Holder),
+		invitations:  make(map[string]Invitation),
+		applications: make(map[int]*Application),
+		redirects:    make(map[string]Redirect),
+		messages:     make(map[string][]*Message),
+		cases:        make(map[int]*Case),
+		sessions:     make(map[string]*Session),
+		sessionIDs:   make(map[[32]byte]string),
+		totpApps:     make(map[string]*authenticator),
+		apiKeys:      make(map[string]*APIKey),
+		apiKeyIDs:    make(map[[32]byte]string),
+		consents:     make(map[int]*Consent),
+		enrichments:  make(map[string]*Enrichment),
+		installments: make(map[string]*InstallmentPlan),
+		rewards:      make(map[string]*Rewards),
+		Events:       &EventBus{},
+		Budgets:      NewBudgets(),
+		Products:     NewCatalog(DefaultProducts...),
+		Flags:        NewFeatureFlags(),
+		Calendar:     calendar.New(),
+		Log:          NewEventLog(eventLogSize),
+		Audit:        NewAuditLog(auditLogSize),
+		Archive:      NewMemoryArchive(),
+		Clock:        SystemClock{},
+		IDs:          &UUIDv7{},
+
+		DeleteGrace:        DefaultDeleteGrace,
+		ApplicationTimeout: DefaultApplicationTimeout,
+		MessageRetention:   DefaultMessageRetention,
+		SessionTTL:         DefaultSessionTTL,
+		StepUpWindow:       DefaultStepUpWindow,
+
+		DelinquencyPolicy: DefaultDelinquencyPolicy,
+		Reserves:          DefaultReserveRatios,
+		Allocation:        make(map[string]AllocationOrder),
+	}
+	b.SecondFactors = []SecondFactor{totpFactor{b}}
+	b.Enrichers = []Enricher{merchantRules{b}}
+	b.Events.Subscribe(b.Log.Record)
+	b.Events.Subscribe(b.inboxEvent)
+	b.Events.Subscribe(b.enrichEvent)
+	b.Events.Subscribe(b.rewardsEvent)
+	return b
+}
+
+// Open registers an account with the bank. Its number must be valid, see
+// validate.Validator.AccountNumber, and so must the variable rate of a
+// savings account.
+func (b *Bank) Open(account BankAccount) error {
+	var v validate.Validator
+	v.AccountNumber("number", account.Number())
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if sa, ok := account.(*SavingsAccount); ok && sa.Variable != nil {
+		if err := sa.Variable.validate(); err != nil {
+			return err
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.archived[account.Number()]; ok {
+		return ErrAccountExists
+	}
+	if !b.accounts.add(account) {
+		return ErrAccountExists
+	}
+	b.hook(account)
+	return nil
+}
+
+// hook has the bank stamp the entries posted to an account.
+func (b *Bank) hook(account BankAccount) {
+	if l, ok := account.(ledgered); ok {
+		number := account.Number()
+		l.ledger().stamp = func(tx *Transaction) { b.stamp(number, tx) }
+	}
+}
+
+func (b *Bank) Account(number string) (BankAccount, error) {
+	entry, err := b.entry(number)
+	if err != nil {
+		return nil, err
+	}
+	return entry.account, nil
+}
+
+// entry returns the registry entry of an open account.
+func (b *Bank) entry(number string) (*accountEntry, error) {
+	if entry, ok := b.accounts.entry(number); ok {
+		return entry, nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if _, archived := b.archived[number]; archived {
+		return nil, ErrAccountArchived
+	}
+	return nil, ErrAccountNotFound
+}
+
+// Accounts returns every open account ordered by account number.
+func (b *Bank) Accounts() []BankAccount {
+	accounts := b.accounts.all()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number() < accounts[j].Number() })
+	return accounts
+}
+
+// Balance returns the current balance of an account.
+func (b *Bank) Balance(number string) (float64, error) {
+	account, unlock, err := b.lockAccount(number)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	return account.CheckBalance(), nil
+}
+
+// History returns a copy of an account's ledger, oldest entry first.
+func (b *Bank) History(number string) ([]Transaction, error) {
+	account, unlock, err := b.lockAccount(number)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return append([]Transaction(nil), account.History()...), nil
+}
+
+func (b *Bank) Deposit(number string, amount float64, opts ...TxOption) error {
+	return b.run(Op{Kind: OpDeposit, Account: number, Amount: amount, Options: opts})
+}
+
+// Withdraw withdraws amount from an account. A withdrawal tagged with a
+// category is checked against that category's budget first.
+func (b *Bank) Withdraw(number string, amount float64, opts ...TxOption) error {
+	return b.run(Op{Kind: OpWithdrawal, Account: number, Amount: amount, Options: opts})
+}
+
+// Transfer moves amount between two accounts, recording each account as the
+// counterparty of the other. It holds both accounts while it posts, so no
+// one sees the money in neither, and undoes the withdrawal if the deposit
+// fails.
+func (b *Bank) Transfer(from, to string, amount float64, opts ...TxOption) error {
+	return b.run(Op{Kind: OpTransfer, Account: from, To: to, Amount: amount, Options: opts})
+}
+
+// withdraw posts a withdrawal and the withdrawal fee of the account's
+// product.
+func (b *Bank) withdraw(number string, amount float64, opts []TxOption) error {
+	account, unlock, err := b.lockAccount(number)
+	if err != nil {
+		return err
+	}
+	posted, err := b.postWithOpFeeLocked(account, OpWithdrawal, amount, TransferRequest{From: number}, func() ([]postedEntry, error) {
+		tx, events, err := b.postLocked(account, TransactionWithdrawal, amount, opts)
+		return []postedEntry{{number, tx, events}}, err
+	})
+	unlock()
+	b.publishPosted(posted)
+	return err
+}
+
+func (b *Bank) transfer(from, to string, amount float64, opts []TxOption) error {
+	var ends [2]BankAccount
+	for i, number := range []string{from, to} {
+		account, err := b.Account(number)
+		if err != nil {
+			return err
+		}
+		ends[i] = account
+	}
+	rate, err := b.exchangeRate(ends[0], ends[1])
+	if err != nil {
+		return err
+	}
+	accounts, unlock := b.lockAccounts(from, to)
+	posted, err := b.postWithOpFeeLocked(accounts[from], OpTransfer, amount, TransferRequest{From: from, To: to}, func() ([]postedEntry, error) {
+		return b.transferLocked(accounts[from], accounts[to], amount, rate, opts)
+	})
+	unlock()
+	b.publishPosted(posted)
+	return err
+}
+
+func (b *Bank) post(number string, kind TransactionType, amount float64, opts []TxOption) error {
+	account, unlock, err := b.lockAccount(number)
+	if err != nil {
+		return err
+	}
+	tx, events, err := b.postLocked(account, kind, amount, opts)
+	unlock()
+
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	if err != nil {
+		return err
+	}
+	b.Events.Publish(Event{Type: EventTransactionPosted, AccountNumber: number, Transaction: &tx, Time: tx.Time})
+	return nil
+}
+
+// postLocked applies the bank's checks to a transaction and posts it. It
+// returns the posted entry and the other events to publish once b.mu is
+// released, such as budget warnings or the earlier legs of a payment into a
+// loan or card. The caller holds b.mu, or its read lock and the lock of the
+// account, and postLocked only reads the bank's own state.
+func (b *Bank) postLocked(account BankAccount, kind TransactionType, amount float64, opts []TxOption) (Transaction, []Event, error) {
+	number := account.Number()
+	tx := Transaction{Type: kind, Amount: amount, Time: b.nowIn(number)}
+	if err := b.checkOpen(number); err != nil {
+		return tx, nil, err
+	}
+	for _, opt := range opts {
+		opt(&tx)
+	}
+	// Loans and cards go below zero by design.
+	_, credit := account.(creditLine)
+	if kind == TransactionWithdrawal && !credit && tx.Amount > account.CheckBalance() && !b.Flags.Enabled(b.Tenant, FeatureOverdraft) {
+		return tx, nil, ErrInsufficientFunds
+	}
+	// Pending transfers hold part of the balance.
+	if held := b.held[number]; kind == TransactionWithdrawal && held > 0 &&
+		account.CheckBalance()-held-tx.Amount < -b.overdraftLimit(account) {
+		return tx, nil, ErrInsufficientFunds
+	}
+	if err := b.checkProductLimits(account, tx); err != nil {
+		return tx, nil, err
+	}
+	if err := b.checkControls(account, tx); err != nil {
+		return tx, nil, err
+	}
+	if err := b.checkDormant(number, kind); err != nil {
+		return tx, nil, err
+	}
+	if err := b.checkEstate(number, kind); err != nil {
+		return tx, nil, err
+	}
+	var events []Event
+	if kind == TransactionWithdrawal && tx.Category != "" {
+		var err error
+		if events, err = b.Budgets.check(account, tx); err != nil {
+			return tx, events, err
+		}
+	}
+	if _, credit := account.(creditLine); credit && kind == TransactionDeposit {
+		return b.postAllocated(account, tx, b.allocation(account))
+	}
+	if err := account.Post(tx); err != nil {
+		return tx, nil, err
+	}
+	history := account.History()
+	return history[len(history)-1], events, nil
+}
+
+// BudgetReport returns budget vs actual spending of an account for a month.
+func (b *Bank) BudgetReport(number string, year int, month time.Month) ([]BudgetLine, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Budgets.Report(account, year, month), nil
+}
+
+// ApplyInterest credits (or, with a negative rate, charges) interest on a
+// savings account. A variable rate is first reset from the reference rate;
+// the reset is recorded on the account and published as EventRateReset.
+// Rate changes that have taken effect are applied first too.
+// Negative rates need FeatureNegativeInterest.
+func (b *Bank) ApplyInterest(number string) error {
+	account, err := b.Account(number)
+	if err != nil {
+		return err
+	}
+	if loan, ok := account.(*LoanAccount); ok {
+		return b.chargeInterest(loan)
+	}
+	if accruer, ok := account.(Accruer); ok {
+		return b.accrue(account, accruer)
+	}
+	savings, ok := account.(*SavingsAccount)
+	if !ok {
+		return banking.New(banking.CodeInvalidArgument, fmt.Sprintf("account %s does not earn interest", number))
+	}
+	// A rate change that took effect since the last accrual splits it.
+	b.ApplyRateChanges()
+
+	var reset *RateReset
+	b.mu.Lock()
+	rate := savings.InterestRate
+	b.mu.Unlock()
+	if savings.Variable != nil {
+		var reference money.Rate
+		rate, reference, err = savings.Variable.Effective(b.Rates)
+		if err != nil {
+			return err
+		}
+		reset = &RateReset{Time: b.now(), Reference: savings.Variable.Reference, ReferenceRate: reference, To: rate}
+	}
+	if rate < 0 && !b.Flags.Enabled(b.Tenant, FeatureNegativeInterest) {
+		return fmt.Errorf("%w: %s", ErrFeatureDisabled, FeatureNegativeInterest)
+	}
+
+	b.mu.Lock()
+	if reset != nil {
+		if reset.From = savings.InterestRate; reset.From != reset.To {
+			savings.InterestRate = rate
+			savings.RateResets = append(savings.RateResets, *reset)
+		} else {
+			reset = nil
+		}
+	}
+	var dayCount money.DayCount
+	if p, ok := b.productOf(savings); ok {
+		dayCount = p.DayCount
+	}
+	posted := len(savings.History())
+	err = savings.applyInterest(b.Rounding, b.now(), dayCount)
+	history := savings.History()
+	b.mu.Unlock()
+
+	if reset != nil {
+		b.Events.Publish(Event{Type: EventRateReset, AccountNumber: number, Time: reset.Time,
+			Message: fmt.Sprintf("Rate of %s reset from %s to %s (%s %s)", number, reset.From, reset.To, reset.Reference, reset.ReferenceRate)})
+	}
+	if err != nil {
+		return err
+	}
+	if len(history) > posted {
+		tx := history[len(history)-1]
+		b.Events.Publish(Event{Type: EventTransactionPosted, AccountNumber: number, Transaction: &tx, Time: tx.Time})
+	}
+	return nil
+}
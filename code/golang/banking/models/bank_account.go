@@ -1,16 +1,55 @@
 package models
 
-import "fmt"
+import (
+	"time"
+
+	"gsolano/banking/i18n"
+	"gsolano/banking/money"
+)
 
 type BankAccount interface {
-	Deposit(amount float64)
-	Withdraw(amount float64)
+	Deposit(amount float64) error
+	Withdraw(amount float64) error
+	Post(tx Transaction) error
 	CheckBalance() float64
+	Number() string
+	History() []Transaction
+}
+
+// KindOf names the kind of an account for display: Savings, Checking, Loan,
+// CreditCard, the AccountKind of a Custom account or Account for other
+// implementations.
+func KindOf(account BankAccount) string {
+	switch a := account.(type) {
+	case *SavingsAccount:
+		return "Savings"
+	case *CheckingAccount:
+		return "Checking"
+	case *LoanAccount:
+		return "Loan"
+	case *CreditCardAccount:
+		return "CreditCard"
+	case Custom:
+		return a.AccountKind()
+	default:
+		return "Account"
+	}
+}
+
+// creditLine is implemented by accounts whose balance is money lent by the
+// bank and may go down to -creditLimit.
+type creditLine interface {
+	creditLimit() float64
 }
 
+// SavingsAccount earns InterestRate per period. When Variable is set
+// the bank resets InterestRate from the reference rate before each accrual
+// and records every change in RateResets.
 type SavingsAccount struct {
 	Account
-	InterestRate float64
+	InterestRate money.Rate
+	Variable     *VariableRate
+	RateResets   []RateReset
 }
 
 type CheckingAccount struct {
@@ -18,21 +57,51 @@ type CheckingAccount struct {
 	OverdraftLimit float64
 }
 
-func (sa *SavingsAccount) ApplyInterest() {
-	interest := sa.Balance * sa.InterestRate / 100
-	sa.Deposit(interest)
-	fmt.Printf("Applied interest: %.2f\n", interest)
+// ApplyInterest posts one period of interest on the balance. A negative
+// InterestRate posts an interest charge that lowers the balance rather than a
+// negative deposit. Nothing accrues on a balance of zero or less. Interest is
+// rounded to the cent with banker's rounding.
+func (sa *SavingsAccount) ApplyInterest() error {
+	return sa.applyInterest(money.HalfEven, time.Now(), "")
 }
 
-func (ca *CheckingAccount) Withdraw(amount float64) {
-	if amount <= 0 {
-		fmt.Println("Withdrawal amount must be positive.")
-		return
+// applyInterest posts the interest due at: one period of it, or with a
+// day-count convention the annual rate on the daily balance since the last
+// interest entry, counted under it.
+func (sa *SavingsAccount) applyInterest(rounding money.Rounding, at time.Time, dayCount money.DayCount) error {
+	interest := sa.InterestRate.Of(money.New(sa.Balance, CurrencyOf(sa), rounding), rounding).Float()
+	var metadata map[string]string
+	if dayCount != "" {
+		exact := dayCountInterest(sa.Transactions, sa.Balance, sa.InterestRate, sa.RateResets, dayCount, at)
+		interest = money.Round(ratFloat(exact), CurrencyOf(sa), rounding)
+		metadata = map[string]string{MetaDayCount: string(dayCount)}
 	}
-	if amount > ca.Balance+ca.OverdraftLimit {
-		fmt.Println("Insufficient funds, even with overdraft.")
-		return
+	switch {
+	case sa.Balance <= 0 || interest == 0:
+		return nil
+	case interest > 0:
+		if err := sa.Post(Transaction{Type: TransactionInterest, Amount: interest, Rate: sa.InterestRate, Time: at, Metadata: metadata}); err != nil {
+			return err
+		}
+		messages.Println(i18n.MsgAppliedInterest, interest)
+	default:
+		if err := sa.Post(Transaction{Type: TransactionInterestCharge, Amount: -interest, Rate: sa.InterestRate, Time: at, Metadata: metadata}); err != nil {
+			return err
+		}
+		messages.Println(i18n.MsgChargedInterest, -interest)
 	}
-	ca.Balance -= amount
-	fmt.Printf("Withdrew: %.2f\n", amount)
+	return nil
+}
+
+func (ca *CheckingAccount) Withdraw(amount float64) error {
+	return ca.WithdrawTo("", amount)
+}
+
+func (ca *CheckingAccount) WithdrawTo(counterparty string, amount float64) error {
+	return ca.Post(Transaction{Type: TransactionWithdrawal, Amount: amount, Counterparty: counterparty})
+}
+
+// Post is like Account.Post but lets the balance go down to -OverdraftLimit.
+func (ca *CheckingAccount) Post(tx Transaction) error {
+	return ca.post(tx, ca.OverdraftLimit)
 }
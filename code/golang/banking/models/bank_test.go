@@ -0,0 +1,546 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"gsolano/banking"
+)
+
+// TestConcurrentTransfersDoNotDeadlock runs transfers both ways between a
+// few hot accounts from many goroutines, alongside the reads and bank-wide
+// operations that take the bank's lock, and fails if they have not all
+// finished within the deadline or if money appeared or vanished.
+func TestConcurrentTransfersDoNotDeadlock(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	const accounts = 4
+	numbers := make([]string, accounts)
+	for i := range numbers {
+		numbers[i] = fmt.Sprintf("H%d", i+1)
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: numbers[i], Balance: 1000}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Open(&CreditCardAccount{Account: Account{AccountNumber: "K1"}, CreditLimit: 500}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				from, to := numbers[r.Intn(accounts)], numbers[r.Intn(accounts)]
+				switch r.Intn(10) {
+				case 0:
+					b.Balance(from)
+					b.History(to)
+				case 1:
+					b.Accounts()
+					b.Search(Query{Account: from})
+				case 2:
+					b.BillCards()
+					if i%100 == 0 {
+						b.Snapshot(io.Discard)
+					}
+				case 3:
+					b.SetAlias(from, Alias{Nickname: fmt.Sprintf("hot %d", r.Intn(3))})
+				default:
+					// Errors, such as insufficient funds or a transfer to
+					// the same account, are expected.
+					b.Transfer(from, to, float64(1+r.Intn(300)))
+				}
+			}
+		}(int64(g))
+	}
+	waitOrDeadlock(t, &wg)
+
+	total := 0.0
+	for _, number := range numbers {
+		balance, err := b.Balance(number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += balance
+	}
+	if math.Abs(total-accounts*1000) > 0.005 {
+		t.Errorf("the accounts hold %.2f, want %.2f", total, float64(accounts*1000))
+	}
+}
+
+// TestRegistryShards checks that accounts spread over the shards are all
+// found, listed and removed.
+func TestRegistryShards(t *testing.T) {
+	s := newAccountShards()
+	for i := 0; i < 1000; i++ {
+		if !s.add(&SavingsAccount{Account: Account{AccountNumber: fmt.Sprintf("S%04d", i)}}) {
+			t.Fatalf("S%04d was not added", i)
+		}
+	}
+	if s.add(&SavingsAccount{Account: Account{AccountNumber: "S0042"}}) {
+		t.Error("S0042 was added twice")
+	}
+	if n := len(s.all()); n != 1000 {
+		t.Errorf("all returned %d accounts, want 1000", n)
+	}
+	used := 0
+	for i := range s.shards {
+		if len(s.shards[i].entries) > 0 {
+			used++
+		}
+	}
+	if used < shardCount/2 {
+		t.Errorf("accounts fell into %d of %d shards", used, shardCount)
+	}
+	s.remove("S0042")
+	if s.account("S0042") != nil || s.account("S0043") == nil {
+		t.Error("remove did not remove exactly S0042")
+	}
+}
+
+// waitOrDeadlock waits for wg and fails t if it takes so long that the
+// goroutines must be deadlocked.
+func waitOrDeadlock(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("concurrent operations did not finish: deadlock")
+	}
+}
+
+// TestOpposingTransfersDoNotDeadlock runs transfers and batches over the
+// same accounts named in opposite orders, which deadlock if each takes the
+// account it names first and waits for the other.
+func TestOpposingTransfersDoNotDeadlock(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	for _, number := range []string{"A", "B", "C"} {
+		if err := b.Open(&SavingsAccount{Account: Account{AccountNumber: number, Balance: 1e6}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				var err error
+				switch g % 4 {
+				case 0:
+					err = b.Transfer("A", "B", 1)
+				case 1:
+					err = b.Transfer("B", "A", 1)
+				case 2:
+					results := b.TransferBatch([]TransferRequest{{From: "C", To: "A", Amount: 1}, {From: "B", To: "C", Amount: 1}}, AllOrNothing())
+					err = results[0].Err
+				default:
+					results := b.TransferBatch([]TransferRequest{{From: "A", To: "C", Amount: 1}, {From: "C", To: "B", Amount: 1}})
+					err = results[1].Err
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	waitOrDeadlock(t, &wg)
+	for _, number := range []string{"A", "B", "C"} {
+		if balance, _ := b.Balance(number); balance != 1e6 {
+			t.Errorf("%s has %.2f, want %.2f", number, balance, 1e6)
+		}
+	}
+}
+
+// TestTransferUndoesWithdrawal checks that a transfer whose deposit fails
+// leaves the source account as it was.
+func TestTransferUndoesWithdrawal(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "A", Balance: 100}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "Z"}})
+	if err := b.CloseAccount("Z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer("A", "Z", 40); !errors.Is(err, ErrAccountClosed) {
+		t.Fatalf("Transfer to a closed account returned %v, want %v", err, ErrAccountClosed)
+	}
+	balance, _ := b.Balance("A")
+	history, _ := b.History("A")
+	if balance != 100 || len(history) != 0 {
+		t.Errorf("A has %.2f and %d entries after the failed transfer, want 100.00 and none", balance, len(history))
+	}
+}
+
+// TestSearchTransactions runs each kind of search term over a few ledgers,
+// checking which entries match and in what order, that cursors continue a
+// search where its page ended, and that malformed queries are refused.
+func TestSearchTransactions(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	for _, p := range []struct {
+		date, account string
+		amount        float64
+		opts          []TxOption
+	}{
+		{"2026-01-05", "C1", 1000, []TxOption{WithDescription("Salary"), WithCounterparty("ACME Corp"), WithCategory("income")}},
+		{"2026-01-10", "C1", -45.5, []TxOption{WithDescription("Electric bill"), WithCounterparty("City Power"), WithCategory("utilities"), WithTags("home")}},
+		{"2026-01-15", "C1", -12, []TxOption{WithDescription("Coffee"), WithCounterparty("Blue Bottle"), WithCategory("dining")}},
+		{"2026-02-01", "C1", -800, []TxOption{WithDescription("Rent"), WithCounterparty("Landlord"), WithTags("home", "rent")}},
+		{"2026-02-02", "C2", 100, []TxOption{WithDescription("Gift")}},
+	} {
+		clock.now = date(t, p.date)
+		var err error
+		if p.amount > 0 {
+			err = b.Deposit(p.account, p.amount, p.opts...)
+		} else {
+			err = b.Withdraw(p.account, -p.amount, p.opts...)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	descriptions := func(r SearchResult) []string {
+		var found []string
+		for _, m := range r.Items {
+			found = append(found, m.Transaction.Description)
+		}
+		return found
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"account:C1 type:withdrawal", []string{"Electric bill", "Coffee", "Rent"}},
+		{"amount>=45.5 amount<800", []string{"Electric bill", "Gift"}},
+		{"amount>45.5 amount<=800", []string{"Rent", "Gift"}},
+		{"amount:12", []string{"Coffee"}},
+		{"counterparty:power", []string{"Electric bill"}},
+		{"category:DINING", []string{"Coffee"}},
+		{"tag:home tag:rent", []string{"Rent"}},
+		{"since:2026-01-10 until:2026-02-01", []string{"Electric bill", "Coffee"}},
+		{`"electric bill"`, []string{"Electric bill"}},
+		{"bill city", []string{"Electric bill"}},
+		{"type:withdrawal sort:-amount", []string{"Rent", "Electric bill", "Coffee"}},
+		{"re:invoice", nil},
+	} {
+		r, err := b.SearchTransactions(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := descriptions(r); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	first, err := b.SearchTransactions("type:withdrawal limit:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := b.SearchTransactions("type:withdrawal limit:2 after:" + first.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(descriptions(first), descriptions(rest)...); !first.HasMore || rest.HasMore || !slices.Equal(got, []string{"Electric bill", "Coffee", "Rent"}) {
+		t.Errorf("pages = %v (more: %v, %v), want all three withdrawals over two pages", got, first.HasMore, rest.HasMore)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  banking.Code
+	}{
+		{"sort:name", banking.CodeInvalidArgument},
+		{"limit:0", banking.CodeInvalidArgument},
+		{"since:yesterday", banking.CodeInvalidArgument},
+		{"amount>lots", banking.CodeInvalidArgument},
+		{"type:withdrawal after:" + first.NextCursor + "x", banking.CodeInvalidArgument},
+		{"account:C9", banking.CodeNotFound},
+	} {
+		if _, err := b.SearchTransactions(tt.query); banking.CodeOf(err) != tt.want {
+			t.Errorf("%s: %v, want %s", tt.query, err, tt.want)
+		}
+	}
+}
+
+// TestTextIndex checks that the full-text index finds entries posted
+// before and after it was made, by description and counterparty, ranks
+// entries that repeat a word or are shorter first, and follows accounts
+// that are renumbered or removed.
+func TestTextIndex(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	b.Deposit("C1", 1000, WithDescription("Coffee at Blue Bottle"), WithCounterparty("Blue Bottle"))
+	ix := NewTextIndex(b)
+	defer ix.Close()
+	b.Withdraw("C1", 5, WithDescription("Blue Bottle"), WithCounterparty("Blue Bottle Coffee"))
+	b.Withdraw("C1", 60, WithDescription("Electric bill"), WithCounterparty("City Power"))
+	b.Deposit("C2", 50, WithDescription("Coffee beans"), WithCounterparty("Grocer"))
+
+	found := func(text string, limit int) []string {
+		var descriptions []string
+		for _, m := range ix.Search(text, limit) {
+			descriptions = append(descriptions, m.Account+" "+m.Transaction.Description)
+		}
+		return descriptions
+	}
+	for _, tt := range []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"ELECTRIC", 0, []string{"C1 Electric bill"}},
+		{"power", 0, []string{"C1 Electric bill"}},
+		// Twice in the counterparty, then in the shortest entry.
+		{"coffee", 0, []string{"C1 Blue Bottle", "C2 Coffee beans", "C1 Coffee at Blue Bottle"}},
+		{"coffee", 1, []string{"C1 Blue Bottle"}},
+		{"electric coffee", 2, []string{"C1 Electric bill", "C1 Blue Bottle"}},
+		{"tea", 0, nil},
+		{"", 0, nil},
+	} {
+		if got := found(tt.text, tt.limit); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q, %d) = %v, want %v", tt.text, tt.limit, got, tt.want)
+		}
+	}
+
+	history, _ := b.History("C1")
+	ix.Add("C1", history[len(history)-1])
+	if got := found("electric", 0); len(got) != 1 {
+		t.Errorf("after adding an entry again, found %v, want it once", got)
+	}
+	if _, err := b.RenumberAccount("C2", "C3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := found("beans", 0); !slices.Equal(got, []string{"C3 Coffee beans"}) {
+		t.Errorf("after renumbering, found %v, want the entry under C3", got)
+	}
+	ix.Remove("C1")
+	if got := found("coffee electric", 0); !slices.Equal(got, []string{"C3 Coffee beans"}) {
+		t.Errorf("after removing C1, found %v, want only C3's entry", got)
+	}
+}
+
+// failingArchive refuses every blob.
+type failingArchive struct{ *MemoryArchive }
+
+func (failingArchive) Put(string, []byte) error { return errors.New("disk full") }
+
+// TestArchiveClosed checks what may be closed, that closed accounts take no
+// entries, that they are archived once the retention period has passed and
+// read back whole from the archive, and that a failed archive write keeps
+// the account in the bank.
+func TestArchiveClosed(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	for _, number := range []string{"C1", "C2", "C3"} {
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: number}})
+	}
+	b.Deposit("C1", 100)
+	b.Withdraw("C1", 100)
+	b.Deposit("C2", 100)
+	if _, err := b.BookTransfer("C2", "C3", 50, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		number string
+		err    error
+	}{
+		{"C2", ErrBalanceNotZero},
+		{"C3", ErrPendingTransfers},
+		{"C1", nil},
+		{"C1", ErrAccountClosed},
+		{"C9", ErrAccountNotFound},
+	} {
+		if err := b.CloseAccount(tt.number); !errors.Is(err, tt.err) {
+			t.Errorf("closing %s: %v, want %v", tt.number, err, tt.err)
+		}
+	}
+	if err := b.Deposit("C1", 10); !errors.Is(err, ErrAccountClosed) {
+		t.Errorf("deposit to a closed account: %v, want ErrAccountClosed", err)
+	}
+
+	for _, tt := range []struct {
+		now  string
+		want []string
+	}{
+		{"2026-01-15", nil},
+		{"2026-02-15", []string{"C1"}},
+		{"2026-03-15", nil},
+	} {
+		archived, err := b.ArchiveClosed(30*24*time.Hour, date(t, tt.now))
+		if err != nil || !slices.Equal(archived, tt.want) {
+			t.Errorf("archiving on %s = %v, %v, want %v", tt.now, archived, err, tt.want)
+		}
+	}
+	if _, err := b.Account("C1"); err == nil {
+		t.Error("archived account C1 is still in the bank")
+	}
+	if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}}); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("reopening C1: %v, want ErrAccountExists", err)
+	}
+	a, err := b.GetArchivedAccount("C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Account.History()) != 2 || !a.Closed.Equal(date(t, "2026-01-01")) || !a.Archived.Equal(date(t, "2026-02-15")) {
+		t.Errorf("archived C1 = %d entries, closed %s, archived %s", len(a.Account.History()), a.Closed, a.Archived)
+	}
+	if _, err := b.GetArchivedAccount("C2"); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("reading open C2 from the archive: %v, want ErrNotArchived", err)
+	}
+
+	b.Archive = failingArchive{NewMemoryArchive()}
+	if _, err := b.Settle(date(t, "2026-03-01")); err != nil {
+		t.Fatal(err)
+	}
+	b.Withdraw("C3", 50)
+	if err := b.CloseAccount("C3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ArchiveClosed(0, date(t, "2026-04-01")); err == nil {
+		t.Error("archiving into a failing archive succeeded")
+	}
+	if _, err := b.Account("C3"); err != nil {
+		t.Errorf("C3 after a failed archive: %v", err)
+	}
+}
+
+// pointsAccount is a custom kind of account that earns Points a day on its
+// balance, pays a one-off fee and shows its points on its statements.
+type pointsAccount struct {
+	Account
+	Points float64 `json:"points"`
+}
+
+func init() {
+	RegisterAccountType("Points", func(ledger Account, state []byte) (Custom, error) {
+		a := &pointsAccount{Account: ledger}
+		if len(state) == 0 {
+			return a, nil
+		}
+		return a, json.Unmarshal(state, a)
+	})
+}
+
+func (a *pointsAccount) AccountKind() string {
+	return "Points"
+}
+
+func (a *pointsAccount) MarshalState() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func (a *pointsAccount) Accrue(at time.Time) []Transaction {
+	a.Points += a.CheckBalance() / 100
+	return []Transaction{{Type: TransactionInterest, Amount: 1, Description: "points bonus"}}
+}
+
+func (a *pointsAccount) AssessFees(at time.Time) []Transaction {
+	for _, tx := range a.History() {
+		if tx.Type == TransactionFee {
+			return nil
+		}
+	}
+	return []Transaction{{Type: TransactionFee, Amount: 5, Description: "joining fee"}}
+}
+
+func (a *pointsAccount) StatementLines(period Period) []StatementLine {
+	return []StatementLine{{Label: "points", Amount: a.Points}}
+}
+
+// TestCustomAccountType opens an account of a registered kind, checks that
+// the bank posts what its hooks return and adds its statement lines, and
+// that it comes back from a snapshot with its state, while kinds that are
+// unknown, built in or registered twice are refused.
+func TestCustomAccountType(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Clock = &testClock{now: date(t, "2026-03-02")}
+	if err := b.Open(&pointsAccount{Account: Account{AccountNumber: "P1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deposit("P1", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ApplyInterest("P1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range [][]string{{"P1"}, nil} {
+		if charged := b.AssessFees(); !slices.Equal(charged, want) {
+			t.Errorf("fees charged to %v, want %v", charged, want)
+		}
+	}
+	balance, _ := b.Balance("P1")
+	if balance != 996 {
+		t.Errorf("balance %.2f, want 996.00", balance)
+	}
+	statement, err := b.Statement("P1", date(t, "2026-03-03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []StatementLine{{Label: "points", Amount: 10}}; !slices.Equal(statement.Lines, want) {
+		t.Errorf("statement lines %v, want %v", statement.Lines, want)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	account, err := restored.Account("P1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := account.(*pointsAccount); !ok || a.Points != 10 || a.CheckBalance() != 996 || KindOf(a) != "Points" {
+		t.Errorf("restored %s %+v, want Points with 10 points and 996.00", KindOf(account), account)
+	}
+
+	if _, err := RestoreCustom("Miles", Account{AccountNumber: "M1"}, nil); !errors.Is(err, ErrUnknownAccountKind) {
+		t.Errorf("restoring an unknown kind: %v, want ErrUnknownAccountKind", err)
+	}
+	if kinds := AccountKinds(); !slices.Contains(kinds, "Points") {
+		t.Errorf("kinds %v, want Points among them", kinds)
+	}
+	for _, kind := range []string{"Points", "Savings", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q did not panic", kind)
+				}
+			}()
+			RegisterAccountType(kind, nil)
+		}()
+	}
+}
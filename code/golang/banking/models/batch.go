@@ -0,0 +1,294 @@
+package models
+
+import (
+	"fmt"
+
+	"gsolano/banking"
+)
+
+// ErrBatchAborted is reported for the transfers of an all-or-nothing batch
+// that were not executed because another transfer of the batch failed.
+var ErrBatchAborted = banking.New(banking.CodeConflict, "batch aborted: another transfer failed")
+
+// TransferRequest is one transfer of a batch. Metadata is recorded on both
+// legs.
+type TransferRequest struct {
+	From, To string
+	Amount   float64
+	Category string
+	Metadata map[string]string
+}
+
+// TransferResult reports what happened to one transfer of a batch. Err is
+// nil when the transfer succeeded or, in a dry run, would succeed. Withdrawal
+// and Deposit are the ledger entries a successful transfer posted, or would
+// post.
+type TransferResult struct {
+	Request    TransferRequest
+	Err        error
+	Withdrawal Transaction
+	Deposit    Transaction
+}
+
+func (r TransferResult) Succeeded() bool {
+	return r.Err == nil
+}
+
+type batchConfig struct {
+	allOrNothing bool
+	dryRun       bool
+}
+
+// BatchOption customizes how TransferBatch runs a batch.
+type BatchOption func(*batchConfig)
+
+// AllOrNothing executes the batch only if every transfer can be made;
+// otherwise no transfer is made.
+func AllOrNothing() BatchOption {
+	return func(c *batchConfig) { c.allOrNothing = true }
+}
+
+// DryRun carries out the batch on a copy of the bank, as Bank.DryRun does,
+// so that the results are those of every check, budgets included, without
+// moving any money.
+func DryRun() BatchOption {
+	return func(c *batchConfig) { c.dryRun = true }
+}
+
+// TransferBatch validates and executes many transfers, in order, and returns
+// one result per request. The whole batch holds every account it names, so
+// each transfer is validated against the balances the previous ones left.
+// Without AllOrNothing, failed transfers are skipped and the others go
+// ahead. Validation covers accounts, amounts and funds; an enforced budget is
+// only checked when the transfer executes, and under AllOrNothing its failure
+// rolls back the transfers already made.
+func (b *Bank) TransferBatch(requests []TransferRequest, opts ...BatchOption) []TransferResult {
+	var cfg batchConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.dryRun {
+		return b.dryRunBatch(requests, cfg)
+	}
+	results := make([]TransferResult, len(requests))
+	for i, req := range requests {
+		results[i].Request = req
+	}
+
+	numbers := make([]string, 0, 2*len(requests))
+	for _, req := range requests {
+		numbers = append(numbers, req.From, req.To)
+	}
+	accounts, unlock := b.lockAccounts(numbers...)
+	failed := b.validateBatch(results, accounts)
+	if failed && cfg.allOrNothing {
+		unlock()
+		abortRest(results)
+		return results
+	}
+
+	var batch ledgerSnapshot
+	if cfg.allOrNothing {
+		batch = b.snapshot(requests)
+	}
+	var posted []postedEntry
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		req := results[i].Request
+		opts := []TxOption{WithCategory(req.Category)}
+		for key, value := range req.Metadata {
+			opts = append(opts, WithMetadata(key, value))
+		}
+		entries, err := b.postWithOpFeeLocked(accounts[req.From], OpTransfer, req.Amount, TransferRequest{From: req.From, To: req.To}, func() ([]postedEntry, error) {
+			return b.transferLocked(accounts[req.From], accounts[req.To], req.Amount, nil, opts)
+		})
+		if err != nil {
+			results[i].Err = err
+			if cfg.allOrNothing {
+				batch.restore()
+				posted = nil
+				for j := range results[:i] {
+					results[j].Withdrawal, results[j].Deposit = Transaction{}, Transaction{}
+				}
+				abortRest(results)
+				break
+			}
+			continue
+		}
+		results[i].Withdrawal, results[i].Deposit = entries[0].tx, entries[1].tx
+		posted = append(posted, entries...)
+	}
+	unlock()
+	b.publishPosted(posted)
+	return results
+}
+
+// dryRunBatch runs a batch on a rehearsal of the bank.
+func (b *Bank) dryRunBatch(requests []TransferRequest, cfg batchConfig) []TransferResult {
+	var numbers []string
+	for _, req := range requests {
+		numbers = append(numbers, req.From, req.To)
+	}
+	c, err := b.rehearsal(numbers)
+	if err != nil {
+		results := make([]TransferResult, len(requests))
+		for i, req := range requests {
+			results[i] = TransferResult{Request: req, Err: err}
+		}
+		return results
+	}
+	cfg.dryRun = false
+	return c.TransferBatch(requests, func(c *batchConfig) { *c = cfg })
+}
+
+// validateBatch checks every transfer against a running copy of the
+// balances and reports whether any failed. The caller holds the accounts,
+// locked with lockAccounts.
+func (b *Bank) validateBatch(results []TransferResult, accounts map[string]BankAccount) bool {
+	balances := make(map[string]float64)
+	failed := false
+	for i := range results {
+		req := results[i].Request
+		if err := b.validateTransfer(req, accounts, balances); err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		balances[req.From] -= req.Amount + b.opFee(accounts[req.From], OpTransfer)
+		balances[req.To] += req.Amount
+	}
+	return failed
+}
+
+func (b *Bank) validateTransfer(req TransferRequest, accounts map[string]BankAccount, balances map[string]float64) error {
+	from, to := accounts[req.From], accounts[req.To]
+	if from == nil || to == nil {
+		return ErrAccountNotFound
+	}
+	if req.From == req.To {
+		return banking.New(banking.CodeInvalidArgument, "cannot transfer to the same account")
+	}
+	if err := b.checkOpen(req.From); err != nil {
+		return err
+	}
+	if err := b.checkOpen(req.To); err != nil {
+		return err
+	}
+	if err := checkAmount(req.Amount); err != nil {
+		return err
+	}
+	if b.AccountCurrency(from) != b.AccountCurrency(to) {
+		return fmt.Errorf("%w: batches transfer within one currency", ErrCurrencyMismatch)
+	}
+	if _, ok := balances[req.From]; !ok {
+		balances[req.From] = from.CheckBalance() - b.held[req.From]
+	}
+	if _, ok := balances[req.To]; !ok {
+		balances[req.To] = to.CheckBalance() - b.held[req.To]
+	}
+	if balances[req.From]-req.Amount-b.opFee(from, OpTransfer) < -b.overdraftLimit(from) {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+func abortRest(results []TransferResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = ErrBatchAborted
+		}
+	}
+}
+
+type postedEntry struct {
+	account string
+	tx      Transaction
+	events  []Event
+}
+
+// transferLocked posts both legs of a transfer, undoing the withdrawal if
+// the deposit fails. Between currencies, rate converts the amount credited;
+// without one, accounts in different currencies are refused. It returns what was posted, and the events of a
+// withdrawal that failed, such as a budget being exhausted. The caller holds
+// both accounts, locked with lockAccounts, or b.mu.
+func (b *Bank) transferLocked(from, to BankAccount, amount float64, rate *ExchangeRate, opts []TxOption) ([]postedEntry, error) {
+	if from == nil || to == nil {
+		return nil, ErrAccountNotFound
+	}
+	credit := amount
+	if rate != nil {
+		credit = b.convert(amount, *rate)
+		opts = append(withExchangeRate(*rate), opts...)
+	} else if b.AccountCurrency(from) != b.AccountCurrency(to) {
+		return nil, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, from.Number(), to.Number())
+	}
+	opts = append(opts, WithMetadata(MetaTransferID, b.NewID()))
+	undo := b.snapshot([]TransferRequest{{From: from.Number(), To: to.Number()}})
+	withdrawal, events, err := b.postLocked(from, TransactionWithdrawal, amount, append([]TxOption{WithCounterparty(to.Number())}, opts...))
+	if err != nil {
+		return []postedEntry{{account: from.Number(), events: events}}, err
+	}
+	deposit, legs, err := b.postLocked(to, TransactionDeposit, credit, append([]TxOption{WithCounterparty(from.Number())}, opts...))
+	if err != nil {
+		undo.restore()
+		return nil, err
+	}
+	return []postedEntry{{from.Number(), withdrawal, events}, {to.Number(), deposit, legs}}, nil
+}
+
+// publishPosted publishes the events of entries once their accounts are
+// released, and each entry that was posted.
+func (b *Bank) publishPosted(posted []postedEntry) {
+	for _, p := range posted {
+		for _, e := range p.events {
+			b.Events.Publish(e)
+		}
+		if p.tx.Sequence == 0 {
+			continue
+		}
+		tx := p.tx
+		b.Events.Publish(Event{Type: EventTransactionPosted, AccountNumber: p.account, Transaction: &tx, Time: tx.Time})
+	}
+}
+
+// ledgerSnapshot remembers the balance and ledger length of accounts so
+// entries posted afterwards can be undone.
+type ledgerSnapshot map[*Account]ledgerState
+
+type ledgerState struct {
+	balance float64
+	entries int
+}
+
+// ledgered is implemented by every account that embeds Account; others
+// cannot be rolled back.
+type ledgered interface {
+	ledger() *Account
+}
+
+// snapshot records the accounts the requests touch. The caller holds b.mu.
+func (b *Bank) snapshot(requests []TransferRequest) ledgerSnapshot {
+	snap := make(ledgerSnapshot)
+	for _, req := range requests {
+		for _, number := range []string{req.From, req.To} {
+			l, ok := b.accounts.account(number).(ledgered)
+			if !ok {
+				continue
+			}
+			a := l.ledger()
+			if _, seen := snap[a]; !seen {
+				snap[a] = ledgerState{a.Balance, len(a.Transactions)}
+			}
+		}
+	}
+	return snap
+}
+
+func (s ledgerSnapshot) restore() {
+	for a, state := range s {
+		a.Balance = state.balance
+		a.Transactions = a.Transactions[:state.entries]
+	}
+}
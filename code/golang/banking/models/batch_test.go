@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// batchBank returns a bank with C1 holding 100 and C2 and C3 empty, and a
+// count of the entries it publishes as posted.
+func batchBank(t *testing.T) (*Bank, *int) {
+	t.Helper()
+	SetOutput(io.Discard)
+	b := NewBank()
+	for _, number := range []string{"C1", "C2", "C3"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	posted := 0
+	b.Events.Subscribe(func(e Event) {
+		if e.Type == EventTransactionPosted {
+			posted++
+		}
+	})
+	return b, &posted
+}
+
+func checkBalances(t *testing.T, b *Bank, want map[string]float64) {
+	t.Helper()
+	for number, balance := range want {
+		if got, _ := b.Balance(number); got != balance {
+			t.Errorf("%s balance = %.2f, want %.2f", number, got, balance)
+		}
+	}
+}
+
+// TestTransferBatch checks that each transfer is validated against the
+// balances the ones before it leave, and that without AllOrNothing a failed
+// transfer is skipped while the others are made.
+func TestTransferBatch(t *testing.T) {
+	b, posted := batchBank(t)
+	results := b.TransferBatch([]TransferRequest{
+		{From: "C1", To: "C2", Amount: 60},
+		{From: "C2", To: "C3", Amount: 50},
+		{From: "C1", To: "C3", Amount: 60},
+		{From: "C1", To: "C1", Amount: 1},
+	})
+	if !results[0].Succeeded() || !results[1].Succeeded() {
+		t.Errorf("transfers within the running balances: %v, %v", results[0].Err, results[1].Err)
+	}
+	if !errors.Is(results[2].Err, ErrInsufficientFunds) || results[3].Err == nil {
+		t.Errorf("transfers beyond the running balance and to the same account: %v, %v", results[2].Err, results[3].Err)
+	}
+	if results[0].Withdrawal.Amount != 60 || results[1].Deposit.Amount != 50 {
+		t.Errorf("entries %+v, %+v", results[0].Withdrawal, results[1].Deposit)
+	}
+	checkBalances(t, b, map[string]float64{"C1": 40, "C2": 10, "C3": 50})
+	if *posted != 4 {
+		t.Errorf("published %d posted entries, want 4", *posted)
+	}
+}
+
+// TestTransferBatchAllOrNothing checks that an all-or-nothing batch with a
+// transfer that fails validation makes none, and that one whose transfer
+// fails when it executes, on an enforced budget, rolls back those already
+// made: balances, ledgers and results are as if none was, and nothing is
+// published.
+func TestTransferBatchAllOrNothing(t *testing.T) {
+	b, posted := batchBank(t)
+	results := b.TransferBatch([]TransferRequest{
+		{From: "C1", To: "C2", Amount: 60},
+		{From: "C1", To: "C3", Amount: 60},
+	}, AllOrNothing())
+	if !errors.Is(results[0].Err, ErrBatchAborted) || !errors.Is(results[1].Err, ErrInsufficientFunds) {
+		t.Errorf("batch failing validation: %v, %v", results[0].Err, results[1].Err)
+	}
+	checkBalances(t, b, map[string]float64{"C1": 100, "C2": 0, "C3": 0})
+
+	b.Budgets.Set("C1", Budget{Category: "rent", Limit: 50, Enforce: true})
+	before := len(historyOf(t, b, "C1"))
+	results = b.TransferBatch([]TransferRequest{
+		{From: "C1", To: "C2", Amount: 30, Category: "rent"},
+		{From: "C2", To: "C3", Amount: 10},
+		{From: "C1", To: "C3", Amount: 30, Category: "rent"},
+	}, AllOrNothing())
+	if !errors.Is(results[2].Err, ErrBudgetExhausted) {
+		t.Errorf("transfer over the enforced budget: %v, want %v", results[2].Err, ErrBudgetExhausted)
+	}
+	for _, r := range results[:2] {
+		if !errors.Is(r.Err, ErrBatchAborted) || r.Withdrawal.ID != "" || r.Deposit.ID != "" {
+			t.Errorf("rolled back transfer %+v, want it aborted without entries", r)
+		}
+	}
+	checkBalances(t, b, map[string]float64{"C1": 100, "C2": 0, "C3": 0})
+	if after := len(historyOf(t, b, "C1")); after != before {
+		t.Errorf("C1 has %d entries after the rollback, want %d", after, before)
+	}
+	for _, number := range []string{"C2", "C3"} {
+		if history := historyOf(t, b, number); len(history) != 0 {
+			t.Errorf("%s has entries %+v after the rollback", number, history)
+		}
+	}
+	if *posted != 0 {
+		t.Errorf("published %d posted entries for batches that made nothing", *posted)
+	}
+}
+
+func historyOf(t *testing.T, b *Bank, number string) []Transaction {
+	t.Helper()
+	account, err := b.Account(number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account.History()
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Budget is a monthly spending envelope for one category of an account.
+// When Enforce is set, withdrawals that would overspend the envelope are
+// rejected; otherwise they go through and a warning event is published.
+type Budget struct {
+	Category string
+	Limit    float64
+	Enforce  bool
+}
+
+// BudgetLine compares what was budgeted for a category in a month with what
+// was actually spent.
+type BudgetLine struct {
+	Category  string
+	Budgeted  float64
+	Actual    float64
+	Remaining float64
+}
+
+// Budgets holds the budgets of every account, keyed by account number and
+// category.
+type Budgets struct {
+	mu        sync.RWMutex
+	byAccount map[string]map[string]Budget
+}
+
+func NewBudgets() *Budgets {
+	return &Budgets{byAccount: make(map[string]map[string]Budget)}
+}
+
+// Set creates or replaces the budget for budget.Category on an account.
+func (b *Budgets) Set(number string, budget Budget) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.byAccount[number] == nil {
+		b.byAccount[number] = make(map[string]Budget)
+	}
+	b.byAccount[number][budget.Category] = budget
+}
+
+func (b *Budgets) Remove(number, category string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.byAccount[number], category)
+}
+
+func (b *Budgets) Get(number, category string) (Budget, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	budget, ok := b.byAccount[number][category]
+	return budget, ok
+}
+
+// Report returns budget vs actual for every category that was budgeted or
+// spent on during the given month, ordered by category.
+func (b *Budgets) Report(account BankAccount, year int, month time.Month) []BudgetLine {
+	lines := make(map[string]*BudgetLine)
+	line := func(category string) *BudgetLine {
+		if lines[category] == nil {
+			lines[category] = &BudgetLine{Category: category}
+		}
+		return lines[category]
+	}
+
+	b.mu.RLock()
+	for category, budget := range b.byAccount[account.Number()] {
+		line(category).Budgeted = budget.Limit
+	}
+	b.mu.RUnlock()
+
+	for _, tx := range account.History() {
+		if tx.Type != TransactionWithdrawal || tx.Category == "" {
+			continue
+		}
+		if y, m, _ := tx.Time.Date(); y == year && m == month {
+			line(tx.Category).Actual += tx.Amount
+		}
+	}
+
+	report := make([]BudgetLine, 0, len(lines))
+	for _, l := range lines {
+		l.Remaining = l.Budgeted - l.Actual
+		report = append(report, *l)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Category < report[j].Category })
+	return report
+}
+
+// check decides whether tx fits the budget of its category. It returns the
+// events to publish and, for enforced budgets, ErrBudgetExhausted when tx
+// would overspend.
+func (b *Budgets) check(account BankAccount, tx Transaction) ([]Event, error) {
+	budget, ok := b.Get(account.Number(), tx.Category)
+	if !ok {
+		return nil, nil
+	}
+
+	year, month, _ := tx.Time.Date()
+	spent := tx.Amount
+	for _, prior := range account.History() {
+		if prior.Type != TransactionWithdrawal || prior.Category != tx.Category {
+			continue
+		}
+		if y, m, _ := prior.Time.Date(); y == year && m == month {
+			spent += prior.Amount
+		}
+	}
+	if spent <= budget.Limit {
+		return nil, nil
+	}
+
+	e := Event{
+		AccountNumber: account.Number(),
+		Transaction:   &tx,
+		Message:       fmt.Sprintf("%s budget of %.2f exceeded: %.2f spent", tx.Category, budget.Limit, spent),
+		Time:          tx.Time,
+	}
+	if budget.Enforce {
+		e.Type = EventBudgetExhausted
+		return []Event{e}, ErrBudgetExhausted
+	}
+	e.Type = EventBudgetWarning
+	return []Event{e}, nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestBudgets spends against an enforced and a warning budget across two
+// months, checking that overspending the enforced one is refused, the other
+// only warns, spending starts over each month, and the report compares
+// what was budgeted and spent by category.
+func TestBudgets(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Budgets.Set("C1", Budget{Category: "dining", Limit: 100, Enforce: true})
+	b.Budgets.Set("C1", Budget{Category: "fun", Limit: 50})
+	var events []EventType
+	b.Events.Subscribe(func(e Event) {
+		if e.Type == EventBudgetWarning || e.Type == EventBudgetExhausted {
+			events = append(events, e.Type)
+		}
+	})
+	clock.now = date(t, "2026-01-02")
+	if err := b.Deposit("C1", 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		date     string
+		category string
+		amount   float64
+		want     error
+		event    EventType
+	}{
+		{date: "2026-01-05", category: "dining", amount: 60},
+		{date: "2026-01-10", category: "dining", amount: 50, want: ErrBudgetExhausted, event: EventBudgetExhausted},
+		{date: "2026-01-11", category: "dining", amount: 40},
+		{date: "2026-01-12", category: "fun", amount: 70, event: EventBudgetWarning},
+		{date: "2026-01-13", category: "travel", amount: 200},
+		// A new month, a new envelope.
+		{date: "2026-02-01", category: "dining", amount: 90},
+	} {
+		events = nil
+		clock.now = date(t, tt.date)
+		if err := b.Withdraw("C1", tt.amount, WithCategory(tt.category)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: spending %.2f on %s: %v, want %v", tt.date, tt.amount, tt.category, err, tt.want)
+		}
+		var want []EventType
+		if tt.event != "" {
+			want = []EventType{tt.event}
+		}
+		if !slices.Equal(events, want) {
+			t.Errorf("%s: events %v, want %v", tt.date, events, want)
+		}
+	}
+
+	report, err := b.BudgetReport("C1", 2026, time.January)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BudgetLine{
+		{Category: "dining", Budgeted: 100, Actual: 100, Remaining: 0},
+		{Category: "fun", Budgeted: 50, Actual: 70, Remaining: -20},
+		{Category: "travel", Actual: 200, Remaining: -200},
+	}
+	if !slices.Equal(report, want) {
+		t.Errorf("January report %+v, want %+v", report, want)
+	}
+	b.Budgets.Remove("C1", "dining")
+	if err := b.Withdraw("C1", 500, WithCategory("dining")); err != nil {
+		t.Errorf("spending without a budget: %v", err)
+	}
+}
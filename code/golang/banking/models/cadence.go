@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Cadence is how often something recurs: a payment, a pay run or the points
+// of a series.
+type Cadence string
+
+const (
+	CadenceWeekly   Cadence = "weekly"
+	CadenceBiweekly Cadence = "biweekly"
+	CadenceMonthly  Cadence = "monthly"
+	CadenceYearly   Cadence = "yearly"
+)
+
+// Next returns the date one period of c after t.
+func (c Cadence) Next(t time.Time) time.Time {
+	return c.Add(t, 1)
+}
+
+// Add returns the date n periods of c after t. Monthly and yearly dates that
+// do not exist, like February 30, become the last day of the month, so a
+// series anchored on the 31st stays on each month's last day.
+func (c Cadence) Add(t time.Time, n int) time.Time {
+	switch c {
+	case CadenceWeekly:
+		return t.AddDate(0, 0, 7*n)
+	case CadenceBiweekly:
+		return t.AddDate(0, 0, 14*n)
+	case CadenceYearly:
+		return addMonths(t, 12*n)
+	default:
+		return addMonths(t, n)
+	}
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
+
+// Valid reports whether c is one of the cadences above.
+func (c Cadence) Valid() bool {
+	switch c {
+	case CadenceWeekly, CadenceBiweekly, CadenceMonthly, CadenceYearly:
+		return true
+	}
+	return false
+}
+
+// PerYear returns how many periods of c fit in a year.
+func (c Cadence) PerYear() int {
+	switch c {
+	case CadenceWeekly:
+		return 52
+	case CadenceBiweekly:
+		return 26
+	case CadenceYearly:
+		return 1
+	default:
+		return 12
+	}
+}
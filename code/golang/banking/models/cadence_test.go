@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCadenceAdd checks that monthly and yearly dates keep to the end of
+// shorter months, counted from the anchor rather than the date before.
+func TestCadenceAdd(t *testing.T) {
+	anchor := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		cadence Cadence
+		n       int
+		want    string
+	}{
+		{CadenceMonthly, 1, "2024-02-29"},
+		{CadenceMonthly, 2, "2024-03-31"},
+		{CadenceMonthly, -2, "2023-11-30"},
+		{CadenceYearly, 1, "2025-01-31"},
+		{CadenceWeekly, 1, "2024-02-07"},
+		{CadenceBiweekly, 2, "2024-02-28"},
+	} {
+		got := tt.cadence.Add(anchor, tt.n)
+		if got.Format(time.DateOnly) != tt.want || got.Hour() != 9 {
+			t.Errorf("%s + %d of %s = %s, want %s at 09:00", anchor.Format(time.DateOnly), tt.n, tt.cadence, got, tt.want)
+		}
+	}
+	leap := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	if got := CadenceYearly.Next(leap); got.Format(time.DateOnly) != "2025-02-28" {
+		t.Errorf("a year after February 29 = %s, want 2025-02-28", got.Format(time.DateOnly))
+	}
+}
@@ -0,0 +1,285 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// Cards are billed with a statement; the minimum payment is the larger of
+// cardMinimumShare of what is owed and cardMinimumFloor, and is due
+// PaymentDueDays after the statement closes.
+const (
+	PaymentDueDays   = 25
+	cardMinimumShare = 0.02
+	cardMinimumFloor = 25
+)
+
+var ErrNotCreditCard = banking.New(banking.CodeInvalidArgument, "account is not a credit card")
+
+// CreditCardAccount is a revolving line of credit. Purchases are
+// withdrawals that may take the balance down to -CreditLimit and payments
+// are deposits; the balance is negative while money is owed. PurchaseRate is
+// the annual rate charged on balances carried past a statement.
+type CreditCardAccount struct {
+	Account
+	CreditLimit  float64
+	PurchaseRate money.Rate
+	Statements   []CardStatement
+}
+
+// CardStatement is one bill of a card. Balance is what was owed when it
+// closed and MinimumDue, which includes any minimum left unpaid from the
+// previous statement, must be paid by DueDate.
+type CardStatement struct {
+	Closed     time.Time `json:"closed"`
+	Balance    float64   `json:"balance"`
+	MinimumDue float64   `json:"minimum_due"`
+	DueDate    time.Time `json:"due_date"`
+}
+
+// CardTerms are the fields of a CreditCardAccount other than its ledger, in
+// the form stores save them.
+type CardTerms struct {
+	CreditLimit  float64         `json:"credit_limit"`
+	PurchaseRate money.Rate      `json:"purchase_rate"`
+	Statements   []CardStatement `json:"statements,omitempty"`
+}
+
+func (c *CreditCardAccount) Terms() CardTerms {
+	return CardTerms{CreditLimit: c.CreditLimit, PurchaseRate: c.PurchaseRate, Statements: append([]CardStatement(nil), c.Statements...)}
+}
+
+func (c *CreditCardAccount) SetTerms(t CardTerms) {
+	c.CreditLimit, c.PurchaseRate, c.Statements = t.CreditLimit, t.PurchaseRate, t.Statements
+}
+
+// Owed returns what is owed on the card.
+func (c *CreditCardAccount) Owed() float64 {
+	return math.Max(-c.Balance, 0)
+}
+
+func (c *CreditCardAccount) Withdraw(amount float64) error {
+	return c.WithdrawTo("", amount)
+}
+
+func (c *CreditCardAccount) WithdrawTo(counterparty string, amount float64) error {
+	return c.Post(Transaction{Type: TransactionWithdrawal, Amount: amount, Counterparty: counterparty})
+}
+
+// Post is like Account.Post but lets purchases use the credit limit.
+func (c *CreditCardAccount) Post(tx Transaction) error {
+	return c.post(tx, c.CreditLimit)
+}
+
+func (c *CreditCardAccount) creditLimit() float64 {
+	return c.CreditLimit
+}
+
+// paidSince sums the payments made after t.
+func (c *CreditCardAccount) paidSince(t time.Time) float64 {
+	paid := 0.0
+	for _, tx := range c.Transactions {
+		if tx.Type == TransactionDeposit && tx.Time.After(t) {
+			paid += tx.Amount
+		}
+	}
+	return paid
+}
+
+// closeStatement bills the card at t, first charging the interest of the
+// cycle that ends (see cycleInterest). The minimum payment's floor is
+// prorated by share for a first, partial, cycle, and the part of the previous
+// minimum that is still unpaid is added to the new one.
+func (c *CreditCardAccount) closeStatement(t time.Time, share float64, rounding money.Rounding) (CardStatement, error) {
+	if interest := money.Round(c.cycleInterest(t), "", rounding); interest > 0 {
+		err := c.Post(Transaction{Type: TransactionInterestCharge, Amount: interest, Rate: c.PurchaseRate, Time: t,
+			Description: "purchase interest"})
+		if err != nil {
+			return CardStatement{}, err
+		}
+	}
+	owed := c.owedAt(t)
+	minimum := math.Min(owed, math.Max(owed*cardMinimumShare, cardMinimumFloor*share))
+	if n := len(c.Statements); n > 0 {
+		prev := c.Statements[n-1]
+		minimum += math.Max(prev.MinimumDue-c.paidSince(prev.Closed), 0)
+	}
+	s := CardStatement{
+		Closed:     t,
+		Balance:    owed,
+		MinimumDue: math.Min(money.Round(minimum, "", money.HalfEven), owed),
+		DueDate:    t.AddDate(0, 0, PaymentDueDays),
+	}
+	c.Statements = append(c.Statements, s)
+	return s, nil
+}
+
+// cycleInterest is the interest owed for the cycle from the last statement
+// to t, at PurchaseRate a year on the daily balance.
+//
+// While each statement is paid in full by its due date the card is in its
+// grace period and no interest is charged. Once a statement is not, the
+// whole balance day by day, new purchases included, bears interest until a
+// statement is paid in full again; and for the cycle in which that payment
+// is made, interest still trails on what was carried over from the
+// statement until the day it was paid off.
+func (c *CreditCardAccount) cycleInterest(t time.Time) float64 {
+	n := len(c.Statements)
+	if n == 0 || c.PurchaseRate <= 0 {
+		return 0
+	}
+	last := c.Statements[n-1]
+	daily := c.PurchaseRate.Percent() / 100 / 365
+	switch {
+	case !c.paidInFull(n - 1):
+		return daily * c.owedDays(last.Closed, t, c.owedAt)
+	case n >= 2 && !c.paidInFull(n-2):
+		// Trailing interest on the carried balance until it was paid.
+		return daily * c.owedDays(last.Closed, t, func(at time.Time) float64 {
+			return math.Min(math.Max(last.Balance-c.paidBetween(last.Closed, at), 0), c.owedAt(at))
+		})
+	default:
+		return 0
+	}
+}
+
+// paidInFull reports whether statement i was paid in full by its due date.
+func (c *CreditCardAccount) paidInFull(i int) bool {
+	s := c.Statements[i]
+	return s.Balance < 0.005 || c.paidBetween(s.Closed, s.DueDate) >= s.Balance-0.005
+}
+
+// owedDays sums owed at the end of every day from start to end.
+func (c *CreditCardAccount) owedDays(start, end time.Time, owed func(time.Time) float64) float64 {
+	sum := 0.0
+	for day := start.Add(24 * time.Hour); !day.After(end); day = day.Add(24 * time.Hour) {
+		sum += math.Max(owed(day), 0)
+	}
+	return sum
+}
+
+// owedAt is what was owed on the card once every entry up to at was posted.
+func (c *CreditCardAccount) owedAt(at time.Time) float64 {
+	return math.Max(-balanceAt(c, at), 0)
+}
+
+// paidBetween sums the payments made after from and up to to.
+func (c *CreditCardAccount) paidBetween(from, to time.Time) float64 {
+	paid := 0.0
+	for _, tx := range c.Transactions {
+		if tx.Type == TransactionDeposit && tx.Time.After(from) && !tx.Time.After(to) {
+			paid += tx.Amount
+		}
+	}
+	return paid
+}
+
+// missed returns the due dates of the statements since the last one met
+// whose minimum was not paid in time, oldest first, and what is past due:
+// the unpaid part of the latest statement's minimum, which carries the
+// earlier ones.
+func (c *CreditCardAccount) missed(now time.Time) ([]time.Time, float64) {
+	var dates []time.Time
+	pastDue := 0.0
+	for i := len(c.Statements) - 1; i >= 0; i-- {
+		s := c.Statements[i]
+		if !now.After(s.DueDate) {
+			continue
+		}
+		unpaid := s.MinimumDue - c.paidSince(s.Closed)
+		if unpaid < 0.005 {
+			break
+		}
+		if len(dates) == 0 {
+			pastDue = money.Round(unpaid, "", money.HalfEven)
+		}
+		dates = append([]time.Time{s.DueDate}, dates...)
+	}
+	return dates, pastDue
+}
+
+// CloseStatement charges a credit card the interest of the cycle, bills it
+// now and returns the statement, whatever its statement cycle. BillCards
+// bills cards by their cycles.
+func (b *Bank) CloseStatement(number string) (CardStatement, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return CardStatement{}, err
+	}
+	card, ok := account.(*CreditCardAccount)
+	if !ok {
+		return CardStatement{}, ErrNotCreditCard
+	}
+	b.mu.Lock()
+	now := b.now()
+	share := 1.0
+	if len(card.Statements) == 0 {
+		share = b.cycleLocked(card).Period(now).Share()
+	}
+	statement, events, err := b.billLocked(card, now, share)
+	b.mu.Unlock()
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	return statement, err
+}
+
+// BillCards closes the statements of every card whose cycle has ended since
+// its last statement, at the end of each cycle, so the statements follow the
+// card's StatementCycle however late this runs. It returns the numbers of the
+// cards billed.
+func (b *Bank) BillCards() []string {
+	now := b.now()
+	b.mu.Lock()
+	var billed []string
+	var events []Event
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		card, ok := account.(*CreditCardAccount)
+		if !ok || !b.closed[number].IsZero() {
+			continue
+		}
+		cycle := b.cycleLocked(card)
+		from := cycle.Since
+		if n := len(card.Statements); n > 0 {
+			from = card.Statements[n-1].Closed
+		}
+		for period := cycle.Period(from.Add(time.Nanosecond)); !period.End.After(now); period = cycle.Period(period.End.Add(time.Nanosecond)) {
+			_, posted, err := b.billLocked(card, period.End, period.Share())
+			events = append(events, posted...)
+			if err != nil {
+				break
+			}
+			if len(billed) == 0 || billed[len(billed)-1] != number {
+				billed = append(billed, number)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	sort.Strings(billed)
+	return billed
+}
+
+// billLocked closes a statement of card at t and returns the events of the
+// interest it charged. The caller holds b.mu.
+func (b *Bank) billLocked(card *CreditCardAccount, t time.Time, share float64) (CardStatement, []Event, error) {
+	posted := len(card.History())
+	statement, err := card.closeStatement(t, share, b.Rounding)
+	if err != nil {
+		return CardStatement{}, nil, err
+	}
+	var events []Event
+	for _, tx := range card.History()[posted:] {
+		tx := tx
+		events = append(events, Event{Type: EventTransactionPosted, AccountNumber: card.Number(), Transaction: &tx, Time: tx.Time})
+	}
+	return statement, events, nil
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// cardStep is one thing that happens to the card, on a day of 2026 at noon
+// for payments and purchases and at midnight for statements.
+type cardStep struct {
+	date     string
+	pay      float64
+	purchase float64
+	close    bool
+	// interest is the interest the statement must charge.
+	interest float64
+}
+
+func runCard(t *testing.T, steps []cardStep) *CreditCardAccount {
+	t.Helper()
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	// 18.25% a year is exactly 0.05% a day.
+	card := &CreditCardAccount{Account: Account{AccountNumber: "K1"}, CreditLimit: 5000, PurchaseRate: money.Percent(18.25)}
+	if err := b.Open(card); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range steps {
+		day, err := time.Parse(time.DateOnly, s.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.now = day.Add(12 * time.Hour)
+		switch {
+		case s.purchase > 0:
+			if err := b.Withdraw("K1", s.purchase); err != nil {
+				t.Fatalf("%s: purchase: %v", s.date, err)
+			}
+		case s.pay > 0:
+			if err := b.Deposit("K1", s.pay); err != nil {
+				t.Fatalf("%s: payment: %v", s.date, err)
+			}
+		case s.close:
+			clock.now = day
+			before := card.Owed()
+			statement, err := b.CloseStatement("K1")
+			if err != nil {
+				t.Fatalf("%s: statement: %v", s.date, err)
+			}
+			if got := statement.Balance - before; math.Abs(got-s.interest) >= 0.005 {
+				t.Errorf("%s: statement charged %.2f interest, want %.2f", s.date, got, s.interest)
+			}
+		}
+	}
+	return card
+}
+
+func TestCardGracePeriod(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []cardStep
+	}{
+		{
+			name: "paid in full by the due date",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-10", pay: 1000},
+				{date: "2026-02-15", purchase: 200},
+				{date: "2026-03-02", close: true, interest: 0},
+				{date: "2026-03-20", pay: 200},
+				{date: "2026-04-01", close: true, interest: 0},
+			},
+		},
+		{
+			// 1000 for 10 days, 600 for 5 days and 800 for 15 days.
+			name: "partial payment charges interest on every day's balance, new purchases included",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-10", pay: 400},
+				{date: "2026-02-15", purchase: 200},
+				{date: "2026-03-02", close: true, interest: 12.50},
+			},
+		},
+		{
+			name: "only the minimum paid",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-20", pay: 25},
+				{date: "2026-03-02", close: true, interest: 14.88},
+			},
+		},
+		{
+			name: "several partial payments that add up to the statement balance",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-05", pay: 600},
+				{date: "2026-02-12", purchase: 80},
+				{date: "2026-02-24", pay: 400},
+				{date: "2026-03-02", close: true, interest: 0},
+			},
+		},
+		{
+			// The full balance arrives two days after the due date.
+			name: "paid in full after the due date",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-27", pay: 1000},
+				{date: "2026-03-02", close: true, interest: 13.50},
+			},
+		},
+		{
+			// After revolving, paying 812.50 in full on March 12 still
+			// owes 10 days of interest on it; the purchase of March 20 is
+			// in the grace period again, and so is the next cycle.
+			name: "trailing interest after paying a revolved balance in full",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-10", pay: 400},
+				{date: "2026-02-15", purchase: 200},
+				{date: "2026-03-02", close: true, interest: 12.50},
+				{date: "2026-03-12", pay: 812.50},
+				{date: "2026-03-20", purchase: 100},
+				{date: "2026-04-01", close: true, interest: 4.06},
+				{date: "2026-04-15", pay: 104.06},
+				{date: "2026-04-20", purchase: 50},
+				{date: "2026-05-01", close: true, interest: 0},
+			},
+		},
+		{
+			// Paying most of the statement is not paying it in full.
+			name: "partial payment of a revolved balance",
+			steps: []cardStep{
+				{date: "2026-01-05", purchase: 1000},
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-10", pay: 400},
+				{date: "2026-02-15", purchase: 200},
+				{date: "2026-03-02", close: true, interest: 12.50},
+				{date: "2026-03-12", pay: 800},
+				{date: "2026-04-01", close: true, interest: 4.19},
+			},
+		},
+		{
+			name: "a statement with nothing owed keeps the grace period",
+			steps: []cardStep{
+				{date: "2026-01-31", close: true},
+				{date: "2026-02-05", purchase: 300},
+				{date: "2026-03-02", close: true, interest: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runCard(t, tt.steps)
+		})
+	}
+}
+
+func TestCardInterestEntry(t *testing.T) {
+	card := runCard(t, []cardStep{
+		{date: "2026-01-05", purchase: 1000},
+		{date: "2026-01-31", close: true},
+		{date: "2026-02-10", pay: 400},
+		{date: "2026-03-02", close: true, interest: 11.00},
+	})
+	history := card.History()
+	tx := history[len(history)-1]
+	if tx.Type != TransactionInterestCharge || tx.Amount != 11 || tx.Rate != money.Percent(18.25) {
+		t.Errorf("last entry is %s %.2f at %s, want interest_charge 11.00 at 18.25%%", tx.Type, tx.Amount, tx.Rate)
+	}
+	if got := card.Statements[1].Balance; got != 611 {
+		t.Errorf("statement balance is %.2f, want 611.00", got)
+	}
+}
+
+// TestStatementCyclePeriods checks the periods of each kind of cycle: that a
+// boundary closes the period before it, that anchor days past the end of a
+// short month fall on its last day, and that the first period starts part
+// way into a cycle and is prorated by the share of it that it covers.
+func TestStatementCyclePeriods(t *testing.T) {
+	for _, tt := range []struct {
+		cycle      StatementCycle
+		at         string
+		start, end string
+		share      float64
+	}{
+		{StatementCycle{Kind: CycleCalendarMonth, Since: date(t, "2026-01-15")}, "2026-01-20", "2026-01-15", "2026-02-01", 17.0 / 31},
+		{StatementCycle{Kind: CycleCalendarMonth, Since: date(t, "2026-01-15")}, "2025-12-01", "2026-01-15", "2026-02-01", 17.0 / 31},
+		{StatementCycle{Kind: CycleCalendarMonth, Since: date(t, "2026-01-15")}, "2026-02-01", "2026-01-15", "2026-02-01", 17.0 / 31},
+		{StatementCycle{Kind: CycleCalendarMonth, Since: date(t, "2026-01-15")}, "2026-02-02", "2026-02-01", "2026-03-01", 1},
+		{StatementCycle{Kind: CycleAnchorDay, Day: 31, Since: date(t, "2026-01-01")}, "2026-02-10", "2026-01-31", "2026-02-28", 1},
+		{StatementCycle{Kind: CycleAnchorDay, Day: 31, Since: date(t, "2026-01-01")}, "2026-03-05", "2026-02-28", "2026-03-31", 1},
+		{StatementCycle{Kind: CycleAnchorDay, Day: 30, Since: date(t, "2026-02-20")}, "2026-02-25", "2026-02-20", "2026-02-28", 8.0 / 29},
+		{StatementCycle{Kind: CycleEveryNDays, Days: 10, Since: date(t, "2026-01-01")}, "2026-01-11", "2026-01-01", "2026-01-11", 1},
+		{StatementCycle{Kind: CycleEveryNDays, Days: 10, Since: date(t, "2026-01-01")}, "2026-01-12", "2026-01-11", "2026-01-21", 1},
+	} {
+		p := tt.cycle.Period(date(t, tt.at))
+		if !p.Start.Equal(date(t, tt.start)) || !p.End.Equal(date(t, tt.end)) {
+			t.Errorf("%s cycle at %s: period %s to %s, want %s to %s", tt.cycle.Kind, tt.at,
+				p.Start.Format(time.DateOnly), p.End.Format(time.DateOnly), tt.start, tt.end)
+		}
+		if math.Abs(p.Share()-tt.share) > 1e-9 {
+			t.Errorf("%s cycle at %s: share %.4f, want %.4f", tt.cycle.Kind, tt.at, p.Share(), tt.share)
+		}
+	}
+}
+
+// TestStatementCycleBilling bills a card that joined its cycle mid-month
+// and checks that the first statement closes at the end of the cycle with
+// a prorated minimum, that the next ones follow the cycle however late
+// billing runs, and that invalid cycles are refused.
+func TestStatementCycleBilling(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-16").Add(12 * time.Hour)}
+	b := NewBank()
+	b.Clock = clock
+	card := &CreditCardAccount{Account: Account{AccountNumber: "K1"}, CreditLimit: 5000, PurchaseRate: money.Percent(18.25)}
+	if err := b.Open(card); err != nil {
+		t.Fatal(err)
+	}
+	for _, cycle := range []StatementCycle{
+		{Kind: "weekly"},
+		{Kind: CycleAnchorDay, Day: 32},
+		{Kind: CycleEveryNDays},
+	} {
+		if err := b.SetStatementCycle("K1", cycle); !errors.Is(err, ErrInvalidCycle) {
+			t.Errorf("setting %+v: %v, want ErrInvalidCycle", cycle, err)
+		}
+	}
+	if err := b.SetStatementCycle("K1", StatementCycle{Kind: CycleAnchorDay, Day: 31, Since: date(t, "2026-01-15")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("K1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = date(t, "2026-03-03")
+	if billed := b.BillCards(); !slices.Equal(billed, []string{"K1"}) {
+		t.Errorf("billed %v, want [K1]", billed)
+	}
+	var closed []string
+	for _, s := range card.Statements {
+		closed = append(closed, s.Closed.Format(time.DateOnly))
+	}
+	if want := []string{"2026-01-31", "2026-02-28"}; !slices.Equal(closed, want) {
+		t.Errorf("statements closed %v, want %v", closed, want)
+	}
+	// 16 of the 31 days of the first cycle.
+	if got, want := card.Statements[0].MinimumDue, money.Round(25*16.0/31, "", money.HalfEven); got != want {
+		t.Errorf("first minimum due %.2f, want %.2f", got, want)
+	}
+	if billed := b.BillCards(); len(billed) != 0 {
+		t.Errorf("billing again billed %v", billed)
+	}
+}
@@ -0,0 +1,299 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/validate"
+)
+
+var (
+	ErrCaseNotFound = banking.New(banking.CodeNotFound, "case not found")
+	ErrCaseState    = banking.New(banking.CodeConflict, "case cannot move to that state")
+	ErrInvalidCase  = banking.New(banking.CodeInvalidArgument, "invalid case")
+)
+
+// CaseKind is what a case is about.
+type CaseKind string
+
+const (
+	// CaseDisputedCharge disputes entries of an account the customer does
+	// not recognise or did not get what they paid for.
+	CaseDisputedCharge CaseKind = "disputed_charge"
+	// CaseFailedTransfer is about a transfer that failed or did not arrive.
+	CaseFailedTransfer CaseKind = "failed_transfer"
+	// CaseFraudReview is the bank's review of activity it suspects; opening
+	// one asks the customer about it in their inbox.
+	CaseFraudReview CaseKind = "fraud_review"
+	CaseOther       CaseKind = "other"
+)
+
+func (k CaseKind) valid() bool {
+	return k == CaseDisputedCharge || k == CaseFailedTransfer || k == CaseFraudReview || k == CaseOther
+}
+
+// CaseState is where a case is in its handling.
+type CaseState string
+
+const (
+	CaseOpen          CaseState = "open"
+	CaseInvestigating CaseState = "investigating"
+	// CaseWaiting waits for the customer to answer, such as with a receipt
+	// or to confirm a charge.
+	CaseWaiting CaseState = "waiting_on_customer"
+	// CaseResolved has an outcome the customer may still contest, which
+	// reopens it; CaseClosed is final.
+	CaseResolved CaseState = "resolved"
+	CaseClosed   CaseState = "closed"
+)
+
+// nextCaseStates lists the states each state may move to.
+var nextCaseStates = map[CaseState][]CaseState{
+	CaseOpen:          {CaseInvestigating, CaseWaiting, CaseResolved, CaseClosed},
+	CaseInvestigating: {CaseWaiting, CaseResolved, CaseClosed},
+	CaseWaiting:       {CaseInvestigating, CaseResolved, CaseClosed},
+	CaseResolved:      {CaseInvestigating, CaseClosed},
+}
+
+// Case is a customer's ticket about an issue with one of their accounts,
+// and the entries it concerns by ID, handled by the member of staff it is
+// assigned to. Steps records when it reached each state, and Comments are
+// the conversation on it; internal ones are for staff only.
+type Case struct {
+	ID           int           `json:"id"`
+	Customer     string        `json:"customer"`
+	Kind         CaseKind      `json:"kind"`
+	Account      string        `json:"account,omitempty"`
+	Transactions []string      `json:"transactions,omitempty"`
+	Subject      string        `json:"subject"`
+	State        CaseState     `json:"state"`
+	Assignee     string        `json:"assignee,omitempty"`
+	Steps        []CaseStep    `json:"steps"`
+	Comments     []CaseComment `json:"comments,omitempty"`
+}
+
+// CaseStep is a state a case reached, and Note why.
+type CaseStep struct {
+	State CaseState `json:"state"`
+	Time  time.Time `json:"time"`
+	Note  string    `json:"note,omitempty"`
+}
+
+// CaseComment is a comment on a case by Author, the customer or a member
+// of staff.
+type CaseComment struct {
+	Author   string    `json:"author"`
+	Time     time.Time `json:"time"`
+	Text     string    `json:"text"`
+	Internal bool      `json:"internal,omitempty"`
+}
+
+// Opened is when the case was opened.
+func (c Case) Opened() time.Time {
+	return c.Steps[0].Time
+}
+
+// Done reports whether the case is closed for good.
+func (c Case) Done() bool {
+	return len(nextCaseStates[c.State]) == 0
+}
+
+// Public returns the case as its customer sees it, without internal
+// comments.
+func (c Case) Public() Case {
+	c.Comments = slices.DeleteFunc(slices.Clone(c.Comments), func(cm CaseComment) bool { return cm.Internal })
+	return c
+}
+
+func (c Case) clone() Case {
+	c.Transactions = slices.Clone(c.Transactions)
+	c.Steps = slices.Clone(c.Steps)
+	c.Comments = slices.Clone(c.Comments)
+	return c
+}
+
+// CaseFilter selects cases; empty fields select every case.
+type CaseFilter struct {
+	Customer string
+	Account  string
+	State    CaseState
+	Assignee string
+}
+
+func (f CaseFilter) match(c *Case) bool {
+	return (f.Customer == "" || c.Customer == f.Customer) && (f.Account == "" || c.Account == f.Account) &&
+		(f.State == "" || c.State == f.State) && (f.Assignee == "" || c.Assignee == f.Assignee)
+}
+
+// OpenCase opens a case of a customer with its Kind, Subject and, if it is
+// about an account, Account, which the customer must hold, and the IDs of
+// its entries the case is about. A fraud review also asks the customer
+// about it in their inbox, with the case's subject.
+func (b *Bank) OpenCase(c Case) (Case, error) {
+	var v validate.Validator
+	v.Required("customer", c.Customer)
+	v.Required("subject", c.Subject)
+	if err := v.Err(); err != nil {
+		return Case{}, err
+	}
+	if !c.Kind.valid() {
+		return Case{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidCase, c.Kind)
+	}
+	if c.Account == "" && len(c.Transactions) > 0 {
+		return Case{}, fmt.Errorf("%w: transactions need their account", ErrInvalidCase)
+	}
+	now := b.now()
+	b.mu.Lock()
+	if _, ok := b.customers[c.Customer]; !ok {
+		b.mu.Unlock()
+		return Case{}, ErrCustomerNotFound
+	}
+	if c.Account != "" {
+		if err := b.caseAccountLocked(c); err != nil {
+			b.mu.Unlock()
+			return Case{}, err
+		}
+	}
+	b.nextCase++
+	opened := &Case{ID: b.nextCase, Customer: c.Customer, Kind: c.Kind, Account: c.Account, Transactions: slices.Clone(c.Transactions),
+		Subject: c.Subject, State: CaseOpen, Assignee: c.Assignee, Steps: []CaseStep{{State: CaseOpen, Time: now}}}
+	b.cases[opened.ID] = opened
+	c = opened.clone()
+	b.mu.Unlock()
+	b.publishCase(c)
+	if c.Kind == CaseFraudReview {
+		b.SendMessage(Message{Customer: c.Customer, Kind: MessageFraudReview, Account: c.Account, Subject: c.Subject,
+			Body: fmt.Sprintf("Please answer on case %d.", c.ID), Key: fmt.Sprintf("case:%d", c.ID)})
+	}
+	return c, nil
+}
+
+// caseAccountLocked checks that the customer of a case holds its account
+// and that its entries are the account's. The caller holds b.mu.
+func (b *Bank) caseAccountLocked(c Case) error {
+	account := b.accounts.account(c.Account)
+	if account == nil {
+		return ErrAccountNotFound
+	}
+	if !b.holdsLocked(c.Account, c.Customer) {
+		return fmt.Errorf("%w: %s does not hold %s", ErrInvalidCase, c.Customer, c.Account)
+	}
+	history := account.History()
+	for _, id := range c.Transactions {
+		if !slices.ContainsFunc(history, func(tx Transaction) bool { return tx.ID == id }) {
+			return fmt.Errorf("%w: %s has no transaction %s", ErrInvalidCase, c.Account, id)
+		}
+	}
+	return nil
+}
+
+// Case returns a case by ID.
+func (b *Bank) Case(id int) (Case, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	c, ok := b.cases[id]
+	if !ok {
+		return Case{}, fmt.Errorf("%w: %d", ErrCaseNotFound, id)
+	}
+	return c.clone(), nil
+}
+
+// Cases returns the cases filter selects, oldest first.
+func (b *Bank) Cases(filter CaseFilter) []Case {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	cases := []Case{}
+	for _, c := range b.cases {
+		if filter.match(c) {
+			cases = append(cases, c.clone())
+		}
+	}
+	slices.SortFunc(cases, func(a, b Case) int { return a.ID - b.ID })
+	return cases
+}
+
+// AssignCase assigns a case that is not closed to a member of staff, or
+// unassigns it for "".
+func (b *Bank) AssignCase(id int, assignee string) (Case, error) {
+	return b.updateCase(id, func(c *Case) error {
+		if c.Done() {
+			return fmt.Errorf("%w: case %d is %s", ErrCaseState, id, c.State)
+		}
+		c.Assignee = assignee
+		return nil
+	})
+}
+
+// MoveCase moves a case to another state, with note as the reason, such as
+// the outcome of resolving it.
+func (b *Bank) MoveCase(id int, to CaseState, note string) (Case, error) {
+	now := b.now()
+	return b.updateCase(id, func(c *Case) error {
+		if !slices.Contains(nextCaseStates[c.State], to) {
+			return fmt.Errorf("%w: case %d is %s and cannot move to %s", ErrCaseState, id, c.State, to)
+		}
+		c.State = to
+		c.Steps = append(c.Steps, CaseStep{State: to, Time: now, Note: note})
+		return nil
+	})
+}
+
+// CommentCase adds a comment to a case that is not closed. The customer
+// commenting on a case waiting for them puts it back under investigation.
+func (b *Bank) CommentCase(id int, comment CaseComment) (Case, error) {
+	var v validate.Validator
+	v.Required("author", comment.Author)
+	v.Required("text", comment.Text)
+	if err := v.Err(); err != nil {
+		return Case{}, err
+	}
+	comment.Time = b.now()
+	return b.updateCase(id, func(c *Case) error {
+		if c.Done() {
+			return fmt.Errorf("%w: case %d is %s", ErrCaseState, id, c.State)
+		}
+		if comment.Author == c.Customer && comment.Internal {
+			return fmt.Errorf("%w: customers cannot comment internally", ErrInvalidCase)
+		}
+		c.Comments = append(c.Comments, comment)
+		if comment.Author == c.Customer && c.State == CaseWaiting {
+			c.State = CaseInvestigating
+			c.Steps = append(c.Steps, CaseStep{State: CaseInvestigating, Time: comment.Time, Note: "customer answered"})
+		}
+		return nil
+	})
+}
+
+// updateCase changes a case with do, holding b.mu, and publishes it.
+func (b *Bank) updateCase(id int, do func(c *Case) error) (Case, error) {
+	b.mu.Lock()
+	current, ok := b.cases[id]
+	if !ok {
+		b.mu.Unlock()
+		return Case{}, fmt.Errorf("%w: %d", ErrCaseNotFound, id)
+	}
+	updated := current.clone()
+	if err := do(&updated); err != nil {
+		b.mu.Unlock()
+		return Case{}, err
+	}
+	*current = updated
+	c := current.clone()
+	b.mu.Unlock()
+	b.publishCase(c)
+	return c, nil
+}
+
+func (b *Bank) publishCase(c Case) {
+	step := c.Steps[len(c.Steps)-1]
+	message := fmt.Sprintf("Case %d of %s about %s: %s", c.ID, c.Customer, c.Kind, c.State)
+	if c.Assignee != "" {
+		message += ", assigned to " + c.Assignee
+	}
+	if step.Note != "" {
+		message += " (" + step.Note + ")"
+	}
+	b.Events.Publish(Event{Type: EventCaseChanged, AccountNumber: c.Account, Time: b.now(), Message: message})
+}
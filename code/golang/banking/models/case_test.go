@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestCase disputes a charge, assigns the case, waits on the customer until
+// they answer and resolves it, and checks that fraud reviews ask the
+// customer in their inbox and internal comments stay with the staff.
+func TestCase(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 30); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := b.Account("C1")
+	charge := account.History()[1].ID
+
+	if _, err := b.OpenCase(Case{Customer: "c1", Kind: CaseDisputedCharge, Account: "C2", Subject: "Not mine"}); !errors.Is(err, ErrInvalidCase) {
+		t.Errorf("opening a case on another's account: %v, want %v", err, ErrInvalidCase)
+	}
+	if _, err := b.OpenCase(Case{Customer: "c1", Kind: CaseDisputedCharge, Account: "C1", Transactions: []string{"nope"}, Subject: "Not mine"}); !errors.Is(err, ErrInvalidCase) {
+		t.Errorf("opening a case on an unknown entry: %v, want %v", err, ErrInvalidCase)
+	}
+	c, err := b.OpenCase(Case{Customer: "c1", Kind: CaseDisputedCharge, Account: "C1", Transactions: []string{charge}, Subject: "I did not withdraw 30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.AssignCase(c.ID, "agent-7"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.CommentCase(c.ID, CaseComment{Author: "agent-7", Text: "ATM footage requested", Internal: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.MoveCase(c.ID, CaseWaiting, "asked for the card's whereabouts"); err != nil {
+		t.Fatal(err)
+	}
+	c, err = b.CommentCase(c.ID, CaseComment{Author: "c1", Text: "It was in my wallet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State != CaseInvestigating || len(c.Comments) != 2 || len(c.Public().Comments) != 1 {
+		t.Errorf("after the customer answered %+v", c)
+	}
+	if _, err := b.MoveCase(c.ID, CaseResolved, "refunded"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.MoveCase(c.ID, CaseClosed, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.MoveCase(c.ID, CaseInvestigating, ""); !errors.Is(err, ErrCaseState) {
+		t.Errorf("reopening a closed case: %v, want %v", err, ErrCaseState)
+	}
+	if cases := b.Cases(CaseFilter{Assignee: "agent-7"}); len(cases) != 1 || len(cases[0].Steps) != 5 {
+		t.Errorf("cases of agent-7 %+v", cases)
+	}
+
+	review, err := b.OpenCase(Case{Customer: "c1", Kind: CaseFraudReview, Account: "C1", Subject: "Did you withdraw 30?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages, _, _ := b.Messages("c1", true); len(messages) != 1 || messages[0].Kind != MessageFraudReview || messages[0].Subject != review.Subject {
+		t.Errorf("inbox after a fraud review %+v", messages)
+	}
+}
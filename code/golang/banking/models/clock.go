@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Clock tells the bank what time it is. Simulations and tests swap in a
+// clock they control to make ledgers reproducible.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (b *Bank) now() time.Time {
+	if b.Clock == nil {
+		return time.Now()
+	}
+	return b.Clock.Now()
+}
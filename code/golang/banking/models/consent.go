@@ -0,0 +1,221 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gsolano/banking"
+)
+
+// DefaultConsentDuration is how long a consent lasts unless it says, and
+// MaxConsentDuration the longest it may, after which the customer consents
+// again.
+const (
+	DefaultConsentDuration = 90 * 24 * time.Hour
+	MaxConsentDuration     = 365 * 24 * time.Hour
+)
+
+var (
+	ErrConsentNotFound = banking.New(banking.CodeNotFound, "consent not found")
+	ErrInvalidConsent  = banking.New(banking.CodeInvalidArgument, "invalid consent")
+	ErrNotConsented    = banking.New(banking.CodePermissionDenied, "the customer has not consented to this")
+)
+
+// ConsentScope is what a consent lets a client read of its accounts.
+type ConsentScope string
+
+const (
+	// ConsentBalances reads the accounts and their balances.
+	ConsentBalances ConsentScope = "balances"
+	// ConsentTransactions reads their transactions.
+	ConsentTransactions ConsentScope = "transactions"
+)
+
+// Consent lets a third-party client, such as a budgeting app, read some of
+// a customer's accounts for the client of its API keys, see APIKey.Client:
+// what Scopes allow, until Expires or until the customer revokes it. Every
+// read under it is recorded in the bank's audit log, see ConsentedRead.
+type Consent struct {
+	ID       int            `json:"id"`
+	Customer string         `json:"customer"`
+	Client   string         `json:"client"`
+	Accounts []string       `json:"accounts"`
+	Scopes   []ConsentScope `json:"scopes"`
+	Created  time.Time      `json:"created"`
+	Expires  time.Time      `json:"expires"`
+	Revoked  *time.Time     `json:"revoked,omitempty"`
+}
+
+// activeAt reports whether c lets its client read at t.
+func (c Consent) activeAt(t time.Time) bool {
+	return t.Before(c.Expires) && c.Revoked == nil
+}
+
+func (c Consent) clone() Consent {
+	c.Accounts = slices.Clone(c.Accounts)
+	c.Scopes = slices.Clone(c.Scopes)
+	return c
+}
+
+// GiveConsent records that a customer lets a client read their accounts as
+// c describes. The customer must hold each account and the client have an
+// active API key. Expires is DefaultConsentDuration away when zero, and at
+// most MaxConsentDuration.
+func (b *Bank) GiveConsent(c Consent) (Consent, error) {
+	now := b.now()
+	if c.Expires.IsZero() {
+		c.Expires = now.Add(DefaultConsentDuration)
+	}
+	switch {
+	case c.Client == "":
+		return Consent{}, fmt.Errorf("%w: no client", ErrInvalidConsent)
+	case len(c.Accounts) == 0:
+		return Consent{}, fmt.Errorf("%w: no accounts", ErrInvalidConsent)
+	case len(c.Scopes) == 0:
+		return Consent{}, fmt.Errorf("%w: no scopes", ErrInvalidConsent)
+	case !c.Expires.After(now) || c.Expires.Sub(now) > MaxConsentDuration:
+		return Consent{}, fmt.Errorf("%w: expires in the past or more than %s away", ErrInvalidConsent, MaxConsentDuration)
+	}
+	for _, scope := range c.Scopes {
+		if scope != ConsentBalances && scope != ConsentTransactions {
+			return Consent{}, fmt.Errorf("%w: scope %q is not balances or transactions", ErrInvalidConsent, scope)
+		}
+	}
+	c = c.clone()
+	slices.Sort(c.Accounts)
+	c.Accounts = slices.Compact(c.Accounts)
+	slices.Sort(c.Scopes)
+	c.Scopes = slices.Compact(c.Scopes)
+
+	b.mu.Lock()
+	if _, ok := b.customers[c.Customer]; !ok {
+		b.mu.Unlock()
+		return Consent{}, ErrCustomerNotFound
+	}
+	for _, number := range c.Accounts {
+		if !b.holdsLocked(number, c.Customer) {
+			b.mu.Unlock()
+			return Consent{}, fmt.Errorf("%w: %s of %s", ErrNotHolder, c.Customer, number)
+		}
+	}
+	if !b.clientLocked(c.Client, now) {
+		b.mu.Unlock()
+		return Consent{}, fmt.Errorf("%w: no API key of client %q", ErrInvalidConsent, c.Client)
+	}
+	b.nextConsent++
+	c.ID, c.Created, c.Revoked = b.nextConsent, now, nil
+	stored := c.clone()
+	b.consents[c.ID] = &stored
+	b.mu.Unlock()
+	for _, number := range c.Accounts {
+		b.Events.Publish(Event{Type: EventConsentGranted, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Consent %d: %s may read the %v of %s until %s", c.ID, c.Client, c.Scopes, number, c.Expires.Format(time.RFC3339))})
+	}
+	return c, nil
+}
+
+// clientLocked reports whether a client has an active API key. The caller
+// holds b.mu.
+func (b *Bank) clientLocked(client string, t time.Time) bool {
+	for _, k := range b.apiKeys {
+		if k.Client == client && k.active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Consents returns the consents a customer gave, past ones included, oldest
+// first.
+func (b *Bank) Consents(customer string) ([]Consent, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if _, ok := b.customers[customer]; !ok {
+		return nil, ErrCustomerNotFound
+	}
+	consents := []Consent{}
+	for _, c := range b.consents {
+		if c.Customer == customer {
+			consents = append(consents, c.clone())
+		}
+	}
+	slices.SortFunc(consents, func(a, b Consent) int { return a.ID - b.ID })
+	return consents, nil
+}
+
+// Consent returns a consent.
+func (b *Bank) Consent(id int) (Consent, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	c, ok := b.consents[id]
+	if !ok {
+		return Consent{}, fmt.Errorf("%w: %d", ErrConsentNotFound, id)
+	}
+	return c.clone(), nil
+}
+
+// RevokeConsent ends a consent of a customer before it expires.
+func (b *Bank) RevokeConsent(customer string, id int) (Consent, error) {
+	now := b.now()
+	b.mu.Lock()
+	c, ok := b.consents[id]
+	if !ok || c.Customer != customer {
+		b.mu.Unlock()
+		return Consent{}, fmt.Errorf("%w: %d", ErrConsentNotFound, id)
+	}
+	if !c.activeAt(now) {
+		revoked := c.clone()
+		b.mu.Unlock()
+		return revoked, nil
+	}
+	c.Revoked = &now
+	revoked := c.clone()
+	b.mu.Unlock()
+	for _, number := range revoked.Accounts {
+		b.Events.Publish(Event{Type: EventConsentRevoked, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Consent %d of %s revoked", id, revoked.Client)})
+	}
+	return revoked, nil
+}
+
+// ConsentedRead checks that client may read an account, "" for the list
+// of accounts, as scope says under a consent, and records the read, or its
+// refusal, in the audit log with the consent and customer.
+func (b *Bank) ConsentedRead(id int, client, number string, scope ConsentScope) (Consent, error) {
+	now := b.now()
+	b.mu.RLock()
+	c, ok := b.consents[id]
+	var consent Consent
+	var err error
+	switch {
+	case !ok || c.Client != client:
+		err = fmt.Errorf("%w: %d", ErrConsentNotFound, id)
+	case !c.activeAt(now):
+		err = fmt.Errorf("%w: consent %d expired or was revoked", ErrNotConsented, id)
+	case number != "" && (!slices.Contains(c.Accounts, number) || !b.holdsLocked(number, c.Customer)):
+		err = fmt.Errorf("%w: consent %d does not cover %s", ErrNotConsented, id, number)
+	case scope != "" && !slices.Contains(c.Scopes, scope):
+		err = fmt.Errorf("%w: consent %d does not allow reading %s", ErrNotConsented, id, scope)
+	}
+	if ok {
+		consent = c.clone()
+	}
+	b.mu.RUnlock()
+
+	e := AuditEntry{Time: now, Client: client, Consent: id, Customer: consent.Customer, Action: "consent.read", Account: number, Status: 200}
+	if scope != "" {
+		e.Action += "." + string(scope)
+	}
+	if err != nil {
+		e.Status, e.Error = 403, err.Error()
+		if banking.CodeOf(err) == banking.CodeNotFound {
+			e.Status = 404
+		}
+	}
+	b.Audit.Record(e)
+	if err != nil {
+		return Consent{}, err
+	}
+	return consent, nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestConsent gives a client consent to read one account's balance, checks
+// that reads beyond it are refused and every read is audited, and that
+// revoking it after a snapshot ends it.
+func TestConsent(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	consent := Consent{Customer: "c1", Client: "budgetapp", Accounts: []string{"C1"}, Scopes: []ConsentScope{ConsentBalances}}
+	if _, err := b.GiveConsent(consent); !errors.Is(err, ErrInvalidConsent) {
+		t.Errorf("consent to a client without keys: %v, want %v", err, ErrInvalidConsent)
+	}
+	if _, _, err := b.IssueAPIKey(APIKey{Name: "budget app", Client: "budgetapp", Scope: PermissionView}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GiveConsent(Consent{Customer: "c1", Client: "budgetapp", Accounts: []string{"C2"}, Scopes: consent.Scopes}); !errors.Is(err, ErrNotHolder) {
+		t.Errorf("consent to another's account: %v, want %v", err, ErrNotHolder)
+	}
+	consent, err := b.GiveConsent(consent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !consent.Expires.Equal(clock.now.Add(DefaultConsentDuration)) {
+		t.Errorf("consent expires %s, want in %s", consent.Expires, DefaultConsentDuration)
+	}
+
+	if _, err := b.ConsentedRead(consent.ID, "budgetapp", "C1", ConsentBalances); err != nil {
+		t.Errorf("reading the balance consented to: %v", err)
+	}
+	if _, err := b.ConsentedRead(consent.ID, "budgetapp", "C1", ConsentTransactions); !errors.Is(err, ErrNotConsented) {
+		t.Errorf("reading transactions: %v, want %v", err, ErrNotConsented)
+	}
+	if _, err := b.ConsentedRead(consent.ID, "budgetapp", "C2", ConsentBalances); !errors.Is(err, ErrNotConsented) {
+		t.Errorf("reading another account: %v, want %v", err, ErrNotConsented)
+	}
+	if _, err := b.ConsentedRead(consent.ID, "otherapp", "C1", ConsentBalances); !errors.Is(err, ErrConsentNotFound) {
+		t.Errorf("reading as another client: %v, want %v", err, ErrConsentNotFound)
+	}
+	if reads := b.Audit.Entries(AuditQuery{Consent: consent.ID, Client: "budgetapp"}); len(reads) != 3 || reads[0].Status != 200 || reads[1].Status != 403 || reads[0].Customer != "c1" {
+		t.Errorf("audited reads %+v, want one allowed and two refused", reads)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	restored.Clock = clock
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if _, err := restored.RevokeConsent("c1", consent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.ConsentedRead(consent.ID, "budgetapp", "C1", ConsentBalances); !errors.Is(err, ErrNotConsented) {
+		t.Errorf("reading after revoking: %v, want %v", err, ErrNotConsented)
+	}
+	if consents, _ := restored.Consents("c1"); len(consents) != 1 || consents[0].Revoked == nil {
+		t.Errorf("consents %+v, want the revoked one", consents)
+	}
+}
@@ -0,0 +1,269 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrATMLimit          = banking.New(banking.CodeLimitExceeded, "daily ATM limit reached")
+	ErrCountryNotAllowed = banking.New(banking.CodeLimitExceeded, "country not allowed for this account")
+	ErrInvalidControls   = banking.New(banking.CodeInvalidArgument, "invalid account controls")
+	ErrOverrideNotFound  = banking.New(banking.CodeNotFound, "override not found")
+)
+
+// The metadata of withdrawals controls look at: the channel, ChannelATM for
+// cash from an ATM, and the ISO 3166 code of the country it happened in, as
+// card networks report them.
+const (
+	MetaChannel = "channel"
+	MetaCountry = "country"
+	ChannelATM  = "atm"
+)
+
+// AtATM marks a withdrawal as cash from an ATM in country, "" if unknown.
+func AtATM(country string) TxOption {
+	return func(tx *Transaction) {
+		WithMetadata(MetaChannel, ChannelATM)(tx)
+		if country != "" {
+			WithMetadata(MetaCountry, strings.ToUpper(country))(tx)
+		}
+	}
+}
+
+// Controls restrict the withdrawals from an account or card: ATMDaily caps
+// the cash taken from ATMs in a day, and Countries, when set, lists the only
+// countries withdrawals may happen in. Withdrawals of no known country pass.
+// Zero means unrestricted.
+type Controls struct {
+	ATMDaily  float64  `json:"atm_daily,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+}
+
+func (c Controls) validate() error {
+	if c.ATMDaily < 0 {
+		return fmt.Errorf("%w: ATM limit must not be negative", ErrInvalidControls)
+	}
+	for _, country := range c.Countries {
+		if len(country) != 2 || strings.ToUpper(country) != country {
+			return fmt.Errorf("%w: %q is not a two letter ISO 3166 country code", ErrInvalidControls, country)
+		}
+	}
+	return nil
+}
+
+// Override relaxes an account's controls from Start until End, such as for
+// travel: ATMDaily, when set, replaces the ATM limit and Countries are
+// allowed besides the usual ones. It reverts by itself at End; Ended is set
+// once it has, or when it was cancelled before.
+type Override struct {
+	ID        int        `json:"id"`
+	Account   string     `json:"account"`
+	Start     time.Time  `json:"start"`
+	End       time.Time  `json:"end"`
+	ATMDaily  float64    `json:"atm_daily,omitempty"`
+	Countries []string   `json:"countries,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Created   time.Time  `json:"created"`
+	Ended     *time.Time `json:"ended,omitempty"`
+}
+
+// activeAt reports whether o applies at t.
+func (o Override) activeAt(t time.Time) bool {
+	return !t.Before(o.Start) && t.Before(o.End) && (o.Ended == nil || t.Before(*o.Ended))
+}
+
+// accountControls are the controls of an account and its overrides, past
+// ones included, oldest first.
+type accountControls struct {
+	Controls  Controls   `json:"controls"`
+	Overrides []Override `json:"overrides,omitempty"`
+}
+
+// SetControls sets the usual controls of an account.
+func (b *Bank) SetControls(number string, controls Controls) error {
+	if err := controls.validate(); err != nil {
+		return err
+	}
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	c := b.controls[number]
+	c.Controls = controls
+	b.controls[number] = c
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventControlsChanged, AccountNumber: number, Time: b.now(),
+		Message: fmt.Sprintf("Controls set: %s", describeControls(controls.ATMDaily, controls.Countries))})
+	return nil
+}
+
+// Controls returns the usual controls of an account, the controls that
+// apply at at, and its overrides, past ones included.
+func (b *Bank) Controls(number string, at time.Time) (usual, effective Controls, overrides []Override, err error) {
+	if _, err := b.Account(number); err != nil {
+		return Controls{}, Controls{}, nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	c := b.controls[number]
+	return c.Controls, c.effective(at), slices.Clone(c.Overrides), nil
+}
+
+// effective returns the controls with the overrides active at t applied.
+func (c accountControls) effective(t time.Time) Controls {
+	e := Controls{ATMDaily: c.Controls.ATMDaily, Countries: slices.Clone(c.Controls.Countries)}
+	for _, o := range c.Overrides {
+		if !o.activeAt(t) {
+			continue
+		}
+		if o.ATMDaily > 0 && (e.ATMDaily == 0 || o.ATMDaily > e.ATMDaily) {
+			e.ATMDaily = o.ATMDaily
+		}
+		if len(e.Countries) > 0 {
+			for _, country := range o.Countries {
+				if !slices.Contains(e.Countries, country) {
+					e.Countries = append(e.Countries, country)
+				}
+			}
+		}
+	}
+	return e
+}
+
+// Override relaxes the controls of an account for a while, as o describes.
+// The override is recorded, and its start and end published as events, so
+// the account's history of overrides stays in the event log.
+func (b *Bank) Override(number string, o Override) (Override, error) {
+	countries := make([]string, len(o.Countries))
+	for i, country := range o.Countries {
+		countries[i] = strings.ToUpper(country)
+	}
+	o.Countries = countries
+	if err := (Controls{ATMDaily: o.ATMDaily, Countries: o.Countries}).validate(); err != nil {
+		return Override{}, err
+	}
+	if o.ATMDaily == 0 && len(o.Countries) == 0 {
+		return Override{}, fmt.Errorf("%w: override changes nothing", ErrInvalidControls)
+	}
+	now := b.now()
+	if o.Start.IsZero() {
+		o.Start = now
+	}
+	if !o.End.After(o.Start) {
+		return Override{}, fmt.Errorf("%w: override ends before it starts", ErrInvalidControls)
+	}
+	if !o.End.After(now) {
+		return Override{}, fmt.Errorf("%w: override ends in the past", ErrInvalidControls)
+	}
+	if _, err := b.Account(number); err != nil {
+		return Override{}, err
+	}
+	b.mu.Lock()
+	b.nextOverride++
+	o.ID, o.Account, o.Created, o.Ended = b.nextOverride, number, now, nil
+	c := b.controls[number]
+	c.Overrides = append(c.Overrides, o)
+	b.controls[number] = c
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventOverrideStarted, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("Override %d from %s to %s: %s", o.ID, o.Start.Format(time.RFC3339), o.End.Format(time.RFC3339), describeControls(o.ATMDaily, o.Countries))})
+	return o, nil
+}
+
+// CancelOverride ends an override of an account before its end.
+func (b *Bank) CancelOverride(number string, id int) (Override, error) {
+	now := b.now()
+	b.mu.Lock()
+	c := b.controls[number]
+	i := slices.IndexFunc(c.Overrides, func(o Override) bool { return o.ID == id })
+	if i < 0 {
+		b.mu.Unlock()
+		return Override{}, fmt.Errorf("%w: %d of %s", ErrOverrideNotFound, id, number)
+	}
+	o := &c.Overrides[i]
+	if o.Ended != nil {
+		ended := *o
+		b.mu.Unlock()
+		return ended, nil
+	}
+	end := now
+	if now.After(o.End) {
+		end = o.End
+	}
+	o.Ended = &end
+	ended := *o
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventOverrideEnded, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("Override %d cancelled", id)})
+	return ended, nil
+}
+
+// ExpireOverrides records the end of the overrides whose end has passed,
+// publishing it, and returns them. Controls revert at the end of an override
+// whether or not this ran; it keeps the event log in step.
+func (b *Bank) ExpireOverrides() []Override {
+	now := b.now()
+	var expired []Override
+	b.mu.Lock()
+	for _, c := range b.controls {
+		for i := range c.Overrides {
+			if o := &c.Overrides[i]; o.Ended == nil && !now.Before(o.End) {
+				end := o.End
+				o.Ended = &end
+				expired = append(expired, *o)
+			}
+		}
+	}
+	b.mu.Unlock()
+	slices.SortFunc(expired, func(a, b Override) int { return a.ID - b.ID })
+	for _, o := range expired {
+		b.Events.Publish(Event{Type: EventOverrideEnded, AccountNumber: o.Account, Time: o.End,
+			Message: fmt.Sprintf("Override %d ended, controls reverted", o.ID)})
+	}
+	return expired
+}
+
+// checkControls checks a withdrawal against the controls of its account
+// that apply when it is posted. The caller holds the account's lock or b.mu.
+func (b *Bank) checkControls(account BankAccount, tx Transaction) error {
+	c, ok := b.controls[account.Number()]
+	if !ok || tx.Type != TransactionWithdrawal {
+		return nil
+	}
+	controls := c.effective(tx.Time)
+	if country := tx.Metadata[MetaCountry]; country != "" && len(controls.Countries) > 0 && !slices.Contains(controls.Countries, country) {
+		return fmt.Errorf("%w: %s", ErrCountryNotAllowed, country)
+	}
+	if limit := controls.ATMDaily; limit > 0 && tx.Metadata[MetaChannel] == ChannelATM {
+		y, m, d := tx.Time.Date()
+		total := tx.Amount
+		for _, t := range account.History() {
+			if ty, tm, td := t.Time.In(tx.Time.Location()).Date(); t.Type == TransactionWithdrawal && t.Metadata[MetaChannel] == ChannelATM && ty == y && tm == m && td == d {
+				total += t.Amount
+			}
+		}
+		if total > limit {
+			return fmt.Errorf("%w (%.2f)", ErrATMLimit, limit)
+		}
+	}
+	return nil
+}
+
+func describeControls(atmDaily float64, countries []string) string {
+	var parts []string
+	if atmDaily > 0 {
+		parts = append(parts, fmt.Sprintf("ATM limit %.2f a day", atmDaily))
+	}
+	if len(countries) > 0 {
+		parts = append(parts, "countries "+strings.Join(countries, ", "))
+	}
+	if len(parts) == 0 {
+		return "unrestricted"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// Credit scoring looks back creditLookbackMonths over every account a
+// customer owns. A score runs from MinCreditScore to MaxCreditScore; below
+// MinLoanScore no loan is offered. Offers keep all monthly obligations,
+// including the new installment, within MaxDebtToIncome of income.
+const (
+	creditLookbackMonths = 6
+	MinCreditScore       = 300
+	MaxCreditScore       = 850
+	MinLoanScore         = 580
+	MaxDebtToIncome      = 0.36
+
+	// lookbackMonth is an average month, a twelfth of a year.
+	lookbackMonth = 30*24*time.Hour + 10*time.Hour + 30*time.Minute
+)
+
+// CreditAssessment is what the scoring engine concluded from a customer's
+// history. Income counts deposits from outside the customer's own accounts;
+// IncomeRegularity is the share of months with any income. Obligations
+// are the recurring payments found in the ledgers plus the installments of
+// loans already held. OverdraftMonths counts months in which an account
+// went below zero.
+type CreditAssessment struct {
+	Score              int
+	MonthlyIncome      float64
+	IncomeRegularity   float64
+	MonthlyObligations float64
+	OverdraftMonths    int
+	Reasons            []string
+}
+
+// MaxLoan returns the largest principal offered at rate over termMonths.
+func (a CreditAssessment) MaxLoan(rate money.Rate, termMonths int) float64 {
+	if a.Score < MinLoanScore {
+		return 0
+	}
+	affordable := a.MonthlyIncome*MaxDebtToIncome - a.MonthlyObligations
+	return MaxPrincipal(affordable, rate, termMonths)
+}
+
+// AssessCredit scores a customer from the ledgers of their accounts.
+func (b *Bank) AssessCredit(customerID string) (CreditAssessment, error) {
+	customer, err := b.Customer(customerID)
+	if err != nil {
+		return CreditAssessment{}, err
+	}
+	now := b.now()
+	since := now.Add(-creditLookbackMonths * lookbackMonth)
+
+	b.mu.Lock()
+	own := make(map[string]bool)
+	var accounts []BankAccount
+	for _, number := range customer.Accounts {
+		if account := b.accounts.account(number); account != nil {
+			own[number] = true
+			accounts = append(accounts, account)
+		}
+	}
+	type ledger struct {
+		balance float64
+		history []Transaction
+	}
+	ledgers := make([]ledger, len(accounts))
+	for i, account := range accounts {
+		ledgers[i] = ledger{account.CheckBalance(), append([]Transaction(nil), account.History()...)}
+	}
+	var loans float64
+	for _, account := range accounts {
+		if loan, ok := account.(*LoanAccount); ok && loan.Owed() > 0 {
+			loans += loan.Installment()
+		}
+	}
+	b.mu.Unlock()
+
+	var a CreditAssessment
+	// Months are counted back from now, so a salary paid on the same day
+	// each month lands in every one of them.
+	incomeMonths := make(map[int]bool)
+	overdraftMonths := make(map[int]bool)
+	var spending []Transaction
+	for i, account := range accounts {
+		if _, ok := account.(*LoanAccount); ok {
+			continue
+		}
+		l := ledgers[i]
+		// Walk back from the current balance to find the balance after
+		// every entry.
+		balance := l.balance
+		for j := len(l.history) - 1; j >= 0; j-- {
+			tx := l.history[j]
+			if tx.Time.Before(since) {
+				break
+			}
+			month := int(now.Sub(tx.Time) / lookbackMonth)
+			if balance < 0 {
+				overdraftMonths[month] = true
+			}
+			if tx.Type.IsCredit() {
+				balance -= tx.Amount
+			} else {
+				balance += tx.Amount
+			}
+			switch {
+			case tx.Type == TransactionDeposit && !own[tx.Counterparty]:
+				a.MonthlyIncome += tx.Amount
+				incomeMonths[month] = true
+			case tx.Type == TransactionWithdrawal && !own[tx.Counterparty]:
+				spending = append(spending, tx)
+			}
+		}
+	}
+	a.MonthlyIncome = money.Round(a.MonthlyIncome/creditLookbackMonths, "", money.HalfEven)
+	a.IncomeRegularity = float64(len(incomeMonths)) / creditLookbackMonths
+	for _, p := range DetectRecurring(spending) {
+		a.MonthlyObligations += p.Amount * float64(p.Cadence.PerYear()) / 12
+	}
+	a.MonthlyObligations = money.Round(a.MonthlyObligations+loans, "", money.HalfEven)
+	a.OverdraftMonths = len(overdraftMonths)
+	a.score()
+	return a, nil
+}
+
+// score weighs income regularity up to 250 points, the share of income left
+// after obligations up to 200 and months without an overdraft up to 100.
+func (a *CreditAssessment) score() {
+	dti := 1.0
+	if a.MonthlyIncome > 0 {
+		dti = math.Min(a.MonthlyObligations/a.MonthlyIncome, 1)
+	}
+	clean := 1 - math.Min(float64(a.OverdraftMonths)/creditLookbackMonths, 1)
+	a.Score = MinCreditScore + int(math.Round(250*a.IncomeRegularity+200*(1-dti)+100*clean))
+
+	if a.MonthlyIncome == 0 {
+		a.Reasons = append(a.Reasons, "no income in the last 6 months")
+	} else if a.IncomeRegularity < 1 {
+		a.Reasons = append(a.Reasons, fmt.Sprintf("income in only %.0f%% of the last 6 months", a.IncomeRegularity*100))
+	}
+	if a.MonthlyIncome > 0 && dti > MaxDebtToIncome {
+		a.Reasons = append(a.Reasons, fmt.Sprintf("obligations take %.0f%% of income", dti*100))
+	}
+	if a.OverdraftMonths > 0 {
+		a.Reasons = append(a.Reasons, fmt.Sprintf("overdrawn in %d of the last 6 months", a.OverdraftMonths))
+	}
+}
+
+func declined(a CreditAssessment, offer float64) error {
+	reasons := a.Reasons
+	if a.Score < MinLoanScore {
+		reasons = append([]string{fmt.Sprintf("score %d is below %d", a.Score, MinLoanScore)}, reasons...)
+	} else {
+		reasons = append([]string{fmt.Sprintf("the most that can be offered is %.2f", offer)}, reasons...)
+	}
+	return fmt.Errorf("%w: %s", ErrLoanDeclined, strings.Join(reasons, "; "))
+}
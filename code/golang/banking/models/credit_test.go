@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// creditBank returns a bank on 2026-07-01 with three customers: c1 paid
+// 3000 on the 20th of each of the last six months and paying 900 rent, c2
+// paid twice and overdrawn after, and c3 never paid.
+func creditBank(t *testing.T) *Bank {
+	t.Helper()
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	for _, c := range []struct{ id, number string }{{"c1", "C1"}, {"c2", "C2"}, {"c3", "C3"}} {
+		b.AddCustomer(&Customer{ID: c.id, Name: c.id})
+		if err := b.OpenFor(c.id, &CheckingAccount{Account: Account{AccountNumber: c.number}, OverdraftLimit: 500}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, month := range []string{"01", "02", "03", "04", "05", "06"} {
+		clock.now = date(t, "2026-"+month+"-20")
+		b.Deposit("C1", 3000, WithCounterparty("employer"))
+		if month == "02" || month == "03" {
+			b.Deposit("C2", 1000, WithCounterparty("employer"))
+		}
+		clock.now = date(t, "2026-"+month+"-22")
+		b.Withdraw("C1", 900, WithCounterparty("landlord"))
+	}
+	clock.now = date(t, "2026-05-01")
+	if err := b.Withdraw("C2", 2300); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = date(t, "2026-07-01")
+	return b
+}
+
+// TestAssessCredit checks the score, the figures it is made of and the
+// reasons given for a regular earner, an irregular one who went overdrawn
+// and a customer without income, and that only a score of at least
+// MinLoanScore is offered a loan.
+func TestAssessCredit(t *testing.T) {
+	b := creditBank(t)
+	a, err := b.AssessCredit("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 250 for income every month, 200 for the 70% left after rent and 100
+	// for never being overdrawn.
+	if a.MonthlyIncome != 3000 || a.IncomeRegularity != 1 || a.MonthlyObligations != 900 || a.OverdraftMonths != 0 || a.Score != 790 || len(a.Reasons) != 0 {
+		t.Errorf("regular earner %+v", a)
+	}
+	if offer := a.MaxLoan(money.Percent(8), 36); offer <= 0 || offer != MaxPrincipal(3000*MaxDebtToIncome-900, money.Percent(8), 36) {
+		t.Errorf("offer %.2f, want what 180 a month pays off", offer)
+	}
+
+	a, _ = b.AssessCredit("c2")
+	if a.IncomeRegularity != 2.0/6 || a.OverdraftMonths != 1 || len(a.Reasons) != 2 ||
+		!strings.Contains(a.Reasons[0], "income in only 33%") || !strings.Contains(a.Reasons[1], "overdrawn in 1 of the last 6 months") {
+		t.Errorf("irregular earner %+v", a)
+	}
+
+	a, _ = b.AssessCredit("c3")
+	if a.Score >= MinLoanScore || a.MaxLoan(money.Percent(8), 36) != 0 || len(a.Reasons) != 1 || a.Reasons[0] != "no income in the last 6 months" {
+		t.Errorf("customer without income %+v", a)
+	}
+	if _, err := b.AssessCredit("c9"); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("unknown customer: %v, want %v", err, ErrCustomerNotFound)
+	}
+}
+
+// TestOpenLoan checks that loans beyond the offer, paid out to someone
+// else's account or without a term are refused, and that one within it is
+// opened and disbursed.
+func TestOpenLoan(t *testing.T) {
+	b := creditBank(t)
+	loan := func(number string, principal float64) *LoanAccount {
+		return &LoanAccount{Account: Account{AccountNumber: number}, Principal: principal, InterestRate: money.Percent(8), TermMonths: 36}
+	}
+	if _, err := b.OpenLoan("c1", &LoanAccount{Account: Account{AccountNumber: "L1"}, Principal: 1000}, "C1"); !errors.Is(err, ErrInvalidLoan) {
+		t.Errorf("loan without a term: %v, want %v", err, ErrInvalidLoan)
+	}
+	if _, err := b.OpenLoan("c1", loan("L1", 1000), "C2"); !errors.Is(err, ErrNotOwnAccount) {
+		t.Errorf("loan paid to another's account: %v, want %v", err, ErrNotOwnAccount)
+	}
+	if _, err := b.OpenLoan("c1", loan("L1", 100_000), "C1"); !errors.Is(err, ErrLoanDeclined) || !strings.Contains(err.Error(), "the most that can be offered is") {
+		t.Errorf("loan beyond the offer: %v, want %v with the offer", err, ErrLoanDeclined)
+	}
+	if _, err := b.OpenLoan("c3", loan("L3", 100), "C3"); !errors.Is(err, ErrLoanDeclined) || !strings.Contains(err.Error(), "is below 580") {
+		t.Errorf("loan at a low score: %v, want %v with the score", err, ErrLoanDeclined)
+	}
+	if _, err := b.Account("L1"); err == nil {
+		t.Fatal("a declined loan was opened")
+	}
+
+	if _, err := b.OpenLoan("c1", loan("L1", 1000), "C1"); err != nil {
+		t.Fatal(err)
+	}
+	checkBalances(t, b, map[string]float64{"L1": -1000, "C1": 3000*6 - 900*6 + 1000})
+	if err := b.Authorize("L1", "c1", PermissionAdmin, ""); err != nil {
+		t.Errorf("c1 does not hold the loan: %v", err)
+	}
+	// The installment counts against the next offer.
+	if a, _ := b.AssessCredit("c1"); a.MonthlyObligations <= 900 {
+		t.Errorf("obligations after the loan %.2f, want the installment added", a.MonthlyObligations)
+	}
+}
+
+// TestAllocationOrder pays into a loan that owes fees, interest and
+// principal under the default order and configured ones, checking the legs
+// posted and what is left owing, and that malformed orders are refused.
+func TestAllocationOrder(t *testing.T) {
+	SetOutput(io.Discard)
+	for _, tt := range []struct {
+		names   []string
+		payment float64
+		// legs are the amounts posted to each component, in order.
+		legs []string
+		left Outstanding
+	}{
+		{nil, 40, []string{"interest 30", "fees 10"}, Outstanding{Fees: 10, Principal: 1000}},
+		{[]string{"fees", "interest", "principal"}, 40, []string{"fees 20", "interest 20"}, Outstanding{Interest: 10, Principal: 1000}},
+		{[]string{"Principal", " fees ", "interest"}, 40, []string{"principal 40"}, Outstanding{Fees: 20, Interest: 30, Principal: 960}},
+		{nil, 1050, []string{"interest 30", "fees 20", "principal 1000"}, Outstanding{}},
+	} {
+		b := NewBank()
+		if tt.names != nil {
+			order, err := ParseAllocationOrder(tt.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.Allocation["Loan"] = order
+		}
+		b.Open(&LoanAccount{Account: Account{AccountNumber: "L1", Balance: -1050, Transactions: []Transaction{
+			{Type: TransactionFee, Amount: 20}, {Type: TransactionInterestCharge, Amount: 30}}}, Principal: 1000})
+		if err := b.Deposit("L1", tt.payment); err != nil {
+			t.Fatal(err)
+		}
+		history, _ := b.History("L1")
+		var legs []string
+		for _, tx := range history[2:] {
+			legs = append(legs, fmt.Sprintf("%s %v", tx.Metadata[allocationKey], tx.Amount))
+		}
+		left, _ := b.Outstanding("L1")
+		if !slices.Equal(legs, tt.legs) || left != tt.left {
+			t.Errorf("order %v paying %v: legs %v leaving %+v, want %v leaving %+v", tt.names, tt.payment, legs, left, tt.legs, tt.left)
+		}
+	}
+
+	for _, names := range [][]string{
+		{"fees", "interest"},
+		{"fees", "fees", "principal"},
+		{"fees", "interest", "penalties"},
+	} {
+		if _, err := ParseAllocationOrder(names); !errors.Is(err, ErrInvalidAllocation) {
+			t.Errorf("ParseAllocationOrder(%v) = %v, want ErrInvalidAllocation", names, err)
+		}
+	}
+}
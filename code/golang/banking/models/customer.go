@@ -0,0 +1,66 @@
+package models
+
+import (
+	"sort"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrCustomerNotFound = banking.New(banking.CodeNotFound, "customer not found")
+	ErrCustomerExists   = banking.New(banking.CodeConflict, "customer already exists")
+)
+
+// Customer is a bank customer and the numbers of the accounts they own.
+type Customer struct {
+	ID       string
+	Name     string
+	Accounts []string
+}
+
+func (b *Bank) AddCustomer(customer *Customer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.customers[customer.ID]; ok {
+		return ErrCustomerExists
+	}
+	b.customers[customer.ID] = customer
+	return nil
+}
+
+func (b *Bank) Customer(id string) (*Customer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	customer, ok := b.customers[id]
+	if !ok {
+		return nil, ErrCustomerNotFound
+	}
+	return customer, nil
+}
+
+// Customers returns every customer ordered by ID.
+func (b *Bank) Customers() []*Customer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	customers := make([]*Customer, 0, len(b.customers))
+	for _, customer := range b.customers {
+		customers = append(customers, customer)
+	}
+	sort.Slice(customers, func(i, j int) bool { return customers[i].ID < customers[j].ID })
+	return customers
+}
+
+// OpenFor opens account and assigns it to an existing customer.
+func (b *Bank) OpenFor(customerID string, account BankAccount) error {
+	customer, err := b.Customer(customerID)
+	if err != nil {
+		return err
+	}
+	if err := b.Open(account); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	customer.Accounts = append(customer.Accounts, account.Number())
+	return nil
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/calendar"
+	"gsolano/banking/money"
+)
+
+// CycleKind is how an account's statement cycles are laid out.
+type CycleKind string
+
+const (
+	// CycleCalendarMonth closes a statement at the start of every month.
+	CycleCalendarMonth CycleKind = "calendar_month"
+	// CycleAnchorDay closes a statement on the same day of every month, or
+	// on the last day of months that are too short.
+	CycleAnchorDay CycleKind = "anchor_day"
+	// CycleEveryNDays closes a statement every Days days.
+	CycleEveryNDays CycleKind = "every_n_days"
+)
+
+var ErrInvalidCycle = banking.New(banking.CodeInvalidArgument, "invalid statement cycle")
+
+// StatementCycle decides the periods an account's statements cover. Day is
+// the anchor day of CycleAnchorDay and Days the length of CycleEveryNDays.
+// Since is when the account started on the cycle: cycles of every N days
+// count from it, and the first period starts there, usually part way into
+// a cycle.
+type StatementCycle struct {
+	Kind  CycleKind `json:"kind"`
+	Day   int       `json:"day,omitempty"`
+	Days  int       `json:"days,omitempty"`
+	Since time.Time `json:"since"`
+}
+
+// Period is the span of one statement: the entries after Start and up to
+// End, when the statement closes. A Partial period is the first one of an
+// account, shorter than a full cycle of FullDays days.
+type Period struct {
+	Start    time.Time
+	End      time.Time
+	Partial  bool
+	FullDays int
+}
+
+// Days is the number of days the period covers.
+func (p Period) Days() int {
+	return int(p.End.Sub(p.Start).Hours()/24 + 0.5)
+}
+
+// Share is how much of a full cycle the period covers, 1 for full periods.
+// Charges made once per cycle are prorated by it.
+func (p Period) Share() float64 {
+	if !p.Partial || p.FullDays == 0 {
+		return 1
+	}
+	return float64(p.Days()) / float64(p.FullDays)
+}
+
+func (c StatementCycle) validate() error {
+	switch {
+	case c.Kind == CycleCalendarMonth:
+	case c.Kind == CycleAnchorDay && c.Day >= 1 && c.Day <= 31:
+	case c.Kind == CycleEveryNDays && c.Days >= 1 && c.Days <= 366:
+	case c.Kind == CycleAnchorDay:
+		return fmt.Errorf("%w: anchor day must be between 1 and 31", ErrInvalidCycle)
+	case c.Kind == CycleEveryNDays:
+		return fmt.Errorf("%w: days must be between 1 and 366", ErrInvalidCycle)
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidCycle, c.Kind)
+	}
+	return nil
+}
+
+// Period returns the period that t falls in: the one that closes at t when
+// t is a cycle boundary. Times before Since fall in the first period.
+func (c StatementCycle) Period(t time.Time) Period {
+	since := calendar.StartOfDay(c.Since)
+	t = t.In(since.Location())
+	if t.Before(since) {
+		t = since
+	}
+	var start, end time.Time
+	switch c.Kind {
+	case CycleEveryNDays:
+		length := time.Duration(c.Days) * 24 * time.Hour
+		k := (t.Sub(since) - 1) / length
+		start = since.Add(k * length)
+		end = start.Add(length)
+	default:
+		day := c.Day
+		if c.Kind == CycleCalendarMonth || day == 0 {
+			day = 1
+		}
+		start = anchorIn(t.Year(), t.Month(), day, t.Location())
+		if !t.After(start) {
+			start = anchorIn(t.Year(), t.Month()-1, day, t.Location())
+		}
+		end = anchorIn(start.Year(), start.Month()+1, day, t.Location())
+	}
+	p := Period{Start: start, End: end}
+	p.FullDays = p.Days()
+	if since.After(start) {
+		p.Start, p.Partial = since, true
+	}
+	return p
+}
+
+// anchorIn returns day of month, or the last day of a shorter month, at
+// midnight.
+func anchorIn(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// SetStatementCycle sets the statement cycle of an account. A cycle without
+// Since starts now.
+func (b *Bank) SetStatementCycle(number string, cycle StatementCycle) error {
+	if err := cycle.validate(); err != nil {
+		return err
+	}
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	if cycle.Since.IsZero() {
+		cycle.Since = b.now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cycles[number] = cycle
+	return nil
+}
+
+// StatementCycle returns the statement cycle of an account: calendar months
+// from its first entry unless one was set.
+func (b *Bank) StatementCycle(number string) (StatementCycle, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return StatementCycle{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cycleLocked(account), nil
+}
+
+// cycleLocked returns the cycle of account. The caller holds b.mu.
+func (b *Bank) cycleLocked(account BankAccount) StatementCycle {
+	if cycle, ok := b.cycles[account.Number()]; ok {
+		return b.inZoneLocked(account.Number(), cycle)
+	}
+	cycle := StatementCycle{Kind: CycleCalendarMonth, Since: b.now()}
+	if history := account.History(); len(history) > 0 {
+		cycle.Since = history[0].Time
+	}
+	if card, ok := account.(*CreditCardAccount); ok && len(card.Statements) > 0 && card.Statements[0].Closed.Before(cycle.Since) {
+		cycle.Since = card.Statements[0].Closed
+	}
+	return b.inZoneLocked(account.Number(), cycle)
+}
+
+// inZoneLocked puts the start of a cycle in the zone of its account, so its
+// periods start at the account's midnights, holding b.mu.
+func (b *Bank) inZoneLocked(number string, cycle StatementCycle) StatementCycle {
+	if loc := b.zoneLocked(number); loc != nil {
+		cycle.Since = cycle.Since.In(loc)
+	}
+	return cycle
+}
+
+// Statement is an account's statement for one period: its balance at the
+// start and the end of the period and the entries in between. The period
+// of a statement still open ends in the future and Closing is the balance
+// so far. Lines are those a StatementLiner adds.
+type Statement struct {
+	Account      string
+	Period       Period
+	Opening      float64
+	Closing      float64
+	Transactions []Transaction
+	Lines        []StatementLine
+}
+
+// Statement returns the statement of the period of an account's cycle that
+// at falls in.
+func (b *Bank) Statement(number string, at time.Time) (Statement, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return Statement{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	period := b.cycleLocked(account).Period(at)
+	s := Statement{
+		Account: number,
+		Period:  period,
+		Opening: balanceAt(account, period.Start),
+		Closing: balanceAt(account, period.End),
+	}
+	for _, tx := range account.History() {
+		if tx.Time.After(period.Start) && !tx.Time.After(period.End) {
+			s.Transactions = append(s.Transactions, tx)
+		}
+	}
+	if liner, ok := account.(StatementLiner); ok {
+		s.Lines = liner.StatementLines(period)
+	}
+	return s, nil
+}
+
+// balanceAt is the balance of account once every entry up to at was posted,
+// to the cent: backing entries out of the balance leaves float noise.
+func balanceAt(account BankAccount, at time.Time) float64 {
+	balance := account.CheckBalance()
+	for _, tx := range account.History() {
+		if !tx.Time.After(at) {
+			continue
+		}
+		if tx.Type.IsCredit() {
+			balance -= tx.Amount
+		} else {
+			balance += tx.Amount
+		}
+	}
+	return money.Round(balance, "", money.HalfEven)
+}
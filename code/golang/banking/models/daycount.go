@@ -0,0 +1,86 @@
+package models
+
+import (
+	"math/big"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// MetaDayCount is the metadata key of the day-count convention an interest
+// entry accrued under. Entries without it accrued a period's rate, or a
+// month's for loans.
+const MetaDayCount = "day_count"
+
+// dayCountInterest is the exact interest at the annual rate on the daily
+// balance of a ledger that left balance, from its last interest entry, or
+// its first entry when it has none, until at under convention d. Nothing
+// accrues on days the balance is zero or less. Days before a scheduled rate
+// change among resets took effect accrue at the rate it changed from.
+func dayCountInterest(history []Transaction, balance float64, rate money.Rate, resets []RateReset, d money.DayCount, at time.Time) *big.Rat {
+	last := -1
+	for i, tx := range history {
+		if tx.Type == TransactionInterest || tx.Type == TransactionInterestCharge {
+			last = i
+		}
+	}
+	running := exactAmount(balance)
+	for _, tx := range history {
+		running.Sub(running, signedAmount(tx))
+	}
+	interest := new(big.Rat)
+	accrue := func(from, to time.Time) {
+		if running.Sign() <= 0 {
+			return
+		}
+		for _, r := range resets {
+			if r.Change == 0 || !r.Time.After(from) {
+				continue
+			}
+			if !r.Time.Before(to) {
+				interest.Add(interest, r.From.Accrued(running, d, from, to))
+				return
+			}
+			interest.Add(interest, r.From.Accrued(running, d, from, r.Time))
+			from = r.Time
+		}
+		interest.Add(interest, rate.Accrued(running, d, from, to))
+	}
+	var since time.Time
+	for i, tx := range history {
+		switch {
+		case i == last || last < 0 && i == 0:
+			since = tx.Time
+		case i > last && !since.IsZero():
+			accrue(since, tx.Time)
+			since = tx.Time
+		}
+		running.Add(running, signedAmount(tx))
+	}
+	if !since.IsZero() {
+		accrue(since, at)
+	}
+	return interest
+}
+
+// dayCountInterest is the exact interest of a loan whose ledger is history
+// that left balance, on the principal it owes, from its last charge, or its
+// opening, until at under convention d.
+func (l *LoanAccount) dayCountInterest(history []Transaction, balance float64, d money.DayCount, at time.Time) *big.Rat {
+	since := l.Opened
+	for _, tx := range history {
+		if tx.Type == TransactionInterestCharge {
+			since = tx.Time
+		}
+	}
+	if since.IsZero() && len(history) > 0 {
+		since = history[0].Time
+	}
+	principal := outstandingOf(history, balance, CurrencyOf(l)).Principal
+	return l.InterestRate.Accrued(exactAmount(principal), d, since, at)
+}
+
+func ratFloat(x *big.Rat) float64 {
+	f, _ := x.Float64()
+	return f
+}
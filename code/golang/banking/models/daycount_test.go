@@ -0,0 +1,163 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// TestDayCountInterest posts interest on a savings product that counts
+// ACT/360 and a loan that counts 30/360, checks every entry against the
+// interest worked out by hand, and that the interest audit explains all of
+// them.
+func TestDayCountInterest(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	// 3.6% a year is exactly 0.01% a day over 360 days.
+	if err := b.Products.Define(Product{Code: "daily", Name: "Daily Savings", Kind: ProductSavings,
+		InterestRate: money.Percent(3.6), DayCount: money.Actual360}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.OpenAccount("daily", "", "S1"); err != nil {
+		t.Fatal(err)
+	}
+	loan := &LoanAccount{Account: Account{AccountNumber: "L1", Balance: -12000}, Principal: 12000,
+		InterestRate: money.Percent(6), TermMonths: 12, Opened: date(t, "2026-01-31"), DayCount: money.Thirty360}
+	if err := b.Open(loan); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		date    string
+		account string
+		deposit float64
+		// interest is what applying interest must post.
+		interest float64
+	}{
+		{date: "2026-01-01", account: "S1", deposit: 10000},
+		{date: "2026-01-11", account: "S1", deposit: 5000},
+		// 10 days on 10,000 and 20 on 15,000.
+		{date: "2026-01-31", account: "S1", interest: 40},
+		// 29 days on 15,040.
+		{date: "2026-03-01", account: "S1", interest: 43.62},
+		// 30/360 counts the 31st of January as the 30th: 28 days.
+		{date: "2026-02-28", account: "L1", interest: 56},
+		// but not the 31st of March after the 28th: 33 days.
+		{date: "2026-03-31", account: "L1", interest: 66},
+	}
+	for _, s := range steps {
+		clock.now = date(t, s.date)
+		if s.deposit > 0 {
+			if err := b.Deposit(s.account, s.deposit); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		account, _ := b.Account(s.account)
+		before := len(account.History())
+		if err := b.ApplyInterest(s.account); err != nil {
+			t.Fatalf("%s: %s: %v", s.date, s.account, err)
+		}
+		history := account.History()
+		if len(history) != before+1 {
+			t.Fatalf("%s: %s: no interest posted", s.date, s.account)
+		}
+		tx := history[len(history)-1]
+		if tx.Amount != s.interest {
+			t.Errorf("%s: %s: interest %v, want %v", s.date, s.account, tx.Amount, s.interest)
+		}
+		want := money.Actual360
+		if s.account == "L1" {
+			want = money.Thirty360
+		}
+		if got := tx.Metadata[MetaDayCount]; got != string(want) {
+			t.Errorf("%s: %s: entry records day count %q, want %q", s.date, s.account, got, want)
+		}
+	}
+
+	report := b.InterestAuditReport(time.Time{}, time.Time{})
+	if len(report) != 2 {
+		t.Fatalf("audit covers %d accounts, want 2", len(report))
+	}
+	for _, audit := range report {
+		if len(audit.Checks) != 2 {
+			t.Errorf("%s: audit checks %d entries, want 2", audit.Account, len(audit.Checks))
+		}
+		if audit.Unexplained != 0 || audit.RateMismatches != 0 {
+			t.Errorf("%s: audit finds %d unexplained entries and %d rate mismatches: %+v",
+				audit.Account, audit.Unexplained, audit.RateMismatches, audit.Checks)
+		}
+		want := map[string]money.DayCount{"S1": money.Actual360, "L1": money.Thirty360}[audit.Account]
+		for _, c := range audit.Checks {
+			if c.Convention != string(want) {
+				t.Errorf("%s: audited as %s", audit.Account, c.Convention)
+			}
+		}
+	}
+}
+
+// TestNegativeInterest checks that a negative rate charges interest as its
+// own entry that lowers the balance, never a negative deposit, and only
+// while FeatureNegativeInterest is on and the balance is positive.
+func TestNegativeInterest(t *testing.T) {
+	SetOutput(io.Discard)
+	for _, tt := range []struct {
+		name    string
+		balance float64
+		rate    money.Rate
+		enabled bool
+		err     error
+		// charged is the interest charge posted, 0 for none.
+		charged float64
+	}{
+		{name: "charged", balance: 1000, rate: money.Percent(-0.5), enabled: true, charged: 5},
+		{name: "half a cent rounds to even", balance: 1001, rate: money.Percent(-0.5), enabled: true, charged: 5},
+		{name: "disabled", balance: 1000, rate: money.Percent(-0.5), err: ErrFeatureDisabled},
+		{name: "empty account", rate: money.Percent(-0.5), enabled: true},
+		{name: "positive rate", balance: 1000, rate: money.Percent(0.5), charged: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBank()
+			b.Flags.Set(FeatureNegativeInterest, tt.enabled)
+			b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}, InterestRate: tt.rate})
+			if tt.balance > 0 {
+				if err := b.Deposit("S1", tt.balance); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := b.ApplyInterest("S1"); !errors.Is(err, tt.err) {
+				t.Fatalf("ApplyInterest = %v, want %v", err, tt.err)
+			}
+			history, _ := b.History("S1")
+			var charged float64
+			for _, tx := range history {
+				if tx.Type == TransactionInterestCharge {
+					charged += tx.Amount
+				}
+				if tx.Type == TransactionInterest && tx.Amount < 0 {
+					t.Errorf("negative interest deposit %+v", tx)
+				}
+			}
+			if charged != tt.charged {
+				t.Errorf("charged %v, want %v", charged, tt.charged)
+			}
+			if balance, _ := b.Balance("S1"); tt.rate < 0 && balance != tt.balance-tt.charged {
+				t.Errorf("balance %v after the charge, want %v", balance, tt.balance-tt.charged)
+			}
+		})
+	}
+}
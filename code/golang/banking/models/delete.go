@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+)
+
+// DefaultDeleteGrace is how long a soft-deleted account can be restored
+// unless the bank's DeleteGrace says otherwise.
+const DefaultDeleteGrace = 30 * 24 * time.Hour
+
+var (
+	ErrNotDeleted     = banking.New(banking.CodeConflict, "account is not deleted")
+	ErrAlreadyDeleted = banking.New(banking.CodeConflict, "account already deleted")
+	ErrGraceExpired   = banking.New(banking.CodeConflict, "the grace period to restore the account has passed")
+)
+
+// DeleteAccount soft-deletes an account: it is closed, as by CloseAccount,
+// if it is still open, and left out of account listings unless they ask for
+// deleted accounts. Its ledger is kept and it can still be read by number.
+// RestoreAccount reopens it until DeleteGrace has passed, and ArchiveClosed
+// leaves it alone until then.
+func (b *Bank) DeleteAccount(number string) error {
+	if err := b.CloseAccount(number); err != nil && !errors.Is(err, ErrAccountClosed) {
+		return err
+	}
+	now := b.now()
+	b.mu.Lock()
+	_, deleted := b.deleted[number]
+	if !deleted {
+		b.deleted[number] = now
+	}
+	b.mu.Unlock()
+	if deleted {
+		return ErrAlreadyDeleted
+	}
+	b.Events.Publish(Event{Type: EventAccountDeleted, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("Account %s deleted", number)})
+	return nil
+}
+
+// RestoreAccount undoes DeleteAccount within the grace period: the account
+// is open again, takes entries and is listed.
+func (b *Bank) RestoreAccount(number string) error {
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	now := b.now()
+	b.mu.Lock()
+	deleted, ok := b.deleted[number]
+	switch {
+	case !ok:
+		b.mu.Unlock()
+		return ErrNotDeleted
+	case !now.Before(deleted.Add(b.DeleteGrace)):
+		b.mu.Unlock()
+		return ErrGraceExpired
+	}
+	delete(b.deleted, number)
+	delete(b.closed, number)
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventAccountRestored, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("Account %s restored", number)})
+	return nil
+}
+
+// DeletedAt returns when an account was soft-deleted, or the zero time when
+// it is not.
+func (b *Bank) DeletedAt(number string) time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.deleted[number]
+}
+
+// ListAccounts returns the accounts of the bank in account number order,
+// without the soft-deleted ones unless includeDeleted is set.
+func (b *Bank) ListAccounts(includeDeleted bool) []BankAccount {
+	accounts := b.Accounts()
+	if includeDeleted {
+		return accounts
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	listed := accounts[:0]
+	for _, account := range accounts {
+		if _, ok := b.deleted[account.Number()]; !ok {
+			listed = append(listed, account)
+		}
+	}
+	return listed
+}
+
+// Lifecycle is when an account was closed and soft-deleted, zero for an
+// account that was not.
+type Lifecycle struct {
+	Closed  time.Time
+	Deleted time.Time
+}
+
+// Lifecycle returns when an account was closed and deleted.
+func (b *Bank) Lifecycle(number string) Lifecycle {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return Lifecycle{Closed: b.closed[number], Deleted: b.deleted[number]}
+}
+
+// SetLifecycle restores the lifecycle of an account loaded from a store,
+// without the checks and events of closing or deleting it.
+func (b *Bank) SetLifecycle(number string, l Lifecycle) error {
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.closed, number)
+	delete(b.deleted, number)
+	if !l.Closed.IsZero() {
+		b.closed[number] = l.Closed
+	}
+	if !l.Deleted.IsZero() {
+		b.deleted[number] = l.Deleted
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestDeleteAndRestore soft-deletes accounts and checks that they leave the
+// listings and take no entries but stay readable, that one is restored
+// within the grace period and opens again, and that one past it, accounts
+// not deleted and accounts deleted twice are refused.
+func TestDeleteAndRestore(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-03-02")}
+	b := NewBank()
+	b.Clock = clock
+	b.DeleteGrace = 7 * 24 * time.Hour
+	for _, number := range []string{"C1", "C2", "C3", "C4"} {
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: number}})
+	}
+	b.Deposit("C4", 10)
+	if err := b.DeleteAccount("C4"); !errors.Is(err, ErrBalanceNotZero) {
+		t.Errorf("deleting an account with money: %v, want ErrBalanceNotZero", err)
+	}
+	b.CloseAccount("C2")
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.DeleteAccount(number); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.DeleteAccount("C1"); !errors.Is(err, ErrAlreadyDeleted) {
+		t.Errorf("deleting C1 twice: %v, want ErrAlreadyDeleted", err)
+	}
+
+	if listed := len(b.ListAccounts(false)); listed != 2 {
+		t.Errorf("%d accounts listed, want 2", listed)
+	}
+	if listed := len(b.ListAccounts(true)); listed != 4 {
+		t.Errorf("%d accounts listed with the deleted ones, want 4", listed)
+	}
+	if _, err := b.Account("C1"); err != nil {
+		t.Errorf("reading a deleted account: %v", err)
+	}
+	if err := b.Deposit("C1", 5); err == nil {
+		t.Error("a deleted account took a deposit")
+	}
+
+	clock.now = clock.now.Add(6 * 24 * time.Hour)
+	if err := b.RestoreAccount("C1"); err != nil {
+		t.Fatal(err)
+	}
+	if l := b.Lifecycle("C1"); !l.Closed.IsZero() || !l.Deleted.IsZero() {
+		t.Errorf("restored C1 has lifecycle %+v", l)
+	}
+	if err := b.Deposit("C1", 5); err != nil {
+		t.Errorf("depositing into the restored account: %v", err)
+	}
+
+	clock.now = clock.now.Add(24 * time.Hour)
+	for number, want := range map[string]error{"C2": ErrGraceExpired, "C3": ErrNotDeleted, "C9": ErrAccountNotFound} {
+		if err := b.RestoreAccount(number); !errors.Is(err, want) {
+			t.Errorf("restoring %s: %v, want %v", number, err, want)
+		}
+	}
+}
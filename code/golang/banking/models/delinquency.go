@@ -0,0 +1,195 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+)
+
+var ErrNoSchedule = banking.New(banking.CodeInvalidArgument, "account has no payment schedule")
+
+// DelinquencyBucket groups accounts by how long their oldest missed payment
+// has been outstanding.
+type DelinquencyBucket string
+
+const (
+	BucketCurrent DelinquencyBucket = "current"
+	// BucketPastDue is 1 to 29 days past due.
+	BucketPastDue DelinquencyBucket = "past_due"
+	Bucket30      DelinquencyBucket = "30_days"
+	Bucket60      DelinquencyBucket = "60_days"
+	Bucket90      DelinquencyBucket = "90_days"
+)
+
+// BucketFor returns the bucket of an account daysPastDue days behind.
+func BucketFor(daysPastDue int) DelinquencyBucket {
+	switch {
+	case daysPastDue >= 90:
+		return Bucket90
+	case daysPastDue >= 60:
+		return Bucket60
+	case daysPastDue >= 30:
+		return Bucket30
+	case daysPastDue > 0:
+		return BucketPastDue
+	default:
+		return BucketCurrent
+	}
+}
+
+// DelinquencyPolicy decides when a missed payment costs a late fee: once it
+// is more than GracePeriod days late, LateFee is charged, once per missed
+// payment.
+type DelinquencyPolicy struct {
+	GracePeriod int
+	LateFee     float64
+}
+
+var DefaultDelinquencyPolicy = DelinquencyPolicy{GracePeriod: 15, LateFee: 25}
+
+// Delinquency is where a loan or card stands on its payments. OldestMissed
+// is the due date of the oldest payment still missing and PastDue what must
+// be paid to bring the account current.
+type Delinquency struct {
+	Account      string
+	Bucket       DelinquencyBucket
+	DaysPastDue  int
+	PastDue      float64
+	Missed       int
+	OldestMissed time.Time
+}
+
+// scheduled is implemented by accounts that expect payments by set dates.
+// missed returns the due dates of the payments missing at now, oldest first,
+// and the amount past due.
+type scheduled interface {
+	BankAccount
+	missed(now time.Time) ([]time.Time, float64)
+}
+
+// lateFeeFor is the metadata key that ties a late fee to the due date it was
+// charged for.
+const lateFeeFor = "late_fee_for"
+
+func delinquencyOf(account scheduled, now time.Time) (Delinquency, []time.Time) {
+	d := Delinquency{Account: account.Number()}
+	dates, pastDue := account.missed(now)
+	if len(dates) > 0 {
+		d.Missed, d.PastDue, d.OldestMissed = len(dates), pastDue, dates[0]
+		d.DaysPastDue = int(now.Sub(dates[0]).Hours() / 24)
+	}
+	d.Bucket = BucketFor(d.DaysPastDue)
+	return d, dates
+}
+
+// Delinquency returns the payment status of a loan or credit card.
+func (b *Bank) Delinquency(number string) (Delinquency, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return Delinquency{}, err
+	}
+	s, ok := account.(scheduled)
+	if !ok {
+		return Delinquency{}, ErrNoSchedule
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	d, _ := delinquencyOf(s, b.now())
+	return d, nil
+}
+
+// ReviewDelinquency brings every loan and card up to date: it charges the late
+// fees that have come due under b.DelinquencyPolicy and publishes
+// EventDelinquencyChanged for every account that moved to another bucket
+// since the last review. It returns the accounts that are not current, most
+// overdue first, for reporting.
+func (b *Bank) ReviewDelinquency() []Delinquency {
+	now := b.now()
+	b.mu.Lock()
+	var report []Delinquency
+	var events []Event
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		s, ok := account.(scheduled)
+		if !ok || !b.closed[number].IsZero() {
+			continue
+		}
+		d, dates := delinquencyOf(s, now)
+		events = append(events, b.chargeLateFees(s, dates, now)...)
+		if previous := b.buckets[number]; d.Bucket != previous && (previous != "" || d.Bucket != BucketCurrent) {
+			events = append(events, Event{Type: EventDelinquencyChanged, AccountNumber: number, Time: now,
+				Message: fmt.Sprintf("Account %s is %s (%d days past due, %.2f overdue)", number, d.Bucket, d.DaysPastDue, d.PastDue)})
+		}
+		b.buckets[number] = d.Bucket
+		if d.Bucket != BucketCurrent {
+			report = append(report, d)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	sortDelinquency(report)
+	return report
+}
+
+// DelinquencyReport returns the loans and cards that are not current, most
+// overdue first, without charging fees.
+func (b *Bank) DelinquencyReport() []Delinquency {
+	now := b.now()
+	b.mu.Lock()
+	var report []Delinquency
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		if s, ok := account.(scheduled); ok && b.closed[number].IsZero() {
+			if d, _ := delinquencyOf(s, now); d.Bucket != BucketCurrent {
+				report = append(report, d)
+			}
+		}
+	}
+	b.mu.Unlock()
+	sortDelinquency(report)
+	return report
+}
+
+func sortDelinquency(report []Delinquency) {
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].DaysPastDue != report[j].DaysPastDue {
+			return report[i].DaysPastDue > report[j].DaysPastDue
+		}
+		return report[i].Account < report[j].Account
+	})
+}
+
+// chargeLateFees posts a late fee for each missed due date past the grace
+// period that has not been charged one yet. The caller holds b.mu.
+func (b *Bank) chargeLateFees(account scheduled, dates []time.Time, now time.Time) []Event {
+	policy := b.DelinquencyPolicy
+	if policy.LateFee <= 0 {
+		return nil
+	}
+	charged := make(map[string]bool)
+	for _, tx := range account.History() {
+		if tx.Type == TransactionFee && tx.Metadata[lateFeeFor] != "" {
+			charged[tx.Metadata[lateFeeFor]] = true
+		}
+	}
+	var events []Event
+	for _, due := range dates {
+		key := due.Format(time.DateOnly)
+		if charged[key] || !now.After(due.AddDate(0, 0, policy.GracePeriod)) {
+			continue
+		}
+		tx, posted, err := b.postLocked(account, TransactionFee, policy.LateFee, []TxOption{
+			WithDescription("late fee"), WithMetadata(lateFeeFor, key),
+		})
+		events = append(events, posted...)
+		if err == nil {
+			events = append(events, Event{Type: EventTransactionPosted, AccountNumber: account.Number(), Transaction: &tx, Time: tx.Time})
+		}
+	}
+	return events
+}
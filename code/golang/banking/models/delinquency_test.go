@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// TestReviewDelinquency misses installments of a loan and checks, review by
+// review, its bucket and what is past due, that a late fee is charged once
+// per missed installment after the grace period, and that the bucket
+// changes are published.
+func TestReviewDelinquency(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{}
+	b := NewBank()
+	b.Clock = clock
+	loan := &LoanAccount{Account: Account{AccountNumber: "L1", Balance: -1200}, Principal: 1200, TermMonths: 12, Opened: date(t, "2026-01-10")}
+	b.Open(loan)
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	installment := loan.Installment()
+	var changes []string
+	b.Events.Subscribe(func(e Event) {
+		if e.Type == EventDelinquencyChanged {
+			changes = append(changes, e.Message)
+		}
+	})
+
+	for _, tt := range []struct {
+		date    string
+		pay     float64
+		bucket  DelinquencyBucket
+		pastDue float64
+		fees    int
+	}{
+		{date: "2026-02-05", bucket: BucketCurrent},
+		{date: "2026-02-20", bucket: BucketPastDue, pastDue: installment},
+		// Past the grace period of 15 days.
+		{date: "2026-02-26", bucket: BucketPastDue, pastDue: installment, fees: 1},
+		{date: "2026-02-27", bucket: BucketPastDue, pastDue: installment, fees: 1},
+		// The installment and the fee bring it current.
+		{date: "2026-02-28", pay: installment + DefaultDelinquencyPolicy.LateFee, bucket: BucketCurrent, fees: 1},
+		// March is late past the grace period, April not yet.
+		{date: "2026-04-15", bucket: Bucket30, pastDue: 2 * installment, fees: 2},
+	} {
+		clock.now = date(t, tt.date)
+		if tt.pay > 0 {
+			if err := b.Deposit("L1", tt.pay); err != nil {
+				t.Fatal(err)
+			}
+		}
+		report := b.ReviewDelinquency()
+		d, err := b.Delinquency("L1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Bucket != tt.bucket || d.PastDue != tt.pastDue || (len(report) == 0) != (tt.bucket == BucketCurrent) {
+			t.Errorf("%s: %s with %.2f past due, reported %v, want %s with %.2f", tt.date, d.Bucket, d.PastDue, report, tt.bucket, tt.pastDue)
+		}
+		fees := 0
+		for _, tx := range loan.History() {
+			if tx.Type == TransactionFee && tx.Metadata[lateFeeFor] != "" {
+				fees++
+			}
+		}
+		if fees != tt.fees {
+			t.Errorf("%s: %d late fees, want %d", tt.date, fees, tt.fees)
+		}
+	}
+	want := []string{
+		"Account L1 is past_due (10 days past due, 100.00 overdue)",
+		"Account L1 is current (0 days past due, 0.00 overdue)",
+		"Account L1 is 30_days (36 days past due, 200.00 overdue)",
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("bucket changes %q, want %q", changes, want)
+	}
+	if _, err := b.Delinquency("C1"); !errors.Is(err, ErrNoSchedule) {
+		t.Errorf("delinquency of a checking account: %v, want ErrNoSchedule", err)
+	}
+}
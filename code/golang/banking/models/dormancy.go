@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrAccountDormant = banking.New(banking.CodeAccountFrozen, "account is dormant")
+	ErrNotDormant     = banking.New(banking.CodeConflict, "account is not dormant")
+)
+
+// Escheatment is a long-dormant balance to be reported and handed over to
+// the state as unclaimed property.
+type Escheatment struct {
+	Number       string     `json:"number"`
+	Customers    []string   `json:"customers"`
+	Balance      float64    `json:"balance"`
+	Currency     string     `json:"currency"`
+	LastActivity time.Time  `json:"last_activity"`
+	DormantSince *time.Time `json:"dormant_since,omitempty"`
+}
+
+// LastActivity returns when the customer last moved money in or out of an
+// account, ignoring the interest and fees the bank posts, and false when
+// they never did.
+func LastActivity(account BankAccount) (time.Time, bool) {
+	history := account.History()
+	for i := len(history) - 1; i >= 0; i-- {
+		if t := history[i].Type; t == TransactionDeposit || t == TransactionWithdrawal {
+			return history[i].Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Dormant returns when an account was marked dormant, and false when it is
+// not.
+func (b *Bank) Dormant(number string) (time.Time, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	since, ok := b.dormant[number]
+	return since, ok
+}
+
+// ReviewDormancy marks dormant the open deposit accounts whose customers
+// have not moved money for the bank's DormancyMonths, and returns their
+// numbers. Dormant accounts take deposits but no withdrawals or transfers
+// out until they are reactivated. Accounts never used are left alone, as
+// are loans and cards. It does nothing when DormancyMonths is 0.
+func (b *Bank) ReviewDormancy() []string {
+	if b.DormancyMonths <= 0 {
+		return nil
+	}
+	now := b.now()
+	b.mu.Lock()
+	var marked []string
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		if _, credit := account.(creditLine); credit || !b.closed[number].IsZero() {
+			continue
+		}
+		if _, ok := b.dormant[number]; ok {
+			continue
+		}
+		last, ok := LastActivity(account)
+		if !ok || now.Before(last.AddDate(0, b.DormancyMonths, 0)) {
+			continue
+		}
+		b.dormant[number] = now
+		marked = append(marked, number)
+	}
+	b.mu.Unlock()
+
+	slices.Sort(marked)
+	for _, number := range marked {
+		b.Events.Publish(Event{Type: EventAccountDormant, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Account %s is dormant after %d months without activity", number, b.DormancyMonths)})
+	}
+	return marked
+}
+
+// Reactivate lifts the dormancy of an account, once its customer has been
+// in touch, so that money can leave it again.
+func (b *Bank) Reactivate(number string) error {
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	now := b.now()
+	b.mu.Lock()
+	if _, ok := b.dormant[number]; !ok {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotDormant, number)
+	}
+	delete(b.dormant, number)
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventAccountReactivated, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("Account %s reactivated", number)})
+	return nil
+}
+
+// Escheatment returns the balances due to be handed over as unclaimed
+// property at at: those of open deposit accounts whose customers have not
+// moved money for the bank's EscheatMonths, by account number, whether
+// they were marked dormant or not. It returns none when EscheatMonths is 0.
+func (b *Bank) Escheatment(at time.Time) []Escheatment {
+	months := b.EscheatMonths
+	if months <= 0 {
+		return nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var due []Escheatment
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		if _, credit := account.(creditLine); credit || !b.closed[number].IsZero() {
+			continue
+		}
+		last, ok := LastActivity(account)
+		balance := account.CheckBalance()
+		if !ok || balance <= 0 || at.Before(last.AddDate(0, months, 0)) {
+			continue
+		}
+		e := Escheatment{Number: number, Balance: balance, Currency: b.AccountCurrency(account), LastActivity: last}
+		if since, ok := b.dormant[number]; ok {
+			e.DormantSince = &since
+		}
+		for _, c := range b.ownersLocked(number) {
+			e.Customers = append(e.Customers, c.ID)
+		}
+		slices.Sort(e.Customers)
+		due = append(due, e)
+	}
+	slices.SortFunc(due, func(a, b Escheatment) int { return strings.Compare(a.Number, b.Number) })
+	return due
+}
+
+// checkDormant refuses money leaving a dormant account.
+func (b *Bank) checkDormant(number string, kind TransactionType) error {
+	if _, ok := b.dormant[number]; ok && kind == TransactionWithdrawal {
+		return fmt.Errorf("%w: %s must be reactivated first", ErrAccountDormant, number)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// TestReviewDormancy leaves accounts idle and checks which ones are marked
+// dormant, that dormant accounts take deposits but let no money out until
+// they are reactivated, and which balances are due for escheatment.
+func TestReviewDormancy(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-05")}
+	b := NewBank()
+	b.Clock = clock
+	for _, number := range []string{"C1", "C2", "C3"} {
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: number}})
+	}
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"S1"}})
+	b.Deposit("C1", 100)
+	b.Deposit("C2", 50)
+	b.Deposit("S1", 200)
+	clock.now = date(t, "2026-05-05")
+	b.Deposit("C2", 10)
+
+	clock.now = date(t, "2026-07-06")
+	if marked := b.ReviewDormancy(); marked != nil {
+		t.Errorf("marked %v with dormancy off", marked)
+	}
+	b.DormancyMonths = 6
+	for _, want := range [][]string{{"C1", "S1"}, nil} {
+		if marked := b.ReviewDormancy(); !slices.Equal(marked, want) {
+			t.Errorf("marked %v dormant, want %v", marked, want)
+		}
+	}
+	if since, ok := b.Dormant("S1"); !ok || !since.Equal(clock.now) {
+		t.Errorf("S1 dormant since %s, %t, want %s", since, ok, clock.now)
+	}
+
+	if err := b.Withdraw("C1", 10); !errors.Is(err, ErrAccountDormant) {
+		t.Errorf("withdrawing from a dormant account: %v, want ErrAccountDormant", err)
+	}
+	if err := b.Transfer("S1", "C2", 10); !errors.Is(err, ErrAccountDormant) {
+		t.Errorf("transferring from a dormant account: %v, want ErrAccountDormant", err)
+	}
+	if err := b.Deposit("C1", 5); err != nil {
+		t.Errorf("depositing into a dormant account: %v", err)
+	}
+	if err := b.Reactivate("C1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 10); err != nil {
+		t.Errorf("withdrawing from the reactivated account: %v", err)
+	}
+	for number, want := range map[string]error{"C1": ErrNotDormant, "C3": ErrNotDormant, "C9": ErrAccountNotFound} {
+		if err := b.Reactivate(number); !errors.Is(err, want) {
+			t.Errorf("reactivating %s: %v, want %v", number, err, want)
+		}
+	}
+
+	at := date(t, "2027-01-06")
+	if due := b.Escheatment(at); due != nil {
+		t.Errorf("%d balances due with escheatment off", len(due))
+	}
+	b.EscheatMonths = 12
+	due := b.Escheatment(at)
+	if len(due) != 1 {
+		t.Fatalf("%d balances due, want S1 only", len(due))
+	}
+	if e := due[0]; e.Number != "S1" || e.Balance != 200 || !slices.Equal(e.Customers, []string{"c1"}) ||
+		!e.LastActivity.Equal(date(t, "2026-01-05")) || e.DormantSince == nil {
+		t.Errorf("escheatment %+v, want S1's 200.00 of c1, dormant, idle since 2026-01-05", e)
+	}
+}
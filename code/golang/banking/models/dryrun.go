@@ -0,0 +1,86 @@
+package models
+
+// DryRunResult is what an op would do: the entries it would post to each
+// account it names, the fees among them, the balances it would leave those
+// accounts with and the events it would publish.
+type DryRunResult struct {
+	Entries  map[string][]Transaction
+	Fees     float64
+	Balances map[string]float64
+	Events   []Event
+}
+
+// DryRun carries out op on a copy of the bank, through the same middleware
+// and checks of limits, controls, funds and budgets, and returns what it
+// would do and the error it would fail with. The bank itself is left as it
+// was and nothing is published on its Events. Middleware sees op with
+// DryRun set, so it can leave out effects of its own, such as counting the
+// op against a velocity limit. The copy makes IDs of its own: the entries
+// do not have the IDs the op will give them.
+func (b *Bank) DryRun(op Op) (DryRunResult, error) {
+	result := DryRunResult{Entries: make(map[string][]Transaction), Balances: make(map[string]float64)}
+	numbers := []string{op.Account}
+	if op.Kind == OpTransfer {
+		numbers = append(numbers, op.To)
+	}
+	c, err := b.rehearsal(numbers)
+	if err != nil {
+		return result, err
+	}
+	before := make(map[string]int)
+	for _, number := range numbers {
+		if account, err := c.Account(number); err == nil {
+			before[number] = len(account.History())
+		}
+	}
+	c.Events.Subscribe(func(e Event) { result.Events = append(result.Events, e) })
+	op.DryRun = true
+	err = c.run(op)
+	for _, number := range numbers {
+		account, aerr := c.Account(number)
+		if aerr != nil {
+			continue
+		}
+		for _, tx := range account.History()[before[number]:] {
+			result.Entries[number] = append(result.Entries[number], tx)
+			if tx.Type == TransactionFee {
+				result.Fees += tx.Amount
+			}
+		}
+		result.Balances[number] = account.CheckBalance()
+	}
+	return result, err
+}
+
+// rehearsal returns a copy of the bank for dry runs of ops on the accounts
+// numbers: the state of those accounts as capture takes it, and the bank's
+// settings, middleware and open quotes as they are, with an event bus and
+// IDs of its own and no event log or archive.
+func (b *Bank) rehearsal(numbers []string) (*Bank, error) {
+	only := make(map[string]bool, len(numbers))
+	for _, number := range numbers {
+		only[number] = true
+	}
+	snap, err := b.capture(only)
+	if err != nil {
+		return nil, err
+	}
+	c := NewBank()
+	c.Currency, c.Rates, c.FX, c.FXHistory, c.FXPricing, c.QuoteTTL = b.Currency, b.Rates, b.FX, b.FXHistory, b.FXPricing, b.QuoteTTL
+	c.Rounding, c.Calendar, c.Clock, c.Location, c.Products, c.Flags = b.Rounding, b.Calendar, b.Clock, b.Location, b.Products, b.Flags
+	c.DeleteGrace, c.ApplicationTimeout, c.DormancyMonths, c.EscheatMonths = b.DeleteGrace, b.ApplicationTimeout, b.DormancyMonths, b.EscheatMonths
+	c.Reserves, c.DelinquencyPolicy, c.Allocation = b.Reserves, b.DelinquencyPolicy, b.Allocation
+	c.SecretKey = b.SecretKey
+	c.IDs = &SequentialIDs{Prefix: "dry-run-"}
+	if err := c.restore(snap); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	middleware := append([]Middleware(nil), b.middleware...)
+	for id, q := range b.quotes {
+		c.quotes[id] = q
+	}
+	b.mu.RUnlock()
+	c.Use(middleware...)
+	return c, nil
+}
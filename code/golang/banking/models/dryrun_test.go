@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestDryRun checks a dry run reports the entries and balances of a
+// transfer and the error of one that would fail, through the middleware,
+// and leaves the bank and its events as they were.
+func TestDryRun(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	var seen []bool
+	errLarge := errors.New("too large")
+	b.Use(Check(func(op Op) error {
+		seen = append(seen, op.DryRun)
+		if op.Amount > 1000 {
+			return errLarge
+		}
+		return nil
+	}))
+	published := 0
+	b.Events.Subscribe(func(Event) { published++ })
+
+	result, err := b.DryRun(Op{Kind: OpTransfer, Account: "C1", To: "C2", Amount: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries["C1"]) != 1 || len(result.Entries["C2"]) != 1 || result.Balances["C1"] != 60 || result.Balances["C2"] != 40 {
+		t.Errorf("dry run %+v, want one entry each and balances 60 and 40", result)
+	}
+	if len(result.Events) == 0 {
+		t.Error("dry run reports no events")
+	}
+	if _, err := b.DryRun(Op{Kind: OpWithdrawal, Account: "C1", Amount: 500}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdrawing dry run: %v, want %v", err, ErrInsufficientFunds)
+	}
+	if _, err := b.DryRun(Op{Kind: OpDeposit, Account: "C1", Amount: 5000}); !errors.Is(err, errLarge) {
+		t.Errorf("dry run the middleware rejects: %v, want %v", err, errLarge)
+	}
+	if len(seen) != 3 || !seen[0] {
+		t.Errorf("middleware saw dry runs %v, want 3 marked DryRun", seen)
+	}
+
+	results := b.TransferBatch([]TransferRequest{{From: "C1", To: "C2", Amount: 70}, {From: "C1", To: "C2", Amount: 70}}, DryRun())
+	if !results[0].Succeeded() || results[0].Withdrawal.Amount != 70 || !errors.Is(results[1].Err, ErrInsufficientFunds) {
+		t.Errorf("batch dry run %+v, want the first to post and the second short of funds", results)
+	}
+
+	c1, _ := b.Account("C1")
+	if c1.CheckBalance() != 100 || len(c1.History()) != 1 || published != 0 {
+		t.Errorf("after dry runs C1 has %.2f in %d entries and %d events were published, want 100, 1 and 0",
+			c1.CheckBalance(), len(c1.History()), published)
+	}
+}
+
+// TestDryRunScope checks that a dry run copies only the accounts it names
+// and those their pending transfers go to, and still counts what the
+// pending transfers hold.
+func TestDryRunScope(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	for _, number := range []string{"C1", "C2", "C3", "C4"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BookTransfer("C1", "C3", 80, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := b.rehearsal([]string{"C1", "C2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []string
+	for _, a := range c.Accounts() {
+		numbers = append(numbers, a.Number())
+	}
+	sort.Strings(numbers)
+	if strings.Join(numbers, ",") != "C1,C2,C3" {
+		t.Errorf("rehearsal has the accounts %v, want C1, C2 and C3", numbers)
+	}
+	if _, err := b.DryRun(Op{Kind: OpTransfer, Account: "C1", To: "C2", Amount: 50}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("dry run of more than is not held: %v, want %v", err, ErrInsufficientFunds)
+	}
+}
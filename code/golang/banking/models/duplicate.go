@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+)
+
+// DefaultDuplicateWindow is how far back DetectDuplicates looks for a
+// payment an op repeats unless the bank's Duplicates say.
+const DefaultDuplicateWindow = time.Hour
+
+var (
+	ErrLikelyDuplicate  = banking.New(banking.CodeConflict, "likely duplicate payment; confirm it to go ahead")
+	ErrDuplicateBlocked = banking.New(banking.CodeConflict, "duplicate payment blocked")
+)
+
+// DuplicateMode is what DetectDuplicates does with a payment that looks
+// like one made just before.
+type DuplicateMode string
+
+const (
+	// DuplicatesOff lets duplicates through.
+	DuplicatesOff DuplicateMode = ""
+	// DuplicatesWarn rejects them until they are confirmed, see
+	// ConfirmDuplicate.
+	DuplicatesWarn DuplicateMode = "warn"
+	// DuplicatesBlock rejects them, and only the bank may confirm one.
+	DuplicatesBlock DuplicateMode = "block"
+)
+
+// DuplicateCheck is how the bank treats payments that repeat one out of
+// the same account to the same payee, of the same amount, within Window:
+// DefaultDuplicateWindow when zero.
+type DuplicateCheck struct {
+	Mode   DuplicateMode
+	Window time.Duration
+}
+
+// MetaDuplicateOf is the metadata key of the transaction a confirmed
+// duplicate repeats, and MetaDuplicateConfirmed that of the confirmation
+// ConfirmDuplicate sets, which DetectDuplicates takes off the entry.
+const (
+	MetaDuplicateOf        = "duplicate_of"
+	MetaDuplicateConfirmed = "duplicate_confirmed"
+)
+
+// ConfirmDuplicate makes a payment DetectDuplicates would take for a
+// duplicate go ahead; the override is recorded in the audit log.
+func ConfirmDuplicate() TxOption {
+	return WithMetadata(MetaDuplicateConfirmed, "true")
+}
+
+// DetectDuplicates is middleware that catches likely duplicate payments,
+// as the bank's Duplicates say: transfers, and withdrawals to a
+// counterparty, of the same amount to the same payee out of an account as
+// one posted within the window. Installments of a plan, which repeat each
+// other by design, are let through. Overrides in dry runs are not recorded.
+func (b *Bank) DetectDuplicates() Middleware {
+	return func(next Operation) Operation {
+		return func(op Op) error {
+			if op.Kind == OpDeposit {
+				return next(op)
+			}
+			var tx Transaction
+			for _, opt := range op.Options {
+				opt(&tx)
+			}
+			check := b.Duplicates
+			payee := tx.Counterparty
+			if op.Kind == OpTransfer {
+				payee = op.To
+			}
+			if check.Mode == DuplicatesOff || payee == "" || tx.Metadata[MetaInstallmentPlan] != "" {
+				return next(op)
+			}
+			original, err := b.duplicateOf(op.Account, payee, op.Amount, check.Window)
+			if err != nil || original == nil {
+				return next(op)
+			}
+			_, confirmed := tx.Metadata[MetaDuplicateConfirmed]
+			holder := tx.Metadata[MetaHolder]
+			seen := original.Time.Format(time.RFC3339)
+			switch {
+			case check.Mode == DuplicatesBlock && (!confirmed || holder != ""):
+				return fmt.Errorf("%w: %.2f to %s repeats transaction %s of %s", ErrDuplicateBlocked, op.Amount, payee, original.ID, seen)
+			case !confirmed:
+				return fmt.Errorf("%w: %.2f to %s repeats transaction %s of %s", ErrLikelyDuplicate, op.Amount, payee, original.ID, seen)
+			}
+			if !op.DryRun {
+				b.Audit.Record(AuditEntry{Time: b.now(), Customer: holder, Action: "duplicate.override", Account: op.Account,
+					To: payee, Amount: op.Amount, Status: 200})
+			}
+			op.Options = append(op.Options[:len(op.Options):len(op.Options)],
+				WithMetadata(MetaDuplicateOf, original.ID), withoutMetadata(MetaDuplicateConfirmed))
+			return next(op)
+		}
+	}
+}
+
+// duplicateOf returns the latest payment out of an account to a payee of
+// amount posted within window, or nil.
+func (b *Bank) duplicateOf(number, payee string, amount float64, window time.Duration) (*Transaction, error) {
+	if window <= 0 {
+		window = DefaultDuplicateWindow
+	}
+	history, err := b.History(number)
+	if err != nil {
+		return nil, err
+	}
+	since := b.now().Add(-window)
+	for i := len(history) - 1; i >= 0 && history[i].Time.After(since); i-- {
+		tx := history[i]
+		if tx.Type == TransactionWithdrawal && strings.EqualFold(tx.Counterparty, payee) && math.Abs(tx.Amount-amount) < 0.005 {
+			return &tx, nil
+		}
+	}
+	return nil, nil
+}
+
+// withoutMetadata takes a key off the metadata of a transaction.
+func withoutMetadata(key string) TxOption {
+	return func(tx *Transaction) { delete(tx.Metadata, key) }
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestDetectDuplicates checks that a repeated transfer is rejected until
+// confirmed in warn mode, that the override is audited and noted on the
+// entry, that one outside the window goes through, and that in block mode
+// only the bank may confirm.
+func TestDetectDuplicates(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.IDs = &SequentialIDs{Prefix: "id-"}
+	b.Duplicates = DuplicateCheck{Mode: DuplicatesWarn, Window: 10 * time.Minute}
+	b.Use(b.AuthorizeHolders(), b.DetectDuplicates())
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	if err := b.Deposit("C1", 500); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Transfer("C1", "S1", 50, ByHolder("c1")); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(5 * time.Minute)
+	if err := b.Transfer("C1", "S1", 50, ByHolder("c1")); !errors.Is(err, ErrLikelyDuplicate) {
+		t.Fatalf("repeated transfer: %v, want ErrLikelyDuplicate", err)
+	}
+	if err := b.Transfer("C1", "S1", 60, ByHolder("c1")); err != nil {
+		t.Errorf("transfer of another amount: %v", err)
+	}
+	if err := b.Withdraw("C1", 20, WithCounterparty("Grocer")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 20, WithCounterparty("grocer")); !errors.Is(err, ErrLikelyDuplicate) {
+		t.Errorf("repeated payment: %v, want ErrLikelyDuplicate", err)
+	}
+	if err := b.Withdraw("C1", 20); err != nil {
+		t.Errorf("withdrawal without a payee: %v", err)
+	}
+
+	if err := b.Transfer("C1", "S1", 50, ByHolder("c1"), ConfirmDuplicate()); err != nil {
+		t.Fatalf("confirmed duplicate: %v", err)
+	}
+	history, _ := b.History("C1")
+	var confirmed Transaction
+	for _, tx := range history {
+		if tx.Metadata[MetaDuplicateOf] != "" {
+			confirmed = tx
+		}
+	}
+	if confirmed.Amount != 50 || confirmed.Metadata[MetaDuplicateOf] != history[1].ID || confirmed.Metadata[MetaDuplicateConfirmed] != "" {
+		t.Errorf("confirmed duplicate %+v", confirmed)
+	}
+	overrides := b.Audit.Entries(AuditQuery{Action: "duplicate.override"})
+	if len(overrides) != 1 || overrides[0].Customer != "c1" || overrides[0].Account != "C1" || overrides[0].To != "S1" || overrides[0].Amount != 50 {
+		t.Errorf("overrides %+v", overrides)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	if err := b.Transfer("C1", "S1", 50, ByHolder("c1")); err != nil {
+		t.Errorf("transfer outside the window: %v", err)
+	}
+
+	b.Duplicates.Mode = DuplicatesBlock
+	if err := b.Transfer("C1", "S1", 50, ByHolder("c1"), ConfirmDuplicate()); !errors.Is(err, ErrDuplicateBlocked) {
+		t.Errorf("duplicate confirmed by a holder in block mode: %v, want ErrDuplicateBlocked", err)
+	}
+	if err := b.Transfer("C1", "S1", 50, ConfirmDuplicate()); err != nil {
+		t.Errorf("duplicate confirmed by the bank in block mode: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+)
+
+var ErrInvalidMerchantRule = banking.New(banking.CodeInvalidArgument, "invalid merchant rule")
+
+// Merchant is a business transactions are made with, as enrichment names
+// it: a clean Name for the cryptic counterparties card networks send, its
+// Logo and Website as URLs, the Category its transactions usually fall in
+// and where it is.
+type Merchant struct {
+	Name     string    `json:"name"`
+	Logo     string    `json:"logo,omitempty"`
+	Website  string    `json:"website,omitempty"`
+	Category string    `json:"category,omitempty"`
+	Location *Location `json:"location,omitempty"`
+}
+
+// Location is where a merchant is.
+type Location struct {
+	City      string  `json:"city,omitempty"`
+	Region    string  `json:"region,omitempty"`
+	Country   string  `json:"country,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+}
+
+// Enrichment is what enrichment made of a transaction's raw counterparty
+// or description, which the ledger keeps as they were. Normalized is the
+// raw text cleaned for matching, see NormalizeMerchant; Merchant, if any,
+// and Category are what the bank's Enrichers made of it, whose names are
+// in Enrichers.
+type Enrichment struct {
+	Transaction string    `json:"transaction"`
+	Account     string    `json:"account"`
+	Raw         string    `json:"raw"`
+	Normalized  string    `json:"normalized"`
+	Merchant    *Merchant `json:"merchant,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Enrichers   []string  `json:"enrichers,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Enricher is a stage of transaction enrichment, such as merchant rules or
+// a lookup in a merchant data provider. The bank's Enrichers run in order
+// on every transaction posted that names a merchant; each adds to what
+// those before it found.
+type Enricher interface {
+	Name() string
+	// Enrich adds what the enricher knows of tx to e, reporting whether it
+	// added anything.
+	Enrich(tx Transaction, e *Enrichment) bool
+}
+
+// MerchantRule names the merchant of transactions whose normalized
+// counterparty or description contains Match, case aside.
+type MerchantRule struct {
+	Match    string   `json:"match"`
+	Merchant Merchant `json:"merchant"`
+}
+
+var (
+	// processorPrefix matches the prefixes payment processors and card
+	// networks put before merchant names.
+	processorPrefix = regexp.MustCompile(`^(?:(?:SQ|TST|PAYPAL|PP|SP|IZ|ZTL|GOOGLE|APL)\s*\*\s*|(?:POS|DEBIT CARD PURCHASE|CARD PURCHASE|PURCHASE)\s+)+`)
+	// storeNumber matches store numbers, card and reference numbers.
+	storeNumber = regexp.MustCompile(`#\s*\d+|\b\d{3,}\b`)
+	noise       = regexp.MustCompile(`[^A-Z0-9&' ]+`)
+)
+
+// NormalizeMerchant cleans the raw counterparty or description of a card
+// transaction for matching: upper case, without processor prefixes such as
+// "SQ *", store and reference numbers or punctuation.
+func NormalizeMerchant(raw string) string {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	s = processorPrefix.ReplaceAllString(s, "")
+	s = storeNumber.ReplaceAllString(s, " ")
+	s = noise.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// rawMerchant is the raw text a transaction names its merchant by: its
+// counterparty, or its description without one.
+func rawMerchant(tx Transaction) string {
+	if tx.Counterparty != "" {
+		return tx.Counterparty
+	}
+	return tx.Description
+}
+
+// merchantRules is the Enricher of the bank's merchant rules, see
+// SetMerchantRules.
+type merchantRules struct{ b *Bank }
+
+func (merchantRules) Name() string { return "rules" }
+
+func (m merchantRules) Enrich(tx Transaction, e *Enrichment) bool {
+	m.b.mu.RLock()
+	defer m.b.mu.RUnlock()
+	for _, rule := range m.b.merchantRules {
+		if strings.Contains(e.Normalized, NormalizeMerchant(rule.Match)) {
+			merchant := rule.Merchant
+			e.Merchant = &merchant
+			if e.Category == "" {
+				e.Category = merchant.Category
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// SetMerchantRules replaces the rules of the bank's merchant rules
+// enricher, the first that matches naming the merchant. Transactions
+// enriched before keep what they got until ReEnrich.
+func (b *Bank) SetMerchantRules(rules []MerchantRule) error {
+	for i, rule := range rules {
+		if NormalizeMerchant(rule.Match) == "" || rule.Merchant.Name == "" {
+			return fmt.Errorf("%w: rule %d needs a match and a merchant name", ErrInvalidMerchantRule, i)
+		}
+	}
+	b.mu.Lock()
+	b.merchantRules = slices.Clone(rules)
+	b.mu.Unlock()
+	return nil
+}
+
+// MerchantRules returns the rules of the bank's merchant rules enricher.
+func (b *Bank) MerchantRules() []MerchantRule {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]MerchantRule{}, b.merchantRules...)
+}
+
+// enrich runs the bank's Enrichers on a transaction of an account, if it
+// names a merchant at all: transfers between accounts, whose counterparty
+// is an account number, do not.
+func (b *Bank) enrich(number string, tx Transaction) (Enrichment, bool) {
+	raw := rawMerchant(tx)
+	e := Enrichment{Transaction: tx.ID, Account: number, Raw: raw, Normalized: NormalizeMerchant(raw), Category: tx.Category, Time: b.now()}
+	if tx.ID == "" || e.Normalized == "" || (tx.Counterparty != "" && b.accounts.account(tx.Counterparty) != nil) {
+		return Enrichment{}, false
+	}
+	for _, f := range b.Enrichers {
+		if f.Enrich(tx, &e) {
+			e.Enrichers = append(e.Enrichers, f.Name())
+		}
+	}
+	return e, true
+}
+
+// enrichEvent enriches the transactions posted.
+func (b *Bank) enrichEvent(e Event) {
+	if e.Type != EventTransactionPosted || e.Transaction == nil {
+		return
+	}
+	enriched, ok := b.enrich(e.AccountNumber, *e.Transaction)
+	if !ok {
+		return
+	}
+	b.mu.Lock()
+	b.enrichments[enriched.Transaction] = &enriched
+	b.mu.Unlock()
+}
+
+// Enrichment returns the enrichment of a transaction, by ID, and whether it
+// has one.
+func (b *Bank) Enrichment(id string) (Enrichment, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	e, ok := b.enrichments[id]
+	if !ok {
+		return Enrichment{}, false
+	}
+	return e.clone(), true
+}
+
+func (e *Enrichment) clone() Enrichment {
+	c := *e
+	c.Enrichers = slices.Clone(e.Enrichers)
+	if e.Merchant != nil {
+		m := *e.Merchant
+		c.Merchant = &m
+	}
+	return c
+}
+
+// ReEnrich runs the bank's Enrichers again on every transaction, such as after the merchant rules improved,
+// and returns how many were enriched differently.
+func (b *Bank) ReEnrich() int {
+	changed := 0
+	for _, account := range b.Accounts() {
+		history, err := b.History(account.Number())
+		if err != nil {
+			continue
+		}
+		for _, tx := range history {
+			enriched, ok := b.enrich(account.Number(), tx)
+			if !ok {
+				continue
+			}
+			b.mu.Lock()
+			if old, ok := b.enrichments[tx.ID]; !ok || !sameEnrichment(*old, enriched) {
+				b.enrichments[tx.ID] = &enriched
+				changed++
+			}
+			b.mu.Unlock()
+		}
+	}
+	return changed
+}
+
+// sameEnrichment reports whether a and b found the same, whenever they
+// were made.
+func sameEnrichment(a, b Enrichment) bool {
+	a.Time, b.Time = time.Time{}, time.Time{}
+	return reflect.DeepEqual(a, b)
+}
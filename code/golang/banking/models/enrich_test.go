@@ -0,0 +1,95 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestNormalizeMerchant(t *testing.T) {
+	for raw, want := range map[string]string{
+		"SQ *BLUE BOTTLE #123":           "BLUE BOTTLE",
+		"PAYPAL *NETFLIX.COM 4029357733": "NETFLIX COM",
+		"POS AMAZON MKTPL*2K4RT5":        "AMAZON MKTPL 2K4RT5",
+		"Spotify USA":                    "SPOTIFY USA",
+		"12345":                          "",
+	} {
+		if got := NormalizeMerchant(raw); got != want {
+			t.Errorf("NormalizeMerchant(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+// TestEnrichment checks that transactions posted are enriched by the
+// merchant rules, transfers between accounts left alone, and that
+// re-enrichment picks up improved rules and survives a snapshot.
+func TestEnrichment(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.IDs = &SequentialIDs{Prefix: "id-"}
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	if err := b.SetMerchantRules([]MerchantRule{{Match: "blue bottle", Merchant: Merchant{Name: ""}}}); err == nil {
+		t.Error("rule without a merchant name accepted")
+	}
+	if err := b.SetMerchantRules([]MerchantRule{{Match: "blue bottle", Merchant: Merchant{Name: "Blue Bottle Coffee", Category: "coffee",
+		Location: &Location{City: "Oakland", Country: "US"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deposit("C1", 100, WithCounterparty("payroll")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 5, WithCounterparty("SQ *BLUE BOTTLE #123")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 12, WithCounterparty("PAYPAL *NETFLIX.COM")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer("C1", "S1", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	coffee, ok := b.Enrichment("id-2")
+	if !ok || coffee.Raw != "SQ *BLUE BOTTLE #123" || coffee.Normalized != "BLUE BOTTLE" || coffee.Merchant == nil ||
+		coffee.Merchant.Name != "Blue Bottle Coffee" || coffee.Category != "coffee" || len(coffee.Enrichers) != 1 {
+		t.Errorf("coffee enrichment %+v, %v", coffee, ok)
+	}
+	if netflix, ok := b.Enrichment("id-3"); !ok || netflix.Merchant != nil || netflix.Normalized != "NETFLIX COM" {
+		t.Errorf("netflix enrichment %+v, %v", netflix, ok)
+	}
+	history, _ := b.History("C1")
+	if _, ok := b.Enrichment(history[len(history)-1].ID); ok {
+		t.Error("transfer between accounts enriched")
+	}
+
+	if err := b.SetMerchantRules(append(b.MerchantRules(), MerchantRule{Match: "netflix", Merchant: Merchant{Name: "Netflix", Category: "streaming"}})); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := b.Enrichment("id-3"); e.Merchant != nil {
+		t.Error("new rules changed an enrichment before re-enrichment")
+	}
+	if n := b.ReEnrich(); n != 1 {
+		t.Errorf("re-enrichment changed %d, want 1", n)
+	}
+	if n := b.ReEnrich(); n != 0 {
+		t.Errorf("second re-enrichment changed %d, want 0", n)
+	}
+
+	restored := NewBank()
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := restored.Enrichment("id-3"); !ok || e.Merchant == nil || e.Merchant.Name != "Netflix" || e.Category != "streaming" {
+		t.Errorf("restored enrichment %+v, %v", e, ok)
+	}
+	if len(restored.MerchantRules()) != 2 {
+		t.Errorf("restored rules %+v", restored.MerchantRules())
+	}
+	if n := restored.ReEnrich(); n != 0 {
+		t.Errorf("re-enrichment after restore changed %d, want 0", n)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"gsolano/banking"
+	"gsolano/banking/validate"
+)
+
+var (
+	ErrInvalidAmount     = validate.ErrInvalidAmount
+	ErrInsufficientFunds = banking.New(banking.CodeInsufficientFunds, "insufficient funds")
+	ErrAccountNotFound   = banking.New(banking.CodeNotFound, "account not found")
+	ErrAccountExists     = banking.New(banking.CodeConflict, "account already exists")
+	ErrBudgetExhausted   = banking.New(banking.CodeLimitExceeded, "budget exhausted")
+	ErrFeatureDisabled   = banking.New(banking.CodeFeatureDisabled, "feature disabled")
+)
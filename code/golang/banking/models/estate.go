@@ -0,0 +1,298 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gsolano/banking"
+)
+
+// MetaEstate is the metadata key of the deceased customer whose estate an
+// entry settled.
+const MetaEstate = "estate"
+
+var (
+	ErrEstateNotFound  = banking.New(banking.CodeNotFound, "no estate for customer")
+	ErrEstateExists    = banking.New(banking.CodeConflict, "customer is already deceased")
+	ErrEstateSettled   = banking.New(banking.CodeConflict, "estate already settled")
+	ErrEstateInsolvent = banking.New(banking.CodeInsufficientFunds, "estate cannot pay its debts")
+	ErrAccountEstate   = banking.New(banking.CodeAccountFrozen, "account is frozen for an estate")
+)
+
+// DefaultExecutorAccess is how long an executor may view the accounts of an
+// estate unless they are appointed until a date.
+const DefaultExecutorAccess = 365 * 24 * time.Hour
+
+// Estate is the estate of a deceased customer. Reporting the death freezes
+// Accounts, those the customer held alone: their interest keeps accruing,
+// but nothing else is posted to them until the estate is settled, which
+// pays their balances out to PayTo and closes them. Joint accounts pass to
+// their other holders and are left alone. Executor is who deals with the
+// estate, under grant Grant.
+type Estate struct {
+	Customer    string     `json:"customer"`
+	DateOfDeath time.Time  `json:"date_of_death"`
+	Reported    time.Time  `json:"reported"`
+	Accounts    []string   `json:"accounts"`
+	Executor    string     `json:"executor,omitempty"`
+	Grant       int        `json:"grant,omitempty"`
+	Settled     *time.Time `json:"settled,omitempty"`
+	PayTo       string     `json:"pay_to,omitempty"`
+}
+
+// Settlement is the outcome of settling an estate: the estate as settled
+// and the statement package of its accounts, every statement of each from
+// its first entry to its closing.
+type Settlement struct {
+	Estate     Estate
+	Statements []Statement
+}
+
+// ReportDeath records that a customer died and freezes the accounts they
+// held alone.
+func (b *Bank) ReportDeath(customerID string, died time.Time) (Estate, error) {
+	now := b.now()
+	if died.IsZero() || died.After(now) {
+		return Estate{}, banking.New(banking.CodeInvalidArgument, "date of death must be set and not in the future")
+	}
+	b.mu.Lock()
+	c, ok := b.customers[customerID]
+	switch {
+	case !ok:
+		b.mu.Unlock()
+		return Estate{}, fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+	case b.estates[customerID] != nil:
+		b.mu.Unlock()
+		return Estate{}, fmt.Errorf("%w: %s", ErrEstateExists, customerID)
+	}
+	e := &Estate{Customer: customerID, DateOfDeath: died, Reported: now, Accounts: []string{}}
+	for _, number := range c.Accounts {
+		if b.accounts.account(number) != nil && b.closed[number].IsZero() && len(b.ownersLocked(number)) == 1 {
+			e.Accounts = append(e.Accounts, number)
+			b.frozen[number] = customerID
+		}
+	}
+	slices.Sort(e.Accounts)
+	b.estates[customerID] = e
+	reported := e.clone()
+	b.mu.Unlock()
+	for _, number := range reported.Accounts {
+		b.Events.Publish(Event{Type: EventEstateOpened, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Account %s frozen: %s died on %s", number, customerID, died.Format(time.DateOnly))})
+	}
+	return reported, nil
+}
+
+// Estate returns the estate of a deceased customer.
+func (b *Bank) Estate(customerID string) (Estate, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	e, ok := b.estates[customerID]
+	if !ok {
+		return Estate{}, fmt.Errorf("%w: %s", ErrEstateNotFound, customerID)
+	}
+	return e.clone(), nil
+}
+
+// AppointExecutor names the executor of an estate and grants them view
+// access to its accounts until until, or for DefaultExecutorAccess when it
+// is zero, in place of the executor appointed before. Executors see the
+// accounts and may settle the estate, but cannot otherwise move money.
+func (b *Bank) AppointExecutor(customerID, executor string, until time.Time) (Estate, error) {
+	e, err := b.Estate(customerID)
+	if err != nil {
+		return Estate{}, err
+	}
+	if e.Settled != nil {
+		return Estate{}, fmt.Errorf("%w: %s", ErrEstateSettled, customerID)
+	}
+	if until.IsZero() {
+		until = b.now().Add(DefaultExecutorAccess)
+	}
+	var g Grant
+	if len(e.Accounts) > 0 {
+		g, err = b.Delegate("", Grant{Grantor: customerID, Delegate: executor, Accounts: e.Accounts,
+			Permission: PermissionView, Expires: until, Reason: "executor of the estate of " + customerID})
+		if err != nil {
+			return Estate{}, err
+		}
+	}
+	if e.Grant != 0 {
+		b.RevokeGrant(e.Grant, "")
+	}
+	b.mu.Lock()
+	current := b.estates[customerID]
+	current.Executor, current.Grant = executor, g.ID
+	e = current.clone()
+	b.mu.Unlock()
+	return e, nil
+}
+
+// SettleEstate pays the balances of an estate's accounts out to account
+// payTo, first paying off what its loans, cards and overdrafts owe, and
+// closes them. by is who asks: the estate's executor or "" for the bank.
+// Either every account is settled or, on an error, none is.
+func (b *Bank) SettleEstate(customerID, payTo, by string) (Settlement, error) {
+	e, err := b.Estate(customerID)
+	if err != nil {
+		return Settlement{}, err
+	}
+	switch {
+	case e.Settled != nil:
+		return Settlement{}, fmt.Errorf("%w: %s", ErrEstateSettled, customerID)
+	case by != "" && by != e.Executor:
+		return Settlement{}, fmt.Errorf("%w: %s is not the executor of %s", ErrPermissionDenied, by, customerID)
+	case slices.Contains(e.Accounts, payTo):
+		return Settlement{}, banking.New(banking.CodeInvalidArgument, "an estate cannot be paid into its own accounts")
+	}
+	to, err := b.Account(payTo)
+	if err != nil {
+		return Settlement{}, err
+	}
+	accounts := make(map[string]BankAccount, len(e.Accounts))
+	for _, number := range e.Accounts {
+		account, err := b.Account(number)
+		if err != nil {
+			return Settlement{}, err
+		}
+		if b.AccountCurrency(account) != b.AccountCurrency(to) {
+			return Settlement{}, fmt.Errorf("%w: %s is in %s", ErrCurrencyMismatch, number, b.AccountCurrency(account))
+		}
+		accounts[number] = account
+	}
+
+	now := b.now()
+	b.mu.Lock()
+	if err := b.checkOpen(payTo); err != nil {
+		b.mu.Unlock()
+		return Settlement{}, err
+	}
+	// Balances are paid out first, so that the debts are paid from them
+	// and not from whatever payTo already held.
+	var requests, debts []TransferRequest
+	var assets, owed float64
+	for _, number := range e.Accounts {
+		for _, pt := range b.pending {
+			if pt.From == number || pt.To == number {
+				b.mu.Unlock()
+				return Settlement{}, fmt.Errorf("%w: %s", ErrPendingTransfers, number)
+			}
+		}
+		switch balance := accounts[number].CheckBalance(); {
+		case balance > 0:
+			assets += balance
+			requests = append(requests, TransferRequest{From: number, To: payTo, Amount: balance})
+		case balance < 0:
+			owed -= balance
+			debts = append(debts, TransferRequest{From: payTo, To: number, Amount: -balance})
+		}
+	}
+	if owed > assets {
+		b.mu.Unlock()
+		return Settlement{}, fmt.Errorf("%w: it owes %.2f and holds %.2f", ErrEstateInsolvent, owed, assets)
+	}
+	requests = append(requests, debts...)
+	undo := b.snapshot(requests)
+	b.settling = customerID
+	var posted []postedEntry
+	for _, req := range requests {
+		entries, err := b.transferLocked(b.accounts.account(req.From), b.accounts.account(req.To), req.Amount, nil,
+			[]TxOption{WithDescription("Estate settlement"), WithMetadata(MetaEstate, customerID)})
+		if err != nil {
+			undo.restore()
+			b.settling = ""
+			b.mu.Unlock()
+			return Settlement{}, err
+		}
+		posted = append(posted, entries...)
+	}
+	b.settling = ""
+	for _, number := range e.Accounts {
+		b.closed[number] = now
+		delete(b.dormant, number)
+	}
+	current := b.estates[customerID]
+	current.Settled, current.PayTo = &now, payTo
+	settled := Settlement{Estate: current.clone()}
+	for _, number := range e.Accounts {
+		settled.Statements = append(settled.Statements, b.statementsLocked(accounts[number], now)...)
+	}
+	b.mu.Unlock()
+
+	b.publishPosted(posted)
+	for _, number := range e.Accounts {
+		b.Events.Publish(Event{Type: EventAccountClosed, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Account %s closed", number)})
+		b.Events.Publish(Event{Type: EventEstateSettled, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Estate of %s settled into %s", customerID, payTo)})
+	}
+	return settled, nil
+}
+
+// EstateStatements returns the statement package of a settled estate again.
+func (b *Bank) EstateStatements(customerID string) ([]Statement, error) {
+	e, err := b.Estate(customerID)
+	if err != nil {
+		return nil, err
+	}
+	if e.Settled == nil {
+		return nil, banking.New(banking.CodeConflict, "estate not settled yet")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var statements []Statement
+	for _, number := range e.Accounts {
+		if account := b.accounts.account(number); account != nil {
+			statements = append(statements, b.statementsLocked(account, *e.Settled)...)
+		}
+	}
+	return statements, nil
+}
+
+// statementsLocked returns every statement of an account's cycle from its
+// first entry to until. The caller holds b.mu.
+func (b *Bank) statementsLocked(account BankAccount, until time.Time) []Statement {
+	history := account.History()
+	if len(history) == 0 {
+		return nil
+	}
+	cycle := b.cycleLocked(account)
+	var statements []Statement
+	// Periods end at an instant they include, so the first one is found
+	// from just before the first entry.
+	for at := history[0].Time.Add(-time.Nanosecond); ; {
+		period := cycle.Period(at)
+		s := Statement{Account: account.Number(), Period: period,
+			Opening: balanceAt(account, period.Start), Closing: balanceAt(account, period.End)}
+		for _, tx := range history {
+			if tx.Time.After(period.Start) && !tx.Time.After(period.End) {
+				s.Transactions = append(s.Transactions, tx)
+			}
+		}
+		if liner, ok := account.(StatementLiner); ok {
+			s.Lines = liner.StatementLines(period)
+		}
+		statements = append(statements, s)
+		if !period.End.Before(until) {
+			return statements
+		}
+		at = period.End.Add(time.Nanosecond)
+	}
+}
+
+// checkEstate refuses everything but interest on an account frozen for an
+// estate, other than the entries that settle it.
+func (b *Bank) checkEstate(number string, kind TransactionType) error {
+	deceased, ok := b.frozen[number]
+	if !ok || deceased == b.settling || kind == TransactionInterest || kind == TransactionInterestCharge {
+		return nil
+	}
+	return fmt.Errorf("%w: %s held by %s, who died", ErrAccountEstate, number, deceased)
+}
+
+func (e *Estate) clone() Estate {
+	c := *e
+	c.Accounts = slices.Clone(e.Accounts)
+	return c
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// estateBank returns a bank whose customer c1 holds S1 with 100 in it and
+// C1, overdrawn by 30, alone, and J1 jointly with c2, who also holds P1,
+// the account the estate is paid to.
+func estateBank(t *testing.T) (*Bank, *testClock) {
+	t.Helper()
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-03-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.Use(b.AuthorizeHolders())
+	accounts := []BankAccount{
+		&SavingsAccount{Account: Account{AccountNumber: "S1"}, InterestRate: money.Percent(1)},
+		&CheckingAccount{Account: Account{AccountNumber: "C1"}, OverdraftLimit: 200},
+		&CheckingAccount{Account: Account{AccountNumber: "J1"}},
+		&CheckingAccount{Account: Account{AccountNumber: "P1"}},
+	}
+	for _, account := range accounts {
+		if err := b.Open(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"S1", "C1", "J1"}})
+	b.AddCustomer(&Customer{ID: "c2", Name: "Grace", Accounts: []string{"J1", "P1"}})
+	b.AddCustomer(&Customer{ID: "c3", Name: "Alan"})
+	if err := b.Deposit("S1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("C1", 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deposit("J1", 50); err != nil {
+		t.Fatal(err)
+	}
+	return b, clock
+}
+
+// TestEstateFreezesAccounts checks that reporting a death freezes the
+// accounts the customer held alone against everything but interest, and
+// leaves joint accounts to their other holders.
+func TestEstateFreezesAccounts(t *testing.T) {
+	b, _ := estateBank(t)
+	if _, err := b.ReportDeath("c1", date(t, "2026-04-01")); err == nil {
+		t.Error("reporting a death in the future succeeded")
+	}
+	estate, err := b.ReportDeath("c1", date(t, "2026-02-20"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estate.Accounts) != 2 || estate.Accounts[0] != "C1" || estate.Accounts[1] != "S1" {
+		t.Errorf("estate accounts = %v, want C1 and S1", estate.Accounts)
+	}
+	if _, err := b.ReportDeath("c1", date(t, "2026-02-20")); !errors.Is(err, ErrEstateExists) {
+		t.Errorf("reporting the death again: %v, want %v", err, ErrEstateExists)
+	}
+
+	if err := b.Withdraw("S1", 10); !errors.Is(err, ErrAccountEstate) {
+		t.Errorf("withdrawal from a frozen account: %v, want %v", err, ErrAccountEstate)
+	}
+	if err := b.Transfer("S1", "P1", 10); !errors.Is(err, ErrAccountEstate) {
+		t.Errorf("transfer out of a frozen account: %v, want %v", err, ErrAccountEstate)
+	}
+	if err := b.Deposit("C1", 30); !errors.Is(err, ErrAccountEstate) {
+		t.Errorf("deposit to a frozen account: %v, want %v", err, ErrAccountEstate)
+	}
+	if err := b.ApplyInterest("S1"); err != nil {
+		t.Errorf("interest on a frozen account: %v", err)
+	}
+	if err := b.Withdraw("J1", 10, ByHolder("c2")); err != nil {
+		t.Errorf("withdrawal from a joint account by its other holder: %v", err)
+	}
+	if balance, _ := b.Balance("S1"); balance <= 100 {
+		t.Errorf("S1 balance = %.2f, want 100 and its interest", balance)
+	}
+}
+
+// TestSettleEstate checks that only the executor or the bank may settle an
+// estate, that settling pays the debts from the balances, moves what is
+// left to the account named and closes the accounts, and that an estate
+// owing more than it holds is not settled at all.
+func TestSettleEstate(t *testing.T) {
+	b, clock := estateBank(t)
+	if _, err := b.ReportDeath("c1", date(t, "2026-02-20")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.AppointExecutor("c1", "c3", date(t, "2026-06-01")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Authorize("S1", "c3", PermissionView, "read the balance"); err != nil {
+		t.Errorf("executor viewing the estate: %v", err)
+	}
+	if err := b.Withdraw("S1", 10, ByHolder("c3")); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("executor withdrawing from the estate: %v, want %v", err, ErrPermissionDenied)
+	}
+	if _, err := b.SettleEstate("c1", "P1", "c2"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("settling as someone else: %v, want %v", err, ErrPermissionDenied)
+	}
+	if _, err := b.SettleEstate("c1", "S1", "c3"); err == nil {
+		t.Error("settling into the estate's own account succeeded")
+	}
+
+	clock.now = date(t, "2026-03-10")
+	settlement, err := b.SettleEstate("c1", "P1", "c3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settled := settlement.Estate; settled.Settled == nil || !settled.Settled.Equal(clock.now) || settled.PayTo != "P1" {
+		t.Errorf("settled estate = %+v", settled)
+	}
+	if len(settlement.Statements) == 0 {
+		t.Error("settlement carries no statements")
+	}
+	checkBalances(t, b, map[string]float64{"S1": 0, "C1": 0, "P1": 70})
+	for _, number := range []string{"S1", "C1"} {
+		history, _ := b.History(number)
+		if last := history[len(history)-1]; last.Metadata[MetaEstate] != "c1" {
+			t.Errorf("last entry of %s = %+v, want it to settle the estate", number, last)
+		}
+		if err := b.Deposit(number, 1); !errors.Is(err, ErrAccountClosed) {
+			t.Errorf("deposit to settled %s: %v, want %v", number, err, ErrAccountClosed)
+		}
+	}
+	if _, err := b.SettleEstate("c1", "P1", ""); !errors.Is(err, ErrEstateSettled) {
+		t.Errorf("settling again: %v, want %v", err, ErrEstateSettled)
+	}
+
+	b, _ = estateBank(t)
+	if err := b.Withdraw("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ReportDeath("c1", date(t, "2026-02-20")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.SettleEstate("c1", "P1", ""); !errors.Is(err, ErrEstateInsolvent) {
+		t.Errorf("settling an insolvent estate: %v, want %v", err, ErrEstateInsolvent)
+	}
+	checkBalances(t, b, map[string]float64{"S1": 100, "C1": -130, "P1": 0})
+	if err := b.Withdraw("S1", 10); !errors.Is(err, ErrAccountEstate) {
+		t.Errorf("withdrawal from an unsettled estate: %v, want %v", err, ErrAccountEstate)
+	}
+}
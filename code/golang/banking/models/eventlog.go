@@ -0,0 +1,52 @@
+package models
+
+import "sync"
+
+// eventLogSize is how many events a bank's EventLog keeps.
+const eventLogSize = 10000
+
+// LoggedEvent is an event with its position in the log.
+type LoggedEvent struct {
+	ID int64
+	Event
+}
+
+// EventLog keeps the most recent events published on a bus so they can be
+// listed page by page. Older events are dropped once it is full.
+type EventLog struct {
+	mu sync.Mutex
+	// events is a ring once full: the oldest event is at start.
+	events   []LoggedEvent
+	start    int
+	lastID   int64
+	capacity int
+}
+
+func NewEventLog(capacity int) *EventLog {
+	return &EventLog{capacity: capacity}
+}
+
+// Record appends an event; subscribe it to an EventBus.
+func (l *EventLog) Record(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastID++
+	logged := LoggedEvent{ID: l.lastID, Event: e}
+	if len(l.events) < l.capacity {
+		l.events = append(l.events, logged)
+		return
+	}
+	// Overwrite the oldest event rather than shift the others down, which
+	// would copy the whole log for every event.
+	l.events[l.start] = logged
+	l.start = (l.start + 1) % len(l.events)
+}
+
+// Page returns a page of the logged events, oldest first. A cursor pointing
+// at events that were dropped continues with the oldest one kept.
+func (l *EventLog) Page(req PageRequest) (Page[LoggedEvent], error) {
+	l.mu.Lock()
+	events := append(append([]LoggedEvent(nil), l.events[l.start:]...), l.events[:l.start]...)
+	l.mu.Unlock()
+	return paginate(events, req, func(e LoggedEvent) string { return sequenceKey(int(e.ID)) })
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventTransactionPosted EventType = "transaction.posted"
+	EventBudgetWarning     EventType = "budget.warning"
+	EventBudgetExhausted   EventType = "budget.exhausted"
+	EventRateReset         EventType = "rate.reset"
+	EventTransferBooked    EventType = "transfer.booked"
+	EventAccountClosed     EventType = "account.closed"
+	EventAccountArchived   EventType = "account.archived"
+	EventAccountDeleted    EventType = "account.deleted"
+	EventAccountRestored   EventType = "account.restored"
+	// EventAccountDormant is published when an account is marked dormant
+	// and EventAccountReactivated when its dormancy is lifted.
+	EventAccountDormant     EventType = "account.dormant"
+	EventAccountReactivated EventType = "account.reactivated"
+	// EventEstateOpened is published for each account frozen when a
+	// customer's death is reported, and EventEstateSettled when their
+	// estate is paid out.
+	EventEstateOpened  EventType = "estate.opened"
+	EventEstateSettled EventType = "estate.settled"
+	// EventDelinquencyChanged is published when a loan or card moves to
+	// another DelinquencyBucket.
+	EventDelinquencyChanged EventType = "delinquency.changed"
+	// EventControlsChanged is published when an account's controls are
+	// set, and EventOverrideStarted and EventOverrideEnded when an
+	// override of them starts and ends.
+	EventControlsChanged EventType = "controls.changed"
+	EventOverrideStarted EventType = "override.started"
+	EventOverrideEnded   EventType = "override.ended"
+	// EventHolderInvited is published when a customer is invited to hold
+	// a joint account, EventHolderAdded when they accept, and
+	// EventHolderChanged and EventHolderRemoved when an admin changes or
+	// removes a holder.
+	EventHolderInvited EventType = "holder.invited"
+	EventHolderAdded   EventType = "holder.added"
+	EventHolderChanged EventType = "holder.changed"
+	EventHolderRemoved EventType = "holder.removed"
+	// EventGrantCreated and EventGrantRevoked are published when access to
+	// an account is delegated and revoked, and EventDelegatedAccess for
+	// everything a delegate does with it.
+	EventGrantCreated    EventType = "grant.created"
+	EventGrantRevoked    EventType = "grant.revoked"
+	EventDelegatedAccess EventType = "grant.used"
+	// EventApplicationChanged is published when an application to open an
+	// account moves to another step.
+	EventApplicationChanged EventType = "application.changed"
+	// EventAccountRenumbered is published for the old number of an account
+	// given a new one, which Bank.Redirect returns.
+	EventAccountRenumbered EventType = "account.renumbered"
+	// EventRateChangeNotice is published for each account a scheduled
+	// RateChange will reach, its notice period before it takes effect.
+	EventRateChangeNotice EventType = "rate.change_notice"
+	// EventMessageSent is published when a message lands in a customer's
+	// inbox, after it was delivered through the bank's channels.
+	EventMessageSent EventType = "message.sent"
+	// EventCaseChanged is published when a support case is opened, assigned,
+	// commented on or moves to another state.
+	EventCaseChanged EventType = "case.changed"
+	// EventSessionStarted and EventSessionRevoked are published when a
+	// customer signs in on a device and when a session is revoked.
+	EventSessionStarted EventType = "session.started"
+	EventSessionRevoked EventType = "session.revoked"
+	// EventAPIKeyIssued and EventAPIKeyRevoked are published when an API
+	// key is issued, or rotated, and when one is revoked.
+	EventAPIKeyIssued  EventType = "apikey.issued"
+	EventAPIKeyRevoked EventType = "apikey.revoked"
+	// EventConsentGranted and EventConsentRevoked are published, for each
+	// account, when a customer lets a third-party client read it and when
+	// they revoke the consent.
+	EventConsentGranted EventType = "consent.granted"
+	EventConsentRevoked EventType = "consent.revoked"
+	// EventInstallmentsChanged is published when an installment plan is
+	// made, one of its installments is paid or fails and when it completes
+	// or is cancelled.
+	EventInstallmentsChanged EventType = "installments.changed"
+	// EventBankRestored is published when the bank's state is replaced by
+	// Restore, which changes balances without ledger entries.
+	EventBankRestored EventType = "bank.restored"
+)
+
+// Event is something that happened in the bank. Transaction is set for
+// events caused by a ledger entry.
+type Event struct {
+	Type          EventType
+	AccountNumber string
+	Transaction   *Transaction
+	Message       string
+	Time          time.Time
+}
+
+// EventBus delivers events synchronously to every subscriber, in the order
+// they subscribed.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers []subscription
+	nextID   int
+}
+
+type subscription struct {
+	id      int
+	handler func(Event)
+}
+
+// Subscribe registers handler for every future event. Calling the returned
+// function removes it again.
+func (b *EventBus) Subscribe(handler func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	// Copy on write so Publish can iterate without holding the lock.
+	b.handlers = append(b.handlers[:len(b.handlers):len(b.handlers)], subscription{id, handler})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.handlers {
+			if sub.id == id {
+				handlers := make([]subscription, 0, len(b.handlers)-1)
+				b.handlers = append(append(handlers, b.handlers[:i]...), b.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, sub := range handlers {
+		sub.handler(e)
+	}
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gsolano/banking"
+	"gsolano/banking/totp"
+)
+
+// maxStepUpFailures is how many wrong codes in a row revoke a session, so
+// that a stolen one cannot guess its way to high-risk operations.
+const maxStepUpFailures = 5
+
+var (
+	ErrFactorUnknown   = banking.New(banking.CodeInvalidArgument, "unknown second factor")
+	ErrFactorRejected  = banking.New(banking.CodeUnauthenticated, "second factor code is wrong, expired or used")
+	ErrTOTPNotEnrolled = banking.New(banking.CodeNotFound, "no authenticator app enrolled")
+)
+
+// SecondFactor is a way for a customer to prove who they are besides their
+// session, such as a code from an authenticator app or sent by SMS, to step
+// it up; see StepUpWith. The bank's SecondFactors are those it takes, by
+// Name, TOTP of authenticator apps by default.
+type SecondFactor interface {
+	Name() string
+	// Verify checks a code the customer gave, an error wrapping
+	// ErrFactorRejected when it is wrong.
+	Verify(customer, code string) error
+}
+
+// authenticator is a customer's authenticator app: Secret, once confirmed,
+// and Pending, enrolled but not confirmed yet. LastStep is the time step of
+// the last code used, which no later code may reuse.
+type authenticator struct {
+	Secret   []byte `json:"secret,omitempty"`
+	Pending  []byte `json:"pending,omitempty"`
+	LastStep int64  `json:"last_step,omitempty"`
+}
+
+// TOTPEnrollment is what a customer adds to their authenticator app: its
+// Secret in base32, or the otpauth URL, usually as a QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+// TOTPStatus is whether a customer has an authenticator app, Enrolled,
+// and whether a new one waits for ConfirmTOTP, Pending.
+type TOTPStatus struct {
+	Enrolled bool `json:"enrolled"`
+	Pending  bool `json:"pending"`
+}
+
+// StepUpWith steps up a session, see StepUp, once its customer proves who
+// they are with a code of a second factor. Wrong codes count against the
+// session, which is revoked after maxStepUpFailures in a row.
+func (b *Bank) StepUpWith(id, factor, code string) (Session, error) {
+	var f SecondFactor
+	for _, candidate := range b.SecondFactors {
+		if candidate.Name() == factor {
+			f = candidate
+		}
+	}
+	if f == nil {
+		return Session{}, fmt.Errorf("%w: %q", ErrFactorUnknown, factor)
+	}
+	now := b.now()
+	b.mu.RLock()
+	s, ok := b.sessions[id]
+	if !ok || !s.active(now) {
+		b.mu.RUnlock()
+		return Session{}, ErrSessionNotFound
+	}
+	customer := s.Customer
+	b.mu.RUnlock()
+	if err := f.Verify(customer, code); err != nil {
+		b.mu.Lock()
+		s.failures++
+		revoked := s.failures >= maxStepUpFailures && s.Revoked == nil
+		if revoked {
+			s.Revoked = &now
+		}
+		device := s.Device
+		b.mu.Unlock()
+		if revoked {
+			b.Events.Publish(Event{Type: EventSessionRevoked, Time: now,
+				Message: fmt.Sprintf("Session %s of %s on %s revoked after %d wrong %s codes", id, customer, device, maxStepUpFailures, factor)})
+		}
+		return Session{}, err
+	}
+	b.mu.Lock()
+	s.failures = 0
+	b.mu.Unlock()
+	return b.StepUp(id)
+}
+
+// EnrollTOTP makes a new authenticator app secret for a customer. It is
+// pending until ConfirmTOTP, so that an app enrolled before keeps working
+// until the new one proves it has the secret.
+func (b *Bank) EnrollTOTP(customer string) (TOTPEnrollment, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.customers[customer]; !ok {
+		return TOTPEnrollment{}, ErrCustomerNotFound
+	}
+	a, ok := b.totpApps[customer]
+	if !ok {
+		a = &authenticator{}
+		b.totpApps[customer] = a
+	}
+	a.Pending = secret
+	issuer := b.Tenant
+	if issuer == "" {
+		issuer = "bank"
+	}
+	return TOTPEnrollment{Secret: totp.Encode(secret), URL: totp.URL(issuer, customer, secret)}, nil
+}
+
+// ConfirmTOTP checks a code of the secret EnrollTOTP made and, if it is
+// right, makes it the customer's authenticator app.
+func (b *Bank) ConfirmTOTP(customer, code string) error {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, ok := b.totpApps[customer]
+	if !ok || a.Pending == nil {
+		return ErrTOTPNotEnrolled
+	}
+	step, ok := totp.Verify(a.Pending, code, now)
+	if !ok {
+		return ErrFactorRejected
+	}
+	a.Secret, a.Pending, a.LastStep = a.Pending, nil, step
+	return nil
+}
+
+// TOTP returns whether a customer has an authenticator app.
+func (b *Bank) TOTP(customer string) (TOTPStatus, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if _, ok := b.customers[customer]; !ok {
+		return TOTPStatus{}, ErrCustomerNotFound
+	}
+	a, ok := b.totpApps[customer]
+	if !ok {
+		return TOTPStatus{}, nil
+	}
+	return TOTPStatus{Enrolled: a.Secret != nil, Pending: a.Pending != nil}, nil
+}
+
+// RemoveTOTP forgets the authenticator app of a customer, such as a lost
+// phone's.
+func (b *Bank) RemoveTOTP(customer string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.totpApps[customer]; !ok {
+		return ErrTOTPNotEnrolled
+	}
+	delete(b.totpApps, customer)
+	return nil
+}
+
+// totpFactor is the SecondFactor of authenticator apps, enrolled with
+// EnrollTOTP.
+type totpFactor struct{ b *Bank }
+
+func (totpFactor) Name() string { return "totp" }
+
+func (f totpFactor) Verify(customer, code string) error {
+	now := f.b.now()
+	f.b.mu.Lock()
+	defer f.b.mu.Unlock()
+	a, ok := f.b.totpApps[customer]
+	if !ok || a.Secret == nil {
+		return ErrTOTPNotEnrolled
+	}
+	step, ok := totp.Verify(a.Secret, code, now)
+	if !ok || step <= a.LastStep {
+		return ErrFactorRejected
+	}
+	a.LastStep = step
+	return nil
+}
+
+// stepUpNeeded is the error of an op over the bank's StepUpAmount by a
+// session that is not stepped up, nil if it may go ahead.
+func (b *Bank) stepUpNeeded(op Op, sessionID string) error {
+	if sessionID == "" || op.Kind != OpTransfer || b.StepUpAmount <= 0 || op.Amount <= b.StepUpAmount {
+		return nil
+	}
+	now := b.now()
+	b.mu.RLock()
+	s, ok := b.sessions[sessionID]
+	var session Session
+	if ok {
+		session = *s
+	}
+	b.mu.RUnlock()
+	if !ok || !session.active(now) {
+		return ErrSessionInvalid
+	}
+	if !session.SteppedUp(now, b.StepUpWindow) {
+		return fmt.Errorf("%w: transfers over %.2f", ErrStepUpRequired, b.StepUpAmount)
+	}
+	return nil
+}
+
+// MetaSession is the metadata key of the session a transaction was made in,
+// so that its history shows the device.
+const MetaSession = "session"
+
+// BySession records the session an op is made in. Ops of sessions go
+// through AuthorizeHolders' step-up check.
+func BySession(id string) TxOption {
+	return WithMetadata(MetaSession, id)
+}
+
+// sealAuthenticator encrypts a customer's authenticator app with the
+// bank's SecretKey for a snapshot, using AES-256-GCM under the key's
+// SHA-256 and the customer ID as additional data, so a sealed app cannot be
+// moved to another customer.
+func (b *Bank) sealAuthenticator(customer string, a authenticator) ([]byte, error) {
+	aead, err := b.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, []byte(customer)), nil
+}
+
+// openAuthenticator decrypts what sealAuthenticator sealed.
+func (b *Bank) openAuthenticator(customer string, sealed []byte) (*authenticator, error) {
+	aead, err := b.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed authenticator app is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(customer))
+	if err != nil {
+		return nil, fmt.Errorf("authenticator app of %s does not open with the secret key: %v", customer, err)
+	}
+	var a authenticator
+	if err := json.Unmarshal(plain, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (b *Bank) secretCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256(b.SecretKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/totp"
+)
+
+// TestSecondFactor enrolls an authenticator app, checks that transfers of a
+// session over StepUpAmount wait for a step up with its codes, that a code
+// is only taken once and that wrong codes end the session.
+func TestSecondFactor(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.StepUpAmount = 500
+	b.Use(b.AuthorizeHolders())
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	if err := b.Deposit("C1", 2000); err != nil {
+		t.Fatal(err)
+	}
+	session, _, err := b.StartSession("c1", "phone", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.EnrollTOTP("c1"); err != nil {
+		t.Fatal(err)
+	}
+	code := func(secret []byte) string { return totp.Code(secret, totp.Step(clock.now)) }
+	if err := b.ConfirmTOTP("c1", "wrong"); !errors.Is(err, ErrFactorRejected) {
+		t.Errorf("confirming a wrong code: %v, want %v", err, ErrFactorRejected)
+	}
+	if err := b.ConfirmTOTP("c1", code(b.totpApps["c1"].Pending)); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := b.TOTP("c1"); !status.Enrolled || status.Pending {
+		t.Errorf("status after confirming %+v", status)
+	}
+	secret := b.totpApps["c1"].Secret
+	if _, err := b.StepUpWith(session.ID, "totp", code(secret)); !errors.Is(err, ErrFactorRejected) {
+		t.Errorf("reusing the code that confirmed: %v, want %v", err, ErrFactorRejected)
+	}
+
+	clock.now = clock.now.Add(time.Hour)
+	by := []TxOption{ByHolder("c1"), BySession(session.ID)}
+	if err := b.Transfer("C1", "C2", 100, by...); err != nil {
+		t.Errorf("transfer under the step-up amount: %v", err)
+	}
+	if err := b.Transfer("C1", "C2", 800, by...); !errors.Is(err, ErrStepUpRequired) {
+		t.Errorf("transfer over the step-up amount: %v, want %v", err, ErrStepUpRequired)
+	}
+	if _, err := b.StepUpWith(session.ID, "sms", code(secret)); !errors.Is(err, ErrFactorUnknown) {
+		t.Errorf("unknown factor: %v, want %v", err, ErrFactorUnknown)
+	}
+	if _, err := b.StepUpWith(session.ID, "totp", code(secret)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer("C1", "C2", 800, by...); err != nil {
+		t.Errorf("transfer after stepping up: %v", err)
+	}
+	if _, err := b.StepUpWith(session.ID, "totp", code(secret)); !errors.Is(err, ErrFactorRejected) {
+		t.Errorf("replayed code: %v, want %v", err, ErrFactorRejected)
+	}
+
+	for i := 1; i < maxStepUpFailures; i++ {
+		b.StepUpWith(session.ID, "totp", "wrong")
+	}
+	if _, err := b.StepUpWith(session.ID, "totp", "wrong"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("stepping up after %d wrong codes: %v, want %v", maxStepUpFailures, err, ErrSessionNotFound)
+	}
+}
+
+// TestSnapshotSealsTOTP checks that snapshots carry authenticator app
+// secrets only encrypted with the bank's SecretKey, leave them out without
+// one, and do not restore with another key.
+func TestSnapshotSealsTOTP(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada"})
+	enrollment, err := b.EnrollTOTP("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ConfirmTOTP("c1", totp.Code(b.totpApps["c1"].Pending, totp.Step(time.Now()))); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := func(key string) []byte {
+		b.SecretKey = []byte(key)
+		var buf bytes.Buffer
+		if err := b.Snapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	restore := func(snap []byte, key string) (*Bank, error) {
+		c := NewBank()
+		c.SecretKey = []byte(key)
+		return c, c.Restore(bytes.NewReader(snap))
+	}
+
+	clear := snapshot("")
+	c, err := restore(clear, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := c.TOTP("c1"); status.Enrolled {
+		t.Error("app restored from a snapshot taken without a secret key")
+	}
+
+	sealed := snapshot("server key")
+	secret := b.totpApps["c1"].Secret
+	if bytes.Contains(sealed, []byte(base64.StdEncoding.EncodeToString(secret))) || bytes.Contains(sealed, []byte(enrollment.Secret)) {
+		t.Error("snapshot carries the secret in the clear")
+	}
+	c, err = restore(sealed, "server key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := c.totpApps["c1"]; a == nil || !bytes.Equal(a.Secret, secret) {
+		t.Errorf("restored app %+v, want the secret back", a)
+	}
+	for _, key := range []string{"", "other key"} {
+		if _, err := restore(sealed, key); !errors.Is(err, ErrUnsupportedSnapshot) {
+			t.Errorf("restoring with key %q: %v, want %v", key, err, ErrUnsupportedSnapshot)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Feature string
+
+const (
+	// FeatureOverdraft lets checking accounts go below zero down to their
+	// overdraft limit.
+	FeatureOverdraft Feature = "overdraft"
+	// FeatureNegativeInterest lets savings accounts with a negative rate
+	// be charged interest.
+	FeatureNegativeInterest Feature = "negative_interest"
+)
+
+// defaultFeatures keeps today's behavior: overdrafts work, everything else
+// has to be switched on.
+var defaultFeatures = map[Feature]bool{
+	FeatureOverdraft:        true,
+	FeatureNegativeInterest: false,
+}
+
+// FeatureFlags decides at runtime which risky behaviors are enabled. Each
+// tenant inherits the global settings and may override any of them, so
+// operators can roll a capability out one tenant at a time.
+type FeatureFlags struct {
+	mu      sync.RWMutex
+	global  map[Feature]bool
+	tenants map[string]map[Feature]bool
+}
+
+func NewFeatureFlags() *FeatureFlags {
+	global := make(map[Feature]bool, len(defaultFeatures))
+	for f, on := range defaultFeatures {
+		global[f] = on
+	}
+	return &FeatureFlags{global: global, tenants: make(map[string]map[Feature]bool)}
+}
+
+// Set enables or disables a feature globally.
+func (f *FeatureFlags) Set(feature Feature, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.global[feature] = enabled
+}
+
+// SetForTenant overrides a feature for one tenant.
+func (f *FeatureFlags) SetForTenant(tenant string, feature Feature, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.tenants[tenant] == nil {
+		f.tenants[tenant] = make(map[Feature]bool)
+	}
+	f.tenants[tenant][feature] = enabled
+}
+
+// Enabled reports whether feature is on for tenant. An empty tenant asks for
+// the global setting.
+func (f *FeatureFlags) Enabled(tenant string, feature Feature) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if on, ok := f.tenants[tenant][feature]; ok {
+		return on
+	}
+	return f.global[feature]
+}
+
+// Apply sets several features by name, for a tenant or globally when tenant
+// is empty, as read from configuration. Unknown names are rejected.
+func (f *FeatureFlags) Apply(tenant string, settings map[string]bool) error {
+	for name := range settings {
+		if _, ok := defaultFeatures[Feature(name)]; !ok {
+			return fmt.Errorf("unknown feature %q", name)
+		}
+	}
+	for name, on := range settings {
+		if tenant == "" {
+			f.Set(Feature(name), on)
+		} else {
+			f.SetForTenant(tenant, Feature(name), on)
+		}
+	}
+	return nil
+}
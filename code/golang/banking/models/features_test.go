@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestFeatureFlags checks that tenants inherit the defaults and the global
+// settings, that their own overrides win over both, and that unknown
+// features are rejected without applying any of the settings.
+func TestFeatureFlags(t *testing.T) {
+	flags := NewFeatureFlags()
+	if err := flags.Apply("", map[string]bool{"negative_interest": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Apply("acme", map[string]bool{"overdraft": false}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Apply("zeta", map[string]bool{"negative_interest": false, "time_travel": true}); err == nil {
+		t.Error("applying an unknown feature succeeded")
+	}
+
+	for _, tt := range []struct {
+		tenant  string
+		feature Feature
+		want    bool
+	}{
+		{"", FeatureOverdraft, true},
+		{"", FeatureNegativeInterest, true},
+		{"acme", FeatureOverdraft, false},
+		{"acme", FeatureNegativeInterest, true},
+		{"zeta", FeatureOverdraft, true},
+		{"zeta", FeatureNegativeInterest, true},
+	} {
+		if got := flags.Enabled(tt.tenant, tt.feature); got != tt.want {
+			t.Errorf("%s for %q = %v, want %v", tt.feature, tt.tenant, got, tt.want)
+		}
+	}
+}
+
+// TestFeatureRollout switches overdrafts off globally and back on for one
+// tenant, and checks that only that tenant's bank lets an account overdraw.
+func TestFeatureRollout(t *testing.T) {
+	SetOutput(io.Discard)
+	flags := NewFeatureFlags()
+	flags.Set(FeatureOverdraft, false)
+	flags.SetForTenant("pilot", FeatureOverdraft, true)
+
+	for _, tt := range []struct {
+		tenant string
+		want   error
+	}{
+		{"", ErrInsufficientFunds},
+		{"other", ErrInsufficientFunds},
+		{"pilot", nil},
+	} {
+		b := NewBank()
+		b.Flags, b.Tenant = flags, tt.tenant
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}, OverdraftLimit: 100})
+		if err := b.Withdraw("C1", 50); !errors.Is(err, tt.want) {
+			t.Errorf("overdrawing for %q: %v, want %v", tt.tenant, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// GLBalance is the balance of a general ledger account on the side it
+// normally falls, debits for assets and expenses and credits for the
+// others; it is negative when the account fell on the other side.
+type GLBalance struct {
+	GLAccount
+	Balance float64 `json:"balance"`
+}
+
+// BalanceSheet is the position of the bank's books in one currency. Earnings
+// are income less expenses to date, which belong to equity until they are
+// closed into it. The books hold together when TotalAssets equals
+// TotalLiabilities plus TotalEquity, earnings included.
+type BalanceSheet struct {
+	Currency         string      `json:"currency"`
+	AsOf             time.Time   `json:"as_of"`
+	Assets           []GLBalance `json:"assets"`
+	Liabilities      []GLBalance `json:"liabilities"`
+	Equity           []GLBalance `json:"equity"`
+	Earnings         float64     `json:"earnings"`
+	TotalAssets      float64     `json:"total_assets"`
+	TotalLiabilities float64     `json:"total_liabilities"`
+	TotalEquity      float64     `json:"total_equity"`
+	Balanced         bool        `json:"balanced"`
+}
+
+// IncomeStatement is what the bank earned and spent in one currency from
+// From until To.
+type IncomeStatement struct {
+	Currency      string      `json:"currency"`
+	From          time.Time   `json:"from"`
+	To            time.Time   `json:"to"`
+	Income        []GLBalance `json:"income"`
+	Expenses      []GLBalance `json:"expenses"`
+	TotalIncome   float64     `json:"total_income"`
+	TotalExpenses float64     `json:"total_expenses"`
+	NetIncome     float64     `json:"net_income"`
+}
+
+// BalanceSheet returns the balance sheet of the books by currency, ordered
+// by currency, with the entries posted before asOf, or every entry when
+// asOf is zero.
+func (b *Bank) BalanceSheet(asOf time.Time) []BalanceSheet {
+	report := []BalanceSheet{}
+	for currency, nets := range b.glBalances(time.Time{}, asOf) {
+		bs := BalanceSheet{Currency: currency, AsOf: asOf, Assets: []GLBalance{}, Liabilities: []GLBalance{}, Equity: []GLBalance{}}
+		var assets, liabilities, equity, earnings big.Rat
+		for _, gl := range ChartOfAccounts {
+			net, ok := nets[gl.Code]
+			if !ok {
+				continue
+			}
+			balance := gl.normal(net)
+			line := GLBalance{GLAccount: gl, Balance: money.Round(ratFloat(balance), currency, money.HalfEven)}
+			switch gl.Type {
+			case GLAsset:
+				bs.Assets = append(bs.Assets, line)
+				assets.Add(&assets, balance)
+			case GLLiability:
+				bs.Liabilities = append(bs.Liabilities, line)
+				liabilities.Add(&liabilities, balance)
+			case GLEquity:
+				bs.Equity = append(bs.Equity, line)
+				equity.Add(&equity, balance)
+			case GLIncome:
+				earnings.Add(&earnings, balance)
+			case GLExpense:
+				earnings.Sub(&earnings, balance)
+			}
+		}
+		equity.Add(&equity, &earnings)
+		bs.Earnings = money.Round(ratFloat(&earnings), currency, money.HalfEven)
+		bs.TotalAssets = money.Round(ratFloat(&assets), currency, money.HalfEven)
+		bs.TotalLiabilities = money.Round(ratFloat(&liabilities), currency, money.HalfEven)
+		bs.TotalEquity = money.Round(ratFloat(&equity), currency, money.HalfEven)
+		bs.Balanced = assets.Cmp(new(big.Rat).Add(&liabilities, &equity)) == 0
+		report = append(report, bs)
+	}
+	slices.SortFunc(report, func(a, b BalanceSheet) int { return strings.Compare(a.Currency, b.Currency) })
+	return report
+}
+
+// IncomeStatement returns the income statement of the books by currency,
+// ordered by currency, for the entries posted from from until before to; a
+// zero bound leaves that end open.
+func (b *Bank) IncomeStatement(from, to time.Time) []IncomeStatement {
+	report := []IncomeStatement{}
+	for currency, nets := range b.glBalances(from, to) {
+		is := IncomeStatement{Currency: currency, From: from, To: to, Income: []GLBalance{}, Expenses: []GLBalance{}}
+		var income, expenses big.Rat
+		for _, gl := range ChartOfAccounts {
+			net, ok := nets[gl.Code]
+			if !ok || gl.Type != GLIncome && gl.Type != GLExpense {
+				continue
+			}
+			balance := gl.normal(net)
+			line := GLBalance{GLAccount: gl, Balance: money.Round(ratFloat(balance), currency, money.HalfEven)}
+			if gl.Type == GLIncome {
+				is.Income = append(is.Income, line)
+				income.Add(&income, balance)
+			} else {
+				is.Expenses = append(is.Expenses, line)
+				expenses.Add(&expenses, balance)
+			}
+		}
+		is.TotalIncome = money.Round(ratFloat(&income), currency, money.HalfEven)
+		is.TotalExpenses = money.Round(ratFloat(&expenses), currency, money.HalfEven)
+		is.NetIncome = money.Round(ratFloat(new(big.Rat).Sub(&income, &expenses)), currency, money.HalfEven)
+		report = append(report, is)
+	}
+	slices.SortFunc(report, func(a, b IncomeStatement) int { return strings.Compare(a.Currency, b.Currency) })
+	return report
+}
+
+// normal turns net, debits less credits, into the balance of gl on the side
+// it normally falls.
+func (gl GLAccount) normal(net *big.Rat) *big.Rat {
+	if gl.Type == GLAsset || gl.Type == GLExpense {
+		return new(big.Rat).Set(net)
+	}
+	return new(big.Rat).Neg(net)
+}
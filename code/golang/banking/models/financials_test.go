@@ -0,0 +1,108 @@
+package models
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// TestTrialBalance runs a mixed workload across two currencies, with failed
+// and rolled back operations among it, and checks that the books balance in
+// each currency as of any time, that the balance sheet holds together and
+// that its earnings are the net income of the income statement.
+func TestTrialBalance(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-02-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.Currency = "USD"
+	b.FX = fixedRate(0.5)
+	b.FXPricing = FXPricing{Margin: money.Percent(10), Fee: 2}
+	if err := b.Products.Define(Product{Code: "fee", Name: "Fee Checking", Kind: ProductChecking, MonthlyFee: 5,
+		Overdraft: OverdraftPolicy{Limit: 100, Fee: 15}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.OpenAccount("fee", "", "C1"); err != nil {
+		t.Fatal(err)
+	}
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1", Balance: 50}, InterestRate: money.Percent(12)})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "E1", Currency: "EUR"}, InterestRate: money.Percent(6)})
+	b.Open(&LoanAccount{Account: Account{AccountNumber: "L1", Balance: -1200}, Principal: 1200,
+		InterestRate: money.Percent(6), TermMonths: 12, Opened: date(t, "2026-01-01")})
+
+	if err := b.Deposit("C1", 500); err != nil {
+		t.Fatal(err)
+	}
+	b.Withdraw("C1", 10_000)
+	b.Transfer("C1", "C1", 10)
+	b.TransferBatch([]TransferRequest{{From: "C1", To: "S1", Amount: 100}, {From: "S1", To: "C1", Amount: 1_000}}, AllOrNothing())
+	for _, err := range []error{b.Transfer("C1", "S1", 120), b.Transfer("C1", "L1", 200), b.ApplyInterest("S1"), b.ApplyInterest("L1")} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	q, err := b.QuoteTransfer("C1", "E1", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.TransferQuoted(q.ID); err != nil {
+		t.Fatal(err)
+	}
+	midway := date(t, "2026-02-05")
+
+	clock.now = date(t, "2026-02-10")
+	for _, err := range []error{b.Withdraw("C1", 150), b.Transfer("E1", "S1", 10), b.ApplyInterest("E1")} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if charged := b.AssessFees(); len(charged) != 1 {
+		t.Fatalf("fees charged to %v, want C1", charged)
+	}
+
+	check := func(name string, trial []TrialBalance, currencies int) {
+		t.Helper()
+		if len(trial) != currencies {
+			t.Fatalf("%s: trial balance in %d currencies, want %d", name, len(trial), currencies)
+		}
+		for _, tb := range trial {
+			if !tb.Balanced || tb.Debits != tb.Credits {
+				t.Errorf("%s: %s books debit %.2f and credit %.2f", name, tb.Currency, tb.Debits, tb.Credits)
+			}
+			var debits, credits float64
+			for _, l := range tb.Lines {
+				debits += l.Debit
+				credits += l.Credit
+			}
+			if money.Round(debits, tb.Currency, money.HalfEven) != tb.Debits || money.Round(credits, tb.Currency, money.HalfEven) != tb.Credits {
+				t.Errorf("%s: %s lines sum to %.2f and %.2f, totals %.2f and %.2f", name, tb.Currency, debits, credits, tb.Debits, tb.Credits)
+			}
+		}
+	}
+	check("all", b.TrialBalance(time.Time{}), 2)
+	check("midway", b.TrialBalance(midway), 2)
+	if earlier := b.TrialBalance(date(t, "2026-01-01")); len(earlier) != 0 {
+		t.Errorf("trial balance before any entry = %+v", earlier)
+	}
+
+	sheets, statements := b.BalanceSheet(time.Time{}), b.IncomeStatement(time.Time{}, time.Time{})
+	if len(sheets) != 2 || len(statements) != 2 {
+		t.Fatalf("%d balance sheets and %d income statements, want 2 each", len(sheets), len(statements))
+	}
+	for i, bs := range sheets {
+		if !bs.Balanced || money.Round(bs.TotalLiabilities+bs.TotalEquity, bs.Currency, money.HalfEven) != bs.TotalAssets {
+			t.Errorf("%s balance sheet: assets %.2f, liabilities %.2f, equity %.2f", bs.Currency, bs.TotalAssets, bs.TotalLiabilities, bs.TotalEquity)
+		}
+		if is := statements[i]; is.Currency != bs.Currency || is.NetIncome != bs.Earnings {
+			t.Errorf("%s earnings %.2f, net income %.2f", bs.Currency, bs.Earnings, is.NetIncome)
+		}
+	}
+	if usd := statements[1]; usd.Currency != "USD" || usd.TotalIncome <= 5+2 || usd.TotalExpenses <= 0 {
+		t.Errorf("USD income statement %+v, want the fees and loan interest earned and savings interest paid", usd)
+	}
+	if later := b.IncomeStatement(midway, time.Time{}); later[1].TotalIncome != 5+15 || later[1].TotalExpenses != 0 {
+		t.Errorf("USD income after %s = %+v, want the monthly and overdraft fees alone", midway.Format("2006-01-02"), later[1])
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters: k1 limits how much repeating a word raises the score and
+// b how much long descriptions are penalized.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// counterpartyWeight counts a word in the counterparty as this many
+	// words of description; payments are mostly searched by payee.
+	counterpartyWeight = 2
+)
+
+// TextIndex is an in-memory inverted index over the descriptions and
+// counterparties of every ledger entry of a bank, ranked with BM25. It
+// indexes the existing ledgers when created and then follows
+// EventTransactionPosted, dropping accounts on EventAccountArchived.
+type TextIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[entryID]int // term frequency per entry
+	entries  map[entryID]indexedEntry
+	totalLen int
+
+	unsubscribe func()
+}
+
+type entryID struct {
+	account  string
+	sequence int
+}
+
+type indexedEntry struct {
+	tx     Transaction
+	length int
+}
+
+// ScoredMatch is a full-text search result; a higher Score ranks higher.
+type ScoredMatch struct {
+	Match
+	Score float64
+}
+
+func NewTextIndex(bank *Bank) *TextIndex {
+	ix := &TextIndex{postings: make(map[string]map[entryID]int), entries: make(map[entryID]indexedEntry)}
+	// Subscribe before reading the ledgers, as the gRPC stream does; entries
+	// seen twice are indexed once.
+	ix.unsubscribe = bank.Events.Subscribe(func(e Event) {
+		switch {
+		case e.Type == EventTransactionPosted && e.Transaction != nil:
+			ix.Add(e.AccountNumber, *e.Transaction)
+		case e.Type == EventAccountArchived:
+			ix.Remove(e.AccountNumber)
+		case e.Type == EventAccountRenumbered:
+			ix.Remove(e.AccountNumber)
+			if r, ok := bank.Redirect(e.AccountNumber); ok {
+				history, _ := bank.History(r.To)
+				for _, tx := range history {
+					ix.Add(r.To, tx)
+				}
+			}
+		}
+	})
+	for _, account := range bank.Accounts() {
+		history, _ := bank.History(account.Number())
+		for _, tx := range history {
+			ix.Add(account.Number(), tx)
+		}
+	}
+	return ix
+}
+
+// Close stops following the bank's events.
+func (ix *TextIndex) Close() {
+	ix.unsubscribe()
+}
+
+// Add indexes one ledger entry.
+func (ix *TextIndex) Add(account string, tx Transaction) {
+	id := entryID{account, tx.Sequence}
+	terms := make(map[string]int)
+	length := 0
+	for _, t := range tokenize(tx.Description) {
+		terms[t]++
+		length++
+	}
+	for _, t := range tokenize(tx.Counterparty) {
+		terms[t] += counterpartyWeight
+		length += counterpartyWeight
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, ok := ix.entries[id]; ok {
+		return
+	}
+	ix.entries[id] = indexedEntry{tx: tx, length: length}
+	ix.totalLen += length
+	for term, n := range terms {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[entryID]int)
+		}
+		ix.postings[term][id] = n
+	}
+}
+
+// Remove drops every entry of an account from the index.
+func (ix *TextIndex) Remove(account string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for id, entry := range ix.entries {
+		if id.account != account {
+			continue
+		}
+		delete(ix.entries, id)
+		ix.totalLen -= entry.length
+	}
+	for term, postings := range ix.postings {
+		for id := range postings {
+			if id.account == account {
+				delete(postings, id)
+			}
+		}
+		if len(postings) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+}
+
+// Search returns up to limit entries containing any word of text, best
+// match first. Entries matching more words, rarer words or shorter
+// descriptions rank higher; ties go to the newest entry.
+func (ix *TextIndex) Search(text string, limit int) []ScoredMatch {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if len(ix.entries) == 0 {
+		return nil
+	}
+	n := float64(len(ix.entries))
+	avgLen := float64(ix.totalLen) / n
+	scores := make(map[entryID]float64)
+	for _, term := range uniq(tokenize(text)) {
+		postings := ix.postings[term]
+		idf := math.Log(1 + (n-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for id, tf := range postings {
+			length := float64(ix.entries[id].length)
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*length/avgLen))
+		}
+	}
+
+	matches := make([]ScoredMatch, 0, len(scores))
+	for id, score := range scores {
+		matches = append(matches, ScoredMatch{Match: Match{Account: id.account, Transaction: ix.entries[id].tx}, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Transaction.Time.After(matches[j].Transaction.Time)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// tokenize splits text into lower-case words of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func uniq(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	out := words[:0]
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
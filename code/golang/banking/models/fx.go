@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var (
+	ErrNoExchangeRate   = banking.New(banking.CodeUnavailable, "no exchange rate")
+	ErrCurrencyMismatch = banking.New(banking.CodeInvalidArgument, "accounts are in different currencies")
+)
+
+// ExchangeRate is the price of one unit of Base in Quote, as published at
+// AsOf. AsOf is zero for rates of no particular date, such as ones set by
+// hand.
+type ExchangeRate struct {
+	Base  string    `json:"base"`
+	Quote string    `json:"quote"`
+	Rate  float64   `json:"rate"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// Inverse returns the rate of Quote in Base.
+func (r ExchangeRate) Inverse() ExchangeRate {
+	return ExchangeRate{Base: r.Quote, Quote: r.Base, Rate: 1 / r.Rate, AsOf: r.AsOf}
+}
+
+// ExchangeRateProvider supplies current exchange rates; package fx has
+// providers for fixed tables and published feeds. It returns
+// ErrNoExchangeRate for pairs it does not know.
+type ExchangeRateProvider interface {
+	ExchangeRate(base, quote string) (ExchangeRate, error)
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code,
+// three upper case letters such as EUR.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyOf returns the currency an account is kept in, or "" for the
+// bank's currency.
+func CurrencyOf(account BankAccount) string {
+	if l, ok := account.(ledgered); ok {
+		return l.ledger().Currency
+	}
+	return ""
+}
+
+// AccountCurrency returns the currency of account, the bank's unless it
+// has its own.
+func (b *Bank) AccountCurrency(account BankAccount) string {
+	if c := CurrencyOf(account); c != "" {
+		return c
+	}
+	return b.Currency
+}
+
+// exchangeRate returns the rate a transfer from one account to the other
+// converts at, or nil when they are in the same currency. It may ask the
+// bank's FX provider, so it is called before the accounts are locked.
+func (b *Bank) exchangeRate(from, to BankAccount) (*ExchangeRate, error) {
+	base, quote := b.AccountCurrency(from), b.AccountCurrency(to)
+	if base == quote {
+		return nil, nil
+	}
+	if base == "" || quote == "" {
+		return nil, fmt.Errorf("%w: %s and %s, and the bank has no currency", ErrCurrencyMismatch, from.Number(), to.Number())
+	}
+	if b.FX == nil {
+		return nil, fmt.Errorf("%w: %s to %s: no exchange rate provider configured", ErrNoExchangeRate, base, quote)
+	}
+	rate, err := b.FX.ExchangeRate(base, quote)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// convert returns what amount of the base currency of rate is worth in its
+// quote currency, rounded to the quote currency's minor unit.
+func (b *Bank) convert(amount float64, rate ExchangeRate) float64 {
+	return money.New(amount, rate.Base, b.Rounding).Convert(rate.Quote, rate.Rate, b.Rounding).Float()
+}
+
+// withExchangeRate records the rate a transfer converted at on its legs.
+func withExchangeRate(rate ExchangeRate) []TxOption {
+	return []TxOption{
+		WithMetadata("fx_pair", rate.Base+"/"+rate.Quote),
+		WithMetadata("fx_rate", strconv.FormatFloat(rate.Rate, 'g', -1, 64)),
+	}
+}
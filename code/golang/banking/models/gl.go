@@ -0,0 +1,231 @@
+package models
+
+import (
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// GLAccountType is the class of an account of the bank's own books.
+// Assets and expenses carry debit balances, the others credit balances.
+type GLAccountType string
+
+const (
+	GLAsset     GLAccountType = "asset"
+	GLLiability GLAccountType = "liability"
+	GLEquity    GLAccountType = "equity"
+	GLIncome    GLAccountType = "income"
+	GLExpense   GLAccountType = "expense"
+)
+
+// Codes of the general ledger accounts in ChartOfAccounts.
+const (
+	GLCash            = "1000"
+	GLLoans           = "1100"
+	GLClearing        = "1900"
+	GLDeposits        = "2000"
+	GLInterestIncome  = "4000"
+	GLFeeIncome       = "4100"
+	GLInterestExpense = "5000"
+)
+
+// GLAccount is an account of the bank's general ledger.
+type GLAccount struct {
+	Code string        `json:"code"`
+	Name string        `json:"name"`
+	Type GLAccountType `json:"type"`
+}
+
+// ChartOfAccounts is the bank's general ledger. Customer deposits, the
+// balances of savings and checking accounts, are what the bank owes; loans
+// and cards what it is owed. Transfers between customer accounts pass
+// through clearing, which they leave at zero unless they change currency,
+// and balances accounts were opened with, outside their ledgers, are booked
+// against cash, as if deposited or lent when they opened, so the bank's
+// equity is what it earned.
+var ChartOfAccounts = []GLAccount{
+	{Code: GLCash, Name: "Cash", Type: GLAsset},
+	{Code: GLLoans, Name: "Loans and cards receivable", Type: GLAsset},
+	{Code: GLClearing, Name: "Transfers clearing", Type: GLAsset},
+	{Code: GLDeposits, Name: "Customer deposits", Type: GLLiability},
+	{Code: GLInterestIncome, Name: "Interest income", Type: GLIncome},
+	{Code: GLFeeIncome, Name: "Fee income", Type: GLIncome},
+	{Code: GLInterestExpense, Name: "Interest expense", Type: GLExpense},
+}
+
+// JournalLine debits or credits a general ledger account.
+type JournalLine struct {
+	GL     string  `json:"gl"`
+	Debit  float64 `json:"debit,omitempty"`
+	Credit float64 `json:"credit,omitempty"`
+}
+
+// JournalEntry is what an entry of a customer account posts to the bank's
+// books, in the currency of the account: a debit and a credit of its
+// amount. Sequence is that of the customer entry, or 0 for the balance the
+// account was opened with.
+type JournalEntry struct {
+	Account  string          `json:"account"`
+	Sequence int             `json:"sequence"`
+	Time     time.Time       `json:"time"`
+	Type     TransactionType `json:"type,omitempty"`
+	Currency string          `json:"currency"`
+	Lines    []JournalLine   `json:"lines"`
+}
+
+// TrialBalanceLine is the balance of a general ledger account, on the side
+// it falls.
+type TrialBalanceLine struct {
+	GLAccount
+	Debit  float64 `json:"debit"`
+	Credit float64 `json:"credit"`
+}
+
+// TrialBalance lists the balances of the general ledger in one currency.
+// The books are in balance when Debits equal Credits.
+type TrialBalance struct {
+	Currency string             `json:"currency"`
+	AsOf     time.Time          `json:"as_of"`
+	Lines    []TrialBalanceLine `json:"lines"`
+	Debits   float64            `json:"debits"`
+	Credits  float64            `json:"credits"`
+	Balanced bool               `json:"balanced"`
+}
+
+// Journal returns what the entries of an account posted to the bank's
+// books, oldest first. The books follow from the ledgers of the customer
+// accounts, so every entry posts to them when it is posted, or undone, and
+// they never drift from the ledgers.
+func (b *Bank) Journal(number string) ([]JournalEntry, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.journalLocked(account), nil
+}
+
+// TrialBalance sums the books by currency, ordered by currency, with the
+// entries posted before asOf, or every entry when asOf is zero.
+func (b *Bank) TrialBalance(asOf time.Time) []TrialBalance {
+	totals := b.glBalances(time.Time{}, asOf)
+	report := make([]TrialBalance, 0, len(totals))
+	for currency, nets := range totals {
+		tb := TrialBalance{Currency: currency, AsOf: asOf, Lines: []TrialBalanceLine{}}
+		debits, credits := new(big.Rat), new(big.Rat)
+		for _, gl := range ChartOfAccounts {
+			net, ok := nets[gl.Code]
+			if !ok {
+				continue
+			}
+			line := TrialBalanceLine{GLAccount: gl}
+			if net.Sign() >= 0 {
+				debits.Add(debits, net)
+				line.Debit = money.Round(ratFloat(net), currency, money.HalfEven)
+			} else {
+				credits.Sub(credits, net)
+				line.Credit = money.Round(-ratFloat(net), currency, money.HalfEven)
+			}
+			tb.Lines = append(tb.Lines, line)
+		}
+		tb.Debits = money.Round(ratFloat(debits), currency, money.HalfEven)
+		tb.Credits = money.Round(ratFloat(credits), currency, money.HalfEven)
+		tb.Balanced = debits.Cmp(credits) == 0
+		report = append(report, tb)
+	}
+	slices.SortFunc(report, func(a, b TrialBalance) int { return strings.Compare(a.Currency, b.Currency) })
+	return report
+}
+
+// glBalances sums the lines the books were posted, debits less credits, by
+// currency and general ledger account, over the entries posted from from
+// until before to; a zero bound leaves that end open.
+func (b *Bank) glBalances(from, to time.Time) map[string]map[string]*big.Rat {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	totals := make(map[string]map[string]*big.Rat)
+	for _, account := range b.accounts.all() {
+		for _, e := range b.journalLocked(account) {
+			if e.Time.Before(from) || !to.IsZero() && !e.Time.Before(to) {
+				continue
+			}
+			if totals[e.Currency] == nil {
+				totals[e.Currency] = make(map[string]*big.Rat)
+			}
+			for _, l := range e.Lines {
+				net := totals[e.Currency][l.GL]
+				if net == nil {
+					net = new(big.Rat)
+					totals[e.Currency][l.GL] = net
+				}
+				net.Add(net, exactAmount(l.Debit))
+				net.Sub(net, exactAmount(l.Credit))
+			}
+		}
+	}
+	return totals
+}
+
+// journalLocked posts the entries of account to the books: entries that add
+// to its balance credit its control account, customer deposits or, for
+// loans and cards, receivables, and the others debit it, against cash,
+// clearing for transfers between accounts on the books, interest or fees.
+// The caller holds b.mu.
+func (b *Bank) journalLocked(account BankAccount) []JournalEntry {
+	control := GLDeposits
+	if _, ok := account.(creditLine); ok {
+		control = GLLoans
+	}
+	currency := b.AccountCurrency(account)
+	history := account.History()
+	entry := func(tx Transaction, counter string, amount float64) JournalEntry {
+		e := JournalEntry{Account: account.Number(), Sequence: tx.Sequence, Time: tx.Time, Type: tx.Type, Currency: currency}
+		debit, credit := counter, control
+		if amount < 0 {
+			debit, credit, amount = control, counter, -amount
+		}
+		e.Lines = []JournalLine{{GL: debit, Debit: amount}, {GL: credit, Credit: amount}}
+		return e
+	}
+
+	var journal []JournalEntry
+	opening := exactAmount(account.CheckBalance())
+	for _, tx := range history {
+		opening.Sub(opening, signedAmount(tx))
+	}
+	if opening.Sign() != 0 {
+		var first Transaction
+		if len(history) > 0 {
+			first.Time = history[0].Time
+		}
+		journal = append(journal, entry(first, GLCash, money.Round(ratFloat(opening), currency, money.HalfEven)))
+	}
+	for _, tx := range history {
+		amount := tx.Amount
+		if !tx.Type.IsCredit() {
+			amount = -amount
+		}
+		journal = append(journal, entry(tx, b.counterGL(tx), amount))
+	}
+	return journal
+}
+
+// counterGL is the account of the books an entry is booked against.
+func (b *Bank) counterGL(tx Transaction) string {
+	switch tx.Type {
+	case TransactionInterest:
+		return GLInterestExpense
+	case TransactionInterestCharge:
+		return GLInterestIncome
+	case TransactionFee:
+		return GLFeeIncome
+	}
+	if tx.Counterparty != "" && b.accounts.account(tx.Counterparty) != nil {
+		return GLClearing
+	}
+	return GLCash
+}
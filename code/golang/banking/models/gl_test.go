@@ -0,0 +1,86 @@
+package models
+
+import (
+	"io"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestJournal checks what each kind of entry posts to the bank's books:
+// opening balances, deposits and withdrawals against cash, transfers
+// through clearing, fees, interest paid on savings and charged on loans.
+func TestJournal(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Clock = &testClock{now: date(t, "2026-02-01")}
+	if err := b.Products.Define(Product{Code: "fee", Name: "Fee Checking", Kind: ProductChecking, MonthlyFee: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.OpenAccount("fee", "", "C1"); err != nil {
+		t.Fatal(err)
+	}
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1", Balance: 50}, InterestRate: money.Percent(12)})
+	b.Open(&LoanAccount{Account: Account{AccountNumber: "L1", Balance: -1200}, Principal: 1200,
+		InterestRate: money.Percent(6), TermMonths: 12, Opened: date(t, "2026-01-01")})
+	for _, err := range []error{
+		b.Deposit("C1", 100), b.Withdraw("C1", 30), b.Transfer("C1", "S1", 20),
+		b.ApplyInterest("S1"), b.ApplyInterest("L1"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if charged := b.AssessFees(); len(charged) != 1 {
+		t.Fatalf("fees charged to %v, want C1", charged)
+	}
+	interest := func(number string) float64 {
+		history, _ := b.History(number)
+		return history[len(history)-1].Amount
+	}
+	line := func(debit, credit string, amount float64) []JournalLine {
+		return []JournalLine{{GL: debit, Debit: amount}, {GL: credit, Credit: amount}}
+	}
+
+	tests := []struct {
+		account string
+		want    [][]JournalLine
+	}{
+		{"C1", [][]JournalLine{
+			line(GLCash, GLDeposits, 100),
+			line(GLDeposits, GLCash, 30),
+			line(GLDeposits, GLClearing, 20),
+			line(GLDeposits, GLFeeIncome, 5),
+		}},
+		{"S1", [][]JournalLine{
+			line(GLCash, GLDeposits, 50),
+			line(GLClearing, GLDeposits, 20),
+			line(GLInterestExpense, GLDeposits, interest("S1")),
+		}},
+		{"L1", [][]JournalLine{
+			line(GLLoans, GLCash, 1200),
+			line(GLLoans, GLInterestIncome, interest("L1")),
+		}},
+	}
+	for _, tt := range tests {
+		journal, err := b.Journal(tt.account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(journal) != len(tt.want) {
+			t.Errorf("%s journal has %d entries, want %d: %+v", tt.account, len(journal), len(tt.want), journal)
+			continue
+		}
+		for i, e := range journal {
+			if len(e.Lines) != 2 || e.Lines[0] != tt.want[i][0] || e.Lines[1] != tt.want[i][1] {
+				t.Errorf("%s entry %d (%s) lines %+v, want %+v", tt.account, i, e.Type, e.Lines, tt.want[i])
+			}
+		}
+	}
+	if journal, _ := b.Journal("S1"); journal[0].Sequence != 0 || journal[1].Sequence == 0 {
+		t.Errorf("sequences %d and %d, want the opening balance at 0", journal[0].Sequence, journal[1].Sequence)
+	}
+	if interest("S1") <= 0 || interest("L1") <= 0 {
+		t.Errorf("interest %.2f and %.2f, want some posted", interest("S1"), interest("L1"))
+	}
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrGrantNotFound = banking.New(banking.CodeNotFound, "grant not found")
+	ErrInvalidGrant  = banking.New(banking.CodeInvalidArgument, "invalid grant")
+)
+
+// MetaGrant is the metadata key of the grant an entry was made under by a
+// delegate.
+const MetaGrant = "grant"
+
+// Grant delegates access to some of a customer's accounts to someone else,
+// such as read access for an accountant or a power of attorney to transact,
+// from Start until Expires or until it is revoked. Delegates act with the
+// ID named Delegate as holders do, and everything they do under the grant
+// is recorded in the event log.
+type Grant struct {
+	ID         int        `json:"id"`
+	Grantor    string     `json:"grantor"`
+	Delegate   string     `json:"delegate"`
+	Accounts   []string   `json:"accounts"`
+	Permission Permission `json:"permission"`
+	Start      time.Time  `json:"start"`
+	Expires    time.Time  `json:"expires"`
+	Reason     string     `json:"reason,omitempty"`
+	Created    time.Time  `json:"created"`
+	Revoked    *time.Time `json:"revoked,omitempty"`
+}
+
+// activeAt reports whether g gives access at t.
+func (g Grant) activeAt(t time.Time) bool {
+	return !t.Before(g.Start) && t.Before(g.Expires) && (g.Revoked == nil || t.Before(*g.Revoked))
+}
+
+// Delegate grants access to accounts of the grantor as g describes. The
+// grantor must be an admin of each of them; by is who asks, the grantor or
+// "" for the bank itself. Grants give view or transact access, never admin.
+func (b *Bank) Delegate(by string, g Grant) (Grant, error) {
+	switch {
+	case g.Delegate == "":
+		return Grant{}, fmt.Errorf("%w: no delegate", ErrInvalidGrant)
+	case g.Delegate == g.Grantor:
+		return Grant{}, fmt.Errorf("%w: %s cannot delegate to themselves", ErrInvalidGrant, g.Grantor)
+	case g.Permission != PermissionView && g.Permission != PermissionTransact:
+		return Grant{}, fmt.Errorf("%w: permission %q is not view or transact", ErrInvalidGrant, g.Permission)
+	case len(g.Accounts) == 0:
+		return Grant{}, fmt.Errorf("%w: no accounts", ErrInvalidGrant)
+	case by != "" && by != g.Grantor:
+		return Grant{}, fmt.Errorf("%w: %s cannot delegate for %s", ErrPermissionDenied, by, g.Grantor)
+	}
+	if _, err := b.Customer(g.Grantor); err != nil {
+		return Grant{}, err
+	}
+	for _, number := range g.Accounts {
+		if err := b.authorizeAdmin(number, g.Grantor); err != nil {
+			return Grant{}, err
+		}
+	}
+	now := b.now()
+	if g.Start.IsZero() {
+		g.Start = now
+	}
+	if !g.Expires.After(g.Start) || !g.Expires.After(now) {
+		return Grant{}, fmt.Errorf("%w: expires before it starts or in the past", ErrInvalidGrant)
+	}
+	g.Accounts = slices.Clone(g.Accounts)
+	slices.Sort(g.Accounts)
+	g.Accounts = slices.Compact(g.Accounts)
+
+	b.mu.Lock()
+	b.nextGrant++
+	g.ID, g.Created, g.Revoked = b.nextGrant, now, nil
+	b.grants = append(b.grants, g)
+	b.mu.Unlock()
+	for _, number := range g.Accounts {
+		b.Events.Publish(Event{Type: EventGrantCreated, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Grant %d: %s may %s until %s, given by %s", g.ID, g.Delegate, g.Permission, g.Expires.Format(time.RFC3339), g.Grantor)})
+	}
+	return g, nil
+}
+
+// Grants returns the grants a customer gave or was given, past ones
+// included, oldest first.
+func (b *Bank) Grants(id string) []Grant {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var grants []Grant
+	for _, g := range b.grants {
+		if g.Grantor == id || g.Delegate == id {
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+// RevokeGrant ends a grant before it expires, as its grantor, its delegate
+// or, with "", the bank itself.
+func (b *Bank) RevokeGrant(id int, by string) (Grant, error) {
+	now := b.now()
+	b.mu.Lock()
+	i := slices.IndexFunc(b.grants, func(g Grant) bool { return g.ID == id })
+	if i < 0 {
+		b.mu.Unlock()
+		return Grant{}, fmt.Errorf("%w: %d", ErrGrantNotFound, id)
+	}
+	g := &b.grants[i]
+	if by != "" && by != g.Grantor && by != g.Delegate {
+		b.mu.Unlock()
+		return Grant{}, fmt.Errorf("%w: %s cannot revoke grant %d", ErrPermissionDenied, by, id)
+	}
+	if g.Revoked != nil || !now.Before(g.Expires) {
+		revoked := *g
+		b.mu.Unlock()
+		return revoked, nil
+	}
+	g.Revoked = &now
+	revoked := *g
+	b.mu.Unlock()
+	for _, number := range revoked.Accounts {
+		b.Events.Publish(Event{Type: EventGrantRevoked, AccountNumber: number, Time: now,
+			Message: fmt.Sprintf("Grant %d to %s revoked", id, revoked.Delegate)})
+	}
+	return revoked, nil
+}
+
+// grantFor returns the grant delegate may access an account with as need
+// asks, ErrNotHolder when none covers it and ErrPermissionDenied when the
+// ones that do give less.
+func (b *Bank) grantFor(number, delegate string, need Permission) (*Grant, error) {
+	now := b.now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var denied *Grant
+	for _, g := range b.grants {
+		if g.Delegate != delegate || !g.activeAt(now) || !slices.Contains(g.Accounts, number) {
+			continue
+		}
+		// The grantor must still be an admin of the account.
+		if !b.holdsLocked(number, g.Grantor) || b.holderLocked(number, g.Grantor).Permission != PermissionAdmin {
+			continue
+		}
+		if g.Permission.Allows(need) {
+			return &g, nil
+		}
+		denied = &g
+	}
+	if denied != nil {
+		return nil, fmt.Errorf("%w: grant %d lets %s %s %s, not %s", ErrPermissionDenied, denied.ID, delegate, denied.Permission, number, need)
+	}
+	return nil, fmt.Errorf("%w: %s of %s", ErrNotHolder, delegate, number)
+}
+
+// auditDelegated records in the event log that the delegate of g did action
+// on an account.
+func (b *Bank) auditDelegated(g Grant, number, action string) {
+	if action = strings.TrimSpace(action); action == "" {
+		action = string(g.Permission)
+	}
+	b.Events.Publish(Event{Type: EventDelegatedAccess, AccountNumber: number, Time: b.now(),
+		Message: fmt.Sprintf("%s under grant %d from %s: %s", g.Delegate, g.ID, g.Grantor, action)})
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// grantBank returns a bank whose customer c1 holds C1 with 100 in it, c2 and
+// c3 hold nothing, and holder permissions are enforced, with the count of
+// delegated accesses it publishes.
+func grantBank(t *testing.T, clock *testClock) (*Bank, *int) {
+	t.Helper()
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Clock = clock
+	b.Use(b.AuthorizeHolders())
+	if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}}); err != nil {
+		t.Fatal(err)
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	b.AddCustomer(&Customer{ID: "c2", Name: "Grace"})
+	b.AddCustomer(&Customer{ID: "c3", Name: "Alan"})
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	delegated := 0
+	b.Events.Subscribe(func(e Event) {
+		if e.Type == EventDelegatedAccess {
+			delegated++
+		}
+	})
+	return b, &delegated
+}
+
+// TestGrantExpiry checks that a grant gives access from its start until it
+// expires and no more than its permission, and that entries made under it
+// carry its ID.
+func TestGrantExpiry(t *testing.T) {
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b, delegated := grantBank(t, clock)
+	if _, err := b.Delegate("c1", Grant{Grantor: "c1", Delegate: "c2", Accounts: []string{"C1"}, Permission: PermissionTransact,
+		Expires: date(t, "2025-12-31")}); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("grant expiring in the past: %v, want %v", err, ErrInvalidGrant)
+	}
+	if _, err := b.Delegate("c1", Grant{Grantor: "c1", Delegate: "c2", Accounts: []string{"C1"}, Permission: PermissionAdmin,
+		Expires: date(t, "2026-01-10")}); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("admin grant: %v, want %v", err, ErrInvalidGrant)
+	}
+	grant, err := b.Delegate("c1", Grant{Grantor: "c1", Delegate: "c2", Accounts: []string{"C1"}, Permission: PermissionTransact,
+		Start: date(t, "2026-01-02"), Expires: date(t, "2026-01-10")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Withdraw("C1", 10, ByHolder("c2")); !errors.Is(err, ErrNotHolder) {
+		t.Errorf("withdrawal before the grant starts: %v, want %v", err, ErrNotHolder)
+	}
+	clock.now = date(t, "2026-01-05")
+	if err := b.Withdraw("C1", 10, ByHolder("c2")); err != nil {
+		t.Fatalf("withdrawal under the grant: %v", err)
+	}
+	history, _ := b.History("C1")
+	if last := history[len(history)-1]; last.Metadata[MetaGrant] != "1" || last.Metadata[MetaHolder] != "c2" {
+		t.Errorf("entry metadata %v, want grant 1 by c2", last.Metadata)
+	}
+	if err := b.Authorize("C1", "c2", PermissionAdmin, "add a holder"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("admin access under a transact grant: %v, want %v", err, ErrPermissionDenied)
+	}
+	if *delegated != 1 {
+		t.Errorf("published %d delegated accesses, want 1", *delegated)
+	}
+
+	clock.now = grant.Expires
+	if err := b.Withdraw("C1", 10, ByHolder("c2")); !errors.Is(err, ErrNotHolder) {
+		t.Errorf("withdrawal when the grant expires: %v, want %v", err, ErrNotHolder)
+	}
+	if expired, err := b.RevokeGrant(grant.ID, "c1"); err != nil || expired.Revoked != nil {
+		t.Errorf("revoking an expired grant = %+v, %v, want it left as it was", expired, err)
+	}
+	if balance, _ := b.Balance("C1"); balance != 90 {
+		t.Errorf("C1 balance = %.2f, want 90", balance)
+	}
+}
+
+// TestRevokeGrant checks that only the grantor, the delegate or the bank
+// may revoke a grant, that revoking ends access at once, and that revoking
+// it again changes nothing.
+func TestRevokeGrant(t *testing.T) {
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b, _ := grantBank(t, clock)
+	revocations := 0
+	b.Events.Subscribe(func(e Event) {
+		if e.Type == EventGrantRevoked {
+			revocations++
+		}
+	})
+	grant, err := b.Delegate("", Grant{Grantor: "c1", Delegate: "c2", Accounts: []string{"C1"}, Permission: PermissionView,
+		Expires: date(t, "2026-02-01")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Authorize("C1", "c2", PermissionView, "read the balance"); err != nil {
+		t.Errorf("view access under the grant: %v", err)
+	}
+	if err := b.Withdraw("C1", 10, ByHolder("c2")); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("withdrawal under a view grant: %v, want %v", err, ErrPermissionDenied)
+	}
+
+	if _, err := b.RevokeGrant(grant.ID, "c3"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("revoking someone else's grant: %v, want %v", err, ErrPermissionDenied)
+	}
+	if _, err := b.RevokeGrant(99, ""); !errors.Is(err, ErrGrantNotFound) {
+		t.Errorf("revoking an unknown grant: %v, want %v", err, ErrGrantNotFound)
+	}
+	clock.now = date(t, "2026-01-03")
+	revoked, err := b.RevokeGrant(grant.ID, "c2")
+	if err != nil || revoked.Revoked == nil || !revoked.Revoked.Equal(clock.now) {
+		t.Fatalf("revoking as the delegate = %+v, %v", revoked, err)
+	}
+	if err := b.Authorize("C1", "c2", PermissionView, "read the balance"); !errors.Is(err, ErrNotHolder) {
+		t.Errorf("view access after revoking: %v, want %v", err, ErrNotHolder)
+	}
+
+	clock.now = date(t, "2026-01-04")
+	again, err := b.RevokeGrant(grant.ID, "c1")
+	if err != nil || !again.Revoked.Equal(*revoked.Revoked) {
+		t.Errorf("revoking again = %+v, %v, want the first revocation kept", again, err)
+	}
+	if revocations != 1 {
+		t.Errorf("published %d revocations, want 1", revocations)
+	}
+	if grants := b.Grants("c2"); len(grants) != 1 || grants[0].Revoked == nil {
+		t.Errorf("grants of c2 = %+v, want the revoked one", grants)
+	}
+}
@@ -0,0 +1,431 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrNotHolder          = banking.New(banking.CodePermissionDenied, "not a holder of the account")
+	ErrPermissionDenied   = banking.New(banking.CodePermissionDenied, "holder's permission does not allow this")
+	ErrHolderLimit        = banking.New(banking.CodeLimitExceeded, "holder's daily limit reached")
+	ErrInvalidHolder      = banking.New(banking.CodeInvalidArgument, "invalid holder")
+	ErrLastAdmin          = banking.New(banking.CodeConflict, "an account needs an admin holder")
+	ErrInvitationNotFound = banking.New(banking.CodeNotFound, "invitation not found")
+	ErrInvitationExpired  = banking.New(banking.CodeConflict, "invitation expired")
+)
+
+// Permission is what a holder of an account may do with it. Each permission
+// includes the ones before it.
+type Permission string
+
+const (
+	// PermissionView sees the account's balance and ledger.
+	PermissionView Permission = "view"
+	// PermissionTransact also deposits, withdraws and transfers.
+	PermissionTransact Permission = "transact"
+	// PermissionAdmin also invites, changes and removes holders.
+	PermissionAdmin Permission = "admin"
+)
+
+var permissionRanks = map[Permission]int{PermissionView: 1, PermissionTransact: 2, PermissionAdmin: 3}
+
+// Allows reports whether a holder with p may do what needs need.
+func (p Permission) Allows(need Permission) bool {
+	return permissionRanks[p] >= permissionRanks[need] && permissionRanks[need] > 0
+}
+
+// InvitationTTL is how long an invitation to hold an account can be
+// accepted.
+const InvitationTTL = 7 * 24 * time.Hour
+
+// MetaHolder is the metadata key of the customer who made a transaction on
+// an account, as ByHolder sets it.
+const MetaHolder = "holder"
+
+// ByHolder makes an op on behalf of a holder of the account, whose
+// permission and daily limit AuthorizeHolders enforces. Ops made otherwise
+// are the bank's own.
+func ByHolder(customerID string) TxOption {
+	return WithMetadata(MetaHolder, customerID)
+}
+
+// Holder is a customer who holds an account and what they may do with it:
+// their Permission and, when set, DailyLimit, the most they may withdraw or
+// transfer out of it in a day. The customers an account was opened for are
+// admins without a limit until an admin says otherwise.
+type Holder struct {
+	Customer   string     `json:"customer"`
+	Permission Permission `json:"permission"`
+	DailyLimit float64    `json:"daily_limit,omitempty"`
+}
+
+func (h Holder) validate() error {
+	if _, ok := permissionRanks[h.Permission]; !ok {
+		return fmt.Errorf("%w: permission %q is not view, transact or admin", ErrInvalidHolder, h.Permission)
+	}
+	if h.DailyLimit < 0 {
+		return fmt.Errorf("%w: daily limit must not be negative", ErrInvalidHolder)
+	}
+	return nil
+}
+
+// Invitation asks Customer to hold Account as Holder describes. They become
+// a holder when they accept it, until Expires.
+type Invitation struct {
+	ID        string    `json:"id"`
+	Account   string    `json:"account"`
+	Holder    Holder    `json:"holder"`
+	InvitedBy string    `json:"invited_by,omitempty"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// Holders returns the holders of an account ordered by customer ID.
+func (b *Bank) Holders(number string) ([]Holder, error) {
+	if _, err := b.Account(number); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var holders []Holder
+	for _, customer := range b.ownersLocked(number) {
+		holders = append(holders, b.holderLocked(number, customer.ID))
+	}
+	slices.SortFunc(holders, func(a, b Holder) int { return strings.Compare(a.Customer, b.Customer) })
+	return holders, nil
+}
+
+// Holder returns how customerID holds an account, ErrNotHolder when they
+// do not.
+func (b *Bank) Holder(number, customerID string) (Holder, error) {
+	if _, err := b.Account(number); err != nil {
+		return Holder{}, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.holdsLocked(number, customerID) {
+		return Holder{}, fmt.Errorf("%w: %s of %s", ErrNotHolder, customerID, number)
+	}
+	return b.holderLocked(number, customerID), nil
+}
+
+// Authorize checks that customerID holds an account with a permission that
+// allows need, or has been granted one; see Grant. Delegated access is
+// recorded in the event log with action, what it was for.
+func (b *Bank) Authorize(number, customerID string, need Permission, action string) error {
+	grant, err := b.authorize(number, customerID, need)
+	if err != nil {
+		return err
+	}
+	if grant != nil {
+		b.auditDelegated(*grant, number, action)
+	}
+	return nil
+}
+
+// authorize is Authorize without the audit. It returns the grant access is
+// delegated by, nil for a holder.
+func (b *Bank) authorize(number, customerID string, need Permission) (*Grant, error) {
+	h, err := b.Holder(number, customerID)
+	if errors.Is(err, ErrNotHolder) {
+		if grant, grantErr := b.grantFor(number, customerID, need); !errors.Is(grantErr, ErrNotHolder) {
+			return grant, grantErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !h.Permission.Allows(need) {
+		return nil, fmt.Errorf("%w: %s may %s %s, not %s", ErrPermissionDenied, customerID, h.Permission, number, need)
+	}
+	return nil, nil
+}
+
+// SetHolder changes the permission and limit of a holder of an account, as
+// admin by, "" for the bank itself. The last admin cannot be demoted.
+func (b *Bank) SetHolder(number, by string, h Holder) error {
+	if err := h.validate(); err != nil {
+		return err
+	}
+	if err := b.authorizeAdmin(number, by); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	if !b.holdsLocked(number, h.Customer) {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s of %s", ErrNotHolder, h.Customer, number)
+	}
+	if h.Permission != PermissionAdmin && b.lastAdminLocked(number, h.Customer) {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s is the only admin of %s", ErrLastAdmin, h.Customer, number)
+	}
+	b.setHolderLocked(number, h)
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventHolderChanged, AccountNumber: number, Time: b.now(),
+		Message: fmt.Sprintf("Holder %s: %s", h.Customer, describeHolder(h))})
+	return nil
+}
+
+// RemoveHolder removes customerID from the holders of an account, as admin
+// by, "" for the bank itself. Holders may also remove themselves. The last
+// admin cannot be removed.
+func (b *Bank) RemoveHolder(number, by, customerID string) error {
+	if by != customerID {
+		if err := b.authorizeAdmin(number, by); err != nil {
+			return err
+		}
+	} else if _, err := b.Holder(number, customerID); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	customer := b.customers[customerID]
+	if customer == nil || !b.holdsLocked(number, customerID) {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s of %s", ErrNotHolder, customerID, number)
+	}
+	if b.lastAdminLocked(number, customerID) {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s is the only admin of %s", ErrLastAdmin, customerID, number)
+	}
+	customer.Accounts = slices.DeleteFunc(slices.Clone(customer.Accounts), func(n string) bool { return n == number })
+	delete(b.holders[number], customerID)
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventHolderRemoved, AccountNumber: number, Time: b.now(),
+		Message: fmt.Sprintf("Holder %s removed", customerID)})
+	return nil
+}
+
+// Invite invites a customer to hold an account as h describes, as admin
+// by, "" for the bank itself. The invitation is accepted with
+// AcceptInvitation.
+func (b *Bank) Invite(number, by string, h Holder) (Invitation, error) {
+	if err := h.validate(); err != nil {
+		return Invitation{}, err
+	}
+	if err := b.authorizeAdmin(number, by); err != nil {
+		return Invitation{}, err
+	}
+	if _, err := b.Customer(h.Customer); err != nil {
+		return Invitation{}, err
+	}
+	now := b.now()
+	inv := Invitation{ID: b.NewID(), Account: number, Holder: h, InvitedBy: by, Created: now, Expires: now.Add(InvitationTTL)}
+
+	b.mu.Lock()
+	if b.holdsLocked(number, h.Customer) {
+		b.mu.Unlock()
+		return Invitation{}, fmt.Errorf("%w: %s already holds %s", ErrInvalidHolder, h.Customer, number)
+	}
+	for id, old := range b.invitations {
+		if !now.Before(old.Expires) {
+			delete(b.invitations, id)
+		}
+	}
+	b.invitations[inv.ID] = inv
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventHolderInvited, AccountNumber: number, Time: now,
+		Message: fmt.Sprintf("%s invited to hold the account: %s", h.Customer, describeHolder(h))})
+	return inv, nil
+}
+
+// Invitations returns the invitations a customer has not answered yet,
+// oldest first.
+func (b *Bank) Invitations(customerID string) []Invitation {
+	now := b.now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var invitations []Invitation
+	for _, inv := range b.invitations {
+		if inv.Holder.Customer == customerID && now.Before(inv.Expires) {
+			invitations = append(invitations, inv)
+		}
+	}
+	slices.SortFunc(invitations, func(a, b Invitation) int { return a.Created.Compare(b.Created) })
+	return invitations
+}
+
+// AcceptInvitation makes the customer invited a holder of the account, as
+// the invitation describes.
+func (b *Bank) AcceptInvitation(id, customerID string) (Holder, error) {
+	now := b.now()
+	b.mu.Lock()
+	inv, ok := b.invitations[id]
+	if !ok || inv.Holder.Customer != customerID {
+		b.mu.Unlock()
+		return Holder{}, fmt.Errorf("%w: %s", ErrInvitationNotFound, id)
+	}
+	delete(b.invitations, id)
+	if !now.Before(inv.Expires) {
+		b.mu.Unlock()
+		return Holder{}, fmt.Errorf("%w: %s expired at %s", ErrInvitationExpired, id, inv.Expires.Format(time.RFC3339))
+	}
+	customer := b.customers[customerID]
+	if customer == nil || b.accounts.account(inv.Account) == nil {
+		b.mu.Unlock()
+		return Holder{}, fmt.Errorf("%w: %s no longer applies", ErrInvitationNotFound, id)
+	}
+	if !b.holdsLocked(inv.Account, customerID) {
+		customer.Accounts = append(customer.Accounts, inv.Account)
+	}
+	b.setHolderLocked(inv.Account, inv.Holder)
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventHolderAdded, AccountNumber: inv.Account, Time: now,
+		Message: fmt.Sprintf("Holder %s added: %s", customerID, describeHolder(inv.Holder))})
+	return inv.Holder, nil
+}
+
+// DeclineInvitation withdraws an invitation, as the customer invited or an
+// admin of the account, "" for the bank itself.
+func (b *Bank) DeclineInvitation(id, by string) (Invitation, error) {
+	b.mu.RLock()
+	inv, ok := b.invitations[id]
+	b.mu.RUnlock()
+	if !ok {
+		return Invitation{}, fmt.Errorf("%w: %s", ErrInvitationNotFound, id)
+	}
+	if by != inv.Holder.Customer {
+		if err := b.authorizeAdmin(inv.Account, by); err != nil {
+			return Invitation{}, err
+		}
+	}
+	b.mu.Lock()
+	delete(b.invitations, id)
+	b.mu.Unlock()
+	return inv, nil
+}
+
+// AuthorizeHolders is middleware that enforces the permissions and daily
+// limits of holders on the ops made by them with ByHolder: deposits,
+// withdrawals and transfers out need PermissionTransact, and withdrawals
+// and transfers count against the holder's limit on the account. Ops of a
+// delegate are recorded in the event log, and their entries carry the ID
+// of the grant as grant metadata. Transfers made in a session, see
+// BySession, over the bank's StepUpAmount need it stepped up.
+func (b *Bank) AuthorizeHolders() Middleware {
+	return func(next Operation) Operation {
+		return func(op Op) error {
+			var tx Transaction
+			for _, opt := range op.Options {
+				opt(&tx)
+			}
+			customerID := tx.Metadata[MetaHolder]
+			if customerID == "" {
+				return next(op)
+			}
+			grant, err := b.authorize(op.Account, customerID, PermissionTransact)
+			if err != nil {
+				return err
+			}
+			if err := b.stepUpNeeded(op, tx.Metadata[MetaSession]); err != nil {
+				return err
+			}
+			if grant != nil {
+				action := fmt.Sprintf("%s of %.2f", op.Kind, op.Amount)
+				if op.Kind == OpTransfer {
+					action += " to " + op.To
+				}
+				b.auditDelegated(*grant, op.Account, action)
+				op.Options = append(op.Options[:len(op.Options):len(op.Options)], WithMetadata(MetaGrant, strconv.Itoa(grant.ID)))
+				return next(op)
+			}
+			if op.Kind != OpDeposit {
+				if err := b.checkHolderLimit(op, customerID); err != nil {
+					return err
+				}
+			}
+			return next(op)
+		}
+	}
+}
+
+// checkHolderLimit checks an op of a holder against their daily limit.
+func (b *Bank) checkHolderLimit(op Op, customerID string) error {
+	h, _ := b.Holder(op.Account, customerID)
+	if h.DailyLimit == 0 {
+		return nil
+	}
+	history, err := b.History(op.Account)
+	if err != nil {
+		return err
+	}
+	now := b.now().In(b.TimeZone(op.Account))
+	y, m, d := now.Date()
+	spent := op.Amount
+	for _, t := range history {
+		if ty, tm, td := t.Time.In(now.Location()).Date(); !t.Type.IsCredit() && t.Metadata[MetaHolder] == customerID && ty == y && tm == m && td == d {
+			spent += t.Amount
+		}
+	}
+	if spent > h.DailyLimit {
+		return fmt.Errorf("%w: %s may move %.2f a day out of %s", ErrHolderLimit, customerID, h.DailyLimit, op.Account)
+	}
+	return nil
+}
+
+// authorizeAdmin checks that by is an admin of an account, or "" for the
+// bank itself.
+func (b *Bank) authorizeAdmin(number, by string) error {
+	if by == "" {
+		_, err := b.Account(number)
+		return err
+	}
+	_, err := b.authorize(number, by, PermissionAdmin)
+	return err
+}
+
+// holdsLocked reports whether customerID holds an account. The caller holds
+// b.mu.
+func (b *Bank) holdsLocked(number, customerID string) bool {
+	customer := b.customers[customerID]
+	return customer != nil && slices.Contains(customer.Accounts, number)
+}
+
+// holderLocked returns how customerID, who holds an account, holds it. The
+// caller holds b.mu.
+func (b *Bank) holderLocked(number, customerID string) Holder {
+	if h, ok := b.holders[number][customerID]; ok {
+		return h
+	}
+	return Holder{Customer: customerID, Permission: PermissionAdmin}
+}
+
+// setHolderLocked records h, keeping only what differs from the default.
+// The caller holds b.mu.
+func (b *Bank) setHolderLocked(number string, h Holder) {
+	if h.Permission == PermissionAdmin && h.DailyLimit == 0 {
+		delete(b.holders[number], h.Customer)
+		return
+	}
+	if b.holders[number] == nil {
+		b.holders[number] = make(map[string]Holder)
+	}
+	b.holders[number][h.Customer] = h
+}
+
+// lastAdminLocked reports whether customerID is the only admin among the
+// holders of an account. The caller holds b.mu.
+func (b *Bank) lastAdminLocked(number, customerID string) bool {
+	if b.holderLocked(number, customerID).Permission != PermissionAdmin {
+		return false
+	}
+	for _, customer := range b.ownersLocked(number) {
+		if customer.ID != customerID && b.holderLocked(number, customer.ID).Permission == PermissionAdmin {
+			return false
+		}
+	}
+	return true
+}
+
+func describeHolder(h Holder) string {
+	if h.DailyLimit > 0 {
+		return fmt.Sprintf("%s, up to %.2f a day", h.Permission, h.DailyLimit)
+	}
+	return string(h.Permission)
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[78][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// TestUUIDv7 makes IDs on a clock that stands still and then goes back, and
+// checks they are version 7 UUIDs that still sort in the order made.
+func TestUUIDv7(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	g := &UUIDv7{Clock: clock}
+	var last string
+	for i := 0; i < 5000; i++ {
+		if i == 4000 {
+			clock.now = clock.now.Add(-time.Hour)
+		}
+		id := g.NewID()
+		if !uuidPattern.MatchString(id) || id[14] != '7' {
+			t.Fatalf("%q is not a version 7 UUID", id)
+		}
+		if id <= last {
+			t.Fatalf("ID %d, %s, sorts before %s", i+1, id, last)
+		}
+		last = id
+	}
+}
+
+// TestDeterministicIDs checks the same seed makes the same IDs and another
+// seed others.
+func TestDeterministicIDs(t *testing.T) {
+	a, b, c := &DeterministicIDs{Seed: 7}, &DeterministicIDs{Seed: 7}, &DeterministicIDs{Seed: 8}
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := a.NewID()
+		if !uuidPattern.MatchString(id) || id[14] != '8' {
+			t.Fatalf("%q is not a version 8 UUID", id)
+		}
+		if other := b.NewID(); other != id {
+			t.Fatalf("ID %d differs with the same seed: %s and %s", i+1, id, other)
+		}
+		if other := c.NewID(); other == id {
+			t.Fatalf("ID %d is the same with another seed", i+1)
+		}
+		if seen[id] {
+			t.Fatalf("%s made twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+// TestEntryIDs checks the bank gives every entry an ID from its IDs, both
+// legs of a transfer the same transfer ID, and that the IDs survive a
+// snapshot.
+func TestEntryIDs(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.IDs = &SequentialIDs{Prefix: "id-"}
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer("C1", "C2", 40); err != nil {
+		t.Fatal(err)
+	}
+	from, _ := b.Account("C1")
+	to, _ := b.Account("C2")
+	deposit, out, in := from.History()[0], from.History()[1], to.History()[0]
+	if deposit.ID != "id-1" || out.ID != "id-3" || in.ID != "id-4" {
+		t.Errorf("entry IDs %q, %q and %q, want id-1, id-3 and id-4", deposit.ID, out.ID, in.ID)
+	}
+	if out.Metadata[MetaTransferID] != "id-2" || in.Metadata[MetaTransferID] != "id-2" {
+		t.Errorf("transfer legs carry transfer IDs %q and %q, want id-2", out.Metadata[MetaTransferID], in.Metadata[MetaTransferID])
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := restored.Account("C2")
+	if got := account.History()[0].ID; got != "id-4" {
+		t.Errorf("restored entry ID %q, want id-4", got)
+	}
+}
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+)
+
+// DefaultMessageRetention is how long messages stay in an inbox unless the
+// bank's MessageRetention says.
+const DefaultMessageRetention = 180 * 24 * time.Hour
+
+var (
+	ErrMessageNotFound = banking.New(banking.CodeNotFound, "message not found")
+	ErrInvalidMessage  = banking.New(banking.CodeInvalidArgument, "invalid message")
+)
+
+// MessageKind is what a message is about.
+type MessageKind string
+
+const (
+	// MessageRateChange tells of a scheduled change of an account's rate,
+	// sent when its notice is due.
+	MessageRateChange MessageKind = "rate_change"
+	// MessageStatementReady tells that a statement period of an account
+	// closed, sent by NotifyStatements.
+	MessageStatementReady MessageKind = "statement_ready"
+	// MessageRewardsStatement tells what an account earned and redeemed
+	// in rewards over a month, sent by NotifyRewardsStatements.
+	MessageRewardsStatement MessageKind = "rewards_statement"
+	// MessageFraudReview asks the customer to confirm or deny activity the
+	// bank's staff is reviewing.
+	MessageFraudReview MessageKind = "fraud_review"
+	// MessageAlert is an alert rule of an account firing, sent by
+	// NotifyAlert.
+	MessageAlert MessageKind = "alert"
+	// MessageNotice is anything else the bank tells a customer.
+	MessageNotice MessageKind = "notice"
+)
+
+func (k MessageKind) valid() bool {
+	return k == MessageRateChange || k == MessageStatementReady || k == MessageRewardsStatement || k == MessageFraudReview || k == MessageAlert || k == MessageNotice
+}
+
+// Message is a message in a customer's inbox, about one of their accounts
+// when Account is set. Key names what it is about, so the same notice sent
+// twice lands once. Read is when the customer read it, nil while unread, and
+// Expires when it leaves the inbox, read or not. Deliveries are the attempts
+// to deliver it through the bank's Channels.
+type Message struct {
+	ID         string      `json:"id"`
+	Customer   string      `json:"customer"`
+	Kind       MessageKind `json:"kind"`
+	Account    string      `json:"account,omitempty"`
+	Subject    string      `json:"subject"`
+	Body       string      `json:"body,omitempty"`
+	Key        string      `json:"key,omitempty"`
+	Created    time.Time   `json:"created"`
+	Read       *time.Time  `json:"read,omitempty"`
+	Expires    time.Time   `json:"expires"`
+	Deliveries []Delivery  `json:"deliveries,omitempty"`
+}
+
+// Delivery is one attempt to deliver a message through a channel, failed
+// when Error is set.
+type Delivery struct {
+	Channel string    `json:"channel"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// MessageChannel delivers messages outside the app, such as by email, SMS
+// or push notification.
+type MessageChannel interface {
+	Name() string
+	Deliver(Message) error
+}
+
+func (m *Message) clone() Message {
+	clone := *m
+	clone.Deliveries = append([]Delivery(nil), m.Deliveries...)
+	return clone
+}
+
+// SendMessage puts a message in its customer's inbox and delivers it
+// through every channel of the bank, returning it as delivered. A message
+// with the Key of one still in the inbox is not sent again; the one there is
+// returned.
+func (b *Bank) SendMessage(m Message) (Message, error) {
+	if !m.Kind.valid() {
+		return Message{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidMessage, m.Kind)
+	}
+	if m.Subject == "" {
+		return Message{}, fmt.Errorf("%w: subject is required", ErrInvalidMessage)
+	}
+	now := b.now()
+	b.mu.Lock()
+	if _, ok := b.customers[m.Customer]; !ok {
+		b.mu.Unlock()
+		return Message{}, ErrCustomerNotFound
+	}
+	if m.Key != "" {
+		for _, sent := range b.messages[m.Customer] {
+			if sent.Key == m.Key {
+				b.mu.Unlock()
+				return sent.clone(), nil
+			}
+		}
+	}
+	retention := b.MessageRetention
+	if retention <= 0 {
+		retention = DefaultMessageRetention
+	}
+	msg := &Message{ID: b.IDs.NewID(), Customer: m.Customer, Kind: m.Kind, Account: m.Account, Subject: m.Subject, Body: m.Body,
+		Key: m.Key, Created: now, Expires: now.Add(retention)}
+	b.messages[m.Customer] = append(b.messages[m.Customer], msg)
+	sent := msg.clone()
+	b.mu.Unlock()
+
+	// Channels may be slow; they are not called holding the bank's lock.
+	var deliveries []Delivery
+	for _, c := range b.Channels {
+		d := Delivery{Channel: c.Name(), Time: b.now()}
+		if err := c.Deliver(sent); err != nil {
+			d.Error = err.Error()
+		}
+		deliveries = append(deliveries, d)
+	}
+	b.mu.Lock()
+	msg.Deliveries = append(msg.Deliveries, deliveries...)
+	sent = msg.clone()
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventMessageSent, AccountNumber: sent.Account, Time: now,
+		Message: fmt.Sprintf("Message %s to %s: %s", sent.ID, sent.Customer, sent.Subject)})
+	return sent, nil
+}
+
+// Messages returns the messages in a customer's inbox, newest first, and
+// how many are unread. unreadOnly leaves out those read.
+func (b *Bank) Messages(customer string, unreadOnly bool) (messages []Message, unread int, err error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if _, ok := b.customers[customer]; !ok {
+		return nil, 0, ErrCustomerNotFound
+	}
+	messages = []Message{}
+	inbox := b.messages[customer]
+	for i := len(inbox) - 1; i >= 0; i-- {
+		if m := inbox[i]; m.Read == nil {
+			unread++
+			messages = append(messages, m.clone())
+		} else if !unreadOnly {
+			messages = append(messages, m.clone())
+		}
+	}
+	return messages, unread, nil
+}
+
+// MarkMessage marks a message of a customer read, or unread again.
+func (b *Bank) MarkMessage(customer, id string, read bool) (Message, error) {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, m := range b.messages[customer] {
+		if m.ID != id {
+			continue
+		}
+		switch {
+		case !read:
+			m.Read = nil
+		case m.Read == nil:
+			m.Read = &now
+		}
+		return m.clone(), nil
+	}
+	return Message{}, ErrMessageNotFound
+}
+
+// DeleteMessage removes a message from a customer's inbox.
+func (b *Bank) DeleteMessage(customer, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inbox := b.messages[customer]
+	for i, m := range inbox {
+		if m.ID == id {
+			b.messages[customer] = append(inbox[:i:i], inbox[i+1:]...)
+			return nil
+		}
+	}
+	return ErrMessageNotFound
+}
+
+// ExpireMessages removes the messages past their retention from every
+// inbox and returns how many it removed.
+func (b *Bank) ExpireMessages() int {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	expired := 0
+	for customer, inbox := range b.messages {
+		kept := inbox[:0]
+		for _, m := range inbox {
+			if now.Before(m.Expires) {
+				kept = append(kept, m)
+			}
+		}
+		expired += len(inbox) - len(kept)
+		if len(kept) == 0 {
+			delete(b.messages, customer)
+		} else {
+			b.messages[customer] = kept
+		}
+	}
+	return expired
+}
+
+// NotifyStatements tells the holders of every open account that the
+// statement of its last closed period is ready, once per period, and
+// returns the messages sent. Accounts with no entries by the end of the
+// period are skipped.
+func (b *Bank) NotifyStatements() []Message {
+	now := b.now()
+	var due []Message
+	b.mu.RLock()
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		if !b.closed[number].IsZero() {
+			continue
+		}
+		// The period that closed when the current one started.
+		cycle := b.cycleLocked(account)
+		period := cycle.Period(cycle.Period(now).Start)
+		history := account.History()
+		// There is no period before the first.
+		if period.End.After(now) || !period.Start.Before(period.End) || len(history) == 0 || history[0].Time.After(period.End) {
+			continue
+		}
+		for _, c := range b.ownersLocked(number) {
+			due = append(due, Message{Customer: c.ID, Kind: MessageStatementReady, Account: number,
+				Subject: fmt.Sprintf("Your statement for %s is ready", number),
+				Body:    fmt.Sprintf("The statement of %s from %s to %s is ready.", number, period.Start.Format(time.DateOnly), period.End.Format(time.DateOnly)),
+				Key:     fmt.Sprintf("statement:%s:%s", number, period.End.Format(time.DateOnly))})
+		}
+	}
+	b.mu.RUnlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].Key+due[i].Customer < due[j].Key+due[j].Customer })
+
+	var sent []Message
+	for _, m := range due {
+		if b.inboxHas(m.Customer, m.Key) {
+			continue
+		}
+		if m, err := b.SendMessage(m); err == nil {
+			sent = append(sent, m)
+		}
+	}
+	return sent
+}
+
+// inboxHas reports whether a customer's inbox holds a message with a key.
+func (b *Bank) inboxHas(customer, key string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, m := range b.messages[customer] {
+		if m.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// inboxEvent puts the notices of events in the inboxes of the customers they
+// concern: rate change notices in those of the account's holders.
+func (b *Bank) inboxEvent(e Event) {
+	if e.Type != EventRateChangeNotice {
+		return
+	}
+	b.mu.RLock()
+	owners := b.ownersLocked(e.AccountNumber)
+	b.mu.RUnlock()
+	for _, c := range owners {
+		b.SendMessage(Message{Customer: c.ID, Kind: MessageRateChange, Account: e.AccountNumber,
+			Subject: fmt.Sprintf("The rate of %s is changing", e.AccountNumber), Body: e.Message,
+			Key: fmt.Sprintf("rate:%s:%s", e.AccountNumber, e.Time.Format(time.RFC3339Nano))})
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+type failingChannel struct{ sent []Message }
+
+func (c *failingChannel) Name() string { return "sms" }
+
+func (c *failingChannel) Deliver(m Message) error {
+	c.sent = append(c.sent, m)
+	return errors.New("no phone number")
+}
+
+// TestInbox checks that a rate change notice and a closed statement period
+// land once in the holder's inbox and go out through the bank's channels,
+// that read state survives a snapshot and that messages expire.
+func TestInbox(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	channel := &failingChannel{}
+	b.Channels = []MessageChannel{channel}
+	b.MessageRetention = 30 * 24 * time.Hour
+	if _, err := b.OpenAccount("savings", "", "S1"); err != nil {
+		t.Fatal(err)
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"S1"}})
+	if err := b.Deposit("S1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.SendMessage(Message{Customer: "c2", Kind: MessageNotice, Subject: "Hello"}); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("sending to an unknown customer: %v, want %v", err, ErrCustomerNotFound)
+	}
+	if _, err := b.SendMessage(Message{Customer: "c1", Kind: "spam", Subject: "Hello"}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("sending an unknown kind: %v, want %v", err, ErrInvalidMessage)
+	}
+
+	if _, err := b.ScheduleRateChange("savings", money.Percent(3), date(t, "2026-01-20"), 10); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = date(t, "2026-01-15")
+	b.ApplyRateChanges()
+	if sent := b.NotifyStatements(); len(sent) != 0 {
+		t.Errorf("statements notified mid-period: %+v", sent)
+	}
+	clock.now = date(t, "2026-02-02")
+	if sent := b.NotifyStatements(); len(sent) != 1 || sent[0].Kind != MessageStatementReady {
+		t.Fatalf("statements notified after the period closed: %+v", sent)
+	}
+	if sent := b.NotifyStatements(); len(sent) != 0 {
+		t.Errorf("statement notified twice: %+v", sent)
+	}
+
+	messages, unread, err := b.Messages("c1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || unread != 2 || messages[0].Kind != MessageStatementReady || messages[1].Kind != MessageRateChange {
+		t.Fatalf("inbox %+v, %d unread", messages, unread)
+	}
+	if d := messages[0].Deliveries; len(d) != 1 || d[0].Channel != "sms" || d[0].Error == "" || len(channel.sent) != 2 {
+		t.Errorf("deliveries %+v, channel sent %d", d, len(channel.sent))
+	}
+	if _, err := b.MarkMessage("c1", messages[1].ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	restored.Clock = clock
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if messages, unread, _ := restored.Messages("c1", true); len(messages) != 1 || unread != 1 {
+		t.Errorf("unread after a restore %+v, %d unread", messages, unread)
+	}
+
+	// The notice was sent on January 15th, the statement on February 2nd.
+	clock.now = date(t, "2026-02-20")
+	if expired := restored.ExpireMessages(); expired != 1 {
+		t.Errorf("expired %d messages, want the rate change notice", expired)
+	}
+	if err := restored.DeleteMessage("c1", messages[1].ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("deleting an expired message: %v, want %v", err, ErrMessageNotFound)
+	}
+}
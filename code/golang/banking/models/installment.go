@@ -0,0 +1,321 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// MaxInstallments is the most installments a transfer may be split into.
+const MaxInstallments = 120
+
+var (
+	ErrInstallmentPlanNotFound = banking.New(banking.CodeNotFound, "installment plan not found")
+	ErrInvalidInstallmentPlan  = banking.New(banking.CodeInvalidArgument, "invalid installment plan")
+	ErrInstallmentPlanEnded    = banking.New(banking.CodeConflict, "installment plan already completed or cancelled")
+)
+
+// MetaInstallmentPlan is the metadata key of the installment plan whose
+// installment an entry pays, and MetaInstallment that of which one, from
+// 1.
+const (
+	MetaInstallmentPlan = "installment_plan"
+	MetaInstallment     = "installment"
+)
+
+type InstallmentStatus string
+
+const (
+	InstallmentScheduled InstallmentStatus = "scheduled"
+	InstallmentPaid      InstallmentStatus = "paid"
+	InstallmentCancelled InstallmentStatus = "cancelled"
+)
+
+// PlannedInstallment is one of the transfers of an installment plan, due
+// on Due. Once paid, Transaction is the ID of its withdrawal from the
+// plan's account and Transfer the transfer ID both its entries carry;
+// until then LastError is why the last of its Attempts failed, and it is
+// tried again by the next PayInstallments.
+type PlannedInstallment struct {
+	Number      int               `json:"number"`
+	Due         time.Time         `json:"due"`
+	Amount      float64           `json:"amount"`
+	Status      InstallmentStatus `json:"status"`
+	Paid        *time.Time        `json:"paid,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Transfer    string            `json:"transfer,omitempty"`
+	Attempts    int               `json:"attempts,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+}
+
+// InstallmentPlan splits a transfer of Amount from From to To into Count
+// installments, a period of Every apart from Start; the last makes up the
+// cents the others round off. ID is the plan's reference, which the
+// entries of every installment carry as installment_plan metadata. Holder
+// is the customer who made the plan, on whose behalf its installments are
+// transferred, "" for the bank. Paid and Remaining are how much of Amount
+// has been paid and is still to be; the cancelled installments of a plan
+// cancelled before the end are never paid.
+type InstallmentPlan struct {
+	ID           string               `json:"id"`
+	From         string               `json:"from"`
+	To           string               `json:"to"`
+	Amount       float64              `json:"amount"`
+	Count        int                  `json:"count"`
+	Every        Cadence              `json:"every"`
+	Start        time.Time            `json:"start"`
+	Category     string               `json:"category,omitempty"`
+	Holder       string               `json:"holder,omitempty"`
+	Created      time.Time            `json:"created"`
+	Completed    *time.Time           `json:"completed,omitempty"`
+	Cancelled    *time.Time           `json:"cancelled,omitempty"`
+	Paid         float64              `json:"paid"`
+	Remaining    float64              `json:"remaining"`
+	Installments []PlannedInstallment `json:"installments"`
+}
+
+func (p *InstallmentPlan) clone() InstallmentPlan {
+	clone := *p
+	clone.Installments = slices.Clone(p.Installments)
+	return clone
+}
+
+func (p *InstallmentPlan) active() bool {
+	return p.Completed == nil && p.Cancelled == nil
+}
+
+// PlanInstallments makes a plan to transfer p.Amount from p.From to p.To
+// in p.Count installments, monthly from now unless p.Every and p.Start
+// say, and returns it with its installments. PayInstallments transfers
+// them as they fall due.
+func (b *Bank) PlanInstallments(p InstallmentPlan) (InstallmentPlan, error) {
+	now := b.now()
+	if p.Every == "" {
+		p.Every = CadenceMonthly
+	}
+	if p.Start.IsZero() {
+		p.Start = now
+	}
+	switch {
+	case p.From == "" || p.To == "":
+		return InstallmentPlan{}, fmt.Errorf("%w: from and to are required", ErrInvalidInstallmentPlan)
+	case p.From == p.To:
+		return InstallmentPlan{}, fmt.Errorf("%w: from and to are the same account", ErrInvalidInstallmentPlan)
+	case p.Count < 2 || p.Count > MaxInstallments:
+		return InstallmentPlan{}, fmt.Errorf("%w: count must be between 2 and %d", ErrInvalidInstallmentPlan, MaxInstallments)
+	case p.Every.PerYear() == 0:
+		return InstallmentPlan{}, fmt.Errorf("%w: every must be weekly, biweekly, monthly or yearly", ErrInvalidInstallmentPlan)
+	}
+	if err := checkAmount(p.Amount); err != nil {
+		return InstallmentPlan{}, err
+	}
+	from, err := b.Account(p.From)
+	if err != nil {
+		return InstallmentPlan{}, err
+	}
+	if _, err := b.Account(p.To); err != nil {
+		return InstallmentPlan{}, err
+	}
+	each := money.Round(p.Amount/float64(p.Count), CurrencyOf(from), money.Floor)
+	if each < 0.01 {
+		return InstallmentPlan{}, fmt.Errorf("%w: %.2f is too little for %d installments", ErrInvalidInstallmentPlan, p.Amount, p.Count)
+	}
+	if p.Holder != "" {
+		if _, err := b.authorize(p.From, p.Holder, PermissionTransact); err != nil {
+			return InstallmentPlan{}, err
+		}
+	}
+	p.ID, p.Created, p.Completed, p.Cancelled = b.NewID(), now, nil, nil
+	p.Paid, p.Remaining = 0, p.Amount
+	p.Installments = make([]PlannedInstallment, p.Count)
+	for i := range p.Installments {
+		amount := each
+		if i == p.Count-1 {
+			amount = money.Round(p.Amount-each*float64(p.Count-1), CurrencyOf(from), money.HalfEven)
+		}
+		p.Installments[i] = PlannedInstallment{Number: i + 1, Due: p.Every.Add(p.Start, i), Amount: amount, Status: InstallmentScheduled}
+	}
+	stored := p.clone()
+	b.mu.Lock()
+	b.installments[p.ID] = &stored
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventInstallmentsChanged, AccountNumber: p.From, Time: now,
+		Message: fmt.Sprintf("Installment plan %s: %.2f to %s in %d %s installments from %s", p.ID, p.Amount, p.To, p.Count, p.Every, p.Start.Format(time.DateOnly))})
+	return p, nil
+}
+
+// InstallmentPlan returns an installment plan and its progress.
+func (b *Bank) InstallmentPlan(id string) (InstallmentPlan, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	p, ok := b.installments[id]
+	if !ok {
+		return InstallmentPlan{}, fmt.Errorf("%w: %s", ErrInstallmentPlanNotFound, id)
+	}
+	return p.clone(), nil
+}
+
+// InstallmentPlans returns the installment plans out of an account, ended
+// ones included, oldest first.
+func (b *Bank) InstallmentPlans(number string) []InstallmentPlan {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	plans := []InstallmentPlan{}
+	for _, p := range b.installments {
+		if p.From == number {
+			plans = append(plans, p.clone())
+		}
+	}
+	slices.SortFunc(plans, func(a, b InstallmentPlan) int {
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+		return compareIDs(a.ID, b.ID)
+	})
+	return plans
+}
+
+// compareIDs orders IDs the bank made in the order it made them, be they
+// sequential or UUIDv7s.
+func compareIDs(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// CancelInstallments cancels the installments of a plan still to be paid;
+// those paid stay paid.
+func (b *Bank) CancelInstallments(id string) (InstallmentPlan, error) {
+	now := b.now()
+	b.mu.Lock()
+	p, ok := b.installments[id]
+	if !ok {
+		b.mu.Unlock()
+		return InstallmentPlan{}, fmt.Errorf("%w: %s", ErrInstallmentPlanNotFound, id)
+	}
+	if !p.active() {
+		b.mu.Unlock()
+		return InstallmentPlan{}, ErrInstallmentPlanEnded
+	}
+	cancelled := 0
+	for i := range p.Installments {
+		if p.Installments[i].Status == InstallmentScheduled {
+			p.Installments[i].Status = InstallmentCancelled
+			cancelled++
+		}
+	}
+	p.Cancelled = &now
+	plan := p.clone()
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventInstallmentsChanged, AccountNumber: plan.From, Time: now,
+		Message: fmt.Sprintf("Installment plan %s cancelled: %d installments of %.2f left unpaid", plan.ID, cancelled, plan.Remaining)})
+	return plan, nil
+}
+
+// PayInstallments transfers the installments that have fallen due, each
+// plan's in order, and returns the plans it paid any of. An installment
+// that fails, such as for insufficient funds, holds back the later ones of
+// its plan until a later run pays it. Installments go through the bank's
+// middleware like any transfer, on behalf of the plan's Holder.
+func (b *Bank) PayInstallments() []InstallmentPlan {
+	b.installmentRun.Lock()
+	defer b.installmentRun.Unlock()
+	now := b.now()
+	b.mu.RLock()
+	var due []InstallmentPlan
+	for _, p := range b.installments {
+		if p.active() {
+			due = append(due, p.clone())
+		}
+	}
+	b.mu.RUnlock()
+	slices.SortFunc(due, func(a, b InstallmentPlan) int { return compareIDs(a.ID, b.ID) })
+
+	var paid []InstallmentPlan
+	for _, p := range due {
+		if plan, ok := b.payInstallments(p, now); ok {
+			paid = append(paid, plan)
+		}
+	}
+	return paid
+}
+
+// payInstallments pays the installments of a plan due by now and reports
+// whether it paid any.
+func (b *Bank) payInstallments(p InstallmentPlan, now time.Time) (InstallmentPlan, bool) {
+	paidAny := false
+	for _, in := range p.Installments {
+		if in.Status != InstallmentScheduled {
+			continue
+		}
+		if in.Due.After(now) {
+			break
+		}
+		opts := []TxOption{WithCategory(p.Category), WithMetadata(MetaInstallmentPlan, p.ID), WithMetadata(MetaInstallment, strconv.Itoa(in.Number)),
+			WithDescription(fmt.Sprintf("Installment %d of %d", in.Number, p.Count))}
+		if p.Holder != "" {
+			opts = append(opts, ByHolder(p.Holder))
+		}
+		err := b.Transfer(p.From, p.To, in.Amount, opts...)
+		var tx Transaction
+		if err == nil {
+			tx = b.lastEntry(p.From, func(tx Transaction) bool {
+				return tx.Type == TransactionWithdrawal && tx.Metadata[MetaInstallmentPlan] == p.ID && tx.Metadata[MetaInstallment] == strconv.Itoa(in.Number)
+			})
+		}
+
+		b.mu.Lock()
+		stored, ok := b.installments[p.ID]
+		if !ok {
+			b.mu.Unlock()
+			return InstallmentPlan{}, paidAny
+		}
+		installment := &stored.Installments[in.Number-1]
+		installment.Attempts++
+		if err != nil {
+			installment.LastError = err.Error()
+			plan := stored.clone()
+			b.mu.Unlock()
+			b.Events.Publish(Event{Type: EventInstallmentsChanged, AccountNumber: p.From, Time: now,
+				Message: fmt.Sprintf("Installment %d of %d of plan %s failed: %v", in.Number, p.Count, p.ID, err)})
+			return plan, paidAny
+		}
+		installment.Status, installment.Paid, installment.LastError = InstallmentPaid, &now, ""
+		installment.Transaction, installment.Transfer = tx.ID, tx.Metadata[MetaTransferID]
+		stored.Paid = money.Round(stored.Paid+in.Amount, "", money.HalfEven)
+		stored.Remaining = money.Round(stored.Amount-stored.Paid, "", money.HalfEven)
+		message := fmt.Sprintf("Installment %d of %d of plan %s paid: %.2f, %.2f to go", in.Number, p.Count, p.ID, in.Amount, stored.Remaining)
+		if in.Number == p.Count && stored.Cancelled == nil {
+			stored.Completed = &now
+			message = fmt.Sprintf("Installment plan %s completed: %.2f paid to %s", p.ID, stored.Paid, p.To)
+		}
+		p = stored.clone()
+		b.mu.Unlock()
+		paidAny = true
+		b.Events.Publish(Event{Type: EventInstallmentsChanged, AccountNumber: p.From, Time: now, Message: message})
+	}
+	return p, paidAny
+}
+
+// lastEntry returns the latest entry of an account that match says is the
+// one, such as that an operation just posted.
+func (b *Bank) lastEntry(number string, match func(Transaction) bool) Transaction {
+	history, _ := b.History(number)
+	for i := len(history) - 1; i >= 0; i-- {
+		if match(history[i]) {
+			return history[i]
+		}
+	}
+	return Transaction{}
+}
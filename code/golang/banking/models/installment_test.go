@@ -0,0 +1,149 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestInstallments checks that a plan splits its amount with the remainder
+// on the last installment, that due installments are paid in order and
+// linked to their ledger entries, that one that fails holds back the rest
+// until funds arrive, and that cancelling leaves those paid alone and
+// survives a snapshot.
+func TestInstallments(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.IDs = &SequentialIDs{Prefix: "id-"}
+	b.Use(b.AuthorizeHolders())
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	if err := b.Deposit("C1", 150); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.PlanInstallments(InstallmentPlan{From: "C1", To: "S1", Amount: 100, Count: 1}); !errors.Is(err, ErrInvalidInstallmentPlan) {
+		t.Errorf("plan of one installment: %v, want ErrInvalidInstallmentPlan", err)
+	}
+	if _, err := b.PlanInstallments(InstallmentPlan{From: "S1", To: "C1", Amount: 100, Count: 3, Holder: "c1"}); err == nil {
+		t.Error("plan out of an account the holder does not hold went through")
+	}
+	plan, err := b.PlanInstallments(InstallmentPlan{From: "C1", To: "S1", Amount: 100, Count: 3, Holder: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var amounts []float64
+	for _, in := range plan.Installments {
+		amounts = append(amounts, in.Amount)
+	}
+	if len(amounts) != 3 || amounts[0] != 33.33 || amounts[1] != 33.33 || amounts[2] != 33.34 {
+		t.Fatalf("amounts = %v, want [33.33 33.33 33.34]", amounts)
+	}
+	if due := plan.Installments[1].Due; !due.Equal(date(t, "2026-02-01")) {
+		t.Errorf("second installment due %v, want 2026-02-01", due)
+	}
+
+	if paid := b.PayInstallments(); len(paid) != 1 {
+		t.Fatalf("first run paid %d plans, want 1", len(paid))
+	}
+	if paid := b.PayInstallments(); len(paid) != 0 {
+		t.Errorf("second run the same day paid %d plans, want 0", len(paid))
+	}
+	plan, _ = b.InstallmentPlan(plan.ID)
+	first := plan.Installments[0]
+	if first.Status != InstallmentPaid || first.Transaction == "" || first.Transfer == "" || plan.Remaining != 66.67 {
+		t.Fatalf("after the first installment: %+v, remaining %.2f", first, plan.Remaining)
+	}
+	history, _ := b.History("S1")
+	credit := history[len(history)-1]
+	if credit.Metadata[MetaInstallmentPlan] != plan.ID || credit.Metadata[MetaTransferID] != first.Transfer {
+		t.Errorf("credit metadata = %v, want plan %s and transfer %s", credit.Metadata, plan.ID, first.Transfer)
+	}
+
+	if err := b.Withdraw("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = date(t, "2026-03-05")
+	b.PayInstallments()
+	plan, _ = b.InstallmentPlan(plan.ID)
+	if in := plan.Installments[1]; in.Status != InstallmentScheduled || in.Attempts != 1 || in.LastError == "" {
+		t.Fatalf("unfunded installment: %+v", in)
+	}
+	if in := plan.Installments[2]; in.Attempts != 0 {
+		t.Errorf("installment after an unfunded one was tried: %+v", in)
+	}
+	if err := b.Deposit("C1", 20); err != nil {
+		t.Fatal(err)
+	}
+	b.PayInstallments()
+	plan, _ = b.InstallmentPlan(plan.ID)
+	if in := plan.Installments[1]; in.Status != InstallmentPaid || in.LastError != "" {
+		t.Fatalf("installment after funds arrived: %+v", in)
+	}
+
+	plan, err = b.CancelInstallments(plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Installments[2].Status != InstallmentCancelled || plan.Paid != 66.66 || plan.Cancelled == nil {
+		t.Errorf("cancelled plan: %+v", plan)
+	}
+	if _, err := b.CancelInstallments(plan.ID); !errors.Is(err, ErrInstallmentPlanEnded) {
+		t.Errorf("cancelling again: %v, want ErrInstallmentPlanEnded", err)
+	}
+	clock.now = date(t, "2026-04-05")
+	if paid := b.PayInstallments(); len(paid) != 0 {
+		t.Errorf("cancelled plan paid %d plans", len(paid))
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if plans := restored.InstallmentPlans("C1"); len(plans) != 1 || plans[0].Paid != 66.66 || plans[0].Cancelled == nil {
+		t.Errorf("restored plans = %+v", plans)
+	}
+}
+
+// TestInstallmentsCatchUp checks that a run catching up on missed
+// installments pays each of them although they repeat one another, with
+// duplicate detection on.
+func TestInstallmentsCatchUp(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.Duplicates = DuplicateCheck{Mode: DuplicatesWarn, Window: 24 * time.Hour}
+	b.Use(b.AuthorizeHolders(), b.DetectDuplicates())
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	if err := b.Deposit("C1", 300); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := b.PlanInstallments(InstallmentPlan{From: "C1", To: "S1", Amount: 90, Count: 3, Every: CadenceWeekly, Holder: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = date(t, "2026-01-20")
+	b.PayInstallments()
+	plan, _ = b.InstallmentPlan(plan.ID)
+	for _, in := range plan.Installments {
+		if in.Status != InstallmentPaid {
+			t.Errorf("installment %d: %+v, want paid", in.Number, in)
+		}
+	}
+	if plan.Completed == nil || plan.Paid != 90 {
+		t.Errorf("plan after catching up: %+v", plan)
+	}
+}
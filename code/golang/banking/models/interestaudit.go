@@ -0,0 +1,215 @@
+package models
+
+import (
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// Conventions interest is recomputed with, by kind of account: savings earn
+// their rate once per period, loans a twelfth of their annual rate a month
+// and cards their annual rate over 365 days on the daily balance. Entries
+// that record a day-count convention are recomputed with it instead.
+const (
+	ConventionPerPeriod = "per-period"
+	ConventionMonthly   = "30/360"
+	ConventionDaily     = "actual/365"
+)
+
+// InterestCheck is one interest entry recomputed from first principles.
+// Base is the balance, or for loans the principal, it accrued on and Rate
+// the rate the account's rate history gives at the time; PostedRate is the
+// one the entry records, a month's for loans, and RateMismatch tells the
+// two apart. Expected is the exact interest, Posted the entry, both signed
+// as they move the balance, and Difference what posting added or lost. A
+// Difference of a cent or more is more than rounding explains.
+type InterestCheck struct {
+	Sequence     int             `json:"sequence"`
+	Time         time.Time       `json:"time"`
+	Type         TransactionType `json:"type"`
+	Convention   string          `json:"convention"`
+	Base         float64         `json:"base"`
+	Rate         money.Rate      `json:"rate"`
+	PostedRate   money.Rate      `json:"posted_rate"`
+	RateMismatch bool            `json:"rate_mismatch,omitempty"`
+	Expected     float64         `json:"expected"`
+	Posted       float64         `json:"posted"`
+	Difference   float64         `json:"difference"`
+	Unexplained  bool            `json:"unexplained,omitempty"`
+}
+
+// InterestAudit diffs the interest posted to an account from From until To
+// against the interest recomputed for it. Difference is the rounding error
+// accumulated over the period, summed exactly.
+type InterestAudit struct {
+	Account        string          `json:"account"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	Checks         []InterestCheck `json:"checks"`
+	Expected       float64         `json:"expected"`
+	Posted         float64         `json:"posted"`
+	Difference     float64         `json:"difference"`
+	RateMismatches int             `json:"rate_mismatches"`
+	Unexplained    int             `json:"unexplained"`
+}
+
+// AuditInterest audits the interest posted to an account from from until
+// to, see InterestAudit. A zero to audits every entry since from.
+func (b *Bank) AuditInterest(number string, from, to time.Time) (InterestAudit, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return InterestAudit{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return auditInterest(account, from, to), nil
+}
+
+// InterestAuditReport audits the interest of every account that was posted
+// interest from from until to, or since from when to is zero, by account
+// number.
+func (b *Bank) InterestAuditReport(from, to time.Time) []InterestAudit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var report []InterestAudit
+	for _, account := range b.accounts.all() {
+		if audit := auditInterest(account, from, to); len(audit.Checks) > 0 {
+			report = append(report, audit)
+		}
+	}
+	slices.SortFunc(report, func(a, b InterestAudit) int { return strings.Compare(a.Account, b.Account) })
+	return report
+}
+
+// auditInterest replays the ledger of account, keeping the exact balance
+// before each entry, and recomputes every interest entry in the period.
+// The caller holds b.mu.
+func auditInterest(account BankAccount, from, to time.Time) InterestAudit {
+	audit := InterestAudit{Account: account.Number(), From: from, To: to, Checks: []InterestCheck{}}
+	history := account.History()
+	balance := exactAmount(account.CheckBalance())
+	for _, tx := range history {
+		balance.Sub(balance, signedAmount(tx))
+	}
+	expected, posted := new(big.Rat), new(big.Rat)
+	for i, tx := range history {
+		before := new(big.Rat).Set(balance)
+		balance.Add(balance, signedAmount(tx))
+		if tx.Type != TransactionInterest && tx.Type != TransactionInterestCharge ||
+			tx.Time.Before(from) || !to.IsZero() && !tx.Time.Before(to) {
+			continue
+		}
+		check, exact, ok := recompute(account, history[:i], before, tx)
+		if !ok {
+			continue
+		}
+		entry := signedAmount(tx)
+		diff := new(big.Rat).Sub(entry, exact)
+		check.Expected, _ = exact.Float64()
+		check.Posted, _ = entry.Float64()
+		check.Difference, _ = diff.Float64()
+		check.Unexplained = math.Abs(check.Difference) >= 0.01
+		expected.Add(expected, exact)
+		posted.Add(posted, entry)
+		if check.RateMismatch {
+			audit.RateMismatches++
+		}
+		if check.Unexplained {
+			audit.Unexplained++
+		}
+		audit.Checks = append(audit.Checks, check)
+	}
+	audit.Expected, _ = expected.Float64()
+	audit.Posted, _ = posted.Float64()
+	audit.Difference, _ = new(big.Rat).Sub(posted, expected).Float64()
+	return audit
+}
+
+// recompute works out the exact interest of entry tx of account from the
+// entries before it and the balance they left. It reports false for
+// accounts whose interest it cannot recompute, such as custom ones.
+func recompute(account BankAccount, before []Transaction, balance *big.Rat, tx Transaction) (InterestCheck, *big.Rat, bool) {
+	check := InterestCheck{Sequence: tx.Sequence, Time: tx.Time, Type: tx.Type, PostedRate: tx.Rate}
+	check.Base, _ = balance.Float64()
+	dayCount := money.DayCount(tx.Metadata[MetaDayCount])
+	switch a := account.(type) {
+	case *SavingsAccount:
+		if dayCount != "" {
+			check.Convention, check.Rate = string(dayCount), a.rateAt(tx.Time)
+			check.RateMismatch = check.Rate != tx.Rate
+			return check, dayCountInterest(before, check.Base, check.Rate, a.RateResets, dayCount, tx.Time), true
+		}
+		check.Convention, check.Rate = ConventionPerPeriod, a.rateAt(tx.Time)
+		check.RateMismatch = check.Rate != tx.Rate
+		exact := new(big.Rat).Mul(balance, big.NewRat(int64(check.Rate), int64(money.Percent100)))
+		return check, exact, true
+	case *LoanAccount:
+		// Loans accrue on the principal still owed and charge it, so
+		// the interest lowers the balance.
+		principal := outstandingOf(before, check.Base, CurrencyOf(a)).Principal
+		if dayCount != "" {
+			exact := a.dayCountInterest(before, check.Base, dayCount, tx.Time)
+			check.Convention, check.Rate, check.Base = string(dayCount), a.InterestRate, principal
+			check.RateMismatch = check.Rate != tx.Rate
+			return check, exact.Neg(exact), true
+		}
+		check.Convention, check.Rate, check.Base = ConventionMonthly, a.InterestRate, principal
+		check.RateMismatch = money.Rate(math.Round(float64(a.InterestRate)/12)) != tx.Rate
+		exact := new(big.Rat).Mul(exactAmount(principal), big.NewRat(int64(a.InterestRate), 12*int64(money.Percent100)))
+		return check, exact.Neg(exact), true
+	case *CreditCardAccount:
+		// Cards are billed interest for the cycle the statement closed at
+		// tx.Time ends, as the card stood before it.
+		prefix := *a
+		prefix.Transactions = before
+		prefix.Balance = check.Base
+		prefix.Statements = nil
+		for _, s := range a.Statements {
+			if s.Closed.Before(tx.Time) {
+				prefix.Statements = append(prefix.Statements, s)
+			}
+		}
+		check.Convention, check.Rate = ConventionDaily, a.PurchaseRate
+		check.RateMismatch = check.Rate != tx.Rate
+		exact := exactAmount(prefix.cycleInterest(tx.Time))
+		return check, exact.Neg(exact), true
+	default:
+		return InterestCheck{}, nil, false
+	}
+}
+
+// rateAt returns the rate the account's rate history gives at t: the rate
+// of the latest reset by then, the rate before the first reset, or the
+// current rate when it never reset.
+func (sa *SavingsAccount) rateAt(t time.Time) money.Rate {
+	rate := sa.InterestRate
+	for i := len(sa.RateResets) - 1; i >= 0; i-- {
+		r := sa.RateResets[i]
+		if !r.Time.After(t) {
+			return r.To
+		}
+		rate = r.From
+	}
+	return rate
+}
+
+// signedAmount is the exact amount tx moves the balance by.
+func signedAmount(tx Transaction) *big.Rat {
+	x := exactAmount(tx.Amount)
+	if !tx.Type.IsCredit() {
+		x.Neg(x)
+	}
+	return x
+}
+
+// exactAmount is amount as the shortest decimal that represents it, as
+// package money reads amounts.
+func exactAmount(amount float64) *big.Rat {
+	x, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'g', -1, 64))
+	return x
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// TestAuditInterest posts interest to a savings account across a rate
+// reset, and an entry at a stale rate, and checks that the audit puts the
+// rounding of each entry and of the period down exactly, flags only the
+// stale entry, and keeps to the period asked for.
+func TestAuditInterest(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-02")}
+	b := NewBank()
+	b.Clock = clock
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	savings := &SavingsAccount{Account: Account{AccountNumber: "S1"}, InterestRate: money.Percent(3)}
+	b.Open(savings)
+	b.Deposit("C1", 100)
+	b.Deposit("S1", 100.05)
+
+	clock.now = date(t, "2026-01-31")
+	b.ApplyInterest("S1") // 3.0015 posted as 3.00
+	savings.InterestRate = money.Percent(4)
+	savings.RateResets = append(savings.RateResets, RateReset{Time: date(t, "2026-02-01"), From: money.Percent(3), To: money.Percent(4)})
+	clock.now = date(t, "2026-02-28")
+	b.ApplyInterest("S1") // 4.122 posted as 4.12
+	// 4.2868 at the current rate, posted as 5.00 at the old one.
+	if err := savings.Post(Transaction{Type: TransactionInterest, Amount: 5, Rate: money.Percent(3), Time: date(t, "2026-03-31")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		from, to                string
+		checks                  int
+		difference              float64
+		mismatches, unexplained int
+	}{
+		{"2026-01-01", "", 3, 0.7097, 1, 1},
+		{"2026-02-01", "2026-03-01", 1, -0.002, 0, 0},
+		{"2026-04-01", "", 0, 0, 0, 0},
+	} {
+		var to time.Time
+		if tt.to != "" {
+			to = date(t, tt.to)
+		}
+		audit, err := b.AuditInterest("S1", date(t, tt.from), to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(audit.Checks) != tt.checks || audit.Difference != tt.difference ||
+			audit.RateMismatches != tt.mismatches || audit.Unexplained != tt.unexplained {
+			t.Errorf("audit from %s to %q: %d checks, difference %v, %d mismatched, %d unexplained, want %d, %v, %d, %d",
+				tt.from, tt.to, len(audit.Checks), audit.Difference, audit.RateMismatches, audit.Unexplained,
+				tt.checks, tt.difference, tt.mismatches, tt.unexplained)
+		}
+	}
+
+	audit, _ := b.AuditInterest("S1", time.Time{}, time.Time{})
+	if c := audit.Checks[1]; c.Convention != ConventionPerPeriod || c.Base != 103.05 || c.Rate != money.Percent(4) || c.Expected != 4.122 || c.Posted != 4.12 {
+		t.Errorf("second check %+v, want 4.122 on 103.05 at 4%% posted as 4.12", c)
+	}
+	if c := audit.Checks[2]; !c.RateMismatch || !c.Unexplained || c.Rate != money.Percent(4) || c.PostedRate != money.Percent(3) {
+		t.Errorf("stale check %+v, want a mismatched rate and an unexplained difference", c)
+	}
+	if report := b.InterestAuditReport(time.Time{}, time.Time{}); len(report) != 1 || report[0].Account != "S1" {
+		t.Errorf("report covers %d accounts, want S1 only", len(report))
+	}
+	if _, err := b.AuditInterest("C9", time.Time{}, time.Time{}); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("auditing an unknown account: %v, want ErrAccountNotFound", err)
+	}
+}
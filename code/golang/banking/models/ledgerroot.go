@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/merkle"
+)
+
+// ledgerRootsKept is how many published roots proofs can be asked against,
+// the latest ones.
+const ledgerRootsKept = 48
+
+var (
+	ErrLedgerRootNotFound = banking.New(banking.CodeNotFound, "ledger root not found; none was published or it is no longer kept")
+	ErrNotInLedgerRoot    = banking.New(banking.CodeNotFound, "entry not in the ledger root; it was posted after the root was published")
+	ErrInvalidProof       = banking.New(banking.CodeInvalidArgument, "inclusion proof does not lead to its root")
+)
+
+// LedgerRoot is a published Merkle root over every entry of the ledger, see
+// package merkle. The leaves are the entries of each account in the order
+// posted, accounts by number; Size counts them. Sequence numbers the roots
+// from 1 in the order published.
+type LedgerRoot struct {
+	Sequence int       `json:"sequence"`
+	Root     string    `json:"root"`
+	Size     int       `json:"size"`
+	Time     time.Time `json:"time"`
+}
+
+// InclusionProof shows that an entry is a leaf of a published root: Path
+// are the hashes, in hex, it is combined with on the way up from Index. It
+// is checked with Verify alone, without the rest of the ledger.
+type InclusionProof struct {
+	Account     string      `json:"account"`
+	Transaction Transaction `json:"transaction"`
+	Index       int         `json:"index"`
+	Size        int         `json:"size"`
+	Sequence    int         `json:"sequence"`
+	Root        string      `json:"root"`
+	Path        []string    `json:"path"`
+}
+
+// publishedRoot is a published root with what its leaves were: the first
+// entries of each account's ledger, in their order. Ledgers are
+// append-only, so the leaves are built again from them to prove an entry
+// rather than kept.
+type publishedRoot struct {
+	LedgerRoot
+	Ledgers []ledgerPrefix `json:"ledgers,omitempty"`
+}
+
+// ledgerPrefix is the first Entries entries of an account's ledger. Account
+// is the number the account had when the root was published, which its
+// leaves hash.
+type ledgerPrefix struct {
+	Account string `json:"account"`
+	Entries int    `json:"entries"`
+}
+
+// ledgerLeaf is what an entry's leaf hashes: the entry as the API writes it,
+// with its account.
+type ledgerLeaf struct {
+	Account     string      `json:"account"`
+	Transaction Transaction `json:"transaction"`
+}
+
+func leafHash(account string, tx Transaction) []byte {
+	data, _ := json.Marshal(ledgerLeaf{account, tx})
+	return merkle.LeafHash(data)
+}
+
+// PublishLedgerRoot builds a Merkle root over the ledger as it is now and
+// keeps it to prove entries against, with the latest roots before it.
+// Entries are not posted while it runs.
+func (b *Bank) PublishLedgerRoot() LedgerRoot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	accounts := b.accounts.all()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number() < accounts[j].Number() })
+	root := publishedRoot{}
+	var leaves [][]byte
+	for _, account := range accounts {
+		history := account.History()
+		if len(history) == 0 {
+			continue
+		}
+		for _, tx := range history {
+			leaves = append(leaves, leafHash(account.Number(), tx))
+		}
+		root.Ledgers = append(root.Ledgers, ledgerPrefix{account.Number(), len(history)})
+	}
+	root.Root = hex.EncodeToString(merkle.Root(leaves))
+	root.Size = len(leaves)
+	root.Time = b.now()
+	root.Sequence = 1
+	if n := len(b.ledgerRoots); n > 0 {
+		root.Sequence = b.ledgerRoots[n-1].Sequence + 1
+	}
+	b.ledgerRoots = append(b.ledgerRoots, root)
+	if len(b.ledgerRoots) > ledgerRootsKept {
+		b.ledgerRoots = b.ledgerRoots[len(b.ledgerRoots)-ledgerRootsKept:]
+	}
+	return root.LedgerRoot
+}
+
+// LedgerRoots returns the published roots proofs can be asked against,
+// oldest first.
+func (b *Bank) LedgerRoots() []LedgerRoot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	roots := make([]LedgerRoot, len(b.ledgerRoots))
+	for i, r := range b.ledgerRoots {
+		roots[i] = r.LedgerRoot
+	}
+	return roots
+}
+
+// ProveInclusion returns the proof that the entry with an ID is in the root
+// with a sequence number, the latest root for 0. The leaves of the root are
+// built again from the ledgers; a root whose leaves cannot be, as when one
+// of its accounts was archived since, proves no entry.
+func (b *Bank) ProveInclusion(id string, sequence int) (InclusionProof, error) {
+	b.mu.RLock()
+	var root *publishedRoot
+	for i, r := range b.ledgerRoots {
+		if r.Sequence == sequence || sequence == 0 {
+			root = &b.ledgerRoots[i]
+		}
+	}
+	var p InclusionProof
+	if root != nil {
+		p = InclusionProof{Index: -1, Size: root.Size, Sequence: root.Sequence, Root: root.Root}
+	}
+	b.mu.RUnlock()
+	if root == nil {
+		return InclusionProof{}, ErrLedgerRootNotFound
+	}
+
+	var leaves [][]byte
+	for _, l := range root.Ledgers {
+		history, err := b.ledgerPrefix(l)
+		if err != nil {
+			return InclusionProof{}, ErrNotInLedgerRoot
+		}
+		for _, tx := range history {
+			if tx.ID == id && p.Index < 0 {
+				p.Index, p.Account, p.Transaction = len(leaves), l.Account, tx
+			}
+			leaves = append(leaves, leafHash(l.Account, tx))
+		}
+	}
+	if p.Index < 0 || hex.EncodeToString(merkle.Root(leaves)) != p.Root {
+		return InclusionProof{}, ErrNotInLedgerRoot
+	}
+	for _, h := range merkle.Proof(leaves, p.Index) {
+		p.Path = append(p.Path, hex.EncodeToString(h))
+	}
+	return p, nil
+}
+
+// ledgerPrefix returns a copy of the entries of a root's ledger, reading
+// the account where it is now if it was renumbered since.
+func (b *Bank) ledgerPrefix(l ledgerPrefix) ([]Transaction, error) {
+	number := l.Account
+	b.mu.RLock()
+	if r, ok := b.redirects[number]; ok && b.accounts.account(number) == nil {
+		number = r.To
+	}
+	b.mu.RUnlock()
+	account, unlock, err := b.lockAccount(number)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	history := account.History()
+	if len(history) < l.Entries {
+		return nil, ErrNotInLedgerRoot
+	}
+	return append([]Transaction(nil), history[:l.Entries]...), nil
+}
+
+// Verify checks that the proof leads from its entry to its root, which the
+// customer compares with the root the bank published.
+func (p InclusionProof) Verify() error {
+	root, err := hex.DecodeString(p.Root)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	path := make([][]byte, len(p.Path))
+	for i, h := range p.Path {
+		if path[i], err = hex.DecodeString(h); err != nil {
+			return ErrInvalidProof
+		}
+	}
+	if !merkle.Verify(root, leafHash(p.Account, p.Transaction), p.Index, p.Size, path) {
+		return ErrInvalidProof
+	}
+	return nil
+}
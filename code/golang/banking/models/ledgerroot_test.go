@@ -0,0 +1,146 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestLedgerRoots proves an entry against the root it was published in,
+// also after a JSON round trip, and checks that an altered entry, a later
+// entry and a root not kept fail.
+func TestLedgerRoots(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.IDs = &SequentialIDs{Prefix: "tx"}
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	for _, amount := range []float64{100, 20, 35.5} {
+		b.Deposit("S1", amount, WithDescription("salary"), WithTags("income"))
+		b.Deposit("C1", amount)
+	}
+	b.Transfer("S1", "C1", 10)
+	first := b.PublishLedgerRoot()
+	if first.Sequence != 1 || first.Size != 8 {
+		t.Fatalf("root %+v, want the first of 8 entries", first)
+	}
+	b.Deposit("C1", 5)
+	latest := b.PublishLedgerRoot()
+	if latest.Root == first.Root || latest.Size != 9 {
+		t.Errorf("root %+v after a deposit, want a new root of 9 entries", latest)
+	}
+
+	p, err := b.ProveInclusion("tx3", first.Sequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Root != first.Root || p.Transaction.Amount != 20 {
+		t.Fatalf("proof %+v, want tx3 of 20 in root %s", p, first.Root)
+	}
+	data, _ := json.Marshal(p)
+	var received InclusionProof
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+	if err := received.Verify(); err != nil {
+		t.Errorf("proof read back: %v", err)
+	}
+	received.Transaction.Amount = 2000
+	if err := received.Verify(); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("altered entry: %v, want ErrInvalidProof", err)
+	}
+
+	if _, err := b.ProveInclusion("tx10", first.Sequence); !errors.Is(err, ErrNotInLedgerRoot) {
+		t.Errorf("entry posted after the root: %v, want ErrNotInLedgerRoot", err)
+	}
+	if p, err := b.ProveInclusion("tx10", 0); err != nil || p.Sequence != latest.Sequence || p.Verify() != nil {
+		t.Errorf("entry in the latest root: %+v, %v", p, err)
+	}
+	for i := 0; i < ledgerRootsKept; i++ {
+		b.PublishLedgerRoot()
+	}
+	if _, err := b.ProveInclusion("tx3", first.Sequence); !errors.Is(err, ErrLedgerRootNotFound) {
+		t.Errorf("root no longer kept: %v, want ErrLedgerRootNotFound", err)
+	}
+}
+
+// TestLedgerRootsSnapshot checks that published roots and delinquency
+// buckets survive a snapshot: an entry is proved against the root it was
+// published in, and a review after the restore does not announce a bucket
+// the loan was already in.
+func TestLedgerRootsSnapshot(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-02-11")}
+	b := NewBank()
+	b.Clock = clock
+	b.IDs = &SequentialIDs{Prefix: "tx"}
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&LoanAccount{Account: Account{AccountNumber: "L1", Balance: -1200}, Principal: 1200,
+		InterestRate: money.Percent(6), TermMonths: 12, Opened: date(t, "2026-01-01")})
+	b.Deposit("C1", 100)
+	b.Deposit("C1", 20)
+	root := b.PublishLedgerRoot()
+	b.Deposit("C1", 5)
+	if report := b.ReviewDelinquency(); len(report) != 1 || report[0].Bucket != BucketPastDue {
+		t.Fatalf("review %+v, want L1 past due", report)
+	}
+
+	var snapshot bytes.Buffer
+	if err := b.Snapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	c := NewBank()
+	c.Clock = clock
+	if err := c.Restore(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if roots := c.LedgerRoots(); len(roots) != 1 || roots[0] != root {
+		t.Fatalf("roots after a restore %+v, want %+v", roots, root)
+	}
+	p, err := c.ProveInclusion("tx2", 0)
+	if err != nil || p.Verify() != nil || p.Root != root.Root {
+		t.Errorf("proof after a restore %+v, %v", p, err)
+	}
+	if _, err := c.ProveInclusion("tx3", 0); !errors.Is(err, ErrNotInLedgerRoot) {
+		t.Errorf("entry posted after the root: %v, want ErrNotInLedgerRoot", err)
+	}
+	changed := 0
+	c.Events.Subscribe(func(e Event) {
+		if e.Type == EventDelinquencyChanged {
+			changed++
+		}
+	})
+	c.ReviewDelinquency()
+	if changed != 0 {
+		t.Errorf("review after a restore announced %d bucket changes, want none", changed)
+	}
+}
+
+// TestLedgerRootsRenumbered proves an entry of an account renumbered since
+// the root was published under the number its leaf hashes.
+func TestLedgerRootsRenumbered(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.IDs = &SequentialIDs{Prefix: "tx"}
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	b.Deposit("C1", 100)
+	b.Deposit("C2", 50)
+	root := b.PublishLedgerRoot()
+	if _, err := b.RenumberAccount("C1", "C9"); err != nil {
+		t.Fatal(err)
+	}
+	b.Deposit("C9", 5)
+
+	p, err := b.ProveInclusion("tx1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Account != "C1" || p.Root != root.Root || p.Verify() != nil {
+		t.Errorf("proof %+v, want C1's entry in root %s", p, root.Root)
+	}
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var (
+	ErrLoanDeclined  = banking.New(banking.CodeLimitExceeded, "loan declined")
+	ErrLoanOverpaid  = banking.New(banking.CodeInvalidArgument, "payment exceeds what is owed")
+	ErrLoanDrawnDown = banking.New(banking.CodeConflict, "loan already paid out")
+	ErrNotOwnAccount = banking.New(banking.CodeInvalidArgument, "account does not belong to the customer")
+	ErrInvalidLoan   = banking.New(banking.CodeInvalidArgument, "principal and term must be positive")
+)
+
+// LoanAccount is money lent to a customer, repaid in TermMonths equal
+// monthly installments at the annual InterestRate. Its balance is negative
+// while money is owed: paying out the Principal is a withdrawal, repayments
+// are deposits and Bank.ApplyInterest charges a month of interest with an
+// interest_charge entry. With a DayCount it charges instead the interest of
+// the days since the last charge, counted under that convention.
+type LoanAccount struct {
+	Account
+	Principal    float64
+	InterestRate money.Rate
+	TermMonths   int
+	Opened       time.Time
+	DayCount     money.DayCount
+}
+
+// LoanTerms are the fields of a LoanAccount other than its ledger, in the
+// form stores save them.
+type LoanTerms struct {
+	Principal    float64        `json:"principal"`
+	InterestRate money.Rate     `json:"interest_rate"`
+	TermMonths   int            `json:"term_months"`
+	Opened       time.Time      `json:"opened"`
+	DayCount     money.DayCount `json:"day_count,omitempty"`
+}
+
+func (l *LoanAccount) Terms() LoanTerms {
+	return LoanTerms{Principal: l.Principal, InterestRate: l.InterestRate, TermMonths: l.TermMonths, Opened: l.Opened, DayCount: l.DayCount}
+}
+
+func (l *LoanAccount) SetTerms(t LoanTerms) {
+	l.Principal, l.InterestRate, l.TermMonths, l.Opened, l.DayCount = t.Principal, t.InterestRate, t.TermMonths, t.Opened, t.DayCount
+}
+
+// Owed returns the outstanding balance of the loan.
+func (l *LoanAccount) Owed() float64 {
+	return math.Max(-l.Balance, 0)
+}
+
+// Installment is the monthly payment that repays the loan over its term.
+func (l *LoanAccount) Installment() float64 {
+	return Installment(l.Principal, l.InterestRate, l.TermMonths)
+}
+
+// APR is the annual percentage rate the loan actually charges: the rate at
+// which its installments, rounded up to the cent, repay the principal. It
+// can be a little above InterestRate because of that rounding.
+func (l *LoanAccount) APR() money.Rate {
+	flows := make([]float64, l.TermMonths+1)
+	flows[0] = l.Principal
+	for i := 1; i <= l.TermMonths; i++ {
+		flows[i] = -l.Installment()
+	}
+	irr, err := money.IRR(flows)
+	if err != nil {
+		return 0
+	}
+	return money.APRFromPeriodicRate(irr, 12)
+}
+
+// EffectiveRate is the annual rate the loan costs with monthly compounding.
+func (l *LoanAccount) EffectiveRate() money.Rate {
+	return money.EffectiveAnnualRate(l.APR(), 12)
+}
+
+// Installment is the monthly payment that repays principal over termMonths
+// at the annual rate, interest compounding monthly, rounded up to the cent.
+func Installment(principal float64, rate money.Rate, termMonths int) float64 {
+	if termMonths <= 0 {
+		return 0
+	}
+	r := rate.Percent() / 100 / 12
+	if r == 0 {
+		return math.Ceil(principal/float64(termMonths)*100) / 100
+	}
+	payment := principal * r / (1 - math.Pow(1+r, -float64(termMonths)))
+	return math.Ceil(payment*100-1e-9) / 100
+}
+
+// MaxPrincipal is the largest principal whose Installment is at most payment.
+func MaxPrincipal(payment float64, rate money.Rate, termMonths int) float64 {
+	if payment <= 0 || termMonths <= 0 {
+		return 0
+	}
+	r := rate.Percent() / 100 / 12
+	if r == 0 {
+		return math.Floor(payment*float64(termMonths)*100) / 100
+	}
+	return math.Floor(payment*(1-math.Pow(1+r, -float64(termMonths)))/r*100) / 100
+}
+
+func (l *LoanAccount) Deposit(amount float64) error {
+	return l.DepositFrom("", amount)
+}
+
+func (l *LoanAccount) DepositFrom(counterparty string, amount float64) error {
+	return l.Post(Transaction{Type: TransactionDeposit, Amount: amount, Counterparty: counterparty})
+}
+
+func (l *LoanAccount) Withdraw(amount float64) error {
+	return l.WithdrawTo("", amount)
+}
+
+func (l *LoanAccount) WithdrawTo(counterparty string, amount float64) error {
+	return l.Post(Transaction{Type: TransactionWithdrawal, Amount: amount, Counterparty: counterparty})
+}
+
+// Post lets the principal be paid out once and repayments bring the
+// balance back up to zero, but not beyond.
+func (l *LoanAccount) Post(tx Transaction) error {
+	switch {
+	case tx.Type == TransactionWithdrawal && l.drawn()+tx.Amount > l.Principal+0.005:
+		return ErrLoanDrawnDown
+	case tx.Type == TransactionDeposit && tx.Amount > l.Owed()+0.005:
+		return ErrLoanOverpaid
+	}
+	return l.post(tx, l.Principal)
+}
+
+func (l *LoanAccount) creditLimit() float64 {
+	return l.Principal
+}
+
+// missed returns the due dates of the installments not paid by now, oldest
+// first, and the sum of what is unpaid of them. Repayments go to the oldest
+// installment first, so paying ahead covers the next ones.
+func (l *LoanAccount) missed(now time.Time) ([]time.Time, float64) {
+	paid := 0.0
+	for _, tx := range l.Transactions {
+		// Late fees are paid on top of the installments.
+		if tx.Type == TransactionDeposit && tx.Metadata[allocationKey] != string(ComponentFees) {
+			paid += tx.Amount
+		}
+	}
+	installment := l.Installment()
+	var dates []time.Time
+	pastDue := 0.0
+	for i := 1; i <= l.TermMonths; i++ {
+		due := addMonths(l.Opened, i)
+		if !now.After(due) {
+			break
+		}
+		covered := math.Min(paid, installment)
+		paid -= covered
+		if unpaid := installment - covered; unpaid >= 0.005 {
+			dates = append(dates, due)
+			pastDue += unpaid
+		}
+	}
+	return dates, money.Round(pastDue, "", money.HalfEven)
+}
+
+// accrueInterest charges a month of interest at the annual InterestRate on
+// the principal still owed, or with a DayCount the interest since the last
+// charge.
+func (l *LoanAccount) accrueInterest(rounding money.Rounding, at time.Time) error {
+	if l.DayCount != "" {
+		exact := l.dayCountInterest(l.Transactions, l.Balance, l.DayCount, at)
+		interest := money.Round(ratFloat(exact), CurrencyOf(l), rounding)
+		if interest <= 0 {
+			return nil
+		}
+		return l.Post(Transaction{Type: TransactionInterestCharge, Amount: interest, Rate: l.InterestRate, Time: at,
+			Metadata: map[string]string{MetaDayCount: string(l.DayCount)}})
+	}
+	monthly := money.Rate(math.Round(float64(l.InterestRate) / 12))
+	principal := outstanding(l).Principal
+	interest := money.Round(principal*l.InterestRate.Percent()/100/12, "", rounding)
+	if interest <= 0 {
+		return nil
+	}
+	return l.Post(Transaction{Type: TransactionInterestCharge, Amount: interest, Rate: monthly, Time: at})
+}
+
+// drawn is how much of the principal has been paid out.
+func (l *LoanAccount) drawn() float64 {
+	total := 0.0
+	for _, tx := range l.Transactions {
+		if tx.Type == TransactionWithdrawal {
+			total += tx.Amount
+		}
+	}
+	return total
+}
+
+// OpenLoan assesses a customer's credit and, when the loan fits the offer,
+// opens it for them and pays the principal out into disburseTo, one of their
+// own accounts. Declined loans return ErrLoanDeclined with the assessment's
+// reasons.
+func (b *Bank) OpenLoan(customerID string, loan *LoanAccount, disburseTo string) (CreditAssessment, error) {
+	if loan.Principal <= 0 || loan.TermMonths <= 0 || loan.DayCount != "" && !loan.DayCount.Valid() {
+		return CreditAssessment{}, ErrInvalidLoan
+	}
+	customer, err := b.Customer(customerID)
+	if err != nil {
+		return CreditAssessment{}, err
+	}
+	b.mu.Lock()
+	owned := false
+	for _, number := range customer.Accounts {
+		owned = owned || number == disburseTo
+	}
+	b.mu.Unlock()
+	if !owned {
+		return CreditAssessment{}, ErrNotOwnAccount
+	}
+
+	assessment, err := b.AssessCredit(customerID)
+	if err != nil {
+		return assessment, err
+	}
+	offer := assessment.MaxLoan(loan.InterestRate, loan.TermMonths)
+	if loan.Principal > offer {
+		return assessment, declined(assessment, offer)
+	}
+
+	loan.Balance = 0
+	if loan.Opened.IsZero() {
+		loan.Opened = b.now()
+	}
+	if err := b.OpenFor(customerID, loan); err != nil {
+		return assessment, err
+	}
+	return assessment, b.Transfer(loan.Number(), disburseTo, loan.Principal, WithDescription("loan disbursement"))
+}
+
+// chargeInterest posts a month of interest on a loan.
+func (b *Bank) chargeInterest(loan *LoanAccount) error {
+	b.mu.Lock()
+	if err := b.checkOpen(loan.Number()); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	posted := len(loan.History())
+	err := loan.accrueInterest(b.Rounding, b.now())
+	history := loan.History()
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if len(history) > posted {
+		tx := history[len(history)-1]
+		b.Events.Publish(Event{Type: EventTransactionPosted, AccountNumber: loan.Number(), Transaction: &tx, Time: tx.Time})
+	}
+	return nil
+}
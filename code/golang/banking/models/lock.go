@@ -0,0 +1,52 @@
+package models
+
+import "sort"
+
+// Locks are taken in one order: b.mu first, then the locks of accounts in
+// account number order. Everything that holds more than one account takes
+// them through lockAccounts, so two operations on the same accounts wait
+// for each other instead of each holding one and waiting for the other.
+
+// lockAccount takes the read lock of b.mu and the lock of an open account,
+// as posting to it needs, and returns the account and a function that
+// releases both.
+func (b *Bank) lockAccount(number string) (BankAccount, func(), error) {
+	entry, err := b.entry(number)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.mu.RLock()
+	entry.mu.Lock()
+	return entry.account, func() {
+		entry.mu.Unlock()
+		b.mu.RUnlock()
+	}, nil
+}
+
+// lockAccounts takes the read lock of b.mu and the locks of the accounts
+// numbered numbers, whatever order they are given in and however often, and
+// returns the accounts by number and a function that releases every lock.
+// Numbers that are not open accounts are left out; callers report them.
+func (b *Bank) lockAccounts(numbers ...string) (map[string]BankAccount, func()) {
+	sorted := append([]string(nil), numbers...)
+	sort.Strings(sorted)
+	var entries []*accountEntry
+	accounts := make(map[string]BankAccount, len(sorted))
+	b.mu.RLock()
+	for i, number := range sorted {
+		if i > 0 && number == sorted[i-1] {
+			continue
+		}
+		if entry, ok := b.accounts.entry(number); ok {
+			entry.mu.Lock()
+			entries = append(entries, entry)
+			accounts[number] = entry.account
+		}
+	}
+	return accounts, func() {
+		for i := len(entries) - 1; i >= 0; i-- {
+			entries[i].mu.Unlock()
+		}
+		b.mu.RUnlock()
+	}
+}
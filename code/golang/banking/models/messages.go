@@ -0,0 +1,23 @@
+package models
+
+import (
+	"io"
+	"os"
+
+	"gsolano/banking/i18n"
+)
+
+// messages prints what happens to accounts, in the locale picked from the
+// environment unless SetLocale says otherwise.
+var messages = i18n.NewPrinter(os.Stdout, i18n.FromEnv())
+
+// SetLocale changes the language of account messages.
+func SetLocale(locale i18n.Locale) {
+	messages.SetLocale(locale)
+}
+
+// SetOutput redirects account messages, e.g. to io.Discard for programs
+// that render their own output.
+func SetOutput(w io.Writer) {
+	messages.SetOutput(w)
+}
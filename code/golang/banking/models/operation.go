@@ -0,0 +1,145 @@
+package models
+
+import (
+	"log"
+	"time"
+
+	"gsolano/banking/validate"
+)
+
+// OpKind is the kind of an Op.
+type OpKind string
+
+const (
+	OpDeposit    OpKind = "deposit"
+	OpWithdrawal OpKind = "withdrawal"
+	OpTransfer   OpKind = "transfer"
+)
+
+// Op is a deposit into, withdrawal from or transfer out of Account, to To
+// for a transfer, as asked of Bank.Deposit, Bank.Withdraw or Bank.Transfer.
+// Quote is the ID of the quote a transfer executes at, for
+// Bank.TransferQuoted.
+type Op struct {
+	Kind    OpKind
+	Account string
+	To      string
+	Amount  float64
+	Quote   string
+	Options []TxOption
+	// DryRun is set on the ops of Bank.DryRun, which are carried out on a
+	// copy of the bank.
+	DryRun bool
+}
+
+// Operation carries out an Op.
+type Operation func(op Op) error
+
+// Middleware wraps an Operation with a concern of its own, such as checking
+// who may operate, fraud rules or logging: it may reject the op, change it
+// or act on its outcome, and calls next to go ahead.
+type Middleware func(next Operation) Operation
+
+// Use adds middleware around the deposits, withdrawals and transfers of the
+// bank. The first middleware used is the outermost, seeing every op before
+// and every outcome after the others. Transfer batches, settlements and
+// entries the bank posts itself, such as interest and fees, do not go
+// through middleware.
+func (b *Bank) Use(middleware ...Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.middleware = append(b.middleware, middleware...)
+	operate := Operation(b.execute)
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		operate = b.middleware[i](operate)
+	}
+	b.operate = operate
+}
+
+// run validates op and carries it out through the middleware.
+func (b *Bank) run(op Op) error {
+	if err := op.validate(); err != nil {
+		return err
+	}
+	b.mu.RLock()
+	operate := b.operate
+	b.mu.RUnlock()
+	if operate == nil {
+		return b.execute(op)
+	}
+	return operate(op)
+}
+
+// validate checks the fields of op: the accounts are given, the amount is
+// one the bank can move and the description is not too long.
+func (op Op) validate() error {
+	var v validate.Validator
+	if op.Kind == OpTransfer {
+		v.Required("from", op.Account)
+		v.Required("to", op.To)
+	} else {
+		v.Required("account", op.Account)
+	}
+	v.Amount("amount", op.Amount, "")
+	var tx Transaction
+	for _, opt := range op.Options {
+		opt(&tx)
+	}
+	v.Memo("description", tx.Description)
+	return v.Err()
+}
+
+// checkAmount validates an amount a customer asked to move.
+func checkAmount(amount float64) error {
+	var v validate.Validator
+	v.Amount("amount", amount, "")
+	return v.Err()
+}
+
+// execute carries out op once the middleware let it through.
+func (b *Bank) execute(op Op) error {
+	switch op.Kind {
+	case OpDeposit:
+		return b.post(op.Account, TransactionDeposit, op.Amount, op.Options)
+	case OpWithdrawal:
+		return b.withdraw(op.Account, op.Amount, op.Options)
+	default:
+		if op.Quote != "" {
+			return b.transferQuoted(op.Quote, op.Account, op.To, op.Amount, op.Options)
+		}
+		return b.transfer(op.Account, op.To, op.Amount, op.Options)
+	}
+}
+
+// Check is middleware that rejects the ops check returns an error for.
+func Check(check func(op Op) error) Middleware {
+	return func(next Operation) Operation {
+		return func(op Op) error {
+			if err := check(op); err != nil {
+				return err
+			}
+			return next(op)
+		}
+	}
+}
+
+// LogOperations is middleware that logs every op to l with its outcome and
+// how long it took.
+func LogOperations(l *log.Logger) Middleware {
+	return func(next Operation) Operation {
+		return func(op Op) error {
+			start := time.Now()
+			err := next(op)
+			outcome := "ok"
+			if err != nil {
+				outcome = err.Error()
+			}
+			if op.Kind == OpTransfer {
+				l.Printf("%s %.2f from %s to %s: %s (%s)", op.Kind, op.Amount, op.Account, op.To, outcome, time.Since(start))
+			} else {
+				l.Printf("%s %.2f on %s: %s (%s)", op.Kind, op.Amount, op.Account, outcome, time.Since(start))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"gsolano/banking"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// PageRequest asks for one page of a list. Cursor is the NextCursor of the
+// previous page, empty for the first page. Size defaults to DefaultPageSize
+// and may not exceed MaxPageSize.
+type PageRequest struct {
+	Cursor string
+	Size   int
+}
+
+// Page is one page of a list. NextCursor is set when HasMore is; cursors are
+// opaque and stay valid while items are added to the list.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	HasMore    bool
+}
+
+var ErrInvalidCursor = banking.New(banking.CodeInvalidArgument, "invalid cursor")
+
+func (r PageRequest) size() (int, error) {
+	switch {
+	case r.Size == 0:
+		return DefaultPageSize, nil
+	case r.Size < 0 || r.Size > MaxPageSize:
+		return 0, banking.New(banking.CodeInvalidArgument, fmt.Sprintf("page size must be between 1 and %d", MaxPageSize))
+	}
+	return r.Size, nil
+}
+
+// paginate returns the page of items after the item whose key the cursor
+// holds. items must be sorted by key, and keys must sort as strings.
+func paginate[T any](items []T, req PageRequest, key func(T) string) (Page[T], error) {
+	size, err := req.size()
+	if err != nil {
+		return Page[T]{}, err
+	}
+	start := 0
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		start = sort.Search(len(items), func(i int) bool { return key(items[i]) > after })
+	}
+	return pageFrom(items[start:], size, key), nil
+}
+
+// pageFrom cuts the first size items and sets the cursor to the key of the
+// last one.
+func pageFrom[T any](items []T, size int, key func(T) string) Page[T] {
+	page := Page[T]{Items: items}
+	if len(items) > size {
+		page.Items = items[:size]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(key(items[size-1]))
+	}
+	return page
+}
+
+// sequenceKey makes ledger sequences sort as strings.
+func sequenceKey(sequence int) string {
+	return fmt.Sprintf("%020d", sequence)
+}
+
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 3 || string(raw[:3]) != "v1:" {
+		return "", ErrInvalidCursor
+	}
+	return string(raw[3:]), nil
+}
+
+// AccountsPage returns a page of the accounts ordered by number, without
+// the soft-deleted ones unless includeDeleted is set.
+func (b *Bank) AccountsPage(req PageRequest, includeDeleted bool) (Page[BankAccount], error) {
+	return paginate(b.ListAccounts(includeDeleted), req, BankAccount.Number)
+}
+
+// HistoryPage returns a page of an account's ledger, oldest entry first.
+func (b *Bank) HistoryPage(number string, req PageRequest) (Page[Transaction], error) {
+	history, err := b.History(number)
+	if err != nil {
+		return Page[Transaction]{}, err
+	}
+	return paginate(history, req, func(tx Transaction) string { return sequenceKey(tx.Sequence) })
+}
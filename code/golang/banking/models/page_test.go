@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"gsolano/banking"
+)
+
+// TestHistoryPages walks a ledger two entries at a time, posting more
+// entries half way, and checks that the cursor continues where the last
+// page ended and that bad cursors and page sizes are refused.
+func TestHistoryPages(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	for _, amount := range []float64{1, 2, 3} {
+		if err := b.Deposit("C1", amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var amounts []float64
+	req := PageRequest{Size: 2}
+	for pages := 0; ; pages++ {
+		page, err := b.HistoryPage("C1", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tx := range page.Items {
+			amounts = append(amounts, tx.Amount)
+		}
+		if pages == 0 {
+			for _, amount := range []float64{4, 5} {
+				b.Deposit("C1", amount)
+			}
+		}
+		if !page.HasMore {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+	if want := []float64{1, 2, 3, 4, 5}; !slices.Equal(amounts, want) {
+		t.Errorf("pages hold %v, want %v", amounts, want)
+	}
+
+	for _, req := range []PageRequest{{Cursor: "nope"}, {Cursor: encodeCursor("x")[1:]}, {Size: -1}, {Size: MaxPageSize + 1}} {
+		if _, err := b.HistoryPage("C1", req); banking.CodeOf(err) != banking.CodeInvalidArgument {
+			t.Errorf("page %+v: %v, want an invalid argument", req, err)
+		}
+	}
+	if _, err := b.HistoryPage("C9", PageRequest{}); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("page of C9: %v, want ErrAccountNotFound", err)
+	}
+}
+
+// TestEventLogPages checks that a full event log drops its oldest events
+// and that a cursor pointing at dropped events continues with the oldest
+// one kept.
+func TestEventLogPages(t *testing.T) {
+	l := NewEventLog(3)
+	l.Record(Event{Message: "1"})
+	l.Record(Event{Message: "2"})
+	first, err := l.Page(PageRequest{Size: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"3", "4", "5"} {
+		l.Record(Event{Message: m})
+	}
+	rest, err := l.Page(PageRequest{Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, e := range rest.Items {
+		messages = append(messages, e.Message)
+	}
+	if want := []string{"3", "4", "5"}; !slices.Equal(messages, want) || rest.HasMore {
+		t.Errorf("after the first page %v, want %v and no more", messages, want)
+	}
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrPayeeMismatch = banking.New(banking.CodeConflict, "the name does not match the payee's account; confirm it to go ahead")
+	ErrPayeeBlocked  = banking.New(banking.CodeConflict, "transfer to a payee whose name does not match blocked")
+)
+
+// PayeeMatch is how the name a payer gives for the payee of a transfer
+// compares with the names the account is held in.
+type PayeeMatch string
+
+const (
+	PayeeMatched    PayeeMatch = "match"
+	PayeeCloseMatch PayeeMatch = "close_match"
+	PayeeNoMatch    PayeeMatch = "no_match"
+)
+
+// PayeeMode is what ConfirmPayees does with a transfer whose payee name
+// does not match.
+type PayeeMode string
+
+const (
+	// PayeesOff lets them through.
+	PayeesOff PayeeMode = ""
+	// PayeesWarn rejects them until they are confirmed, see ConfirmPayee.
+	PayeesWarn PayeeMode = "warn"
+	// PayeesBlock rejects them, and only the bank may confirm one.
+	PayeesBlock PayeeMode = "block"
+)
+
+// PayeeCheck is the answer to a confirmation of payee: how Name compares
+// with the holders of Account. Holder is the name the account is held in
+// on a close match, for the payer to correct theirs; it is not disclosed
+// otherwise.
+type PayeeCheck struct {
+	Account string     `json:"account"`
+	Name    string     `json:"name"`
+	Match   PayeeMatch `json:"match"`
+	Holder  string     `json:"holder,omitempty"`
+}
+
+// PayeeRegistry knows the names accounts outside the bank are held in,
+// such as a scheme's confirmation of payee directory.
+type PayeeRegistry interface {
+	PayeeNames(number string) ([]string, error)
+}
+
+// MetaPayeeName is the metadata key of the name of the payee a transfer
+// was made to, as WithPayeeName sets it, and MetaPayeeMatch that of how it
+// matched; MetaPayeeConfirmed is the key of the confirmation ConfirmPayee
+// sets, which ConfirmPayees takes off the entry.
+const (
+	MetaPayeeName      = "payee_name"
+	MetaPayeeMatch     = "payee_match"
+	MetaPayeeConfirmed = "payee_confirmed"
+)
+
+// WithPayeeName names the payee of a transfer, for ConfirmPayees to check.
+func WithPayeeName(name string) TxOption {
+	return WithMetadata(MetaPayeeName, name)
+}
+
+// ConfirmPayee makes a transfer go ahead whose payee name ConfirmPayees
+// finds does not match; the override is recorded in the audit log.
+func ConfirmPayee() TxOption {
+	return WithMetadata(MetaPayeeConfirmed, "true")
+}
+
+// CheckPayee compares name with those of the holders of an account, or of
+// the bank's PayeeRegistry for accounts it does not hold, and answers with
+// the best match.
+func (b *Bank) CheckPayee(number, name string) (PayeeCheck, error) {
+	check := PayeeCheck{Account: number, Name: name, Match: PayeeNoMatch}
+	if strings.TrimSpace(name) == "" {
+		return PayeeCheck{}, banking.New(banking.CodeInvalidArgument, "payee name is required")
+	}
+	var names []string
+	if _, err := b.Account(number); err == nil {
+		b.mu.RLock()
+		for _, customer := range b.ownersLocked(number) {
+			names = append(names, customer.Name)
+		}
+		b.mu.RUnlock()
+		slices.Sort(names)
+	} else if b.PayeeRegistry != nil {
+		if names, err = b.PayeeRegistry.PayeeNames(number); err != nil {
+			return PayeeCheck{}, err
+		}
+	} else {
+		return PayeeCheck{}, err
+	}
+	for _, holder := range names {
+		switch matchName(name, holder) {
+		case PayeeMatched:
+			check.Match, check.Holder = PayeeMatched, ""
+			return check, nil
+		case PayeeCloseMatch:
+			if check.Match == PayeeNoMatch {
+				check.Match, check.Holder = PayeeCloseMatch, holder
+			}
+		}
+	}
+	return check, nil
+}
+
+// ConfirmPayees is middleware that checks the payee name of transfers,
+// see WithPayeeName, as the bank's PayeeCheck says. Transfers without one
+// are not checked. The entries of those checked record how the name
+// matched. Overrides in dry runs are not recorded.
+func (b *Bank) ConfirmPayees() Middleware {
+	return func(next Operation) Operation {
+		return func(op Op) error {
+			var tx Transaction
+			for _, opt := range op.Options {
+				opt(&tx)
+			}
+			name := tx.Metadata[MetaPayeeName]
+			mode := b.PayeeCheck
+			if op.Kind != OpTransfer || mode == PayeesOff || name == "" {
+				return next(op)
+			}
+			check, err := b.CheckPayee(op.To, name)
+			if err != nil {
+				return err
+			}
+			if check.Match != PayeeMatched {
+				_, confirmed := tx.Metadata[MetaPayeeConfirmed]
+				holder := tx.Metadata[MetaHolder]
+				why := fmt.Sprintf("%s for %q", check.Match, name)
+				if check.Holder != "" {
+					why += fmt.Sprintf(", the account is held by %q", check.Holder)
+				}
+				switch {
+				case mode == PayeesBlock && (!confirmed || holder != ""):
+					return fmt.Errorf("%w: %s", ErrPayeeBlocked, why)
+				case !confirmed:
+					return fmt.Errorf("%w: %s", ErrPayeeMismatch, why)
+				}
+				if !op.DryRun {
+					b.Audit.Record(AuditEntry{Time: b.now(), Customer: holder, Action: "payee.override", Account: op.Account,
+						To: op.To, Amount: op.Amount, Status: 200})
+				}
+			}
+			op.Options = append(op.Options[:len(op.Options):len(op.Options)],
+				WithMetadata(MetaPayeeMatch, string(check.Match)), withoutMetadata(MetaPayeeConfirmed))
+			return next(op)
+		}
+	}
+}
+
+// nameNoise are the titles and company suffixes names are compared
+// without.
+var nameNoise = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true, "prof": true, "sir": true,
+	"ltd": true, "limited": true, "inc": true, "llc": true, "plc": true, "co": true, "corp": true, "the": true,
+}
+
+// nameWords splits a name into lower-case words, without punctuation,
+// titles or company suffixes.
+func nameWords(name string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if !nameNoise[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// matchName compares the name a payer gave with one an account is held
+// in. Names that differ only in case, punctuation, titles or company
+// suffixes match. Names in another order, with initials for some words or
+// a typo or two are close matches.
+func matchName(given, holder string) PayeeMatch {
+	g, h := nameWords(given), nameWords(holder)
+	if len(g) == 0 || len(h) == 0 {
+		return PayeeNoMatch
+	}
+	if slices.Equal(g, h) {
+		return PayeeMatched
+	}
+	sg, sh := slices.Clone(g), slices.Clone(h)
+	slices.Sort(sg)
+	slices.Sort(sh)
+	if slices.Equal(sg, sh) {
+		return PayeeCloseMatch
+	}
+	if len(g) == len(h) && g[len(g)-1] == h[len(h)-1] {
+		initials := true
+		for i := range g[:len(g)-1] {
+			if g[i] != h[i] && !(len([]rune(g[i])) == 1 && strings.HasPrefix(h[i], g[i])) {
+				initials = false
+			}
+		}
+		if initials {
+			return PayeeCloseMatch
+		}
+	}
+	joinedG, joinedH := strings.Join(g, " "), strings.Join(h, " ")
+	if d := editDistance(joinedG, joinedH); d <= max(1, len([]rune(joinedH))/5) {
+		return PayeeCloseMatch
+	}
+	return PayeeNoMatch
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
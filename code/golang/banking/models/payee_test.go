@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMatchName(t *testing.T) {
+	for _, c := range []struct {
+		given, holder string
+		want          PayeeMatch
+	}{
+		{"Ada Lovelace", "Ada Lovelace", PayeeMatched},
+		{"mrs. ada LOVELACE", "Ada Lovelace", PayeeMatched},
+		{"Acme Ltd", "ACME", PayeeMatched},
+		{"Lovelace Ada", "Ada Lovelace", PayeeCloseMatch},
+		{"A Lovelace", "Ada Lovelace", PayeeCloseMatch},
+		{"Ada Lovelance", "Ada Lovelace", PayeeCloseMatch},
+		{"Charles Babbage", "Ada Lovelace", PayeeNoMatch},
+		{"B Lovelace", "Ada Lovelace", PayeeNoMatch},
+		{"Mr", "Ada Lovelace", PayeeNoMatch},
+	} {
+		if got := matchName(c.given, c.holder); got != c.want {
+			t.Errorf("matchName(%q, %q) = %s, want %s", c.given, c.holder, got, c.want)
+		}
+	}
+}
+
+type payeeNames map[string][]string
+
+func (p payeeNames) PayeeNames(number string) ([]string, error) {
+	if names, ok := p[number]; ok {
+		return names, nil
+	}
+	return nil, ErrAccountNotFound
+}
+
+// TestConfirmPayees checks that a transfer naming its payee goes through
+// on a match, is rejected on a close or no match until confirmed in warn
+// mode, with the override audited, and that in block mode only the bank
+// may confirm.
+func TestConfirmPayees(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.PayeeCheck = PayeesWarn
+	b.PayeeRegistry = payeeNames{"EXT1": {"Grace Hopper"}}
+	b.Use(b.AuthorizeHolders(), b.ConfirmPayees())
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada Lovelace", Accounts: []string{"C1"}})
+	b.AddCustomer(&Customer{ID: "c2", Name: "Charles Babbage", Accounts: []string{"C2"}})
+	if err := b.Deposit("C1", 500); err != nil {
+		t.Fatal(err)
+	}
+
+	if check, err := b.CheckPayee("C2", "C Babbage"); err != nil || check.Match != PayeeCloseMatch || check.Holder != "Charles Babbage" {
+		t.Errorf("close match %+v, %v", check, err)
+	}
+	if check, err := b.CheckPayee("C2", "Ada Lovelace"); err != nil || check.Match != PayeeNoMatch || check.Holder != "" {
+		t.Errorf("no match %+v, %v", check, err)
+	}
+	if check, err := b.CheckPayee("EXT1", "grace hopper"); err != nil || check.Match != PayeeMatched {
+		t.Errorf("registry match %+v, %v", check, err)
+	}
+	if _, err := b.CheckPayee("NOPE", "Ada"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("unknown account: %v, want ErrAccountNotFound", err)
+	}
+
+	if err := b.Transfer("C1", "C2", 10, ByHolder("c1"), WithPayeeName("Charles Babbage")); err != nil {
+		t.Fatalf("matching payee: %v", err)
+	}
+	if err := b.Transfer("C1", "C2", 10, ByHolder("c1"), WithPayeeName("C Babbage")); !errors.Is(err, ErrPayeeMismatch) {
+		t.Errorf("close match: %v, want ErrPayeeMismatch", err)
+	}
+	if err := b.Transfer("C1", "C2", 10, ByHolder("c1")); err != nil {
+		t.Errorf("transfer without a payee name: %v", err)
+	}
+	if err := b.Transfer("C1", "C2", 10, ByHolder("c1"), WithPayeeName("C Babbage"), ConfirmPayee()); err != nil {
+		t.Fatalf("confirmed close match: %v", err)
+	}
+	history, _ := b.History("C1")
+	last := history[len(history)-1]
+	if last.Metadata[MetaPayeeMatch] != string(PayeeCloseMatch) || last.Metadata[MetaPayeeName] != "C Babbage" || last.Metadata[MetaPayeeConfirmed] != "" {
+		t.Errorf("confirmed transfer metadata %v", last.Metadata)
+	}
+	if overrides := b.Audit.Entries(AuditQuery{Action: "payee.override"}); len(overrides) != 1 || overrides[0].Customer != "c1" || overrides[0].To != "C2" {
+		t.Errorf("overrides %+v", overrides)
+	}
+
+	b.PayeeCheck = PayeesBlock
+	if err := b.Transfer("C1", "C2", 10, ByHolder("c1"), WithPayeeName("Grace Hopper"), ConfirmPayee()); !errors.Is(err, ErrPayeeBlocked) {
+		t.Errorf("no match confirmed by a holder in block mode: %v, want ErrPayeeBlocked", err)
+	}
+	if err := b.Transfer("C1", "C2", 10, WithPayeeName("Grace Hopper"), ConfirmPayee()); err != nil {
+		t.Errorf("no match confirmed by the bank in block mode: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+)
+
+// PreviewKind is what a line of a Preview is for.
+type PreviewKind string
+
+const (
+	PreviewAmount       PreviewKind = "amount"
+	PreviewFXFee        PreviewKind = "fx_fee"
+	PreviewOverdraftFee PreviewKind = "overdraft_fee"
+	// PreviewProductFee is the withdrawal or transfer fee of the account's
+	// product.
+	PreviewProductFee PreviewKind = "product_fee"
+)
+
+// PreviewLine is one item of what an op costs, in the currency of the
+// account it is taken from.
+type PreviewLine struct {
+	Kind        PreviewKind `json:"kind"`
+	Description string      `json:"description"`
+	Amount      float64     `json:"amount"`
+}
+
+// Preview is the full cost of a transfer or withdrawal before it is made:
+// its Lines, the Fees among them and the Total taken from the account, in
+// Currency; the Rate it converts at between currencies; what the recipient
+// is credited, Net in NetCurrency, the cash paid out for a withdrawal; and
+// Available, when they can use it. The bank charges no tax on transfers or
+// withdrawals, so no line is a tax.
+type Preview struct {
+	Lines       []PreviewLine `json:"lines"`
+	Fees        float64       `json:"fees"`
+	Total       float64       `json:"total"`
+	Currency    string        `json:"currency"`
+	Rate        *ExchangeRate `json:"rate,omitempty"`
+	Net         float64       `json:"net"`
+	NetCurrency string        `json:"net_currency"`
+	Available   time.Time     `json:"available"`
+}
+
+// PreviewTransfer itemizes a transfer at the price QuoteTransfer would give
+// it, without quoting it. A transfer settling days business days after it
+// is booked, as BookTransfer books it, is available on the day the bank's
+// calendar settles it; one of 0 days is available at once.
+func (b *Bank) PreviewTransfer(from, to string, amount float64, days int) (Preview, error) {
+	if days < 0 {
+		return Preview{}, banking.New(banking.CodeInvalidArgument, "settlement days must not be negative")
+	}
+	q, err := b.price(from, to, amount)
+	if err != nil {
+		return Preview{}, err
+	}
+	target, err := b.Account(to)
+	if err != nil {
+		return Preview{}, err
+	}
+	p := Preview{Currency: q.Currency, Net: q.Credit, NetCurrency: b.AccountCurrency(target), Available: b.now()}
+	p.line(PreviewAmount, fmt.Sprintf("transfer to %s", to), amount)
+	if q.Rate.Base != q.Rate.Quote {
+		p.Rate = &q.Rate
+	}
+	if q.Fee > 0 {
+		p.line(PreviewFXFee, fmt.Sprintf("FX fee for transfer to %s", to), q.Fee)
+	}
+	if err := b.previewProductFee(&p, from, OpTransfer); err != nil {
+		return Preview{}, err
+	}
+	if err := b.previewOverdraft(&p, from); err != nil {
+		return Preview{}, err
+	}
+	if days > 0 {
+		p.Available = b.Calendar.AddBusinessDays(p.Available, days)
+	}
+	return p, nil
+}
+
+// PreviewWithdrawal itemizes a withdrawal, paid out at once.
+func (b *Bank) PreviewWithdrawal(number string, amount float64) (Preview, error) {
+	if err := (Op{Kind: OpWithdrawal, Account: number, Amount: amount}).validate(); err != nil {
+		return Preview{}, err
+	}
+	account, err := b.Account(number)
+	if err != nil {
+		return Preview{}, err
+	}
+	currency := b.AccountCurrency(account)
+	p := Preview{Currency: currency, Net: amount, NetCurrency: currency, Available: b.now()}
+	p.line(PreviewAmount, "withdrawal", amount)
+	if err := b.previewProductFee(&p, number, OpWithdrawal); err != nil {
+		return Preview{}, err
+	}
+	if err := b.previewOverdraft(&p, number); err != nil {
+		return Preview{}, err
+	}
+	return p, nil
+}
+
+func (p *Preview) line(kind PreviewKind, description string, amount float64) {
+	p.Lines = append(p.Lines, PreviewLine{Kind: kind, Description: description, Amount: amount})
+	p.Total += amount
+	if kind != PreviewAmount {
+		p.Fees += amount
+	}
+}
+
+// previewProductFee adds the withdrawal or transfer fee of the account's
+// product.
+func (b *Bank) previewProductFee(p *Preview, number string, kind OpKind) error {
+	account, err := b.Account(number)
+	if err != nil {
+		return err
+	}
+	if fee := b.opFee(account, kind); fee > 0 {
+		p.line(PreviewProductFee, string(kind)+" fee", fee)
+	}
+	return nil
+}
+
+// previewOverdraft adds the overdraft fee of the account's product when
+// the preview would leave what is available on it below zero. AssessFees
+// charges it for each day the balance stays there; the line is the first.
+func (b *Bank) previewOverdraft(p *Preview, number string) error {
+	account, err := b.Account(number)
+	if err != nil {
+		return err
+	}
+	product, ok := b.productOf(account)
+	if !ok || product.Overdraft.Fee <= 0 {
+		return nil
+	}
+	available, err := b.Available(number)
+	if err != nil {
+		return err
+	}
+	if available-p.Total < 0 {
+		p.line(PreviewOverdraftFee, "overdraft fee, charged each day the balance is below zero", product.Overdraft.Fee)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+type fixedRate float64
+
+func (r fixedRate) ExchangeRate(base, quote string) (ExchangeRate, error) {
+	return ExchangeRate{Base: base, Quote: quote, Rate: float64(r)}, nil
+}
+
+// TestPreviewTransfer previews a transfer between currencies that dips
+// into the overdraft and settles over a weekend.
+func TestPreviewTransfer(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Currency = "USD"
+	b.FX = fixedRate(0.5)
+	b.FXPricing = FXPricing{Margin: money.Percent(10), Fee: 2}
+	b.Clock = &testClock{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)} // a Friday
+	b.Products = NewCatalog(Product{Code: "chk", Name: "Checking", Kind: ProductChecking, Overdraft: OverdraftPolicy{Limit: 500, Fee: 25}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1", Product: "chk"}, OverdraftLimit: 500})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1", Currency: "EUR"}})
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := b.PreviewTransfer("C1", "S1", 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Lines) != 3 || p.Lines[1].Kind != PreviewFXFee || p.Lines[2].Kind != PreviewOverdraftFee {
+		t.Fatalf("lines %+v, want the amount, FX fee and overdraft fee", p.Lines)
+	}
+	if p.Fees != 27 || p.Total != 127 || p.Currency != "USD" {
+		t.Errorf("fees %.2f and total %.2f %s, want 27 and 127 USD", p.Fees, p.Total, p.Currency)
+	}
+	if p.Rate == nil || p.Net != 45 || p.NetCurrency != "EUR" {
+		t.Errorf("rate %v and net %.2f %s, want 45 EUR at 0.45", p.Rate, p.Net, p.NetCurrency)
+	}
+	if want := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC); !p.Available.Equal(want) {
+		t.Errorf("available %v, want Monday %v", p.Available, want)
+	}
+	c1, _ := b.Account("C1")
+	if len(c1.History()) != 1 {
+		t.Error("the preview posted entries")
+	}
+
+	w, err := b.PreviewWithdrawal("C1", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Lines) != 1 || w.Total != 60 || w.Net != 60 {
+		t.Errorf("withdrawal preview %+v, want 60 without fees", w)
+	}
+}
+
+// TestProductOpFees previews and charges the withdrawal and transfer fees of
+// an account's product.
+func TestProductOpFees(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Products = NewCatalog(Product{Code: "chk", Name: "Checking", Kind: ProductChecking, WithdrawalFee: 1.5, TransferFee: 0.25})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1", Product: "chk"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := b.PreviewWithdrawal("C1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Lines) != 2 || w.Lines[1].Kind != PreviewProductFee || w.Total != 11.5 {
+		t.Errorf("withdrawal preview %+v, want 10 and a 1.50 fee", w)
+	}
+	if err := b.Withdraw("C1", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer("C1", "S1", 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deposit("S1", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Withdraw("S1", 5); err != nil {
+		t.Fatal(err)
+	}
+	c1, _ := b.Account("C1")
+	if got := c1.CheckBalance(); got != 68.25 {
+		t.Errorf("C1 balance %.2f, want 68.25 after a 1.50 and a 0.25 fee", got)
+	}
+	s1, _ := b.Account("S1")
+	if got := s1.CheckBalance(); got != 20 {
+		t.Errorf("S1 balance %.2f, want 20 without a product", got)
+	}
+
+	// Neither the withdrawal nor its fee posts when they do not both fit.
+	if err := b.Withdraw("C1", 68.25); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("withdrawing the whole balance: %v, want ErrInsufficientFunds", err)
+	}
+	results := b.TransferBatch([]TransferRequest{{From: "C1", To: "S1", Amount: 40}, {From: "C1", To: "S1", Amount: 28}})
+	if results[0].Err != nil || !errors.Is(results[1].Err, ErrInsufficientFunds) {
+		t.Errorf("batch results %v and %v, want the second short of its fee", results[0].Err, results[1].Err)
+	}
+	if got, entries := c1.CheckBalance(), len(c1.History()); got != 28 || entries != 7 {
+		t.Errorf("C1 balance %.2f with %d entries, want 28 with 7", got, entries)
+	}
+}
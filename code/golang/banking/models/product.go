@@ -0,0 +1,365 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// ProductKind is the kind of account a product opens.
+type ProductKind string
+
+const (
+	ProductSavings  ProductKind = "savings"
+	ProductChecking ProductKind = "checking"
+)
+
+var (
+	ErrProductNotFound      = banking.New(banking.CodeNotFound, "product not found")
+	ErrInvalidProduct       = banking.New(banking.CodeInvalidArgument, "invalid product")
+	ErrTransactionLimit     = banking.New(banking.CodeLimitExceeded, "amount is over the per-transaction limit")
+	ErrDailyWithdrawalLimit = banking.New(banking.CodeLimitExceeded, "daily withdrawal limit reached")
+)
+
+// OverdraftPolicy is how far below zero a checking product may go, while
+// overdrafts are enabled, and the fee AssessFees charges for each day an
+// account ends overdrawn.
+type OverdraftPolicy struct {
+	Limit float64 `json:"limit,omitempty"`
+	Fee   float64 `json:"fee,omitempty"`
+}
+
+// ProductLimits cap the withdrawals from an account, transfers out
+// included; zero means unlimited. DailyWithdrawal counts the calendar day of
+// the bank's clock.
+type ProductLimits struct {
+	PerTransaction  float64 `json:"per_transaction,omitempty"`
+	DailyWithdrawal float64 `json:"daily_withdrawal,omitempty"`
+}
+
+// Product is an account product the bank offers: the kind of account it
+// opens, its rate, fees, limits and overdraft policy. Accounts remember the
+// code of the product they were opened with, so a change to the product
+// changes their fees and limits but not a rate or overdraft limit already
+// set on them. Currency is the currency of its accounts, the bank's when
+// empty. DayCount is the day-count convention savings products accrue
+// interest under; without one they earn their rate once per period.
+// WithdrawalFee and TransferFee are charged on each withdrawal from and
+// transfer out of its accounts.
+type Product struct {
+	Code          string          `json:"code"`
+	Name          string          `json:"name"`
+	Kind          ProductKind     `json:"kind"`
+	InterestRate  money.Rate      `json:"interest_rate,omitempty"`
+	MonthlyFee    float64         `json:"monthly_fee,omitempty"`
+	WithdrawalFee float64         `json:"withdrawal_fee,omitempty"`
+	TransferFee   float64         `json:"transfer_fee,omitempty"`
+	Overdraft     OverdraftPolicy `json:"overdraft"`
+	Limits        ProductLimits   `json:"limits"`
+	Currency      string          `json:"currency,omitempty"`
+	DayCount      money.DayCount  `json:"day_count,omitempty"`
+	// MinimumDeposit is the least an application must be funded with to
+	// open an account of the product.
+	MinimumDeposit float64 `json:"minimum_deposit,omitempty"`
+}
+
+// DefaultProducts are the products of a new bank: a savings and a checking
+// account without fees or limits.
+var DefaultProducts = []Product{
+	{Code: "savings", Name: "Savings", Kind: ProductSavings, InterestRate: money.Percent(2)},
+	{Code: "checking", Name: "Checking", Kind: ProductChecking, Overdraft: OverdraftPolicy{Limit: 200}},
+}
+
+func (p Product) validate() error {
+	invalid := func(msg string) error { return fmt.Errorf("%w %q: %s", ErrInvalidProduct, p.Code, msg) }
+	switch {
+	case p.Code == "":
+		return invalid("code is required")
+	case p.Kind != ProductSavings && p.Kind != ProductChecking:
+		return invalid(fmt.Sprintf("unknown kind %q, want savings or checking", p.Kind))
+	case p.Kind != ProductSavings && p.InterestRate != 0:
+		return invalid("only savings products earn interest")
+	case p.Kind != ProductChecking && p.Overdraft != OverdraftPolicy{}:
+		return invalid("only checking products have an overdraft")
+	case p.MonthlyFee < 0 || p.WithdrawalFee < 0 || p.TransferFee < 0 || p.Overdraft.Limit < 0 || p.Overdraft.Fee < 0:
+		return invalid("fees and overdraft limit must not be negative")
+	case p.Limits.PerTransaction < 0 || p.Limits.DailyWithdrawal < 0:
+		return invalid("limits must not be negative")
+	case p.MinimumDeposit < 0:
+		return invalid("minimum deposit must not be negative")
+	case p.Kind != ProductSavings && p.DayCount != "":
+		return invalid("only savings products have a day-count convention")
+	case p.DayCount != "" && !p.DayCount.Valid():
+		return invalid(fmt.Sprintf("unknown day-count convention %q", p.DayCount))
+	case p.Currency != "" && !ValidCurrency(p.Currency):
+		return invalid(fmt.Sprintf("currency %q is not a three letter ISO 4217 code", p.Currency))
+	}
+	return nil
+}
+
+// Catalog is the set of products a bank offers, by code.
+type Catalog struct {
+	mu       sync.RWMutex
+	products map[string]Product
+}
+
+// NewCatalog returns a catalog of products, which must be valid.
+func NewCatalog(products ...Product) *Catalog {
+	c := &Catalog{products: make(map[string]Product)}
+	for _, p := range products {
+		if err := c.Define(p); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+// Define adds a product to the catalog or replaces the one with its code.
+func (c *Catalog) Define(p Product) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.products[p.Code] = p
+	return nil
+}
+
+// Product returns the product with a code.
+func (c *Catalog) Product(code string) (Product, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, ok := c.products[code]
+	if !ok {
+		return Product{}, fmt.Errorf("%w: %s", ErrProductNotFound, code)
+	}
+	return p, nil
+}
+
+// Products returns every product ordered by code.
+func (c *Catalog) Products() []Product {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	products := make([]Product, 0, len(c.products))
+	for _, p := range c.products {
+		products = append(products, p)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Code < products[j].Code })
+	return products
+}
+
+// OpenAccount opens account number as a product of the catalog, with the
+// product's rate and overdraft limit, for a customer unless customerID is
+// empty.
+func (b *Bank) OpenAccount(productCode, customerID, number string) (BankAccount, error) {
+	p, err := b.Products.Product(productCode)
+	if err != nil {
+		return nil, err
+	}
+	base := Account{AccountNumber: number, Product: p.Code, Currency: p.Currency}
+	var account BankAccount
+	switch p.Kind {
+	case ProductSavings:
+		account = &SavingsAccount{Account: base, InterestRate: p.InterestRate}
+	default:
+		account = &CheckingAccount{Account: base, OverdraftLimit: p.Overdraft.Limit}
+	}
+	if customerID == "" {
+		err = b.Open(account)
+	} else {
+		err = b.OpenFor(customerID, account)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ProductOf returns the product an account was opened with, and false for
+// accounts opened without one or with a product no longer in the catalog.
+func (b *Bank) ProductOf(number string) (Product, bool) {
+	account, err := b.Account(number)
+	if err != nil {
+		return Product{}, false
+	}
+	return b.productOf(account)
+}
+
+func (b *Bank) productOf(account BankAccount) (Product, bool) {
+	code := ProductCodeOf(account)
+	if code == "" {
+		return Product{}, false
+	}
+	p, err := b.Products.Product(code)
+	return p, err == nil
+}
+
+// ProductCodeOf returns the code of the product an account was opened with,
+// or "" for none.
+func ProductCodeOf(account BankAccount) string {
+	if l, ok := account.(ledgered); ok {
+		return l.ledger().Product
+	}
+	return ""
+}
+
+// checkProductLimits checks a withdrawal against the limits of the
+// account's product. The caller holds the account's lock or b.mu.
+func (b *Bank) checkProductLimits(account BankAccount, tx Transaction) error {
+	p, ok := b.productOf(account)
+	if !ok || tx.Type != TransactionWithdrawal {
+		return nil
+	}
+	if limit := p.Limits.PerTransaction; limit > 0 && tx.Amount > limit {
+		return fmt.Errorf("%w (%.2f)", ErrTransactionLimit, limit)
+	}
+	if limit := p.Limits.DailyWithdrawal; limit > 0 {
+		y, m, d := tx.Time.Date()
+		total := tx.Amount
+		for _, t := range account.History() {
+			if ty, tm, td := t.Time.In(tx.Time.Location()).Date(); t.Type == TransactionWithdrawal && ty == y && tm == m && td == d {
+				total += t.Amount
+			}
+		}
+		if total > limit {
+			return fmt.Errorf("%w (%.2f)", ErrDailyWithdrawalLimit, limit)
+		}
+	}
+	return nil
+}
+
+// feeFor is the metadata key of a product fee: the month of a monthly fee,
+// the day of an overdraft fee or the entry a withdrawal or transfer fee is
+// charged for, so each is charged once.
+const feeFor = "fee_for"
+
+// opFee returns the withdrawal or transfer fee of the product of account,
+// 0 without one.
+func (b *Bank) opFee(account BankAccount, kind OpKind) float64 {
+	p, _ := b.productOf(account)
+	switch kind {
+	case OpWithdrawal:
+		return p.WithdrawalFee
+	case OpTransfer:
+		return p.TransferFee
+	}
+	return 0
+}
+
+// postWithOpFeeLocked runs post, which posts a withdrawal or transfer of
+// amount out of account and returns its debit first, then charges the
+// withdrawal or transfer fee of account's product for it. Amount and fee
+// are checked against what is available before either is posted, and post
+// is undone if the fee cannot be charged, so both are posted or neither.
+// touched names the accounts post changes; the caller holds them.
+func (b *Bank) postWithOpFeeLocked(account BankAccount, kind OpKind, amount float64, touched TransferRequest, post func() ([]postedEntry, error)) ([]postedEntry, error) {
+	fee := b.opFee(account, kind)
+	if fee <= 0 {
+		return post()
+	}
+	if err := b.checkFeeFundsLocked(account, amount, fee); err != nil {
+		return nil, err
+	}
+	undo := b.snapshot([]TransferRequest{touched})
+	posted, err := post()
+	if err != nil {
+		return posted, err
+	}
+	entry, events, err := b.postLocked(account, TransactionFee, fee, []TxOption{
+		WithDescription(string(kind) + " fee"), WithMetadata(feeFor, string(kind)+":"+posted[0].tx.ID),
+	})
+	if err != nil {
+		undo.restore()
+		return nil, err
+	}
+	return append(posted, postedEntry{account.Number(), entry, events}), nil
+}
+
+// checkFeeFundsLocked checks that account has amount and a fee on top
+// available, its overdraft or credit limit included. Fees post whatever the
+// balance, so they are checked before what they are charged for. The caller
+// holds the account.
+func (b *Bank) checkFeeFundsLocked(account BankAccount, amount, fee float64) error {
+	available := account.CheckBalance() - b.held[account.Number()] + b.overdraftLimit(account)
+	if amount+fee > available {
+		return fmt.Errorf("%w: %.2f and a fee of %.2f", ErrInsufficientFunds, amount, fee)
+	}
+	return nil
+}
+
+// AssessFees charges the fees of every open account's product that have
+// come due: the monthly fee once a calendar month and the overdraft fee for
+// a day the balance is below zero. Custom accounts that are a FeeAssessor
+// charge their own fees too. It returns the numbers of the accounts
+// charged.
+func (b *Bank) AssessFees() []string {
+	now := b.now()
+	month := "monthly:" + now.Format("2006-01")
+	day := "overdraft:" + now.Format(time.DateOnly)
+	b.mu.Lock()
+	var charged []string
+	var events []Event
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		if !b.closed[number].IsZero() {
+			continue
+		}
+		posted := false
+		if assessor, ok := account.(FeeAssessor); ok {
+			legs, _ := b.postEntries(account, assessor.AssessFees(now))
+			events = append(events, legs...)
+			posted = len(legs) > 0
+		}
+		p, ok := b.productOf(account)
+		if !ok {
+			if posted {
+				charged = append(charged, number)
+			}
+			continue
+		}
+		type fee struct {
+			key, description string
+			amount           float64
+		}
+		var due []fee
+		if p.MonthlyFee > 0 {
+			due = append(due, fee{month, "monthly fee", p.MonthlyFee})
+		}
+		if p.Overdraft.Fee > 0 && account.CheckBalance() < 0 {
+			due = append(due, fee{day, "overdraft fee", p.Overdraft.Fee})
+		}
+		paid := make(map[string]bool)
+		for _, tx := range account.History() {
+			if tx.Type == TransactionFee && tx.Metadata[feeFor] != "" {
+				paid[tx.Metadata[feeFor]] = true
+			}
+		}
+		for _, f := range due {
+			if paid[f.key] {
+				continue
+			}
+			tx, legs, err := b.postLocked(account, TransactionFee, f.amount, []TxOption{
+				WithDescription(f.description), WithMetadata(feeFor, f.key),
+			})
+			events = append(events, legs...)
+			if err == nil {
+				events = append(events, Event{Type: EventTransactionPosted, AccountNumber: number, Transaction: &tx, Time: tx.Time})
+				posted = true
+			}
+		}
+		if posted {
+			charged = append(charged, number)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	sort.Strings(charged)
+	return charged
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// TestOpenFromCatalog opens accounts from a catalog and checks that they
+// take the terms of their product, that its limits cap withdrawals, that
+// its monthly and overdraft fees are charged once per month and day, and
+// that invalid and unknown products are refused.
+func TestOpenFromCatalog(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-03-02").Add(9 * time.Hour)}
+	b := NewBank()
+	b.Clock = clock
+	b.Products = NewCatalog(
+		Product{Code: "plus", Name: "Plus", Kind: ProductChecking, MonthlyFee: 5,
+			Overdraft: OverdraftPolicy{Limit: 300, Fee: 10}, Limits: ProductLimits{PerTransaction: 400, DailyWithdrawal: 500}},
+		Product{Code: "saver", Name: "Saver", Kind: ProductSavings, InterestRate: money.Percent(3)},
+	)
+	for _, p := range []Product{
+		{Name: "No code", Kind: ProductChecking},
+		{Code: "cd", Kind: "deposit"},
+		{Code: "x", Kind: ProductChecking, InterestRate: money.Percent(1)},
+		{Code: "x", Kind: ProductSavings, Overdraft: OverdraftPolicy{Limit: 10}},
+		{Code: "x", Kind: ProductChecking, MonthlyFee: -1},
+		{Code: "x", Kind: ProductSavings, Currency: "euro"},
+	} {
+		if err := b.Products.Define(p); !errors.Is(err, ErrInvalidProduct) {
+			t.Errorf("defining %+v: %v, want ErrInvalidProduct", p, err)
+		}
+	}
+	if _, err := b.OpenAccount("gold", "", "C9"); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("opening an unknown product: %v, want ErrProductNotFound", err)
+	}
+
+	checking, err := b.OpenAccount("plus", "", "C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savings, err := b.OpenAccount("saver", "", "S1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := checking.(*CheckingAccount); c.OverdraftLimit != 300 || ProductCodeOf(c) != "plus" {
+		t.Errorf("C1 has overdraft limit %.2f and product %q, want 300.00 and plus", c.OverdraftLimit, ProductCodeOf(c))
+	}
+	if s := savings.(*SavingsAccount); s.InterestRate != money.Percent(3) {
+		t.Errorf("S1 earns %s, want 3%%", s.InterestRate)
+	}
+
+	b.Deposit("C1", 200)
+	for _, tt := range []struct {
+		amount float64
+		want   error
+	}{
+		{450, ErrTransactionLimit},
+		{300, nil},
+		{250, ErrDailyWithdrawalLimit},
+		{150, nil},
+	} {
+		if err := b.Withdraw("C1", tt.amount); !errors.Is(err, tt.want) {
+			t.Errorf("withdrawing %.2f: %v, want %v", tt.amount, err, tt.want)
+		}
+	}
+
+	// C1 is 250 overdrawn: a monthly fee and a day's overdraft fee, once.
+	for _, want := range [][]string{{"C1"}, nil} {
+		if charged := b.AssessFees(); !slices.Equal(charged, want) {
+			t.Errorf("fees charged to %v, want %v", charged, want)
+		}
+	}
+	clock.now = clock.now.Add(24 * time.Hour)
+	b.AssessFees()
+	if balance, _ := b.Balance("C1"); balance != -275 {
+		t.Errorf("C1 balance after fees %.2f, want -275.00", balance)
+	}
+}
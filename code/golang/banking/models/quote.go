@@ -0,0 +1,191 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// DefaultQuoteTTL is how long a transfer quote locks its rate unless the
+// bank sets its own QuoteTTL.
+const DefaultQuoteTTL = 30 * time.Second
+
+var (
+	ErrQuoteNotFound = banking.New(banking.CodeNotFound, "transfer quote not found")
+	ErrQuoteExpired  = banking.New(banking.CodeConflict, "transfer quote expired, quote the transfer again")
+)
+
+// FXPricing is what the bank charges for a transfer between currencies: its
+// Margin is taken off the market rate, and Fee is debited from the source
+// account in its currency.
+type FXPricing struct {
+	Margin money.Rate
+	Fee    float64
+}
+
+// TransferQuote is the price of a transfer, as QuoteTransfer gives it:
+// Amount debited from From, Credit credited to To at Rate, and Fee charged
+// besides. The rate is locked until Expires; a quote executed later than
+// that has to be asked for again.
+type TransferQuote struct {
+	ID       string       `json:"id"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Amount   float64      `json:"amount"`
+	Rate     ExchangeRate `json:"rate"`
+	Credit   float64      `json:"credit"`
+	Fee      float64      `json:"fee"`
+	Expires  time.Time    `json:"expires"`
+	Currency string       `json:"currency"`
+}
+
+// quoteID is the metadata key of the quote a transfer was executed at.
+const quoteID = "quote_id"
+
+// QuoteTransfer prices a transfer of amount from one account to the other,
+// converting at the bank's current rate less its FX margin when they are in
+// different currencies, and locks the price for the bank's QuoteTTL. The
+// quote is executed with TransferQuoted.
+func (b *Bank) QuoteTransfer(from, to string, amount float64) (TransferQuote, error) {
+	q, err := b.price(from, to, amount)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	q.ID = b.NewID()
+	now := b.now()
+	ttl := b.QuoteTTL
+	if ttl <= 0 {
+		ttl = DefaultQuoteTTL
+	}
+	q.Expires = now.Add(ttl)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Expired quotes are kept for a while to be reported as expired.
+	for id, old := range b.quotes {
+		if now.Sub(old.Expires) > ttl {
+			delete(b.quotes, id)
+		}
+	}
+	b.quotes[q.ID] = q
+	return q, nil
+}
+
+// price prices a transfer for QuoteTransfer and PreviewTransfer, leaving
+// the ID and expiry of the quote to be set.
+func (b *Bank) price(from, to string, amount float64) (TransferQuote, error) {
+	if err := (Op{Kind: OpTransfer, Account: from, To: to, Amount: amount}).validate(); err != nil {
+		return TransferQuote{}, err
+	}
+	if from == to {
+		return TransferQuote{}, banking.New(banking.CodeInvalidArgument, "cannot transfer to the same account")
+	}
+	source, err := b.Account(from)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	target, err := b.Account(to)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	currency := b.AccountCurrency(source)
+	q := TransferQuote{From: from, To: to, Amount: amount, Credit: amount, Currency: currency,
+		Rate: ExchangeRate{Base: currency, Quote: currency, Rate: 1}}
+	rate, err := b.exchangeRate(source, target)
+	if err != nil {
+		return TransferQuote{}, err
+	}
+	if rate != nil {
+		q.Rate = *rate
+		q.Rate.Rate *= 1 - b.FXPricing.Margin.Percent()/100
+		q.Credit = b.convert(amount, q.Rate)
+		q.Fee = b.FXPricing.Fee
+	}
+	return q, nil
+}
+
+// TransferQuoted executes a quote, through the bank's middleware like
+// Transfer, at the quoted rate and fee. Each quote is executed once, and
+// not after it expires: ErrQuoteExpired asks for a new one. Both legs and
+// the fee record the quote's ID as quote_id metadata.
+func (b *Bank) TransferQuoted(id string, opts ...TxOption) error {
+	q, err := b.Quote(id)
+	if err != nil {
+		return err
+	}
+	return b.run(Op{Kind: OpTransfer, Account: q.From, To: q.To, Amount: q.Amount, Quote: id, Options: opts})
+}
+
+// Quote returns a quote not executed yet, including one that has just
+// expired.
+func (b *Bank) Quote(id string) (TransferQuote, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	q, ok := b.quotes[id]
+	if !ok {
+		return TransferQuote{}, fmt.Errorf("%w: %s", ErrQuoteNotFound, id)
+	}
+	return q, nil
+}
+
+// takeQuote removes the quote a transfer executes, checking that it has
+// not expired and prices the transfer asked for, which middleware may have
+// changed.
+func (b *Bank) takeQuote(id, from, to string, amount float64) (TransferQuote, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.quotes[id]
+	if !ok {
+		return q, fmt.Errorf("%w: %s", ErrQuoteNotFound, id)
+	}
+	delete(b.quotes, id)
+	if !b.now().Before(q.Expires) {
+		return q, fmt.Errorf("%w: %s expired at %s", ErrQuoteExpired, id, q.Expires.Format(time.RFC3339))
+	}
+	if q.From != from || q.To != to || q.Amount != amount {
+		return q, banking.New(banking.CodeInvalidArgument, fmt.Sprintf("transfer quote %s is for %.2f from %s to %s", id, q.Amount, q.From, q.To))
+	}
+	return q, nil
+}
+
+// transferQuoted carries out the transfer of a quote with its rate and fee.
+func (b *Bank) transferQuoted(id, from, to string, amount float64, opts []TxOption) error {
+	q, err := b.takeQuote(id, from, to, amount)
+	if err != nil {
+		return err
+	}
+	var rate *ExchangeRate
+	if q.Rate.Base != q.Rate.Quote {
+		rate = &q.Rate
+	}
+	opts = append([]TxOption{WithMetadata(quoteID, id)}, opts...)
+	accounts, unlock := b.lockAccounts(from, to)
+	// The FX fee counts toward what the transfer fee is checked against,
+	// and is posted with the transfer or not at all.
+	touched := TransferRequest{From: from, To: to}
+	posted, err := b.postWithOpFeeLocked(accounts[from], OpTransfer, amount+q.Fee, touched, func() ([]postedEntry, error) {
+		if q.Fee <= 0 {
+			return b.transferLocked(accounts[from], accounts[to], amount, rate, opts)
+		}
+		if err := b.checkFeeFundsLocked(accounts[from], amount, q.Fee); err != nil {
+			return nil, err
+		}
+		undo := b.snapshot([]TransferRequest{touched})
+		posted, err := b.transferLocked(accounts[from], accounts[to], amount, rate, opts)
+		if err != nil {
+			return posted, err
+		}
+		fee, events, err := b.postLocked(accounts[from], TransactionFee, q.Fee, []TxOption{
+			WithDescription(fmt.Sprintf("FX fee for transfer to %s", to)), WithMetadata(quoteID, id)})
+		if err != nil {
+			undo.restore()
+			return nil, err
+		}
+		return append(posted, postedEntry{from, fee, events}), nil
+	})
+	unlock()
+	b.publishPosted(posted)
+	return err
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// TestTransferQuoted quotes transfers between currencies and checks that
+// one executed in time credits the locked rate after the market moved,
+// charges the FX fee and records the quote on every entry, and that a
+// quote executed twice or after it expired moves nothing.
+func TestTransferQuoted(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-03-02").Add(9 * time.Hour)}
+	b := NewBank()
+	b.Clock = clock
+	b.Currency = "USD"
+	b.FX = fixedRate(0.5)
+	b.FXPricing = FXPricing{Margin: money.Percent(10), Fee: 2}
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "E1", Currency: "EUR"}})
+	b.Deposit("C1", 1000)
+
+	q, err := b.QuoteTransfer("C1", "E1", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Rate.Rate != 0.45 || q.Credit != 45 || q.Fee != 2 || !q.Expires.Equal(clock.now.Add(DefaultQuoteTTL)) {
+		t.Errorf("quote %+v, want 45.00 at 0.45 with a fee of 2.00 for %s", q, DefaultQuoteTTL)
+	}
+	b.FX = fixedRate(0.8)
+	clock.now = clock.now.Add(10 * time.Second)
+	if err := b.TransferQuoted(q.ID); err != nil {
+		t.Fatal(err)
+	}
+	for number, want := range map[string]float64{"C1": 898, "E1": 45} {
+		if got, _ := b.Balance(number); got != want {
+			t.Errorf("%s balance = %.2f, want %.2f", number, got, want)
+		}
+	}
+	for _, number := range []string{"C1", "E1"} {
+		history, _ := b.History(number)
+		for _, tx := range history[len(history)-1:] {
+			if tx.Metadata[quoteID] != q.ID {
+				t.Errorf("%s entry %s %.2f has quote %q, want %s", number, tx.Type, tx.Amount, tx.Metadata[quoteID], q.ID)
+			}
+		}
+	}
+	if err := b.TransferQuoted(q.ID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("executing a quote twice: %v, want ErrQuoteNotFound", err)
+	}
+
+	same, err := b.QuoteTransfer("C1", "C2", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Rate.Rate != 1 || same.Credit != 50 || same.Fee != 0 {
+		t.Errorf("quote in one currency %+v, want 50.00 at 1 without a fee", same)
+	}
+	clock.now = clock.now.Add(DefaultQuoteTTL)
+	if err := b.TransferQuoted(same.ID); !errors.Is(err, ErrQuoteExpired) {
+		t.Errorf("executing an expired quote: %v, want ErrQuoteExpired", err)
+	}
+	if balance, _ := b.Balance("C2"); balance != 0 {
+		t.Errorf("the expired quote moved %.2f", balance)
+	}
+}
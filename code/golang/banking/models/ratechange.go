@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var (
+	ErrRateChangeNotFound = banking.New(banking.CodeNotFound, "rate change not found")
+	ErrInvalidRateChange  = banking.New(banking.CodeInvalidArgument, "invalid rate change")
+	ErrRateChangeApplied  = banking.New(banking.CodeConflict, "rate change already took effect or was cancelled")
+)
+
+// RateChange changes the rate of a savings product and of every account of
+// it on a fixed-rate from Effective on. Holders are sent an
+// EventRateChangeNotice NoticeDays days before, when Noticed is set, and the
+// change takes effect when ApplyRateChanges runs on or after Effective,
+// when Applied is set and Accounts lists the accounts it changed. Interest
+// accrued under a day-count convention is split at Effective, the days
+// before earning the rate the account had; see RateReset.Change.
+type RateChange struct {
+	ID         int        `json:"id"`
+	Product    string     `json:"product"`
+	Rate       money.Rate `json:"rate"`
+	Effective  time.Time  `json:"effective"`
+	NoticeDays int        `json:"notice_days"`
+	Created    time.Time  `json:"created"`
+	Noticed    *time.Time `json:"noticed,omitempty"`
+	Applied    *time.Time `json:"applied,omitempty"`
+	Cancelled  *time.Time `json:"cancelled,omitempty"`
+	Accounts   []string   `json:"accounts,omitempty"`
+}
+
+// noticeFrom is when the holders of the product are told of the change.
+func (c *RateChange) noticeFrom() time.Time {
+	return c.Effective.AddDate(0, 0, -c.NoticeDays)
+}
+
+func (c *RateChange) pending() bool {
+	return c.Applied == nil && c.Cancelled == nil
+}
+
+func (c *RateChange) clone() RateChange {
+	clone := *c
+	clone.Accounts = append([]string(nil), c.Accounts...)
+	return clone
+}
+
+// ScheduleRateChange schedules the rate of a savings product to change to
+// rate on effective, with noticeDays days of notice to its holders, so
+// effective must be at least that far off.
+func (b *Bank) ScheduleRateChange(product string, rate money.Rate, effective time.Time, noticeDays int) (RateChange, error) {
+	p, err := b.Products.Product(product)
+	if err != nil {
+		return RateChange{}, err
+	}
+	now := b.now()
+	change := &RateChange{Product: p.Code, Rate: rate, Effective: effective, NoticeDays: noticeDays, Created: now}
+	switch {
+	case p.Kind != ProductSavings:
+		return RateChange{}, fmt.Errorf("%w: only savings products earn interest", ErrInvalidRateChange)
+	case noticeDays < 0:
+		return RateChange{}, fmt.Errorf("%w: notice_days must not be negative", ErrInvalidRateChange)
+	case !effective.After(now):
+		return RateChange{}, fmt.Errorf("%w: effective must be in the future", ErrInvalidRateChange)
+	case change.noticeFrom().Before(now):
+		return RateChange{}, fmt.Errorf("%w: effective is too soon to give %d days of notice", ErrInvalidRateChange, noticeDays)
+	case rate < 0 && !b.Flags.Enabled(b.Tenant, FeatureNegativeInterest):
+		return RateChange{}, fmt.Errorf("%w: %s", ErrFeatureDisabled, FeatureNegativeInterest)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextRateChange++
+	change.ID = b.nextRateChange
+	b.rateChanges = append(b.rateChanges, change)
+	return change.clone(), nil
+}
+
+// RateChanges returns the rate changes of a product, or of every product
+// for "", cancelled and applied ones included, by ID.
+func (b *Bank) RateChanges(product string) []RateChange {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	changes := []RateChange{}
+	for _, c := range b.rateChanges {
+		if product == "" || c.Product == product {
+			changes = append(changes, c.clone())
+		}
+	}
+	return changes
+}
+
+// CancelRateChange cancels a rate change that has not taken effect. Holders
+// already sent notice of it are not told.
+func (b *Bank) CancelRateChange(id int) (RateChange, error) {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.rateChanges {
+		if c.ID != id {
+			continue
+		}
+		if !c.pending() {
+			return RateChange{}, ErrRateChangeApplied
+		}
+		c.Cancelled = &now
+		return c.clone(), nil
+	}
+	return RateChange{}, ErrRateChangeNotFound
+}
+
+// ApplyRateChanges sends the notices of rate changes that are due, to each
+// account a change will reach, and applies the changes that have taken
+// effect, oldest first: the rate of each fixed-rate savings account of the
+// product open then is reset as of Effective, published as EventRateReset,
+// and the product's rate changes for accounts opened after. It returns the
+// changes noticed and applied.
+func (b *Bank) ApplyRateChanges() (noticed, applied []RateChange) {
+	now := b.now()
+	var events []Event
+	b.mu.Lock()
+	due := make([]*RateChange, 0, len(b.rateChanges))
+	for _, c := range b.rateChanges {
+		if c.pending() {
+			due = append(due, c)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Effective.Before(due[j].Effective) })
+	for _, c := range due {
+		if c.Noticed == nil && !now.Before(c.noticeFrom()) {
+			for _, sa := range b.rateChangeAccounts(c.Product) {
+				events = append(events, Event{Type: EventRateChangeNotice, AccountNumber: sa.Number(), Time: now,
+					Message: fmt.Sprintf("Rate of %s changes from %s to %s on %s", sa.Number(), sa.InterestRate, c.Rate, c.Effective.Format(time.DateOnly))})
+			}
+			c.Noticed = &now
+			noticed = append(noticed, c.clone())
+		}
+		if now.Before(c.Effective) {
+			continue
+		}
+		for _, sa := range b.rateChangeAccounts(c.Product) {
+			reset := RateReset{Time: c.Effective, From: sa.InterestRate, To: c.Rate, Change: c.ID}
+			sa.InterestRate = c.Rate
+			sa.RateResets = append(sa.RateResets, reset)
+			c.Accounts = append(c.Accounts, sa.Number())
+			events = append(events, Event{Type: EventRateReset, AccountNumber: sa.Number(), Time: c.Effective,
+				Message: fmt.Sprintf("Rate of %s changed from %s to %s (rate change %d)", sa.Number(), reset.From, reset.To, c.ID)})
+		}
+		if p, err := b.Products.Product(c.Product); err == nil {
+			p.InterestRate = c.Rate
+			b.Products.Define(p)
+		}
+		c.Applied = &now
+		applied = append(applied, c.clone())
+	}
+	b.mu.Unlock()
+
+	for _, e := range events {
+		b.Events.Publish(e)
+	}
+	return noticed, applied
+}
+
+// rateChangeAccounts returns the open fixed-rate savings accounts of a
+// product, by number. The caller holds b.mu.
+func (b *Bank) rateChangeAccounts(product string) []*SavingsAccount {
+	var accounts []*SavingsAccount
+	for _, account := range b.accounts.all() {
+		sa, ok := account.(*SavingsAccount)
+		if ok && sa.Variable == nil && sa.Product == product && b.closed[sa.Number()].IsZero() {
+			accounts = append(accounts, sa)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number() < accounts[j].Number() })
+	return accounts
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestRateChange schedules a product's rate to double three weeks into a
+// month, checks its holders are sent notice ten days before, that the
+// interest of the month is split at the change and that the audit explains
+// it.
+func TestRateChange(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	// 3.6% a year is exactly 0.01% a day over 360 days.
+	if err := b.Products.Define(Product{Code: "daily", Name: "Daily Savings", Kind: ProductSavings,
+		InterestRate: money.Percent(3.6), DayCount: money.Actual360}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.OpenAccount("daily", "", "S1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deposit("S1", 10000); err != nil {
+		t.Fatal(err)
+	}
+	var notices []Event
+	b.Events.Subscribe(func(e Event) {
+		if e.Type == EventRateChangeNotice {
+			notices = append(notices, e)
+		}
+	})
+
+	if _, err := b.ScheduleRateChange("daily", money.Percent(7.2), date(t, "2026-01-05"), 10); !errors.Is(err, ErrInvalidRateChange) {
+		t.Errorf("scheduling without time for notice: %v, want %v", err, ErrInvalidRateChange)
+	}
+	if _, err := b.ScheduleRateChange("checking", money.Percent(1), date(t, "2026-02-01"), 0); !errors.Is(err, ErrInvalidRateChange) {
+		t.Errorf("scheduling a checking product: %v, want %v", err, ErrInvalidRateChange)
+	}
+	change, err := b.ScheduleRateChange("daily", money.Percent(7.2), date(t, "2026-01-21"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = date(t, "2026-01-10")
+	if noticed, applied := b.ApplyRateChanges(); len(noticed)+len(applied) != 0 || len(notices) != 0 {
+		t.Errorf("eleven days before: noticed %v, applied %v", noticed, applied)
+	}
+	clock.now = date(t, "2026-01-11")
+	if noticed, _ := b.ApplyRateChanges(); len(noticed) != 1 || len(notices) != 1 || notices[0].AccountNumber != "S1" {
+		t.Errorf("ten days before: noticed %v, notices %v", noticed, notices)
+	}
+
+	// 20 days at 0.01% a day and 10 at 0.02%.
+	clock.now = date(t, "2026-01-31")
+	if err := b.ApplyInterest("S1"); err != nil {
+		t.Fatal(err)
+	}
+	account, _ := b.Account("S1")
+	history := account.History()
+	if tx := history[len(history)-1]; tx.Amount != 40 || tx.Rate != money.Percent(7.2) {
+		t.Errorf("interest %v at %s, want 40 at 7.2%%", tx.Amount, tx.Rate)
+	}
+	if p, _ := b.Products.Product("daily"); p.InterestRate != money.Percent(7.2) {
+		t.Errorf("product rate %s after the change, want 7.2%%", p.InterestRate)
+	}
+	changes := b.RateChanges("daily")
+	if len(changes) != 1 || changes[0].Applied == nil || len(changes[0].Accounts) != 1 {
+		t.Errorf("rate changes %+v", changes)
+	}
+	if audit, err := b.AuditInterest("S1", date(t, "2026-01-01"), date(t, "2026-02-01")); err != nil || audit.Unexplained != 0 || audit.RateMismatches != 0 {
+		t.Errorf("audit %+v, %v", audit, err)
+	}
+	if _, err := b.CancelRateChange(change.ID); !errors.Is(err, ErrRateChangeApplied) {
+		t.Errorf("cancelling an applied change: %v, want %v", err, ErrRateChangeApplied)
+	}
+}
+
+// TestVariableRate checks the rate a variable rate works out to from its
+// reference rate, spread, floor and cap, and that one whose floor is above
+// its cap is refused.
+func TestVariableRate(t *testing.T) {
+	rate := func(p float64) *money.Rate {
+		r := money.Percent(p)
+		return &r
+	}
+	rates := StaticRates{"base": money.Percent(3), "negative": money.Percent(-1)}
+	for _, tt := range []struct {
+		name     string
+		variable VariableRate
+		provider RateProvider
+		want     money.Rate
+		err      error
+	}{
+		{"spread", VariableRate{Reference: "base", Spread: money.Percent(1)}, rates, money.Percent(4), nil},
+		{"floor", VariableRate{Reference: "negative", Spread: money.Percent(0.5), Floor: rate(0)}, rates, 0, nil},
+		{"cap", VariableRate{Reference: "base", Spread: money.Percent(1), Cap: rate(3.5)}, rates, money.Percent(3.5), nil},
+		{"within bounds", VariableRate{Reference: "base", Floor: rate(1), Cap: rate(5)}, rates, money.Percent(3), nil},
+		{"floor at cap", VariableRate{Reference: "base", Floor: rate(2), Cap: rate(2)}, rates, money.Percent(2), nil},
+		{"floor above cap", VariableRate{Reference: "base", Floor: rate(5), Cap: rate(4)}, rates, 0, ErrInvalidVariableRate},
+		{"no reference", VariableRate{Spread: money.Percent(1)}, rates, 0, ErrInvalidVariableRate},
+	} {
+		got, _, err := tt.variable.Effective(tt.provider)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%s: Effective = %s, %v, want %s, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+	if _, _, err := (&VariableRate{Reference: "libor"}).Effective(rates); err == nil {
+		t.Error("Effective with an unknown reference rate succeeded")
+	}
+	if _, _, err := (&VariableRate{Reference: "base"}).Effective(nil); err == nil {
+		t.Error("Effective without a provider succeeded")
+	}
+
+	b := NewBank()
+	bad := &SavingsAccount{Account: Account{AccountNumber: "S1"}, Variable: &VariableRate{Reference: "base", Floor: rate(5), Cap: rate(4)}}
+	if err := b.Open(bad); !errors.Is(err, ErrInvalidVariableRate) {
+		t.Errorf("opening with the floor above the cap: %v, want ErrInvalidVariableRate", err)
+	}
+}
+
+// TestVariableRateResets applies interest as the reference rate moves and
+// checks that each move is recorded as a reset and every entry carries the
+// rate it was accrued at.
+func TestVariableRateResets(t *testing.T) {
+	SetOutput(io.Discard)
+	rates := StaticRates{"base": money.Percent(2)}
+	b := NewBank()
+	b.Rates = rates
+	sa := &SavingsAccount{Account: Account{AccountNumber: "S1"}, Variable: &VariableRate{Reference: "base", Spread: money.Percent(1)}}
+	if err := b.Open(sa); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deposit("S1", 1000); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		base   float64
+		want   money.Rate
+		resets int
+	}{
+		{2, money.Percent(3), 1},
+		{2, money.Percent(3), 1},
+		{2.5, money.Percent(3.5), 2},
+	} {
+		rates["base"] = money.Percent(tt.base)
+		if err := b.ApplyInterest("S1"); err != nil {
+			t.Fatal(err)
+		}
+		history, _ := b.History("S1")
+		if tx := history[len(history)-1]; tx.Type != TransactionInterest || tx.Rate != tt.want {
+			t.Errorf("base %v%%: entry %+v, want interest at %s", tt.base, tx, tt.want)
+		}
+		if len(sa.RateResets) != tt.resets || sa.RateResets[len(sa.RateResets)-1].ReferenceRate != money.Percent(tt.base) {
+			t.Errorf("base %v%%: resets %+v, want %d", tt.base, sa.RateResets, tt.resets)
+		}
+	}
+}
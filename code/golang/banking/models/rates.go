@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var ErrInvalidVariableRate = banking.New(banking.CodeInvalidArgument, "invalid variable rate")
+
+// RateProvider supplies the current value of reference rates such as a
+// central bank base rate.
+type RateProvider interface {
+	ReferenceRate(name string) (money.Rate, error)
+}
+
+// StaticRates is a RateProvider backed by a fixed table, for tests, demos and
+// rates that are set by hand.
+type StaticRates map[string]money.Rate
+
+func (r StaticRates) ReferenceRate(name string) (money.Rate, error) {
+	rate, ok := r[name]
+	if !ok {
+		return 0, banking.New(banking.CodeNotFound, fmt.Sprintf("unknown reference rate %q", name))
+	}
+	return rate, nil
+}
+
+// VariableRate defines an interest rate as a reference rate plus a spread.
+// Floor and Cap, when set, bound the resulting rate; the floor may not be
+// above the cap.
+type VariableRate struct {
+	Reference string
+	Spread    money.Rate
+	Floor     *money.Rate
+	Cap       *money.Rate
+}
+
+func (v *VariableRate) validate() error {
+	switch {
+	case v.Reference == "":
+		return fmt.Errorf("%w: reference rate is required", ErrInvalidVariableRate)
+	case v.Floor != nil && v.Cap != nil && *v.Floor > *v.Cap:
+		return fmt.Errorf("%w: floor %s is above cap %s", ErrInvalidVariableRate, *v.Floor, *v.Cap)
+	}
+	return nil
+}
+
+// Effective returns the rate that applies with the reference rate p reports
+// now, and the reference rate itself.
+func (v *VariableRate) Effective(p RateProvider) (rate, reference money.Rate, err error) {
+	if err := v.validate(); err != nil {
+		return 0, 0, err
+	}
+	if p == nil {
+		return 0, 0, banking.New(banking.CodeUnavailable, "no reference rate provider configured")
+	}
+	reference, err = p.ReferenceRate(v.Reference)
+	if err != nil {
+		return 0, 0, err
+	}
+	rate = reference + v.Spread
+	if v.Floor != nil && rate < *v.Floor {
+		rate = *v.Floor
+	}
+	if v.Cap != nil && rate > *v.Cap {
+		rate = *v.Cap
+	}
+	return rate, reference, nil
+}
+
+// RateReset records a change of a savings account's interest rate: of a
+// variable rate from its reference rate, or a scheduled RateChange, whose ID
+// Change is, taking effect at Time.
+type RateReset struct {
+	Time          time.Time
+	Reference     string
+	ReferenceRate money.Rate
+	From, To      money.Rate
+	Change        int `json:",omitempty"`
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var (
+	ErrReceiptNotFound  = banking.New(banking.CodeNotFound, "receipt not found")
+	ErrReceiptInvalid   = banking.New(banking.CodeInvalidArgument, "receipt signature does not match; it was altered or not issued by this bank")
+	ErrReceiptsUnsigned = banking.New(banking.CodeFeatureDisabled, "receipts are not signed; set receipt_key")
+)
+
+// Receipt is the proof of a completed operation, made from the ledger
+// entries it posted so that every operation has one. Reference is what the
+// receipt is asked for by: the transfer ID for both legs of a transfer, the
+// entry ID otherwise. Kind is "transfer" or the type of the entry.
+//
+// From and To are the parties, account numbers or the counterparty of a
+// deposit or withdrawal. Amount left From in Currency; Credit reached To in
+// CreditCurrency when a transfer converted it. Fee is what the operation
+// charged besides. Balances are the running balances of the accounts it
+// posted to right after it. Signature is an HMAC of the rest under the
+// bank's ReceiptKey, empty when it has none.
+type Receipt struct {
+	Reference      string             `json:"reference"`
+	Kind           string             `json:"kind"`
+	From           string             `json:"from,omitempty"`
+	To             string             `json:"to,omitempty"`
+	Amount         float64            `json:"amount"`
+	Currency       string             `json:"currency"`
+	Credit         float64            `json:"credit,omitempty"`
+	CreditCurrency string             `json:"credit_currency,omitempty"`
+	Fee            float64            `json:"fee,omitempty"`
+	Description    string             `json:"description,omitempty"`
+	Time           time.Time          `json:"time"`
+	Balances       map[string]float64 `json:"balances"`
+	Signature      string             `json:"signature,omitempty"`
+}
+
+// Receipt returns the receipt of the operation a reference names, the entry
+// ID of either leg of a transfer naming the transfer.
+func (b *Bank) Receipt(reference string) (Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.receiptLocked(reference)
+}
+
+// receiptLocked is Receipt holding b.mu.
+func (b *Bank) receiptLocked(reference string) (Receipt, error) {
+	type found struct {
+		account BankAccount
+		index   int
+	}
+	var entries []found
+	transfer := ""
+	for _, account := range b.accounts.all() {
+		for i, tx := range account.History() {
+			if tx.ID == reference && tx.Metadata[MetaTransferID] != "" {
+				transfer = tx.Metadata[MetaTransferID]
+			}
+			if tx.ID == reference || tx.Metadata[MetaTransferID] == reference {
+				entries = append(entries, found{account, i})
+			}
+		}
+	}
+	if transfer != "" && transfer != reference {
+		return b.receiptLocked(transfer)
+	}
+	if len(entries) == 0 {
+		return Receipt{}, ErrReceiptNotFound
+	}
+	r := Receipt{Reference: reference, Balances: make(map[string]float64)}
+	for _, e := range entries {
+		tx := e.account.History()[e.index]
+		number := e.account.Number()
+		r.Balances[number] = balanceAfter(e.account, e.index)
+		if r.Time.IsZero() || tx.Time.After(r.Time) {
+			r.Time = tx.Time
+		}
+		switch {
+		case tx.Metadata[MetaTransferID] == "":
+			r.Kind, r.Amount, r.Currency, r.Description = string(tx.Type), tx.Amount, b.AccountCurrency(e.account), tx.Description
+			if tx.Type.IsCredit() {
+				r.From, r.To = tx.Counterparty, number
+			} else {
+				r.From, r.To = number, tx.Counterparty
+			}
+		case tx.Type == TransactionWithdrawal:
+			r.Kind, r.From, r.To = "transfer", number, tx.Counterparty
+			r.Amount, r.Currency, r.Description = tx.Amount, b.AccountCurrency(e.account), tx.Description
+		case tx.Type == TransactionDeposit:
+			r.Credit, r.CreditCurrency = tx.Amount, b.AccountCurrency(e.account)
+		}
+	}
+	if r.Kind == "transfer" {
+		b.quotedFee(&r)
+		if r.CreditCurrency == r.Currency {
+			r.Credit, r.CreditCurrency = 0, ""
+		}
+	}
+	r.Signature = b.signReceipt(r)
+	return r, nil
+}
+
+// quotedFee adds the FX fee of a transfer executed at a quote, which the
+// fee entry records by the quote's ID rather than the transfer's.
+func (b *Bank) quotedFee(r *Receipt) {
+	from := b.accounts.account(r.From)
+	if from == nil {
+		return
+	}
+	history := from.History()
+	quote := ""
+	for _, tx := range history {
+		if tx.Metadata[MetaTransferID] == r.Reference && tx.Metadata[quoteID] != "" {
+			quote = tx.Metadata[quoteID]
+		}
+	}
+	if quote == "" {
+		return
+	}
+	for i, tx := range history {
+		if tx.Type == TransactionFee && tx.Metadata[quoteID] == quote && tx.Metadata[MetaTransferID] == "" {
+			r.Fee += tx.Amount
+			r.Balances[r.From] = balanceAfter(from, i)
+		}
+	}
+}
+
+// balanceAfter is the balance of account right after its ith entry was
+// posted, to the cent.
+func balanceAfter(account BankAccount, i int) float64 {
+	balance := account.CheckBalance()
+	for _, tx := range account.History()[i+1:] {
+		if tx.Type.IsCredit() {
+			balance -= tx.Amount
+		} else {
+			balance += tx.Amount
+		}
+	}
+	return money.Round(balance, "", money.HalfEven)
+}
+
+// VerifyReceipt checks that a receipt was issued by the bank as it is:
+// ErrReceiptInvalid when any of it was changed, ErrReceiptsUnsigned when the
+// bank has no ReceiptKey to check it with.
+func (b *Bank) VerifyReceipt(r Receipt) error {
+	if len(b.ReceiptKey) == 0 {
+		return ErrReceiptsUnsigned
+	}
+	want, err := hex.DecodeString(b.signReceipt(r))
+	if err != nil {
+		return err
+	}
+	got, err := hex.DecodeString(r.Signature)
+	if err != nil || !hmac.Equal(got, want) {
+		return ErrReceiptInvalid
+	}
+	return nil
+}
+
+// signReceipt is the HMAC-SHA256 of a receipt's JSON without its signature,
+// in hex, or "" when the bank has no ReceiptKey. Times are compared as
+// written, so a receipt read back from JSON checks the same.
+func (b *Bank) signReceipt(r Receipt) string {
+	if len(b.ReceiptKey) == 0 {
+		return ""
+	}
+	r.Signature = ""
+	data, _ := json.Marshal(r)
+	mac := hmac.New(sha256.New, b.ReceiptKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestReceipts checks the receipts of a deposit, a transfer and a transfer
+// executed at a quote, that one read back from JSON verifies and that an
+// altered one does not.
+func TestReceipts(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Currency = "USD"
+	b.IDs = &SequentialIDs{Prefix: "id-"}
+	b.FX = fixedRate(0.5)
+	b.FXPricing = FXPricing{Margin: money.Percent(10), Fee: 2}
+	b.ReceiptKey = []byte("secret")
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "E1", Currency: "EUR"}})
+	if err := b.Deposit("C1", 100, WithCounterparty("payroll")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Transfer("C1", "S1", 30); err != nil {
+		t.Fatal(err)
+	}
+
+	deposit, err := b.Receipt("id-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Kind != "deposit" || deposit.From != "payroll" || deposit.To != "C1" || deposit.Balances["C1"] != 100 {
+		t.Errorf("deposit receipt %+v", deposit)
+	}
+	// Either leg's ID names the transfer.
+	transfer, err := b.Receipt("id-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Reference != "id-2" || transfer.Kind != "transfer" || transfer.From != "C1" || transfer.To != "S1" ||
+		transfer.Amount != 30 || transfer.Balances["C1"] != 70 || transfer.Balances["S1"] != 30 {
+		t.Errorf("transfer receipt %+v", transfer)
+	}
+
+	q, err := b.QuoteTransfer("C1", "E1", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.TransferQuoted(q.ID); err != nil {
+		t.Fatal(err)
+	}
+	c1, _ := b.Account("C1")
+	history := c1.History()
+	fx, err := b.Receipt(history[len(history)-2].Metadata[MetaTransferID])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fx.Credit != 9 || fx.CreditCurrency != "EUR" || fx.Fee != 2 || fx.Balances["C1"] != 48 {
+		t.Errorf("FX transfer receipt %+v, want 9 EUR credited for 20 USD and a 2 USD fee, leaving 48", fx)
+	}
+
+	data, _ := json.Marshal(fx)
+	var saved Receipt
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.VerifyReceipt(saved); err != nil {
+		t.Errorf("the receipt read back does not verify: %v", err)
+	}
+	saved.Amount = 2
+	if err := b.VerifyReceipt(saved); !errors.Is(err, ErrReceiptInvalid) {
+		t.Errorf("altered receipt: %v, want ErrReceiptInvalid", err)
+	}
+	if _, err := b.Receipt("nope"); !errors.Is(err, ErrReceiptNotFound) {
+		t.Errorf("unknown reference: %v, want ErrReceiptNotFound", err)
+	}
+}
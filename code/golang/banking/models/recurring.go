@@ -0,0 +1,148 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// cadences lists the supported payment rhythms with the typical gap between
+// two payments and how far a single gap may drift from it.
+var cadences = []struct {
+	cadence   Cadence
+	interval  time.Duration
+	tolerance time.Duration
+}{
+	{CadenceWeekly, 7 * day, 1 * day},
+	{CadenceBiweekly, 14 * day, 2 * day},
+	{CadenceMonthly, 30 * day, 4 * day},
+	{CadenceYearly, 365 * day, 7 * day},
+}
+
+// minOccurrences is how many payments are needed before a series counts as
+// recurring, and amountTolerance is how far (as a fraction) each payment may
+// be from the typical amount of its series.
+const (
+	minOccurrences  = 3
+	amountTolerance = 0.10
+)
+
+// RecurringPayment is a series of outgoing payments to the same counterparty
+// with a similar amount and a regular cadence, such as rent or a subscription.
+// Missed counts the payments the series skipped.
+type RecurringPayment struct {
+	Counterparty string    `json:"counterparty"`
+	Amount       float64   `json:"amount"`
+	Cadence      Cadence   `json:"cadence"`
+	Occurrences  int       `json:"occurrences"`
+	Missed       int       `json:"missed,omitempty"`
+	LastPaid     time.Time `json:"last_paid"`
+	NextDue      time.Time `json:"next_due"`
+}
+
+// DetectRecurring scans a ledger for recurring outgoing payments. Only
+// withdrawals with a known counterparty are considered. Results are ordered by
+// their next due date.
+func DetectRecurring(history []Transaction) []RecurringPayment {
+	byCounterparty := make(map[string][]Transaction)
+	for _, tx := range history {
+		if tx.Type != TransactionWithdrawal || tx.Counterparty == "" {
+			continue
+		}
+		byCounterparty[tx.Counterparty] = append(byCounterparty[tx.Counterparty], tx)
+	}
+
+	var payments []RecurringPayment
+	for counterparty, txs := range byCounterparty {
+		if payment, ok := detectSeries(counterparty, txs); ok {
+			payments = append(payments, payment)
+		}
+	}
+
+	sort.Slice(payments, func(i, j int) bool {
+		if payments[i].NextDue.Equal(payments[j].NextDue) {
+			return payments[i].Counterparty < payments[j].Counterparty
+		}
+		return payments[i].NextDue.Before(payments[j].NextDue)
+	})
+	return payments
+}
+
+// UpcomingBills returns the recurring payments expected to fall due between
+// from and until, inclusive.
+func UpcomingBills(payments []RecurringPayment, from, until time.Time) []RecurringPayment {
+	var upcoming []RecurringPayment
+	for _, p := range payments {
+		if !p.NextDue.Before(from) && !p.NextDue.After(until) {
+			upcoming = append(upcoming, p)
+		}
+	}
+	return upcoming
+}
+
+func detectSeries(counterparty string, txs []Transaction) (RecurringPayment, bool) {
+	if len(txs) < minOccurrences {
+		return RecurringPayment{}, false
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Time.Before(txs[j].Time) })
+
+	amounts := make([]float64, len(txs))
+	for i, tx := range txs {
+		amounts[i] = tx.Amount
+	}
+	typical := median(amounts)
+	for _, amount := range amounts {
+		if diff := amount - typical; diff > typical*amountTolerance || -diff > typical*amountTolerance {
+			return RecurringPayment{}, false
+		}
+	}
+
+	for _, c := range cadences {
+		missed, ok := regular(txs, c.interval, c.tolerance)
+		if !ok {
+			continue
+		}
+		last := txs[len(txs)-1].Time
+		return RecurringPayment{
+			Counterparty: counterparty,
+			Amount:       typical,
+			Cadence:      c.cadence,
+			Occurrences:  len(txs),
+			Missed:       missed,
+			LastPaid:     last,
+			NextDue:      c.cadence.Next(last),
+		}, true
+	}
+	return RecurringPayment{}, false
+}
+
+// regular reports whether every gap between consecutive transactions is
+// within tolerance of a whole number of intervals, and how many payments the
+// longer gaps missed. Most gaps must be a single interval, so that a
+// biweekly series is not taken for a weekly one missing every other week.
+func regular(txs []Transaction, interval, tolerance time.Duration) (missed int, ok bool) {
+	single := 0
+	for i := 1; i < len(txs); i++ {
+		gap := txs[i].Time.Sub(txs[i-1].Time)
+		periods := (gap + interval/2) / interval
+		if periods == 0 || gap < periods*interval-tolerance || gap > periods*interval+tolerance {
+			return 0, false
+		}
+		if periods == 1 {
+			single++
+		}
+		missed += int(periods) - 1
+	}
+	return missed, single > len(txs)-1-single
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,52 @@
+package models
+
+import "testing"
+
+// TestDetectRecurring checks series that drift by a day or two, fall on the
+// end of the month or skipped a payment, and that irregular amounts, too few
+// payments and money coming in are not taken for bills.
+func TestDetectRecurring(t *testing.T) {
+	var history []Transaction
+	pay := func(counterparty string, amount float64, days ...string) {
+		for _, d := range days {
+			history = append(history, Transaction{Type: TransactionWithdrawal, Counterparty: counterparty, Amount: amount, Time: date(t, d)})
+		}
+	}
+	pay("gym", 20, "2026-01-02", "2026-01-09", "2026-01-17", "2026-01-23", "2026-01-30")
+	pay("rent", 900, "2025-11-30", "2025-12-31", "2026-01-31")
+	pay("streaming", 12, "2026-01-05", "2026-02-05", "2026-04-05", "2026-05-05")
+	pay("daycare", 300, "2026-01-02", "2026-01-16", "2026-01-30", "2026-02-13")
+	pay("grocer", 50, "2026-01-03", "2026-01-10")
+	pay("grocer", 90, "2026-01-17")
+	pay("phone", 30, "2026-01-10", "2026-02-10")
+	history = append(history, Transaction{Type: TransactionDeposit, Counterparty: "employer", Amount: 3000, Time: date(t, "2026-01-20")},
+		Transaction{Type: TransactionDeposit, Counterparty: "employer", Amount: 3000, Time: date(t, "2026-02-20")},
+		Transaction{Type: TransactionDeposit, Counterparty: "employer", Amount: 3000, Time: date(t, "2026-03-20")})
+	history[1].Amount = 21
+	history[2].Amount = 19.5
+
+	want := []RecurringPayment{
+		{Counterparty: "gym", Amount: 20, Cadence: CadenceWeekly, Occurrences: 5, LastPaid: date(t, "2026-01-30"), NextDue: date(t, "2026-02-06")},
+		{Counterparty: "daycare", Amount: 300, Cadence: CadenceBiweekly, Occurrences: 4, LastPaid: date(t, "2026-02-13"), NextDue: date(t, "2026-02-27")},
+		// The 31st of January is followed by the last day of February.
+		{Counterparty: "rent", Amount: 900, Cadence: CadenceMonthly, Occurrences: 3, LastPaid: date(t, "2026-01-31"), NextDue: date(t, "2026-02-28")},
+		{Counterparty: "streaming", Amount: 12, Cadence: CadenceMonthly, Occurrences: 4, Missed: 1, LastPaid: date(t, "2026-05-05"), NextDue: date(t, "2026-06-05")},
+	}
+	got := DetectRecurring(history)
+	if len(got) != len(want) {
+		t.Fatalf("detected %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("payment %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	upcoming := UpcomingBills(got, date(t, "2026-02-10"), date(t, "2026-02-28"))
+	if len(upcoming) != 2 || upcoming[0].Counterparty != "daycare" || upcoming[1].Counterparty != "rent" {
+		t.Errorf("bills due from February 10 to 28 = %+v, want daycare and rent", upcoming)
+	}
+	if upcoming := UpcomingBills(got, date(t, "2026-03-01"), date(t, "2026-05-31")); len(upcoming) != 0 {
+		t.Errorf("bills due from March to May = %+v, want none", upcoming)
+	}
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/validate"
+)
+
+var (
+	ErrNumberTaken     = banking.New(banking.CodeConflict, "account number is in use or was used by another account")
+	ErrNotRenumberable = banking.New(banking.CodeInvalidArgument, "account kind cannot be renumbered")
+)
+
+// Redirect records that an account once numbered From is now To, so that
+// references to its old number still resolve.
+type Redirect struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// RenumberAccount changes the number of an account, such as after a branch
+// merge. Everything kept by number moves with it: its owners' and their
+// estates' lists of accounts, its nickname, cycle, zone, controls, holders,
+// invitations, grants, consents, enrichments, installment plans, rewards,
+// budgets, pending transfers, quotes, messages, cases and its application;
+// services that keep numbers of their own follow EventAccountRenumbered. A
+// Redirect from the old number is left for Resolve, and redirects to the
+// old number follow it to the new.
+// The ledger is not rewritten: entries keep their IDs, and the entries of
+// other accounts keep naming the old number as their counterparty.
+// Published ledger roots keep the old number too, which their leaves hash,
+// and find the account by its redirect.
+//
+// The bank is locked throughout, so no operation sees the account half
+// moved.
+func (b *Bank) RenumberAccount(from, to string) (Redirect, error) {
+	var v validate.Validator
+	v.AccountNumber("number", to)
+	if err := v.Err(); err != nil {
+		return Redirect{}, err
+	}
+	now := b.now()
+	b.mu.Lock()
+	r, err := b.renumberLocked(from, to, now)
+	b.mu.Unlock()
+	if err != nil {
+		return Redirect{}, err
+	}
+	b.Events.Publish(Event{Type: EventAccountRenumbered, AccountNumber: from, Time: now,
+		Message: fmt.Sprintf("Account %s is now %s", from, to)})
+	return r, nil
+}
+
+func (b *Bank) renumberLocked(from, to string, now time.Time) (Redirect, error) {
+	account := b.accounts.account(from)
+	if account == nil {
+		return Redirect{}, ErrAccountNotFound
+	}
+	l, ok := account.(ledgered)
+	if !ok {
+		return Redirect{}, fmt.Errorf("%w: %s", ErrNotRenumberable, KindOf(account))
+	}
+	_, archived := b.archived[to]
+	// Going back to a number the account had is allowed; a number another
+	// account had would redirect two ways.
+	if r, redirected := b.redirects[to]; b.accounts.account(to) != nil || archived || redirected && r.To != from {
+		return Redirect{}, fmt.Errorf("%w: %s", ErrNumberTaken, to)
+	}
+	delete(b.redirects, to)
+
+	b.accounts.remove(from)
+	l.ledger().AccountNumber = to
+	b.accounts.add(account)
+	b.hook(account)
+
+	rename := func(numbers []string) {
+		for i, number := range numbers {
+			if number == from {
+				numbers[i] = to
+			}
+		}
+	}
+	for _, c := range b.customers {
+		rename(c.Accounts)
+	}
+	for _, e := range b.estates {
+		rename(e.Accounts)
+		if e.PayTo == from {
+			e.PayTo = to
+		}
+	}
+	for i := range b.grants {
+		rename(b.grants[i].Accounts)
+	}
+	for _, c := range b.consents {
+		rename(c.Accounts)
+	}
+	for _, e := range b.enrichments {
+		if e.Account == from {
+			e.Account = to
+		}
+	}
+	for _, p := range b.installments {
+		if p.From == from {
+			p.From = to
+		}
+		if p.To == from {
+			p.To = to
+		}
+	}
+	if r, ok := b.rewards[from]; ok {
+		r.Account = to
+	}
+	moveKey(b.rewards, from, to)
+	moveKey(b.closed, from, to)
+	moveKey(b.deleted, from, to)
+	moveKey(b.dormant, from, to)
+	moveKey(b.held, from, to)
+	moveKey(b.frozen, from, to)
+	moveKey(b.buckets, from, to)
+	moveKey(b.cycles, from, to)
+	moveKey(b.aliases, from, to)
+	moveKey(b.zones, from, to)
+	moveKey(b.holders, from, to)
+	if c, ok := b.controls[from]; ok {
+		for i := range c.Overrides {
+			c.Overrides[i].Account = to
+		}
+		delete(b.controls, from)
+		b.controls[to] = c
+	}
+	for id, inv := range b.invitations {
+		if inv.Account == from {
+			inv.Account = to
+			b.invitations[id] = inv
+		}
+	}
+	for _, app := range b.applications {
+		if app.Number == from {
+			app.Number = to
+		}
+	}
+	for _, pt := range b.pending {
+		if pt.From == from {
+			pt.From = to
+		}
+		if pt.To == from {
+			pt.To = to
+		}
+	}
+	for id, q := range b.quotes {
+		if q.From == from || q.To == from {
+			if q.From == from {
+				q.From = to
+			}
+			if q.To == from {
+				q.To = to
+			}
+			b.quotes[id] = q
+		}
+	}
+	for _, c := range b.cases {
+		if c.Account == from {
+			c.Account = to
+		}
+	}
+	for _, inbox := range b.messages {
+		for _, m := range inbox {
+			if m.Account == from {
+				m.Account = to
+			}
+		}
+	}
+	b.Budgets.mu.Lock()
+	moveKey(b.Budgets.byAccount, from, to)
+	b.Budgets.mu.Unlock()
+
+	for old, r := range b.redirects {
+		if r.To == from {
+			r.To = to
+			b.redirects[old] = r
+		}
+	}
+	r := Redirect{From: from, To: to, Time: now}
+	b.redirects[from] = r
+	return r, nil
+}
+
+// moveKey moves the value of a map from one key to another, if it has one.
+func moveKey[V any](m map[string]V, from, to string) {
+	if v, ok := m[from]; ok {
+		delete(m, from)
+		m[to] = v
+	}
+}
+
+// Redirects returns the redirects of renumbered accounts, by old number.
+func (b *Bank) Redirects() []Redirect {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	redirects := make([]Redirect, 0, len(b.redirects))
+	for _, r := range b.redirects {
+		redirects = append(redirects, r)
+	}
+	sort.Slice(redirects, func(i, j int) bool { return redirects[i].From < redirects[j].From })
+	return redirects
+}
+
+// AddRedirect restores a redirect loaded from a store, without moving
+// anything. Its old number must not name an account.
+func (b *Bank) AddRedirect(r Redirect) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, archived := b.archived[r.From]; b.accounts.account(r.From) != nil || archived {
+		return fmt.Errorf("%w: %s", ErrNumberTaken, r.From)
+	}
+	b.redirects[r.From] = r
+	return nil
+}
+
+// Redirect returns where an account that was renumbered from a number is
+// now, following every renumbering since.
+func (b *Bank) Redirect(number string) (Redirect, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	r, ok := b.redirects[number]
+	return r, ok
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestRenumberAccount checks that a renumbered account takes its ledger,
+// owner, nickname and pending transfers to its new number, that the old
+// number resolves to it through a snapshot, that numbers in use are refused
+// and that renumbering back drops the redirect.
+func TestRenumberAccount(t *testing.T) {
+	SetOutput(io.Discard)
+	b := NewBank()
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1", "S1"}})
+	b.SetAlias("C1", Alias{Nickname: "bills"})
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BookTransfer("C1", "S1", 40, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.RenumberAccount("C1", "S1"); !errors.Is(err, ErrNumberTaken) {
+		t.Errorf("renumbering to an open account's number: %v, want %v", err, ErrNumberTaken)
+	}
+	r, err := b.RenumberAccount("C1", "C2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.From != "C1" || r.To != "C2" {
+		t.Errorf("redirect %+v", r)
+	}
+	if _, err := b.Account("C1"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("old number still opens an account: %v", err)
+	}
+	if balance, err := b.Balance("C2"); err != nil || balance != 100 {
+		t.Errorf("balance of the new number %v, %v, want 100", balance, err)
+	}
+	c, _ := b.Customer("c1")
+	if c.Accounts[0] != "C2" {
+		t.Errorf("customer's accounts %v, want C2 first", c.Accounts)
+	}
+	if number, err := b.Resolve("bills"); err != nil || number != "C2" {
+		t.Errorf("nickname resolves to %q, %v, want C2", number, err)
+	}
+	if pending := b.Pending("C2"); len(pending) != 1 || pending[0].From != "C2" {
+		t.Errorf("pending transfers of C2 %+v", pending)
+	}
+	// Entries posted after renumbering belong to the new number.
+	if err := b.Withdraw("C2", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if number, err := restored.Resolve("C1"); err != nil || number != "C2" {
+		t.Errorf("old number resolves to %q, %v after a restore, want C2", number, err)
+	}
+
+	if _, err := restored.RenumberAccount("S1", "C1"); !errors.Is(err, ErrNumberTaken) {
+		t.Errorf("renumbering to another account's old number: %v, want %v", err, ErrNumberTaken)
+	}
+	if _, err := restored.RenumberAccount("C2", "C3"); err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := restored.Redirect("C1"); !ok || r.To != "C3" {
+		t.Errorf("redirect of C1 %+v, want it to follow to C3", r)
+	}
+	if _, err := restored.RenumberAccount("C3", "C1"); err != nil {
+		t.Fatalf("renumbering back: %v", err)
+	}
+	if _, ok := restored.Redirect("C1"); ok {
+		t.Error("renumbering back kept the redirect from C1")
+	}
+	if number, err := restored.Resolve("C3"); err != nil || number != "C1" {
+		t.Errorf("C3 resolves to %q, %v, want C1", number, err)
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"gsolano/banking/money"
+)
+
+// ReserveRatios are what a simulated regulator holds the bank to. Reserve is
+// the share of customer deposits it must hold as cash, Capital the least
+// capital it must hold against its risk-weighted assets, and LoanRiskWeight
+// how much of the loans and cards it is owed count as at risk; cash counts
+// for none. PaidInCapital is the capital shareholders put in, held as cash
+// in the bank's currency, on top of what the books show it earned.
+type ReserveRatios struct {
+	Reserve        money.Rate `json:"reserve"`
+	Capital        money.Rate `json:"capital"`
+	LoanRiskWeight money.Rate `json:"loan_risk_weight"`
+	PaidInCapital  float64    `json:"paid_in_capital"`
+}
+
+// DefaultReserveRatios are a 10% reserve requirement and an 8% capital
+// ratio with loans weighted in full, as in the textbooks.
+var DefaultReserveRatios = ReserveRatios{Reserve: money.Percent(10), Capital: money.Percent(8), LoanRiskWeight: money.Percent100}
+
+// ReserveReport works out from the books whether the bank meets its ratios
+// in one currency, and how much more it can lend. Reserves are its cash,
+// and Capital its assets less its liabilities. LendingCapacity is the most
+// it can lend out of the bank, paid away in cash, keeping both ratios;
+// DepositMultiplier is how many times its excess reserves the banking
+// system as a whole can lend when every loan comes back as a deposit.
+type ReserveReport struct {
+	Currency           string        `json:"currency"`
+	AsOf               time.Time     `json:"as_of"`
+	Ratios             ReserveRatios `json:"ratios"`
+	Deposits           float64       `json:"deposits"`
+	Reserves           float64       `json:"reserves"`
+	RequiredReserves   float64       `json:"required_reserves"`
+	ExcessReserves     float64       `json:"excess_reserves"`
+	Loans              float64       `json:"loans"`
+	RiskWeightedAssets float64       `json:"risk_weighted_assets"`
+	Capital            float64       `json:"capital"`
+	RequiredCapital    float64       `json:"required_capital"`
+	CapitalRatio       money.Rate    `json:"capital_ratio"`
+	LeverageRatio      money.Rate    `json:"leverage_ratio"`
+	ReserveShortfall   bool          `json:"reserve_shortfall,omitempty"`
+	CapitalShortfall   bool          `json:"capital_shortfall,omitempty"`
+	LendingCapacity    float64       `json:"lending_capacity"`
+	DepositMultiplier  float64       `json:"deposit_multiplier"`
+}
+
+// ReserveReport returns the reserve report of the books by currency,
+// ordered by currency, with the entries posted before asOf, or every entry
+// when asOf is zero, under the bank's Reserves ratios.
+func (b *Bank) ReserveReport(asOf time.Time) []ReserveReport {
+	ratios := b.Reserves
+	totals := b.glBalances(time.Time{}, asOf)
+	if ratios.PaidInCapital != 0 && totals[b.Currency] == nil {
+		totals[b.Currency] = make(map[string]*big.Rat)
+	}
+	report := []ReserveReport{}
+	for currency, nets := range totals {
+		balance := func(code string) *big.Rat {
+			for _, gl := range ChartOfAccounts {
+				if gl.Code == code && nets[code] != nil {
+					return gl.normal(nets[code])
+				}
+			}
+			return new(big.Rat)
+		}
+		assets, liabilities := new(big.Rat), new(big.Rat)
+		for _, gl := range ChartOfAccounts {
+			switch gl.Type {
+			case GLAsset:
+				assets.Add(assets, balance(gl.Code))
+			case GLLiability:
+				liabilities.Add(liabilities, balance(gl.Code))
+			}
+		}
+		reserves := balance(GLCash)
+		if currency == b.Currency {
+			paidIn := exactAmount(ratios.PaidInCapital)
+			reserves.Add(reserves, paidIn)
+			assets.Add(assets, paidIn)
+		}
+		deposits, loans := balance(GLDeposits), balance(GLLoans)
+		capital := new(big.Rat).Sub(assets, liabilities)
+		required := ratioOf(deposits, ratios.Reserve)
+		excess := new(big.Rat).Sub(reserves, required)
+		rwa := ratioOf(loans, ratios.LoanRiskWeight)
+		requiredCapital := ratioOf(rwa, ratios.Capital)
+
+		round := func(x *big.Rat) float64 { return money.Round(ratFloat(x), currency, money.HalfEven) }
+		r := ReserveReport{Currency: currency, AsOf: asOf, Ratios: ratios,
+			Deposits: round(deposits), Reserves: round(reserves), RequiredReserves: round(required), ExcessReserves: round(excess),
+			Loans: round(loans), RiskWeightedAssets: round(rwa), Capital: round(capital), RequiredCapital: round(requiredCapital),
+			CapitalRatio: rateOf(capital, rwa), LeverageRatio: rateOf(capital, assets),
+			ReserveShortfall: excess.Sign() < 0, CapitalShortfall: capital.Cmp(requiredCapital) < 0}
+
+		// A loan paid away lowers reserves by its amount and adds its
+		// weight to the risk-weighted assets; the tighter ratio caps it.
+		capacity := new(big.Rat).Set(excess)
+		if ratios.Capital > 0 && ratios.LoanRiskWeight > 0 {
+			headroom := new(big.Rat).Sub(capital, requiredCapital)
+			headroom.Mul(headroom, big.NewRat(int64(money.Percent100)*int64(money.Percent100), int64(ratios.Capital)*int64(ratios.LoanRiskWeight)))
+			if headroom.Cmp(capacity) < 0 {
+				capacity = headroom
+			}
+		}
+		if capacity.Sign() > 0 {
+			r.LendingCapacity = round(capacity)
+		}
+		if ratios.Reserve > 0 {
+			r.DepositMultiplier = float64(money.Percent100) / float64(ratios.Reserve)
+		}
+		report = append(report, r)
+	}
+	slices.SortFunc(report, func(a, b ReserveReport) int { return strings.Compare(a.Currency, b.Currency) })
+	return report
+}
+
+// ratioOf is amount at rate, exactly.
+func ratioOf(amount *big.Rat, rate money.Rate) *big.Rat {
+	return new(big.Rat).Mul(amount, big.NewRat(int64(rate), int64(money.Percent100)))
+}
+
+// rateOf is part as a share of whole, to the basis point, or 0 when whole
+// is not positive.
+func rateOf(part, whole *big.Rat) money.Rate {
+	if whole.Sign() <= 0 {
+		return 0
+	}
+	return money.Percent(ratFloat(new(big.Rat).Quo(part, whole)) * 100)
+}
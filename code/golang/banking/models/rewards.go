@@ -0,0 +1,408 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var (
+	ErrNoRewards           = banking.New(banking.CodeNotFound, "account has no rewards program")
+	ErrInvalidRewards      = banking.New(banking.CodeInvalidArgument, "invalid rewards program")
+	ErrInsufficientRewards = banking.New(banking.CodeInsufficientFunds, "rewards balance is too low")
+)
+
+// MetaRewardsRedemption is the metadata key of the reference of the
+// redemption a credit of rewards pays out.
+const MetaRewardsRedemption = "rewards_redemption"
+
+// RewardsKind is what a rewards program pays: points, redeemed at the
+// program's PointValue, or cash back.
+type RewardsKind string
+
+const (
+	RewardsPoints   RewardsKind = "points"
+	RewardsCashBack RewardsKind = "cash_back"
+)
+
+// RewardRate is what spending in a category earns, as a rate of what is
+// spent: cash back such as 2%, or points, of which 100% earns one per unit
+// of the account's currency.
+type RewardRate struct {
+	Category string     `json:"category"`
+	Rate     money.Rate `json:"rate"`
+}
+
+// RewardsProgram is how an account earns rewards on its purchases: at the
+// Rate of the purchase's category in Rates, or at Rate otherwise; a
+// category of rate 0 earns nothing. A purchase's category is its own, or
+// what enrichment made of it. Points are earned in whole points per
+// purchase and redeem for PointValue each; cash back is earned in the
+// minor unit of the account's currency.
+type RewardsProgram struct {
+	Kind       RewardsKind  `json:"kind"`
+	Rate       money.Rate   `json:"rate"`
+	Rates      []RewardRate `json:"rates,omitempty"`
+	PointValue float64      `json:"point_value,omitempty"`
+}
+
+func (p RewardsProgram) validate() error {
+	switch {
+	case p.Kind != RewardsPoints && p.Kind != RewardsCashBack:
+		return fmt.Errorf("%w: unknown kind %q, want points or cash_back", ErrInvalidRewards, p.Kind)
+	case p.Kind == RewardsPoints && p.PointValue <= 0:
+		return fmt.Errorf("%w: points need a point value", ErrInvalidRewards)
+	case p.Rate < 0:
+		return fmt.Errorf("%w: rate must not be negative", ErrInvalidRewards)
+	}
+	for _, r := range p.Rates {
+		if r.Category == "" || r.Rate < 0 {
+			return fmt.Errorf("%w: rates need a category and a rate not below 0", ErrInvalidRewards)
+		}
+	}
+	return nil
+}
+
+// rate is what spending in a category earns.
+func (p RewardsProgram) rate(category string) money.Rate {
+	for _, r := range p.Rates {
+		if r.Category == category {
+			return r.Rate
+		}
+	}
+	return p.Rate
+}
+
+// value is what amount of rewards redeems for, rounded to the cent.
+func (p RewardsProgram) value(amount float64, currency string) float64 {
+	if p.Kind == RewardsPoints {
+		amount *= p.PointValue
+	}
+	return money.Round(amount, currency, money.HalfEven)
+}
+
+// RewardsEntryKind is what a rewards entry does to the rewards balance.
+type RewardsEntryKind string
+
+const (
+	RewardsEarned   RewardsEntryKind = "earned"
+	RewardsRedeemed RewardsEntryKind = "redeemed"
+)
+
+// RewardsEntry is an entry of an account's rewards. An earned one is what a
+// purchase, Transaction, of Spent in Category earned; a redeemed one took
+// Amount off the balance and credited Value to the account, in the entry
+// Transaction. Balance is the rewards balance after it.
+type RewardsEntry struct {
+	Time        time.Time        `json:"time"`
+	Kind        RewardsEntryKind `json:"kind"`
+	Amount      float64          `json:"amount"`
+	Balance     float64          `json:"balance"`
+	Transaction string           `json:"transaction,omitempty"`
+	Category    string           `json:"category,omitempty"`
+	Spent       float64          `json:"spent,omitempty"`
+	Value       float64          `json:"value,omitempty"`
+}
+
+// Rewards are the rewards program of an account, its rewards balance, what
+// it earned and redeemed in all and its entries, oldest first. Currency is
+// the account's, whose minor unit cash back is earned in.
+type Rewards struct {
+	Account  string         `json:"account"`
+	Currency string         `json:"currency,omitempty"`
+	Program  RewardsProgram `json:"program"`
+	Balance  float64        `json:"balance"`
+	Earned   float64        `json:"earned"`
+	Redeemed float64        `json:"redeemed"`
+	Entries  []RewardsEntry `json:"entries,omitempty"`
+}
+
+func (r *Rewards) clone() Rewards {
+	c := *r
+	c.Program.Rates = slices.Clone(r.Program.Rates)
+	c.Entries = slices.Clone(r.Entries)
+	return c
+}
+
+// round rounds an amount of rewards: to whole points, or to the minor unit
+// of the account's currency.
+func (r *Rewards) round(amount float64) float64 {
+	if r.Program.Kind == RewardsPoints {
+		return math.Round(amount)
+	}
+	return money.Round(amount, r.Currency, money.HalfEven)
+}
+
+// earn is what spending amount earns at rate, rounded down: to whole
+// points, or to the minor unit of the account's currency.
+func (r *Rewards) earn(amount float64, rate money.Rate) float64 {
+	earned := rate.Of(money.New(amount, r.Currency, money.HalfEven), money.Floor).Float()
+	if r.Program.Kind == RewardsPoints {
+		return math.Floor(earned)
+	}
+	return earned
+}
+
+// SetRewards enrolls a checking or card account in a rewards program, or
+// changes its program. Rewards already earned stay in the balance.
+func (b *Bank) SetRewards(number string, program RewardsProgram) (Rewards, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return Rewards{}, err
+	}
+	if kind := KindOf(account); kind != "Checking" && kind != "CreditCard" {
+		return Rewards{}, fmt.Errorf("%w: %s is a %s account, want checking or a card", ErrInvalidRewards, number, kind)
+	}
+	if err := program.validate(); err != nil {
+		return Rewards{}, err
+	}
+	program.Rates = slices.Clone(program.Rates)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.rewards[number]
+	if !ok {
+		r = &Rewards{Account: number, Currency: CurrencyOf(account)}
+		b.rewards[number] = r
+	}
+	if ok && r.Program.Kind != program.Kind && r.Balance != 0 {
+		return Rewards{}, fmt.Errorf("%w: redeem the %s balance before switching to %s", ErrInvalidRewards, r.Program.Kind, program.Kind)
+	}
+	r.Program = program
+	return r.clone(), nil
+}
+
+// Rewards returns the rewards of an account.
+func (b *Bank) Rewards(number string) (Rewards, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	r, ok := b.rewards[number]
+	if !ok {
+		return Rewards{}, fmt.Errorf("%w: %s", ErrNoRewards, number)
+	}
+	return r.clone(), nil
+}
+
+// rewardsEvent earns the rewards of purchases posted to accounts in a
+// program: withdrawals, but not transfers, cash from ATMs or fees.
+func (b *Bank) rewardsEvent(e Event) {
+	if e.Type != EventTransactionPosted || e.Transaction == nil {
+		return
+	}
+	tx := *e.Transaction
+	_, transfer := tx.Metadata[MetaTransferID]
+	if tx.Type != TransactionWithdrawal || transfer || tx.Metadata[MetaChannel] == ChannelATM {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.rewards[e.AccountNumber]
+	if !ok {
+		return
+	}
+	category := tx.Category
+	if enriched, ok := b.enrichments[tx.ID]; ok && category == "" {
+		category = enriched.Category
+	}
+	earned := r.earn(tx.Amount, r.Program.rate(category))
+	if earned <= 0 {
+		return
+	}
+	r.Balance = r.round(r.Balance + earned)
+	r.Earned = r.round(r.Earned + earned)
+	r.Entries = append(r.Entries, RewardsEntry{Time: tx.Time, Kind: RewardsEarned, Amount: earned, Balance: r.Balance,
+		Transaction: tx.ID, Category: category, Spent: tx.Amount})
+}
+
+// RedeemRewards takes amount off an account's rewards balance, in points or
+// cash back, and credits what it is worth to the account. The credit goes
+// through the bank's middleware like any deposit, with opts; with ByHolder
+// the holder must be allowed to transact on the account.
+func (b *Bank) RedeemRewards(number string, amount float64, opts ...TxOption) (RewardsEntry, error) {
+	if err := checkAmount(amount); err != nil {
+		return RewardsEntry{}, err
+	}
+	account, err := b.Account(number)
+	if err != nil {
+		return RewardsEntry{}, err
+	}
+	var tx Transaction
+	for _, opt := range opts {
+		opt(&tx)
+	}
+	if holder := tx.Metadata[MetaHolder]; holder != "" {
+		if _, err := b.authorize(number, holder, PermissionTransact); err != nil {
+			return RewardsEntry{}, err
+		}
+	}
+
+	b.mu.Lock()
+	r, ok := b.rewards[number]
+	if !ok {
+		b.mu.Unlock()
+		return RewardsEntry{}, fmt.Errorf("%w: %s", ErrNoRewards, number)
+	}
+	if amount != r.round(amount) {
+		b.mu.Unlock()
+		return RewardsEntry{}, fmt.Errorf("%w: points are redeemed whole", ErrInvalidRewards)
+	}
+	if amount > r.Balance+1e-9 {
+		b.mu.Unlock()
+		return RewardsEntry{}, fmt.Errorf("%w: %v to redeem, %v in the balance", ErrInsufficientRewards, amount, r.Balance)
+	}
+	entry := RewardsEntry{Kind: RewardsRedeemed, Amount: amount, Value: r.Program.value(amount, CurrencyOf(account))}
+	if entry.Value < 0.01 {
+		b.mu.Unlock()
+		return RewardsEntry{}, fmt.Errorf("%w: %v is worth less than a cent", ErrInvalidRewards, amount)
+	}
+	// The balance is taken while the credit posts, so that it cannot be
+	// redeemed twice.
+	r.Balance = r.round(r.Balance - amount)
+	b.mu.Unlock()
+
+	ref := b.NewID()
+	opts = append(opts[:len(opts):len(opts)], WithCategory("rewards"),
+		WithDescription(fmt.Sprintf("Rewards redemption of %v %s", amount, r.Program.Kind)),
+		WithMetadata(MetaRewardsRedemption, ref))
+	err = b.Deposit(number, entry.Value, opts...)
+	if err == nil {
+		entry.Transaction = b.lastEntry(number, func(tx Transaction) bool { return tx.Metadata[MetaRewardsRedemption] == ref }).ID
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok = b.rewards[number]; !ok {
+		return RewardsEntry{}, fmt.Errorf("%w: %s", ErrNoRewards, number)
+	}
+	if err != nil {
+		r.Balance = r.round(r.Balance + amount)
+		return RewardsEntry{}, err
+	}
+	entry.Time, entry.Balance = b.nowIn(number), r.Balance
+	r.Redeemed = r.round(r.Redeemed + amount)
+	r.Entries = append(r.Entries, entry)
+	return entry, nil
+}
+
+// RewardsCategory is what spending in a category earned over a rewards
+// statement.
+type RewardsCategory struct {
+	Category string  `json:"category"`
+	Spent    float64 `json:"spent"`
+	Earned   float64 `json:"earned"`
+}
+
+// RewardsStatement is an account's rewards over a calendar month, in its
+// zone: the balance it opened and closed with, what was earned, by
+// category, and redeemed, and the entries.
+type RewardsStatement struct {
+	Account    string            `json:"account"`
+	Kind       RewardsKind       `json:"kind"`
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Opening    float64           `json:"opening"`
+	Earned     float64           `json:"earned"`
+	Redeemed   float64           `json:"redeemed"`
+	Closing    float64           `json:"closing"`
+	Categories []RewardsCategory `json:"categories"`
+	Entries    []RewardsEntry    `json:"entries"`
+}
+
+// RewardsStatement returns the rewards statement of an account for the
+// month month falls in.
+func (b *Bank) RewardsStatement(number string, month time.Time) (RewardsStatement, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	r, ok := b.rewards[number]
+	if !ok {
+		return RewardsStatement{}, fmt.Errorf("%w: %s", ErrNoRewards, number)
+	}
+	return b.rewardsStatementLocked(r, month), nil
+}
+
+// rewardsStatementLocked makes the statement of the month month falls in,
+// holding b.mu.
+func (b *Bank) rewardsStatementLocked(r *Rewards, month time.Time) RewardsStatement {
+	if loc := b.zoneLocked(r.Account); loc != nil {
+		month = month.In(loc)
+	}
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	s := RewardsStatement{Account: r.Account, Kind: r.Program.Kind, From: from, To: from.AddDate(0, 1, 0),
+		Categories: []RewardsCategory{}, Entries: []RewardsEntry{}}
+	categories := make(map[string]*RewardsCategory)
+	for _, e := range r.Entries {
+		if e.Time.Before(s.From) {
+			s.Opening = e.Balance
+			continue
+		}
+		if !e.Time.Before(s.To) {
+			break
+		}
+		s.Entries = append(s.Entries, e)
+		if e.Kind == RewardsRedeemed {
+			s.Redeemed = r.round(s.Redeemed + e.Amount)
+			continue
+		}
+		s.Earned = r.round(s.Earned + e.Amount)
+		c, ok := categories[e.Category]
+		if !ok {
+			c = &RewardsCategory{Category: e.Category}
+			categories[e.Category] = c
+		}
+		c.Spent = money.Round(c.Spent+e.Spent, r.Currency, money.HalfEven)
+		c.Earned = r.round(c.Earned + e.Amount)
+	}
+	s.Closing = r.round(s.Opening + s.Earned - s.Redeemed)
+	for _, c := range categories {
+		s.Categories = append(s.Categories, *c)
+	}
+	sort.Slice(s.Categories, func(i, j int) bool { return s.Categories[i].Category < s.Categories[j].Category })
+	return s
+}
+
+// NotifyRewardsStatements tells the holders of every open account in a
+// rewards program that the rewards statement of last month is ready, once
+// a month, and returns the messages sent. Accounts that had no entries by
+// the end of the month are skipped.
+func (b *Bank) NotifyRewardsStatements() []Message {
+	var due []Message
+	b.mu.RLock()
+	for number, r := range b.rewards {
+		if !b.closed[number].IsZero() || len(r.Entries) == 0 {
+			continue
+		}
+		now := b.nowIn(number)
+		s := b.rewardsStatementLocked(r, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0))
+		if !r.Entries[0].Time.Before(s.To) {
+			continue
+		}
+		unit := "points"
+		if r.Program.Kind == RewardsCashBack {
+			unit = "cash back"
+		}
+		for _, c := range b.ownersLocked(number) {
+			due = append(due, Message{Customer: c.ID, Kind: MessageRewardsStatement, Account: number,
+				Subject: fmt.Sprintf("Your rewards for %s", s.From.Format("January 2006")),
+				Body: fmt.Sprintf("%s earned %v %s in %s and redeemed %v, leaving %v.", number, s.Earned, unit,
+					s.From.Format("January 2006"), s.Redeemed, s.Closing),
+				Key: fmt.Sprintf("rewards:%s:%s", number, s.From.Format("2006-01"))})
+		}
+	}
+	b.mu.RUnlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].Key+due[i].Customer < due[j].Key+due[j].Customer })
+
+	var sent []Message
+	for _, m := range due {
+		if b.inboxHas(m.Customer, m.Key) {
+			continue
+		}
+		if m, err := b.SendMessage(m); err == nil {
+			sent = append(sent, m)
+		}
+	}
+	return sent
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestRewards checks that purchases earn at the rate of their category,
+// that transfers and ATM cash do not, that redeeming credits the account
+// and takes the balance, and that the monthly statement and its notice add
+// up.
+func TestRewards(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-10")}
+	b := NewBank()
+	b.Clock = clock
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+	b.Open(&SavingsAccount{Account: Account{AccountNumber: "S1"}})
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	if err := b.Deposit("C1", 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.SetRewards("S1", RewardsProgram{Kind: RewardsCashBack, Rate: money.Percent(1)}); !errors.Is(err, ErrInvalidRewards) {
+		t.Errorf("rewards on savings: %v, want ErrInvalidRewards", err)
+	}
+	program := RewardsProgram{Kind: RewardsPoints, Rate: money.Percent100, PointValue: 0.01,
+		Rates: []RewardRate{{Category: "dining", Rate: money.Percent(300)}, {Category: "gambling", Rate: 0}}}
+	if _, err := b.SetRewards("C1", program); err != nil {
+		t.Fatal(err)
+	}
+	b.Withdraw("C1", 40.5, WithCategory("dining"))
+	b.Withdraw("C1", 99.99, WithCategory("groceries"))
+	b.Withdraw("C1", 50, WithCategory("gambling"))
+	b.Withdraw("C1", 60, AtATM("US"))
+	b.Transfer("C1", "S1", 100)
+	r, err := b.Rewards("C1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 121 points for dining and 99 for groceries.
+	if r.Balance != 220 || len(r.Entries) != 2 {
+		t.Fatalf("balance = %v with %d entries, want 220 with 2", r.Balance, len(r.Entries))
+	}
+
+	clock.now = date(t, "2026-02-03")
+	if _, err := b.RedeemRewards("C1", 500); !errors.Is(err, ErrInsufficientRewards) {
+		t.Errorf("redeeming more than the balance: %v, want ErrInsufficientRewards", err)
+	}
+	if _, err := b.RedeemRewards("C1", 10.5); !errors.Is(err, ErrInvalidRewards) {
+		t.Errorf("redeeming part of a point: %v, want ErrInvalidRewards", err)
+	}
+	before, _ := b.Balance("C1")
+	entry, err := b.RedeemRewards("C1", 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := b.Balance("C1")
+	if entry.Value != 2 || after-before < 1.995 || entry.Balance != 20 || entry.Transaction == "" {
+		t.Errorf("redemption = %+v, balance %v to %v", entry, before, after)
+	}
+	if tx := b.lastEntry("C1", func(tx Transaction) bool { return tx.ID == entry.Transaction }); tx.Type != TransactionDeposit {
+		t.Errorf("redemption credit = %+v", tx)
+	}
+
+	s, err := b.RewardsStatement("C1", date(t, "2026-01-20"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Opening != 0 || s.Earned != 220 || s.Redeemed != 0 || s.Closing != 220 || len(s.Categories) != 2 || s.Categories[0].Category != "dining" {
+		t.Errorf("January statement = %+v", s)
+	}
+	s, _ = b.RewardsStatement("C1", date(t, "2026-02-01"))
+	if s.Opening != 220 || s.Redeemed != 200 || s.Closing != 20 {
+		t.Errorf("February statement = %+v", s)
+	}
+
+	if sent := b.NotifyRewardsStatements(); len(sent) != 1 || sent[0].Kind != MessageRewardsStatement {
+		t.Fatalf("notices sent = %+v, want one for January", sent)
+	}
+	if sent := b.NotifyRewardsStatements(); len(sent) != 0 {
+		t.Errorf("notices sent again = %d, want 0", len(sent))
+	}
+}
+
+// TestRewardsEarned checks that rates given in percent or basis points earn
+// exactly what they say, rounded down to whole points or to the cent.
+func TestRewardsEarned(t *testing.T) {
+	SetOutput(io.Discard)
+	for _, tt := range []struct {
+		program string
+		spent   float64
+		want    float64
+	}{
+		{`{"kind": "cash_back", "rate": "1.5%"}`, 99.99, 1.49},
+		{`{"kind": "cash_back", "rate": "150bps"}`, 100, 1.5},
+		{`{"kind": "cash_back", "rate": "2%"}`, 0.49, 0},
+		// 0.29 at 100% would be 28.999... points in floats.
+		{`{"kind": "points", "rate": "10000%", "point_value": 0.01}`, 0.29, 29},
+		{`{"kind": "points", "rate": "100%", "point_value": 0.01, "rates": [{"category": "dining", "rate": "300%"}]}`, 40.5, 121},
+	} {
+		var program RewardsProgram
+		if err := json.Unmarshal([]byte(tt.program), &program); err != nil {
+			t.Fatal(err)
+		}
+		b := NewBank()
+		b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1"}})
+		b.Deposit("C1", 1000)
+		if _, err := b.SetRewards("C1", program); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Withdraw("C1", tt.spent, WithCategory("dining")); err != nil {
+			t.Fatal(err)
+		}
+		if r, _ := b.Rewards("C1"); r.Balance != tt.want {
+			t.Errorf("%s on %v earned %v, want %v", tt.program, tt.spent, r.Balance, tt.want)
+		}
+	}
+}
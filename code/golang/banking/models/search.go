@@ -0,0 +1,306 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gsolano/banking"
+)
+
+// A transaction search query is a list of terms separated by spaces:
+//
+//	account:12345            only this account
+//	type:withdrawal          only this transaction type
+//	counterparty:acme        counterparty contains "acme"
+//	category:groceries       exactly this category
+//	tag:rent                 has this tag
+//	amount>=10 amount<100    amount range; also >, <=, and amount:25 for exact
+//	since:2026-01-01         on or after this date (or RFC 3339 time)
+//	until:2026-02-01         before this date
+//	sort:-amount             order by time (default) or amount; - for descending
+//	limit:20                 page size, 50 by default and at most 500
+//	after:<cursor>           continue after the page that returned cursor
+//	"electric bill" coffee   free text every term must appear in, matched
+//	                         against description, counterparty and category
+//
+// Matching is case-insensitive.
+
+// Query is a parsed transaction search.
+type Query struct {
+	Account      string
+	Type         TransactionType
+	Counterparty string
+	Category     string
+	Tags         []string
+	MinAmount    *float64
+	MaxAmount    *float64
+	// MinExclusive and MaxExclusive make the amount bounds strict.
+	MinExclusive bool
+	MaxExclusive bool
+	Since, Until time.Time
+	Text         []string
+	SortBy       string // "time" or "amount"
+	Descending   bool
+	Limit        int
+	After        string
+}
+
+// Match is a transaction found by a search, with the account it belongs to.
+type Match struct {
+	Account     string
+	Transaction Transaction
+}
+
+// SearchResult is one page of matches. NextCursor continues the search with
+// an after: term.
+type SearchResult = Page[Match]
+
+// ParseQuery parses the search language described above.
+func ParseQuery(s string) (Query, error) {
+	q := Query{SortBy: "time", Limit: DefaultPageSize}
+	for _, term := range splitTerms(s) {
+		if err := q.parseTerm(term); err != nil {
+			return Query{}, banking.New(banking.CodeInvalidArgument, err.Error())
+		}
+	}
+	return q, nil
+}
+
+func (q *Query) parseTerm(term string) error {
+	if rest, ok := strings.CutPrefix(strings.ToLower(term), "amount"); ok && rest != "" && strings.ContainsRune(":<>", rune(rest[0])) {
+		return q.parseAmount(term, rest)
+	}
+	key, value, ok := strings.Cut(term, ":")
+	if !ok || value == "" {
+		q.Text = append(q.Text, strings.ToLower(term))
+		return nil
+	}
+	switch strings.ToLower(key) {
+	case "account":
+		q.Account = value
+	case "type":
+		q.Type = TransactionType(strings.ToLower(value))
+	case "counterparty":
+		q.Counterparty = strings.ToLower(value)
+	case "category":
+		q.Category = strings.ToLower(value)
+	case "tag":
+		q.Tags = append(q.Tags, strings.ToLower(value))
+	case "since", "until":
+		t, err := parseSearchTime(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		if key == "since" {
+			q.Since = t
+		} else {
+			q.Until = t
+		}
+	case "sort":
+		q.Descending = strings.HasPrefix(value, "-")
+		q.SortBy = strings.ToLower(strings.TrimPrefix(value, "-"))
+		if q.SortBy != "time" && q.SortBy != "amount" {
+			return fmt.Errorf("sort: unknown field %q", q.SortBy)
+		}
+	case "limit":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > MaxPageSize {
+			return fmt.Errorf("limit: must be between 1 and %d", MaxPageSize)
+		}
+		q.Limit = n
+	case "after":
+		q.After = value
+	default:
+		// Not a filter, such as "re:invoice"; search for it as text.
+		q.Text = append(q.Text, strings.ToLower(term))
+	}
+	return nil
+}
+
+func (q *Query) parseAmount(term, rest string) error {
+	op := rest[:1]
+	if strings.HasPrefix(rest, ">=") || strings.HasPrefix(rest, "<=") {
+		op = rest[:2]
+	}
+	amount, err := strconv.ParseFloat(rest[len(op):], 64)
+	if err != nil {
+		return fmt.Errorf("%s: not an amount", term)
+	}
+	switch op {
+	case ":":
+		q.MinAmount, q.MaxAmount = &amount, &amount
+		q.MinExclusive, q.MaxExclusive = false, false
+	case ">", ">=":
+		q.MinAmount, q.MinExclusive = &amount, op == ">"
+	case "<", "<=":
+		q.MaxAmount, q.MaxExclusive = &amount, op == "<"
+	}
+	return nil
+}
+
+func parseSearchTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// splitTerms splits on spaces, keeping double-quoted phrases together.
+func splitTerms(s string) []string {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	flush := func() {
+		if term.Len() > 0 {
+			terms = append(terms, term.String())
+			term.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			term.WriteRune(r)
+		}
+	}
+	flush()
+	return terms
+}
+
+// Matches reports whether a transaction satisfies every filter of q.
+func (q Query) Matches(tx Transaction) bool {
+	switch {
+	case q.Type != "" && tx.Type != q.Type,
+		q.Counterparty != "" && !strings.Contains(strings.ToLower(tx.Counterparty), q.Counterparty),
+		q.Category != "" && strings.ToLower(tx.Category) != q.Category,
+		q.MinAmount != nil && (tx.Amount < *q.MinAmount || q.MinExclusive && tx.Amount == *q.MinAmount),
+		q.MaxAmount != nil && (tx.Amount > *q.MaxAmount || q.MaxExclusive && tx.Amount == *q.MaxAmount),
+		!q.Since.IsZero() && tx.Time.Before(q.Since),
+		!q.Until.IsZero() && !tx.Time.Before(q.Until):
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !hasTag(tx, tag) {
+			return false
+		}
+	}
+	text := strings.ToLower(tx.Description + "\n" + tx.Counterparty + "\n" + tx.Category)
+	for _, word := range q.Text {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTag(tx Transaction, tag string) bool {
+	for _, t := range tx.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchTransactions runs a search query over the ledgers of every account,
+// or of the account named in the query, and returns one page of matches.
+func (b *Bank) SearchTransactions(query string) (SearchResult, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return b.Search(q)
+}
+
+// Search is SearchTransactions with a parsed query.
+func (b *Bank) Search(q Query) (SearchResult, error) {
+	limit, err := PageRequest{Size: q.Limit}.size()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	b.mu.Lock()
+	var matches []Match
+	for _, account := range b.accounts.all() {
+		number := account.Number()
+		if q.Account != "" && number != q.Account {
+			continue
+		}
+		for _, tx := range account.History() {
+			if q.Matches(tx) {
+				matches = append(matches, Match{Account: number, Transaction: tx})
+			}
+		}
+	}
+	b.mu.Unlock()
+	if q.Account != "" && len(matches) == 0 {
+		if _, err := b.Account(q.Account); err != nil {
+			return SearchResult{}, err
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return q.less(matches[i], matches[j]) })
+	start := 0
+	if q.After != "" {
+		after, err := decodeCursor(q.After)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		// The order depends on the query, so look for the entry the cursor
+		// names; ledgers are append-only, so it is still there unless the
+		// query changed.
+		start = -1
+		for i, m := range matches {
+			if matchKey(m) == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return SearchResult{}, banking.New(banking.CodeInvalidArgument, "cursor does not belong to this query")
+		}
+	}
+	return pageFrom(matches[start:], limit, matchKey), nil
+}
+
+func matchKey(m Match) string {
+	return m.Account + "/" + sequenceKey(m.Transaction.Sequence)
+}
+
+// less orders matches by the sort field, then by account and sequence so
+// every match has a stable position for cursors.
+func (q Query) less(a, b Match) bool {
+	var cmp int
+	switch q.SortBy {
+	case "amount":
+		cmp = compareFloat(a.Transaction.Amount, b.Transaction.Amount)
+	default:
+		cmp = a.Transaction.Time.Compare(b.Transaction.Time)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.Account, b.Account)
+	}
+	if cmp == 0 {
+		cmp = a.Transaction.Sequence - b.Transaction.Sequence
+	}
+	if q.Descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+)
+
+// DefaultSessionTTL is how long a session lasts unless the bank's
+// SessionTTL says, and DefaultStepUpWindow how long after authenticating a
+// session may do high-risk operations unless the bank's StepUpWindow says.
+const (
+	DefaultSessionTTL   = 30 * 24 * time.Hour
+	DefaultStepUpWindow = 5 * time.Minute
+)
+
+var (
+	ErrSessionNotFound = banking.New(banking.CodeNotFound, "session not found")
+	ErrSessionInvalid  = banking.New(banking.CodeUnauthenticated, "session expired, revoked or unknown")
+	ErrStepUpRequired  = banking.New(banking.CodeUnauthenticated, "re-authenticate to do this")
+)
+
+// Session is a customer signed in on a device, the one their token was
+// issued to. IP and UserAgent are those it was last seen with, at LastSeen.
+// Authenticated is when the customer last proved who they are, at sign-in
+// or by stepping up since; high-risk operations need it recent, see
+// SteppedUp. A session ends at Expires or when it is Revoked.
+type Session struct {
+	ID            string     `json:"id"`
+	Customer      string     `json:"customer"`
+	Device        string     `json:"device"`
+	IP            string     `json:"ip,omitempty"`
+	UserAgent     string     `json:"user_agent,omitempty"`
+	Created       time.Time  `json:"created"`
+	LastSeen      time.Time  `json:"last_seen"`
+	Authenticated time.Time  `json:"authenticated"`
+	Expires       time.Time  `json:"expires"`
+	Revoked       *time.Time `json:"revoked,omitempty"`
+	// token is the SHA-256 of the session's token; the token itself is only
+	// given out when the session starts.
+	token [sha256.Size]byte
+	// failures counts the wrong codes of StepUpWith since the last right
+	// one.
+	failures int
+}
+
+// active reports whether the session may be used at t.
+func (s *Session) active(t time.Time) bool {
+	return s.Revoked == nil && t.Before(s.Expires)
+}
+
+// SteppedUp reports whether the customer authenticated within window of t.
+func (s Session) SteppedUp(t time.Time, window time.Duration) bool {
+	return t.Sub(s.Authenticated) <= window
+}
+
+// StartSession signs a customer in on a device and returns the session and
+// its token, which requests present to act as the customer.
+func (b *Bank) StartSession(customer, device, ip, userAgent string) (Session, string, error) {
+	if device == "" {
+		return Session{}, "", banking.New(banking.CodeInvalidArgument, "device is required")
+	}
+	token, err := newToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	now := b.now()
+	ttl := b.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	b.mu.Lock()
+	if _, ok := b.customers[customer]; !ok {
+		b.mu.Unlock()
+		return Session{}, "", ErrCustomerNotFound
+	}
+	s := &Session{ID: b.IDs.NewID(), Customer: customer, Device: device, IP: ip, UserAgent: userAgent,
+		Created: now, LastSeen: now, Authenticated: now, Expires: now.Add(ttl), token: sha256.Sum256([]byte(token))}
+	b.sessions[s.ID] = s
+	b.sessionIDs[s.token] = s.ID
+	started := *s
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventSessionStarted, Time: now,
+		Message: fmt.Sprintf("Session %s of %s started on %s from %s", s.ID, customer, device, ip)})
+	return started, token, nil
+}
+
+// newToken returns a random token of 256 bits, for sessions and API keys.
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// SessionByToken returns the active session of a token, seen now from ip
+// with userAgent.
+func (b *Bank) SessionByToken(token, ip, userAgent string) (Session, error) {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.sessions[b.sessionIDs[sha256.Sum256([]byte(token))]]
+	if !ok || !s.active(now) {
+		return Session{}, ErrSessionInvalid
+	}
+	s.LastSeen, s.IP, s.UserAgent = now, ip, userAgent
+	return *s, nil
+}
+
+// Sessions returns the active sessions of a customer, the most recently
+// seen first.
+func (b *Bank) Sessions(customer string) ([]Session, error) {
+	now := b.now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if _, ok := b.customers[customer]; !ok {
+		return nil, ErrCustomerNotFound
+	}
+	sessions := []Session{}
+	for _, s := range b.sessions {
+		if s.Customer == customer && s.active(now) {
+			sessions = append(sessions, *s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeen.After(sessions[j].LastSeen) })
+	return sessions, nil
+}
+
+// RevokeSession signs a customer out of one of their sessions, such as on a
+// lost device.
+func (b *Bank) RevokeSession(customer, id string) (Session, error) {
+	now := b.now()
+	b.mu.Lock()
+	s, ok := b.sessions[id]
+	if !ok || s.Customer != customer || !s.active(now) {
+		b.mu.Unlock()
+		return Session{}, ErrSessionNotFound
+	}
+	s.Revoked = &now
+	revoked := *s
+	b.mu.Unlock()
+	b.Events.Publish(Event{Type: EventSessionRevoked, Time: now,
+		Message: fmt.Sprintf("Session %s of %s on %s revoked", id, customer, revoked.Device)})
+	return revoked, nil
+}
+
+// StepUp records that the customer of a session authenticated again, such
+// as with their password or a one-time code, so that it may do high-risk
+// operations for the bank's StepUpWindow.
+func (b *Bank) StepUp(id string) (Session, error) {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.sessions[id]
+	if !ok || !s.active(now) {
+		return Session{}, ErrSessionNotFound
+	}
+	s.Authenticated = now
+	return *s, nil
+}
+
+// ExpireSessions forgets the sessions that expired or were revoked and
+// returns how many.
+func (b *Bank) ExpireSessions() int {
+	now := b.now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	expired := 0
+	for id, s := range b.sessions {
+		if !s.active(now) {
+			delete(b.sessions, id)
+			delete(b.sessionIDs, s.token)
+			expired++
+		}
+	}
+	return expired
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestSession signs a customer in on two devices, checks that tokens find
+// their session through a snapshot and track where they were last seen,
+// that stepping up lasts for the window and that revoked sessions end.
+func TestSession(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-01")}
+	b := NewBank()
+	b.Clock = clock
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada"})
+	if _, _, err := b.StartSession("c2", "phone", "", ""); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("signing in an unknown customer: %v, want %v", err, ErrCustomerNotFound)
+	}
+	phone, phoneToken, err := b.StartSession("c1", "phone", "10.0.0.1", "app/1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, laptopToken, err := b.StartSession("c1", "laptop", "10.0.0.2", "browser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	restored.Clock = clock
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	s, err := restored.SessionByToken(phoneToken, "10.0.0.9", "app/1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != phone.ID || s.IP != "10.0.0.9" || !s.LastSeen.Equal(clock.now) {
+		t.Errorf("session of the phone's token %+v", s)
+	}
+	if s.SteppedUp(clock.now, restored.StepUpWindow) {
+		t.Error("an hour after signing in counts as stepped up")
+	}
+	if s, err = restored.StepUp(phone.ID); err != nil || !s.SteppedUp(clock.now, restored.StepUpWindow) {
+		t.Errorf("after stepping up %+v, %v", s, err)
+	}
+	if _, err := restored.SessionByToken("forged", "", ""); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("unknown token: %v, want %v", err, ErrSessionInvalid)
+	}
+
+	if sessions, _ := restored.Sessions("c1"); len(sessions) != 2 || sessions[0].ID != phone.ID {
+		t.Errorf("sessions %+v, want the phone's first", sessions)
+	}
+	if _, err := restored.RevokeSession("c1", laptop.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.SessionByToken(laptopToken, "", ""); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("revoked token: %v, want %v", err, ErrSessionInvalid)
+	}
+	if expired := restored.ExpireSessions(); expired != 1 {
+		t.Errorf("forgot %d sessions, want the laptop's", expired)
+	}
+}
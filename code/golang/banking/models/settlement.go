@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gsolano/banking"
+)
+
+var ErrPendingNotFound = banking.New(banking.CodeNotFound, "pending transfer not found")
+
+// PendingTransfer is a transfer that has been booked but not settled yet.
+// Held, its amount and the transfer fee of the source account's product
+// when it was booked, is held on the source account, lowering its
+// available balance, until Settle posts both legs on or after SettlesOn.
+type PendingTransfer struct {
+	ID        int       `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Amount    float64   `json:"amount"`
+	Held      float64   `json:"held"`
+	Booked    time.Time `json:"booked"`
+	SettlesOn time.Time `json:"settles_on"`
+
+	opts []TxOption
+}
+
+// BookTransfer books a transfer today that settles days business days later
+// (T+days) according to the bank's calendar. The amount and the transfer
+// fee must be available on the source account, counting what earlier
+// pending transfers hold, and the transfer must pass the checks it will
+// settle under: neither account closed or frozen for an estate, the source
+// not dormant, and a rate between their currencies.
+func (b *Bank) BookTransfer(from, to string, amount float64, days int, opts ...TxOption) (PendingTransfer, error) {
+	source, err := b.Account(from)
+	if err != nil {
+		return PendingTransfer{}, err
+	}
+	target, err := b.Account(to)
+	if err != nil {
+		return PendingTransfer{}, err
+	}
+	if err := checkAmount(amount); err != nil {
+		return PendingTransfer{}, err
+	}
+	if days < 0 {
+		return PendingTransfer{}, banking.New(banking.CodeInvalidArgument, "settlement days must not be negative")
+	}
+	if _, err := b.exchangeRate(source, target); err != nil {
+		return PendingTransfer{}, err
+	}
+
+	now := b.now()
+	fee := b.opFee(source, OpTransfer)
+	b.mu.Lock()
+	for _, check := range []func() error{
+		func() error { return b.checkOpen(from) },
+		func() error { return b.checkOpen(to) },
+		func() error { return b.checkDormant(from, TransactionWithdrawal) },
+		func() error { return b.checkEstate(from, TransactionWithdrawal) },
+		func() error { return b.checkEstate(to, TransactionDeposit) },
+		func() error { return b.checkFeeFundsLocked(source, amount, fee) },
+	} {
+		if err := check(); err != nil {
+			b.mu.Unlock()
+			return PendingTransfer{}, err
+		}
+	}
+	b.nextPending++
+	pt := &PendingTransfer{
+		ID: b.nextPending, From: from, To: to, Amount: amount, Held: amount + fee,
+		Booked: now, SettlesOn: b.Calendar.AddBusinessDays(now, days),
+		opts: opts,
+	}
+	b.pending = append(b.pending, pt)
+	b.held[from] += pt.Held
+	booked := *pt
+	b.mu.Unlock()
+
+	b.Events.Publish(Event{Type: EventTransferBooked, AccountNumber: from, Time: now,
+		Message: fmt.Sprintf("Transfer of %.2f from %s to %s settles on %s", amount, from, to, booked.SettlesOn.Format(time.DateOnly))})
+	return booked, nil
+}
+
+// Available returns what can be spent from an account: its balance less the
+// amounts held by pending outgoing transfers.
+func (b *Bank) Available(number string) (float64, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return 0, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return account.CheckBalance() - b.held[number], nil
+}
+
+// Pending returns the unsettled transfers into or out of an account, in the
+// order they were booked.
+func (b *Bank) Pending(number string) []PendingTransfer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var pending []PendingTransfer
+	for _, pt := range b.pending {
+		if pt.From == number || pt.To == number {
+			pending = append(pending, *pt)
+		}
+	}
+	return pending
+}
+
+// CancelPending cancels the pending transfer with an ID out of an account
+// and releases its hold.
+func (b *Bank) CancelPending(from string, id int) (PendingTransfer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, pt := range b.pending {
+		if pt.ID == id && pt.From == from {
+			b.pending = append(b.pending[:i:i], b.pending[i+1:]...)
+			b.release(pt)
+			return *pt, nil
+		}
+	}
+	return PendingTransfer{}, ErrPendingNotFound
+}
+
+// release releases the hold of a pending transfer. The caller holds b.mu.
+func (b *Bank) release(pt *PendingTransfer) {
+	if b.held[pt.From] -= pt.Held; b.held[pt.From] <= 0 {
+		delete(b.held, pt.From)
+	}
+}
+
+// Settle posts every pending transfer due by now and returns those that
+// settled. The legs are posted by the bank, without going through
+// middleware. A transfer keeps its hold until its legs are posted, so
+// nothing else spends what it holds in between. One that fails to post
+// stays pending, holding its funds, and is tried again by the next Settle
+// until it posts or is cancelled; the failures are returned joined.
+func (b *Bank) Settle(now time.Time) ([]PendingTransfer, error) {
+	b.mu.Lock()
+	var due []PendingTransfer
+	for _, pt := range b.pending {
+		if !now.Before(pt.SettlesOn) {
+			due = append(due, *pt)
+		}
+	}
+	b.mu.Unlock()
+
+	var settled []PendingTransfer
+	var errs []error
+	for _, pt := range due {
+		ok, err := b.settle(pt.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transfer %d: %w", pt.ID, err))
+		}
+		if ok {
+			settled = append(settled, pt)
+		}
+	}
+	return settled, errors.Join(errs...)
+}
+
+// settle posts the pending transfer with an ID and ends it, reporting
+// whether it did. The hold is released and the legs posted under b.mu; if
+// they fail to post, the hold is taken again and the transfer stays
+// pending. A transfer settled or cancelled meanwhile is left alone.
+func (b *Bank) settle(id int) (bool, error) {
+	b.mu.RLock()
+	var from, to string
+	for _, pt := range b.pending {
+		if pt.ID == id {
+			from, to = pt.From, pt.To
+		}
+	}
+	b.mu.RUnlock()
+	if from == "" {
+		return false, nil
+	}
+	var ends [2]BankAccount
+	for i, number := range []string{from, to} {
+		account, err := b.Account(number)
+		if err != nil {
+			return false, err
+		}
+		ends[i] = account
+	}
+	rate, err := b.exchangeRate(ends[0], ends[1])
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	i := slices.IndexFunc(b.pending, func(pt *PendingTransfer) bool { return pt.ID == id })
+	if i < 0 {
+		b.mu.Unlock()
+		return false, nil
+	}
+	pt := b.pending[i]
+	source, target := b.accounts.account(pt.From), b.accounts.account(pt.To)
+	if source == nil || target == nil {
+		b.mu.Unlock()
+		return false, ErrAccountNotFound
+	}
+	b.release(pt)
+	posted, err := b.postWithOpFeeLocked(source, OpTransfer, pt.Amount, TransferRequest{From: pt.From, To: pt.To}, func() ([]postedEntry, error) {
+		return b.transferLocked(source, target, pt.Amount, rate, pt.opts)
+	})
+	if err != nil {
+		b.held[pt.From] += pt.Held
+	} else {
+		b.pending = append(b.pending[:i:i], b.pending[i+1:]...)
+	}
+	b.mu.Unlock()
+	b.publishPosted(posted)
+	return err == nil, err
+}
+
+// OverdraftLimit returns how far below zero the balance of an account may
+// go: the overdraft limit of a checking account while overdrafts are
+// enabled, the credit limit of a loan or card, and zero otherwise.
+func (b *Bank) OverdraftLimit(number string) (float64, error) {
+	account, err := b.Account(number)
+	if err != nil {
+		return 0, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.overdraftLimit(account), nil
+}
+
+// overdraftLimit is how far below zero an account may go. The caller holds
+// b.mu.
+func (b *Bank) overdraftLimit(account BankAccount) float64 {
+	if credit, ok := account.(creditLine); ok {
+		return credit.creditLimit()
+	}
+	checking, ok := account.(*CheckingAccount)
+	if !ok || !b.Flags.Enabled(b.Tenant, FeatureOverdraft) {
+		return 0
+	}
+	return checking.OverdraftLimit
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSettlement books transfers on a Friday before a holiday and checks
+// that their holds lower the available balance until the next business
+// day, when one posts both legs, without going through middleware, and one
+// into a frozen account fails to post and stays pending with its hold
+// until it is cancelled.
+func TestSettlement(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-16").Add(10 * time.Hour)}
+	b := NewBank()
+	b.Clock = clock
+	b.Calendar.AddHoliday(date(t, "2026-01-19"), "Martin Luther King Jr. Day")
+	for _, number := range []string{"C1", "S1", "S2"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := b.BookTransfer("C1", "S1", 40, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.SettlesOn.Equal(date(t, "2026-01-20")) {
+		t.Errorf("T+1 of a Friday before a holiday settles on %s, want 2026-01-20", first.SettlesOn.Format(time.DateOnly))
+	}
+	if _, err := b.BookTransfer("C1", "S2", 50, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BookTransfer("C1", "S1", 20, 1); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("booking beyond the available balance: %v, want %v", err, ErrInsufficientFunds)
+	}
+	if available, _ := b.Available("C1"); available != 10 {
+		t.Errorf("available after booking 90 of 100 = %.2f, want 10", available)
+	}
+	if balance, _ := b.Balance("C1"); balance != 100 {
+		t.Errorf("balance while pending = %.2f, want 100", balance)
+	}
+
+	for _, day := range []string{"2026-01-17", "2026-01-19"} {
+		settled, err := b.Settle(date(t, day).Add(23 * time.Hour))
+		if len(settled) != 0 || err != nil {
+			t.Errorf("settling on %s: %v, %v, want nothing due", day, settled, err)
+		}
+	}
+	if pending := b.Pending("C1"); len(pending) != 2 {
+		t.Fatalf("pending before the business day %+v, want both", pending)
+	}
+
+	b.Use(func(next Operation) Operation {
+		return func(op Op) error { return errors.New("middleware refuses every op") }
+	})
+	b.AddCustomer(&Customer{ID: "c2", Name: "Grace", Accounts: []string{"S2"}})
+	if _, err := b.ReportDeath("c2", date(t, "2026-01-15")); err != nil {
+		t.Fatal(err)
+	}
+	settled, err := b.Settle(date(t, "2026-01-20"))
+	if len(settled) != 1 || settled[0].ID != first.ID {
+		t.Errorf("settled %+v, want the first transfer", settled)
+	}
+	if err == nil || !strings.Contains(err.Error(), "transfer 2: ") || !errors.Is(err, ErrAccountEstate) {
+		t.Errorf("settling error %v, want the second transfer's", err)
+	}
+	for number, want := range map[string]float64{"C1": 60, "S1": 40, "S2": 0} {
+		if balance, _ := b.Balance(number); balance != want {
+			t.Errorf("%s balance after settling = %.2f, want %.2f", number, balance, want)
+		}
+	}
+	if available, _ := b.Available("C1"); available != 10 {
+		t.Errorf("available after settling = %.2f, want 10 with the failed transfer holding 50", available)
+	}
+	if pending := b.Pending("C1"); len(pending) != 1 || pending[0].ID != 2 {
+		t.Fatalf("pending after settling %+v, want the failed transfer", pending)
+	}
+	if _, err := b.Settle(date(t, "2026-01-21")); !errors.Is(err, ErrAccountEstate) {
+		t.Errorf("settling again: %v, want the failed transfer tried again", err)
+	}
+
+	if _, err := b.CancelPending("C1", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.CancelPending("C1", 2); !errors.Is(err, ErrPendingNotFound) {
+		t.Errorf("cancelling twice: %v, want %v", err, ErrPendingNotFound)
+	}
+	if available, _ := b.Available("C1"); available != 60 || len(b.Pending("C1")) != 0 {
+		t.Errorf("available after cancelling = %.2f, want the balance of 60 with no holds", available)
+	}
+}
+
+// TestSettlementFees checks that a booking holds the transfer fee of the
+// source's product with its amount, so one the fee would not fit in is
+// refused and one that fits settles with its fee.
+func TestSettlementFees(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-12")}
+	b := NewBank()
+	b.Clock = clock
+	b.Products = NewCatalog(Product{Code: "chk", Name: "Checking", Kind: ProductChecking, TransferFee: 2})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C1", Product: "chk"}})
+	b.Open(&CheckingAccount{Account: Account{AccountNumber: "C2"}})
+	if err := b.Deposit("C1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.BookTransfer("C1", "C2", 100, 1); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("booking the whole balance with a fee: %v, want %v", err, ErrInsufficientFunds)
+	}
+	pt, err := b.BookTransfer("C1", "C2", 98, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available, _ := b.Available("C1"); pt.Held != 100 || available != 0 {
+		t.Errorf("booking holds %.2f, leaving %.2f available, want 100 and 0", pt.Held, available)
+	}
+	if err := b.Withdraw("C1", 1); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("withdrawing held funds: %v, want %v", err, ErrInsufficientFunds)
+	}
+	settled, err := b.Settle(date(t, "2026-01-13"))
+	if err != nil || len(settled) != 1 {
+		t.Fatalf("settled %+v, %v, want the transfer", settled, err)
+	}
+	for number, want := range map[string]float64{"C1": 0, "C2": 98} {
+		if balance, _ := b.Balance(number); balance != want {
+			t.Errorf("%s balance after settling = %.2f, want %.2f", number, balance, want)
+		}
+	}
+}
+
+// TestBookTransferChecks checks that a transfer that could not settle is
+// refused when booked, holding nothing: out of a dormant account, into or
+// out of one frozen for an estate, or between currencies without a rate.
+func TestBookTransferChecks(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2025-01-06")}
+	b := NewBank()
+	b.Clock = clock
+	b.Currency = "USD"
+	b.DormancyMonths = 12
+	for _, a := range []BankAccount{
+		&CheckingAccount{Account: Account{AccountNumber: "C1"}},
+		&CheckingAccount{Account: Account{AccountNumber: "IDLE"}},
+		&CheckingAccount{Account: Account{AccountNumber: "DEAD"}},
+		&CheckingAccount{Account: Account{AccountNumber: "EUR", Currency: "EUR"}},
+	} {
+		if err := b.Open(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, number := range []string{"C1", "IDLE", "DEAD"} {
+		if err := b.Deposit(number, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.now = date(t, "2026-03-02")
+	if err := b.Deposit("C1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if marked := b.ReviewDormancy(); len(marked) != 2 {
+		t.Fatalf("dormant %v, want IDLE and DEAD", marked)
+	}
+	if err := b.Reactivate("DEAD"); err != nil {
+		t.Fatal(err)
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Grace", Accounts: []string{"DEAD"}})
+	if _, err := b.ReportDeath("c1", date(t, "2026-03-01")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to string
+		want     error
+	}{
+		{"IDLE", "C1", ErrAccountDormant},
+		{"C1", "IDLE", nil},
+		{"DEAD", "C1", ErrAccountEstate},
+		{"C1", "DEAD", ErrAccountEstate},
+		{"C1", "EUR", ErrNoExchangeRate},
+	}
+	for _, tt := range tests {
+		_, err := b.BookTransfer(tt.from, tt.to, 10, 1)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("booking %s to %s: %v, want %v", tt.from, tt.to, err, tt.want)
+		}
+	}
+	for number, want := range map[string]float64{"C1": 91, "IDLE": 100, "DEAD": 100} {
+		if available, _ := b.Available(number); available != want {
+			t.Errorf("%s available = %.2f, want %.2f", number, available, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// shardCount is how many shards the account registry is split into.
+const shardCount = 64
+
+// accountShards is the registry of a bank's open accounts, split across
+// shards by a hash of the account number so that looking up accounts, and
+// posting to different ones, do not all wait on one lock.
+type accountShards struct {
+	seed   maphash.Seed
+	shards [shardCount]accountShard
+}
+
+type accountShard struct {
+	mu      sync.RWMutex
+	entries map[string]*accountEntry
+}
+
+// accountEntry is an open account with the lock that serializes the
+// entries posted to it.
+type accountEntry struct {
+	mu      sync.Mutex
+	account BankAccount
+}
+
+func newAccountShards() *accountShards {
+	s := &accountShards{seed: maphash.MakeSeed()}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*accountEntry)
+	}
+	return s
+}
+
+func (s *accountShards) shard(number string) *accountShard {
+	return &s.shards[maphash.String(s.seed, number)%shardCount]
+}
+
+func (s *accountShards) entry(number string) (*accountEntry, bool) {
+	shard := s.shard(number)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	e, ok := shard.entries[number]
+	return e, ok
+}
+
+// account returns the account numbered number, or nil.
+func (s *accountShards) account(number string) BankAccount {
+	if e, ok := s.entry(number); ok {
+		return e.account
+	}
+	return nil
+}
+
+// add registers account unless one with its number already is.
+func (s *accountShards) add(account BankAccount) bool {
+	shard := s.shard(account.Number())
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if _, ok := shard.entries[account.Number()]; ok {
+		return false
+	}
+	shard.entries[account.Number()] = &accountEntry{account: account}
+	return true
+}
+
+func (s *accountShards) remove(number string) {
+	shard := s.shard(number)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.entries, number)
+}
+
+// all returns every account, in no particular order.
+func (s *accountShards) all() []BankAccount {
+	var accounts []BankAccount
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for _, e := range shard.entries {
+			accounts = append(accounts, e.account)
+		}
+		shard.mu.RUnlock()
+	}
+	return accounts
+}
@@ -0,0 +1,768 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// SnapshotVersion is the format version written by Bank.Snapshot. Restore
+// reads this and every earlier version.
+const SnapshotVersion = 1
+
+var ErrUnsupportedSnapshot = banking.New(banking.CodeInvalidArgument, "unsupported snapshot")
+
+// bankSnapshot is the JSON document written by Snapshot.
+type bankSnapshot struct {
+	Version     int                `json:"version"`
+	Taken       time.Time          `json:"taken"`
+	Tenant      string             `json:"tenant,omitempty"`
+	Customers   []customerSnapshot `json:"customers"`
+	Accounts    []accountSnapshot  `json:"accounts"`
+	Pending     []pendingSnapshot  `json:"pending,omitempty"`
+	NextPending int                `json:"next_pending,omitempty"`
+	// NextOverride is the ID of the latest override of controls.
+	NextOverride int                 `json:"next_override,omitempty"`
+	Budgets      map[string][]Budget `json:"budgets,omitempty"`
+	Invitations  []Invitation        `json:"invitations,omitempty"`
+	Grants       []Grant             `json:"grants,omitempty"`
+	Estates      []Estate            `json:"estates,omitempty"`
+	NextGrant    int                 `json:"next_grant,omitempty"`
+	// Applications are the applications to open accounts.
+	Applications    []Application `json:"applications,omitempty"`
+	NextApplication int           `json:"next_application,omitempty"`
+	// Archived maps the numbers of archived accounts, whose ledgers live in
+	// the bank's Archive, to when they were archived.
+	Archived map[string]time.Time `json:"archived,omitempty"`
+	// Redirects are where renumbered accounts are now, see RenumberAccount.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// RateChanges are the scheduled changes of product rates.
+	RateChanges    []RateChange `json:"rate_changes,omitempty"`
+	NextRateChange int          `json:"next_rate_change,omitempty"`
+	// Messages are the messages in customers' inboxes, oldest first.
+	Messages []Message `json:"messages,omitempty"`
+	// Cases are the customers' support cases.
+	Cases    []Case `json:"cases,omitempty"`
+	NextCase int    `json:"next_case,omitempty"`
+	// Sessions are the customers' active sessions.
+	Sessions []sessionSnapshot `json:"sessions,omitempty"`
+	// SealedTOTPApps are the customers' authenticator apps, by customer,
+	// sealed with the bank's SecretKey. TOTPApps are the same in the
+	// clear, as earlier snapshots kept them; they are read but no longer
+	// written.
+	SealedTOTPApps map[string][]byte        `json:"sealed_totp_apps,omitempty"`
+	TOTPApps       map[string]authenticator `json:"totp_apps,omitempty"`
+	// APIKeys are the API keys issued, revoked and expired ones included.
+	APIKeys []apiKeySnapshot `json:"api_keys,omitempty"`
+	// Consents are the consents customers gave third-party clients.
+	Consents    []Consent `json:"consents,omitempty"`
+	NextConsent int       `json:"next_consent,omitempty"`
+	// Enrichments are what enrichment made of transactions, and
+	// MerchantRules the rules of the merchant rules enricher.
+	Enrichments   []Enrichment   `json:"enrichments,omitempty"`
+	MerchantRules []MerchantRule `json:"merchant_rules,omitempty"`
+	// InstallmentPlans are the installment plans, ended ones included.
+	InstallmentPlans []InstallmentPlan `json:"installment_plans,omitempty"`
+	// Rewards are the rewards of the accounts in a rewards program.
+	Rewards []Rewards `json:"rewards,omitempty"`
+	// LedgerRoots are the Merkle roots kept to prove entries against,
+	// oldest first.
+	LedgerRoots []publishedRoot `json:"ledger_roots,omitempty"`
+	// DelinquencyBuckets are the buckets of loans and cards at the last
+	// review of delinquency, by account.
+	DelinquencyBuckets map[string]DelinquencyBucket `json:"delinquency_buckets,omitempty"`
+}
+
+// apiKeySnapshot is an API key with its hash, which its JSON leaves out.
+type apiKeySnapshot struct {
+	APIKey
+	Token []byte `json:"token"`
+}
+
+// sessionSnapshot is a session with the hash of its token, which its JSON
+// leaves out.
+type sessionSnapshot struct {
+	Session
+	Token []byte `json:"token"`
+}
+
+type customerSnapshot struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// accountSnapshot holds the fields of every kind of account; Kind says which
+// of them apply.
+type accountSnapshot struct {
+	Kind           string           `json:"kind"`
+	Number         string           `json:"number"`
+	Product        string           `json:"product,omitempty"`
+	Currency       string           `json:"currency,omitempty"`
+	Balance        float64          `json:"balance"`
+	Transactions   []Transaction    `json:"transactions,omitempty"`
+	InterestRate   *money.Rate      `json:"interest_rate,omitempty"`
+	Variable       *VariableRate    `json:"variable,omitempty"`
+	RateResets     []RateReset      `json:"rate_resets,omitempty"`
+	OverdraftLimit float64          `json:"overdraft_limit,omitempty"`
+	Loan           *LoanTerms       `json:"loan,omitempty"`
+	Card           *CardTerms       `json:"card,omitempty"`
+	State          json.RawMessage  `json:"state,omitempty"`
+	Cycle          *StatementCycle  `json:"cycle,omitempty"`
+	Alias          *Alias           `json:"alias,omitempty"`
+	TimeZone       string           `json:"time_zone,omitempty"`
+	Controls       *accountControls `json:"controls,omitempty"`
+	// Holders are the holders of the account that are not admins without
+	// a limit.
+	Holders []Holder   `json:"holders,omitempty"`
+	Closed  *time.Time `json:"closed,omitempty"`
+	Deleted *time.Time `json:"deleted,omitempty"`
+	Dormant *time.Time `json:"dormant,omitempty"`
+}
+
+// pendingSnapshot is a PendingTransfer. The options it was booked with are
+// kept as the transaction fields they set, since functions cannot be saved.
+type pendingSnapshot struct {
+	ID        int         `json:"id"`
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	Amount    float64     `json:"amount"`
+	Held      float64     `json:"held,omitempty"`
+	Booked    time.Time   `json:"booked"`
+	SettlesOn time.Time   `json:"settles_on"`
+	Template  Transaction `json:"template"`
+}
+
+// Snapshot writes the complete state of the bank as versioned JSON: its
+// customers, accounts with their ledgers, pending transfers and budgets. The
+// bank is locked while the snapshot is taken, so it is consistent.
+// Authenticator app secrets are only written sealed with SecretKey and are
+// left out without one.
+func (b *Bank) Snapshot(w io.Writer) error {
+	snap, err := b.capture(nil)
+	if err != nil {
+		return err
+	}
+	sort.Slice(snap.Customers, func(i, j int) bool { return snap.Customers[i].ID < snap.Customers[j].ID })
+	sort.Slice(snap.Accounts, func(i, j int) bool { return snap.Accounts[i].Number < snap.Accounts[j].Number })
+	for _, budgets := range snap.Budgets {
+		sort.Slice(budgets, func(i, j int) bool { return budgets[i].Category < budgets[j].Category })
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// capture takes the state of the bank for Snapshot, with the bank locked so
+// it is consistent. Given only, it takes just what a deposit, withdrawal or
+// transfer between the accounts only names reads: those accounts and the
+// ones their pending transfers go to, with their budgets, the customers
+// owning them, and the bank's grants, estates and redirects. The state of
+// customers and of the ledger as a whole, such as sessions, messages and
+// ledger roots, is left out.
+func (b *Bank) capture(only map[string]bool) (bankSnapshot, error) {
+	b.mu.Lock()
+	snap, err := b.captureLocked(only)
+	b.mu.Unlock()
+	if err != nil {
+		return snap, err
+	}
+
+	b.Budgets.mu.RLock()
+	for number, byCategory := range b.Budgets.byAccount {
+		if only != nil && !only[number] {
+			continue
+		}
+		for _, budget := range byCategory {
+			if snap.Budgets == nil {
+				snap.Budgets = make(map[string][]Budget)
+			}
+			snap.Budgets[number] = append(snap.Budgets[number], budget)
+		}
+	}
+	b.Budgets.mu.RUnlock()
+	return snap, nil
+}
+
+func (b *Bank) captureLocked(only map[string]bool) (bankSnapshot, error) {
+	if only != nil {
+		scope := make(map[string]bool, len(only))
+		for number := range only {
+			scope[number] = true
+		}
+		for _, pt := range b.pending {
+			if scope[pt.From] {
+				scope[pt.To] = true
+			}
+		}
+		only = scope
+	}
+	keep := func(number string) bool { return only == nil || only[number] }
+
+	snap := bankSnapshot{Version: SnapshotVersion, Taken: b.now(), Tenant: b.Tenant, NextPending: b.nextPending, NextOverride: b.nextOverride,
+		Grants: append([]Grant(nil), b.grants...), NextGrant: b.nextGrant}
+	for _, c := range b.customers {
+		s := customerSnapshot{ID: c.ID, Name: c.Name}
+		for _, number := range c.Accounts {
+			if keep(number) {
+				s.Accounts = append(s.Accounts, number)
+			}
+		}
+		snap.Customers = append(snap.Customers, s)
+	}
+	for _, a := range b.accounts.all() {
+		number := a.Number()
+		if !keep(number) {
+			continue
+		}
+		s, err := snapshotAccount(a)
+		if err != nil {
+			return snap, err
+		}
+		if closed, ok := b.closed[number]; ok {
+			s.Closed = &closed
+		}
+		if deleted, ok := b.deleted[number]; ok {
+			s.Deleted = &deleted
+		}
+		if dormant, ok := b.dormant[number]; ok {
+			s.Dormant = &dormant
+		}
+		if cycle, ok := b.cycles[number]; ok {
+			s.Cycle = &cycle
+		}
+		if alias, ok := b.aliases[number]; ok {
+			s.Alias = &alias
+		}
+		if loc, ok := b.zones[number]; ok {
+			s.TimeZone = loc.String()
+		}
+		if controls, ok := b.controls[number]; ok {
+			s.Controls = &controls
+		}
+		for _, h := range b.holders[number] {
+			s.Holders = append(s.Holders, h)
+		}
+		sort.Slice(s.Holders, func(i, j int) bool { return s.Holders[i].Customer < s.Holders[j].Customer })
+		snap.Accounts = append(snap.Accounts, s)
+	}
+	for _, pt := range b.pending {
+		if !keep(pt.From) || !keep(pt.To) {
+			continue
+		}
+		template := Transaction{}
+		for _, opt := range pt.opts {
+			opt(&template)
+		}
+		snap.Pending = append(snap.Pending, pendingSnapshot{
+			ID: pt.ID, From: pt.From, To: pt.To, Amount: pt.Amount, Held: pt.Held,
+			Booked: pt.Booked, SettlesOn: pt.SettlesOn, Template: template,
+		})
+	}
+	for _, e := range b.estates {
+		snap.Estates = append(snap.Estates, e.clone())
+	}
+	sort.Slice(snap.Estates, func(i, j int) bool { return snap.Estates[i].Customer < snap.Estates[j].Customer })
+	for _, r := range b.redirects {
+		snap.Redirects = append(snap.Redirects, r)
+	}
+	sort.Slice(snap.Redirects, func(i, j int) bool { return snap.Redirects[i].From < snap.Redirects[j].From })
+	for _, r := range b.rewards {
+		if keep(r.Account) {
+			snap.Rewards = append(snap.Rewards, r.clone())
+		}
+	}
+	sort.Slice(snap.Rewards, func(i, j int) bool { return snap.Rewards[i].Account < snap.Rewards[j].Account })
+	for number, bucket := range b.buckets {
+		if !keep(number) {
+			continue
+		}
+		if snap.DelinquencyBuckets == nil {
+			snap.DelinquencyBuckets = make(map[string]DelinquencyBucket)
+		}
+		snap.DelinquencyBuckets[number] = bucket
+	}
+	snap.MerchantRules = append([]MerchantRule(nil), b.merchantRules...)
+	if only != nil {
+		return snap, nil
+	}
+
+	snap.NextApplication = b.nextApplication
+	for _, app := range b.applications {
+		snap.Applications = append(snap.Applications, app.clone())
+	}
+	sort.Slice(snap.Applications, func(i, j int) bool { return snap.Applications[i].ID < snap.Applications[j].ID })
+	for _, inv := range b.invitations {
+		snap.Invitations = append(snap.Invitations, inv)
+	}
+	sort.Slice(snap.Invitations, func(i, j int) bool { return snap.Invitations[i].ID < snap.Invitations[j].ID })
+	for number, at := range b.archived {
+		if snap.Archived == nil {
+			snap.Archived = make(map[string]time.Time)
+		}
+		snap.Archived[number] = at
+	}
+	for _, c := range b.rateChanges {
+		snap.RateChanges = append(snap.RateChanges, c.clone())
+	}
+	snap.NextRateChange = b.nextRateChange
+	for _, c := range b.cases {
+		snap.Cases = append(snap.Cases, c.clone())
+	}
+	sort.Slice(snap.Cases, func(i, j int) bool { return snap.Cases[i].ID < snap.Cases[j].ID })
+	snap.NextCase = b.nextCase
+	now := b.now()
+	for _, s := range b.sessions {
+		if s.active(now) {
+			snap.Sessions = append(snap.Sessions, sessionSnapshot{Session: *s, Token: s.token[:]})
+		}
+	}
+	sort.Slice(snap.Sessions, func(i, j int) bool { return snap.Sessions[i].ID < snap.Sessions[j].ID })
+	for _, k := range b.apiKeys {
+		snap.APIKeys = append(snap.APIKeys, apiKeySnapshot{APIKey: k.clone(), Token: k.token[:]})
+	}
+	sort.Slice(snap.APIKeys, func(i, j int) bool { return snap.APIKeys[i].ID < snap.APIKeys[j].ID })
+	for _, c := range b.consents {
+		snap.Consents = append(snap.Consents, c.clone())
+	}
+	sort.Slice(snap.Consents, func(i, j int) bool { return snap.Consents[i].ID < snap.Consents[j].ID })
+	snap.NextConsent = b.nextConsent
+	for _, e := range b.enrichments {
+		snap.Enrichments = append(snap.Enrichments, e.clone())
+	}
+	sort.Slice(snap.Enrichments, func(i, j int) bool { return snap.Enrichments[i].Transaction < snap.Enrichments[j].Transaction })
+	for _, p := range b.installments {
+		snap.InstallmentPlans = append(snap.InstallmentPlans, p.clone())
+	}
+	sort.Slice(snap.InstallmentPlans, func(i, j int) bool {
+		return compareIDs(snap.InstallmentPlans[i].ID, snap.InstallmentPlans[j].ID) < 0
+	})
+	snap.LedgerRoots = append([]publishedRoot(nil), b.ledgerRoots...)
+	if len(b.SecretKey) > 0 {
+		for customer, a := range b.totpApps {
+			sealed, err := b.sealAuthenticator(customer, *a)
+			if err != nil {
+				return snap, err
+			}
+			if snap.SealedTOTPApps == nil {
+				snap.SealedTOTPApps = make(map[string][]byte)
+			}
+			snap.SealedTOTPApps[customer] = sealed
+		}
+	}
+	customerIDs := make([]string, 0, len(b.messages))
+	for id := range b.messages {
+		customerIDs = append(customerIDs, id)
+	}
+	sort.Strings(customerIDs)
+	for _, id := range customerIDs {
+		for _, m := range b.messages[id] {
+			snap.Messages = append(snap.Messages, m.clone())
+		}
+	}
+	return snap, nil
+}
+
+func snapshotAccount(a BankAccount) (accountSnapshot, error) {
+	s := accountSnapshot{
+		Kind:         KindOf(a),
+		Number:       a.Number(),
+		Product:      ProductCodeOf(a),
+		Currency:     CurrencyOf(a),
+		Balance:      a.CheckBalance(),
+		Transactions: append([]Transaction(nil), a.History()...),
+	}
+	switch a := a.(type) {
+	case *SavingsAccount:
+		rate := a.InterestRate
+		s.InterestRate = &rate
+		s.Variable = a.Variable
+		s.RateResets = append([]RateReset(nil), a.RateResets...)
+	case *CheckingAccount:
+		s.OverdraftLimit = a.OverdraftLimit
+	case *LoanAccount:
+		terms := a.Terms()
+		s.Loan = &terms
+	case *CreditCardAccount:
+		terms := a.Terms()
+		s.Card = &terms
+	case Custom:
+		state, err := a.MarshalState()
+		if err != nil {
+			return s, fmt.Errorf("account %s: %w", s.Number, err)
+		}
+		s.State = state
+	}
+	return s, nil
+}
+
+// Restore replaces the state of the bank with a snapshot written by
+// Snapshot. Nothing is changed when the snapshot cannot be read, is of a
+// newer version or is inconsistent, for example when a customer owns an
+// account the snapshot does not contain. No events are published.
+func (b *Bank) Restore(r io.Reader) error {
+	return b.RestoreWith(r, nil, nil)
+}
+
+// RestoreWith is Restore with accounts in place of the snapshot's accounts
+// of the same numbers, and added to them otherwise, for stores that keep
+// ledgers apart from the rest of a bank. What else the snapshot says of an
+// account, such as its holders, controls and when it was closed, still
+// holds. Accounts the snapshot has archived are left out. Customers, unless
+// nil, replace those of the snapshot.
+func (b *Bank) RestoreWith(r io.Reader, accounts []BankAccount, customers []*Customer) error {
+	var snap bankSnapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedSnapshot, err)
+	}
+	if snap.Version < 1 || snap.Version > SnapshotVersion {
+		return fmt.Errorf("%w: version %d", ErrUnsupportedSnapshot, snap.Version)
+	}
+	if err := snap.replaceAccounts(accounts); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedSnapshot, err)
+	}
+	if customers != nil {
+		snap.Customers = make([]customerSnapshot, len(customers))
+		for i, c := range customers {
+			snap.Customers[i] = customerSnapshot{ID: c.ID, Name: c.Name, Accounts: append([]string(nil), c.Accounts...)}
+		}
+	}
+	return b.restore(snap)
+}
+
+// replaceAccounts puts accounts in place of those of snap, see RestoreWith.
+func (snap *bankSnapshot) replaceAccounts(accounts []BankAccount) error {
+	index := make(map[string]int, len(snap.Accounts))
+	for i, s := range snap.Accounts {
+		index[s.Number] = i
+	}
+	for _, a := range accounts {
+		if _, ok := snap.Archived[a.Number()]; ok {
+			continue
+		}
+		s, err := snapshotAccount(a)
+		if err != nil {
+			return err
+		}
+		i, ok := index[s.Number]
+		if !ok {
+			snap.Accounts = append(snap.Accounts, s)
+			continue
+		}
+		old := snap.Accounts[i]
+		s.Closed, s.Deleted, s.Dormant = old.Closed, old.Deleted, old.Dormant
+		s.Cycle, s.Alias, s.TimeZone, s.Controls, s.Holders = old.Cycle, old.Alias, old.TimeZone, old.Controls, old.Holders
+		snap.Accounts[i] = s
+	}
+	return nil
+}
+
+func (b *Bank) restore(snap bankSnapshot) error {
+
+	accounts := make(map[string]BankAccount, len(snap.Accounts))
+	closed := make(map[string]time.Time)
+	deleted := make(map[string]time.Time)
+	dormant := make(map[string]time.Time)
+	cycles := make(map[string]StatementCycle)
+	aliases := make(map[string]Alias)
+	zones := make(map[string]*time.Location)
+	controls := make(map[string]accountControls)
+	holders := make(map[string]map[string]Holder)
+	for _, s := range snap.Accounts {
+		if _, ok := accounts[s.Number]; ok {
+			return fmt.Errorf("%w: account %s appears twice", ErrUnsupportedSnapshot, s.Number)
+		}
+		account, err := restoreAccount(s)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedSnapshot, err)
+		}
+		accounts[s.Number] = account
+		if s.Closed != nil {
+			closed[s.Number] = *s.Closed
+		}
+		if s.Deleted != nil {
+			deleted[s.Number] = *s.Deleted
+		}
+		if s.Dormant != nil {
+			dormant[s.Number] = *s.Dormant
+		}
+		if s.Cycle != nil {
+			cycles[s.Number] = *s.Cycle
+		}
+		if s.Alias != nil {
+			aliases[s.Number] = *s.Alias
+		}
+		if s.TimeZone != "" {
+			loc, err := time.LoadLocation(s.TimeZone)
+			if err != nil {
+				return fmt.Errorf("%w: account %s: %v", ErrUnsupportedSnapshot, s.Number, err)
+			}
+			zones[s.Number] = loc
+		}
+		if s.Controls != nil {
+			controls[s.Number] = *s.Controls
+		}
+		for _, h := range s.Holders {
+			if holders[s.Number] == nil {
+				holders[s.Number] = make(map[string]Holder)
+			}
+			holders[s.Number][h.Customer] = h
+		}
+	}
+	archived := make(map[string]time.Time, len(snap.Archived))
+	for number, at := range snap.Archived {
+		archived[number] = at
+	}
+	rateChanges := make([]*RateChange, len(snap.RateChanges))
+	for i := range snap.RateChanges {
+		rateChanges[i] = &snap.RateChanges[i]
+	}
+	cases := make(map[int]*Case, len(snap.Cases))
+	for i := range snap.Cases {
+		c := &snap.Cases[i]
+		if len(c.Steps) == 0 {
+			return fmt.Errorf("%w: case %d has no steps", ErrUnsupportedSnapshot, c.ID)
+		}
+		cases[c.ID] = c
+	}
+	sessions := make(map[string]*Session, len(snap.Sessions))
+	sessionIDs := make(map[[32]byte]string, len(snap.Sessions))
+	for _, ss := range snap.Sessions {
+		s := ss.Session
+		if len(ss.Token) != len(s.token) {
+			return fmt.Errorf("%w: session %s has no token", ErrUnsupportedSnapshot, s.ID)
+		}
+		copy(s.token[:], ss.Token)
+		sessions[s.ID] = &s
+		sessionIDs[s.token] = s.ID
+	}
+	apiKeys := make(map[string]*APIKey, len(snap.APIKeys))
+	apiKeyIDs := make(map[[32]byte]string, len(snap.APIKeys))
+	for _, ks := range snap.APIKeys {
+		k := ks.APIKey
+		if len(ks.Token) != len(k.token) {
+			return fmt.Errorf("%w: API key %s has no token", ErrUnsupportedSnapshot, k.ID)
+		}
+		copy(k.token[:], ks.Token)
+		apiKeys[k.ID] = &k
+		apiKeyIDs[k.token] = k.ID
+	}
+	consents := make(map[int]*Consent, len(snap.Consents))
+	for i := range snap.Consents {
+		consents[snap.Consents[i].ID] = &snap.Consents[i]
+	}
+	enrichments := make(map[string]*Enrichment, len(snap.Enrichments))
+	for i := range snap.Enrichments {
+		enrichments[snap.Enrichments[i].Transaction] = &snap.Enrichments[i]
+	}
+	installments := make(map[string]*InstallmentPlan, len(snap.InstallmentPlans))
+	for i := range snap.InstallmentPlans {
+		installments[snap.InstallmentPlans[i].ID] = &snap.InstallmentPlans[i]
+	}
+	rewards := make(map[string]*Rewards, len(snap.Rewards))
+	for i := range snap.Rewards {
+		r := &snap.Rewards[i]
+		// Snapshots from before rewards kept the account's currency.
+		if account := accounts[r.Account]; r.Currency == "" && account != nil {
+			r.Currency = CurrencyOf(account)
+		}
+		rewards[r.Account] = r
+	}
+	buckets := make(map[string]DelinquencyBucket, len(snap.DelinquencyBuckets))
+	for number, bucket := range snap.DelinquencyBuckets {
+		buckets[number] = bucket
+	}
+	totpApps := make(map[string]*authenticator, len(snap.TOTPApps)+len(snap.SealedTOTPApps))
+	for customer, a := range snap.TOTPApps {
+		totpApps[customer] = &a
+	}
+	if len(snap.SealedTOTPApps) > 0 && len(b.SecretKey) == 0 {
+		return fmt.Errorf("%w: authenticator apps are sealed and the bank has no secret key", ErrUnsupportedSnapshot)
+	}
+	for customer, sealed := range snap.SealedTOTPApps {
+		a, err := b.openAuthenticator(customer, sealed)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedSnapshot, err)
+		}
+		totpApps[customer] = a
+	}
+	messages := make(map[string][]*Message)
+	for i := range snap.Messages {
+		m := &snap.Messages[i]
+		messages[m.Customer] = append(messages[m.Customer], m)
+	}
+	redirects := make(map[string]Redirect, len(snap.Redirects))
+	for _, r := range snap.Redirects {
+		redirects[r.From] = r
+	}
+	customers := make(map[string]*Customer, len(snap.Customers))
+	for _, s := range snap.Customers {
+		if _, ok := customers[s.ID]; ok {
+			return fmt.Errorf("%w: customer %s appears twice", ErrUnsupportedSnapshot, s.ID)
+		}
+		for _, number := range s.Accounts {
+			if _, ok := accounts[number]; !ok && archived[number].IsZero() {
+				return fmt.Errorf("%w: customer %s owns unknown account %s", ErrUnsupportedSnapshot, s.ID, number)
+			}
+		}
+		customers[s.ID] = &Customer{ID: s.ID, Name: s.Name, Accounts: s.Accounts}
+	}
+	pending := make([]*PendingTransfer, 0, len(snap.Pending))
+	held := make(map[string]float64)
+	for _, s := range snap.Pending {
+		if accounts[s.From] == nil || accounts[s.To] == nil {
+			return fmt.Errorf("%w: pending transfer %d names an unknown account", ErrUnsupportedSnapshot, s.ID)
+		}
+		// Snapshots taken before fees were held hold the amount alone.
+		if s.Held == 0 {
+			s.Held = s.Amount
+		}
+		pending = append(pending, &PendingTransfer{
+			ID: s.ID, From: s.From, To: s.To, Amount: s.Amount, Held: s.Held,
+			Booked: s.Booked, SettlesOn: s.SettlesOn,
+			opts: []TxOption{withTemplate(s.Template)},
+		})
+		held[s.From] += s.Held
+	}
+	budgets := make(map[string]map[string]Budget, len(snap.Budgets))
+	for number, list := range snap.Budgets {
+		budgets[number] = make(map[string]Budget, len(list))
+		for _, budget := range list {
+			budgets[number][budget.Category] = budget
+		}
+	}
+
+	applications := make(map[int]*Application, len(snap.Applications))
+	for _, app := range snap.Applications {
+		if len(app.Steps) == 0 {
+			return fmt.Errorf("%w: application %d has no steps", ErrUnsupportedSnapshot, app.ID)
+		}
+		applications[app.ID] = &app
+	}
+	invitations := make(map[string]Invitation, len(snap.Invitations))
+	for _, inv := range snap.Invitations {
+		invitations[inv.ID] = inv
+	}
+
+	estates := make(map[string]*Estate, len(snap.Estates))
+	frozen := make(map[string]string)
+	for _, e := range snap.Estates {
+		estates[e.Customer] = &e
+		for _, number := range e.Accounts {
+			frozen[number] = e.Customer
+		}
+	}
+
+	registry := newAccountShards()
+	for _, account := range accounts {
+		registry.add(account)
+		b.hook(account)
+	}
+
+	b.mu.Lock()
+	b.Tenant = snap.Tenant
+	b.accounts = registry
+	b.closed = closed
+	b.deleted = deleted
+	b.dormant = dormant
+	b.estates = estates
+	b.frozen = frozen
+	b.cycles = cycles
+	b.aliases = aliases
+	b.zones = zones
+	b.controls = controls
+	b.nextOverride = snap.NextOverride
+	b.holders = holders
+	b.invitations = invitations
+	b.grants = snap.Grants
+	b.nextGrant = snap.NextGrant
+	b.applications = applications
+	b.nextApplication = snap.NextApplication
+	b.archived = archived
+	b.redirects = redirects
+	b.rateChanges = rateChanges
+	b.nextRateChange = snap.NextRateChange
+	b.messages = messages
+	b.cases = cases
+	b.sessions = sessions
+	b.sessionIDs = sessionIDs
+	b.totpApps = totpApps
+	b.apiKeys = apiKeys
+	b.apiKeyIDs = apiKeyIDs
+	b.consents = consents
+	b.nextConsent = snap.NextConsent
+	b.enrichments = enrichments
+	b.merchantRules = snap.MerchantRules
+	b.installments = installments
+	b.rewards = rewards
+	b.ledgerRoots = snap.LedgerRoots
+	b.buckets = buckets
+	b.nextCase = snap.NextCase
+	b.customers = customers
+	b.pending = pending
+	b.nextPending = snap.NextPending
+	b.held = held
+	b.mu.Unlock()
+
+	b.Budgets.mu.Lock()
+	b.Budgets.byAccount = budgets
+	b.Budgets.mu.Unlock()
+	b.Events.Publish(Event{Type: EventBankRestored, Time: b.now()})
+	return nil
+}
+
+func restoreAccount(s accountSnapshot) (BankAccount, error) {
+	account := Account{AccountNumber: s.Number, Balance: s.Balance, Transactions: s.Transactions, Product: s.Product, Currency: s.Currency}
+	switch s.Kind {
+	case "Savings":
+		sa := &SavingsAccount{Account: account, Variable: s.Variable, RateResets: s.RateResets}
+		if s.InterestRate != nil {
+			sa.InterestRate = *s.InterestRate
+		}
+		return sa, nil
+	case "Checking":
+		return &CheckingAccount{Account: account, OverdraftLimit: s.OverdraftLimit}, nil
+	case "Loan":
+		loan := &LoanAccount{Account: account}
+		if s.Loan != nil {
+			loan.SetTerms(*s.Loan)
+		}
+		return loan, nil
+	case "CreditCard":
+		card := &CreditCardAccount{Account: account}
+		if s.Card != nil {
+			card.SetTerms(*s.Card)
+		}
+		return card, nil
+	case "Account", "":
+		return &account, nil
+	default:
+		return RestoreCustom(s.Kind, account, s.State)
+	}
+}
+
+// withTemplate copies the fields set on template onto a transaction, undoing
+// the flattening of TxOptions done by Snapshot.
+func withTemplate(template Transaction) TxOption {
+	return func(tx *Transaction) {
+		if template.Counterparty != "" {
+			tx.Counterparty = template.Counterparty
+		}
+		if template.Category != "" {
+			tx.Category = template.Category
+		}
+		if template.Description != "" {
+			tx.Description = template.Description
+		}
+		tx.Tags = append(tx.Tags, template.Tags...)
+		for k, v := range template.Metadata {
+			WithMetadata(k, v)(tx)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestSnapshotRoundTrip restores a snapshot of a bank with an account of
+// every built-in kind, a customer, a budget and a closed account, and
+// checks that the restored bank has the same balances and terms and writes
+// the same snapshot again.
+func TestSnapshotRoundTrip(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-03-02")}
+	b := NewBank()
+	b.Clock = clock
+	for _, account := range []BankAccount{
+		&SavingsAccount{Account: Account{AccountNumber: "S1"}, InterestRate: money.Percent(2)},
+		&CheckingAccount{Account: Account{AccountNumber: "C1"}, OverdraftLimit: 200},
+		&CheckingAccount{Account: Account{AccountNumber: "C2"}},
+		&LoanAccount{Account: Account{AccountNumber: "L1"}, Principal: 5000, InterestRate: money.Percent(6), TermMonths: 24, Opened: clock.now},
+		&CreditCardAccount{Account: Account{AccountNumber: "K1"}, CreditLimit: 1000, PurchaseRate: money.Percent(18.25)},
+	} {
+		if err := b.Open(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada", Accounts: []string{"S1", "C1"}})
+	b.Budgets.Set("C1", Budget{Category: "dining", Limit: 100, Enforce: true})
+	b.Deposit("S1", 1000)
+	b.Withdraw("C1", 80, WithCategory("dining"))
+	b.Withdraw("K1", 40)
+	if err := b.CloseAccount("C2"); err != nil {
+		t.Fatal(err)
+	}
+
+	var first bytes.Buffer
+	if err := b.Snapshot(&first); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	restored.Clock = clock
+	if err := restored.Restore(bytes.NewReader(first.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	for number, want := range map[string]float64{"S1": 1000, "C1": -80, "L1": 0, "K1": -40} {
+		if got, err := restored.Balance(number); err != nil || got != want {
+			t.Errorf("%s balance = %.2f, %v, want %.2f", number, got, err, want)
+		}
+	}
+	if c, _ := restored.Account("C1"); c.(*CheckingAccount).OverdraftLimit != 200 {
+		t.Errorf("C1 overdraft limit %.2f, want 200.00", c.(*CheckingAccount).OverdraftLimit)
+	}
+	if l, _ := restored.Account("L1"); l.(*LoanAccount).Principal != 5000 || l.(*LoanAccount).TermMonths != 24 {
+		t.Errorf("L1 terms %+v, want 5000.00 over 24 months", l.(*LoanAccount).Terms())
+	}
+	if restored.ClosedAt("C2").IsZero() {
+		t.Error("C2 is open again")
+	}
+	if budget, ok := restored.Budgets.Get("C1", "dining"); !ok || !budget.Enforce {
+		t.Errorf("C1 dining budget %+v, %v", budget, ok)
+	}
+
+	var second bytes.Buffer
+	if err := restored.Snapshot(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("snapshot of the restored bank differs:\n%s\nwant\n%s", second.String(), first.String())
+	}
+}
+
+// TestRestoreRejects checks that snapshots that are not JSON, of versions
+// not known, or that name an account twice are refused.
+func TestRestoreRejects(t *testing.T) {
+	for _, snap := range []string{
+		`not json`,
+		`{"version": 0}`,
+		`{"version": 99}`,
+		`{"version": 1, "accounts": [{"kind": "Checking", "number": "C1"}, {"kind": "Checking", "number": "C1"}]}`,
+	} {
+		if err := NewBank().Restore(strings.NewReader(snap)); !errors.Is(err, ErrUnsupportedSnapshot) {
+			t.Errorf("restoring %s: %v, want ErrUnsupportedSnapshot", snap, err)
+		}
+	}
+}
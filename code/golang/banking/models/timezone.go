@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+)
+
+var ErrInvalidTimeZone = banking.New(banking.CodeInvalidArgument, "invalid time zone")
+
+// SetTimeZone sets the time zone of an account by its IANA name, such as
+// "Europe/Madrid": its entries are stamped in it and its statement periods
+// and daily limits start at its midnights. An empty name makes the account
+// follow the bank's zone again. Entries already posted keep their instant.
+func (b *Bank) SetTimeZone(number, name string) error {
+	if _, err := b.Account(number); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if name == "" {
+		delete(b.zones, number)
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return fmt.Errorf("%w: %q", ErrInvalidTimeZone, name)
+	}
+	b.zones[number] = loc
+	return nil
+}
+
+// TimeZone returns the time zone of an account: its own, the bank's
+// Location, or the zone of the bank's clock when neither is set.
+func (b *Bank) TimeZone(number string) *time.Location {
+	b.mu.RLock()
+	loc := b.zoneLocked(number)
+	b.mu.RUnlock()
+	if loc == nil {
+		return b.now().Location()
+	}
+	return loc
+}
+
+// zoneLocked returns the zone set for an account or the bank, nil when
+// neither is, holding b.mu.
+func (b *Bank) zoneLocked(number string) *time.Location {
+	if loc, ok := b.zones[number]; ok {
+		return loc
+	}
+	return b.Location
+}
+
+// nowIn is the time in an account's zone, holding b.mu.
+func (b *Bank) nowIn(number string) time.Time {
+	now := b.now()
+	if loc := b.zoneLocked(number); loc != nil {
+		return now.In(loc)
+	}
+	return now
+}
+
+// stamp gives an entry posted to an account its ID and puts its time in the
+// account's zone. Posts hold b.mu, so the zones are read without a lock of
+// their own.
+func (b *Bank) stamp(number string, tx *Transaction) {
+	if tx.ID == "" {
+		tx.ID = b.NewID()
+	}
+	if loc := b.zoneLocked(number); loc != nil {
+		tx.Time = tx.Time.In(loc)
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestTimeZones posts late in the evening in Los Angeles, already the next
+// day in UTC, and checks the entry is stamped in the account's zone, falls
+// in the account's statement month and survives a snapshot with its zone.
+func TestTimeZones(t *testing.T) {
+	SetOutput(io.Discard)
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	clock := &testClock{now: time.Date(2026, 3, 1, 5, 0, 0, 0, time.UTC)}
+	b := NewBank()
+	b.Clock = clock
+	b.Location = time.UTC
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.Open(&CheckingAccount{Account: Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.SetTimeZone("C1", "America/Los_Angeles"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetTimeZone("C1", "Mars/Olympus_Mons"); err == nil {
+		t.Error("set an unknown time zone")
+	}
+	for _, number := range []string{"C1", "C2"} {
+		if err := b.Deposit(number, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c1, _ := b.Account("C1")
+	c2, _ := b.Account("C2")
+	if got := c1.History()[0].Time; got.Location().String() != la.String() || got.Day() != 28 {
+		t.Errorf("C1 entry at %v, want 28 February in Los Angeles", got)
+	}
+	if got := c2.History()[0].Time; got.Location() != time.UTC || got.Day() != 1 {
+		t.Errorf("C2 entry at %v, want 1 March in UTC", got)
+	}
+
+	// Both statements for a day in March: C1's deposit was in February.
+	at := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	s1, err := b.Statement("C1", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, la); !s1.Period.Start.Equal(want) || len(s1.Transactions) != 0 {
+		t.Errorf("C1 statement from %v with %d entries, want from %v with none", s1.Period.Start, len(s1.Transactions), want)
+	}
+	s2, err := b.Statement("C2", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s2.Transactions) != 1 {
+		t.Errorf("C2 statement has %d entries, want 1", len(s2.Transactions))
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBank()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.TimeZone("C1"); got.String() != "America/Los_Angeles" {
+		t.Errorf("restored zone %v, want America/Los_Angeles", got)
+	}
+}
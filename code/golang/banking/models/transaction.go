@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"gsolano/banking/money"
+)
+
+type TransactionType string
+
+const (
+	TransactionDeposit    TransactionType = "deposit"
+	TransactionWithdrawal TransactionType = "withdrawal"
+	// TransactionInterest credits interest earned and
+	// TransactionInterestCharge debits interest owed under a negative rate.
+	TransactionInterest       TransactionType = "interest"
+	TransactionInterestCharge TransactionType = "interest_charge"
+	// TransactionFee debits a fee the bank charges, such as a late fee.
+	TransactionFee TransactionType = "fee"
+)
+
+// IsCredit reports whether transactions of type t add to the balance.
+func (t TransactionType) IsCredit() bool {
+	return t == TransactionDeposit || t == TransactionInterest
+}
+
+// Transaction is a single entry in an account's ledger. ID identifies it
+// across the bank, from the bank's IDs, and Sequence is its 1-based
+// position in the ledger, both assigned when it is posted. Counterparty is
+// the other party of the movement when it is known (a payee, an employer, ...)
+// and Category is the spending category it was tagged with, if any.
+// Description is a free-text memo and Tags are labels set by the customer. Interest
+// entries record the Rate they accrued at. Metadata carries free-form details
+// such as the lines of a payslip.
+type Transaction struct {
+	ID           string            `json:"id,omitempty"`
+	Sequence     int               `json:"sequence"`
+	Type         TransactionType   `json:"type"`
+	Amount       float64           `json:"amount"`
+	Counterparty string            `json:"counterparty,omitempty"`
+	Category     string            `json:"category,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Rate         money.Rate        `json:"rate,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Time         time.Time         `json:"time"`
+}
+
+// TxOption customizes a transaction before the Bank posts it.
+type TxOption func(*Transaction)
+
+// WithCounterparty records the other party of a transaction.
+func WithCounterparty(counterparty string) TxOption {
+	return func(tx *Transaction) { tx.Counterparty = counterparty }
+}
+
+// WithDescription sets the memo of a transaction.
+func WithDescription(description string) TxOption {
+	return func(tx *Transaction) { tx.Description = description }
+}
+
+// WithTags adds labels to a transaction.
+func WithTags(tags ...string) TxOption {
+	return func(tx *Transaction) { tx.Tags = append(tx.Tags, tags...) }
+}
+
+// WithMetadata adds a key and value to the metadata of a transaction.
+func WithMetadata(key, value string) TxOption {
+	return func(tx *Transaction) {
+		if tx.Metadata == nil {
+			tx.Metadata = make(map[string]string)
+		}
+		tx.Metadata[key] = value
+	}
+}
+
+// WithCategory tags a transaction with a spending category.
+func WithCategory(category string) TxOption {
+	return func(tx *Transaction) { tx.Category = category }
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// HistoricalRates supplies exchange rates as they were on a day, for
+// valuations of the past. Package fx has providers of them.
+type HistoricalRates interface {
+	ExchangeRateOn(base, quote string, day time.Time) (ExchangeRate, error)
+}
+
+// Valuation is what a customer's accounts were worth in Currency at At:
+// each account's balance converted at the rate of that day, and their
+// total. Debts, such as loans, count against it.
+type Valuation struct {
+	Customer string         `json:"customer"`
+	Currency string         `json:"currency"`
+	At       time.Time      `json:"at"`
+	Accounts []AccountValue `json:"accounts"`
+	Total    float64        `json:"total"`
+}
+
+// AccountValue is one account of a Valuation: its Balance in its own
+// currency and its Value in the valuation's at Rate.
+type AccountValue struct {
+	Number   string       `json:"number"`
+	Currency string       `json:"currency"`
+	Balance  float64      `json:"balance"`
+	Rate     ExchangeRate `json:"rate"`
+	Value    float64      `json:"value"`
+}
+
+// ValueCustomer values the accounts of a customer in currency at at.
+func (b *Bank) ValueCustomer(customerID, currency string, at time.Time) (Valuation, error) {
+	valuations, err := b.valueCustomer(customerID, currency, []time.Time{at})
+	if err != nil {
+		return Valuation{}, err
+	}
+	return valuations[0], nil
+}
+
+// maxValuations caps the points of a NetWorth series.
+const maxValuations = 1000
+
+// NetWorth values the accounts of a customer in currency at from and every
+// period of cadence after it up to to, for charts of their net worth over
+// time.
+func (b *Bank) NetWorth(customerID, currency string, from, to time.Time, every Cadence) ([]Valuation, error) {
+	if to.Before(from) {
+		return nil, banking.New(banking.CodeInvalidArgument, "net worth: to is before from")
+	}
+	if !every.Valid() {
+		return nil, banking.New(banking.CodeInvalidArgument, fmt.Sprintf("net worth: unknown cadence %q", every))
+	}
+	var dates []time.Time
+	for i := 0; ; i++ {
+		at := every.Add(from, i)
+		if at.After(to) {
+			break
+		}
+		if len(dates) == maxValuations {
+			return nil, banking.New(banking.CodeInvalidArgument, fmt.Sprintf("net worth: more than %d points, choose a longer cadence", maxValuations))
+		}
+		dates = append(dates, at)
+	}
+	return b.valueCustomer(customerID, currency, dates)
+}
+
+func (b *Bank) valueCustomer(customerID, currency string, dates []time.Time) ([]Valuation, error) {
+	if !ValidCurrency(currency) {
+		return nil, banking.New(banking.CodeInvalidArgument, fmt.Sprintf("%q is not a three letter ISO 4217 code", currency))
+	}
+	customer, err := b.Customer(customerID)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	numbers := append([]string(nil), customer.Accounts...)
+	b.mu.RUnlock()
+
+	valuations := make([]Valuation, len(dates))
+	for i, at := range dates {
+		valuations[i] = Valuation{Customer: customerID, Currency: currency, At: at, Accounts: []AccountValue{}}
+	}
+	for _, number := range numbers {
+		account, unlock, err := b.lockAccount(number)
+		if err != nil {
+			// Archived accounts no longer count.
+			continue
+		}
+		balances := make([]float64, len(dates))
+		for i, at := range dates {
+			balances[i] = balanceAt(account, at)
+		}
+		base := b.AccountCurrency(account)
+		unlock()
+
+		for i, at := range dates {
+			v := AccountValue{Number: number, Currency: base, Balance: balances[i]}
+			// Empty accounts, such as ones not opened yet, need no rate.
+			if balances[i] != 0 {
+				rate, err := b.rateOn(base, currency, at)
+				if err != nil {
+					return nil, fmt.Errorf("valuing %s: %w", number, err)
+				}
+				v.Rate = rate
+				v.Value = money.New(balances[i], base, b.Rounding).Convert(currency, rate.Rate, b.Rounding).Float()
+			}
+			valuations[i].Accounts = append(valuations[i].Accounts, v)
+			valuations[i].Total = money.Round(valuations[i].Total+v.Value, currency, b.Rounding)
+		}
+	}
+	return valuations, nil
+}
+
+// rateOn returns the rate of base in quote on the day of at: the current
+// one of the bank's FX from today on, and that of FXHistory before.
+func (b *Bank) rateOn(base, quote string, at time.Time) (ExchangeRate, error) {
+	if base == quote {
+		return ExchangeRate{Base: base, Quote: quote, Rate: 1}, nil
+	}
+	if base == "" {
+		return ExchangeRate{}, fmt.Errorf("%w: to %s, and the bank has no currency", ErrNoExchangeRate, quote)
+	}
+	now := b.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if b.FX != nil && !at.Before(today) {
+		return b.FX.ExchangeRate(base, quote)
+	}
+	if b.FXHistory != nil {
+		return b.FXHistory.ExchangeRateOn(base, quote, at)
+	}
+	return ExchangeRate{}, fmt.Errorf("%w: %s/%s on %s: no historical exchange rates configured", ErrNoExchangeRate, base, quote, at.Format(time.DateOnly))
+}
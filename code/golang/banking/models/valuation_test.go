@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking"
+)
+
+// monthlyRates gives the same rate on each day of a month, and none for the
+// months it lacks.
+type monthlyRates map[time.Month]float64
+
+func (r monthlyRates) ExchangeRateOn(base, quote string, day time.Time) (ExchangeRate, error) {
+	rate, ok := r[day.Month()]
+	if !ok {
+		return ExchangeRate{}, ErrNoExchangeRate
+	}
+	return ExchangeRate{Base: base, Quote: quote, Rate: rate, AsOf: day}, nil
+}
+
+// TestValuation values a customer with a dollar, a euro and a loan account
+// at the end of past months, at the rates of their day, and today, at the
+// current rate.
+func TestValuation(t *testing.T) {
+	SetOutput(io.Discard)
+	clock := &testClock{now: date(t, "2026-01-10")}
+	b := NewBank()
+	b.Clock = clock
+	b.Currency = "USD"
+	b.FX = fixedRate(1.3)
+	b.FXHistory = monthlyRates{time.January: 1.1, time.February: 1.2}
+	b.AddCustomer(&Customer{ID: "c1", Name: "Ada"})
+	for _, account := range []BankAccount{
+		&CheckingAccount{Account: Account{AccountNumber: "C1"}},
+		&SavingsAccount{Account: Account{AccountNumber: "E1", Currency: "EUR"}},
+		&LoanAccount{Account: Account{AccountNumber: "L1", Balance: -50}, Principal: 50},
+	} {
+		if err := b.OpenFor("c1", account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Deposit("C1", 100)
+	b.Deposit("E1", 100)
+	clock.now = date(t, "2026-02-10")
+	b.Deposit("E1", 100)
+	clock.now = date(t, "2026-03-15")
+
+	v, err := b.ValueCustomer("c1", "USD", date(t, "2026-01-31"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Total != 100+110-50 || len(v.Accounts) != 3 || v.Accounts[1].Balance != 100 || v.Accounts[1].Rate.Rate != 1.1 {
+		t.Errorf("valuation at the end of January %+v, want 160", v)
+	}
+	if v, _ := b.ValueCustomer("c1", "USD", clock.now); v.Total != 100+260-50 {
+		t.Errorf("valuation today %.2f, want 310 at the current rate", v.Total)
+	}
+
+	// Monthly points keep to the end of the month, February's being its 28th.
+	series, err := b.NetWorth("c1", "USD", date(t, "2026-01-31"), clock.now, CadenceMonthly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || !series[1].At.Equal(date(t, "2026-02-28")) || series[0].Total != 160 || series[1].Total != 100+240-50 {
+		t.Errorf("net worth %+v, want 160 on January 31 and 290 on February 28", series)
+	}
+
+	if _, err := b.ValueCustomer("c1", "USD", date(t, "2025-12-31")); err != nil {
+		t.Errorf("valuation before the accounts held anything: %v, want no rate needed", err)
+	}
+	clock.now = date(t, "2026-04-15")
+	if _, err := b.ValueCustomer("c1", "USD", date(t, "2026-03-31")); !errors.Is(err, ErrNoExchangeRate) {
+		t.Errorf("valuation without a rate: %v, want %v", err, ErrNoExchangeRate)
+	}
+	if _, err := b.ValueCustomer("c9", "USD", clock.now); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("unknown customer: %v, want %v", err, ErrCustomerNotFound)
+	}
+	for _, err := range []error{
+		func() error { _, err := b.ValueCustomer("c1", "dollars", clock.now); return err }(),
+		func() error {
+			_, err := b.NetWorth("c1", "USD", clock.now, date(t, "2026-01-01"), CadenceMonthly)
+			return err
+		}(),
+		func() error { _, err := b.NetWorth("c1", "USD", date(t, "2026-01-01"), clock.now, "daily"); return err }(),
+	} {
+		if banking.CodeOf(err) != banking.CodeInvalidArgument {
+			t.Errorf("got %v, want %s", err, banking.CodeInvalidArgument)
+		}
+	}
+}
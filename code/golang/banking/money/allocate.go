@@ -0,0 +1,64 @@
+package money
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Allocate splits m into parts proportional to ratios whose sum is exactly
+// m. Each part gets its share rounded down and the cents left over go one at
+// a time to the parts with the largest remainders, earlier parts first on a
+// tie, so the same inputs always split the same way. Allocate(1, 1, 1) of
+// 100.00 gives 33.34, 33.33 and 33.33.
+//
+// It panics if there are no ratios, a ratio is negative or all are zero,
+// or if m is math.MinInt64 minor units, which has no positive counterpart.
+func (m Money) Allocate(ratios ...int) []Money {
+	var total uint64
+	for _, r := range ratios {
+		if r < 0 {
+			panic("money: negative allocation ratio")
+		}
+		total += uint64(r)
+	}
+	if total == 0 {
+		panic("money: allocation ratios sum to zero")
+	}
+	if m.Minor == math.MinInt64 {
+		panic("money: amount out of range for allocation")
+	}
+
+	// Split the absolute amount so remainders are distributed the same way
+	// for debits and credits.
+	amount, sign := m.Minor, int64(1)
+	if amount < 0 {
+		amount, sign = -amount, -1
+	}
+	parts := make([]Money, len(ratios))
+	remainders := make([]uint64, len(ratios))
+	left := amount
+	for i, r := range ratios {
+		// The share is 128 bits wide; its quotient is at most amount.
+		hi, lo := bits.Mul64(uint64(amount), uint64(r))
+		quo, rem := bits.Div64(hi, lo, total)
+		parts[i] = Money{Minor: int64(quo), Currency: m.Currency}
+		remainders[i] = rem
+		left -= parts[i].Minor
+	}
+
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; left > 0; i++ {
+		parts[order[i]].Minor++
+		left--
+	}
+
+	for i := range parts {
+		parts[i].Minor *= sign
+	}
+	return parts
+}
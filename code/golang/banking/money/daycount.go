@@ -0,0 +1,105 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DayCount is a day-count convention: how the days between two dates are
+// counted and how many make a year, which together give the share of an
+// annual rate that accrues between them.
+type DayCount string
+
+const (
+	// Actual365 counts the actual days over a 365-day year, leap years
+	// included (ACT/365 Fixed).
+	Actual365 DayCount = "ACT/365"
+	// Actual360 counts the actual days over a 360-day year.
+	Actual360 DayCount = "ACT/360"
+	// Thirty360 counts every month as 30 days over a 360-day year, with
+	// the 31st of a month taken as the 30th as in the ISDA 30/360 bond
+	// basis: an end on the 31st counts as the 30th only when the start is
+	// the 30th or 31st.
+	Thirty360 DayCount = "30/360"
+)
+
+// ParseDayCount parses a convention written as ACT/365, ACT/360 or 30/360,
+// in any case and with ACTUAL for ACT.
+func ParseDayCount(s string) (DayCount, error) {
+	name := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	name = strings.Replace(name, "ACTUAL", "ACT", 1)
+	switch d := DayCount(name); d {
+	case Actual365, Actual360, Thirty360:
+		return d, nil
+	case "ACT/365F", "ACT/365FIXED":
+		return Actual365, nil
+	}
+	return "", fmt.Errorf("unknown day-count convention %q, want ACT/365, ACT/360 or 30/360", s)
+}
+
+// Valid reports whether d is one of the conventions above.
+func (d DayCount) Valid() bool {
+	return d == Actual365 || d == Actual360 || d == Thirty360
+}
+
+// Days counts the days from start to end under d, by calendar date in the
+// location of each; it is negative when end is before start.
+func (d DayCount) Days(start, end time.Time) int {
+	if d == Thirty360 {
+		y1, m1, d1 := start.Date()
+		y2, m2, d2 := end.Date()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		return 360*(y2-y1) + 30*(int(m2)-int(m1)) + d2 - d1
+	}
+	return civilDay(end) - civilDay(start)
+}
+
+// Basis is the number of days in a year under d.
+func (d DayCount) Basis() int {
+	if d == Actual365 {
+		return 365
+	}
+	return 360
+}
+
+// YearFraction is the exact share of a year from start to end under d.
+func (d DayCount) YearFraction(start, end time.Time) *big.Rat {
+	return big.NewRat(int64(d.Days(start, end)), int64(d.Basis()))
+}
+
+// Accrued returns the exact interest amount earns at the annual rate r from
+// start to end under d, in major units of its currency.
+func (r Rate) Accrued(amount *big.Rat, d DayCount, start, end time.Time) *big.Rat {
+	x := new(big.Rat).Mul(amount, d.YearFraction(start, end))
+	return x.Mul(x, big.NewRat(int64(r), int64(Percent100)))
+}
+
+func (d DayCount) MarshalText() ([]byte, error) {
+	return []byte(d), nil
+}
+
+func (d *DayCount) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = ""
+		return nil
+	}
+	parsed, err := ParseDayCount(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// civilDay numbers the calendar date of t, whatever its time of day.
+func civilDay(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
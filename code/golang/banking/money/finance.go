@@ -0,0 +1,115 @@
+package money
+
+import (
+	"errors"
+	"math"
+)
+
+// The finance helpers below let a customer check what an account charges or
+// pays. Quoted annual rates are Rates; periodic rates, such as the monthly
+// rate of a loan or an IRR, are fractions per period (0.005 is 0.5% a
+// period) since they are usually finer than a basis point.
+
+// EffectiveAnnualRate returns the rate actually earned or paid over a year
+// when the nominal annual rate compounds periodsPerYear times: the APY of
+// a savings account or deposit, or the effective rate of a loan. A nominal
+// 12% compounding monthly is 12.68%.
+func EffectiveAnnualRate(nominal Rate, periodsPerYear int) Rate {
+	if periodsPerYear <= 0 {
+		return nominal
+	}
+	periodic := nominal.Percent() / 100 / float64(periodsPerYear)
+	return Percent((math.Pow(1+periodic, float64(periodsPerYear)) - 1) * 100)
+}
+
+// NominalAnnualRate is the inverse of EffectiveAnnualRate: the nominal rate
+// that compounds to effective over periodsPerYear periods.
+func NominalAnnualRate(effective Rate, periodsPerYear int) Rate {
+	if periodsPerYear <= 0 {
+		return effective
+	}
+	periodic := math.Pow(1+effective.Percent()/100, 1/float64(periodsPerYear)) - 1
+	return APRFromPeriodicRate(periodic, periodsPerYear)
+}
+
+// APRFromPeriodicRate returns the annual percentage rate of a periodic rate:
+// the periodic rate times the number of periods in a year, the way APRs are
+// quoted.
+func APRFromPeriodicRate(periodic float64, periodsPerYear int) Rate {
+	return Percent(periodic * float64(periodsPerYear) * 100)
+}
+
+// NPV is the net present value of cashflows, one per period starting now,
+// discounted at the periodic rate. Money received is positive and money paid
+// out negative.
+func NPV(periodic float64, cashflows []float64) float64 {
+	npv := 0.0
+	for t, cf := range cashflows {
+		npv += cf / math.Pow(1+periodic, float64(t))
+	}
+	return npv
+}
+
+var ErrNoIRR = errors.New("cashflows have no internal rate of return")
+
+// irrTolerance is how close to zero the NPV at the IRR must be.
+const irrTolerance = 1e-9
+
+// IRR returns the periodic rate at which the NPV of cashflows is zero. The
+// cashflows must change sign at least once; when they change more than once
+// there may be several such rates and any one of them, between -100% and
+// 1000% a period, is returned. IRR returns ErrNoIRR when there is none.
+func IRR(cashflows []float64) (float64, error) {
+	positive, negative := false, false
+	for _, cf := range cashflows {
+		positive = positive || cf > 0
+		negative = negative || cf < 0
+	}
+	if !positive || !negative {
+		return 0, ErrNoIRR
+	}
+	f := func(r float64) float64 { return NPV(r, cashflows) }
+	df := func(r float64) float64 {
+		d := 0.0
+		for t, cf := range cashflows {
+			d -= float64(t) * cf / math.Pow(1+r, float64(t+1))
+		}
+		return d
+	}
+
+	// Newton's method converges in a few steps for the usual loan or deposit.
+	r := 0.1
+	for i := 0; i < 50; i++ {
+		v, d := f(r), df(r)
+		if math.Abs(v) < irrTolerance {
+			return r, nil
+		}
+		if d == 0 {
+			break
+		}
+		next := r - v/d
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		r = next
+	}
+
+	// Otherwise bisect over the widest range that makes sense.
+	lo, hi := -0.9999, 10.0
+	if f(lo)*f(hi) > 0 {
+		return 0, ErrNoIRR
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		v := f(mid)
+		if math.Abs(v) < irrTolerance || hi-lo < 1e-12 {
+			return mid, nil
+		}
+		if v*f(lo) < 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return (lo + hi) / 2, nil
+}
@@ -0,0 +1,195 @@
+// Package money represents amounts of a currency as an integer number of
+// minor units (cents) and rounds explicitly, with a chosen Rounding, whenever
+// a computation such as interest, FX conversion or a percentage fee produces
+// fractions of a minor unit.
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Rounding says how an amount that falls between two minor units is rounded.
+// The zero value is HalfEven.
+type Rounding int
+
+const (
+	// HalfEven rounds to the nearest minor unit and ties to the even one
+	// (banker's rounding), so ties do not bias sums in either direction.
+	HalfEven Rounding = iota
+	// HalfUp rounds to the nearest minor unit and ties away from zero.
+	HalfUp
+	// Floor rounds down, towards negative infinity.
+	Floor
+)
+
+var roundingNames = map[Rounding]string{
+	HalfEven: "half_even",
+	HalfUp:   "half_up",
+	Floor:    "floor",
+}
+
+func (r Rounding) String() string {
+	if name, ok := roundingNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Rounding(%d)", int(r))
+}
+
+// ParseRounding parses the name of a rounding mode: half_even (or bankers),
+// half_up or floor.
+func ParseRounding(name string) (Rounding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "half_even", "bankers":
+		return HalfEven, nil
+	case "half_up":
+		return HalfUp, nil
+	case "floor":
+		return Floor, nil
+	}
+	return 0, fmt.Errorf("unknown rounding %q", name)
+}
+
+// minorUnits lists currencies whose minor unit is not a hundredth.
+var minorUnits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"CLP": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"JOD": 3,
+}
+
+// MinorUnits returns the number of decimals of a currency: 2 unless the
+// currency is known to use another number, such as 0 for JPY.
+func MinorUnits(currency string) int {
+	if n, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return n
+	}
+	return 2
+}
+
+// Money is an amount of a currency in its minor units.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// New converts amount to money, rounding it to the currency's minor unit.
+// amount is taken as the shortest decimal that represents it, so 2.675
+// rounds half up to 2.68 even though the float is slightly below 2.675.
+//
+// New does not fail: NaN is taken as zero, and amounts whose minor units an
+// int64 cannot hold, the infinities among them, saturate at the largest or
+// smallest it can. The same holds for the factors and rates of Mul, Convert
+// and Percent. Callers that take amounts from outside should reject
+// non-finite ones before they get here.
+func New(amount float64, currency string, r Rounding) Money {
+	return Money{Minor: round(scale(decimal(amount), MinorUnits(currency)), r), Currency: strings.ToUpper(currency)}
+}
+
+// Round rounds a float amount to the minor unit of currency.
+func Round(amount float64, currency string, r Rounding) float64 {
+	return New(amount, currency, r).Float()
+}
+
+// Float returns the amount in major units.
+func (m Money) Float() float64 {
+	f, _ := new(big.Rat).SetFrac(big.NewInt(m.Minor), pow10(MinorUnits(m.Currency))).Float64()
+	return f
+}
+
+func (m Money) IsZero() bool { return m.Minor == 0 }
+
+func (m Money) Neg() Money { return Money{Minor: -m.Minor, Currency: m.Currency} }
+
+// Add returns m+o. It panics if the currencies differ.
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return Money{Minor: m.Minor + o.Minor, Currency: m.Currency}
+}
+
+// Sub returns m-o. It panics if the currencies differ.
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return Money{Minor: m.Minor - o.Minor, Currency: m.Currency}
+}
+
+// Mul multiplies m by factor, such as an interest rate or an exchange rate,
+// and rounds the result to a minor unit.
+func (m Money) Mul(factor float64, r Rounding) Money {
+	x := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Minor), decimal(factor))
+	return Money{Minor: round(x, r), Currency: m.Currency}
+}
+
+// Convert changes m into currency at rate units of currency per unit of m's
+// currency, rounding to the target currency's minor unit.
+func (m Money) Convert(currency string, rate float64, r Rounding) Money {
+	major := new(big.Rat).SetFrac(big.NewInt(m.Minor), pow10(MinorUnits(m.Currency)))
+	x := scale(new(big.Rat).Mul(major, decimal(rate)), MinorUnits(currency))
+	return Money{Minor: round(x, r), Currency: strings.ToUpper(currency)}
+}
+
+// String formats m as "12.34 USD".
+func (m Money) String() string {
+	s := strconv.FormatFloat(m.Float(), 'f', MinorUnits(m.Currency), 64)
+	if m.Currency == "" {
+		return s
+	}
+	return s + " " + m.Currency
+}
+
+func (m Money) mustMatch(o Money) {
+	if m.Currency != o.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, o.Currency))
+	}
+}
+
+// decimal returns f as the exact value of its shortest decimal form, NaN
+// as zero and the infinities as the largest finite floats.
+func decimal(f float64) *big.Rat {
+	switch {
+	case math.IsNaN(f):
+		return new(big.Rat)
+	case math.IsInf(f, 0):
+		f = math.Copysign(math.MaxFloat64, f)
+	}
+	x, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return x
+}
+
+func scale(x *big.Rat, decimals int) *big.Rat {
+	return x.Mul(x, new(big.Rat).SetInt(pow10(decimals)))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// round rounds x to an integer, saturating at the bounds of an int64.
+func round(x *big.Rat, r Rounding) int64 {
+	// DivMod is Euclidean: q is the floor of x and 0 <= rem < denominator.
+	q, rem := new(big.Int).DivMod(x.Num(), x.Denom(), new(big.Int))
+	switch {
+	case rem.Sign() == 0 || r == Floor:
+	default:
+		switch rem.Lsh(rem, 1).Cmp(x.Denom()) {
+		case 1:
+			q.Add(q, big.NewInt(1))
+		case 0:
+			if r == HalfEven && q.Bit(0) == 1 || r == HalfUp && x.Sign() > 0 {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	switch {
+	case q.IsInt64():
+		return q.Int64()
+	case q.Sign() > 0:
+		return math.MaxInt64
+	}
+	return math.MinInt64
+}
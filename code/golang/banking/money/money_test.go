@@ -0,0 +1,332 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzParseRate(f *testing.F) {
+	for _, s := range []string{"5%", "-0.25 %", "5", "125bps", "125 bp", "1.255%", "", "%", "bps", "1e9999", "1/3", "0x10", " 7.5 % "} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		r, err := ParseRate(s)
+		if err != nil {
+			return
+		}
+		back, err := ParseRate(r.String())
+		if err != nil {
+			t.Fatalf("ParseRate(%q) = %d, but its String %q does not parse: %v", s, r, r.String(), err)
+		}
+		if back != r {
+			t.Fatalf("ParseRate(%q) = %d, String %q, parsed back as %d", s, r, r.String(), back)
+		}
+	})
+}
+
+func FuzzNew(f *testing.F) {
+	f.Add(2.675, "USD", uint8(HalfUp))
+	f.Add(-0.005, "", uint8(HalfEven))
+	f.Add(1234.5, "JPY", uint8(Floor))
+	f.Add(0.0005, "KWD", uint8(HalfUp))
+	f.Add(1e14, "eur", uint8(HalfEven))
+	f.Add(-0.001, "USD", uint8(Floor))
+	f.Add(math.NaN(), "USD", uint8(HalfEven))
+	f.Add(math.Inf(-1), "USD", uint8(HalfEven))
+	f.Add(1e300, "KWD", uint8(HalfUp))
+	f.Fuzz(func(t *testing.T, amount float64, currency string, rounding uint8) {
+		r := Rounding(rounding % 3)
+		m := New(amount, currency, r)
+		if m.Currency != strings.ToUpper(currency) {
+			t.Fatalf("New(%v, %q) has currency %q", amount, currency, m.Currency)
+		}
+		switch {
+		case math.IsNaN(amount):
+			if m.Minor != 0 {
+				t.Fatalf("New(NaN, %q, %s) = %s, want zero", currency, r, m)
+			}
+		// Beyond a trillion units, which is not money, minor units may
+		// outgrow an int64 and saturate, but keep the sign.
+		case math.Abs(amount) > 1e15:
+			if amount > 0 && m.Minor <= 0 || amount < 0 && m.Minor >= 0 {
+				t.Fatalf("New(%v, %q, %s) = %s, of the wrong sign", amount, currency, r, m)
+			}
+		default:
+			unit := math.Pow10(-MinorUnits(currency))
+			if diff := math.Abs(m.Float() - amount); diff > unit*(1+1e-9) {
+				t.Fatalf("New(%v, %q, %s) = %s, %v away", amount, currency, r, m, diff)
+			}
+		}
+	})
+}
+
+// TestRounding checks each rounding mode on ties, amounts either side of
+// them and negative amounts, in currencies of 0, 2 and 3 minor units.
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		// want is the minor units under HalfEven, HalfUp and Floor.
+		want [3]int64
+	}{
+		{2.675, "USD", [3]int64{268, 268, 267}},
+		{2.665, "USD", [3]int64{266, 267, 266}},
+		{-2.665, "USD", [3]int64{-266, -267, -267}},
+		{2.6651, "USD", [3]int64{267, 267, 266}},
+		{2.6649, "USD", [3]int64{266, 266, 266}},
+		{-0.001, "USD", [3]int64{0, 0, -1}},
+		{10, "USD", [3]int64{1000, 1000, 1000}},
+		{0.5, "JPY", [3]int64{0, 1, 0}},
+		{1.5, "jpy", [3]int64{2, 2, 1}},
+		{-1.5, "KRW", [3]int64{-2, -2, -2}},
+		{1234.4, "CLP", [3]int64{1234, 1234, 1234}},
+		{1.0005, "KWD", [3]int64{1000, 1001, 1000}},
+		{1.0015, "BHD", [3]int64{1002, 1002, 1001}},
+		{-1.0015, "JOD", [3]int64{-1002, -1002, -1002}},
+		{0.0004, "KWD", [3]int64{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		for i, r := range []Rounding{HalfEven, HalfUp, Floor} {
+			if m := New(tt.amount, tt.currency, r); m.Minor != tt.want[i] {
+				t.Errorf("New(%v, %s, %s) = %d minor units, want %d", tt.amount, tt.currency, r, m.Minor, tt.want[i])
+			}
+		}
+	}
+
+	for _, f := range []float64{math.Inf(1), 1e300} {
+		if m := New(f, "USD", HalfEven); m.Minor != math.MaxInt64 {
+			t.Errorf("New(%v) = %d minor units, want them saturated", f, m.Minor)
+		}
+	}
+	if m := New(math.Inf(-1), "USD", HalfEven); m.Minor != math.MinInt64 {
+		t.Errorf("New(-Inf) = %d minor units, want them saturated", m.Minor)
+	}
+	if p := Percent(math.NaN()); p != 0 {
+		t.Errorf("Percent(NaN) = %d, want 0", p)
+	}
+	if p := Percent(math.Inf(1)); p != math.MaxInt64 {
+		t.Errorf("Percent(+Inf) = %d, want it saturated", p)
+	}
+	if m := New(10, "USD", HalfEven).Mul(math.NaN(), HalfEven); m.Minor != 0 {
+		t.Errorf("Mul(NaN) = %s, want zero", m)
+	}
+	if m := Percent(5).Of(Money{Minor: 1e17}, HalfEven); m.Minor != 5e15 {
+		t.Errorf("5%% of 1e15 = %d minor units, want 5e15", m.Minor)
+	}
+
+	for name, want := range map[string]Rounding{"bankers": HalfEven, "HALF_UP": HalfUp, " floor ": Floor} {
+		if r, err := ParseRounding(name); err != nil || r != want {
+			t.Errorf("ParseRounding(%q) = %s, %v, want %s", name, r, err, want)
+		}
+	}
+	if _, err := ParseRounding("up"); err == nil {
+		t.Error("ParseRounding accepted up")
+	}
+}
+
+// TestDayCount checks the conventions against worked examples: the days and
+// the interest on 1,000,000 at 5% under each, from the ISDA 2006 definitions
+// and the 30/360 bond basis month-end rules.
+func TestDayCount(t *testing.T) {
+	fixtures := []struct {
+		start, end string
+		d          DayCount
+		days       int
+		interest   string
+	}{
+		{"2007-01-15", "2007-07-15", Actual365, 181, "24794.520548"},
+		{"2007-01-15", "2007-07-15", Actual360, 181, "25138.888889"},
+		{"2007-01-15", "2007-07-15", Thirty360, 180, "25000.000000"},
+		{"2024-01-01", "2025-01-01", Actual365, 366, "50136.986301"},
+		{"2024-01-01", "2025-01-01", Thirty360, 360, "50000.000000"},
+		// The 31st counts as the 30th at the start, but at the end only
+		// when the start is the 30th or 31st.
+		{"2024-01-31", "2024-02-29", Thirty360, 29, "4027.777778"},
+		{"2024-01-30", "2024-03-31", Thirty360, 60, "8333.333333"},
+		{"2023-02-28", "2023-03-31", Thirty360, 33, "4583.333333"},
+		{"2023-02-28", "2023-03-31", Actual360, 31, "4305.555556"},
+		{"2024-03-31", "2024-03-01", Thirty360, -29, "-4027.777778"},
+	}
+	for _, f := range fixtures {
+		start, _ := time.Parse(time.DateOnly, f.start)
+		end, _ := time.Parse(time.DateOnly, f.end)
+		if days := f.d.Days(start, end); days != f.days {
+			t.Errorf("%s from %s to %s: %d days, want %d", f.d, f.start, f.end, days, f.days)
+		}
+		interest := Percent(5).Accrued(big.NewRat(1000000, 1), f.d, start, end)
+		if got := interest.FloatString(6); got != f.interest {
+			t.Errorf("%s from %s to %s: interest %s, want %s", f.d, f.start, f.end, got, f.interest)
+		}
+	}
+
+	for s, want := range map[string]DayCount{"ACT/365": Actual365, "act/360": Actual360, "Actual/365F": Actual365, " 30 / 360 ": Thirty360} {
+		if d, err := ParseDayCount(s); err != nil || d != want {
+			t.Errorf("ParseDayCount(%q) = %q, %v, want %q", s, d, err, want)
+		}
+	}
+	if _, err := ParseDayCount("30E/360"); err == nil {
+		t.Error("ParseDayCount accepted 30E/360")
+	}
+}
+
+// TestAllocate checks splits with and without remainders, of negative and
+// zero amounts, zero ratios among others, and that ratios that cannot split
+// anything panic.
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		minor  int64
+		ratios []int
+		want   []int64
+	}{
+		{10000, []int{1, 1, 1}, []int64{3334, 3333, 3333}},
+		{10000, []int{1, 1}, []int64{5000, 5000}},
+		{5, []int{1, 1, 1}, []int64{2, 2, 1}},
+		{100, []int{70, 20, 10}, []int64{70, 20, 10}},
+		{101, []int{3, 7}, []int64{30, 71}},
+		{2, []int{1, 1, 1}, []int64{1, 1, 0}},
+		{-10000, []int{1, 1, 1}, []int64{-3334, -3333, -3333}},
+		{-101, []int{3, 7}, []int64{-30, -71}},
+		{0, []int{1, 2, 3}, []int64{0, 0, 0}},
+		{100, []int{0, 1, 0}, []int64{0, 100, 0}},
+		{100, []int{1, 0, 2}, []int64{33, 0, 67}},
+		{100, []int{5}, []int64{100}},
+		// Amounts times ratios beyond an int64.
+		{1e17, []int{60000, 5000}, []int64{92307692307692308, 7692307692307692}},
+		{math.MaxInt64, []int{1, 1}, []int64{4611686018427387904, 4611686018427387903}},
+	}
+	for _, tt := range tests {
+		parts := Money{Minor: tt.minor, Currency: "USD"}.Allocate(tt.ratios...)
+		got := make([]int64, len(parts))
+		for i, p := range parts {
+			got[i] = p.Minor
+			if p.Currency != "USD" {
+				t.Errorf("Allocate(%d, %v) part %d in %q", tt.minor, tt.ratios, i, p.Currency)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Allocate(%d, %v) = %v, want %v", tt.minor, tt.ratios, got, tt.want)
+		}
+	}
+
+	for _, ratios := range [][]int{nil, {0, 0}, {1, -1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Allocate(%v) did not panic", ratios)
+				}
+			}()
+			Money{Minor: 100}.Allocate(ratios...)
+		}()
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Allocate of math.MinInt64 did not panic")
+			}
+		}()
+		Money{Minor: math.MinInt64}.Allocate(1, 1)
+	}()
+}
+
+// FuzzAllocate checks that the parts always sum to the amount and that each
+// is within a minor unit of its exact share.
+func FuzzAllocate(f *testing.F) {
+	f.Add(int64(10000), uint16(1), uint16(1), uint16(1))
+	f.Add(int64(-7), uint16(0), uint16(3), uint16(5))
+	f.Add(int64(0), uint16(9), uint16(0), uint16(0))
+	f.Fuzz(func(t *testing.T, minor int64, a, b, c uint16) {
+		if minor == math.MinInt64 || a == 0 && b == 0 && c == 0 {
+			t.Skip()
+		}
+		ratios := []int{int(a), int(b), int(c)}
+		parts := Money{Minor: minor}.Allocate(ratios...)
+		var sum int64
+		total := big.NewRat(int64(a)+int64(b)+int64(c), 1)
+		for i, p := range parts {
+			sum += p.Minor
+			exact := new(big.Rat).Quo(new(big.Rat).Mul(big.NewRat(minor, 1), big.NewRat(int64(ratios[i]), 1)), total)
+			diff, _ := new(big.Rat).Sub(big.NewRat(p.Minor, 1), exact).Float64()
+			if math.Abs(diff) >= 1 {
+				t.Fatalf("Allocate(%d, %v) part %d = %d, %v from its share", minor, ratios, i, p.Minor, diff)
+			}
+		}
+		if sum != minor {
+			t.Fatalf("Allocate(%d, %v) parts sum to %d", minor, ratios, sum)
+		}
+	})
+}
+
+// TestFinance checks the rate conversions, NPV and IRR against reference
+// values: the NPV and IRR examples of spreadsheet documentation and a
+// 12-month loan of 1,000 at 1% a month.
+func TestFinance(t *testing.T) {
+	if r := EffectiveAnnualRate(Percent(12), 12); r != Percent(12.68) {
+		t.Errorf("effective rate of 12%% monthly = %s, want 12.68%%", r)
+	}
+	if r := NominalAnnualRate(Percent(12.68), 12); r != Percent(12) {
+		t.Errorf("nominal rate of 12.68%% monthly = %s, want 12.00%%", r)
+	}
+	if r := EffectiveAnnualRate(Percent(5), 0); r != Percent(5) {
+		t.Errorf("effective rate without periods = %s, want the nominal", r)
+	}
+	if r := APRFromPeriodicRate(0.01, 12); r != Percent(12) {
+		t.Errorf("APR of 1%% a month = %s, want 12.00%%", r)
+	}
+
+	npvs := []struct {
+		rate      float64
+		cashflows []float64
+		want      float64
+	}{
+		{0.1, []float64{-100, 50, 60}, -4.958678},
+		// Spreadsheet NPVs discount the first cashflow a period, so these
+		// start with an empty one.
+		{0.1, []float64{0, -10000, 3000, 4200, 6800}, 1188.443412},
+		{0.08, []float64{-40000, 8000, 9200, 10000, 12000, 14500}, 1922.061555},
+		{0, []float64{-5, 2, 2}, -1},
+	}
+	for _, tt := range npvs {
+		if got := NPV(tt.rate, tt.cashflows); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("NPV(%v, %v) = %.6f, want %.6f", tt.rate, tt.cashflows, got, tt.want)
+		}
+	}
+
+	loan := []float64{1000}
+	for i := 0; i < 12; i++ {
+		loan = append(loan, -88.848789)
+	}
+	irrs := []struct {
+		cashflows []float64
+		want      float64
+	}{
+		{[]float64{-100, 110}, 0.1},
+		{[]float64{-1000, 0, 1210}, 0.1},
+		{[]float64{-70000, 12000, 15000, 18000, 21000, 26000}, 0.086631},
+		{[]float64{-70000, 12000, 15000, 18000, 21000}, -0.021245},
+		{[]float64{-70000, 12000, 15000}, -0.443507},
+		{loan, 0.01},
+	}
+	for _, tt := range irrs {
+		got, err := IRR(tt.cashflows)
+		if err != nil || math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("IRR(%v) = %.6f, %v, want %.6f", tt.cashflows, got, err, tt.want)
+		}
+	}
+
+	// -1 + 5/(1+r) - 6/(1+r)^2 is zero at 100% and at 200%.
+	if r, err := IRR([]float64{-1, 5, -6}); err != nil || math.Abs(r-1) > 1e-6 && math.Abs(r-2) > 1e-6 {
+		t.Errorf("IRR of cashflows with two rates = %v, %v, want 1 or 2", r, err)
+	}
+	// 1 - 3/(1+r) + 3/(1+r)^2 is never zero, nor are cashflows that all
+	// have the same sign.
+	for _, cashflows := range [][]float64{{1, -3, 3}, {100, 10}, {-5, -5}, {0, 0}, nil} {
+		if r, err := IRR(cashflows); !errors.Is(err, ErrNoIRR) {
+			t.Errorf("IRR(%v) = %v, %v, want %v", cashflows, r, err, ErrNoIRR)
+		}
+	}
+}
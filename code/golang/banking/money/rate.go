@@ -0,0 +1,81 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rate is an interest rate or a percentage fee in basis points: 1% is 100.
+// Being an integer, rates compare and add exactly; use Percent for the value
+// in percent and Of to apply a rate to an amount.
+type Rate int64
+
+// Percent100 is a rate of 100%.
+const Percent100 Rate = 10000
+
+// Percent returns the rate whose value is p percent, rounded to a basis
+// point.
+func Percent(p float64) Rate {
+	return Rate(round(scale(decimal(p), 2), HalfEven))
+}
+
+// ParseRate parses a rate written as a percentage ("5%", "-0.25 %", or a bare
+// "5" meaning 5%) or in basis points ("125bps", "125 bp"). Percentages finer
+// than a basis point are rejected.
+func ParseRate(s string) (Rate, error) {
+	text := strings.TrimSpace(s)
+	number, bps := text, false
+	switch {
+	case strings.HasSuffix(number, "bps"):
+		number, bps = strings.TrimSuffix(number, "bps"), true
+	case strings.HasSuffix(number, "bp"):
+		number, bps = strings.TrimSuffix(number, "bp"), true
+	case strings.HasSuffix(number, "%"):
+		number = strings.TrimSuffix(number, "%")
+	}
+	x, ok := new(big.Rat).SetString(strings.TrimSpace(number))
+	if !ok {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	if !bps {
+		scale(x, 2)
+	}
+	if !x.IsInt() || !x.Num().IsInt64() {
+		return 0, fmt.Errorf("invalid rate %q: not a whole number of basis points", s)
+	}
+	return Rate(x.Num().Int64()), nil
+}
+
+// Percent returns the rate in percent.
+func (r Rate) Percent() float64 {
+	return float64(r) / 100
+}
+
+// Of returns r of m, such as the interest m earns at r, rounded to a minor
+// unit.
+func (r Rate) Of(m Money, rounding Rounding) Money {
+	x := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(m.Minor), big.NewInt(int64(r))), big.NewInt(int64(Percent100)))
+	return Money{Minor: round(x, rounding), Currency: m.Currency}
+}
+
+// String formats r as a percentage with no trailing zeros, such as "5%" or
+// "1.25%".
+func (r Rate) String() string {
+	s := new(big.Rat).SetFrac64(int64(r), 100).FloatString(2)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
+
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	rate, err := ParseRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package payroll pays the employees of a business from its account: it keeps
+// the roster, works out pay days from a schedule, withholds taxes and other
+// deductions through pluggable hooks and pays everyone in one transfer batch.
+package payroll
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/calendar"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// Employee is someone on the payroll. Salary is the gross annual salary,
+// paid in equal parts every pay day into Account.
+type Employee struct {
+	ID      string
+	Name    string
+	Account string
+	Salary  float64
+}
+
+// Deduction is an amount withheld from gross pay, such as income tax.
+type Deduction struct {
+	Name   string
+	Amount float64
+}
+
+// Withholding computes what to withhold from one employee's gross pay for a
+// pay day.
+type Withholding interface {
+	Withhold(e Employee, gross float64) []Deduction
+}
+
+// WithholdingFunc adapts a function to a Withholding.
+type WithholdingFunc func(e Employee, gross float64) []Deduction
+
+func (f WithholdingFunc) Withhold(e Employee, gross float64) []Deduction {
+	return f(e, gross)
+}
+
+// FlatTax withholds rate of gross pay under name.
+func FlatTax(name string, rate money.Rate) Withholding {
+	return WithholdingFunc(func(_ Employee, gross float64) []Deduction {
+		return []Deduction{{Name: name, Amount: rate.Of(money.New(gross, "", money.HalfEven), money.HalfEven).Float()}}
+	})
+}
+
+var (
+	ErrEmployeeNotFound = banking.New(banking.CodeNotFound, "employee not found")
+	ErrEmployeeExists   = banking.New(banking.CodeConflict, "employee already on the payroll")
+)
+
+// Payroll pays a roster from Employer every Cadence, starting on FirstPayDay.
+// A pay day that is not a business day moves to the business day before it.
+// Withheld amounts go to TaxAccount, or stay with the employer when it is
+// empty.
+type Payroll struct {
+	Employer     string
+	TaxAccount   string
+	Cadence      models.Cadence
+	FirstPayDay  time.Time
+	Withholdings []Withholding
+
+	mu      sync.Mutex
+	roster  map[string]Employee
+	lastRun time.Time
+}
+
+func New(employer string, cadence models.Cadence, firstPayDay time.Time) *Payroll {
+	return &Payroll{
+		Employer:    employer,
+		Cadence:     cadence,
+		FirstPayDay: calendar.StartOfDay(firstPayDay),
+		roster:      make(map[string]Employee),
+	}
+}
+
+func (p *Payroll) Hire(e Employee) error {
+	if e.Salary <= 0 {
+		return banking.New(banking.CodeInvalidArgument, "salary must be positive")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.roster[e.ID]; ok {
+		return ErrEmployeeExists
+	}
+	p.roster[e.ID] = e
+	return nil
+}
+
+func (p *Payroll) Terminate(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.roster[id]; !ok {
+		return ErrEmployeeNotFound
+	}
+	delete(p.roster, id)
+	return nil
+}
+
+// Roster returns the employees ordered by ID.
+func (p *Payroll) Roster() []Employee {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	roster := make([]Employee, 0, len(p.roster))
+	for _, e := range p.roster {
+		roster = append(roster, e)
+	}
+	sort.Slice(roster, func(i, j int) bool { return roster[i].ID < roster[j].ID })
+	return roster
+}
+
+// PayDays returns the pay days after from up to and including until, moved
+// to business days with cal.
+func (p *Payroll) PayDays(cal *calendar.Calendar, from, until time.Time) []time.Time {
+	var days []time.Time
+	for n := 0; ; n++ {
+		payDay := cal.PreviousBusinessDay(p.Cadence.Add(p.FirstPayDay, n))
+		if payDay.After(until) {
+			return days
+		}
+		if payDay.After(from) {
+			days = append(days, payDay)
+		}
+	}
+}
+
+// Payslip is what one employee was paid on a pay day. Err is set when the
+// payment failed.
+type Payslip struct {
+	Employee   Employee
+	PayDay     time.Time
+	Gross      float64
+	Deductions []Deduction
+	Net        float64
+	Err        error
+}
+
+// PayRun is the result of paying the roster on one pay day.
+type PayRun struct {
+	PayDay   time.Time
+	Payslips []Payslip
+}
+
+// Failed returns the payslips whose payment failed.
+func (r PayRun) Failed() []Payslip {
+	var failed []Payslip
+	for _, slip := range r.Payslips {
+		if slip.Err != nil {
+			failed = append(failed, slip)
+		}
+	}
+	return failed
+}
+
+// Run pays every employee for payDay in one batch; opts are passed to
+// Bank.TransferBatch, so a pay run can be all-or-nothing or a dry run. The
+// deposit of each employee carries the payslip as transaction metadata.
+func (p *Payroll) Run(bank *models.Bank, payDay time.Time, opts ...models.BatchOption) PayRun {
+	run := PayRun{PayDay: payDay}
+	var requests []models.TransferRequest
+	var owner []int // index of the payslip each request belongs to
+	for _, e := range p.Roster() {
+		slip := p.payslip(e, payDay)
+		run.Payslips = append(run.Payslips, slip)
+		i := len(run.Payslips) - 1
+
+		requests = append(requests, models.TransferRequest{
+			From: p.Employer, To: e.Account, Amount: slip.Net, Category: "payroll", Metadata: slip.metadata(),
+		})
+		owner = append(owner, i)
+		if p.TaxAccount == "" {
+			continue
+		}
+		for _, d := range slip.Deductions {
+			requests = append(requests, models.TransferRequest{
+				From: p.Employer, To: p.TaxAccount, Amount: d.Amount, Category: "payroll",
+				Metadata: map[string]string{"payslip.employee": e.ID, "payslip.deduction": d.Name},
+			})
+			owner = append(owner, i)
+		}
+	}
+
+	for i, result := range bank.TransferBatch(requests, opts...) {
+		if slip := &run.Payslips[owner[i]]; slip.Err == nil {
+			slip.Err = result.Err
+		}
+	}
+	return run
+}
+
+// RunDue runs every pay day since the last run up to now, oldest first.
+// Failed payments are reported in the returned runs and not retried.
+func (p *Payroll) RunDue(bank *models.Bank, now time.Time, opts ...models.BatchOption) []PayRun {
+	p.mu.Lock()
+	last := p.lastRun
+	p.mu.Unlock()
+
+	var runs []PayRun
+	for _, payDay := range p.PayDays(bank.Calendar, last, now) {
+		runs = append(runs, p.Run(bank, payDay, opts...))
+		p.mu.Lock()
+		p.lastRun = payDay
+		p.mu.Unlock()
+	}
+	return runs
+}
+
+func (p *Payroll) payslip(e Employee, payDay time.Time) Payslip {
+	gross := money.Round(e.Salary/float64(p.Cadence.PerYear()), "", money.HalfEven)
+	slip := Payslip{Employee: e, PayDay: payDay, Gross: gross, Net: gross}
+	for _, w := range p.Withholdings {
+		for _, d := range w.Withhold(e, gross) {
+			slip.Deductions = append(slip.Deductions, d)
+			slip.Net -= d.Amount
+		}
+	}
+	slip.Net = money.Round(slip.Net, "", money.HalfEven)
+	return slip
+}
+
+func (s Payslip) metadata() map[string]string {
+	deductions := make([]string, len(s.Deductions))
+	for i, d := range s.Deductions {
+		deductions[i] = fmt.Sprintf("%s=%.2f", d.Name, d.Amount)
+	}
+	return map[string]string{
+		"payslip.employee":   s.Employee.ID,
+		"payslip.pay_day":    s.PayDay.Format(time.DateOnly),
+		"payslip.gross":      fmt.Sprintf("%.2f", s.Gross),
+		"payslip.deductions": strings.Join(deductions, ", "),
+		"payslip.net":        fmt.Sprintf("%.2f", s.Net),
+	}
+}
@@ -0,0 +1,118 @@
+package payroll
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+var payDay = time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC)
+
+// testPayroll returns a bank whose employer E holds funds, with the accounts
+// A and B of two employees and the tax account T, and a monthly payroll of
+// both that withholds 20% of gross pay.
+func testPayroll(t *testing.T, funds float64) (*models.Bank, *Payroll) {
+	t.Helper()
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	for _, number := range []string{"E", "A", "B", "T"} {
+		if err := bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bank.Deposit("E", funds); err != nil {
+		t.Fatal(err)
+	}
+	p := New("E", models.CadenceMonthly, payDay)
+	p.TaxAccount = "T"
+	p.Withholdings = []Withholding{FlatTax("income tax", money.Percent(20))}
+	for _, e := range []Employee{{ID: "e1", Account: "A", Salary: 60_000}, {ID: "e2", Account: "B", Salary: 24_000}} {
+		if err := p.Hire(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bank, p
+}
+
+func checkBalances(t *testing.T, bank *models.Bank, want map[string]float64) {
+	t.Helper()
+	for number, balance := range want {
+		if got, _ := bank.Balance(number); got != balance {
+			t.Errorf("%s balance = %.2f, want %.2f", number, got, balance)
+		}
+	}
+}
+
+// TestRun checks that each employee is paid their net pay, that what is
+// withheld goes to the tax account, and that the deposit carries the
+// payslip.
+func TestRun(t *testing.T) {
+	bank, p := testPayroll(t, 10_000)
+	run := p.Run(bank, payDay)
+	if failed := run.Failed(); len(failed) != 0 {
+		t.Fatalf("failed payslips: %+v", failed)
+	}
+	want := []struct{ gross, tax, net float64 }{{5000, 1000, 4000}, {2000, 400, 1600}}
+	for i, slip := range run.Payslips {
+		if slip.Gross != want[i].gross || slip.Net != want[i].net || len(slip.Deductions) != 1 || slip.Deductions[0].Amount != want[i].tax {
+			t.Errorf("payslip of %s = %+v, want gross %.2f, tax %.2f, net %.2f", slip.Employee.ID, slip, want[i].gross, want[i].tax, want[i].net)
+		}
+	}
+	checkBalances(t, bank, map[string]float64{"E": 3000, "A": 4000, "B": 1600, "T": 1400})
+
+	history, _ := bank.History("A")
+	metadata := history[len(history)-1].Metadata
+	if metadata["payslip.gross"] != "5000.00" || metadata["payslip.deductions"] != "income tax=1000.00" || metadata["payslip.net"] != "4000.00" {
+		t.Errorf("deposit metadata = %v", metadata)
+	}
+}
+
+// TestRunWithoutTaxAccount checks that withheld amounts stay with the
+// employer when there is no tax account.
+func TestRunWithoutTaxAccount(t *testing.T) {
+	bank, p := testPayroll(t, 10_000)
+	p.TaxAccount = ""
+	p.Run(bank, payDay)
+	checkBalances(t, bank, map[string]float64{"E": 4400, "A": 4000, "B": 1600, "T": 0})
+}
+
+// TestRunFailingPartway checks that an employer who can only pay the first
+// employee pays them and reports the second as failed, and that an
+// all-or-nothing run pays no one.
+func TestRunFailingPartway(t *testing.T) {
+	bank, p := testPayroll(t, 5000)
+	run := p.Run(bank, payDay)
+	failed := run.Failed()
+	if len(failed) != 1 || failed[0].Employee.ID != "e2" || !errors.Is(failed[0].Err, models.ErrInsufficientFunds) {
+		t.Fatalf("failed payslips = %+v, want e2 without funds", failed)
+	}
+	checkBalances(t, bank, map[string]float64{"E": 0, "A": 4000, "B": 0, "T": 1000})
+
+	bank, p = testPayroll(t, 5000)
+	run = p.Run(bank, payDay, models.AllOrNothing())
+	if failed := run.Failed(); len(failed) != 2 {
+		t.Fatalf("all-or-nothing run failed %d payslips, want 2", len(failed))
+	}
+	checkBalances(t, bank, map[string]float64{"E": 5000, "A": 0, "B": 0, "T": 0})
+}
+
+// TestRunDue checks that RunDue pays each pay day once, moving those on a
+// weekend to the Friday before, and does not retry a failed run.
+func TestRunDue(t *testing.T) {
+	bank, p := testPayroll(t, 12_000)
+	// February 28 2026 is a Saturday.
+	runs := p.RunDue(bank, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	if len(runs) != 2 || !runs[1].PayDay.Equal(time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("runs = %+v, want January 30 and February 27", runs)
+	}
+	if len(runs[0].Failed()) != 0 || len(runs[1].Failed()) != 1 {
+		t.Errorf("failed payslips = %d and %d, want 0 and 1", len(runs[0].Failed()), len(runs[1].Failed()))
+	}
+	if runs := p.RunDue(bank, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)); len(runs) != 0 {
+		t.Errorf("second RunDue made %d runs, want none", len(runs))
+	}
+}
@@ -0,0 +1,421 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The expression language of rules:
+//
+//	amount > 5_000 && kind == "withdrawal"   numbers, "strings", true and false
+//	withdrawn_today + amount > 10000         + - * / on numbers
+//	country in ["KP", "IR"]                  in tests membership of a list
+//	!(product == "premium") || hour < 6      ! && || and parentheses
+//
+// Comparisons take two numbers or two strings, == and != two booleans too.
+// Expressions are type checked when compiled, so evaluating one cannot fail.
+
+type valueType int
+
+const (
+	typeNumber valueType = iota
+	typeString
+	typeBool
+	typeList
+)
+
+func (t valueType) String() string {
+	return [...]string{"number", "string", "boolean", "list"}[t]
+}
+
+// value is the result of an expression: a float64, string, bool or []value.
+type value any
+
+// node is a compiled expression.
+type node struct {
+	typ  valueType
+	eval func(env map[string]value) value
+}
+
+type token struct {
+	kind string // "num", "str", "ident", "op" or "eof"
+	text string
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || s[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{"num", s[i:j], i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("at %d: unterminated string", i)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("at %d: %v", i, err)
+			}
+			tokens = append(tokens, token{"str", text, i})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{"ident", s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", "[", "]", ","} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("at %d: unexpected %q", i, c)
+			}
+			tokens = append(tokens, token{"op", op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{"eof", "", len(s)}), nil
+}
+
+// parser compiles tokens into nodes, checking the types of variables
+// against vars.
+type parser struct {
+	tokens []token
+	vars   map[string]valueType
+}
+
+// compile parses an expression of type want.
+func compile(s string, vars map[string]valueType, want valueType) (node, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return node{}, err
+	}
+	p := &parser{tokens: tokens, vars: vars}
+	n, err := p.or()
+	if err != nil {
+		return node{}, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return node{}, fmt.Errorf("at %d: unexpected %q", t.pos, t.text)
+	}
+	if n.typ != want {
+		return node{}, fmt.Errorf("is a %s, want a %s", n.typ, want)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[0] }
+
+func (p *parser) next() token {
+	t := p.tokens[0]
+	if t.kind != "eof" {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == "op" && t.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("at %d: want %q", t.pos, op)
+	}
+	return nil
+}
+
+func typed(t token, n node, want valueType) error {
+	if n.typ != want {
+		return fmt.Errorf("at %d: %s wants a %s, got a %s", t.pos, t.text, want, n.typ)
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.peek().text == "||" {
+		var right node
+		op := p.next()
+		if right, err = p.and(); err == nil {
+			if err = typed(op, left, typeBool); err == nil {
+				err = typed(op, right, typeBool)
+			}
+			l, r := left, right
+			left = node{typeBool, func(env map[string]value) value { return l.eval(env).(bool) || r.eval(env).(bool) }}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	for err == nil && p.peek().text == "&&" {
+		var right node
+		op := p.next()
+		if right, err = p.not(); err == nil {
+			if err = typed(op, left, typeBool); err == nil {
+				err = typed(op, right, typeBool)
+			}
+			l, r := left, right
+			left = node{typeBool, func(env map[string]value) value { return l.eval(env).(bool) && r.eval(env).(bool) }}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) not() (node, error) {
+	if t := p.peek(); t.kind == "op" && t.text == "!" {
+		p.next()
+		n, err := p.not()
+		if err == nil {
+			err = typed(t, n, typeBool)
+		}
+		return node{typeBool, func(env map[string]value) value { return !n.eval(env).(bool) }}, err
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return left, err
+	}
+	t := p.peek()
+	if t.kind == "ident" && t.text == "in" {
+		p.next()
+		right, err := p.sum()
+		if err != nil {
+			return right, err
+		}
+		if right.typ != typeList {
+			return node{}, fmt.Errorf("at %d: in wants a list, got a %s", t.pos, right.typ)
+		}
+		// Lists are literals, so their items are known now.
+		for _, item := range right.eval(nil).([]value) {
+			if typeOf(item) != left.typ {
+				return node{}, fmt.Errorf("at %d: cannot look for a %s in a list of %ss", t.pos, left.typ, typeOf(item))
+			}
+		}
+		return node{typeBool, func(env map[string]value) value {
+			v := left.eval(env)
+			for _, item := range right.eval(env).([]value) {
+				if item == v {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	}
+	if t.kind != "op" || !strings.Contains(" == != < <= > >= ", " "+t.text+" ") {
+		return left, nil
+	}
+	p.next()
+	right, err := p.sum()
+	if err != nil {
+		return right, err
+	}
+	if left.typ != right.typ || left.typ == typeList || left.typ == typeBool && t.text != "==" && t.text != "!=" {
+		return node{}, fmt.Errorf("at %d: cannot compare a %s %s a %s", t.pos, left.typ, t.text, right.typ)
+	}
+	compare := func(env map[string]value) int {
+		switch l, r := left.eval(env), right.eval(env); l := l.(type) {
+		case float64:
+			return cmp(l, r.(float64))
+		case string:
+			return cmp(l, r.(string))
+		default:
+			if l == r {
+				return 0
+			}
+			return 1
+		}
+	}
+	var test func(int) bool
+	switch t.text {
+	case "==":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	default:
+		test = func(c int) bool { return c >= 0 }
+	}
+	return node{typeBool, func(env map[string]value) value { return test(compare(env)) }}, nil
+}
+
+func cmp[T float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arithmetic(p.product, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.arithmetic(p.unary, "*", "/")
+}
+
+// arithmetic parses operands joined by ops, left to right.
+func (p *parser) arithmetic(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	for err == nil {
+		t := p.peek()
+		if t.kind != "op" || t.text != ops[0] && t.text != ops[1] {
+			break
+		}
+		p.next()
+		var right node
+		if right, err = operand(); err != nil {
+			break
+		}
+		if err = typed(t, left, typeNumber); err == nil {
+			err = typed(t, right, typeNumber)
+		}
+		l, r, op := left, right, t.text
+		left = node{typeNumber, func(env map[string]value) value {
+			a, b := l.eval(env).(float64), r.eval(env).(float64)
+			switch op {
+			case "+":
+				return a + b
+			case "-":
+				return a - b
+			case "*":
+				return a * b
+			}
+			return a / b
+		}}
+	}
+	return left, err
+}
+
+func (p *parser) unary() (node, error) {
+	if t := p.peek(); t.kind == "op" && t.text == "-" {
+		p.next()
+		n, err := p.unary()
+		if err == nil {
+			err = typed(t, n, typeNumber)
+		}
+		return node{typeNumber, func(env map[string]value) value { return -n.eval(env).(float64) }}, err
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case "num":
+		f, err := strconv.ParseFloat(strings.ReplaceAll(t.text, "_", ""), 64)
+		if err != nil {
+			return node{}, fmt.Errorf("at %d: %q is not a number", t.pos, t.text)
+		}
+		return constant(typeNumber, f), nil
+	case "str":
+		return constant(typeString, t.text), nil
+	case "ident":
+		switch t.text {
+		case "true", "false":
+			return constant(typeBool, t.text == "true"), nil
+		}
+		typ, ok := p.vars[t.text]
+		if !ok {
+			return node{}, fmt.Errorf("at %d: unknown variable %q", t.pos, t.text)
+		}
+		name := t.text
+		return node{typ, func(env map[string]value) value { return env[name] }}, nil
+	case "op":
+		switch t.text {
+		case "(":
+			n, err := p.or()
+			if err == nil {
+				err = p.expect(")")
+			}
+			return n, err
+		case "[":
+			return p.list(t)
+		}
+	}
+	if t.kind == "eof" {
+		return node{}, fmt.Errorf("at %d: unexpected end", t.pos)
+	}
+	return node{}, fmt.Errorf("at %d: unexpected %q", t.pos, t.text)
+}
+
+// list parses the items of a list literal, which are constants of one type.
+func (p *parser) list(open token) (node, error) {
+	var items []value
+	var typ valueType
+	for !p.accept("]") {
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return node{}, err
+			}
+		}
+		t := p.peek()
+		item, err := p.primary()
+		if err != nil {
+			return node{}, err
+		}
+		if t.kind != "num" && t.kind != "str" {
+			return node{}, fmt.Errorf("at %d: list items must be numbers or strings", t.pos)
+		}
+		if len(items) > 0 && item.typ != typ {
+			return node{}, fmt.Errorf("at %d: list mixes %ss and %ss", t.pos, typ, item.typ)
+		}
+		typ = item.typ
+		items = append(items, item.eval(nil))
+	}
+	return constant(typeList, items), nil
+}
+
+// typeOf returns the type of a value of a list.
+func typeOf(v value) valueType {
+	if _, ok := v.(string); ok {
+		return typeString
+	}
+	return typeNumber
+}
+
+func constant(typ valueType, v value) node {
+	return node{typ, func(map[string]value) value { return v }}
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+// testVars are the variables of the expressions under test.
+var testVars = map[string]valueType{"amount": typeNumber, "kind": typeString, "vip": typeBool}
+
+func testEnv() map[string]value {
+	return map[string]value{"amount": 1500.0, "kind": "withdrawal", "vip": false}
+}
+
+// TestExpressions evaluates expressions of every operator, checking
+// precedence, associativity and literals.
+func TestExpressions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want value
+		typ  valueType
+	}{
+		{`amount > 1000 && kind == "withdrawal"`, true, typeBool},
+		{`amount > 1000 && kind == "deposit"`, false, typeBool},
+		{`amount < 10 || kind != "deposit"`, true, typeBool},
+		{`!vip && amount >= 1500`, true, typeBool},
+		{`!(amount <= 1500)`, false, typeBool},
+		{`vip == false`, true, typeBool},
+		{`true || false && false`, true, typeBool},
+		{`(true || false) && false`, false, typeBool},
+		{`1 + 2 * 3`, 7.0, typeNumber},
+		{`(1 + 2) * 3`, 9.0, typeNumber},
+		{`10 - 4 - 3`, 3.0, typeNumber},
+		{`24 / 4 / 2`, 3.0, typeNumber},
+		{`-amount + --5`, -1495.0, typeNumber},
+		{`1_000.5 * 2`, 2001.0, typeNumber},
+		{`.5 + 1.`, 1.5, typeNumber},
+		{`kind in ["deposit", "withdrawal"]`, true, typeBool},
+		{`amount in [1, 2, 3]`, false, typeBool},
+		{`amount in []`, false, typeBool},
+		{`"b" > "a" && "ab" < "b"`, true, typeBool},
+		{`"tab\t\"q\"" == "tab\t\"q\""`, true, typeBool},
+		{`kind`, "withdrawal", typeString},
+		{"\tamount\n>\r1", true, typeBool},
+	}
+	for _, tt := range tests {
+		n, err := compile(tt.expr, testVars, tt.typ)
+		if err != nil {
+			t.Errorf("compile(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := n.eval(testEnv()); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestExpressionErrors checks that malformed and mistyped expressions are
+// refused at compile time with the byte offset of the culprit.
+func TestExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{``, "at 0: unexpected end"},
+		{`amount >`, "at 8: unexpected end"},
+		{`amount > 5 5`, `at 11: unexpected "5"`},
+		{`amount # 5`, `at 7: unexpected '#'`},
+		{`kind == "withdrawal`, "at 8: unterminated string"},
+		{`kind == "\q"`, "at 8: invalid syntax"},
+		{`1.2.3 > 0`, `at 0: "1.2.3" is not a number`},
+		{`balance > 0`, `at 0: unknown variable "balance"`},
+		{`(amount > 0`, `at 11: want ")"`},
+		{`amount + "x" > 0`, "at 7: + wants a number, got a string"},
+		{`-kind == "x"`, "at 0: - wants a number, got a string"},
+		{`!amount`, "at 0: ! wants a boolean, got a number"},
+		{`amount && vip`, "at 7: && wants a boolean, got a number"},
+		{`vip || kind`, "at 4: || wants a boolean, got a string"},
+		{`amount == "1500"`, "at 7: cannot compare a number == a string"},
+		{`vip < true`, "at 4: cannot compare a boolean < a boolean"},
+		{`[1] == [1]`, "at 4: cannot compare a list == a list"},
+		{`kind in "withdrawal"`, "at 5: in wants a list, got a string"},
+		{`kind in [1, 2]`, "at 5: cannot look for a string in a list of numbers"},
+		{`amount in [1, "2"]`, "at 14: list mixes numbers and strings"},
+		{`amount in [vip]`, "at 11: list items must be numbers or strings"},
+		{`amount in [1 2]`, `at 13: want ","`},
+		{`amount in [1,`, "at 13: unexpected end"},
+		{`amount + 1`, "is a number, want a boolean"},
+		{`)`, `at 0: unexpected ")"`},
+	}
+	for _, tt := range tests {
+		_, err := compile(tt.expr, testVars, typeBool)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("compile(%q) = %v, want an error containing %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+// FuzzCompile checks that no expression makes the lexer or parser panic,
+// and that an expression that compiles evaluates without one.
+func FuzzCompile(f *testing.F) {
+	for _, s := range []string{
+		`amount > 5000 && kind == "withdrawal"`, `kind in ["a", "b"]`, `!(vip) || -amount * 2 / 0 < 1`,
+		`((((amount))))`, `"é" == kind`, `[`, `"`, `1_0 > .`, "ni\xc3\xb1o", `amount in [] == true`,
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if n, err := compile(s, testVars, typeBool); err == nil {
+			n.eval(testEnv())
+		}
+		for _, typ := range []valueType{typeNumber, typeString} {
+			if n, err := compile(s, testVars, typ); err == nil {
+				n.eval(testEnv())
+			}
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Package policy evaluates limits and rules written in configuration, such
+// as a daily maximum, a per-transaction maximum or countries money may not
+// go to, against every deposit, withdrawal and transfer. Rules are
+// expressions over the operation, so changing a policy needs no code
+// change, and a running bank can swap its policy for a new one.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var ErrDenied = banking.New(banking.CodeLimitExceeded, "denied by policy")
+
+// Rule denies the operations When is true for, with Message as the reason.
+type Rule struct {
+	Name    string
+	When    string
+	Message string
+}
+
+// variables are what a rule's expression can use, with their types:
+//
+//	kind             string  deposit, withdrawal or transfer
+//	amount           number  amount of the operation
+//	account          string  account deposited to, withdrawn or transferred from
+//	to               string  account transferred to, "" otherwise
+//	product          string  product code of account, "" for none
+//	balance          number  balance of account before the operation
+//	withdrawn_today  number  withdrawn from account today, transfers out included
+//	category         string  category of the operation
+//	counterparty     string  counterparty of the operation
+//	country          string  the "country" metadata of the operation, such as
+//	                         the country of a card merchant
+//	hour             number  hour of the day on the bank's clock, 0 to 23
+var variables = map[string]valueType{
+	"kind": typeString, "amount": typeNumber, "account": typeString, "to": typeString,
+	"product": typeString, "balance": typeNumber, "withdrawn_today": typeNumber,
+	"category": typeString, "counterparty": typeString, "country": typeString, "hour": typeNumber,
+}
+
+type compiledRule struct {
+	Rule
+	when node
+}
+
+// Policy is a compiled list of rules.
+type Policy struct {
+	rules []compiledRule
+}
+
+// Compile checks and compiles rules, reporting every rule that is invalid.
+func Compile(rules []Rule) (*Policy, error) {
+	p := &Policy{}
+	var errs []error
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		when, err := compile(r.When, variables, typeBool)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: when %v", name, err))
+			continue
+		}
+		r.Name = name
+		if r.Message == "" {
+			r.Message = "denied by rule " + name
+		}
+		p.rules = append(p.rules, compiledRule{r, when})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// check returns ErrDenied, with the rule's name and message, for the first
+// rule that denies an operation with the variables of env, which holds
+// every one of them.
+func (p *Policy) check(env map[string]value) error {
+	for _, r := range p.rules {
+		if r.when.eval(env).(bool) {
+			return fmt.Errorf("%w: %s: %s", ErrDenied, r.Name, r.Message)
+		}
+	}
+	return nil
+}
+
+// Enforcer applies a policy to the operations of a bank.
+type Enforcer struct {
+	bank   *models.Bank
+	policy atomic.Pointer[Policy]
+}
+
+// NewEnforcer returns an Enforcer of p for bank. Add its Middleware to the
+// bank with Bank.Use.
+func NewEnforcer(bank *models.Bank, p *Policy) *Enforcer {
+	e := &Enforcer{bank: bank}
+	e.policy.Store(p)
+	return e
+}
+
+// Set replaces the policy, for the operations that start after it.
+func (e *Enforcer) Set(p *Policy) {
+	e.policy.Store(p)
+}
+
+// Middleware denies the operations the policy denies.
+func (e *Enforcer) Middleware() models.Middleware {
+	return models.Check(func(op models.Op) error {
+		return e.policy.Load().check(e.env(op))
+	})
+}
+
+// env returns the variables of a rule for op.
+func (e *Enforcer) env(op models.Op) map[string]value {
+	var tx models.Transaction
+	for _, opt := range op.Options {
+		opt(&tx)
+	}
+	now := e.bank.Clock.Now()
+	env := map[string]value{
+		"kind": string(op.Kind), "amount": op.Amount, "account": op.Account, "to": op.To,
+		"product": "", "balance": 0.0, "withdrawn_today": 0.0,
+		"category": tx.Category, "counterparty": tx.Counterparty, "country": tx.Metadata["country"],
+		"hour": float64(now.Hour()),
+	}
+	if p, ok := e.bank.ProductOf(op.Account); ok {
+		env["product"] = p.Code
+	}
+	if balance, err := e.bank.Balance(op.Account); err == nil {
+		env["balance"] = balance
+	}
+	history, _ := e.bank.History(op.Account)
+	today := now.Format(time.DateOnly)
+	withdrawn := 0.0
+	for _, t := range history {
+		if t.Type == models.TransactionWithdrawal && t.Time.In(now.Location()).Format(time.DateOnly) == today {
+			withdrawn += t.Amount
+		}
+	}
+	env["withdrawn_today"] = withdrawn
+	return env
+}
@@ -0,0 +1,157 @@
+// Package projection answers "how much will I have?": it simulates the
+// growth of a savings balance many times over under random interest rates
+// and deposits, and reports the spread of outcomes year by year.
+package projection
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+// Assumptions describe the saver and the rate environment. Rates compound
+// monthly. The rate drifts as a random walk pulled back towards Rate, moving
+// by RateVolatility a year on average, and never goes below zero. Each month
+// MonthlyDeposit is paid in, varied by DepositVolatility (a fraction of the
+// deposit) and missed altogether with probability SkipProbability.
+type Assumptions struct {
+	Balance           float64
+	Years             int
+	Rate              money.Rate
+	RateVolatility    money.Rate
+	MonthlyDeposit    float64
+	DepositVolatility float64
+	SkipProbability   float64
+	// Runs is the number of simulated paths, DefaultRuns when zero. Seed
+	// makes the projection reproducible.
+	Runs int
+	Seed uint64
+}
+
+const (
+	DefaultRuns = 2000
+	MaxRuns     = 100000
+	MaxYears    = 100
+	// meanReversion is how strongly, per year, the rate is pulled back
+	// towards its long-run level.
+	meanReversion = 0.5
+)
+
+var ErrInvalidAssumptions = banking.New(banking.CodeInvalidArgument, "invalid projection assumptions")
+
+// Percentiles are the ones reported in every Band.
+var Percentiles = []int{10, 25, 50, 75, 90}
+
+// Band is the spread of simulated balances at the end of a year: Values
+// holds one balance per entry of Percentiles. Deposited is the median of
+// what the saver paid in by then.
+type Band struct {
+	Year      int
+	Values    []float64
+	Deposited float64
+}
+
+// Percentile returns the value of the band for p, which must be one of
+// Percentiles.
+func (b Band) Percentile(p int) float64 {
+	for i, q := range Percentiles {
+		if q == p {
+			return b.Values[i]
+		}
+	}
+	panic(fmt.Sprintf("projection: percentile %d is not reported", p))
+}
+
+// Project runs the simulation. Year 0 is the starting balance; then there is
+// one band per year.
+func Project(a Assumptions) ([]Band, error) {
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	runs := a.Runs
+	if runs == 0 {
+		runs = DefaultRuns
+	}
+	rng := rand.New(rand.NewPCG(a.Seed, a.Seed^0x9e3779b97f4a7c15))
+
+	mean := a.Rate.Percent() / 100
+	vol := a.RateVolatility.Percent() / 100 / math.Sqrt(12)
+	// balances[y][run] is the balance of a run at the end of year y.
+	balances := make([][]float64, a.Years+1)
+	deposited := make([][]float64, a.Years+1)
+	for y := range balances {
+		balances[y] = make([]float64, runs)
+		deposited[y] = make([]float64, runs)
+	}
+	for run := 0; run < runs; run++ {
+		balance, rate, paid := a.Balance, mean, 0.0
+		balances[0][run] = balance
+		for y := 1; y <= a.Years; y++ {
+			for m := 0; m < 12; m++ {
+				rate += meanReversion*(mean-rate)/12 + vol*rng.NormFloat64()
+				rate = math.Max(rate, 0)
+				balance += balance * rate / 12
+				if a.MonthlyDeposit > 0 && rng.Float64() >= a.SkipProbability {
+					deposit := math.Max(a.MonthlyDeposit*(1+a.DepositVolatility*rng.NormFloat64()), 0)
+					balance += deposit
+					paid += deposit
+				}
+			}
+			balances[y][run] = balance
+			deposited[y][run] = paid
+		}
+	}
+
+	bands := make([]Band, a.Years+1)
+	for y := range bands {
+		sort.Float64s(balances[y])
+		sort.Float64s(deposited[y])
+		band := Band{Year: y, Deposited: money.Round(percentile(deposited[y], 50), "", money.HalfEven)}
+		for _, p := range Percentiles {
+			band.Values = append(band.Values, money.Round(percentile(balances[y], p), "", money.HalfEven))
+		}
+		bands[y] = band
+	}
+	return bands, nil
+}
+
+func (a Assumptions) validate() error {
+	var problem string
+	switch {
+	case a.Balance < 0:
+		problem = "balance must not be negative"
+	case a.Years < 1 || a.Years > MaxYears:
+		problem = fmt.Sprintf("years must be between 1 and %d", MaxYears)
+	case a.Runs < 0 || a.Runs > MaxRuns:
+		problem = fmt.Sprintf("runs must be between 1 and %d", MaxRuns)
+	case a.RateVolatility < 0:
+		problem = "rate volatility must not be negative"
+	case a.MonthlyDeposit < 0:
+		problem = "monthly deposit must not be negative"
+	case a.DepositVolatility < 0:
+		problem = "deposit volatility must not be negative"
+	case a.SkipProbability < 0 || a.SkipProbability > 1:
+		problem = "skip probability must be between 0 and 1"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidAssumptions, problem)
+}
+
+// percentile interpolates linearly between the closest ranks of sorted.
+func percentile(sorted []float64, p int) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := float64(p) / 100 * float64(len(sorted)-1)
+	lo := int(rank)
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
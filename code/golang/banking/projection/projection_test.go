@@ -0,0 +1,131 @@
+package projection
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"gsolano/banking/money"
+)
+
+// TestValidate checks that each assumption out of range is refused.
+func TestValidate(t *testing.T) {
+	valid := Assumptions{Balance: 1000, Years: 10, Rate: money.Percent(3), Runs: 10}
+	tests := []struct {
+		name   string
+		change func(*Assumptions)
+		ok     bool
+	}{
+		{"valid", func(a *Assumptions) {}, true},
+		{"default runs", func(a *Assumptions) { a.Runs = 0 }, true},
+		{"max years", func(a *Assumptions) { a.Years = MaxYears }, true},
+		{"negative balance", func(a *Assumptions) { a.Balance = -1 }, false},
+		{"no years", func(a *Assumptions) { a.Years = 0 }, false},
+		{"too many years", func(a *Assumptions) { a.Years = MaxYears + 1 }, false},
+		{"negative runs", func(a *Assumptions) { a.Runs = -1 }, false},
+		{"too many runs", func(a *Assumptions) { a.Runs = MaxRuns + 1 }, false},
+		{"negative volatility", func(a *Assumptions) { a.RateVolatility = money.Percent(-1) }, false},
+		{"negative deposit", func(a *Assumptions) { a.MonthlyDeposit = -50 }, false},
+		{"negative deposit volatility", func(a *Assumptions) { a.DepositVolatility = -0.1 }, false},
+		{"skip above 1", func(a *Assumptions) { a.SkipProbability = 1.5 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			tt.change(&a)
+			_, err := Project(a)
+			if ok := err == nil; ok != tt.ok {
+				t.Errorf("Project() = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidAssumptions) {
+				t.Errorf("error %v is not ErrInvalidAssumptions", err)
+			}
+		})
+	}
+}
+
+// TestProjectCertain checks that without randomness every percentile is the
+// balance compounded monthly, plus the deposits when they are never missed.
+func TestProjectCertain(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         Assumptions
+		year1     float64
+		deposited float64
+	}{
+		{"interest only", Assumptions{Balance: 1000, Years: 2, Rate: money.Percent(5), Runs: 5}, 1051.16, 0},
+		{"no interest", Assumptions{Balance: 1000, Years: 2, MonthlyDeposit: 100, Runs: 5}, 2200, 1200},
+		{"deposits always missed", Assumptions{Balance: 1000, Years: 2, MonthlyDeposit: 100, SkipProbability: 1, Runs: 5}, 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bands, err := Project(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bands) != tt.a.Years+1 {
+				t.Fatalf("%d bands, want %d", len(bands), tt.a.Years+1)
+			}
+			for _, p := range Percentiles {
+				if got := bands[0].Percentile(p); got != tt.a.Balance {
+					t.Errorf("year 0 p%d = %.2f, want %.2f", p, got, tt.a.Balance)
+				}
+				if got := bands[1].Percentile(p); math.Abs(got-tt.year1) > 0.005 {
+					t.Errorf("year 1 p%d = %.2f, want %.2f", p, got, tt.year1)
+				}
+			}
+			if bands[1].Deposited != tt.deposited {
+				t.Errorf("deposited by year 1 = %.2f, want %.2f", bands[1].Deposited, tt.deposited)
+			}
+		})
+	}
+}
+
+// TestProjectRandom checks that a seed reproduces a projection and that
+// each band's percentiles are in order and spread out.
+func TestProjectRandom(t *testing.T) {
+	a := Assumptions{
+		Balance: 5000, Years: 5, Rate: money.Percent(4), RateVolatility: money.Percent(1),
+		MonthlyDeposit: 200, DepositVolatility: 0.2, SkipProbability: 0.1, Runs: 500, Seed: 42,
+	}
+	bands, err := Project(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Project(a)
+	if !reflect.DeepEqual(bands, again) {
+		t.Error("the same seed projected differently")
+	}
+	a.Seed++
+	if other, _ := Project(a); reflect.DeepEqual(bands, other) {
+		t.Error("another seed projected the same")
+	}
+	for _, band := range bands[1:] {
+		for i := 1; i < len(band.Values); i++ {
+			if band.Values[i] < band.Values[i-1] {
+				t.Errorf("year %d: percentiles out of order: %v", band.Year, band.Values)
+			}
+		}
+		if band.Percentile(90) == band.Percentile(10) {
+			t.Errorf("year %d: no spread: %v", band.Year, band.Values)
+		}
+	}
+}
+
+// TestPercentile checks the interpolation between ranks.
+func TestPercentile(t *testing.T) {
+	sorted := []float64{10, 20, 30, 40, 50}
+	tests := []struct {
+		p    int
+		want float64
+	}{{0, 10}, {10, 14}, {25, 20}, {50, 30}, {90, 46}, {100, 50}}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%d) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]float64{7}, 90); got != 7 {
+		t.Errorf("percentile of one value = %v", got)
+	}
+}
@@ -0,0 +1,312 @@
+// Package reconcile matches the ledger of an account against a statement
+// from outside the bank, such as a correspondent bank or card processor
+// export, and reports the breaks: entries found on only one side.
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/calendar"
+	"gsolano/banking/models"
+)
+
+// Line is one line of an external statement. Amount is signed: credits to
+// the account are positive and debits negative.
+type Line struct {
+	Date        time.Time
+	Amount      float64
+	Reference   string
+	Description string
+}
+
+// Tolerance is how far a ledger entry and a statement line may differ and
+// still be matched automatically.
+type Tolerance struct {
+	Amount float64
+	Days   int
+}
+
+// DefaultTolerance matches amounts to the cent and allows two days for
+// items to clear.
+var DefaultTolerance = Tolerance{Amount: 0.005, Days: 2}
+
+// suggestionFactor widens the tolerance when looking for suggested matches
+// for the breaks, and maxSuggestions caps how many are offered per break.
+const (
+	suggestionFactor = 5
+	maxSuggestions   = 3
+)
+
+// Match pairs a ledger entry with a statement line. Exact matches agree on
+// amount, date and, when both carry one, reference.
+type Match struct {
+	Entry     models.Transaction
+	Line      Line
+	AmountGap float64
+	DayGap    int
+	Exact     bool
+}
+
+// BreakKind says on which side a break is missing.
+type BreakKind string
+
+const (
+	// MissingFromStatement is a ledger entry the statement does not show.
+	MissingFromStatement BreakKind = "missing_from_statement"
+	// MissingFromLedger is a statement line the ledger does not show.
+	MissingFromLedger BreakKind = "missing_from_ledger"
+)
+
+// Break is a ledger entry or a statement line left unmatched, with the
+// unmatched items on the other side that come closest to it.
+type Break struct {
+	Kind        BreakKind
+	Entry       *models.Transaction
+	Line        *Line
+	Suggestions []Match
+}
+
+// Report is the outcome of a reconciliation. The ledger and the statement
+// reconcile when there are no breaks.
+type Report struct {
+	Matches []Match
+	Breaks  []Break
+	// LedgerTotal and StatementTotal are the signed sums of each side.
+	LedgerTotal    float64
+	StatementTotal float64
+}
+
+// Reconciled reports whether every entry and line was matched.
+func (r Report) Reconciled() bool {
+	return len(r.Breaks) == 0
+}
+
+// Reconcile matches ledger entries to statement lines one to one. Exact
+// matches are paired first; the rest are paired within tol, closest first,
+// where a reference that both sides share outweighs gaps in date or amount.
+// Items whose references differ are never paired automatically.
+// Whatever remains is reported as a break with suggested matches found
+// within a wider tolerance.
+func Reconcile(ledger []models.Transaction, statement []Line, tol Tolerance) Report {
+	var report Report
+	for _, tx := range ledger {
+		report.LedgerTotal += signed(tx)
+	}
+	for _, l := range statement {
+		report.StatementTotal += l.Amount
+	}
+
+	entryUsed := make([]bool, len(ledger))
+	lineUsed := make([]bool, len(statement))
+	pair := func(within Tolerance, exactOnly bool) {
+		var candidates []candidate
+		for i, tx := range ledger {
+			if entryUsed[i] {
+				continue
+			}
+			for j, l := range statement {
+				if lineUsed[j] {
+					continue
+				}
+				c := compare(i, j, tx, l)
+				if !c.within(within) || c.otherRef || (exactOnly && !c.exact) {
+					continue
+				}
+				candidates = append(candidates, c)
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].cost() < candidates[b].cost() })
+		for _, c := range candidates {
+			if entryUsed[c.entry] || lineUsed[c.line] {
+				continue
+			}
+			entryUsed[c.entry], lineUsed[c.line] = true, true
+			report.Matches = append(report.Matches, c.match(ledger, statement))
+		}
+	}
+	pair(tol, true)
+	pair(tol, false)
+
+	wide := Tolerance{Amount: math.Max(tol.Amount*suggestionFactor, 1), Days: (tol.Days + 1) * suggestionFactor}
+	for i := range ledger {
+		if entryUsed[i] {
+			continue
+		}
+		b := Break{Kind: MissingFromStatement, Entry: &ledger[i]}
+		for j := range statement {
+			if c := compare(i, j, ledger[i], statement[j]); !lineUsed[j] && c.within(wide) {
+				b.Suggestions = append(b.Suggestions, c.match(ledger, statement))
+			}
+		}
+		report.Breaks = append(report.Breaks, b)
+	}
+	for j := range statement {
+		if lineUsed[j] {
+			continue
+		}
+		b := Break{Kind: MissingFromLedger, Line: &statement[j]}
+		for i := range ledger {
+			if c := compare(i, j, ledger[i], statement[j]); !entryUsed[i] && c.within(wide) {
+				b.Suggestions = append(b.Suggestions, c.match(ledger, statement))
+			}
+		}
+		report.Breaks = append(report.Breaks, b)
+	}
+	for i := range report.Breaks {
+		s := report.Breaks[i].Suggestions
+		sort.SliceStable(s, func(a, b int) bool { return matchCost(s[a]) < matchCost(s[b]) })
+		if len(s) > maxSuggestions {
+			report.Breaks[i].Suggestions = s[:maxSuggestions]
+		}
+	}
+	return report
+}
+
+type candidate struct {
+	entry, line int
+	amountGap   float64
+	dayGap      int
+	sameRef     bool
+	otherRef    bool // both sides carry a reference and they differ
+	exact       bool
+}
+
+func compare(i, j int, tx models.Transaction, l Line) candidate {
+	c := candidate{entry: i, line: j, amountGap: math.Abs(signed(tx) - l.Amount)}
+	gap := calendar.StartOfDay(tx.Time).Sub(calendar.StartOfDay(l.Date))
+	if gap < 0 {
+		gap = -gap
+	}
+	c.dayGap = int(math.Round(gap.Hours() / 24))
+	ref := reference(tx)
+	c.sameRef = l.Reference != "" && ref != "" && strings.EqualFold(ref, l.Reference)
+	c.otherRef = l.Reference != "" && ref != "" && !c.sameRef
+	c.exact = c.amountGap < 0.005 && c.dayGap == 0 && !c.otherRef
+	return c
+}
+
+func (c candidate) within(tol Tolerance) bool {
+	return c.amountGap <= tol.Amount && c.dayGap <= tol.Days
+}
+
+// cost orders candidates: a shared reference first, then the fewest days
+// apart, then the smallest difference in amount.
+func (c candidate) cost() float64 {
+	cost := float64(c.dayGap) + c.amountGap
+	if !c.sameRef {
+		cost += 1000
+	}
+	return cost
+}
+
+func (c candidate) match(ledger []models.Transaction, statement []Line) Match {
+	return Match{Entry: ledger[c.entry], Line: statement[c.line], AmountGap: c.amountGap, DayGap: c.dayGap, Exact: c.exact}
+}
+
+func matchCost(m Match) float64 {
+	return float64(m.DayGap) + m.AmountGap
+}
+
+// signed returns the amount of tx as it moves the balance.
+func signed(tx models.Transaction) float64 {
+	if tx.Type.IsCredit() {
+		return tx.Amount
+	}
+	return -tx.Amount
+}
+
+// reference is what identifies tx to the outside world: its "reference"
+// metadata, or else its description.
+func reference(tx models.Transaction) string {
+	if ref := tx.Metadata["reference"]; ref != "" {
+		return ref
+	}
+	return tx.Description
+}
+
+var ErrInvalidStatement = banking.New(banking.CodeInvalidArgument, "invalid statement")
+
+// ReadCSV reads a statement from CSV with a header row naming the columns
+// date, amount and optionally reference and description, in any order.
+// Dates are written 2006-01-02 and amounts are signed.
+func ReadCSV(r io.Reader) ([]Line, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+	col := make(map[string]int)
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"date", "amount"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("%w: no %s column", ErrInvalidStatement, required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var lines []Line
+	for n := 2; ; n++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+		}
+		date, err := time.Parse(time.DateOnly, field(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: bad date %q", ErrInvalidStatement, n, field(record, "date"))
+		}
+		amount, err := strconv.ParseFloat(field(record, "amount"), 64)
+		if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+			return nil, fmt.Errorf("%w: line %d: bad amount %q", ErrInvalidStatement, n, field(record, "amount"))
+		}
+		lines = append(lines, Line{Date: date, Amount: amount, Reference: field(record, "reference"), Description: field(record, "description")})
+	}
+}
+
+// WriteText writes the report for people: a summary, then every break with
+// its suggestions.
+func (r Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	exact := 0
+	for _, m := range r.Matches {
+		if m.Exact {
+			exact++
+		}
+	}
+	fmt.Fprintf(&b, "matched %d (%d exact, %d within tolerance), %d breaks\n", len(r.Matches), exact, len(r.Matches)-exact, len(r.Breaks))
+	fmt.Fprintf(&b, "ledger total %.2f, statement total %.2f, difference %.2f\n", r.LedgerTotal, r.StatementTotal, r.LedgerTotal-r.StatementTotal)
+	for _, br := range r.Breaks {
+		switch br.Kind {
+		case MissingFromStatement:
+			fmt.Fprintf(&b, "\nnot on statement: ledger #%d %s %.2f %s\n", br.Entry.Sequence, br.Entry.Time.Format(time.DateOnly), signed(*br.Entry), reference(*br.Entry))
+		case MissingFromLedger:
+			fmt.Fprintf(&b, "\nnot in ledger: statement %s %.2f %s\n", br.Line.Date.Format(time.DateOnly), br.Line.Amount, br.Line.Reference)
+		}
+		for _, s := range br.Suggestions {
+			fmt.Fprintf(&b, "  maybe ledger #%d %s %.2f with statement %s %.2f %s (off by %.2f, %d days)\n",
+				s.Entry.Sequence, s.Entry.Time.Format(time.DateOnly), signed(s.Entry),
+				s.Line.Date.Format(time.DateOnly), s.Line.Amount, s.Line.Reference, s.AmountGap, s.DayGap)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,135 @@
+package reconcile
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking/golden"
+	"gsolano/banking/models"
+)
+
+// TestWriteTextGolden reconciles a ledger against testdata/statement.csv,
+// with an exact match, a match within tolerance, a near miss and a break on
+// each side, and compares the report with testdata/report.txt.
+func TestWriteTextGolden(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 10, 0, 0, 0, time.UTC) }
+	ledger := []models.Transaction{
+		{Sequence: 1, Type: models.TransactionDeposit, Amount: 2500, Time: day(2), Metadata: map[string]string{"reference": "PAY-0302"}},
+		{Sequence: 2, Type: models.TransactionWithdrawal, Amount: 42.5, Time: day(4), Description: "coffee"},
+		{Sequence: 3, Type: models.TransactionWithdrawal, Amount: 1200, Time: day(5), Description: "rent"},
+		{Sequence: 4, Type: models.TransactionWithdrawal, Amount: 19.99, Time: day(9), Description: "subscription"},
+	}
+	f, err := os.Open("testdata/statement.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	statement, err := ReadCSV(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := Reconcile(ledger, statement, DefaultTolerance).WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	golden.Assert(t, "report.txt", out.Bytes())
+}
+
+// FuzzReadCSV feeds the statement reader malformed files: it must return an
+// error or lines that reconcile without panicking, never amounts that are
+// not numbers.
+func FuzzReadCSV(f *testing.F) {
+	f.Add("date,amount,reference,description\n2026-01-05,-42.50,REF1,coffee\n2026-01-06,1000,,salary\n")
+	f.Add("amount,date\n12,2026-02-30\n")
+	f.Add("date,amount\n2026-01-05,NaN\n")
+	f.Add("date,amount\n2026-01-05,\"1,000\"\n")
+	f.Add("date\n")
+	f.Add("")
+	f.Add("\"unterminated\n")
+	f.Fuzz(func(t *testing.T, file string) {
+		lines, err := ReadCSV(strings.NewReader(file))
+		if err != nil {
+			return
+		}
+		for i, l := range lines {
+			if math.IsNaN(l.Amount) || math.IsInf(l.Amount, 0) {
+				t.Fatalf("line %d has amount %v", i+2, l.Amount)
+			}
+		}
+		ledger := []models.Transaction{{Sequence: 1, Type: models.TransactionDeposit, Amount: 1000, Time: time.Date(2026, 1, 6, 9, 0, 0, 0, time.UTC)}}
+		report := Reconcile(ledger, lines, DefaultTolerance)
+		accounted := len(report.Matches)
+		for _, br := range report.Breaks {
+			if br.Kind == MissingFromLedger {
+				accounted++
+			}
+		}
+		if accounted != len(lines) {
+			t.Fatalf("%d lines but %d matched or missing from the ledger", len(lines), accounted)
+		}
+	})
+}
+
+// TestReconcile checks that exact matches are paired before closer ones
+// within the tolerance, that a shared reference outweighs a gap in dates,
+// that entries whose references differ are left as breaks suggesting each
+// other, and the totals of each side.
+func TestReconcile(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 10, 0, 0, 0, time.UTC) }
+	ref := func(r string) map[string]string { return map[string]string{"reference": r} }
+	ledger := []models.Transaction{
+		{Sequence: 1, Type: models.TransactionWithdrawal, Amount: 100, Time: day(2)},
+		{Sequence: 2, Type: models.TransactionWithdrawal, Amount: 100, Time: day(3)},
+		{Sequence: 3, Type: models.TransactionDeposit, Amount: 50, Time: day(5), Metadata: ref("INV-1")},
+		{Sequence: 4, Type: models.TransactionDeposit, Amount: 50, Time: day(7)},
+		{Sequence: 5, Type: models.TransactionWithdrawal, Amount: 20, Time: day(12), Metadata: ref("A")},
+	}
+	statement := []Line{
+		{Date: day(3), Amount: -100},
+		{Date: day(4), Amount: -100},
+		{Date: day(6), Amount: 50, Reference: "inv-1"},
+		{Date: day(12), Amount: -20, Reference: "B"},
+	}
+	report := Reconcile(ledger, statement, DefaultTolerance)
+
+	type pair struct {
+		entry int
+		line  time.Time
+		exact bool
+	}
+	var pairs []pair
+	for _, m := range report.Matches {
+		pairs = append(pairs, pair{m.Entry.Sequence, m.Line.Date, m.Exact})
+	}
+	if want := []pair{{2, day(3), true}, {3, day(6), false}, {1, day(4), false}}; !slices.Equal(pairs, want) {
+		t.Errorf("matches %v, want %v", pairs, want)
+	}
+
+	var breaks []string
+	for _, b := range report.Breaks {
+		s := string(b.Kind)
+		if b.Entry != nil {
+			s += fmt.Sprintf(" entry %d", b.Entry.Sequence)
+		} else {
+			s += " line " + b.Line.Reference
+		}
+		breaks = append(breaks, fmt.Sprintf("%s with %d suggestions", s, len(b.Suggestions)))
+	}
+	want := []string{
+		"missing_from_statement entry 4 with 0 suggestions",
+		"missing_from_statement entry 5 with 1 suggestions",
+		"missing_from_ledger line B with 1 suggestions",
+	}
+	if !slices.Equal(breaks, want) {
+		t.Errorf("breaks %q, want %q", breaks, want)
+	}
+	if report.Reconciled() || report.LedgerTotal != -120 || report.StatementTotal != -170 {
+		t.Errorf("reconciled %v with totals %.2f and %.2f, want false with -120.00 and -170.00", report.Reconciled(), report.LedgerTotal, report.StatementTotal)
+	}
+}
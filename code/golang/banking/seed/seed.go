@@ -0,0 +1,348 @@
+// Package seed fills a bank with realistic fake customers, their accounts
+// and months of everyday transactions: salaries, rent, bills, groceries,
+// eating out, travel and shopping, savings and card payments. The same options always generate
+// the same bank, for load tests and demos.
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/calendar"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/sim"
+)
+
+// Options say how much to generate. The transactions cover the Months
+// before End, today when End is zero; Seed makes the bank reproducible.
+type Options struct {
+	Customers int
+	Months    int
+	Seed      uint64
+	End       time.Time
+}
+
+const (
+	MaxCustomers = 100000
+	MaxMonths    = 120
+)
+
+var ErrInvalidOptions = banking.New(banking.CodeInvalidArgument, "invalid seed options")
+
+// Report counts what Generate created.
+type Report struct {
+	Customers    int
+	Accounts     int
+	Transactions int
+}
+
+var (
+	firstNames = []string{"Ana", "Ben", "Carla", "David", "Elena", "Farid", "Grace", "Hugo", "Ines", "Jonas",
+		"Keiko", "Luis", "Maya", "Noah", "Olga", "Pablo", "Quinn", "Rosa", "Samir", "Tara", "Umar", "Vera", "Wei", "Yara"}
+	lastNames = []string{"Alvarez", "Brown", "Chen", "Diaz", "Evans", "Fischer", "Garcia", "Haddad", "Ito", "Jensen",
+		"Kowalski", "Lopez", "Moreau", "Nguyen", "Okafor", "Petrov", "Rossi", "Silva", "Tanaka", "Weber"}
+	employers   = []string{"Acme Corp", "Globex", "Initech", "Umbrella Health", "Stark Logistics", "City Council", "Northwind Traders"}
+	landlords   = []string{"Parkview Apartments", "Oak Street Lettings", "Riverside Homes", "J. Smith (landlord)"}
+	supermarket = []string{"FreshMart", "Green Grocer", "Corner Market", "MegaSave", "Organic Pantry"}
+	restaurants = []string{"Luigi's Trattoria", "Sakura Sushi", "The Burger Joint", "Taco Town", "Curry House", "Cafe Central"}
+	coffee      = []string{"Bean There", "Daily Grind", "Espresso Bar"}
+	transport   = []string{"Metro Transit", "QuickFuel", "CityCab"}
+	shops       = []string{"Urban Outfitters Co", "TechWorld", "HomeGoods Plus", "BookNook", "Online Marketplace"}
+	bills       = []bill{
+		{"City Power & Light", "utilities", 45, 140},
+		{"AquaWorks Water", "utilities", 20, 45},
+		{"FiberNet", "internet", 35, 60},
+		{"MobileOne", "phone", 15, 55},
+	}
+	subscriptions = []bill{
+		{"StreamFlix", "entertainment", 9.99, 15.99},
+		{"Tunes Premium", "entertainment", 4.99, 10.99},
+		{"FitLife Gym", "health", 25, 45},
+	}
+)
+
+type bill struct {
+	payee     string
+	category  string
+	low, high float64
+}
+
+// persona is a generated customer and how they live.
+type persona struct {
+	id       string
+	checking string
+	savings  string
+	card     string
+	salary   float64
+	biweekly bool
+	employer string
+	rent     float64
+	landlord string
+	bills    []bill
+	// billDay and subDay are the days of the month bills and subscriptions
+	// are paid.
+	billDay, subDay int
+	saveShare       float64
+	coffeeLover     bool
+}
+
+// Generate adds the customers, accounts and transactions described by o to
+// bank. It replaces the bank's clock while it runs, so every transaction is
+// dated when it would have happened, and puts it back before returning.
+// Customers are "seed00001" and so on, with accounts CHK00001, SAV00001 and,
+// for some, CRD00001; Generate fails if any of them exists.
+func Generate(bank *models.Bank, o Options) (Report, error) {
+	if o.Customers < 1 || o.Customers > MaxCustomers {
+		return Report{}, fmt.Errorf("%w: customers must be between 1 and %d", ErrInvalidOptions, MaxCustomers)
+	}
+	if o.Months < 1 || o.Months > MaxMonths {
+		return Report{}, fmt.Errorf("%w: months must be between 1 and %d", ErrInvalidOptions, MaxMonths)
+	}
+	end := o.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	end = calendar.StartOfDay(end)
+	start := end.AddDate(0, -o.Months, 0)
+
+	rng := rand.New(rand.NewPCG(o.Seed, o.Seed^0x9e3779b97f4a7c15))
+	clock := sim.NewClock(start)
+	saved := bank.Clock
+	bank.Clock = clock
+	defer func() { bank.Clock = saved }()
+
+	var report Report
+	people := make([]*persona, o.Customers)
+	for i := range people {
+		p := newPersona(rng, i+1)
+		if err := open(bank, rng, p, &report); err != nil {
+			return report, err
+		}
+		people[i] = p
+	}
+
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		var entries []entry
+		for _, p := range people {
+			entries = append(entries, p.day(rng, day)...)
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+		for _, e := range entries {
+			clock.Advance(e.at.Sub(clock.Now()))
+			if e.post(bank) == nil {
+				report.Transactions++
+			}
+		}
+		clock.Advance(day.AddDate(0, 0, 1).Sub(clock.Now()))
+		bank.BillCards()
+	}
+	return report, nil
+}
+
+func newPersona(rng *rand.Rand, n int) *persona {
+	salary := money.Round(math.Max(1600, lognormal(rng, 3400, 0.35)), "", money.HalfEven)
+	p := &persona{
+		id:          fmt.Sprintf("seed%05d", n),
+		checking:    fmt.Sprintf("CHK%05d", n),
+		savings:     fmt.Sprintf("SAV%05d", n),
+		salary:      salary,
+		biweekly:    rng.Float64() < 0.3,
+		employer:    pick(rng, employers),
+		rent:        money.Round(salary*(0.22+rng.Float64()*0.14), "", money.HalfEven),
+		landlord:    pick(rng, landlords),
+		billDay:     3 + rng.IntN(10),
+		subDay:      1 + rng.IntN(28),
+		saveShare:   []float64{0, 0.05, 0.1, 0.15}[rng.IntN(4)],
+		coffeeLover: rng.Float64() < 0.4,
+	}
+	if rng.Float64() < 0.6 {
+		p.card = fmt.Sprintf("CRD%05d", n)
+	}
+	p.bills = append(p.bills, bills...)
+	for _, s := range subscriptions {
+		if rng.Float64() < 0.5 {
+			p.bills = append(p.bills, s)
+		}
+	}
+	return p
+}
+
+func open(bank *models.Bank, rng *rand.Rand, p *persona, report *Report) error {
+	name := pick(rng, firstNames) + " " + pick(rng, lastNames)
+	if err := bank.AddCustomer(&models.Customer{ID: p.id, Name: name}); err != nil {
+		return fmt.Errorf("customer %s: %w", p.id, err)
+	}
+	accounts := []models.BankAccount{
+		&models.CheckingAccount{Account: models.Account{AccountNumber: p.checking}, OverdraftLimit: 200},
+		&models.SavingsAccount{Account: models.Account{AccountNumber: p.savings}, InterestRate: money.Percent(2 + rng.Float64()*2)},
+	}
+	if p.card != "" {
+		limit := math.Round(p.salary/500) * 500
+		accounts = append(accounts, &models.CreditCardAccount{Account: models.Account{AccountNumber: p.card}, CreditLimit: limit, PurchaseRate: money.Percent(19.9)})
+	}
+	for _, a := range accounts {
+		if err := bank.OpenFor(p.id, a); err != nil {
+			return fmt.Errorf("account %s: %w", a.Number(), err)
+		}
+	}
+	report.Customers++
+	report.Accounts += len(accounts)
+
+	// Start everyone with a month's salary, and some savings.
+	opening := []entry{
+		{kind: deposit, account: p.checking, amount: p.salary, description: "opening balance"},
+		{kind: deposit, account: p.savings, amount: money.Round(lognormal(rng, p.salary*2, 0.8), "", money.HalfEven), description: "opening balance"},
+	}
+	for _, e := range opening {
+		e.at = bank.Clock.Now()
+		if e.post(bank) == nil {
+			report.Transactions++
+		}
+	}
+	return nil
+}
+
+type entryKind int
+
+const (
+	deposit entryKind = iota
+	withdrawal
+	transfer
+	// cardPayment pays share of what is owed on the card to.
+	cardPayment
+)
+
+// entry is one transaction a persona makes.
+type entry struct {
+	at           time.Time
+	kind         entryKind
+	account      string
+	to           string
+	amount       float64
+	share        float64
+	counterparty string
+	category     string
+	description  string
+}
+
+func (e entry) post(bank *models.Bank) error {
+	if e.kind == cardPayment {
+		balance, err := bank.Balance(e.to)
+		if err != nil {
+			return err
+		}
+		e.kind, e.amount = transfer, -balance*e.share
+	}
+	amount := money.Round(e.amount, "", money.HalfEven)
+	if amount <= 0 {
+		return models.ErrInvalidAmount
+	}
+	opts := []models.TxOption{models.WithCounterparty(e.counterparty), models.WithDescription(e.description)}
+	switch e.kind {
+	case deposit:
+		return bank.Deposit(e.account, amount, opts...)
+	case withdrawal:
+		return bank.Withdraw(e.account, amount, append(opts, models.WithCategory(e.category))...)
+	default:
+		return bank.Transfer(e.account, e.to, amount, opts...)
+	}
+}
+
+// day returns what p does on day, in time order.
+func (p *persona) day(rng *rand.Rand, day time.Time) []entry {
+	var entries []entry
+	at := func(hour int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(rng.IntN(3600))*time.Second)
+	}
+	last := day.AddDate(0, 1, -day.Day()).Day()
+	weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+
+	switch {
+	case p.biweekly && day.Weekday() == time.Friday && day.YearDay()/7%2 == 0:
+		entries = append(entries, entry{at: at(6), kind: deposit, account: p.checking, amount: p.salary * 12 / 26,
+			counterparty: p.employer, description: "salary"})
+	case !p.biweekly && day.Day() == min(25, last):
+		entries = append(entries, entry{at: at(6), kind: deposit, account: p.checking, amount: p.salary,
+			counterparty: p.employer, description: "salary"})
+	}
+	if day.Day() == 1 {
+		entries = append(entries, entry{at: at(8), kind: withdrawal, account: p.checking, amount: p.rent,
+			counterparty: p.landlord, category: "rent", description: "rent"})
+	}
+	if day.Day() == min(26, last) && p.saveShare > 0 {
+		entries = append(entries, entry{at: at(9), kind: transfer, account: p.checking, to: p.savings,
+			amount: p.salary * p.saveShare, description: "monthly savings"})
+	}
+	for i, b := range p.bills {
+		due := p.billDay
+		if i >= len(bills) {
+			due = p.subDay
+		}
+		if day.Day() == min(due, last) {
+			entries = append(entries, entry{at: at(10), kind: withdrawal, account: p.spending(rng, i >= len(bills)),
+				amount: b.low + rng.Float64()*(b.high-b.low), counterparty: b.payee, category: b.category, description: b.payee})
+		}
+	}
+	if p.coffeeLover && !weekend && rng.Float64() < 0.6 {
+		entries = append(entries, entry{at: at(8), kind: withdrawal, account: p.spending(rng, true),
+			amount: 2.5 + rng.Float64()*3.5, counterparty: pick(rng, coffee), category: "dining", description: "coffee"})
+	}
+	groceries := 0.3
+	if weekend {
+		groceries = 0.55
+	}
+	if rng.Float64() < groceries {
+		entries = append(entries, entry{at: at(17), kind: withdrawal, account: p.spending(rng, true),
+			amount: lognormal(rng, 55, 0.5), counterparty: pick(rng, supermarket), category: "groceries", description: "groceries"})
+	}
+	dining := 0.1
+	if day.Weekday() == time.Friday || day.Weekday() == time.Saturday {
+		dining = 0.35
+	}
+	if rng.Float64() < dining {
+		entries = append(entries, entry{at: at(19), kind: withdrawal, account: p.spending(rng, true),
+			amount: lognormal(rng, 32, 0.6), counterparty: pick(rng, restaurants), category: "dining", description: "restaurant"})
+	}
+	if rng.Float64() < 0.15 {
+		entries = append(entries, entry{at: at(7), kind: withdrawal, account: p.spending(rng, true),
+			amount: lognormal(rng, 35, 0.4), counterparty: pick(rng, transport), category: "transport", description: "travel"})
+	}
+	if rng.Float64() < 0.08 {
+		entries = append(entries, entry{at: at(14), kind: withdrawal, account: p.spending(rng, true),
+			amount: lognormal(rng, 70, 0.9), counterparty: pick(rng, shops), category: "shopping", description: "shopping"})
+	}
+	if p.card != "" && day.Day() == 20 {
+		// Most months the card is paid in full, otherwise in part.
+		share := 1.0
+		if rng.Float64() < 0.25 {
+			share = 0.1 + rng.Float64()*0.5
+		}
+		entries = append(entries, entry{at: at(11), kind: cardPayment, account: p.checking, to: p.card,
+			share: share, description: "card payment"})
+	}
+	return entries
+}
+
+// spending returns the account a payment is made from: the card, when p has
+// one and it suits the payment, or checking.
+func (p *persona) spending(rng *rand.Rand, cardOK bool) string {
+	if p.card != "" && cardOK && rng.Float64() < 0.5 {
+		return p.card
+	}
+	return p.checking
+}
+
+// lognormal draws a positive amount whose median is median; spread is the
+// standard deviation of its logarithm.
+func lognormal(rng *rand.Rand, median, spread float64) float64 {
+	return median * math.Exp(rng.NormFloat64()*spread)
+}
+
+func pick(rng *rand.Rand, from []string) string {
+	return from[rng.IntN(len(from))]
+}
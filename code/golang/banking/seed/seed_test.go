@@ -0,0 +1,70 @@
+package seed
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+type fixedClock struct{ now time.Time }
+
+func (c fixedClock) Now() time.Time { return c.now }
+
+// seeded returns the snapshot of a bank generated with o, and its report.
+func seeded(t *testing.T, o Options) ([]byte, Report, *models.Bank) {
+	t.Helper()
+	bank := models.NewBank()
+	bank.Clock = fixedClock{o.End}
+	bank.IDs = &models.DeterministicIDs{}
+	report, err := Generate(bank, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := bank.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), report, bank
+}
+
+// TestGenerateIsReproducible generates two banks from the same options
+// and one from another seed, checking that the same options give the same
+// bank, that the report counts what is in it, that the bank's clock is put
+// back and that invalid options and existing accounts are refused.
+func TestGenerateIsReproducible(t *testing.T) {
+	models.SetOutput(io.Discard)
+	o := Options{Customers: 5, Months: 2, Seed: 7, End: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	first, report, bank := seeded(t, o)
+	second, _, _ := seeded(t, o)
+	if !bytes.Equal(first, second) {
+		t.Error("the same options generated different banks")
+	}
+	o.Seed = 8
+	if other, _, _ := seeded(t, o); bytes.Equal(first, other) {
+		t.Error("another seed generated the same bank")
+	}
+
+	transactions := 0
+	for _, account := range bank.Accounts() {
+		transactions += len(account.History())
+	}
+	if report.Customers != 5 || report.Accounts != len(bank.Accounts()) || report.Transactions == 0 || report.Transactions > transactions {
+		t.Errorf("report %+v for %d accounts and %d entries", report, len(bank.Accounts()), transactions)
+	}
+	if now := bank.Clock.Now(); !now.Equal(o.End) {
+		t.Errorf("bank clock left at %s, want %s", now, o.End)
+	}
+
+	for _, bad := range []Options{{Customers: 0, Months: 1}, {Customers: 1, Months: MaxMonths + 1}} {
+		if _, err := Generate(models.NewBank(), bad); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("generating %+v: %v, want ErrInvalidOptions", bad, err)
+		}
+	}
+	if _, err := Generate(bank, o); err == nil {
+		t.Error("generating over existing seed accounts succeeded")
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var ErrAlertsDisabled = banking.New(banking.CodeFeatureDisabled, "alerts are not enabled")
+
+// WithAlerts lets holders configure the alert rules of their accounts in a.
+// Without it the alert endpoints answer 403.
+func WithAlerts(a *models.Alerts) Option {
+	return func(s *Server) { s.alerts = a }
+}
+
+// alertRuleRequest adds a rule to the {number} account of the path.
+type alertRuleRequest struct {
+	Kind      models.AlertKind `json:"kind"`
+	Threshold float64          `json:"threshold"`
+}
+
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		writeError(w, ErrAlertsDisabled)
+		return
+	}
+	rules := s.alerts.Rules(r.PathValue("number"))
+	if rules == nil {
+		rules = []models.AlertRule{}
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) handleAddAlertRule(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		writeError(w, ErrAlertsDisabled)
+		return
+	}
+	var req alertRuleRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	rule := models.AlertRule{AccountNumber: r.PathValue("number"), Kind: req.Kind, Threshold: req.Threshold}
+	id, err := s.alerts.AddRule(rule)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rule.ID = id
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) handleRemoveAlertRule(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		writeError(w, ErrAlertsDisabled)
+		return
+	}
+	if err := s.alerts.RemoveRule(r.PathValue("number"), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleAlertRules(w, r)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"gsolano/banking/models"
+)
+
+// resolveAccount lets the {number} of a route be a nickname as well as an
+// account number; see Bank.Resolve. References that name no account are
+// left for the handler to report.
+func (s *Server) resolveAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ref := r.PathValue("number"); ref != "" {
+			number, err := s.bank.Resolve(ref)
+			switch {
+			case err == nil:
+				r.SetPathValue("number", number)
+			case errors.Is(err, models.ErrAmbiguousAlias):
+				writeError(w, err)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// resolve replaces the account references in a request body with the
+// numbers they name. References that name no account are kept as they are.
+func (s *Server) resolve(refs ...*string) error {
+	for _, ref := range refs {
+		number, err := s.bank.Resolve(*ref)
+		switch {
+		case err == nil:
+			*ref = number
+		case errors.Is(err, models.ErrAmbiguousAlias):
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleGetAlias(w http.ResponseWriter, r *http.Request) {
+	alias, err := s.bank.Alias(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alias)
+}
+
+func (s *Server) handleSetAlias(w http.ResponseWriter, r *http.Request) {
+	var alias models.Alias
+	if !readJSON(w, r, &alias) {
+		return
+	}
+	if err := s.bank.SetAlias(r.PathValue("number"), alias); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleGetAlias(w, r)
+}
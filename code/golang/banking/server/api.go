@@ -0,0 +1,861 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/insights"
+	"gsolano/banking/models"
+)
+
+// apiRoute is one endpoint of the JSON API. The same table registers the
+// handlers and generates the OpenAPI document, so the two cannot drift apart.
+type apiRoute struct {
+	method  string
+	path    string
+	summary string
+	// request and response are zero values of the body types, nil when the
+	// endpoint has no body.
+	request  any
+	response any
+	// contentType is the media type of a response that is not JSON, such
+	// as text/csv, described as a string.
+	contentType string
+	status      int
+	handler     http.HandlerFunc
+	// query names the query parameters the endpoint reads, with their
+	// descriptions. Paged endpoints also read cursor and limit.
+	query map[string]string
+	paged bool
+	// permission is the least a holder named by X-Customer-ID must hold
+	// the route's {number} account with, and the least scope of an API key
+	// using the route: view for GET routes and admin for others unless set.
+	permission models.Permission
+	// action names the route's requests in the audit log, such as deposit;
+	// its method and path by default.
+	action string
+	// stepUp marks high-risk routes, which sessions may only use soon after
+	// their customer authenticated, see Bank.StepUp.
+	stepUp bool
+}
+
+type accountJSON struct {
+	Number    string        `json:"number"`
+	Kind      string        `json:"kind"`
+	Product   string        `json:"product,omitempty"`
+	Currency  string        `json:"currency,omitempty"`
+	Balance   float64       `json:"balance"`
+	Available float64       `json:"available"`
+	Closed    *time.Time    `json:"closed,omitempty"`
+	Deleted   *time.Time    `json:"deleted,omitempty"`
+	Dormant   *time.Time    `json:"dormant,omitempty"`
+	Alias     *models.Alias `json:"alias,omitempty"`
+}
+
+type archivedAccountJSON struct {
+	Number       string               `json:"number"`
+	Kind         string               `json:"kind"`
+	Closed       time.Time            `json:"closed"`
+	Archived     time.Time            `json:"archived"`
+	Transactions []models.Transaction `json:"transactions"`
+}
+
+type customerJSON struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Accounts []string `json:"accounts"`
+}
+
+type amountRequest struct {
+	Amount       float64  `json:"amount"`
+	Counterparty string   `json:"counterparty,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// ConfirmDuplicate goes ahead with a withdrawal that looks like one
+	// just made.
+	ConfirmDuplicate bool `json:"confirm_duplicate,omitempty"`
+}
+
+type transferRequest struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Amount   float64 `json:"amount"`
+	Category string  `json:"category,omitempty"`
+	// ConfirmDuplicate goes ahead with a transfer that looks like one just
+	// made.
+	ConfirmDuplicate bool `json:"confirm_duplicate,omitempty"`
+	// PayeeName is checked against the holders of To, and ConfirmPayee
+	// goes ahead though it does not match.
+	PayeeName    string `json:"payee_name,omitempty"`
+	ConfirmPayee bool   `json:"confirm_payee,omitempty"`
+}
+
+type matchJSON struct {
+	Account     string             `json:"account"`
+	Transaction models.Transaction `json:"transaction"`
+}
+
+// Paged lists share the shape of a page: the items, and the cursor of the
+// next page while has_more is true.
+
+type accountPageJSON struct {
+	Items      []accountJSON `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	HasMore    bool          `json:"has_more"`
+}
+
+type transactionPageJSON struct {
+	Items      []models.Transaction `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+type searchJSON struct {
+	Items      []matchJSON `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+}
+
+type eventJSON struct {
+	ID          int64               `json:"id"`
+	Type        models.EventType    `json:"type"`
+	Account     string              `json:"account,omitempty"`
+	Message     string              `json:"message,omitempty"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Time        time.Time           `json:"time"`
+}
+
+type eventPageJSON struct {
+	Items      []eventJSON `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+}
+
+type scoredMatchJSON struct {
+	Account     string             `json:"account"`
+	Transaction models.Transaction `json:"transaction"`
+	Score       float64            `json:"score"`
+}
+
+type batchRequest struct {
+	Transfers    []transferRequest `json:"transfers"`
+	AllOrNothing bool              `json:"all_or_nothing,omitempty"`
+	DryRun       bool              `json:"dry_run,omitempty"`
+}
+
+type batchResultJSON struct {
+	Transfer  transferRequest `json:"transfer"`
+	Succeeded bool            `json:"succeeded"`
+	Error     *errorJSON      `json:"error,omitempty"`
+	// Withdrawal and Deposit are the entries the transfer posted, or
+	// would post in a dry run.
+	Withdrawal *models.Transaction `json:"withdrawal,omitempty"`
+	Deposit    *models.Transaction `json:"deposit,omitempty"`
+}
+
+type errorJSON struct {
+	Error     string               `json:"error"`
+	Code      banking.Code         `json:"code"`
+	Retryable bool                 `json:"retryable"`
+	Fields    []banking.FieldError `json:"fields,omitempty"`
+}
+
+func (s *Server) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{method: "GET", path: "/api/customers", summary: "List customers",
+			response: []customerJSON{}, handler: s.handleListCustomers},
+		{method: "GET", path: "/api/customers/{id}", summary: "Get a customer",
+			response: customerJSON{}, handler: s.handleGetCustomer},
+		{method: "GET", path: "/api/customers/{id}/credit", summary: "Score a customer's credit from their transaction history",
+			response: creditJSON{}, handler: s.handleCredit, query: creditQuery},
+		{method: "POST", path: "/api/customers/{id}/loans", summary: "Open a loan for a customer and pay it out into one of their accounts",
+			request: loanRequest{}, response: loanJSON{}, status: http.StatusCreated, handler: s.handleOpenLoan},
+		{method: "GET", path: "/api/customers/{id}/valuation", summary: "Value a customer's accounts in one currency",
+			response: models.Valuation{}, handler: s.handleValuation, query: valuationQuery},
+		{method: "GET", path: "/api/customers/{id}/net-worth", summary: "Value a customer's accounts in one currency over time",
+			response: []models.Valuation{}, handler: s.handleNetWorth, query: netWorthQuery},
+		{method: "GET", path: "/api/accounts", summary: "List accounts",
+			response: accountPageJSON{}, handler: s.handleListAccounts, paged: true,
+			query: map[string]string{"include_deleted": "Also list soft-deleted accounts"}},
+		{method: "POST", path: "/api/accounts", summary: "Open an account as a product of the catalog",
+			request: openAccountRequest{}, response: accountJSON{}, status: http.StatusCreated, handler: s.handleOpenAccount},
+		{method: "POST", path: "/api/applications", summary: "Apply to open an account as a product of the catalog",
+			request: applicationRequest{}, response: models.Application{}, status: http.StatusCreated, handler: s.handleApply},
+		{method: "GET", path: "/api/applications/{id}", summary: "Get an application to open an account and the steps it went through",
+			response: models.Application{}, handler: s.handleGetApplication},
+		{method: "GET", path: "/api/customers/{id}/applications", summary: "List the applications of a customer",
+			response: []models.Application{}, handler: s.handleCustomerApplications},
+		{method: "POST", path: "/api/applications/{id}/submit", summary: "Submit an application for its KYC checks",
+			response: models.Application{}, handler: s.handleSubmitApplication},
+		{method: "POST", path: "/api/applications/{id}/kyc", summary: "Record the outcome of the KYC checks of an application",
+			request: kycRequest{}, response: models.Application{}, handler: s.handleCompleteKYC},
+		{method: "POST", path: "/api/applications/{id}/fund", summary: "Make the opening deposit, which opens the account",
+			request: fundRequest{}, response: models.Application{}, handler: s.handleFundApplication},
+		{method: "DELETE", path: "/api/applications/{id}", summary: "Abandon an application",
+			response: models.Application{}, handler: s.handleAbandonApplication},
+		{method: "GET", path: "/api/products", summary: "List the account products of the catalog",
+			response: []models.Product{}, handler: s.handleListProducts},
+		{method: "POST", path: "/api/products/{code}/rate-changes", summary: "Change the rate of a savings product and its fixed-rate accounts from a future date, with notice to their holders",
+			request: rateChangeRequest{}, response: models.RateChange{}, status: http.StatusCreated, handler: s.handleScheduleRateChange},
+		{method: "GET", path: "/api/rate-changes", summary: "List the scheduled, applied and cancelled changes of product rates",
+			response: []models.RateChange{}, handler: s.handleListRateChanges, query: rateChangesQuery},
+		{method: "DELETE", path: "/api/rate-changes/{id}", summary: "Cancel a rate change that has not taken effect",
+			response: models.RateChange{}, handler: s.handleCancelRateChange},
+		{method: "GET", path: "/api/accounts/{number}", summary: "Get an account and its balance",
+			response: accountJSON{}, handler: s.handleGetAccount},
+		{method: "POST", path: "/api/accounts/{number}/close", summary: "Close an account with a zero balance",
+			response: accountJSON{}, handler: s.handleCloseAccount},
+		{method: "DELETE", path: "/api/accounts/{number}", summary: "Soft-delete an account: close it, keep its ledger and leave it out of listings",
+			response: accountJSON{}, handler: s.handleDeleteAccount},
+		{method: "POST", path: "/api/accounts/{number}/restore", summary: "Reopen a deleted account within the grace period",
+			response: accountJSON{}, handler: s.handleRestoreAccount},
+		{method: "POST", path: "/api/accounts/{number}/reactivate", summary: "Lift the dormancy of an account so that money can leave it again",
+			response: accountJSON{}, handler: s.handleReactivate},
+		{method: "POST", path: "/api/accounts/{number}/renumber", summary: "Give an account a new number, such as after a branch merge; the old number keeps naming it",
+			request: renumberRequest{}, response: accountJSON{}, handler: s.handleRenumber},
+		{method: "GET", path: "/api/accounts/{number}/alias", summary: "Get the nickname, color and emoji of an account",
+			response: models.Alias{}, handler: s.handleGetAlias},
+		{method: "PUT", path: "/api/accounts/{number}/alias", summary: "Name an account; nicknames are unique among a customer's accounts and work wherever an account number does",
+			request: models.Alias{}, response: models.Alias{}, handler: s.handleSetAlias},
+		{method: "GET", path: "/api/accounts/{number}/insights", summary: "Explain a month of spending: changes from the month before, top counterparties and unusual payments",
+			response: insights.Report{}, handler: s.handleInsights, query: insightsQuery},
+		{method: "GET", path: "/api/accounts/{number}/bills", summary: "List the recurring payments, such as rent and subscriptions, expected to fall due in the coming days",
+			response: []models.RecurringPayment{}, handler: s.handleUpcomingBills, query: billsQuery},
+		{method: "GET", path: "/api/accounts/{number}/holders", summary: "List the holders of an account and what each may do",
+			response: []models.Holder{}, handler: s.handleHolders},
+		{method: "PUT", path: "/api/accounts/{number}/holders/{customer}", summary: "Change the permission and daily limit of a holder, as an admin", stepUp: true,
+			request: holderRequest{}, response: models.Holder{}, handler: s.handleSetHolder},
+		{method: "DELETE", path: "/api/accounts/{number}/holders/{customer}", summary: "Remove a holder, as an admin or the holder themselves",
+			response: []models.Holder{}, handler: s.handleRemoveHolder, permission: models.PermissionView},
+		{method: "POST", path: "/api/accounts/{number}/invitations", summary: "Invite a customer to hold an account, as an admin", stepUp: true,
+			request: models.Holder{}, response: models.Invitation{}, status: http.StatusCreated, handler: s.handleInvite},
+		{method: "GET", path: "/api/customers/{id}/invitations", summary: "List the invitations a customer has not answered",
+			response: []models.Invitation{}, handler: s.handleInvitations},
+		{method: "POST", path: "/api/invitations/{id}/accept", summary: "Accept an invitation as the customer invited, named by X-Customer-ID",
+			response: models.Holder{}, handler: s.handleAcceptInvitation},
+		{method: "DELETE", path: "/api/invitations/{id}", summary: "Decline an invitation, or withdraw it as an admin",
+			response: models.Invitation{}, handler: s.handleDeclineInvitation},
+		{method: "POST", path: "/api/cases", summary: "Open a support case of a customer about an account and its entries, such as a disputed charge",
+			request: caseRequest{}, response: models.Case{}, status: http.StatusCreated, handler: s.handleOpenCase},
+		{method: "GET", path: "/api/cases", summary: "List support cases, oldest first",
+			response: []models.Case{}, handler: s.handleListCases, query: casesQuery},
+		{method: "GET", path: "/api/cases/{id}", summary: "Get a support case, its steps and comments",
+			response: models.Case{}, handler: s.handleGetCase},
+		{method: "POST", path: "/api/cases/{id}/assign", summary: "Assign a support case to a member of staff",
+			request: assignRequest{}, response: models.Case{}, handler: s.handleAssignCase},
+		{method: "POST", path: "/api/cases/{id}/state", summary: "Move a support case to another state, such as resolved",
+			request: caseStateRequest{}, response: models.Case{}, handler: s.handleMoveCase},
+		{method: "POST", path: "/api/cases/{id}/comments", summary: "Comment on a support case; a customer's answer puts a case waiting on them back under investigation",
+			request: commentRequest{}, response: models.Case{}, handler: s.handleCommentCase},
+		{method: "POST", path: "/api/sessions", summary: "Sign a customer in on a device, answering with the session's token for X-Session",
+			request: sessionRequest{}, response: sessionJSON{}, status: http.StatusCreated, handler: s.handleStartSession},
+		{method: "POST", path: "/api/sessions/{id}/step-up", summary: "Record that the customer of a session authenticated again, allowing high-risk operations for a while",
+			response: models.Session{}, handler: s.handleStepUp},
+		{method: "POST", path: "/api/sessions/{id}/second-factor", summary: "Step up a session with a code of a second factor, such as totp from an authenticator app",
+			request: secondFactorRequest{}, response: models.Session{}, handler: s.handleSecondFactor},
+		{method: "GET", path: "/api/customers/{id}/totp", summary: "Report whether a customer has an authenticator app for stepping up",
+			response: models.TOTPStatus{}, handler: s.handleTOTP},
+		{method: "POST", path: "/api/customers/{id}/totp", summary: "Enroll an authenticator app, answering with its secret and otpauth URL; it takes over once confirmed", stepUp: true,
+			response: models.TOTPEnrollment{}, status: http.StatusCreated, handler: s.handleEnrollTOTP},
+		{method: "POST", path: "/api/customers/{id}/totp/confirm", summary: "Confirm the authenticator app enrolled with one of its codes",
+			request: totpConfirmRequest{}, response: models.TOTPStatus{}, handler: s.handleConfirmTOTP},
+		{method: "DELETE", path: "/api/customers/{id}/totp", summary: "Remove a customer's authenticator app, such as of a lost phone", stepUp: true,
+			response: models.TOTPStatus{}, handler: s.handleRemoveTOTP},
+		{method: "GET", path: "/api/customers/{id}/sessions", summary: "List a customer's active sessions, the most recently seen first",
+			response: []models.Session{}, handler: s.handleSessions},
+		{method: "DELETE", path: "/api/customers/{id}/sessions/{session}", summary: "Revoke a session, such as of a lost device",
+			response: models.Session{}, handler: s.handleRevokeSession},
+		{method: "GET", path: "/api/keys", summary: "List the API keys issued, revoked and expired ones included, with their usage", permission: models.PermissionAdmin,
+			response: []models.APIKey{}, handler: s.handleAPIKeys},
+		{method: "POST", path: "/api/keys", summary: "Issue an API key of view, transact or admin scope, answering with the key for bearer authentication",
+			request: apiKeyRequest{}, response: apiKeyJSON{}, status: http.StatusCreated, handler: s.handleIssueAPIKey},
+		{method: "GET", path: "/api/keys/{id}", summary: "Get an API key and its usage", permission: models.PermissionAdmin,
+			response: models.APIKey{}, handler: s.handleAPIKey},
+		{method: "POST", path: "/api/keys/{id}/rotate", summary: "Replace an API key with a new one; the old one keeps working for a grace period",
+			request: rotateRequest{}, response: apiKeyJSON{}, status: http.StatusCreated, handler: s.handleRotateAPIKey},
+		{method: "DELETE", path: "/api/keys/{id}", summary: "Revoke an API key at once",
+			response: models.APIKey{}, handler: s.handleRevokeAPIKey},
+		{method: "POST", path: "/api/customers/{id}/consents", summary: "Let a third-party client read the balances or transactions of some of a customer's accounts until a date", stepUp: true,
+			request: consentRequest{}, response: models.Consent{}, status: http.StatusCreated, handler: s.handleGiveConsent},
+		{method: "GET", path: "/api/customers/{id}/consents", summary: "List the consents a customer gave, past ones included",
+			response: []models.Consent{}, handler: s.handleConsents},
+		{method: "DELETE", path: "/api/customers/{id}/consents/{consent}", summary: "Revoke a consent",
+			response: models.Consent{}, handler: s.handleRevokeConsent},
+		{method: "GET", path: "/api/customers/{id}/consents/{consent}/reads", summary: "List what the client of a consent read under it, and was refused, oldest first",
+			response: []models.AuditEntry{}, handler: s.handleConsentReads},
+		{method: "GET", path: "/api/consents/{consent}", summary: "Get a consent, as its client",
+			response: models.Consent{}, handler: s.consented("", s.handleConsent)},
+		{method: "GET", path: "/api/consents/{consent}/accounts/{number}", summary: "Get an account and its balance under a consent of balances scope, as its client",
+			response: accountJSON{}, handler: s.consented(models.ConsentBalances, s.handleGetAccount)},
+		{method: "GET", path: "/api/consents/{consent}/accounts/{number}/transactions", summary: "List the transactions of an account under a consent of transactions scope, as its client",
+			response: transactionPageJSON{}, handler: s.consented(models.ConsentTransactions, s.handleListTransactions), paged: true},
+		{method: "GET", path: "/api/customers/{id}/messages", summary: "List the messages in a customer's inbox, newest first, and how many are unread",
+			response: inboxJSON{}, handler: s.handleMessages, query: messagesQuery},
+		{method: "POST", path: "/api/customers/{id}/messages", summary: "Send a message to a customer's inbox and through the notification channels, such as a fraud review request",
+			request: messageRequest{}, response: models.Message{}, status: http.StatusCreated, handler: s.handleSendMessage},
+		{method: "POST", path: "/api/customers/{id}/messages/{message}/read", summary: "Mark a message read",
+			response: models.Message{}, handler: s.handleReadMessage},
+		{method: "DELETE", path: "/api/customers/{id}/messages/{message}/read", summary: "Mark a message unread again",
+			response: models.Message{}, handler: s.handleReadMessage},
+		{method: "DELETE", path: "/api/customers/{id}/messages/{message}", summary: "Delete a message from a customer's inbox, answering with the rest",
+			response: inboxJSON{}, handler: s.handleDeleteMessage},
+		{method: "POST", path: "/api/customers/{id}/grants", summary: "Delegate view or transact access to some of a customer's accounts until a date", stepUp: true,
+			request: grantRequest{}, response: models.Grant{}, status: http.StatusCreated, handler: s.handleDelegate},
+		{method: "GET", path: "/api/customers/{id}/grants", summary: "List the grants a customer gave or was given",
+			response: []models.Grant{}, handler: s.handleGrants},
+		{method: "POST", path: "/api/customers/{id}/estate", summary: "Report a customer's death, freezing the accounts they held alone except for interest", action: "freeze",
+			request: deathRequest{}, response: models.Estate{}, status: http.StatusCreated, handler: s.handleReportDeath},
+		{method: "GET", path: "/api/customers/{id}/estate", summary: "Get the estate of a deceased customer",
+			response: models.Estate{}, handler: s.handleGetEstate},
+		{method: "PUT", path: "/api/customers/{id}/estate/executor", summary: "Appoint the executor of an estate, with view access to its accounts",
+			request: executorRequest{}, response: models.Estate{}, handler: s.handleAppointExecutor},
+		{method: "POST", path: "/api/customers/{id}/estate/settle", summary: "Pay an estate out to an account and close its accounts, answering with their statements",
+			request: settleRequest{}, response: settlementJSON{}, handler: s.handleSettleEstate},
+		{method: "GET", path: "/api/customers/{id}/estate/statements", summary: "Get the statement package of a settled estate",
+			response: settlementJSON{}, handler: s.handleEstateStatements},
+		{method: "DELETE", path: "/api/grants/{id}", summary: "Revoke a grant, as its grantor or delegate",
+			response: models.Grant{}, handler: s.handleRevokeGrant},
+		{method: "GET", path: "/api/accounts/{number}/alerts", summary: "List the alert rules of an account",
+			response: []models.AlertRule{}, handler: s.handleAlertRules},
+		{method: "POST", path: "/api/accounts/{number}/alerts", summary: "Alert the holders of an account in their inboxes on a low balance, a large withdrawal or a day's spending over a threshold",
+			request: alertRuleRequest{}, response: models.AlertRule{}, status: http.StatusCreated, handler: s.handleAddAlertRule},
+		{method: "DELETE", path: "/api/accounts/{number}/alerts/{id}", summary: "Remove an alert rule of an account, answering with the rest",
+			response: []models.AlertRule{}, handler: s.handleRemoveAlertRule},
+		{method: "GET", path: "/api/accounts/{number}/controls", summary: "Get the ATM limit and allowed countries of an account, its overrides and what applies now",
+			response: controlsJSON{}, handler: s.handleGetControls},
+		{method: "PUT", path: "/api/accounts/{number}/controls", summary: "Set the usual ATM limit and allowed countries of an account", stepUp: true,
+			request: models.Controls{}, response: controlsJSON{}, handler: s.handleSetControls},
+		{method: "POST", path: "/api/accounts/{number}/overrides", summary: "Raise the ATM limit or allow countries until an end date, such as for travel", stepUp: true,
+			request: overrideRequest{}, response: models.Override{}, status: http.StatusCreated, handler: s.handleOverride},
+		{method: "DELETE", path: "/api/accounts/{number}/overrides/{id}", summary: "End an override before its end date",
+			response: models.Override{}, handler: s.handleCancelOverride},
+		{method: "GET", path: "/api/accounts/{number}/interest-audit", summary: "Recompute the interest posted to an account in a period and diff it against the ledger",
+			response: models.InterestAudit{}, handler: s.handleInterestAudit, query: interestAuditQuery},
+		{method: "GET", path: "/api/accounts/{number}/journal", summary: "List what the entries of an account posted to the bank's general ledger",
+			response: []models.JournalEntry{}, handler: s.handleJournal},
+		{method: "GET", path: "/api/accounts/{number}/delinquency", summary: "Get how far a loan or card is behind on its payments",
+			response: delinquencyJSON{}, handler: s.handleDelinquency},
+		{method: "GET", path: "/api/accounts/{number}/outstanding", summary: "Get what is owed on a loan or card in fees, interest and principal",
+			response: outstandingJSON{}, handler: s.handleOutstanding},
+		{method: "GET", path: "/api/accounts/{number}/statement", summary: "Get the statement of an account for the period of its cycle a date falls in",
+			response: statementJSON{}, handler: s.handleAccountStatement, query: statementQuery},
+		{method: "GET", path: "/api/accounts/{number}/statement-cycle", summary: "Get the statement cycle of an account",
+			response: models.StatementCycle{}, handler: s.handleGetCycle},
+		{method: "PUT", path: "/api/accounts/{number}/statement-cycle", summary: "Set the statement cycle of an account, starting now",
+			request: cycleRequest{}, response: models.StatementCycle{}, handler: s.handleSetCycle},
+		{method: "GET", path: "/api/accounts/{number}/time-zone", summary: "Get the time zone an account's entries, statements and daily limits are in",
+			response: timeZoneJSON{}, handler: s.handleGetTimeZone},
+		{method: "PUT", path: "/api/accounts/{number}/time-zone", summary: "Set the time zone of an account by its IANA name; an empty name follows the bank's zone",
+			request: timeZoneJSON{}, response: timeZoneJSON{}, handler: s.handleSetTimeZone},
+		{method: "GET", path: "/api/reports/delinquency", summary: "List the loans and cards that are behind on their payments, most overdue first",
+			response: []delinquencyJSON{}, handler: s.handleDelinquencyReport},
+		{method: "GET", path: "/api/reports/interest-audit", summary: "Recompute the interest posted to every account in a period and report the differences",
+			response: []models.InterestAudit{}, handler: s.handleInterestAuditReport, query: interestAuditQuery},
+		{method: "GET", path: "/api/reports/escheatment", summary: "List the long-dormant balances due as unclaimed property",
+			response: []models.Escheatment{}, handler: s.handleEscheatmentReport, query: escheatmentQuery},
+		{method: "GET", path: "/api/gl/accounts", summary: "List the bank's chart of accounts",
+			response: []models.GLAccount{}, handler: s.handleChartOfAccounts},
+		{method: "GET", path: "/api/reports/trial-balance", summary: "Sum the bank's general ledger by currency as a trial balance",
+			response: []models.TrialBalance{}, handler: s.handleTrialBalance, query: trialBalanceQuery},
+		{method: "GET", path: "/api/reports/balance-sheet", summary: "Report the assets, liabilities and equity of the bank's books by currency",
+			response: []models.BalanceSheet{}, handler: s.handleBalanceSheet, query: trialBalanceQuery},
+		{method: "GET", path: "/api/reports/income-statement", summary: "Report the income and expenses of the bank's books in a period by currency",
+			response: []models.IncomeStatement{}, handler: s.handleIncomeStatement, query: incomeStatementQuery},
+		{method: "GET", path: "/api/reports/reserves", summary: "Simulate the reserve requirement and capital ratios of the bank's books by currency",
+			response: []models.ReserveReport{}, handler: s.handleReserveReport, query: trialBalanceQuery},
+		{method: "GET", path: "/api/chaos", summary: "Get the faults injected, when fault injection is enabled",
+			response: chaosJSON{}, handler: s.handleGetChaos},
+		{method: "PUT", path: "/api/chaos", summary: "Switch injected faults on and off: failing store writes and webhooks, and clock skew",
+			request: chaosJSON{}, response: chaosJSON{}, handler: s.handleSetChaos},
+		{method: "GET", path: "/api/archive/accounts/{number}", summary: "Get an archived account and its ledger",
+			response: archivedAccountJSON{}, handler: s.handleGetArchivedAccount},
+		{method: "GET", path: "/api/accounts/{number}/transactions", summary: "List the transactions of an account, oldest first",
+			response: transactionPageJSON{}, handler: s.handleListTransactions, paged: true},
+		{method: "GET", path: "/api/accounts/{number}/transactions/enriched", summary: "List the transactions of an account, oldest first, with the merchants enrichment made of their counterparties",
+			response: enrichedPageJSON{}, handler: s.handleEnrichedTransactions, paged: true},
+		{method: "GET", path: "/api/enrichment/rules", summary: "List the merchant rules enrichment names the merchants of transactions by, the first that matches winning",
+			response: []models.MerchantRule{}, handler: s.handleMerchantRules},
+		{method: "PUT", path: "/api/enrichment/rules", summary: "Replace the merchant rules; transactions enriched before keep what they got until enrichment reruns",
+			request: []models.MerchantRule{}, response: []models.MerchantRule{}, handler: s.handleSetMerchantRules},
+		{method: "POST", path: "/api/enrichment/rerun", summary: "Enrich every transaction again, such as after the merchant rules improved, answering with how many changed",
+			response: reEnrichJSON{}, handler: s.handleReEnrich},
+		{method: "GET", path: "/api/transactions/search", summary: "Search transactions",
+			response: searchJSON{}, handler: s.handleSearch, paged: true,
+			query: map[string]string{"q": "Search query, such as type:withdrawal amount>=10 coffee"}},
+		{method: "GET", path: "/api/ledger/roots", summary: "List the latest published Merkle roots over the ledger, oldest first",
+			response: []models.LedgerRoot{}, handler: s.handleLedgerRoots},
+		{method: "POST", path: "/api/ledger/roots", summary: "Publish a Merkle root over the ledger as it is now",
+			response: models.LedgerRoot{}, status: http.StatusCreated, handler: s.handlePublishLedgerRoot},
+		{method: "GET", path: "/api/transactions/{id}/proof", summary: "Prove that an entry of an account the caller may view is in a published ledger root",
+			response: models.InclusionProof{}, handler: s.handleInclusionProof, query: proofQuery},
+		{method: "POST", path: "/api/ledger/proofs/verify", summary: "Check that an inclusion proof leads from its entry to its root",
+			request: models.InclusionProof{}, response: models.InclusionProof{}, handler: s.handleVerifyInclusion},
+		{method: "GET", path: "/api/payments/search", summary: "Full-text search of descriptions and counterparties, best match first",
+			response: []scoredMatchJSON{}, handler: s.handleTextSearch,
+			query: map[string]string{"q": "Words to look for", "limit": "How many results to return, 50 by default"}},
+		{method: "GET", path: "/api/projection", summary: "Project savings growth under random rates and deposits, as percentile bands per year",
+			response: projectionJSON{}, handler: s.handleProjection, query: projectionQuery},
+		{method: "GET", path: "/api/audit", summary: "List the operations asked of the bank, by whom and with what outcome, oldest first",
+			response: auditPageJSON{}, handler: s.handleAudit, query: auditQuery, paged: true},
+		{method: "GET", path: "/api/audit/export", summary: "Export the operations asked of the bank as CSV",
+			contentType: "text/csv", handler: s.handleAuditExport, query: auditQuery},
+		{method: "GET", path: "/api/events", summary: "List recent events, oldest first",
+			response: eventPageJSON{}, handler: s.handleListEvents, paged: true},
+		{method: "POST", path: "/api/accounts/{number}/deposits", summary: "Deposit into an account", permission: models.PermissionTransact, action: "deposit",
+			request: amountRequest{}, response: accountJSON{}, status: http.StatusCreated, handler: s.handleDeposit},
+		{method: "POST", path: "/api/accounts/{number}/withdrawals", summary: "Withdraw from an account", permission: models.PermissionTransact, action: "withdrawal",
+			request: amountRequest{}, response: accountJSON{}, status: http.StatusCreated, handler: s.handleWithdraw},
+		{method: "POST", path: "/api/payees/check", summary: "Check a payee name against the holders of an account before transferring to it: match, close_match with the holder's name, or no_match",
+			permission: models.PermissionView, request: payeeRequest{}, response: models.PayeeCheck{}, handler: s.handleCheckPayee},
+		{method: "POST", path: "/api/transfers", summary: "Transfer between two accounts, reporting those of them the caller may view", permission: models.PermissionTransact, action: "transfer",
+			request: transferRequest{}, response: []accountJSON{}, status: http.StatusCreated, handler: s.handleTransfer},
+		{method: "POST", path: "/api/accounts/{number}/deposits/dry-run", summary: "Report what a deposit would post and leave, or why it would fail, without making it", permission: models.PermissionTransact,
+			request: amountRequest{}, response: dryRunJSON{}, handler: s.handleDryRunDeposit},
+		{method: "POST", path: "/api/accounts/{number}/withdrawals/dry-run", summary: "Report what a withdrawal would post, charge and leave, or why it would fail, without making it", permission: models.PermissionTransact,
+			request: amountRequest{}, response: dryRunJSON{}, handler: s.handleDryRunWithdrawal},
+		{method: "POST", path: "/api/transfers/dry-run", summary: "Report what a transfer would post, charge and leave, or why it would fail, without making it", permission: models.PermissionTransact,
+			request: transferRequest{}, response: dryRunJSON{}, handler: s.handleDryRunTransfer},
+		{method: "POST", path: "/api/transfers/batch", summary: "Run many transfers and report the result of each", permission: models.PermissionTransact,
+			request: batchRequest{}, response: []batchResultJSON{}, handler: s.handleTransferBatch},
+		{method: "GET", path: "/api/accounts/{number}/rewards", summary: "Get the rewards program of an account, its rewards balance and entries",
+			response: models.Rewards{}, handler: s.handleRewards},
+		{method: "PUT", path: "/api/accounts/{number}/rewards", summary: "Enroll a checking or card account in a rewards program of points or cash back by category, or change it",
+			request: models.RewardsProgram{}, response: models.Rewards{}, handler: s.handleSetRewards},
+		{method: "POST", path: "/api/accounts/{number}/rewards/redemptions", summary: "Redeem points or cash back, crediting what they are worth to the account",
+			permission: models.PermissionTransact, request: redeemRequest{}, response: models.RewardsEntry{}, status: http.StatusCreated, handler: s.handleRedeemRewards},
+		{method: "GET", path: "/api/accounts/{number}/rewards/statement", summary: "Get the rewards statement of a month: what was earned by category and redeemed",
+			response: models.RewardsStatement{}, handler: s.handleRewardsStatement, query: map[string]string{"month": "The month, such as 2026-01; last month by default"}},
+		{method: "POST", path: "/api/accounts/{number}/installments", summary: "Split a transfer out of an account into installments, paid as they fall due under one plan reference",
+			permission: models.PermissionTransact, stepUp: true, request: installmentsRequest{}, response: models.InstallmentPlan{}, status: http.StatusCreated, handler: s.handlePlanInstallments},
+		{method: "GET", path: "/api/accounts/{number}/installments", summary: "List the installment plans out of an account, ended ones included, oldest first",
+			response: []models.InstallmentPlan{}, handler: s.handleInstallmentPlans},
+		{method: "GET", path: "/api/accounts/{number}/installments/{id}", summary: "Get an installment plan, how much of it is paid and the ledger entries that paid each installment",
+			response: models.InstallmentPlan{}, handler: s.handleInstallmentPlan},
+		{method: "DELETE", path: "/api/accounts/{number}/installments/{id}", summary: "Cancel the installments of a plan still to be paid; those paid stay paid",
+			permission: models.PermissionTransact, response: models.InstallmentPlan{}, handler: s.handleCancelInstallments},
+		{method: "POST", path: "/api/accounts/{number}/pending-transfers", summary: "Book a transfer out of an account that settles settlement_days business days later, holding its amount and fee until then",
+			permission: models.PermissionTransact, stepUp: true, request: bookRequest{}, response: models.PendingTransfer{}, status: http.StatusCreated, handler: s.handleBookTransfer},
+		{method: "GET", path: "/api/accounts/{number}/pending-transfers", summary: "List the transfers into or out of an account that are booked and not settled yet",
+			response: []models.PendingTransfer{}, handler: s.handlePendingTransfers},
+		{method: "DELETE", path: "/api/accounts/{number}/pending-transfers/{id}", summary: "Cancel a pending transfer out of an account, releasing its hold",
+			permission: models.PermissionTransact, response: models.PendingTransfer{}, handler: s.handleCancelPending},
+		{method: "POST", path: "/api/transfers/quotes", summary: "Quote a transfer, locking its exchange rate for a while", permission: models.PermissionTransact,
+			request: quoteRequest{}, response: models.TransferQuote{}, status: http.StatusCreated, handler: s.handleQuoteTransfer},
+		{method: "POST", path: "/api/transfers/preview", summary: "Itemize what a transfer costs, what the recipient gets and when, without quoting it", permission: models.PermissionTransact,
+			request: previewRequest{}, response: models.Preview{}, handler: s.handlePreviewTransfer},
+		{method: "POST", path: "/api/accounts/{number}/withdrawals/preview", summary: "Itemize what a withdrawal costs",
+			request: amountRequest{}, response: models.Preview{}, handler: s.handlePreviewWithdrawal},
+		{method: "GET", path: "/api/receipts/{reference}", summary: "Get the signed receipt of an operation by its reference, the transfer or entry ID",
+			response: models.Receipt{}, handler: s.handleGetReceipt},
+		{method: "POST", path: "/api/receipts/verify", summary: "Check that a receipt was issued by this bank and not altered",
+			request: models.Receipt{}, response: models.Receipt{}, handler: s.handleVerifyReceipt},
+		{method: "GET", path: "/api/signing-key", summary: "Get the public key statements and receipts are signed with, in their Signature-Ed25519 header",
+			response: signingKeyJSON{}, handler: s.handleSigningKey},
+		{method: "POST", path: "/api/transfers/quotes/{id}", summary: "Execute a transfer quote before it expires", permission: models.PermissionTransact, action: "transfer",
+			response: []accountJSON{}, status: http.StatusCreated, handler: s.handleExecuteQuote},
+	}
+}
+
+// handleListCustomers lists every customer to the bank, and only themselves
+// to a customer.
+func (s *Server) handleListCustomers(w http.ResponseWriter, r *http.Request) {
+	customers := []customerJSON{}
+	if holder := r.Header.Get(holderHeader); holder != "" {
+		if c, err := s.bank.Customer(holder); err == nil {
+			customers = append(customers, toCustomerJSON(c))
+		}
+		writeJSON(w, http.StatusOK, customers)
+		return
+	}
+	for _, c := range s.bank.Customers() {
+		customers = append(customers, toCustomerJSON(c))
+	}
+	writeJSON(w, http.StatusOK, customers)
+}
+
+func (s *Server) handleGetCustomer(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "details") {
+		return
+	}
+	customer, err := s.bank.Customer(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCustomerJSON(customer))
+}
+
+func (s *Server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	req, ok := pageRequest(w, r)
+	if !ok {
+		return
+	}
+	includeDeleted, err := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	if err != nil && r.URL.Query().Has("include_deleted") {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "include_deleted must be true or false"))
+		return
+	}
+	page, err := s.bank.AccountsPage(req, includeDeleted)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	viewable := s.viewable(r)
+	resp := accountPageJSON{Items: []accountJSON{}, NextCursor: page.NextCursor, HasMore: page.HasMore}
+	for _, account := range page.Items {
+		if !viewable(account.Number()) {
+			continue
+		}
+		if item, err := s.accountJSON(account.Number()); err == nil {
+			resp.Items = append(resp.Items, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := s.accountJSON(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (s *Server) handleCloseAccount(w http.ResponseWriter, r *http.Request) {
+	s.changeAccount(w, r, s.bank.CloseAccount)
+}
+
+func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	s.changeAccount(w, r, s.bank.DeleteAccount)
+}
+
+func (s *Server) handleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	s.changeAccount(w, r, s.bank.RestoreAccount)
+}
+
+// changeAccount applies change to the account of the path and answers with
+// the account as it is then.
+func (s *Server) changeAccount(w http.ResponseWriter, r *http.Request, change func(number string) error) {
+	number := r.PathValue("number")
+	if err := change(number); err != nil {
+		writeError(w, err)
+		return
+	}
+	account, err := s.accountJSON(number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (s *Server) handleGetArchivedAccount(w http.ResponseWriter, r *http.Request) {
+	archived, err := s.bank.GetArchivedAccount(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	account := archived.Account
+	writeJSON(w, http.StatusOK, archivedAccountJSON{
+		Number: account.Number(), Kind: models.KindOf(account),
+		Closed: archived.Closed, Archived: archived.Archived,
+		Transactions: append([]models.Transaction{}, account.History()...),
+	})
+}
+
+func (s *Server) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	req, ok := pageRequest(w, r)
+	if !ok {
+		return
+	}
+	page, err := s.bank.HistoryPage(r.PathValue("number"), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []models.Transaction{}
+	}
+	writeJSON(w, http.StatusOK, transactionPageJSON(page))
+}
+
+func (s *Server) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	req, ok := pageRequest(w, r)
+	if !ok {
+		return
+	}
+	page, err := s.bank.Log.Page(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	viewable := s.viewable(r)
+	resp := eventPageJSON{Items: []eventJSON{}, NextCursor: page.NextCursor, HasMore: page.HasMore}
+	for _, e := range page.Items {
+		if r.Header.Get(holderHeader) != "" && (e.AccountNumber == "" || !viewable(e.AccountNumber)) {
+			continue
+		}
+		resp.Items = append(resp.Items, eventJSON{ID: e.ID, Type: e.Type, Account: e.AccountNumber,
+			Message: e.Message, Transaction: e.Transaction, Time: e.Time})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// pageRequest reads the cursor and limit query parameters.
+func pageRequest(w http.ResponseWriter, r *http.Request) (models.PageRequest, bool) {
+	req := models.PageRequest{Cursor: r.URL.Query().Get("cursor")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, banking.New(banking.CodeInvalidArgument, "limit must be a number"))
+			return req, false
+		}
+		req.Size = n
+	}
+	return req, true
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	req, ok := pageRequest(w, r)
+	if !ok {
+		return
+	}
+	q, err := models.ParseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Cursor != "" {
+		q.After = req.Cursor
+	}
+	if req.Size != 0 {
+		q.Limit = req.Size
+	}
+	if q.Account != "" {
+		if err := s.authorizeAccount(r, q.Account, models.PermissionView); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	result, err := s.bank.Search(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	viewable := s.viewable(r)
+	page := searchJSON{Items: []matchJSON{}, NextCursor: result.NextCursor, HasMore: result.HasMore}
+	for _, m := range result.Items {
+		if viewable(m.Account) {
+			page.Items = append(page.Items, matchJSON(m))
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
+	limit := models.DefaultPageSize
+	if text := r.URL.Query().Get("limit"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n <= 0 || n > models.MaxPageSize {
+			writeError(w, banking.New(banking.CodeInvalidArgument, "limit must be a number between 1 and 500"))
+			return
+		}
+		limit = n
+	}
+	viewable := s.viewable(r)
+	results := []scoredMatchJSON{}
+	for _, m := range s.index.Search(r.URL.Query().Get("q"), limit) {
+		if !viewable(m.Account) {
+			continue
+		}
+		results = append(results, scoredMatchJSON{Account: m.Account, Transaction: m.Transaction, Score: m.Score})
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleDeposit(w http.ResponseWriter, r *http.Request) {
+	s.handleAmount(w, r, s.bank.Deposit)
+}
+
+func (s *Server) handleWithdraw(w http.ResponseWriter, r *http.Request) {
+	s.handleAmount(w, r, s.bank.Withdraw)
+}
+
+func (s *Server) handleAmount(w http.ResponseWriter, r *http.Request, op func(string, float64, ...models.TxOption) error) {
+	var req amountRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	number := r.PathValue("number")
+	opts := []models.TxOption{models.WithCounterparty(req.Counterparty), models.WithCategory(req.Category),
+		models.WithDescription(req.Description), models.WithTags(req.Tags...), byHolder(r)}
+	if req.ConfirmDuplicate {
+		opts = append(opts, models.ConfirmDuplicate())
+	}
+	err := op(number, req.Amount, opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	account, err := s.accountJSON(number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, account)
+}
+
+func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.From, &req.To); err != nil {
+		writeError(w, err)
+		return
+	}
+	opts := []models.TxOption{models.WithCategory(req.Category), byHolder(r)}
+	if req.ConfirmDuplicate {
+		opts = append(opts, models.ConfirmDuplicate())
+	}
+	if req.PayeeName != "" {
+		opts = append(opts, models.WithPayeeName(req.PayeeName))
+	}
+	if req.ConfirmPayee {
+		opts = append(opts, models.ConfirmPayee())
+	}
+	if err := s.bank.Transfer(req.From, req.To, req.Amount, opts...); err != nil {
+		writeError(w, err)
+		return
+	}
+	// The destination is only reported to those who may view it.
+	accounts := []accountJSON{}
+	viewable := s.viewable(r)
+	for _, number := range []string{req.From, req.To} {
+		if account, err := s.accountJSON(number); err == nil && viewable(number) {
+			accounts = append(accounts, account)
+		}
+	}
+	writeJSON(w, http.StatusCreated, accounts)
+}
+
+func (s *Server) handleTransferBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	// TransferBatch skips the middleware, so the holder is checked here.
+	requests := make([]models.TransferRequest, len(req.Transfers))
+	for i, t := range req.Transfers {
+		if err := s.resolve(&t.From, &t.To); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := s.authorizeAccount(r, t.From, models.PermissionTransact); err != nil {
+			writeError(w, err)
+			return
+		}
+		requests[i] = models.TransferRequest{From: t.From, To: t.To, Amount: t.Amount, Category: t.Category}
+	}
+	var opts []models.BatchOption
+	if req.AllOrNothing {
+		opts = append(opts, models.AllOrNothing())
+	}
+	if req.DryRun {
+		opts = append(opts, models.DryRun())
+	}
+
+	results := []batchResultJSON{}
+	for i, result := range s.bank.TransferBatch(requests, opts...) {
+		item := batchResultJSON{Transfer: req.Transfers[i], Succeeded: result.Succeeded()}
+		if result.Err != nil {
+			item.Error = &errorJSON{Error: result.Err.Error(), Code: banking.CodeOf(result.Err), Retryable: banking.IsRetryable(result.Err)}
+		} else {
+			item.Withdrawal, item.Deposit = &result.Withdrawal, &result.Deposit
+		}
+		results = append(results, item)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) accountJSON(number string) (accountJSON, error) {
+	account, err := s.bank.Account(number)
+	if err != nil {
+		return accountJSON{}, err
+	}
+	balance, err := s.balances.Balance(number)
+	if err != nil {
+		return accountJSON{}, err
+	}
+	available, err := s.bank.Available(number)
+	if err != nil {
+		return accountJSON{}, err
+	}
+	result := accountJSON{Number: number, Kind: models.KindOf(account), Product: models.ProductCodeOf(account), Currency: s.bank.AccountCurrency(account), Balance: balance, Available: available}
+	if closed := s.bank.ClosedAt(number); !closed.IsZero() {
+		result.Closed = &closed
+	}
+	if deleted := s.bank.DeletedAt(number); !deleted.IsZero() {
+		result.Deleted = &deleted
+	}
+	if dormant, ok := s.bank.Dormant(number); ok {
+		result.Dormant = &dormant
+	}
+	if alias, _ := s.bank.Alias(number); alias.Nickname != "" {
+		result.Alias = &alias
+	}
+	return result, nil
+}
+
+func toCustomerJSON(c *models.Customer) customerJSON {
+	accounts := append([]string{}, c.Accounts...)
+	return customerJSON{ID: c.ID, Name: c.Name, Accounts: accounts}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "invalid request body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// httpStatus maps error codes to HTTP status codes.
+var httpStatus = map[banking.Code]int{
+	banking.CodeInvalidArgument:   http.StatusBadRequest,
+	banking.CodeNotFound:          http.StatusNotFound,
+	banking.CodeConflict:          http.StatusConflict,
+	banking.CodeInsufficientFunds: http.StatusUnprocessableEntity,
+	banking.CodeLimitExceeded:     http.StatusUnprocessableEntity,
+	banking.CodeAccountFrozen:     http.StatusForbidden,
+	banking.CodePermissionDenied:  http.StatusForbidden,
+	banking.CodeFeatureDisabled:   http.StatusForbidden,
+	banking.CodeUnavailable:       http.StatusServiceUnavailable,
+	banking.CodeRateLimited:       http.StatusTooManyRequests,
+	banking.CodeUnauthenticated:   http.StatusUnauthorized,
+	banking.CodeInternal:          http.StatusInternalServerError,
+}
+
+// writeError answers with the status of err's code and the code itself in
+// the body, so clients need not parse messages.
+func writeError(w http.ResponseWriter, err error) {
+	code := banking.CodeOf(err)
+	status, ok := httpStatus[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, errorJSON{Error: err.Error(), Code: code, Retryable: banking.IsRetryable(err), Fields: banking.FieldsOf(err)})
+}
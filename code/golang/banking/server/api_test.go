@@ -0,0 +1,317 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/chaos"
+	"gsolano/banking/models"
+)
+
+// TestCustomerAccess checks that the bank sees every customer and a
+// customer only themselves.
+func TestCustomerAccess(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.AddCustomer(&models.Customer{ID: "c1", Name: "Ada"})
+	bank.AddCustomer(&models.Customer{ID: "c2", Name: "Grace"})
+	s := New(bank)
+	get := func(path, holder string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if holder != "" {
+			r.Header.Set(holderHeader, holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	for holder, want := range map[string]int{"": 2, "c1": 1} {
+		var customers []customerJSON
+		if err := json.Unmarshal(get("/api/customers", holder).Body.Bytes(), &customers); err != nil {
+			t.Fatal(err)
+		}
+		if len(customers) != want || holder != "" && customers[0].ID != holder {
+			t.Errorf("customers listed to %q = %+v, want %d", holder, customers, want)
+		}
+	}
+	for _, tt := range []struct {
+		holder string
+		want   int
+	}{{"c2", http.StatusForbidden}, {"c1", http.StatusOK}, {"", http.StatusOK}} {
+		if w := get("/api/customers/c1", tt.holder); w.Code != tt.want {
+			t.Errorf("GET /api/customers/c1 as %q = %d, want %d", tt.holder, w.Code, tt.want)
+		}
+	}
+}
+
+// TestAccountAccess checks that a customer can neither see nor move money
+// out of another customer's accounts through the routes that name accounts
+// in their body or list many of them.
+func TestAccountAccess(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Use(bank.AuthorizeHolders())
+	for _, c := range []struct{ id, number string }{{"c1", "S0001"}, {"c2", "S0002"}} {
+		bank.AddCustomer(&models.Customer{ID: c.id, Name: c.id})
+		if err := bank.OpenFor(c.id, &models.SavingsAccount{Account: models.Account{AccountNumber: c.number}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := bank.Deposit(c.number, 500, models.WithDescription("salary")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bank.PublishLedgerRoot()
+	quote, err := bank.QuoteTransfer("S0002", "S0001", 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(bank)
+	do := func(method, path, holder, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if holder != "" {
+			r.Header.Set(holderHeader, holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, tt := range []struct{ method, path, body string }{
+		{"POST", "/api/transfers/batch", `{"transfers": [{"from": "S0002", "to": "S0001", "amount": 10}]}`},
+		{"POST", "/api/transfers/quotes", `{"from": "S0002", "to": "S0001", "amount": 25}`},
+		{"POST", "/api/transfers/quotes/" + quote.ID, ""},
+		{"POST", "/api/accounts", `{"product": "savings", "customer": "c2", "number": "S0003"}`},
+		{"POST", "/api/ledger/roots", ""},
+		{"GET", "/api/reports/trial-balance", ""},
+		{"GET", "/api/reports/delinquency", ""},
+		{"POST", "/api/products/savings/rate-changes", `{"rate": 500, "effective": "2030-01-01T00:00:00Z"}`},
+		{"DELETE", "/api/rate-changes/1", ""},
+	} {
+		if w := do(tt.method, tt.path, "c1", tt.body); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s as c1 = %d %s, want 403", tt.method, tt.path, w.Code, w.Body)
+		}
+	}
+	for _, tt := range []struct {
+		body string
+		want []string
+	}{
+		{`{"from": "S0002", "to": "S0001", "amount": 10}`, nil},
+		{`{"from": "S0001", "to": "S0002", "amount": 10}`, []string{"S0001"}},
+	} {
+		var resp dryRunJSON
+		if err := json.Unmarshal(do("POST", "/api/transfers/dry-run", "c1", tt.body).Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		var entries, balances []string
+		for number := range resp.Entries {
+			entries = append(entries, number)
+		}
+		for number := range resp.Balances {
+			balances = append(balances, number)
+		}
+		if !reflect.DeepEqual(entries, tt.want) || !reflect.DeepEqual(balances, tt.want) {
+			t.Errorf("dry run of %s as c1 reports the accounts %v and %v, want %v", tt.body, entries, balances, tt.want)
+		}
+	}
+	s2, _ := bank.Account("S0002")
+	if s2.CheckBalance() != 500 {
+		t.Errorf("S0002 balance %.2f, want 500", s2.CheckBalance())
+	}
+
+	for _, path := range []string{"/api/accounts", "/api/events", "/api/transactions/search?q=salary", "/api/payments/search?q=salary"} {
+		if body := do("GET", path, "c1", "").Body.String(); strings.Contains(body, "S0002") || !strings.Contains(body, "S0001") {
+			t.Errorf("GET %s as c1 = %s, want S0001 only", path, body)
+		}
+		if body := do("GET", path, "", "").Body.String(); !strings.Contains(body, "S0002") {
+			t.Errorf("GET %s as the bank = %s, want S0002 too", path, body)
+		}
+	}
+
+	for _, tt := range []struct {
+		holder string
+		want   int
+	}{{"c1", http.StatusNotFound}, {"c2", http.StatusOK}, {"", http.StatusOK}} {
+		if w := do("GET", "/api/transactions/"+s2.History()[0].ID+"/proof", tt.holder, ""); w.Code != tt.want {
+			t.Errorf("proof of S0002's entry as %q = %d %s, want %d", tt.holder, w.Code, w.Body, tt.want)
+		}
+	}
+
+	var accounts []accountJSON
+	if err := json.Unmarshal(do("POST", "/api/transfers", "c1", `{"from": "S0001", "to": "S0002", "amount": 10}`).Body.Bytes(), &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Number != "S0001" {
+		t.Errorf("transfer to S0002 as c1 reports %+v, want S0001 only", accounts)
+	}
+}
+
+// TestChaosAccess checks that only the bank switches injected faults on.
+func TestChaosAccess(t *testing.T) {
+	models.SetOutput(io.Discard)
+	faults := &chaos.Faults{}
+	s := New(models.NewBank(), WithChaos(faults))
+	for _, tt := range []struct {
+		holder string
+		want   int
+		skew   time.Duration
+	}{{"c1", http.StatusForbidden, 0}, {"", http.StatusOK, 5 * time.Minute}} {
+		r := httptest.NewRequest("PUT", "/api/chaos", strings.NewReader(`{"skew": "5m"}`))
+		if tt.holder != "" {
+			r.Header.Set(holderHeader, tt.holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.want || faults.State().Skew != tt.skew {
+			t.Errorf("PUT /api/chaos as %q = %d with skew %s, want %d with %s", tt.holder, w.Code, faults.State().Skew, tt.want, tt.skew)
+		}
+	}
+}
+
+// TestPendingTransfers checks that a holder books, lists and cancels
+// transfers out of their own account only.
+func TestPendingTransfers(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Use(bank.AuthorizeHolders())
+	for _, c := range []struct{ id, number string }{{"c1", "S0001"}, {"c2", "S0002"}} {
+		bank.AddCustomer(&models.Customer{ID: c.id, Name: c.id})
+		if err := bank.OpenFor(c.id, &models.SavingsAccount{Account: models.Account{AccountNumber: c.number}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := bank.Deposit(c.number, 500); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(bank)
+	do := func(method, path, holder, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if holder != "" {
+			r.Header.Set(holderHeader, holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	book := `{"to": "S0001", "amount": 100, "settlement_days": 2}`
+	if w := do("POST", "/api/accounts/S0002/pending-transfers", "c1", book); w.Code != http.StatusForbidden {
+		t.Errorf("booking out of S0002 as c1 = %d %s, want 403", w.Code, w.Body)
+	}
+	w := do("POST", "/api/accounts/S0001/pending-transfers", "c1", `{"to": "S0002", "amount": 100, "settlement_days": 2}`)
+	var pt models.PendingTransfer
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &pt) != nil {
+		t.Fatalf("booking out of S0001 as c1 = %d %s, want 201", w.Code, w.Body)
+	}
+	if available, _ := bank.Available("S0001"); available != 400 {
+		t.Errorf("S0001 available %.2f after booking, want 400", available)
+	}
+	var pending []models.PendingTransfer
+	if err := json.Unmarshal(do("GET", "/api/accounts/S0002/pending-transfers", "c2", "").Body.Bytes(), &pending); err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != pt.ID {
+		t.Errorf("pending transfers of S0002 = %+v, want %d", pending, pt.ID)
+	}
+
+	cancel := "/pending-transfers/" + strconv.Itoa(pt.ID)
+	for _, tt := range []struct {
+		path, holder string
+		want         int
+	}{
+		{"/api/accounts/S0001" + cancel, "c2", http.StatusForbidden},
+		{"/api/accounts/S0002" + cancel, "c2", http.StatusNotFound},
+		{"/api/accounts/S0001" + cancel, "c1", http.StatusOK},
+		{"/api/accounts/S0001" + cancel, "c1", http.StatusNotFound},
+	} {
+		if w := do("DELETE", tt.path, tt.holder, ""); w.Code != tt.want {
+			t.Errorf("DELETE %s as %s = %d %s, want %d", tt.path, tt.holder, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+// TestAlertRoutes checks that holders configure the alert rules of their
+// own accounts only, and that servers without alerts refuse them.
+func TestAlertRoutes(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	for _, c := range []struct{ id, number string }{{"c1", "S0001"}, {"c2", "S0002"}} {
+		bank.AddCustomer(&models.Customer{ID: c.id, Name: c.id})
+		if err := bank.OpenFor(c.id, &models.SavingsAccount{Account: models.Account{AccountNumber: c.number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alerts := models.NewAlerts(bank, bank.NotifyAlert)
+	do := func(s *Server, method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set(holderHeader, "c1")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	s := New(bank, WithAlerts(alerts))
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/accounts/S0002/alerts", `{"kind": "low_balance", "threshold": 100}`, http.StatusForbidden},
+		{"POST", "/api/accounts/S0001/alerts", `{"kind": "birthday"}`, http.StatusBadRequest},
+		{"POST", "/api/accounts/S0001/alerts", `{"kind": "low_balance", "threshold": 100}`, http.StatusCreated},
+		{"GET", "/api/accounts/S0002/alerts", "", http.StatusForbidden},
+		{"DELETE", "/api/accounts/S0001/alerts/rule-2", "", http.StatusNotFound},
+	} {
+		if w := do(s, tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s as c1 = %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+	var rules []models.AlertRule
+	if err := json.Unmarshal(do(s, "GET", "/api/accounts/S0001/alerts", "").Body.Bytes(), &rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Kind != models.AlertLowBalance || rules[0].Threshold != 100 {
+		t.Errorf("rules of S0001 = %+v, want the low balance one", rules)
+	}
+	if w := do(s, "DELETE", "/api/accounts/S0001/alerts/"+rules[0].ID, ""); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("removing %s = %d %s, want 200 and no rules left", rules[0].ID, w.Code, w.Body)
+	}
+
+	if w := do(New(bank), "GET", "/api/accounts/S0001/alerts", ""); w.Code != http.StatusForbidden {
+		t.Errorf("GET alerts without WithAlerts = %d, want 403", w.Code)
+	}
+}
+
+// TestWriteError checks that errors answer with the HTTP status of their
+// code and carry the code and whether to retry in the body.
+func TestWriteError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{models.ErrAccountNotFound, http.StatusNotFound},
+		{fmt.Errorf("withdrawing: %w", models.ErrInsufficientFunds), http.StatusUnprocessableEntity},
+		{banking.New(banking.CodeRateLimited, "slow down"), http.StatusTooManyRequests},
+		{banking.New(banking.CodeFeatureDisabled, "off"), http.StatusForbidden},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	} {
+		w := httptest.NewRecorder()
+		writeError(w, tt.err)
+		var body errorJSON
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.want || body.Code != banking.CodeOf(tt.err) || body.Retryable != banking.IsRetryable(tt.err) || body.Error != tt.err.Error() {
+			t.Errorf("%v: %d %+v, want %d with code %s", tt.err, w.Code, body, tt.want, banking.CodeOf(tt.err))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+	"gsolano/banking/shared"
+)
+
+type apiKeyKey struct{}
+
+type apiKeyRequest struct {
+	Name      string            `json:"name"`
+	Client    string            `json:"client,omitempty"`
+	Scope     models.Permission `json:"scope"`
+	RateLimit float64           `json:"rate_limit,omitempty"`
+	RateBurst int               `json:"rate_burst,omitempty"`
+}
+
+// rotateRequest is how long the old key keeps working, such as "1h"; a
+// day by default.
+type rotateRequest struct {
+	Grace string `json:"grace,omitempty"`
+}
+
+type apiKeyJSON struct {
+	models.APIKey
+	// Key is only given out here, when it is issued.
+	Key string `json:"key"`
+}
+
+// statusWriter notes the status of a response. It passes hijacks through,
+// for WebSockets.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// withAPIKey authenticates a request by the API key it carries and counts
+// it toward the key's usage.
+func (s *Server) withAPIKey(w http.ResponseWriter, r *http.Request, token string, next http.HandlerFunc) {
+	key, err := s.bank.APIKeyByToken(token)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	next(sw, r.WithContext(context.WithValue(r.Context(), apiKeyKey{}, key)))
+	s.bank.RecordAPIKeyUse(key.ID, clientIP(r), sw.status >= 400, sw.status == http.StatusTooManyRequests)
+}
+
+// keyRate is the client a request is rate limited as and its rate: that of
+// its API key if it has one, or of its address.
+func (s *Server) keyRate(r *http.Request) (string, shared.Rate) {
+	key, ok := r.Context().Value(apiKeyKey{}).(models.APIKey)
+	if !ok {
+		return clientIP(r), s.rate
+	}
+	if key.RateLimit > 0 {
+		return "key:" + key.ID, shared.Rate{Limit: key.RateLimit, Burst: key.RateBurst}
+	}
+	return "key:" + key.ID, s.rate
+}
+
+// scoped refuses requests with an API key whose scope does not allow need.
+func scoped(need models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := r.Context().Value(apiKeyKey{}).(models.APIKey); ok && !key.Scope.Allows(need) {
+			writeError(w, banking.New(banking.CodePermissionDenied, "API key of "+string(key.Scope)+" scope may not "+r.Method+" "+r.URL.Path))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.APIKeys())
+}
+
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	key, err := s.bank.APIKey(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *Server) handleIssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	key, secret, err := s.bank.IssueAPIKey(models.APIKey{Name: req.Name, Client: req.Client, Scope: req.Scope, RateLimit: req.RateLimit, RateBurst: req.RateBurst})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiKeyJSON{APIKey: key, Key: secret})
+}
+
+func (s *Server) handleRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req rotateRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	var grace time.Duration
+	if req.Grace != "" {
+		var err error
+		if grace, err = time.ParseDuration(req.Grace); err != nil {
+			writeError(w, banking.New(banking.CodeInvalidArgument, "grace: "+err.Error()))
+			return
+		}
+	}
+	key, secret, err := s.bank.RotateAPIKey(r.PathValue("id"), grace)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiKeyJSON{APIKey: key, Key: secret})
+}
+
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	key, err := s.bank.RevokeAPIKey(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
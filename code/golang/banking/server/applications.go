@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+type applicationRequest struct {
+	Customer string `json:"customer"`
+	Product  string `json:"product"`
+	Number   string `json:"number"`
+}
+
+type kycRequest struct {
+	Passed bool   `json:"passed"`
+	Note   string `json:"note,omitempty"`
+}
+
+type fundRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+func (s *Server) handleApply(w http.ResponseWriter, r *http.Request) {
+	var req applicationRequest
+	if !readJSON(w, r, &req) || !ownCustomer(w, r, req.Customer, "applications") {
+		return
+	}
+	app, err := s.bank.Apply(req.Customer, req.Product, req.Number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, app)
+}
+
+func (s *Server) handleGetApplication(w http.ResponseWriter, r *http.Request) {
+	s.changeApplication(w, r, s.bank.Application)
+}
+
+func (s *Server) handleCustomerApplications(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !ownCustomer(w, r, id, "applications") {
+		return
+	}
+	if _, err := s.bank.Customer(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	apps := s.bank.Applications(id)
+	if apps == nil {
+		apps = []models.Application{}
+	}
+	writeJSON(w, http.StatusOK, apps)
+}
+
+func (s *Server) handleSubmitApplication(w http.ResponseWriter, r *http.Request) {
+	s.changeApplication(w, r, s.bank.SubmitApplication)
+}
+
+func (s *Server) handleCompleteKYC(w http.ResponseWriter, r *http.Request) {
+	var req kycRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	s.changeApplication(w, r, func(id int) (models.Application, error) {
+		return s.bank.CompleteKYC(id, req.Passed, req.Note)
+	})
+}
+
+func (s *Server) handleFundApplication(w http.ResponseWriter, r *http.Request) {
+	var req fundRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	s.changeApplication(w, r, func(id int) (models.Application, error) {
+		return s.bank.FundApplication(id, req.Amount)
+	})
+}
+
+func (s *Server) handleAbandonApplication(w http.ResponseWriter, r *http.Request) {
+	s.changeApplication(w, r, func(id int) (models.Application, error) {
+		return s.bank.AbandonApplication(id, "withdrawn")
+	})
+}
+
+// changeApplication applies change to the application of the path and
+// answers with the application as it is then. A customer may only change
+// their own applications.
+func (s *Server) changeApplication(w http.ResponseWriter, r *http.Request, change func(id int) (models.Application, error)) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "application id must be a number"))
+		return
+	}
+	app, err := s.bank.Application(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ownCustomer(w, r, app.Customer, "applications") {
+		return
+	}
+	app, err = change(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, app)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestApplicationAccess checks that customers can only take their own
+// applications through their steps, and that only the bank records the
+// outcome of the KYC checks.
+func TestApplicationAccess(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.AddCustomer(&models.Customer{ID: "c1", Name: "Ada"})
+	bank.AddCustomer(&models.Customer{ID: "c2", Name: "Grace"})
+	s := New(bank)
+	send := func(method, path, holder, body string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if holder != "" {
+			r.Header.Set(holderHeader, holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	steps := []struct {
+		method, path, holder, body string
+		want                       int
+	}{
+		{"POST", "/api/applications", "c2", `{"customer": "c1", "product": "checking", "number": "C1"}`, http.StatusForbidden},
+		{"POST", "/api/applications", "c1", `{"customer": "c1", "product": "checking", "number": "C1"}`, http.StatusCreated},
+		{"GET", "/api/applications/1", "c2", "", http.StatusForbidden},
+		{"GET", "/api/customers/c1/applications", "c2", "", http.StatusForbidden},
+		{"POST", "/api/applications/1/submit", "c2", "", http.StatusForbidden},
+		{"POST", "/api/applications/1/submit", "c1", "", http.StatusOK},
+		{"POST", "/api/applications/1/kyc", "c1", `{"passed": true}`, http.StatusForbidden},
+		{"POST", "/api/applications/1/kyc", "", `{"passed": true}`, http.StatusOK},
+		{"POST", "/api/applications/1/fund", "c2", `{"amount": 100}`, http.StatusForbidden},
+		{"DELETE", "/api/applications/1", "c2", "", http.StatusForbidden},
+		{"POST", "/api/applications/1/fund", "c1", `{"amount": 100}`, http.StatusOK},
+		{"GET", "/api/applications/1", "c1", "", http.StatusOK},
+		{"GET", "/api/customers/c1/applications", "c1", "", http.StatusOK},
+		{"GET", "/api/applications/9", "c1", "", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		if code := send(step.method, step.path, step.holder, step.body); code != step.want {
+			t.Errorf("%s %s as %q = %d, want %d", step.method, step.path, step.holder, code, step.want)
+		}
+	}
+	if balance, _ := bank.Balance("C1"); balance != 100 {
+		t.Errorf("C1 balance = %.2f, want 100", balance)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// operatorHeader names the member of staff, such as a teller, a request of
+// the bank is made by, for the audit log.
+const operatorHeader = "X-Operator"
+
+var auditQuery = map[string]string{
+	"operator":   "Member of staff, as named by X-Operator, to list the operations of",
+	"customer":   "ID of the customer, as named by X-Customer-ID, to list the operations of",
+	"client":     "Third-party client to list the reads under consents of",
+	"consent":    "ID of the consent to list the reads under",
+	"action":     "Action to list, such as deposit, withdrawal, transfer or freeze, or a route such as POST /api/accounts/{number}/close",
+	"account":    "Number of the account operated on, either side of a transfer",
+	"from":       "First day listed, such as 2024-01-01",
+	"to":         "Last day listed, such as 2024-12-31",
+	"min_amount": "Least amount moved, such as 10000",
+}
+
+type auditPageJSON struct {
+	Items      []models.AuditEntry `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	HasMore    bool                `json:"has_more"`
+}
+
+// audited records every request of route in the bank's audit log, with who
+// made it and its outcome. The entries of routes with an action of their
+// own, such as deposits and freezes, also get the account, counterparty and
+// amount of the request body; the action of others is their method and
+// path.
+func (s *Server) audited(route apiRoute, next http.HandlerFunc) http.HandlerFunc {
+	action := route.action
+	if action == "" {
+		action = route.method + " " + route.path
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if route.action != "" {
+			body, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		var req struct {
+			From   string  `json:"from"`
+			To     string  `json:"to"`
+			Amount float64 `json:"amount"`
+		}
+		if body != nil {
+			json.Unmarshal(body, &req)
+		}
+		e := models.AuditEntry{Time: s.bank.Clock.Now(), Operator: r.Header.Get(operatorHeader), Customer: r.Header.Get(holderHeader),
+			Action: action, Account: r.PathValue("number"), To: req.To, Amount: req.Amount, Status: rec.status}
+		if e.Account == "" {
+			e.Account = req.From
+		}
+		s.resolve(&e.Account, &e.To)
+		if rec.status >= 400 {
+			var failure struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(rec.body.Bytes(), &failure) != nil || failure.Error == "" {
+				failure.Error = http.StatusText(rec.status)
+			}
+			e.Error = failure.Error
+		}
+		s.bank.Audit.Record(e)
+	}
+}
+
+// auditFilter reads an audit query from the query parameters.
+func auditFilter(r *http.Request) (models.AuditQuery, error) {
+	q := r.URL.Query()
+	query := models.AuditQuery{Operator: q.Get("operator"), Customer: q.Get("customer"), Client: q.Get("client"), Action: q.Get("action"), Account: q.Get("account")}
+	var err error
+	if query.From, query.To, err = auditPeriod(q); err != nil {
+		return query, err
+	}
+	if err := parseQuery(q, "consent", &query.Consent); err != nil {
+		return query, err
+	}
+	return query, parseQuery(q, "min_amount", &query.MinAmount)
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	req, ok := pageRequest(w, r)
+	if !ok {
+		return
+	}
+	query, err := auditFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := s.bank.Audit.Page(query, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, auditPageJSON{Items: page.Items, NextCursor: page.NextCursor, HasMore: page.HasMore})
+}
+
+// handleAuditExport answers with every entry the query selects as CSV, for
+// spreadsheets and regulators.
+func (s *Server) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	query, err := auditFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "time", "operator", "customer", "client", "consent", "action", "account", "to", "amount", "status", "error"})
+	for _, e := range s.bank.Audit.Entries(query) {
+		consent := ""
+		if e.Consent != 0 {
+			consent = strconv.Itoa(e.Consent)
+		}
+		cw.Write([]string{strconv.FormatInt(e.ID, 10), e.Time.Format(time.RFC3339), e.Operator, e.Customer, e.Client, consent, e.Action,
+			e.Account, e.To, strconv.FormatFloat(e.Amount, 'f', 2, 64), strconv.Itoa(e.Status), e.Error})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var billsQuery = map[string]string{
+	"days": "How many days ahead to look, 30 by default and at most 366",
+}
+
+func (s *Server) handleUpcomingBills(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if err := parseQuery(r.URL.Query(), "days", &days); err != nil {
+		writeError(w, err)
+		return
+	}
+	if days < 1 || days > 366 {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "days must be from 1 to 366"))
+		return
+	}
+	history, err := s.bank.History(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	now := s.bank.Clock.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	bills := models.UpcomingBills(models.DetectRecurring(history), today, endOfDay(today.AddDate(0, 0, days)))
+	if bills == nil {
+		bills = []models.RecurringPayment{}
+	}
+	writeJSON(w, http.StatusOK, bills)
+}
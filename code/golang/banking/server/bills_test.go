@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/sim"
+)
+
+// TestUpcomingBills pays rent on the last day of three months and a gym
+// weekly and checks which of them the coming days are expected to bring.
+func TestUpcomingBills(t *testing.T) {
+	models.SetOutput(io.Discard)
+	clock := sim.NewClock(time.Date(2025, 11, 30, 9, 0, 0, 0, time.UTC))
+	bank := models.NewBank()
+	bank.Clock = clock
+	if err := bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1", Balance: 5000}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []time.Time{
+		time.Date(2025, 11, 30, 9, 0, 0, 0, time.UTC),
+		time.Date(2025, 12, 31, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+	} {
+		clock.Advance(day.Sub(clock.Now()))
+		bank.Withdraw("C1", 900, models.WithCounterparty("landlord"))
+	}
+	for i := 0; i < 3; i++ {
+		clock.Advance(7 * 24 * time.Hour)
+		bank.Withdraw("C1", 20, models.WithCounterparty("gym"))
+	}
+	s := New(bank)
+	get := func(path string) ([]models.RecurringPayment, int) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var bills []models.RecurringPayment
+		json.Unmarshal(w.Body.Bytes(), &bills)
+		return bills, w.Code
+	}
+
+	// Today is February 21; the gym is due on the 28th, as is the rent.
+	bills, code := get("/api/accounts/C1/bills?days=7")
+	if code != http.StatusOK || len(bills) != 2 || bills[0].Counterparty != "gym" || bills[1].Counterparty != "landlord" || bills[1].NextDue.Day() != 28 {
+		t.Errorf("bills of the next 7 days = %d %+v, want the gym and the rent on the 28th", code, bills)
+	}
+	if bills, _ := get("/api/accounts/C1/bills?days=6"); len(bills) != 0 {
+		t.Errorf("bills of the next 6 days = %+v, want none", bills)
+	}
+	if _, code := get("/api/accounts/C1/bills?days=0"); code != http.StatusBadRequest {
+		t.Errorf("days=0 = %d, want %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var casesQuery = map[string]string{
+	"customer": "ID of the customer to list the cases of",
+	"account":  "Number of the account to list the cases about",
+	"state":    "open, investigating, waiting_on_customer, resolved or closed",
+	"assignee": "Member of staff to list the cases assigned to",
+}
+
+type caseRequest struct {
+	Customer     string          `json:"customer"`
+	Kind         models.CaseKind `json:"kind"`
+	Account      string          `json:"account,omitempty"`
+	Transactions []string        `json:"transactions,omitempty"`
+	Subject      string          `json:"subject"`
+	Assignee     string          `json:"assignee,omitempty"`
+}
+
+type assignRequest struct {
+	Assignee string `json:"assignee"`
+}
+
+type caseStateRequest struct {
+	State models.CaseState `json:"state"`
+	Note  string           `json:"note,omitempty"`
+}
+
+type commentRequest struct {
+	Author   string `json:"author"`
+	Text     string `json:"text"`
+	Internal bool   `json:"internal,omitempty"`
+}
+
+// caseJSON is a case as the request may see it: without internal comments
+// for a customer named by X-Customer-ID.
+func caseJSON(r *http.Request, c models.Case) models.Case {
+	if r.Header.Get(holderHeader) != "" {
+		return c.Public()
+	}
+	return c
+}
+
+func (s *Server) handleOpenCase(w http.ResponseWriter, r *http.Request) {
+	var req caseRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if customer := r.Header.Get(holderHeader); customer != "" && (customer != req.Customer || req.Assignee != "") {
+		writeError(w, banking.New(banking.CodePermissionDenied, "customers open cases of their own, unassigned"))
+		return
+	}
+	c, err := s.bank.OpenCase(models.Case{Customer: req.Customer, Kind: req.Kind, Account: req.Account,
+		Transactions: req.Transactions, Subject: req.Subject, Assignee: req.Assignee})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, caseJSON(r, c))
+}
+
+func (s *Server) handleListCases(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := models.CaseFilter{Customer: q.Get("customer"), Account: q.Get("account"),
+		State: models.CaseState(q.Get("state")), Assignee: q.Get("assignee")}
+	if customer := r.Header.Get(holderHeader); customer != "" {
+		if filter.Customer != "" && filter.Customer != customer {
+			writeError(w, banking.New(banking.CodePermissionDenied, "cases of another customer"))
+			return
+		}
+		filter.Customer = customer
+	}
+	cases := s.bank.Cases(filter)
+	for i := range cases {
+		cases[i] = caseJSON(r, cases[i])
+	}
+	writeJSON(w, http.StatusOK, cases)
+}
+
+func (s *Server) handleGetCase(w http.ResponseWriter, r *http.Request) {
+	s.changeCase(w, r, s.bank.Case)
+}
+
+func (s *Server) handleAssignCase(w http.ResponseWriter, r *http.Request) {
+	var req assignRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	s.changeCase(w, r, func(id int) (models.Case, error) {
+		return s.bank.AssignCase(id, req.Assignee)
+	})
+}
+
+func (s *Server) handleMoveCase(w http.ResponseWriter, r *http.Request) {
+	var req caseStateRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	s.changeCase(w, r, func(id int) (models.Case, error) {
+		return s.bank.MoveCase(id, req.State, req.Note)
+	})
+}
+
+func (s *Server) handleCommentCase(w http.ResponseWriter, r *http.Request) {
+	var req commentRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if customer := r.Header.Get(holderHeader); customer != "" {
+		req.Author = customer
+	}
+	s.changeCase(w, r, func(id int) (models.Case, error) {
+		return s.bank.CommentCase(id, models.CaseComment{Author: req.Author, Text: req.Text, Internal: req.Internal})
+	})
+}
+
+// changeCase applies change to the case of the path, which a customer named
+// by X-Customer-ID must have opened, and answers with the case as it is
+// then.
+func (s *Server) changeCase(w http.ResponseWriter, r *http.Request, change func(id int) (models.Case, error)) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "case id must be a number"))
+		return
+	}
+	if customer := r.Header.Get(holderHeader); customer != "" {
+		if c, err := s.bank.Case(id); err != nil || c.Customer != customer {
+			writeError(w, models.ErrCaseNotFound)
+			return
+		}
+	}
+	c, err := change(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, caseJSON(r, c))
+}
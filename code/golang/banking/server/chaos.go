@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/chaos"
+)
+
+var ErrChaosDisabled = banking.New(banking.CodeFeatureDisabled, "fault injection is not enabled")
+
+// WithChaos lets API clients switch the faults of f on and off, for
+// sandboxes and resilience tests. Without it the chaos endpoints answer
+// 403.
+func WithChaos(f *chaos.Faults) Option {
+	return func(s *Server) { s.chaos = f }
+}
+
+// chaosJSON is what is injected. Failing maps each fault point switched on
+// to how many more calls fail there, 0 for every call until it is switched
+// off; Skew, such as "-5m", is how far the bank's clock runs ahead.
+type chaosJSON struct {
+	Failing map[chaos.Point]int `json:"failing"`
+	Skew    string              `json:"skew,omitempty"`
+}
+
+func (s *Server) handleGetChaos(w http.ResponseWriter, r *http.Request) {
+	if s.chaos == nil {
+		writeError(w, ErrChaosDisabled)
+		return
+	}
+	state := s.chaos.State()
+	resp := chaosJSON{Failing: state.Failing}
+	if state.Skew != 0 {
+		resp.Skew = state.Skew.String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleSetChaos(w http.ResponseWriter, r *http.Request) {
+	if s.chaos == nil {
+		writeError(w, ErrChaosDisabled)
+		return
+	}
+	var req chaosJSON
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	state := chaos.State{Failing: req.Failing}
+	if req.Skew != "" {
+		skew, err := time.ParseDuration(req.Skew)
+		if err != nil {
+			writeError(w, banking.New(banking.CodeInvalidArgument, "skew: "+err.Error()))
+			return
+		}
+		state.Skew = skew
+	}
+	if err := s.chaos.Set(state); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleGetChaos(w, r)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+type consentRequest struct {
+	Client   string                `json:"client"`
+	Accounts []string              `json:"accounts"`
+	Scopes   []models.ConsentScope `json:"scopes"`
+	Expires  time.Time             `json:"expires,omitempty"`
+}
+
+// ownConsents refuses requests by a customer for the consents of another.
+func ownConsents(w http.ResponseWriter, r *http.Request) bool {
+	if customer := r.Header.Get(holderHeader); customer != "" && customer != r.PathValue("id") {
+		writeError(w, banking.New(banking.CodePermissionDenied, "consents of another customer"))
+		return false
+	}
+	return true
+}
+
+// consentID reads the {consent} of the path.
+func consentID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("consent"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "consent id must be a number"))
+		return 0, false
+	}
+	return id, true
+}
+
+// consented lets a third-party client read under the {consent} of the path
+// what scope says of its {number} account, if any. The client is that of
+// the request's API key; other requests are refused, the bank reading
+// accounts through the routes of its own.
+func (s *Server) consented(scope models.ConsentScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := r.Context().Value(apiKeyKey{}).(models.APIKey)
+		if !ok || key.Client == "" {
+			writeError(w, banking.New(banking.CodePermissionDenied, "consents are read with the API key of their client"))
+			return
+		}
+		id, ok := consentID(w, r)
+		if !ok {
+			return
+		}
+		if _, err := s.bank.ConsentedRead(id, key.Client, r.PathValue("number"), scope); err != nil {
+			writeError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// consentRoute reports whether path is read by clients under a consent.
+func consentRoute(path string) bool {
+	return strings.HasPrefix(path, "/api/consents/")
+}
+
+// noClient refuses requests with the API key of a third-party client, whose
+// key only reads under consents: it is no key of the bank's, whatever the
+// request says it acts for.
+func noClient(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := r.Context().Value(apiKeyKey{}).(models.APIKey); ok && key.Client != "" {
+			writeError(w, banking.New(banking.CodePermissionDenied, "the API key of client "+key.Client+" only reads under its consents"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleGiveConsent(w http.ResponseWriter, r *http.Request) {
+	var req consentRequest
+	if !ownConsents(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	refs := make([]*string, len(req.Accounts))
+	for i := range req.Accounts {
+		refs[i] = &req.Accounts[i]
+	}
+	if err := s.resolve(refs...); err != nil {
+		writeError(w, err)
+		return
+	}
+	consent, err := s.bank.GiveConsent(models.Consent{Customer: r.PathValue("id"), Client: req.Client,
+		Accounts: req.Accounts, Scopes: req.Scopes, Expires: req.Expires})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, consent)
+}
+
+func (s *Server) handleConsents(w http.ResponseWriter, r *http.Request) {
+	if !ownConsents(w, r) {
+		return
+	}
+	consents, err := s.bank.Consents(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, consents)
+}
+
+func (s *Server) handleRevokeConsent(w http.ResponseWriter, r *http.Request) {
+	id, ok := consentID(w, r)
+	if !ok || !ownConsents(w, r) {
+		return
+	}
+	consent, err := s.bank.RevokeConsent(r.PathValue("id"), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, consent)
+}
+
+// handleConsentReads answers with what the client of a consent read under
+// it, and what it was refused, oldest first.
+func (s *Server) handleConsentReads(w http.ResponseWriter, r *http.Request) {
+	id, ok := consentID(w, r)
+	if !ok || !ownConsents(w, r) {
+		return
+	}
+	consent, err := s.bank.Consent(id)
+	if err != nil || consent.Customer != r.PathValue("id") {
+		writeError(w, models.ErrConsentNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.Audit.Entries(models.AuditQuery{Consent: id, Client: consent.Client}))
+}
+
+func (s *Server) handleConsent(w http.ResponseWriter, r *http.Request) {
+	id, _ := consentID(w, r)
+	consent, err := s.bank.Consent(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, consent)
+}
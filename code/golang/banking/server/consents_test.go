@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestClientKey checks that the API key of a third-party client reads
+// what it was consented to and nothing else, whether or not it claims to
+// act for a customer.
+func TestClientKey(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}})
+	bank.Deposit("C1", 100)
+	bank.AddCustomer(&models.Customer{ID: "c1", Name: "Ada", Accounts: []string{"C1"}})
+	_, key, err := bank.IssueAPIKey(models.APIKey{Name: "budget app", Client: "budgetapp", Scope: models.PermissionAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consent, err := bank.GiveConsent(models.Consent{Customer: "c1", Client: "budgetapp", Accounts: []string{"C1"}, Scopes: []models.ConsentScope{models.ConsentBalances}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(bank)
+
+	tests := []struct {
+		method, path, holder string
+		want                 int
+	}{
+		{"GET", fmt.Sprintf("/api/consents/%d/accounts/C1", consent.ID), "", http.StatusOK},
+		{"GET", "/api/accounts/C1", "", http.StatusForbidden},
+		{"GET", "/api/accounts/C1", "c1", http.StatusForbidden},
+		{"GET", "/api/accounts", "", http.StatusForbidden},
+		{"POST", "/api/customers/c1/estate", "", http.StatusForbidden},
+		{"GET", "/api/keys", "", http.StatusForbidden},
+		{"POST", "/graphql", "", http.StatusForbidden},
+		{"GET", "/ws/accounts/C1", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"amount": 10, "query": "{ accounts { number } }"}`))
+		r.Header.Set("Authorization", "Bearer "+key)
+		if tt.holder != "" {
+			r.Header.Set(holderHeader, tt.holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s as %q = %d %s, want %d", tt.method, tt.path, tt.holder, w.Code, w.Body, tt.want)
+		}
+	}
+	if balance, _ := bank.Balance("C1"); balance != 100 {
+		t.Errorf("C1 balance = %.2f, want 100", balance)
+	}
+}
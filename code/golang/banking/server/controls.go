@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+// controlsJSON is an account's usual controls, those that apply now with
+// its active overrides, and its overrides, past ones included.
+type controlsJSON struct {
+	Controls  models.Controls   `json:"controls"`
+	Effective models.Controls   `json:"effective"`
+	Overrides []models.Override `json:"overrides"`
+}
+
+type overrideRequest struct {
+	Start     time.Time `json:"start,omitempty"`
+	End       time.Time `json:"end"`
+	ATMDaily  float64   `json:"atm_daily,omitempty"`
+	Countries []string  `json:"countries,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func (s *Server) handleGetControls(w http.ResponseWriter, r *http.Request) {
+	usual, effective, overrides, err := s.bank.Controls(r.PathValue("number"), s.bank.Clock.Now())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if overrides == nil {
+		overrides = []models.Override{}
+	}
+	writeJSON(w, http.StatusOK, controlsJSON{usual, effective, overrides})
+}
+
+func (s *Server) handleSetControls(w http.ResponseWriter, r *http.Request) {
+	var controls models.Controls
+	if !readJSON(w, r, &controls) {
+		return
+	}
+	if err := s.bank.SetControls(r.PathValue("number"), controls); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleGetControls(w, r)
+}
+
+func (s *Server) handleOverride(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	o, err := s.bank.Override(r.PathValue("number"), models.Override{
+		Start: req.Start, End: req.End, ATMDaily: req.ATMDaily, Countries: req.Countries, Reason: req.Reason})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, o)
+}
+
+func (s *Server) handleCancelOverride(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "override id must be a number"))
+		return
+	}
+	o, err := s.bank.CancelOverride(r.PathValue("number"), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+type creditJSON struct {
+	Score              int      `json:"score"`
+	MonthlyIncome      float64  `json:"monthly_income"`
+	IncomeRegularity   float64  `json:"income_regularity"`
+	MonthlyObligations float64  `json:"monthly_obligations"`
+	OverdraftMonths    int      `json:"overdraft_months"`
+	Reasons            []string `json:"reasons"`
+	// MaxLoan is the offer at the rate and term asked for.
+	MaxLoan float64 `json:"max_loan"`
+}
+
+type loanRequest struct {
+	Number     string     `json:"number"`
+	Principal  float64    `json:"principal"`
+	Rate       money.Rate `json:"rate"`
+	TermMonths int        `json:"term_months"`
+	DisburseTo string     `json:"disburse_to"`
+	// DayCount charges interest by the day under ACT/365, ACT/360 or
+	// 30/360 rather than a month at a time.
+	DayCount money.DayCount `json:"day_count,omitempty"`
+}
+
+type loanJSON struct {
+	accountJSON
+	Principal   float64        `json:"principal"`
+	Rate        money.Rate     `json:"rate"`
+	TermMonths  int            `json:"term_months"`
+	Installment float64        `json:"installment"`
+	APR         money.Rate     `json:"apr"`
+	Effective   money.Rate     `json:"effective_rate"`
+	Opened      time.Time      `json:"opened"`
+	DayCount    money.DayCount `json:"day_count,omitempty"`
+}
+
+var creditQuery = map[string]string{
+	"rate":        "Annual rate of the loan the offer is for, 8% by default",
+	"term_months": "Term of the loan the offer is for, 36 by default",
+}
+
+func toCreditJSON(a models.CreditAssessment, rate money.Rate, term int) creditJSON {
+	reasons := append([]string{}, a.Reasons...)
+	return creditJSON{
+		Score: a.Score, MonthlyIncome: a.MonthlyIncome, IncomeRegularity: a.IncomeRegularity,
+		MonthlyObligations: a.MonthlyObligations, OverdraftMonths: a.OverdraftMonths,
+		Reasons: reasons, MaxLoan: a.MaxLoan(rate, term),
+	}
+}
+
+func (s *Server) handleCredit(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "credit scores") {
+		return
+	}
+	rate, term := money.Percent(8), 36
+	q := r.URL.Query()
+	if err := parseQuery(q, "rate", &rate); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := parseQuery(q, "term_months", &term); err != nil {
+		writeError(w, err)
+		return
+	}
+	assessment, err := s.bank.AssessCredit(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCreditJSON(assessment, rate, term))
+}
+
+func (s *Server) handleOpenLoan(w http.ResponseWriter, r *http.Request) {
+	var req loanRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.DisburseTo); err != nil {
+		writeError(w, err)
+		return
+	}
+	loan := &models.LoanAccount{
+		Account:   models.Account{AccountNumber: req.Number},
+		Principal: req.Principal, InterestRate: req.Rate, TermMonths: req.TermMonths, DayCount: req.DayCount,
+	}
+	if _, err := s.bank.OpenLoan(r.PathValue("id"), loan, req.DisburseTo); err != nil {
+		writeError(w, err)
+		return
+	}
+	account, err := s.accountJSON(loan.Number())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, loanJSON{
+		accountJSON: account, Principal: loan.Principal, Rate: loan.InterestRate,
+		TermMonths: loan.TermMonths, Installment: loan.Installment(),
+		APR: loan.APR(), Effective: loan.EffectiveRate(), Opened: loan.Opened, DayCount: loan.DayCount,
+	})
+}
+
+type delinquencyJSON struct {
+	Account      string                   `json:"account"`
+	Bucket       models.DelinquencyBucket `json:"bucket"`
+	DaysPastDue  int                      `json:"days_past_due"`
+	PastDue      float64                  `json:"past_due"`
+	Missed       int                      `json:"missed"`
+	OldestMissed *time.Time               `json:"oldest_missed,omitempty"`
+}
+
+func toDelinquencyJSON(d models.Delinquency) delinquencyJSON {
+	j := delinquencyJSON{Account: d.Account, Bucket: d.Bucket, DaysPastDue: d.DaysPastDue, PastDue: d.PastDue, Missed: d.Missed}
+	if !d.OldestMissed.IsZero() {
+		j.OldestMissed = &d.OldestMissed
+	}
+	return j
+}
+
+func (s *Server) handleDelinquency(w http.ResponseWriter, r *http.Request) {
+	d, err := s.bank.Delinquency(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDelinquencyJSON(d))
+}
+
+func (s *Server) handleDelinquencyReport(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	report := []delinquencyJSON{}
+	for _, d := range s.bank.DelinquencyReport() {
+		report = append(report, toDelinquencyJSON(d))
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+type outstandingJSON struct {
+	Fees      float64 `json:"fees"`
+	Interest  float64 `json:"interest"`
+	Principal float64 `json:"principal"`
+}
+
+func (s *Server) handleOutstanding(w http.ResponseWriter, r *http.Request) {
+	o, err := s.bank.Outstanding(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, outstandingJSON{Fees: o.Fees, Interest: o.Interest, Principal: o.Principal})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestCreditAccess checks that a customer may only read their own credit
+// score and that only the bank opens loans.
+func TestCreditAccess(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	for _, c := range []struct{ id, number string }{{"c1", "C1"}, {"c2", "C2"}} {
+		bank.AddCustomer(&models.Customer{ID: c.id, Name: c.id})
+		if err := bank.OpenFor(c.id, &models.CheckingAccount{Account: models.Account{AccountNumber: c.number}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(bank)
+	loan := `{"number": "L1", "principal": 1000, "rate": "8%", "term_months": 12, "disburse_to": "C1"}`
+	tests := []struct {
+		method, path, holder, body string
+		want                       int
+	}{
+		{"GET", "/api/customers/c1/credit", "c2", "", http.StatusForbidden},
+		{"GET", "/api/customers/c1/credit", "c1", "", http.StatusOK},
+		{"GET", "/api/customers/c1/credit", "", "", http.StatusOK},
+		{"POST", "/api/customers/c1/loans", "c1", loan, http.StatusForbidden},
+		{"POST", "/api/customers/c1/loans", "c2", loan, http.StatusForbidden},
+		// Without income the bank's own request is declined.
+		{"POST", "/api/customers/c1/loans", "", loan, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.holder != "" {
+			r.Header.Set(holderHeader, tt.holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s as %q = %d %s, want %d", tt.method, tt.path, tt.holder, w.Code, w.Body, tt.want)
+		}
+	}
+}
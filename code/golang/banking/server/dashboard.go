@@ -0,0 +1,166 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gsolano/banking/i18n"
+	"gsolano/banking/models"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// templates are cloned per Server, which binds money to its locale.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"money": func(float64) string { return "" },
+}).ParseFS(templateFS, "templates/*.html"))
+
+type accountView struct {
+	Number    string
+	Alias     models.Alias
+	Kind      string
+	Balance   float64
+	Available float64
+}
+
+type dashboardPage struct {
+	Accounts []accountView
+	Message  string
+	Error    string
+	CSRF     string
+}
+
+type searchPage struct {
+	Query   string
+	Results []models.ScoredMatch
+}
+
+// searchResults is how many payments the search page shows.
+const searchResults = 50
+
+type statementPage struct {
+	Account      accountView
+	Statement    models.Statement
+	Transactions []models.Transaction
+	// Previous and Next are dates in the periods around the statement's.
+	Previous, Next string
+}
+
+func (s *Server) parseTemplates() *template.Template {
+	return template.Must(templates.Clone()).Funcs(template.FuncMap{
+		"money": func(amount float64) string { return i18n.FormatAmount(s.locale, amount) },
+	})
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	page := dashboardPage{
+		Accounts: s.accountViews(),
+		Message:  r.URL.Query().Get("message"),
+		Error:    r.URL.Query().Get("error"),
+		CSRF:     s.csrf,
+	}
+	s.render(w, "dashboard.html", page)
+}
+
+func (s *Server) handleSearchPage(w http.ResponseWriter, r *http.Request) {
+	page := searchPage{Query: r.URL.Query().Get("q")}
+	if page.Query != "" {
+		page.Results = s.index.Search(page.Query, searchResults)
+	}
+	s.render(w, "search.html", page)
+}
+
+func (s *Server) handleStatement(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+	account, err := s.bank.Account(number)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	at := time.Now()
+	if err := parseQuery(r.URL.Query(), "at", &at); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	statement, err := s.bank.Statement(number, at)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	// Newest first reads more naturally on a statement page.
+	history := append([]models.Transaction(nil), statement.Transactions...)
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	page := statementPage{
+		Account:      s.accountView(account),
+		Statement:    statement,
+		Transactions: history,
+		Next:         statement.Period.End.Add(time.Hour).Format(time.DateOnly),
+	}
+	if !statement.Period.Partial {
+		page.Previous = statement.Period.Start.Format(time.DateOnly)
+	}
+	s.render(w, "statement.html", page)
+}
+
+// newCSRFToken makes the token the dashboard's forms post back, which
+// other sites cannot read to forge them.
+func newCSRFToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) handleTransferForm(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("csrf")), []byte(s.csrf)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	from, to := r.FormValue("from"), r.FormValue("to")
+	amount, err := strconv.ParseFloat(r.FormValue("amount"), 64)
+	if err == nil {
+		err = s.bank.Transfer(from, to, amount)
+	}
+
+	query := url.Values{}
+	if err != nil {
+		query.Set("error", "Transfer failed: "+err.Error())
+	} else {
+		query.Set("message", "Transfer completed.")
+	}
+	http.Redirect(w, r, "/?"+query.Encode(), http.StatusSeeOther)
+}
+
+func (s *Server) accountViews() []accountView {
+	var views []accountView
+	for _, account := range s.bank.ListAccounts(false) {
+		views = append(views, s.accountView(account))
+	}
+	return views
+}
+
+func (s *Server) accountView(account models.BankAccount) accountView {
+	balance, _ := s.balances.Balance(account.Number())
+	available, _ := s.bank.Available(account.Number())
+	alias, _ := s.bank.Alias(account.Number())
+	return accountView{Number: account.Number(), Alias: alias, Kind: models.KindOf(account), Balance: balance, Available: available}
+}
+
+func (s *Server) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("render %s: %v", name, err)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestDashboard checks that the dashboard is only served when asked for,
+// and that its transfer form refuses posts without its CSRF token.
+func TestDashboard(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}})
+	bank.Open(&models.SavingsAccount{Account: models.Account{AccountNumber: "S1"}})
+	bank.Deposit("C1", 100)
+
+	for _, path := range []string{"/", "/accounts/C1", "/search"} {
+		w := httptest.NewRecorder()
+		New(bank).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s without the dashboard = %d, want 404", path, w.Code)
+		}
+	}
+
+	s := New(bank, WithDashboard())
+	post := func(form url.Values) int {
+		r := httptest.NewRequest("POST", "/transfer", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+	form := url.Values{"from": {"C1"}, "to": {"S1"}, "amount": {"10"}}
+	if code := post(form); code != http.StatusForbidden {
+		t.Errorf("transfer without the CSRF token = %d, want 403", code)
+	}
+	form.Set("csrf", "guess")
+	if code := post(form); code != http.StatusForbidden {
+		t.Errorf("transfer with a wrong CSRF token = %d, want 403", code)
+	}
+	if balance, _ := bank.Balance("S1"); balance != 0 {
+		t.Fatalf("forged transfers moved %.2f", balance)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), s.csrf) {
+		t.Fatal("dashboard does not carry the CSRF token")
+	}
+	form.Set("csrf", s.csrf)
+	if code := post(form); code != http.StatusSeeOther {
+		t.Errorf("transfer with the CSRF token = %d, want 303", code)
+	}
+	if balance, _ := bank.Balance("S1"); balance != 10 {
+		t.Errorf("S1 balance = %.2f, want 10", balance)
+	}
+}
@@ -0,0 +1,67 @@
+// Renders the OpenAPI document of the server: every operation with its
+// parameters, request and responses, and every schema they refer to.
+(async function () {
+  const doc = await (await fetch("/openapi.json")).json();
+  const el = (tag, attrs, ...children) => {
+    const e = document.createElement(tag);
+    Object.assign(e, attrs);
+    e.append(...children);
+    return e;
+  };
+  const schemaName = (ref) => ref.replace("#/components/schemas/", "");
+  const schemaView = (schema) => {
+    if (!schema) return "";
+    if (schema.$ref) {
+      return el("a", {href: "#schema-" + schemaName(schema.$ref)}, schemaName(schema.$ref));
+    }
+    if (schema.type === "array") {
+      return el("span", {}, "array of ", schemaView(schema.items));
+    }
+    if (schema.type === "object" && schema.additionalProperties) {
+      return el("span", {}, "map of ", schemaView(schema.additionalProperties));
+    }
+    return schema.format ? schema.type + " (" + schema.format + ")" : schema.type || "any";
+  };
+  const bodyView = (content) => schemaView(content && content["application/json"].schema);
+
+  const operations = document.getElementById("operations");
+  for (const path of Object.keys(doc.paths).sort()) {
+    for (const [method, op] of Object.entries(doc.paths[path])) {
+      const details = el("details", {},
+        el("summary", {},
+          el("span", {className: "method " + method}, method.toUpperCase()),
+          el("span", {className: "path"}, path), " ", op.summary));
+      if (op.parameters) {
+        const rows = op.parameters.map((p) => el("tr", {},
+          el("td", {}, el("code", {}, p.name)), el("td", {}, p.in),
+          el("td", {}, p.required ? "required" : ""), el("td", {}, p.description || "")));
+        details.append(el("table", {}, el("tr", {}, el("th", {}, "Parameter"), el("th", {}, "In"), el("th"), el("th", {}, "Description")), ...rows));
+      }
+      if (op.requestBody) {
+        details.append(el("p", {}, "Request: ", bodyView(op.requestBody.content)));
+      }
+      for (const [status, response] of Object.entries(op.responses)) {
+        details.append(el("p", {}, status + " " + response.description + ": ", bodyView(response.content)));
+      }
+      operations.append(details);
+    }
+  }
+
+  const schemas = document.getElementById("schemas");
+  for (const name of Object.keys(doc.components.schemas).sort()) {
+    const schema = doc.components.schemas[name];
+    const required = new Set(schema.required || []);
+    const rows = Object.keys(schema.properties || {}).sort().map((field) => el("tr", {},
+      el("td", {}, el("code", {}, field)), el("td", {}, schemaView(schema.properties[field])),
+      el("td", {}, required.has(field) ? "required" : "")));
+    schemas.append(el("details", {id: "schema-" + name}, el("summary", {}, name), el("table", {}, ...rows)));
+  }
+  if (location.hash) {
+    const target = document.getElementById(location.hash.slice(1));
+    if (target) target.open = true;
+  }
+  window.addEventListener("hashchange", () => {
+    const target = document.getElementById(location.hash.slice(1));
+    if (target) target.open = true;
+  });
+})();
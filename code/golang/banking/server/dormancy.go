@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking/models"
+)
+
+// escheatmentQuery documents the parameter of the escheatment report.
+var escheatmentQuery = map[string]string{"at": "Date the balances are reported at, 2006-01-02; today by default"}
+
+func (s *Server) handleReactivate(w http.ResponseWriter, r *http.Request) {
+	s.changeAccount(w, r, s.bank.Reactivate)
+}
+
+func (s *Server) handleEscheatmentReport(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	at := s.bank.Clock.Now()
+	if err := parseQuery(r.URL.Query(), "at", &at); err != nil {
+		writeError(w, err)
+		return
+	}
+	report := s.bank.Escheatment(at)
+	if report == nil {
+		report = []models.Escheatment{}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+// dryRunJSON is what a deposit, withdrawal or transfer would do. An op that
+// would fail is not an error of the request: Error says why it would.
+type dryRunJSON struct {
+	Succeeded bool                            `json:"succeeded"`
+	Error     *errorJSON                      `json:"error,omitempty"`
+	Entries   map[string][]models.Transaction `json:"entries"`
+	Fees      float64                         `json:"fees"`
+	Balances  map[string]float64              `json:"balances"`
+}
+
+func (s *Server) handleDryRunDeposit(w http.ResponseWriter, r *http.Request) {
+	s.handleDryRunAmount(w, r, models.OpDeposit)
+}
+
+func (s *Server) handleDryRunWithdrawal(w http.ResponseWriter, r *http.Request) {
+	s.handleDryRunAmount(w, r, models.OpWithdrawal)
+}
+
+func (s *Server) handleDryRunAmount(w http.ResponseWriter, r *http.Request, kind models.OpKind) {
+	var req amountRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	s.writeDryRun(w, r, models.Op{Kind: kind, Account: r.PathValue("number"), Amount: req.Amount, Options: []models.TxOption{
+		models.WithCounterparty(req.Counterparty), models.WithCategory(req.Category),
+		models.WithDescription(req.Description), models.WithTags(req.Tags...), byHolder(r)}})
+}
+
+func (s *Server) handleDryRunTransfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.From, &req.To); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeDryRun(w, r, models.Op{Kind: models.OpTransfer, Account: req.From, To: req.To, Amount: req.Amount,
+		Options: []models.TxOption{models.WithCategory(req.Category), byHolder(r)}})
+}
+
+// writeDryRun writes what op would do, with the entries and balances of
+// only the accounts the caller may view. An op the caller may not make
+// reports none.
+func (s *Server) writeDryRun(w http.ResponseWriter, r *http.Request, op models.Op) {
+	result, err := s.bank.DryRun(op)
+	resp := dryRunJSON{Succeeded: err == nil, Entries: map[string][]models.Transaction{}, Balances: map[string]float64{}}
+	if err != nil {
+		resp.Error = &errorJSON{Error: err.Error(), Code: banking.CodeOf(err), Retryable: banking.IsRetryable(err)}
+	}
+	if code := banking.CodeOf(err); code == banking.CodePermissionDenied || code == banking.CodeUnauthenticated {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	viewable := s.viewable(r)
+	for number, entries := range result.Entries {
+		if viewable(number) {
+			resp.Entries[number] = entries
+		}
+	}
+	for number, balance := range result.Balances {
+		if viewable(number) {
+			resp.Balances[number] = balance
+		}
+	}
+	resp.Fees = result.Fees
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking/models"
+)
+
+// enrichedJSON is a transaction as the ledger keeps it, with what
+// enrichment made of it, if anything.
+type enrichedJSON struct {
+	models.Transaction
+	Enrichment *models.Enrichment `json:"enrichment,omitempty"`
+}
+
+type enrichedPageJSON struct {
+	Items      []enrichedJSON `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+}
+
+type reEnrichJSON struct {
+	Changed int `json:"changed"`
+}
+
+func (s *Server) handleMerchantRules(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.MerchantRules())
+}
+
+func (s *Server) handleSetMerchantRules(w http.ResponseWriter, r *http.Request) {
+	var rules []models.MerchantRule
+	if !bankOnly(w, r) || !readJSON(w, r, &rules) {
+		return
+	}
+	if err := s.bank.SetMerchantRules(rules); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.MerchantRules())
+}
+
+func (s *Server) handleReEnrich(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, reEnrichJSON{Changed: s.bank.ReEnrich()})
+}
+
+func (s *Server) handleEnrichedTransactions(w http.ResponseWriter, r *http.Request) {
+	req, ok := pageRequest(w, r)
+	if !ok {
+		return
+	}
+	page, err := s.bank.HistoryPage(r.PathValue("number"), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := enrichedPageJSON{Items: []enrichedJSON{}, NextCursor: page.NextCursor, HasMore: page.HasMore}
+	for _, tx := range page.Items {
+		item := enrichedJSON{Transaction: tx}
+		if e, ok := s.bank.Enrichment(tx.ID); ok {
+			item.Enrichment = &e
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
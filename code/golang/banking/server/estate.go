@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+type deathRequest struct {
+	DateOfDeath time.Time `json:"date_of_death"`
+}
+
+type executorRequest struct {
+	Executor string    `json:"executor"`
+	Until    time.Time `json:"until,omitempty"`
+}
+
+type settleRequest struct {
+	PayTo string `json:"pay_to"`
+}
+
+type settlementJSON struct {
+	Estate     models.Estate   `json:"estate"`
+	Statements []statementJSON `json:"statements"`
+}
+
+func toSettlementJSON(estate models.Estate, statements []models.Statement) settlementJSON {
+	j := settlementJSON{Estate: estate, Statements: []statementJSON{}}
+	for _, st := range statements {
+		j.Statements = append(j.Statements, toStatementJSON(st))
+	}
+	return j
+}
+
+// bankOnly refuses requests made as a customer, or with the API key of a
+// third-party client: reporting a death and appointing an executor are for
+// the bank's staff.
+func bankOnly(w http.ResponseWriter, r *http.Request) bool {
+	key, _ := r.Context().Value(apiKeyKey{}).(models.APIKey)
+	if r.Header.Get(holderHeader) != "" || key.Client != "" {
+		writeError(w, banking.New(banking.CodePermissionDenied, "only the bank can do this"))
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleReportDeath(w http.ResponseWriter, r *http.Request) {
+	var req deathRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	estate, err := s.bank.ReportDeath(r.PathValue("id"), req.DateOfDeath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, estate)
+}
+
+func (s *Server) handleGetEstate(w http.ResponseWriter, r *http.Request) {
+	estate, err := s.bank.Estate(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if by := r.Header.Get(holderHeader); by != "" && by != estate.Executor {
+		writeError(w, banking.New(banking.CodePermissionDenied, "estate of another customer"))
+		return
+	}
+	writeJSON(w, http.StatusOK, estate)
+}
+
+func (s *Server) handleAppointExecutor(w http.ResponseWriter, r *http.Request) {
+	var req executorRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	estate, err := s.bank.AppointExecutor(r.PathValue("id"), req.Executor, req.Until)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, estate)
+}
+
+func (s *Server) handleSettleEstate(w http.ResponseWriter, r *http.Request) {
+	var req settleRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.PayTo); err != nil {
+		writeError(w, err)
+		return
+	}
+	settlement, err := s.bank.SettleEstate(r.PathValue("id"), req.PayTo, r.Header.Get(holderHeader))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeSigned(w, http.StatusOK, toSettlementJSON(settlement.Estate, settlement.Statements))
+}
+
+func (s *Server) handleEstateStatements(w http.ResponseWriter, r *http.Request) {
+	estate, err := s.bank.Estate(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if by := r.Header.Get(holderHeader); by != "" && by != estate.Executor {
+		writeError(w, banking.New(banking.CodePermissionDenied, "estate of another customer"))
+		return
+	}
+	statements, err := s.bank.EstateStatements(estate.Customer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeSigned(w, http.StatusOK, toSettlementJSON(estate, statements))
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// trialBalanceQuery documents the parameter of the trial balance, the
+// balance sheet and the reserve report.
+var trialBalanceQuery = map[string]string{"at": "Last day included, such as 2024-12-31; every entry by default"}
+
+// incomeStatementQuery documents the period of the income statement.
+var incomeStatementQuery = map[string]string{
+	"from": "First day included, such as 2024-01-01; the first entry by default",
+	"to":   "Last day included, such as 2024-12-31; the last entry by default",
+}
+
+// reportAsOf parses the last day a report includes into the instant it
+// stops before, or zero for every entry.
+func reportAsOf(q url.Values) (time.Time, error) {
+	var at time.Time
+	if err := parseQuery(q, "at", &at); err != nil || at.IsZero() {
+		return at, err
+	}
+	return at.AddDate(0, 0, 1), nil
+}
+
+func (s *Server) handleChartOfAccounts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, models.ChartOfAccounts)
+}
+
+func (s *Server) handleJournal(w http.ResponseWriter, r *http.Request) {
+	journal, err := s.bank.Journal(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if journal == nil {
+		journal = []models.JournalEntry{}
+	}
+	writeJSON(w, http.StatusOK, journal)
+}
+
+func (s *Server) handleTrialBalance(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	at, err := reportAsOf(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.TrialBalance(at))
+}
+
+func (s *Server) handleBalanceSheet(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	at, err := reportAsOf(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.BalanceSheet(at))
+}
+
+func (s *Server) handleReserveReport(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	at, err := reportAsOf(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.ReserveReport(at))
+}
+
+func (s *Server) handleIncomeStatement(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	from, to, err := auditPeriod(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.bank.IncomeStatement(from, to))
+}
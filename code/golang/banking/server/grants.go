@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+type grantRequest struct {
+	Delegate   string            `json:"delegate"`
+	Accounts   []string          `json:"accounts"`
+	Permission models.Permission `json:"permission"`
+	Start      time.Time         `json:"start,omitempty"`
+	Expires    time.Time         `json:"expires"`
+	Reason     string            `json:"reason,omitempty"`
+}
+
+func (s *Server) handleDelegate(w http.ResponseWriter, r *http.Request) {
+	var req grantRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	refs := make([]*string, len(req.Accounts))
+	for i := range req.Accounts {
+		refs[i] = &req.Accounts[i]
+	}
+	if err := s.resolve(refs...); err != nil {
+		writeError(w, err)
+		return
+	}
+	grant, err := s.bank.Delegate(r.Header.Get(holderHeader), models.Grant{
+		Grantor: r.PathValue("id"), Delegate: req.Delegate, Accounts: req.Accounts, Permission: req.Permission,
+		Start: req.Start, Expires: req.Expires, Reason: req.Reason})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, grant)
+}
+
+func (s *Server) handleGrants(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if customer := r.Header.Get(holderHeader); customer != "" && customer != id {
+		writeError(w, banking.New(banking.CodePermissionDenied, "grants of another customer"))
+		return
+	}
+	grants := s.bank.Grants(id)
+	if grants == nil {
+		grants = []models.Grant{}
+	}
+	writeJSON(w, http.StatusOK, grants)
+}
+
+func (s *Server) handleRevokeGrant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "grant id must be a number"))
+		return
+	}
+	grant, err := s.bank.RevokeGrant(id, r.Header.Get(holderHeader))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, grant)
+}
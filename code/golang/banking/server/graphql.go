@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/graphql"
+	"gsolano/banking/models"
+)
+
+// schema is the GraphQL schema served at /graphql:
+//
+//	type Query {
+//	  customers: [Customer]
+//	  customer(id: ID!): Customer
+//	  accounts: [Account]
+//	  account(number: String!): Account
+//	}
+//	type Mutation {
+//	  transfer(from: String!, to: String!, amount: Float!): Transfer
+//	}
+//	type Customer { id: ID, name: String, accounts: [Account] }
+//	type Account {
+//	  number: String, kind: String, balance: Float, available: Float
+//	  transactions(type: String, category: String, counterparty: String,
+//	    minAmount: Float, maxAmount: Float, since: String, until: String,
+//	    first: Int, offset: Int): [Transaction]
+//	}
+//	type Transaction { type: String, amount: Float, counterparty: String, category: String, rate: Float, time: String }
+//	type Transfer { from: Account, to: Account }
+//
+// Its resolvers act for the customer r names in X-Customer-ID, if any,
+// who only sees themselves and the accounts they hold or were granted.
+func (s *Server) schema(r *http.Request) *graphql.Schema {
+	holder := r.Header.Get(holderHeader)
+	// authorized reports whether the holder may use the account for need.
+	authorized := func(number string, need models.Permission) error {
+		if holder == "" {
+			return nil
+		}
+		return s.bank.Authorize(number, holder, need, r.Method+" "+r.URL.Path)
+	}
+	lookup := func(number string) (any, error) {
+		if err := authorized(number, models.PermissionView); err != nil {
+			return nil, err
+		}
+		return s.bank.Account(number)
+	}
+
+	transaction := &graphql.Object{Name: "Transaction", Fields: graphql.Fields{
+		"type":         txField(func(tx models.Transaction) any { return string(tx.Type) }),
+		"amount":       txField(func(tx models.Transaction) any { return tx.Amount }),
+		"counterparty": txField(func(tx models.Transaction) any { return tx.Counterparty }),
+		"category":     txField(func(tx models.Transaction) any { return tx.Category }),
+		"rate":         txField(func(tx models.Transaction) any { return tx.Rate.Percent() }),
+		"time":         txField(func(tx models.Transaction) any { return tx.Time.Format(time.RFC3339) }),
+	}}
+
+	account := &graphql.Object{Name: "Account", Fields: graphql.Fields{
+		"number": {Resolve: func(src any, _ graphql.Args) (any, error) {
+			return src.(models.BankAccount).Number(), nil
+		}},
+		"kind": {Resolve: func(src any, _ graphql.Args) (any, error) {
+			return models.KindOf(src.(models.BankAccount)), nil
+		}},
+		"balance": {Resolve: func(src any, _ graphql.Args) (any, error) {
+			return s.balances.Balance(src.(models.BankAccount).Number())
+		}},
+		"available": {Resolve: func(src any, _ graphql.Args) (any, error) {
+			return s.bank.Available(src.(models.BankAccount).Number())
+		}},
+		"transactions": {Type: transaction, Resolve: func(src any, args graphql.Args) (any, error) {
+			history, err := s.bank.History(src.(models.BankAccount).Number())
+			if err != nil {
+				return nil, err
+			}
+			return filterTransactions(history, args)
+		}},
+	}}
+
+	customer := &graphql.Object{Name: "Customer", Fields: graphql.Fields{
+		"id": {Resolve: func(src any, _ graphql.Args) (any, error) {
+			return src.(*models.Customer).ID, nil
+		}},
+		"name": {Resolve: func(src any, _ graphql.Args) (any, error) {
+			return src.(*models.Customer).Name, nil
+		}},
+		"accounts": {Type: account, Resolve: func(src any, _ graphql.Args) (any, error) {
+			var accounts []models.BankAccount
+			for _, number := range src.(*models.Customer).Accounts {
+				if authorized(number, models.PermissionView) != nil {
+					continue
+				}
+				a, err := s.bank.Account(number)
+				if err != nil {
+					return nil, err
+				}
+				accounts = append(accounts, a)
+			}
+			return accounts, nil
+		}},
+	}}
+
+	transfer := &graphql.Object{Name: "Transfer", Fields: graphql.Fields{
+		"from": {Type: account, Resolve: func(src any, _ graphql.Args) (any, error) {
+			return lookup(src.([2]string)[0])
+		}},
+		"to": {Type: account, Resolve: func(src any, _ graphql.Args) (any, error) {
+			return lookup(src.([2]string)[1])
+		}},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"customers": {Type: customer, Resolve: func(any, graphql.Args) (any, error) {
+			if holder == "" {
+				return s.bank.Customers(), nil
+			}
+			c, err := s.bank.Customer(holder)
+			if err != nil {
+				return nil, err
+			}
+			return []*models.Customer{c}, nil
+		}},
+		"customer": {Type: customer, Resolve: func(_ any, args graphql.Args) (any, error) {
+			id, _ := args.String("id")
+			if holder != "" && id != holder {
+				return nil, banking.New(banking.CodePermissionDenied, "another customer")
+			}
+			return s.bank.Customer(id)
+		}},
+		"accounts": {Type: account, Resolve: func(_ any, args graphql.Args) (any, error) {
+			includeDeleted, _ := args.Bool("includeDeleted")
+			var accounts []models.BankAccount
+			for _, a := range s.bank.ListAccounts(includeDeleted) {
+				if authorized(a.Number(), models.PermissionView) == nil {
+					accounts = append(accounts, a)
+				}
+			}
+			return accounts, nil
+		}},
+		"account": {Type: account, Resolve: func(_ any, args graphql.Args) (any, error) {
+			number, _ := args.String("number")
+			if err := s.resolve(&number); err != nil {
+				return nil, err
+			}
+			return lookup(number)
+		}},
+	}}
+
+	mutation := &graphql.Object{Name: "Mutation", Fields: graphql.Fields{
+		"transfer": {Type: transfer, Resolve: func(_ any, args graphql.Args) (any, error) {
+			from, _ := args.String("from")
+			to, _ := args.String("to")
+			amount, ok := args.Float("amount")
+			if !ok {
+				return nil, errors.New("amount is required")
+			}
+			if err := s.resolve(&from, &to); err != nil {
+				return nil, err
+			}
+			if err := s.bank.Transfer(from, to, amount, byHolder(r)); err != nil {
+				return nil, err
+			}
+			return [2]string{from, to}, nil
+		}},
+	}}
+
+	return &graphql.Schema{Query: query, Mutation: mutation}
+}
+
+func txField(get func(models.Transaction) any) *graphql.Field {
+	return &graphql.Field{Resolve: func(src any, _ graphql.Args) (any, error) {
+		return get(src.(models.Transaction)), nil
+	}}
+}
+
+// filterTransactions applies the filter and offset pagination arguments of
+// Account.transactions.
+func filterTransactions(history []models.Transaction, args graphql.Args) ([]models.Transaction, error) {
+	kind, _ := args.String("type")
+	category, _ := args.String("category")
+	counterparty, _ := args.String("counterparty")
+	minAmount, hasMin := args.Float("minAmount")
+	maxAmount, hasMax := args.Float("maxAmount")
+	since, err := timeArg(args, "since")
+	if err != nil {
+		return nil, err
+	}
+	until, err := timeArg(args, "until")
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []models.Transaction
+	for _, tx := range history {
+		switch {
+		case kind != "" && !strings.EqualFold(string(tx.Type), kind),
+			category != "" && tx.Category != category,
+			counterparty != "" && tx.Counterparty != counterparty,
+			hasMin && tx.Amount < minAmount,
+			hasMax && tx.Amount > maxAmount,
+			!since.IsZero() && tx.Time.Before(since),
+			!until.IsZero() && !tx.Time.Before(until):
+			continue
+		}
+		matched = append(matched, tx)
+	}
+
+	if offset, ok := args.Int("offset"); ok && offset > 0 {
+		if offset > len(matched) {
+			offset = len(matched)
+		}
+		matched = matched[offset:]
+	}
+	if first, ok := args.Int("first"); ok && first >= 0 && first < len(matched) {
+		matched = matched[:first]
+	}
+	return matched, nil
+}
+
+func timeArg(args graphql.Args, name string) (time.Time, error) {
+	text, ok := args.String(name)
+	if !ok || text == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return time.Time{}, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
+
+// handleGraphQL runs a query sent with POST, or with GET as URL parameters.
+// Mutations must be posted: GET is allowed with API keys of view scope, and
+// links are easily forged.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.IsMutation() {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "mutations must be sent with POST", http.StatusMethodNotAllowed)
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := s.schema(r).Execute(req)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
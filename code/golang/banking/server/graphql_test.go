@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestGraphQLMutationNeedsPost checks that GET /graphql runs queries but
+// not mutations, so that an API key of view scope cannot move money.
+func TestGraphQLMutationNeedsPost(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}})
+	bank.Open(&models.SavingsAccount{Account: models.Account{AccountNumber: "S1"}})
+	bank.Deposit("C1", 100)
+	_, view, err := bank.IssueAPIKey(models.APIKey{Name: "reports", Scope: models.PermissionView})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(bank)
+	send := func(r *http.Request) *httptest.ResponseRecorder {
+		r.Header.Set("Authorization", "Bearer "+view)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	get := func(params url.Values) *httptest.ResponseRecorder {
+		return send(httptest.NewRequest("GET", "/graphql?"+params.Encode(), nil))
+	}
+
+	if w := get(url.Values{"query": {`{ account(number: "C1") { balance } }`}}); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"balance":100`) {
+		t.Errorf("GET query = %d %s", w.Code, w.Body)
+	}
+	mutation := `mutation M { transfer(from: "C1", to: "S1", amount: 10) { to { balance } } }`
+	for _, params := range []url.Values{
+		{"query": {mutation}},
+		{"query": {`query Q { customers { id } } ` + mutation}, "operationName": {"M"}},
+	} {
+		w := get(params)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+			t.Errorf("GET %v = %d, Allow %q, want 405 and POST", params, w.Code, w.Header().Get("Allow"))
+		}
+	}
+	body := `{"query":"mutation { transfer(from: \"C1\", to: \"S1\", amount: 10) { to { balance } } }"}`
+	if w := send(httptest.NewRequest("POST", "/graphql", strings.NewReader(body))); w.Code != http.StatusForbidden {
+		t.Errorf("POST mutation with a view key = %d, want 403", w.Code)
+	}
+	if balance, _ := bank.Balance("S1"); balance != 0 {
+		t.Errorf("a view key moved %.2f", balance)
+	}
+}
+
+// TestGraphQLHolder checks that a request for a customer only reaches
+// that customer and the accounts they hold, over GraphQL and WebSocket.
+func TestGraphQLHolder(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Use(bank.AuthorizeHolders())
+	for _, c := range []struct{ id, number string }{{"c1", "A1"}, {"c2", "B1"}} {
+		bank.AddCustomer(&models.Customer{ID: c.id, Name: c.id})
+		if err := bank.OpenFor(c.id, &models.CheckingAccount{Account: models.Account{AccountNumber: c.number}}); err != nil {
+			t.Fatal(err)
+		}
+		bank.Deposit(c.number, 100)
+	}
+	s := New(bank)
+	query := func(q string) string {
+		body, _ := json.Marshal(map[string]string{"query": q})
+		r := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+		r.Header.Set(holderHeader, "c1")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	tests := []struct{ query, want string }{
+		{`{ customers { id accounts { number } } }`, `{"data":{"customers":[{"id":"c1","accounts":[{"number":"A1"}]}]}}`},
+		{`{ accounts { number } }`, `{"data":{"accounts":[{"number":"A1"}]}}`},
+		{`{ customer(id: "c2") { name } }`, `"customer":null`},
+		{`{ account(number: "B1") { balance } }`, `"account":null`},
+		{`mutation { transfer(from: "B1", to: "A1", amount: 10) { from { number } } }`, `"transfer":null`},
+		// The payee's account is not the holder's, so it stays hidden.
+		{`mutation { transfer(from: "A1", to: "B1", amount: 10) { from { balance } to { balance } } }`, `{"from":{"balance":90},"to":null}`},
+	}
+	for _, tt := range tests {
+		if got := query(tt.query); !strings.Contains(got, tt.want) {
+			t.Errorf("%s = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+	if balance, _ := bank.Balance("B1"); balance != 110 {
+		t.Errorf("B1 balance = %.2f, want 110 after one transfer in", balance)
+	}
+
+	r := httptest.NewRequest("GET", "/ws/accounts/B1", nil)
+	r.Header.Set(holderHeader, "c1")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("stream of another customer's account = %d, want 403", w.Code)
+	}
+}
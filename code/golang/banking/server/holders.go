@@ -0,0 +1,182 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+// holderHeader names the customer, or delegate, a request acts for.
+// Requests without it act for the bank, with every permission; with it,
+// they are held to what that customer may do as a holder of the accounts
+// they touch, or was granted.
+const holderHeader = "X-Customer-ID"
+
+type holderRequest struct {
+	Permission models.Permission `json:"permission"`
+	DailyLimit float64           `json:"daily_limit,omitempty"`
+}
+
+// byHolder makes an op on behalf of the customer of the request, if any,
+// in its session if it has one.
+func byHolder(r *http.Request) models.TxOption {
+	customer := r.Header.Get(holderHeader)
+	if customer == "" {
+		return func(*models.Transaction) {}
+	}
+	if session, ok := r.Context().Value(sessionKey{}).(models.Session); ok {
+		return func(tx *models.Transaction) {
+			models.ByHolder(customer)(tx)
+			models.BySession(session.ID)(tx)
+		}
+	}
+	return models.ByHolder(customer)
+}
+
+// ownCustomer refuses requests by a customer for what, such as
+// "applications", of customer id, another one. Requests of the bank go
+// ahead.
+func ownCustomer(w http.ResponseWriter, r *http.Request, id, what string) bool {
+	if customer := r.Header.Get(holderHeader); customer != "" && customer != id {
+		writeError(w, banking.New(banking.CodePermissionDenied, what+" of another customer"))
+		return false
+	}
+	return true
+}
+
+func (route apiRoute) permissionOrDefault() models.Permission {
+	switch {
+	case route.permission != "":
+		return route.permission
+	case route.method == http.MethodGet:
+		return models.PermissionView
+	default:
+		return models.PermissionAdmin
+	}
+}
+
+// authorizeHolder rejects requests for a customer who does not hold the
+// {number} account of the path with a permission that allows need.
+func (s *Server) authorizeHolder(need models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if customer := r.Header.Get(holderHeader); customer != "" {
+			if err := s.bank.Authorize(r.PathValue("number"), customer, need, r.Method+" "+r.URL.Path); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// authorizeAccount checks that the customer of r, if any, may use an
+// account named in the body rather than the path for need.
+func (s *Server) authorizeAccount(r *http.Request, number string, need models.Permission) error {
+	customer := r.Header.Get(holderHeader)
+	if customer == "" {
+		return nil
+	}
+	return s.bank.Authorize(number, customer, need, r.Method+" "+r.URL.Path)
+}
+
+// viewable returns whether the customer of r may see an account, to filter
+// what lists and searches answer with: every account for the bank, and the
+// accounts a customer holds or was granted.
+func (s *Server) viewable(r *http.Request) func(number string) bool {
+	if r.Header.Get(holderHeader) == "" {
+		return func(string) bool { return true }
+	}
+	seen := map[string]bool{}
+	return func(number string) bool {
+		ok, checked := seen[number]
+		if !checked {
+			ok = s.authorizeAccount(r, number, models.PermissionView) == nil
+			seen[number] = ok
+		}
+		return ok
+	}
+}
+
+func (s *Server) handleHolders(w http.ResponseWriter, r *http.Request) {
+	holders, err := s.bank.Holders(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, holders)
+}
+
+func (s *Server) handleSetHolder(w http.ResponseWriter, r *http.Request) {
+	var req holderRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	h := models.Holder{Customer: r.PathValue("customer"), Permission: req.Permission, DailyLimit: req.DailyLimit}
+	if err := s.bank.SetHolder(r.PathValue("number"), r.Header.Get(holderHeader), h); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (s *Server) handleRemoveHolder(w http.ResponseWriter, r *http.Request) {
+	if err := s.bank.RemoveHolder(r.PathValue("number"), r.Header.Get(holderHeader), r.PathValue("customer")); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleHolders(w, r)
+}
+
+func (s *Server) handleInvite(w http.ResponseWriter, r *http.Request) {
+	var h models.Holder
+	if !readJSON(w, r, &h) {
+		return
+	}
+	inv, err := s.bank.Invite(r.PathValue("number"), r.Header.Get(holderHeader), h)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, inv)
+}
+
+func (s *Server) handleInvitations(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if customer := r.Header.Get(holderHeader); customer != "" && customer != id {
+		writeError(w, banking.New(banking.CodePermissionDenied, "invitations of another customer"))
+		return
+	}
+	if _, err := s.bank.Customer(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	invitations := s.bank.Invitations(id)
+	if invitations == nil {
+		invitations = []models.Invitation{}
+	}
+	writeJSON(w, http.StatusOK, invitations)
+}
+
+func (s *Server) handleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	customer := r.Header.Get(holderHeader)
+	if customer == "" {
+		writeError(w, banking.New(banking.CodeInvalidArgument, holderHeader+" must name the customer accepting"))
+		return
+	}
+	h, err := s.bank.AcceptInvitation(r.PathValue("id"), customer)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (s *Server) handleDeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	inv, err := s.bank.DeclineInvitation(r.PathValue("id"), r.Header.Get(holderHeader))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inv)
+}
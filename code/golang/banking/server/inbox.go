@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var messagesQuery = map[string]string{
+	"unread": "true to list only the messages not read yet",
+}
+
+type inboxJSON struct {
+	Unread   int              `json:"unread"`
+	Messages []models.Message `json:"messages"`
+}
+
+type messageRequest struct {
+	Kind    models.MessageKind `json:"kind"`
+	Account string             `json:"account,omitempty"`
+	Subject string             `json:"subject"`
+	Body    string             `json:"body,omitempty"`
+}
+
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "inbox") {
+		return
+	}
+	unreadOnly, err := strconv.ParseBool(r.URL.Query().Get("unread"))
+	if err != nil && r.URL.Query().Has("unread") {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "unread must be true or false"))
+		return
+	}
+	messages, unread, err := s.bank.Messages(r.PathValue("id"), unreadOnly)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inboxJSON{Unread: unread, Messages: messages})
+}
+
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req messageRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	m, err := s.bank.SendMessage(models.Message{Customer: r.PathValue("id"), Kind: req.Kind, Account: req.Account,
+		Subject: req.Subject, Body: req.Body})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) handleReadMessage(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "inbox") {
+		return
+	}
+	m, err := s.bank.MarkMessage(r.PathValue("id"), r.PathValue("message"), r.Method == http.MethodPost)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) handleDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "inbox") {
+		return
+	}
+	if err := s.bank.DeleteMessage(r.PathValue("id"), r.PathValue("message")); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleMessages(w, r)
+}
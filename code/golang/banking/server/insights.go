@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/insights"
+)
+
+var insightsQuery = map[string]string{
+	"month":  "Month to report on, such as 2024-01; the current one by default",
+	"top":    "How many counterparties to report, 3 by default",
+	"factor": "How many times the usual payment a payment must be to stand out, 3 by default",
+}
+
+func (s *Server) handleInsights(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+	q := r.URL.Query()
+	month := s.bank.Clock.Now()
+	if text := q.Get("month"); text != "" {
+		var err error
+		if month, err = time.ParseInLocation("2006-01", text, time.Local); err != nil {
+			writeError(w, banking.New(banking.CodeInvalidArgument, fmt.Sprintf("month: %q is not a month such as 2024-01", text)))
+			return
+		}
+	}
+	opts := insights.Options{Rounding: s.bank.Rounding}
+	for name, dst := range map[string]any{"top": &opts.Top, "factor": &opts.Factor} {
+		if err := parseQuery(q, name, dst); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	account, err := s.bank.Account(number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts.Currency = s.bank.AccountCurrency(account)
+	history, err := s.bank.History(number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, insights.Analyze(history, month, opts))
+}
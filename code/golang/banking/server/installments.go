@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// installmentsRequest splits a transfer to To into Count installments,
+// monthly from now unless Every and Start say.
+type installmentsRequest struct {
+	To       string         `json:"to"`
+	Amount   float64        `json:"amount"`
+	Count    int            `json:"count"`
+	Every    models.Cadence `json:"every,omitempty"`
+	Start    time.Time      `json:"start,omitempty"`
+	Category string         `json:"category,omitempty"`
+}
+
+// installmentPlan gets the {id} installment plan of the path, refusing those
+// out of another account than its {number}.
+func (s *Server) installmentPlan(w http.ResponseWriter, r *http.Request) (models.InstallmentPlan, bool) {
+	plan, err := s.bank.InstallmentPlan(r.PathValue("id"))
+	if err == nil && plan.From != r.PathValue("number") {
+		err = fmt.Errorf("%w: %s", models.ErrInstallmentPlanNotFound, r.PathValue("id"))
+	}
+	if err != nil {
+		writeError(w, err)
+		return models.InstallmentPlan{}, false
+	}
+	return plan, true
+}
+
+func (s *Server) handlePlanInstallments(w http.ResponseWriter, r *http.Request) {
+	var req installmentsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.To); err != nil {
+		writeError(w, err)
+		return
+	}
+	plan, err := s.bank.PlanInstallments(models.InstallmentPlan{From: r.PathValue("number"), To: req.To, Amount: req.Amount,
+		Count: req.Count, Every: req.Every, Start: req.Start, Category: req.Category, Holder: r.Header.Get(holderHeader)})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, plan)
+}
+
+func (s *Server) handleInstallmentPlans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.bank.InstallmentPlans(r.PathValue("number")))
+}
+
+func (s *Server) handleInstallmentPlan(w http.ResponseWriter, r *http.Request) {
+	if plan, ok := s.installmentPlan(w, r); ok {
+		writeJSON(w, http.StatusOK, plan)
+	}
+}
+
+func (s *Server) handleCancelInstallments(w http.ResponseWriter, r *http.Request) {
+	plan, ok := s.installmentPlan(w, r)
+	if !ok {
+		return
+	}
+	plan, err := s.bank.CancelInstallments(plan.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// interestAuditQuery documents the period parameters of the interest audits.
+var interestAuditQuery = map[string]string{
+	"from": "First day audited, such as 2024-01-01; the first entry by default",
+	"to":   "Last day audited, such as 2024-12-31; the last entry by default",
+}
+
+// auditPeriod parses the days of an audit into the instants it runs from and
+// stops before.
+func auditPeriod(q url.Values) (from, to time.Time, err error) {
+	if err = parseQuery(q, "from", &from); err != nil {
+		return
+	}
+	if err = parseQuery(q, "to", &to); err == nil && !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
+	return
+}
+
+func (s *Server) handleInterestAudit(w http.ResponseWriter, r *http.Request) {
+	from, to, err := auditPeriod(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	audit, err := s.bank.AuditInterest(r.PathValue("number"), from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, audit)
+}
+
+func (s *Server) handleInterestAuditReport(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	from, to, err := auditPeriod(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	report := s.bank.InterestAuditReport(from, to)
+	if report == nil {
+		report = []models.InterestAudit{}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking/models"
+)
+
+var proofQuery = map[string]string{
+	"root": "Sequence number of the published root to prove against, the latest by default",
+}
+
+func (s *Server) handleLedgerRoots(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.bank.LedgerRoots())
+}
+
+func (s *Server) handlePublishLedgerRoot(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.bank.PublishLedgerRoot())
+}
+
+func (s *Server) handleInclusionProof(w http.ResponseWriter, r *http.Request) {
+	var sequence int
+	if err := parseQuery(r.URL.Query(), "root", &sequence); err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := s.bank.ProveInclusion(r.PathValue("id"), sequence)
+	if number := proof.Account; err == nil {
+		// Entries of accounts the caller may not view are answered as if
+		// they were not there, so their IDs cannot be probed.
+		s.resolve(&number)
+		if !s.viewable(r)(number) {
+			err = models.ErrNotInLedgerRoot
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, proof)
+}
+
+// handleVerifyInclusion checks a proof for clients that cannot hash
+// themselves; the check needs nothing of the bank but the published root.
+func (s *Server) handleVerifyInclusion(w http.ResponseWriter, r *http.Request) {
+	var proof models.InclusionProof
+	if !readJSON(w, r, &proof) {
+		return
+	}
+	if err := proof.Verify(); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, proof)
+}
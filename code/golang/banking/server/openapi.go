@@ -0,0 +1,232 @@
+package server
+
+import (
+	"embed"
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+// openAPI builds the OpenAPI 3 document of the JSON API from apiRoutes.
+// Schemas are derived from the Go types of the request and response bodies
+// and their json tags; fields without omitempty are required.
+func (s *Server) openAPI() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+
+	for _, route := range s.apiRoutes() {
+		status := route.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		content := map[string]any{route.contentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		if route.contentType == "" {
+			content = jsonContent(schemaOf(reflect.TypeOf(route.response), schemas))
+		}
+		op := map[string]any{
+			"summary":     route.summary,
+			"operationId": operationID(route),
+			"responses": map[string]any{
+				strconv.Itoa(status): map[string]any{
+					"description": http.StatusText(status),
+					"content":     content,
+				},
+				"default": map[string]any{
+					"description": "Error",
+					"content":     jsonContent(schemaOf(reflect.TypeOf(errorJSON{}), schemas)),
+				},
+			},
+		}
+		if params := append(pathParams(route.path), queryParams(route)...); len(params) > 0 {
+			op["parameters"] = params
+		}
+		if route.request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(route.request), schemas)),
+			}
+		}
+		if s.token != "" {
+			op["security"] = []map[string][]string{{"bearer": {}}, {"session": {}}}
+		}
+
+		if paths[route.path] == nil {
+			paths[route.path] = map[string]any{}
+		}
+		paths[route.path][strings.ToLower(route.method)] = op
+	}
+
+	components := map[string]any{"schemas": schemas}
+	if s.token != "" {
+		components["securitySchemes"] = map[string]any{
+			"bearer":  map[string]any{"type": "http", "scheme": "bearer"},
+			"session": map[string]any{"type": "apiKey", "in": "header", "name": sessionHeader},
+		}
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Bank API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": components,
+	}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// operationID turns "POST /api/accounts/{number}/deposits" into
+// "postAccountsNumberDeposits".
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.method)
+	for _, part := range strings.Split(strings.TrimPrefix(route.path, "/api/"), "/") {
+		part = strings.Trim(part, "{}")
+		if part != "" {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+func queryParams(route apiRoute) []map[string]any {
+	query := map[string]string{}
+	for name, description := range route.query {
+		query[name] = description
+	}
+	if route.paged {
+		query["cursor"] = "next_cursor of the previous page"
+		query["limit"] = fmt.Sprintf("Page size, %d by default and at most %d", models.DefaultPageSize, models.MaxPageSize)
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var params []map[string]any
+	for _, name := range names {
+		schema := map[string]any{"type": "string"}
+		if name == "limit" {
+			schema = map[string]any{"type": "integer"}
+		}
+		params = append(params, map[string]any{
+			"name":        name,
+			"in":          "query",
+			"description": query[name],
+			"schema":      schema,
+		})
+	}
+	return params
+}
+
+func pathParams(path string) []map[string]any {
+	var params []map[string]any
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params = append(params, map[string]any{
+				"name":     strings.Trim(part, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+	}
+	return params
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf returns the JSON schema of t. Named structs are added to schemas
+// and referenced.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(textMarshalerType) {
+		// Such as money.Rate, written as "5%".
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil // placeholder for recursive types
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = schemaOf(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName names the schema of t after the Go type, dropping the JSON
+// suffix of the API's transport types: accountJSON becomes Account.
+func schemaName(t reflect.Type) string {
+	name := strings.TrimSuffix(t.Name(), "JSON")
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPI())
+}
+
+// docsFS holds the API documentation page, served under /docs/, which
+// renders /openapi.json without loading anything from elsewhere.
+//
+//go:embed docs
+var docsFS embed.FS
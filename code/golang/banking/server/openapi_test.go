@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestOpenAPI checks that every route of the API is described, with an
+// operation ID of its own and its path parameters, and that the
+// documentation page is served without assets from elsewhere.
+func TestOpenAPI(t *testing.T) {
+	models.SetOutput(io.Discard)
+	s := New(models.NewBank())
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/openapi.json")
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name, In string
+			}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	routes := 0
+	for _, route := range s.apiRoutes() {
+		routes++
+		op, ok := doc.Paths[route.path][strings.ToLower(route.method)]
+		if !ok {
+			t.Errorf("%s %s is not in the OpenAPI document", route.method, route.path)
+			continue
+		}
+		if other, ok := ids[op.OperationID]; ok {
+			t.Errorf("%s %s and %s share the operation ID %s", route.method, route.path, other, op.OperationID)
+		}
+		ids[op.OperationID] = route.method + " " + route.path
+		for _, param := range pathParams(route.path) {
+			found := false
+			for _, p := range op.Parameters {
+				found = found || p.In == "path" && p.Name == param["name"]
+			}
+			if !found {
+				t.Errorf("%s %s does not describe its path parameter %s", route.method, route.path, param["name"])
+			}
+		}
+	}
+	described := 0
+	for _, ops := range doc.Paths {
+		described += len(ops)
+	}
+	if described != routes {
+		t.Errorf("%d operations described for %d routes", described, routes)
+	}
+
+	if w := get("/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/docs/" {
+		t.Errorf("GET /docs = %d to %q, want a redirect to /docs/", w.Code, w.Header().Get("Location"))
+	}
+	for _, path := range []string{"/docs/", "/docs/docs.js", "/docs/docs.css"} {
+		w := get(path)
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "https://") {
+			t.Errorf("GET %s = %d, want the embedded page without remote assets", path, w.Code)
+		}
+	}
+}
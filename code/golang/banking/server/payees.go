@@ -0,0 +1,27 @@
+package server
+
+import "net/http"
+
+type payeeRequest struct {
+	Account string `json:"account"`
+	Name    string `json:"name"`
+}
+
+// handleCheckPayee answers how a payee name compares with the holders of
+// an account, before a transfer to it.
+func (s *Server) handleCheckPayee(w http.ResponseWriter, r *http.Request) {
+	var req payeeRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.Account); err != nil {
+		writeError(w, err)
+		return
+	}
+	check, err := s.bank.CheckPayee(req.Account, req.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, check)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking/validate"
+)
+
+type openAccountRequest struct {
+	Product  string `json:"product"`
+	Customer string `json:"customer,omitempty"`
+	Number   string `json:"number"`
+}
+
+func (s *Server) handleListProducts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.bank.Products.Products())
+}
+
+func (s *Server) handleOpenAccount(w http.ResponseWriter, r *http.Request) {
+	var req openAccountRequest
+	if !readJSON(w, r, &req) || !ownCustomer(w, r, req.Customer, "accounts") {
+		return
+	}
+	var v validate.Validator
+	v.Required("product", req.Product)
+	v.AccountNumber("number", req.Number)
+	if err := v.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := s.bank.OpenAccount(req.Product, req.Customer, req.Number); err != nil {
+		writeError(w, err)
+		return
+	}
+	account, err := s.accountJSON(req.Number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, account)
+}
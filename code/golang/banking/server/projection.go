@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/projection"
+)
+
+type projectionJSON struct {
+	Percentiles []int      `json:"percentiles"`
+	Bands       []bandJSON `json:"bands"`
+}
+
+// bandJSON holds one balance per entry of percentiles.
+type bandJSON struct {
+	Year      int       `json:"year"`
+	Deposited float64   `json:"deposited"`
+	Values    []float64 `json:"values"`
+}
+
+var projectionQuery = map[string]string{
+	"account":            "Start from this account's balance and, for savings, its rate",
+	"balance":            "Starting balance, when no account is given",
+	"years":              "Years to project, 10 by default",
+	"rate":               "Long-run annual rate such as 4%, or the account's rate",
+	"rate_volatility":    "How much the rate moves in a year, 1% by default",
+	"monthly":            "Monthly deposit",
+	"deposit_volatility": "How much deposits vary, as a fraction; 0.1 by default",
+	"skip":               "Chance of missing a month's deposit, 0.05 by default",
+	"seed":               "Random seed; the same seed gives the same projection",
+}
+
+func (s *Server) handleProjection(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	a := projection.Assumptions{
+		Years: 10, Rate: money.Percent(4), RateVolatility: money.Percent(1),
+		DepositVolatility: 0.1, SkipProbability: 0.05, Seed: 1,
+	}
+	if number := q.Get("account"); number != "" {
+		account, err := s.bank.Account(number)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if a.Balance, err = s.bank.Balance(number); err != nil {
+			writeError(w, err)
+			return
+		}
+		if savings, ok := account.(*models.SavingsAccount); ok {
+			a.Rate = savings.InterestRate
+		}
+	} else if err := parseQuery(q, "balance", &a.Balance); err != nil {
+		writeError(w, err)
+		return
+	}
+	for name, dst := range map[string]any{
+		"years": &a.Years, "rate": &a.Rate, "rate_volatility": &a.RateVolatility, "monthly": &a.MonthlyDeposit,
+		"deposit_volatility": &a.DepositVolatility, "skip": &a.SkipProbability, "seed": &a.Seed,
+	} {
+		if err := parseQuery(q, name, dst); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	bands, err := projection.Project(a)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := projectionJSON{Percentiles: projection.Percentiles}
+	for _, b := range bands {
+		resp.Bands = append(resp.Bands, bandJSON{Year: b.Year, Deposited: b.Deposited, Values: b.Values})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dateIn is a date query parameter read in a time zone other than the
+// host's, such as an account's.
+type dateIn struct {
+	t   *time.Time
+	loc *time.Location
+}
+
+// parseQuery sets *dst from the query parameter name when it is present.
+func parseQuery(q url.Values, name string, dst any) error {
+	text := q.Get(name)
+	if text == "" {
+		return nil
+	}
+	var err error
+	switch dst := dst.(type) {
+	case *float64:
+		*dst, err = strconv.ParseFloat(text, 64)
+	case *int:
+		*dst, err = strconv.Atoi(text)
+	case *uint64:
+		*dst, err = strconv.ParseUint(text, 10, 64)
+	case *money.Rate:
+		err = dst.UnmarshalText([]byte(text))
+	case *time.Time:
+		*dst, err = time.ParseInLocation(time.DateOnly, text, time.Local)
+	case dateIn:
+		*dst.t, err = time.ParseInLocation(time.DateOnly, text, dst.loc)
+	}
+	if err != nil {
+		return banking.New(banking.CodeInvalidArgument, fmt.Sprintf("%s: %q is not valid", name, text))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking/models"
+)
+
+type quoteRequest struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+// previewRequest is a transfer to preview; SettlementDays is how many
+// business days after booking it settles.
+type previewRequest struct {
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Amount         float64 `json:"amount"`
+	SettlementDays int     `json:"settlement_days,omitempty"`
+}
+
+func (s *Server) handleQuoteTransfer(w http.ResponseWriter, r *http.Request) {
+	var req quoteRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.From, &req.To); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.authorizeAccount(r, req.From, models.PermissionTransact); err != nil {
+		writeError(w, err)
+		return
+	}
+	quote, err := s.bank.QuoteTransfer(req.From, req.To, req.Amount)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, quote)
+}
+
+func (s *Server) handleExecuteQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := s.bank.Quote(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.authorizeAccount(r, quote.From, models.PermissionTransact); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.bank.TransferQuoted(quote.ID, byHolder(r)); err != nil {
+		writeError(w, err)
+		return
+	}
+	from, _ := s.accountJSON(quote.From)
+	to, _ := s.accountJSON(quote.To)
+	writeJSON(w, http.StatusCreated, []accountJSON{from, to})
+}
+
+func (s *Server) handlePreviewTransfer(w http.ResponseWriter, r *http.Request) {
+	var req previewRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.From, &req.To); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.authorizeAccount(r, req.From, models.PermissionTransact); err != nil {
+		writeError(w, err)
+		return
+	}
+	preview, err := s.bank.PreviewTransfer(req.From, req.To, req.Amount, req.SettlementDays)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
+
+func (s *Server) handlePreviewWithdrawal(w http.ResponseWriter, r *http.Request) {
+	var req amountRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	preview, err := s.bank.PreviewWithdrawal(r.PathValue("number"), req.Amount)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
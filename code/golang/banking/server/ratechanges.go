@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+var rateChangesQuery = map[string]string{
+	"product": "Code of the product to list the rate changes of, every product by default",
+}
+
+type rateChangeRequest struct {
+	Rate       money.Rate `json:"rate"`
+	Effective  time.Time  `json:"effective"`
+	NoticeDays int        `json:"notice_days"`
+}
+
+func (s *Server) handleScheduleRateChange(w http.ResponseWriter, r *http.Request) {
+	var req rateChangeRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	change, err := s.bank.ScheduleRateChange(r.PathValue("code"), req.Rate, req.Effective, req.NoticeDays)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, change)
+}
+
+func (s *Server) handleListRateChanges(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.bank.RateChanges(r.URL.Query().Get("product")))
+}
+
+func (s *Server) handleCancelRateChange(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "rate change id must be a number"))
+		return
+	}
+	change, err := s.bank.CancelRateChange(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, change)
+}
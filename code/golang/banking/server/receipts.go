@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+func (s *Server) handleGetReceipt(w http.ResponseWriter, r *http.Request) {
+	receipt, err := s.bank.Receipt(r.PathValue("reference"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if viewable := s.viewable(r); !viewable(receipt.From) && !viewable(receipt.To) {
+		writeError(w, banking.New(banking.CodePermissionDenied, "receipt of another customer's accounts"))
+		return
+	}
+	s.writeSigned(w, http.StatusOK, receipt)
+}
+
+// handleVerifyReceipt answers with the receipt when it verifies.
+func (s *Server) handleVerifyReceipt(w http.ResponseWriter, r *http.Request) {
+	var receipt models.Receipt
+	if !readJSON(w, r, &receipt) {
+		return
+	}
+	if err := s.bank.VerifyReceipt(receipt); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, receipt)
+}
@@ -0,0 +1,26 @@
+package server
+
+import "net/http"
+
+type renumberRequest struct {
+	Number string `json:"number"`
+}
+
+// handleRenumber gives the account of the path a new number, as the bank's
+// staff; its old number keeps naming it through the redirect left behind.
+func (s *Server) handleRenumber(w http.ResponseWriter, r *http.Request) {
+	var req renumberRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	if _, err := s.bank.RenumberAccount(r.PathValue("number"), req.Number); err != nil {
+		writeError(w, err)
+		return
+	}
+	account, err := s.accountJSON(req.Number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
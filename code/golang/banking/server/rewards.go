@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+type redeemRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+func (s *Server) handleRewards(w http.ResponseWriter, r *http.Request) {
+	rewards, err := s.bank.Rewards(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rewards)
+}
+
+func (s *Server) handleSetRewards(w http.ResponseWriter, r *http.Request) {
+	var program models.RewardsProgram
+	if !bankOnly(w, r) || !readJSON(w, r, &program) {
+		return
+	}
+	rewards, err := s.bank.SetRewards(r.PathValue("number"), program)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rewards)
+}
+
+func (s *Server) handleRedeemRewards(w http.ResponseWriter, r *http.Request) {
+	var req redeemRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	entry, err := s.bank.RedeemRewards(r.PathValue("number"), req.Amount, byHolder(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+// handleRewardsStatement answers with the rewards statement of the month
+// query, such as 2026-01, last month by default. It asks for the middle of
+// the month, which falls in the same one in any account's zone.
+func (s *Server) handleRewardsStatement(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 14)
+	if q := r.URL.Query().Get("month"); q != "" {
+		var err error
+		if month, err = time.Parse("2006-01", q); err != nil {
+			writeError(w, banking.New(banking.CodeInvalidArgument, "month must be like 2026-01"))
+			return
+		}
+		month = month.AddDate(0, 0, 14)
+	}
+	statement, err := s.bank.RewardsStatement(r.PathValue("number"), month)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statement)
+}
@@ -0,0 +1,144 @@
+// Package server exposes a models.Bank over HTTP.
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"gsolano/banking/chaos"
+	"gsolano/banking/i18n"
+	"gsolano/banking/models"
+	"gsolano/banking/shared"
+)
+
+type Server struct {
+	bank      *models.Bank
+	index     *models.TextIndex
+	balances  *models.BalanceCache
+	mux       *http.ServeMux
+	templates *template.Template
+	token     string
+	locale    i18n.Locale
+	shared    shared.Backend
+	rate      shared.Rate
+	chaos     *chaos.Faults
+	alerts    *models.Alerts
+	// signingKey signs statements and receipts, see WithSigningKey.
+	signingKey ed25519.PrivateKey
+	// dashboard serves the web dashboard, see WithDashboard, and csrf is
+	// the token its forms must post back.
+	dashboard bool
+	csrf      string
+}
+
+type Option func(*Server)
+
+// WithToken requires API clients to present token as a bearer token. Browsers
+// opening an account stream may pass it in the token query parameter
+// instead, which no other route accepts.
+func WithToken(token string) Option {
+	return func(s *Server) { s.token = token }
+}
+
+// WithLocale sets the locale amounts are formatted in on web pages.
+func WithLocale(locale i18n.Locale) Option {
+	return func(s *Server) { s.locale = locale }
+}
+
+// WithDashboard serves the web dashboard at /, for demos: it shows every
+// account and transfers between them as the bank, with neither the token
+// nor sessions, so it must not be reachable by anyone else.
+func WithDashboard() Option {
+	return func(s *Server) { s.dashboard = true }
+}
+
+func New(bank *models.Bank, opts ...Option) *Server {
+	s := &Server{bank: bank, index: models.NewTextIndex(bank), balances: models.NewBalanceCache(bank), mux: http.NewServeMux(), locale: i18n.Default, shared: shared.NewMemory()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.csrf = newCSRFToken()
+	s.templates = s.parseTemplates()
+	s.routes()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	if s.dashboard {
+		s.mux.HandleFunc("GET /{$}", s.handleDashboard)
+		s.mux.HandleFunc("GET /accounts/{number}", s.resolveAccount(s.handleStatement))
+		s.mux.HandleFunc("POST /transfer", s.handleTransferForm)
+		s.mux.HandleFunc("GET /search", s.handleSearchPage)
+	}
+	// API keys of view scope query GraphQL with GET; POST may transfer.
+	s.mux.HandleFunc("GET /graphql", s.authenticated(s.limited(noClient(scoped(models.PermissionView, s.handleGraphQL)))))
+	s.mux.HandleFunc("POST /graphql", s.authenticated(s.limited(noClient(scoped(models.PermissionTransact, s.handleGraphQL)))))
+	s.mux.HandleFunc("GET "+streamPrefix+"{number}", s.authenticated(noClient(scoped(models.PermissionView, s.resolveAccount(s.authorizeHolder(models.PermissionView, s.handleAccountStream))))))
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	s.mux.Handle("GET /docs", http.RedirectHandler("/docs/", http.StatusMovedPermanently))
+	s.mux.Handle("GET /docs/", http.FileServerFS(docsFS))
+	for _, route := range s.apiRoutes() {
+		handler := route.handler
+		if route.stepUp {
+			handler = s.steppedUp(handler)
+		}
+		if strings.Contains(route.path, "{number}") {
+			handler = s.authorizeHolder(route.permissionOrDefault(), handler)
+		}
+		// Inside resolveAccount, to record numbers rather than nicknames,
+		// and outside authorizeHolder, to record what it refuses.
+		if route.method != http.MethodGet {
+			handler = s.audited(route, handler)
+		}
+		if strings.Contains(route.path, "{number}") {
+			handler = s.resolveAccount(handler)
+		}
+		handler = scoped(route.permissionOrDefault(), handler)
+		if !consentRoute(route.path) {
+			handler = noClient(handler)
+		}
+		s.mux.HandleFunc(route.method+" "+route.path, s.authenticated(s.limited(s.idempotent(handler))))
+	}
+}
+
+// authenticated rejects requests without the configured token. Without a
+// token every request is allowed, which is convenient for local demos.
+// Requests with a session token or an API key are authenticated by it
+// instead.
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(sessionHeader) != "" {
+			if r, ok := s.withSession(w, r); ok {
+				next(w, r)
+			}
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// Browsers cannot set headers on WebSockets. Elsewhere a token in
+		// the query would only end up in logs and browser history.
+		if token == "" || token == r.Header.Get("Authorization") {
+			token = ""
+			if strings.HasPrefix(r.URL.Path, streamPrefix) {
+				token = r.URL.Query().Get("token")
+			}
+		}
+		if strings.HasPrefix(token, models.APIKeyPrefix) {
+			s.withAPIKey(w, r, token, next)
+			return
+		}
+		if s.token != "" {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
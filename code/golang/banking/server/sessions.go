@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+// sessionHeader carries the token of a customer's session. Requests with
+// one need no API token and act as the session's customer, as if they named
+// them by X-Customer-ID.
+const sessionHeader = "X-Session"
+
+type sessionKey struct{}
+
+type sessionRequest struct {
+	Customer string `json:"customer"`
+	Device   string `json:"device"`
+	// IP and UserAgent are those of the customer's device, when the
+	// sign-in is relayed by a front end; the request's own by default.
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+type sessionJSON struct {
+	models.Session
+	// Token is only given out here, when the session starts.
+	Token string `json:"token"`
+}
+
+// clientIP is the address a request came from, without its port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// withSession authenticates a request by the session token it carries, if
+// any, and makes it act as the session's customer.
+func (s *Server) withSession(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	session, err := s.bank.SessionByToken(r.Header.Get(sessionHeader), clientIP(r), r.UserAgent())
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	if customer := r.Header.Get(holderHeader); customer != "" && customer != session.Customer {
+		writeError(w, banking.New(banking.CodePermissionDenied, holderHeader+" is not the customer of the session"))
+		return nil, false
+	}
+	r.Header.Set(holderHeader, session.Customer)
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, session)), true
+}
+
+// steppedUp refuses high-risk requests of sessions whose customer has not
+// authenticated within the bank's StepUpWindow, asking them to step up.
+// Requests of the bank or without a session go ahead.
+func (s *Server) steppedUp(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := r.Context().Value(sessionKey{}).(models.Session)
+		if ok && !session.SteppedUp(s.bank.Clock.Now(), s.bank.StepUpWindow) {
+			w.Header().Set("WWW-Authenticate", `StepUp session="`+session.ID+`"`)
+			writeError(w, models.ErrStepUpRequired)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ownSessions refuses requests by a customer for the sessions, or second
+// factors, of another.
+func ownSessions(w http.ResponseWriter, r *http.Request) bool {
+	return ownCustomer(w, r, r.PathValue("id"), "sessions")
+}
+
+func (s *Server) handleStartSession(w http.ResponseWriter, r *http.Request) {
+	var req sessionRequest
+	if !bankOnly(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	if req.IP == "" {
+		req.IP = clientIP(r)
+	}
+	if req.UserAgent == "" {
+		req.UserAgent = r.UserAgent()
+	}
+	session, token, err := s.bank.StartSession(req.Customer, req.Device, req.IP, req.UserAgent)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sessionJSON{Session: session, Token: token})
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !ownSessions(w, r) {
+		return
+	}
+	sessions, err := s.bank.Sessions(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	if !ownSessions(w, r) {
+		return
+	}
+	session, err := s.bank.RevokeSession(r.PathValue("id"), r.PathValue("session"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (s *Server) handleStepUp(w http.ResponseWriter, r *http.Request) {
+	if !bankOnly(w, r) {
+		return
+	}
+	session, err := s.bank.StepUp(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+type secondFactorRequest struct {
+	Factor string `json:"factor"`
+	Code   string `json:"code"`
+}
+
+type totpConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// handleSecondFactor steps up a session with a code of a second factor.
+// Customers may only step up the session they make the request in; the
+// bank may relay the code of any.
+func (s *Server) handleSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var req secondFactorRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if r.Header.Get(holderHeader) != "" {
+		if session, ok := r.Context().Value(sessionKey{}).(models.Session); !ok || session.ID != r.PathValue("id") {
+			writeError(w, banking.New(banking.CodePermissionDenied, "customers step up the session they are in"))
+			return
+		}
+	}
+	session, err := s.bank.StepUpWith(r.PathValue("id"), req.Factor, req.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// totpStatus answers with the authenticator app status of the customer of
+// the path.
+func (s *Server) totpStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.bank.TOTP(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleTOTP(w http.ResponseWriter, r *http.Request) {
+	if !ownSessions(w, r) {
+		return
+	}
+	s.totpStatus(w, r)
+}
+
+func (s *Server) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	if !ownSessions(w, r) {
+		return
+	}
+	enrollment, err := s.bank.EnrollTOTP(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, enrollment)
+}
+
+func (s *Server) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var req totpConfirmRequest
+	if !ownSessions(w, r) || !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.bank.ConfirmTOTP(r.PathValue("id"), req.Code); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.totpStatus(w, r)
+}
+
+func (s *Server) handleRemoveTOTP(w http.ResponseWriter, r *http.Request) {
+	if !ownSessions(w, r) {
+		return
+	}
+	if err := s.bank.RemoveTOTP(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.totpStatus(w, r)
+}
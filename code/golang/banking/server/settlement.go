@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+// bookRequest books a transfer to To that settles SettlementDays business
+// days later.
+type bookRequest struct {
+	To             string  `json:"to"`
+	Amount         float64 `json:"amount"`
+	SettlementDays int     `json:"settlement_days"`
+	Description    string  `json:"description,omitempty"`
+	Category       string  `json:"category,omitempty"`
+}
+
+func (s *Server) handleBookTransfer(w http.ResponseWriter, r *http.Request) {
+	var req bookRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.resolve(&req.To); err != nil {
+		writeError(w, err)
+		return
+	}
+	pt, err := s.bank.BookTransfer(r.PathValue("number"), req.To, req.Amount, req.SettlementDays,
+		models.WithDescription(req.Description), models.WithCategory(req.Category), byHolder(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, pt)
+}
+
+func (s *Server) handlePendingTransfers(w http.ResponseWriter, r *http.Request) {
+	pending := s.bank.Pending(r.PathValue("number"))
+	if pending == nil {
+		pending = []models.PendingTransfer{}
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+func (s *Server) handleCancelPending(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, banking.New(banking.CodeInvalidArgument, "pending transfer id must be a number"))
+		return
+	}
+	pt, err := s.bank.CancelPending(r.PathValue("number"), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pt)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/shared"
+)
+
+// claimTTL is how long a request with an idempotency key holds it while it
+// runs, and recordTTL how long its response is then replayed to retries.
+const (
+	claimTTL  = time.Minute
+	recordTTL = 24 * time.Hour
+)
+
+var ErrRateLimited = banking.New(banking.CodeRateLimited, "too many requests")
+
+// WithShared keeps idempotency keys and rate-limit buckets in b, such as
+// Redis, so that replicas behind one load balancer share them. By default
+// they are kept in memory.
+func WithShared(b shared.Backend) Option {
+	return func(s *Server) { s.shared = b }
+}
+
+// WithRateLimit limits each client, by remote address, or API key, to rate
+// API requests. A zero rate does not limit, but for keys with a rate limit
+// of their own.
+func WithRateLimit(rate shared.Rate) Option {
+	return func(s *Server) { s.rate = rate }
+}
+
+// limited answers 429 Too Many Requests to clients past the rate limit. If
+// the limiter fails the request is let through rather than the API going
+// down with it.
+func (s *Server) limited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, rate := s.keyRate(r)
+		if rate.Limit <= 0 {
+			next(w, r)
+			return
+		}
+		ok, retryAfter, err := s.shared.Allow(r.Context(), client, rate)
+		if err != nil {
+			log.Printf("rate limit of %s: %v", client, err)
+		} else if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.999)))
+			writeError(w, ErrRateLimited)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// idempotent carries out a request with an Idempotency-Key header once: a
+// retry with the same key, method and path gets the recorded response, and
+// one made while the first is still running gets a retryable conflict.
+// Responses with server errors are not recorded, so those can be retried.
+// The ID of the record, from the bank's IDs, is sent as Idempotency-Record
+// with the response and every replay of it.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method == http.MethodGet {
+			next(w, r)
+			return
+		}
+		key = r.Method + " " + r.URL.Path + " " + key
+		recorded, err := s.shared.Claim(r.Context(), key, claimTTL)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if recorded != nil {
+			if recorded.ContentType != "" {
+				w.Header().Set("Content-Type", recorded.ContentType)
+			}
+			if recorded.ID != "" {
+				w.Header().Set("Idempotency-Record", recorded.ID)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(recorded.Status)
+			w.Write(recorded.Body)
+			return
+		}
+
+		id := s.bank.NewID()
+		w.Header().Set("Idempotency-Record", id)
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		if rec.status >= 500 {
+			err = s.shared.Release(r.Context(), key)
+		} else {
+			err = s.shared.Record(r.Context(), key, shared.Response{ID: id, Status: rec.status, ContentType: w.Header().Get("Content-Type"), Body: rec.body.Bytes()}, recordTTL)
+		}
+		if err != nil {
+			log.Printf("idempotency key %q: %v", key, err)
+		}
+	}
+}
+
+// recorder passes a response through while keeping its status and body.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+
+	"gsolano/banking"
+	"gsolano/banking/signing"
+)
+
+var ErrSigningDisabled = banking.New(banking.CodeFeatureDisabled, "the bank has no signing key")
+
+// WithSigningKey signs statements and receipts: their responses carry the
+// signature of the body in the Signature-Ed25519 header.
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(s *Server) { s.signingKey = key }
+}
+
+// signingKeyJSON is the public key signed responses are verified with, as
+// a PEM block.
+type signingKeyJSON struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+func (s *Server) handleSigningKey(w http.ResponseWriter, r *http.Request) {
+	if s.signingKey == nil {
+		writeError(w, ErrSigningDisabled)
+		return
+	}
+	pub, err := signing.MarshalPublicKey(s.signingKey.Public().(ed25519.PublicKey))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, signingKeyJSON{Algorithm: "ed25519", PublicKey: string(pub)})
+}
+
+// writeSigned is writeJSON with the body signed when the server has a
+// signing key. Saved as it was sent, the body verifies with bank verify.
+func (s *Server) writeSigned(w http.ResponseWriter, status int, v any) {
+	if s.signingKey == nil {
+		writeJSON(w, status, v)
+		return
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(signing.Header, signing.Sign(s.signingKey, body.Bytes()))
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+type statementJSON struct {
+	Account      string                 `json:"account"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Partial      bool                   `json:"partial"`
+	Opening      float64                `json:"opening"`
+	Closing      float64                `json:"closing"`
+	Transactions []models.Transaction   `json:"transactions"`
+	Lines        []models.StatementLine `json:"lines,omitempty"`
+}
+
+type cycleRequest struct {
+	Kind models.CycleKind `json:"kind"`
+	Day  int              `json:"day,omitempty"`
+	Days int              `json:"days,omitempty"`
+}
+
+// timeZoneJSON is the time zone of an account, by its IANA name.
+type timeZoneJSON struct {
+	TimeZone string `json:"time_zone"`
+}
+
+var statementQuery = map[string]string{
+	"at": "A date (YYYY-MM-DD) in the period of the statement, in the zone of the account, today by default",
+}
+
+func (s *Server) handleAccountStatement(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+	at := time.Now()
+	if err := parseQuery(r.URL.Query(), "at", dateIn{&at, s.bank.TimeZone(number)}); err != nil {
+		writeError(w, err)
+		return
+	}
+	statement, err := s.bank.Statement(number, at)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeSigned(w, http.StatusOK, toStatementJSON(statement))
+}
+
+func toStatementJSON(st models.Statement) statementJSON {
+	return statementJSON{
+		Account: st.Account, Start: st.Period.Start, End: st.Period.End, Partial: st.Period.Partial,
+		Opening: st.Opening, Closing: st.Closing, Transactions: append([]models.Transaction{}, st.Transactions...),
+		Lines: st.Lines,
+	}
+}
+
+func (s *Server) handleGetCycle(w http.ResponseWriter, r *http.Request) {
+	cycle, err := s.bank.StatementCycle(r.PathValue("number"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cycle)
+}
+
+func (s *Server) handleSetCycle(w http.ResponseWriter, r *http.Request) {
+	var req cycleRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	number := r.PathValue("number")
+	if err := s.bank.SetStatementCycle(number, models.StatementCycle{Kind: req.Kind, Day: req.Day, Days: req.Days}); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleGetCycle(w, r)
+}
+
+func (s *Server) handleGetTimeZone(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+	if _, err := s.bank.Account(number); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, timeZoneJSON{TimeZone: s.bank.TimeZone(number).String()})
+}
+
+func (s *Server) handleSetTimeZone(w http.ResponseWriter, r *http.Request) {
+	var req timeZoneJSON
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := s.bank.SetTimeZone(r.PathValue("number"), req.TimeZone); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleGetTimeZone(w, r)
+}
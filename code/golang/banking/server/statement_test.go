@@ -0,0 +1,45 @@
+package server
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"gsolano/banking/golden"
+	"gsolano/banking/models"
+	"gsolano/banking/sim"
+)
+
+// TestStatementJSONGolden compares the statement the API returns for a
+// month of a checking account with testdata/statement.json.
+func TestStatementJSONGolden(t *testing.T) {
+	models.SetOutput(io.Discard)
+	clock := sim.NewClock(time.Date(2026, 2, 20, 9, 0, 0, 0, time.UTC))
+	bank := models.NewBank()
+	bank.Clock = clock
+	bank.IDs = &models.SequentialIDs{Prefix: "tx"}
+	if err := bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: "C1"}, OverdraftLimit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		days int
+		post func() error
+	}{
+		{0, func() error { return bank.Deposit("C1", 500, models.WithDescription("opening deposit")) }},
+		{10, func() error { return bank.Deposit("C1", 2500, models.WithDescription("salary")) }},
+		{2, func() error { return bank.Withdraw("C1", 1200, models.WithDescription("rent")) }},
+		{6, func() error { return bank.Withdraw("C1", 64.3, models.WithDescription("groceries")) }},
+		{25, func() error { return bank.Withdraw("C1", 12, models.WithDescription("next month")) }},
+	}
+	for _, s := range steps {
+		clock.Advance(time.Duration(s.days) * 24 * time.Hour)
+		if err := s.post(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statement, err := bank.Statement("C1", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden.AssertJSON(t, "statement.json", toStatementJSON(statement))
+}
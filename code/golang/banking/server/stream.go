@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/websocket"
+)
+
+const (
+	// streamPrefix is where account streams are served, the only routes
+	// that take the token in the query.
+	streamPrefix = "/ws/accounts/"
+	// streamBuffer is how many messages may queue up for a slow client
+	// before it is disconnected rather than holding back the event bus.
+	streamBuffer = 64
+	writeTimeout = 10 * time.Second
+	pingInterval = 30 * time.Second
+)
+
+type streamMessage struct {
+	Type        string              `json:"type"`
+	Account     string              `json:"account"`
+	Balance     float64             `json:"balance"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+}
+
+// handleAccountStream pushes the balance of an account and every new
+// transaction on it to a WebSocket client. The first message is a balance
+// snapshot.
+func (s *Server) handleAccountStream(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+	balance, err := s.bank.Balance(number)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	messages := make(chan streamMessage, streamBuffer)
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+	messages <- streamMessage{Type: "balance", Account: number, Balance: balance}
+
+	unsubscribe := s.bank.Events.Subscribe(func(e models.Event) {
+		if e.Type != models.EventTransactionPosted || e.AccountNumber != number {
+			return
+		}
+		balance, _ := s.bank.Balance(number)
+		select {
+		case messages <- streamMessage{Type: "transaction", Account: number, Balance: balance, Transaction: e.Transaction}:
+		default:
+			// Never block the publisher on a slow client.
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	})
+	defer unsubscribe()
+
+	// The client is not expected to send anything, but reading is how pings
+	// get answered and a close from the other side is noticed.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case msg := <-messages:
+			data, _ := json.Marshal(msg)
+			if err := conn.WriteText(data, writeTimeout); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if err := conn.Ping(writeTimeout); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-overflow:
+			log.Printf("stream %s: client too slow, disconnecting", number)
+			conn.Close(websocket.CloseTryAgainLater, "client too slow")
+			return
+		case <-done:
+			conn.Close(websocket.CloseNormal, "")
+			return
+		case <-r.Context().Done():
+			conn.Close(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/websocket"
+)
+
+// wsClient is the client end of an account stream.
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialStream opens path on srv as a WebSocket and returns the handshake's
+// status and, if it switched protocols, the client.
+func dialStream(t *testing.T, srv *httptest.Server, path string) (int, *wsClient) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, &wsClient{conn: conn, r: r}
+}
+
+// next returns the next frame's opcode and payload.
+func (c *wsClient) next(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+// message decodes the next frame, which must be a stream message.
+func (c *wsClient) message(t *testing.T) streamMessage {
+	t.Helper()
+	opcode, payload := c.next(t)
+	if opcode != 0x1 {
+		t.Fatalf("got frame %#x %q, want a text message", opcode, payload)
+	}
+	var msg streamMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func streamBank(t *testing.T) *models.Bank {
+	t.Helper()
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	for _, number := range []string{"C0001", "C0002"} {
+		bank.Open(&models.CheckingAccount{Account: models.Account{AccountNumber: number}})
+	}
+	if err := bank.Deposit("C0001", 100); err != nil {
+		t.Fatal(err)
+	}
+	return bank
+}
+
+// TestAccountStreamAuth checks the token is required on the upgrade
+// request, in the query as browsers cannot set headers on WebSockets, that
+// unknown accounts are refused before upgrading and that the API does not
+// take the token in the query.
+func TestAccountStreamAuth(t *testing.T) {
+	srv := httptest.NewServer(New(streamBank(t), WithToken("secret")))
+	defer srv.Close()
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/ws/accounts/C0001", http.StatusUnauthorized},
+		{"/ws/accounts/C0001?token=wrong", http.StatusUnauthorized},
+		{"/ws/accounts/C0001?token=secret", http.StatusSwitchingProtocols},
+		{"/ws/accounts/C9999?token=secret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got, _ := dialStream(t, srv, tt.path); got != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		path, authorization string
+		want                int
+	}{
+		{"/api/accounts/C0001?token=secret", "", http.StatusUnauthorized},
+		{"/api/accounts/C0001", "Bearer secret", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+tt.path, nil)
+		req.Header.Set("Authorization", tt.authorization)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestAccountStream checks that a subscriber first gets the balance and
+// then every transaction on its account, and none on others.
+func TestAccountStream(t *testing.T) {
+	bank := streamBank(t)
+	srv := httptest.NewServer(New(bank))
+	defer srv.Close()
+	status, c := dialStream(t, srv, "/ws/accounts/C0001")
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", status)
+	}
+	if msg := c.message(t); msg.Type != "balance" || msg.Account != "C0001" || msg.Balance != 100 {
+		t.Fatalf("first message = %+v, want the balance of 100", msg)
+	}
+
+	if err := bank.Deposit("C0002", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.Withdraw("C0001", 30); err != nil {
+		t.Fatal(err)
+	}
+	msg := c.message(t)
+	if msg.Type != "transaction" || msg.Account != "C0001" || msg.Balance != 70 {
+		t.Fatalf("next message = %+v, want the withdrawal from C0001", msg)
+	}
+	if msg.Transaction == nil || msg.Transaction.Type != models.TransactionWithdrawal || msg.Transaction.Amount != 30 {
+		t.Errorf("transaction = %+v", msg.Transaction)
+	}
+}
+
+// TestAccountStreamSlowConsumer checks that a client that stops reading is
+// disconnected with 1013 rather than holding back the publisher.
+func TestAccountStreamSlowConsumer(t *testing.T) {
+	bank := streamBank(t)
+	srv := httptest.NewServer(New(bank))
+	defer srv.Close()
+	status, c := dialStream(t, srv, "/ws/accounts/C0001")
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", status)
+	}
+	c.message(t)
+
+	// Large entries fill the socket buffers so the server stops writing and
+	// its queue fills up. Nothing reads meanwhile, so publishing would hang
+	// here if it waited for the client.
+	tx := &models.Transaction{Type: models.TransactionDeposit, Amount: 1, Metadata: map[string]string{"padding": strings.Repeat("x", 32<<10)}}
+	for i := 0; i < 1000; i++ {
+		bank.Events.Publish(models.Event{Type: models.EventTransactionPosted, AccountNumber: "C0001", Transaction: tx})
+	}
+
+	for {
+		opcode, payload := c.next(t)
+		if opcode == 0x1 {
+			continue
+		}
+		if opcode != 0x8 || len(payload) < 2 {
+			t.Fatalf("got frame %#x, want text or close", opcode)
+		}
+		if code := binary.BigEndian.Uint16(payload); code != websocket.CloseTryAgainLater {
+			t.Errorf("closed with %d, want %d", code, websocket.CloseTryAgainLater)
+		}
+		return
+	}
+}
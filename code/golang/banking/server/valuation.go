@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"gsolano/banking/models"
+)
+
+var valuationQuery = map[string]string{
+	"currency": "Currency to value the accounts in, the bank's by default",
+	"at":       "Day to value the accounts at the end of, such as 2024-01-31; now by default",
+}
+
+var netWorthQuery = map[string]string{
+	"currency": "Currency to value the accounts in, the bank's by default",
+	"from":     "First day of the series, such as 2024-01-31",
+	"to":       "Last day of the series, today by default",
+	"every":    "Cadence of the series: weekly, biweekly, monthly (the default) or yearly",
+}
+
+// endOfDay returns the last instant of the day of t, so that valuations of a
+// day count its entries.
+func endOfDay(t time.Time) time.Time {
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+func (s *Server) handleValuation(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "valuations") {
+		return
+	}
+	q := r.URL.Query()
+	at := time.Time{}
+	if err := parseQuery(q, "at", &at); err != nil {
+		writeError(w, err)
+		return
+	}
+	if at.IsZero() {
+		at = s.bank.Clock.Now()
+	} else {
+		at = endOfDay(at)
+	}
+	valuation, err := s.bank.ValueCustomer(r.PathValue("id"), s.reportingCurrency(q.Get("currency")), at)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, valuation)
+}
+
+func (s *Server) handleNetWorth(w http.ResponseWriter, r *http.Request) {
+	if !ownCustomer(w, r, r.PathValue("id"), "valuations") {
+		return
+	}
+	q := r.URL.Query()
+	var from, to time.Time
+	if err := parseQuery(q, "from", &from); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := parseQuery(q, "to", &to); err != nil {
+		writeError(w, err)
+		return
+	}
+	if to.IsZero() {
+		to = s.bank.Clock.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(-1, 0, 0)
+	}
+	every := models.Cadence(q.Get("every"))
+	if every == "" {
+		every = models.CadenceMonthly
+	}
+	series, err := s.bank.NetWorth(r.PathValue("id"), s.reportingCurrency(q.Get("currency")), endOfDay(from), endOfDay(to), every)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, series)
+}
+
+func (s *Server) reportingCurrency(currency string) string {
+	if currency == "" {
+		return s.bank.Currency
+	}
+	return currency
+}
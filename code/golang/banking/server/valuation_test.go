@@ -0,0 +1,41 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// TestValuationAccess checks that a customer may only value their own
+// accounts.
+func TestValuationAccess(t *testing.T) {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	bank.Currency = "USD"
+	bank.AddCustomer(&models.Customer{ID: "c1", Name: "Ada"})
+	s := New(bank)
+	tests := []struct {
+		path, holder string
+		want         int
+	}{
+		{"/api/customers/c1/valuation", "c2", http.StatusForbidden},
+		{"/api/customers/c1/net-worth", "c2", http.StatusForbidden},
+		{"/api/customers/c1/valuation", "c1", http.StatusOK},
+		{"/api/customers/c1/net-worth", "c1", http.StatusOK},
+		{"/api/customers/c1/valuation", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.holder != "" {
+			r.Header.Set(holderHeader, tt.holder)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("GET %s as %q = %d %s, want %d", tt.path, tt.holder, w.Code, w.Body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package shared_test
+
+import (
+	"testing"
+
+	"gsolano/banking/shared"
+	"gsolano/banking/shared/sharedtest"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	sharedtest.RunBackendTests(t, func(t *testing.T) shared.Backend { return shared.NewMemory() })
+}
@@ -0,0 +1,178 @@
+package shared
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Memory keeps shared state in the process that uses it.
+type Memory struct {
+	mu      sync.Mutex
+	keys    map[string]idempotencyEntry
+	buckets map[string]bucket
+	locks   map[string]lockEntry
+	nextID  int64
+	swept   time.Time
+}
+
+// sweepInterval is how often Memory drops expired keys, full buckets and
+// expired locks, so its maps do not grow with every client ever seen.
+const sweepInterval = time.Minute
+
+type idempotencyEntry struct {
+	response *Response // nil while claimed
+	expires  time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time // when it refills to its burst
+}
+
+type lockEntry struct {
+	id      int64
+	expires time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		keys:    make(map[string]idempotencyEntry),
+		buckets: make(map[string]bucket),
+		locks:   make(map[string]lockEntry),
+	}
+}
+
+func (m *Memory) Claim(ctx context.Context, key string, ttl time.Duration) (*Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+	if e, ok := m.keys[key]; ok && now.Before(e.expires) {
+		if e.response == nil {
+			return nil, ErrInProgress
+		}
+		r := *e.response
+		return &r, nil
+	}
+	m.keys[key] = idempotencyEntry{expires: now.Add(ttl)}
+	return nil, nil
+}
+
+func (m *Memory) Record(ctx context.Context, key string, r Response, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = idempotencyEntry{response: &r, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *Memory) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.keys[key]; ok && e.response == nil {
+		delete(m.keys, key)
+	}
+	return nil
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(rate.Burst), at: now}
+	}
+	b.tokens = math.Min(float64(rate.Burst), b.tokens+now.Sub(b.at).Seconds()*rate.Limit)
+	b.at = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(refill(float64(rate.Burst)-b.tokens, rate))
+	m.buckets[key] = b
+	if !allowed {
+		return false, refill(1-b.tokens, rate), nil
+	}
+	return true, 0, nil
+}
+
+// refill is how long rate takes to add tokens to a bucket.
+func refill(tokens float64, rate Rate) time.Duration {
+	return time.Duration(tokens / rate.Limit * float64(time.Second))
+}
+
+func (m *Memory) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	id, err := m.lock(key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return func() { m.unlock(key, id) }, nil
+}
+
+func (m *Memory) Hold(ctx context.Context, key string, ttl time.Duration) (<-chan struct{}, error) {
+	id, err := m.lock(key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	renew := func() (bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		l, ok := m.locks[key]
+		if !ok || l.id != id {
+			return false, nil
+		}
+		m.locks[key] = lockEntry{id: id, expires: time.Now().Add(ttl)}
+		return true, nil
+	}
+	return Keep(ctx, ttl, renew, func() { m.unlock(key, id) }), nil
+}
+
+// lock takes the lock named key for ttl, returning the ID it was taken
+// with.
+func (m *Memory) lock(key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if l, ok := m.locks[key]; ok && now.Before(l.expires) {
+		return 0, ErrLocked
+	}
+	m.nextID++
+	m.locks[key] = lockEntry{id: m.nextID, expires: now.Add(ttl)}
+	return m.nextID, nil
+}
+
+// unlock releases the lock named key if it was taken with id. It may have
+// expired and been taken by someone else.
+func (m *Memory) unlock(key string, id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.locks[key]; ok && l.id == id {
+		delete(m.locks, key)
+	}
+}
+
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+	for key, e := range m.keys {
+		if !now.Before(e.expires) {
+			delete(m.keys, key)
+		}
+	}
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	for key, l := range m.locks {
+		if !now.Before(l.expires) {
+			delete(m.locks, key)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestMemorySweep checks that expired keys, refilled buckets and expired
+// locks are dropped once the sweep interval has passed, and nothing else.
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	m.Claim(ctx, "expired", time.Millisecond)
+	m.Record(ctx, "kept", Response{Status: 200}, time.Hour)
+	m.Allow(ctx, "refilled", Rate{Limit: 1000, Burst: 1})
+	m.Allow(ctx, "empty", Rate{Limit: 0.001, Burst: 1})
+	m.TryLock(ctx, "expired", time.Millisecond)
+	m.TryLock(ctx, "held", time.Hour)
+	time.Sleep(5 * time.Millisecond)
+
+	m.sweep(time.Now())
+	if len(m.keys) != 2 || len(m.buckets) != 2 || len(m.locks) != 2 {
+		t.Fatalf("swept within the interval: %d keys, %d buckets, %d locks", len(m.keys), len(m.buckets), len(m.locks))
+	}
+	m.sweep(time.Now().Add(sweepInterval))
+	tests := []struct {
+		name string
+		kept bool
+		want bool
+	}{
+		{"expired key", has(m.keys, "expired"), false},
+		{"recorded key", has(m.keys, "kept"), true},
+		{"refilled bucket", has(m.buckets, "refilled"), false},
+		{"empty bucket", has(m.buckets, "empty"), true},
+		{"expired lock", has(m.locks, "expired"), false},
+		{"held lock", has(m.locks, "held"), true},
+	}
+	for _, tt := range tests {
+		if tt.kept != tt.want {
+			t.Errorf("%s kept = %v, want %v", tt.name, tt.kept, tt.want)
+		}
+	}
+}
+
+func has[V any](m map[string]V, key string) bool {
+	_, ok := m[key]
+	return ok
+}
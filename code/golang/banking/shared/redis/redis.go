@@ -0,0 +1,195 @@
+// Package redis keeps the state that replicas of bankserver share in Redis,
+// so that a retried request, a client's rate limit and a lock mean the same
+// on every replica. Each operation is one command or script, atomic on the
+// Redis server, and time is read from the server so that replicas with
+// skewed clocks fill rate-limit buckets alike.
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"gsolano/banking/shared"
+)
+
+// Backend is shared state in a Redis server, under keys starting with a
+// prefix.
+type Backend struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+var _ shared.Backend = (*Backend)(nil)
+
+// Open connects to the Redis server at url, such as
+// redis://:password@cache:6379/0, and checks that it answers.
+func Open(ctx context.Context, url string) (*Backend, error) {
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := goredis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return New(client, "bank:"), nil
+}
+
+// New returns a Backend on client that names its keys with prefix.
+func New(client goredis.UniversalClient, prefix string) *Backend {
+	return &Backend{client: client, prefix: prefix}
+}
+
+func (b *Backend) Close() error {
+	return b.client.Close()
+}
+
+// claim returns the value of an idempotency key, or claims it with an empty
+// value and returns nil.
+var claim = goredis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	return v
+end
+redis.call('SET', KEYS[1], '', 'PX', ARGV[1])
+return false
+`)
+
+// release deletes an idempotency key that is claimed but has no response.
+var release = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == '' then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (b *Backend) Claim(ctx context.Context, key string, ttl time.Duration) (*shared.Response, error) {
+	v, err := claim.Run(ctx, b.client, []string{b.prefix + "idempotency:" + key}, ttl.Milliseconds()).Text()
+	switch {
+	case errors.Is(err, goredis.Nil):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case v == "":
+		return nil, shared.ErrInProgress
+	}
+	var r shared.Response
+	if err := json.Unmarshal([]byte(v), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (b *Backend) Record(ctx context.Context, key string, r shared.Response, ttl time.Duration) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return b.client.Set(ctx, b.prefix+"idempotency:"+key, data, ttl).Err()
+}
+
+func (b *Backend) Release(ctx context.Context, key string) error {
+	return release.Run(ctx, b.client, []string{b.prefix + "idempotency:" + key}).Err()
+}
+
+// take takes a token from the bucket hashed at KEYS[1], refilled at
+// ARGV[1] tokens a second up to ARGV[2], and returns whether it could and
+// otherwise how many milliseconds until it can. The bucket expires once
+// full, when it is the same as no bucket.
+var take = goredis.NewScript(`
+local limit, burst = tonumber(ARGV[1]), tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + t[2] / 1000
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(b[1]) or burst
+local at = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + (now - at) / 1000 * limit)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / limit * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / limit * 1000) + 1)
+return {allowed, wait}
+`)
+
+func (b *Backend) Allow(ctx context.Context, key string, rate shared.Rate) (bool, time.Duration, error) {
+	res, err := take.Run(ctx, b.client, []string{b.prefix + "ratelimit:" + key}, rate.Limit, rate.Burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// unlock deletes a lock only if it still holds the value its holder set,
+// rather than a lock taken by someone else after it expired.
+var unlock = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// renew extends a lock by ARGV[2] milliseconds only if it still holds the
+// value its holder set.
+var renew = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (b *Backend) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	key, token, err := b.lock(ctx, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return func() { b.unlock(key, token) }, nil
+}
+
+func (b *Backend) Hold(ctx context.Context, key string, ttl time.Duration) (<-chan struct{}, error) {
+	key, token, err := b.lock(ctx, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	extend := func() (bool, error) {
+		n, err := renew.Run(context.Background(), b.client, []string{key}, token, ttl.Milliseconds()).Int()
+		return n == 1, err
+	}
+	return shared.Keep(ctx, ttl, extend, func() { b.unlock(key, token) }), nil
+}
+
+// lock sets the lock named key to a random token for ttl, returning its
+// Redis key and the token.
+func (b *Backend) lock(ctx context.Context, key string, ttl time.Duration) (string, string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(id[:])
+	key = b.prefix + "lock:" + key
+	ok, err := b.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return "", "", shared.ErrLocked
+	}
+	return key, token, nil
+}
+
+// unlock releases a lock taken with token. A lock that cannot be released
+// expires after its ttl.
+func (b *Backend) unlock(key, token string) {
+	unlock.Run(context.Background(), b.client, []string{key}, token)
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"gsolano/banking/shared"
+	"gsolano/banking/shared/sharedtest"
+)
+
+// prefixes numbers the key prefixes the tests use.
+var prefixes atomic.Int64
+
+// TestConformance runs the shared state conformance suite against the
+// Redis server at $BANK_TEST_REDIS_URL, each case under a key prefix of its
+// own that is deleted when it ends:
+//
+//	BANK_TEST_REDIS_URL=redis://localhost:6379/15 go test ./shared/redis
+func TestConformance(t *testing.T) {
+	url := os.Getenv("BANK_TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("BANK_TEST_REDIS_URL is not set")
+	}
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedtest.RunBackendTests(t, func(t *testing.T) shared.Backend {
+		ctx := context.Background()
+		client := goredis.NewClient(opts)
+		if err := client.Ping(ctx).Err(); err != nil {
+			t.Fatal(err)
+		}
+		prefix := fmt.Sprintf("sharedtest:%d:%d:", time.Now().UnixNano(), prefixes.Add(1))
+		t.Cleanup(func() {
+			keys, _ := client.Keys(ctx, prefix+"*").Result()
+			if len(keys) > 0 {
+				client.Del(ctx, keys...)
+			}
+			client.Close()
+		})
+		return New(client, prefix)
+	})
+}
@@ -0,0 +1,109 @@
+// Package shared keeps the state that replicas of bankserver must agree on
+// to serve the same clients: the responses recorded for idempotency keys,
+// rate-limit buckets and locks. Memory keeps it in the process, which is
+// enough for a single replica and is the default; shared/redis keeps it in
+// Redis for several.
+package shared
+
+import (
+	"context"
+	"time"
+
+	"gsolano/banking"
+)
+
+var (
+	ErrInProgress = &banking.Error{Code: banking.CodeConflict, Message: "a request with this idempotency key is in progress", Retryable: true}
+	ErrLocked     = banking.New(banking.CodeUnavailable, "lock is held elsewhere")
+)
+
+// Response is what a request with an idempotency key answered, replayed to
+// retries of it. ID identifies the record.
+type Response struct {
+	ID          string `json:"id,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency records the response to each idempotency key, so a request
+// retried with the same key is answered without being carried out again.
+type Idempotency interface {
+	// Claim returns the response recorded for key, if any. Otherwise it
+	// claims key for ttl, for the caller to carry out the request and
+	// Record or Release it, or returns ErrInProgress if another caller
+	// holds the claim.
+	Claim(ctx context.Context, key string, ttl time.Duration) (*Response, error)
+	// Record keeps the response to a claimed key for ttl.
+	Record(ctx context.Context, key string, r Response, ttl time.Duration) error
+	// Release drops the claim on key without recording a response, so the
+	// request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Rate is a token bucket: Burst requests at once, refilled at Limit a
+// second. Limit must be positive.
+type Rate struct {
+	Limit float64
+	Burst int
+}
+
+// Limiter decides whether a client may make another request.
+type Limiter interface {
+	// Allow takes a token from the bucket of key, reporting false and how
+	// long until one is available when it is empty.
+	Allow(ctx context.Context, key string, rate Rate) (ok bool, retryAfter time.Duration, err error)
+}
+
+// Locker holds named locks across replicas, for work only one of them
+// should do at a time.
+type Locker interface {
+	// TryLock takes the lock named key for at most ttl, after which it is
+	// released even if unlock was not called, as when the holder died. It
+	// returns ErrLocked if the lock is held.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error)
+	// Hold takes the lock named key as TryLock does and renews it every
+	// third of ttl until ctx is done, when it is released. The channel is
+	// closed once the lock is no longer held: when ctx is done, or when it
+	// was taken by someone else or could not be renewed for ttl.
+	Hold(ctx context.Context, key string, ttl time.Duration) (lost <-chan struct{}, err error)
+}
+
+// Keep renews a held lock every third of ttl until ctx is done, then calls
+// release, for implementations of Locker.Hold. renew reports whether the
+// lock was still held; an error is retried until ttl has passed since the
+// last renewal. The returned channel is closed when Keep stops.
+func Keep(ctx context.Context, ttl time.Duration, renew func() (bool, error), release func()) <-chan struct{} {
+	lost := make(chan struct{})
+	go func() {
+		defer close(lost)
+		tick := time.NewTicker(ttl / 3)
+		defer tick.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				release()
+				return
+			case now := <-tick.C:
+				held, err := renew()
+				switch {
+				case err == nil && !held:
+					return
+				case err == nil:
+					renewed = now
+				case now.Sub(renewed) >= ttl:
+					return
+				}
+			}
+		}
+	}()
+	return lost
+}
+
+// Backend is all of the shared state.
+type Backend interface {
+	Idempotency
+	Limiter
+	Locker
+}
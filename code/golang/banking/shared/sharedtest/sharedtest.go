@@ -0,0 +1,227 @@
+// Package sharedtest is a conformance suite for shared state backends.
+// Every backend runs it from its tests, so that replicas behave the same
+// whichever one they share:
+//
+//	func TestConformance(t *testing.T) {
+//		sharedtest.RunBackendTests(t, func(t *testing.T) shared.Backend {
+//			return shared.NewMemory()
+//		})
+//	}
+//
+// It is meant to be imported by tests only.
+package sharedtest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gsolano/banking/shared"
+)
+
+// Factory returns a new, empty backend for the test t, cleaning it up when
+// t ends.
+type Factory func(t *testing.T) shared.Backend
+
+// RunBackendTests runs the suite against the backends factory makes, each
+// case as a subtest of t on a backend of its own.
+func RunBackendTests(t *testing.T, factory Factory) {
+	cases := []struct {
+		name string
+		test func(t *testing.T, b shared.Backend)
+	}{
+		{"ClaimAndReplay", testClaimAndReplay},
+		{"Release", testRelease},
+		{"ClaimExpires", testClaimExpires},
+		{"RateLimit", testRateLimit},
+		{"RateRefills", testRateRefills},
+		{"TryLock", testTryLock},
+		{"LockExpires", testLockExpires},
+		{"Hold", testHold},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.test(t, factory(t))
+		})
+	}
+}
+
+// testClaimAndReplay checks that a claimed key is in progress for others
+// until its response is recorded, and then replays it.
+func testClaimAndReplay(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	if r, err := b.Claim(ctx, "k", time.Minute); r != nil || err != nil {
+		t.Fatalf("first Claim = %v, %v, want a claim", r, err)
+	}
+	if _, err := b.Claim(ctx, "k", time.Minute); !errors.Is(err, shared.ErrInProgress) {
+		t.Fatalf("Claim of a claimed key = %v, want ErrInProgress", err)
+	}
+	if r, err := b.Claim(ctx, "other", time.Minute); r != nil || err != nil {
+		t.Fatalf("Claim of another key = %v, %v, want a claim", r, err)
+	}
+
+	want := shared.Response{ID: "r1", Status: 201, ContentType: "application/json", Body: []byte(`{"ok":true}`)}
+	if err := b.Record(ctx, "k", want, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		r, err := b.Claim(ctx, "k", time.Minute)
+		if err != nil || r == nil || !reflect.DeepEqual(*r, want) {
+			t.Fatalf("Claim of a recorded key = %+v, %v, want %+v", r, err, want)
+		}
+	}
+}
+
+// testRelease checks that a released claim can be claimed again and that a
+// recorded response is not released.
+func testRelease(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	b.Claim(ctx, "k", time.Minute)
+	if err := b.Release(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := b.Claim(ctx, "k", time.Minute); r != nil || err != nil {
+		t.Fatalf("Claim after Release = %v, %v, want a claim", r, err)
+	}
+
+	b.Record(ctx, "k", shared.Response{Status: 200}, time.Minute)
+	if err := b.Release(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := b.Claim(ctx, "k", time.Minute); err != nil || r == nil || r.Status != 200 {
+		t.Fatalf("Claim after releasing a recorded key = %v, %v, want the response", r, err)
+	}
+	if err := b.Release(ctx, "never claimed"); err != nil {
+		t.Errorf("Release of an unknown key: %v", err)
+	}
+}
+
+// testClaimExpires checks that claims and responses are forgotten after
+// their ttl.
+func testClaimExpires(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	b.Claim(ctx, "claimed", 50*time.Millisecond)
+	b.Record(ctx, "recorded", shared.Response{Status: 200}, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{"claimed", "recorded"} {
+		if r, err := b.Claim(ctx, key, time.Minute); r != nil || err != nil {
+			t.Errorf("Claim of %s after its ttl = %v, %v, want a new claim", key, r, err)
+		}
+	}
+}
+
+// testRateLimit checks that a bucket allows its burst, then refuses with
+// how long until the next token, and that keys have buckets of their own.
+func testRateLimit(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	rate := shared.Rate{Limit: 1, Burst: 3}
+	for i := 0; i < rate.Burst; i++ {
+		if ok, _, err := b.Allow(ctx, "client", rate); !ok || err != nil {
+			t.Fatalf("request %d of the burst refused: %v", i+1, err)
+		}
+	}
+	ok, retry, err := b.Allow(ctx, "client", rate)
+	if ok || err != nil {
+		t.Fatalf("request past the burst = %v, %v, want refused", ok, err)
+	}
+	if retry <= 0 || retry > time.Second {
+		t.Errorf("retry after %s, want at most a second", retry)
+	}
+	if ok, _, _ := b.Allow(ctx, "another", rate); !ok {
+		t.Error("another client was refused")
+	}
+}
+
+// testRateRefills checks that tokens come back at the rate's limit.
+func testRateRefills(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	rate := shared.Rate{Limit: 20, Burst: 1}
+	b.Allow(ctx, "client", rate)
+	if ok, _, _ := b.Allow(ctx, "client", rate); ok {
+		t.Fatal("request past the burst allowed")
+	}
+	time.Sleep(80 * time.Millisecond)
+	if ok, _, err := b.Allow(ctx, "client", rate); !ok || err != nil {
+		t.Errorf("request after a refill = %v, %v, want allowed", ok, err)
+	}
+}
+
+// testTryLock checks that a lock is held by one caller until unlocked.
+func testTryLock(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	unlock, err := b.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.TryLock(ctx, "job", time.Minute); !errors.Is(err, shared.ErrLocked) {
+		t.Fatalf("TryLock of a held lock = %v, want ErrLocked", err)
+	}
+	other, err := b.TryLock(ctx, "other job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock of another lock: %v", err)
+	}
+	defer other()
+	unlock()
+	again, err := b.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock after unlock: %v", err)
+	}
+	again()
+}
+
+// testLockExpires checks that a lock is released after its ttl and that
+// its former holder cannot release it from whoever took it next.
+func testLockExpires(t *testing.T, b shared.Backend) {
+	ctx := context.Background()
+	stale, err := b.TryLock(ctx, "job", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	unlock, err := b.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock after the ttl: %v", err)
+	}
+	defer unlock()
+	stale()
+	if _, err := b.TryLock(ctx, "job", time.Minute); !errors.Is(err, shared.ErrLocked) {
+		t.Errorf("TryLock after a stale unlock = %v, want ErrLocked", err)
+	}
+}
+
+// testHold checks that a held lock outlives its ttl and is released when
+// its context is done.
+func testHold(t *testing.T, b shared.Backend) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lost, err := b.Hold(ctx, "job", 90*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Hold(context.Background(), "job", time.Minute); !errors.Is(err, shared.ErrLocked) {
+		t.Fatalf("Hold of a held lock = %v, want ErrLocked", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, err := b.TryLock(context.Background(), "job", time.Minute); !errors.Is(err, shared.ErrLocked) {
+		t.Fatalf("TryLock past the ttl of a held lock = %v, want ErrLocked", err)
+	}
+	select {
+	case <-lost:
+		t.Fatal("lock lost while held")
+	default:
+	}
+
+	cancel()
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("lock not released when its context was done")
+	}
+	unlock, err := b.TryLock(context.Background(), "job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock after the holder stopped: %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,143 @@
+// Package signing signs what the bank exports, such as statements, receipts
+// and snapshots, with the bank's Ed25519 key, so that whoever receives one
+// can prove with the public key alone that the bank issued it and that it
+// was not changed since. Signatures are detached and base64: the API sends
+// them in the Signature-Ed25519 header of a response, and the CLI writes
+// them next to an exported file, with Extension added to its name.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gsolano/banking"
+)
+
+// Header is the HTTP header a signed response carries its signature in.
+const Header = "Signature-Ed25519"
+
+// Extension is added to the name of an exported file to name its signature.
+const Extension = ".sig"
+
+var ErrInvalidSignature = banking.New(banking.CodeInvalidArgument, "signature does not match; the data was altered or signed with another key")
+
+// Sign returns the signature of data under key.
+func Sign(key ed25519.PrivateKey, data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+}
+
+// Verify checks a signature Sign made of data, ErrInvalidSignature when it
+// does not match.
+func Verify(key ed25519.PublicKey, data []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || !ed25519.Verify(key, data, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// MarshalPrivateKey encodes a key as a PKCS #8 PEM block, the format of
+// openssl genpkey -algorithm ed25519.
+func MarshalPrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKey encodes a key as a PKIX PEM block.
+func MarshalPublicKey(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey decodes a private key MarshalPrivateKey encoded.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("a %T, not an Ed25519 key", key)
+	}
+	return ed, nil
+}
+
+// ParsePublicKey decodes a public key MarshalPublicKey encoded, or the
+// public half of a private key.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block != nil && block.Type == "PRIVATE KEY" {
+		key, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("not a PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("a %T, not an Ed25519 key", key)
+	}
+	return ed, nil
+}
+
+// LoadPrivateKey reads a private key from a PEM file.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadPublicKey reads a public key, or the public half of a private one,
+// from a PEM file.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// WriteFile writes data to path and, with a key, its signature to path
+// with Extension added.
+func WriteFile(path string, data []byte, key ed25519.PrivateKey) error {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	if key == nil {
+		return nil
+	}
+	return os.WriteFile(path+Extension, []byte(Sign(key, data)+"\n"), 0o644)
+}
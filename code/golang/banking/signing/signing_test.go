@@ -0,0 +1,56 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSignAndVerify signs an exported file, reads the keys back from PEM
+// and checks that the file verifies and that an altered one does not.
+func TestSignAndVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := MarshalPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM, err := MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePrivateKey(keyPEM)
+	if err != nil || !parsed.Equal(key) {
+		t.Fatalf("private key read back as %v, %v", parsed, err)
+	}
+	// The public half of the private key verifies as the public key does.
+	for _, data := range [][]byte{pubPEM, keyPEM} {
+		if got, err := ParsePublicKey(data); err != nil || !got.Equal(pub) {
+			t.Fatalf("public key read back as %v, %v", got, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "statement.json")
+	data := []byte(`{"account":"12345","closing":100}` + "\n")
+	if err := WriteFile(path, data, parsed); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := os.ReadFile(path + Extension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, data, string(sig)); err != nil {
+		t.Errorf("signed file: %v", err)
+	}
+	if err := Verify(pub, []byte(`{"account":"12345","closing":1000}`+"\n"), string(sig)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("altered file: %v, want ErrInvalidSignature", err)
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+	if err := Verify(other, data, string(sig)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("another key: %v, want ErrInvalidSignature", err)
+	}
+}
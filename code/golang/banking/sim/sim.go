@@ -0,0 +1,421 @@
+// Package sim runs scripted scenarios against a bank on a simulated clock.
+// A scenario opens accounts, moves money, changes rates and advances time;
+// the run produces a report of every event and the final balances that is
+// the same on every run, so it can be checked in and diffed.
+//
+// Scenarios are YAML:
+//
+//	name: savings with a rate cut
+//	start: 2026-01-01
+//	rates: {base: 4%}
+//	steps:
+//	  - open: {account: S1, kind: savings, balance: 1000, rate: 5%}
+//	  - open: {account: V1, kind: savings, reference: base, spread: 1%}
+//	  - deposit: {account: S1, amount: 250, description: bonus}
+//	  - advance: 720h
+//	  - interest: {account: S1}
+//	  - set_rate: {account: S1, rate: 3%}
+//	  - set_reference: {name: base, rate: 3.5%}
+//	  - transfer: {from: S1, to: V1, amount: 100}
+//	  - book: {from: S1, to: V1, amount: 50, days: 2}
+//	  - advance: 72h
+//	  - expect: {account: S1, balance: 1080.21}
+//	  - reserves: {label: after the transfers}
+//
+// Each step has exactly one action. advance moves the clock and then
+// settles the booked transfers that have come due; expect records a
+// failure in the report when a balance differs by more than half a cent;
+// reserves records the reserve report of the bank's books under the
+// scenario's ratios.
+package sim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// Clock is a models.Clock that only moves when told to.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Scenario is a script to run.
+type Scenario struct {
+	Name     string                `yaml:"name"`
+	Start    string                `yaml:"start"`
+	Rounding string                `yaml:"rounding"`
+	Features map[string]bool       `yaml:"features"`
+	Rates    map[string]money.Rate `yaml:"rates"`
+	Ratios   *Ratios               `yaml:"ratios"`
+	Steps    []Step                `yaml:"steps"`
+}
+
+// Ratios are the reserve and capital ratios reserves steps report against,
+// see models.ReserveRatios; a scenario without them uses the defaults.
+type Ratios struct {
+	Reserve        money.Rate `yaml:"reserve"`
+	Capital        money.Rate `yaml:"capital"`
+	LoanRiskWeight money.Rate `yaml:"loan_risk_weight"`
+	PaidInCapital  float64    `yaml:"paid_in_capital"`
+}
+
+// Step is one action of a scenario; exactly one field is set.
+type Step struct {
+	Open         *OpenStep      `yaml:"open"`
+	Deposit      *AmountStep    `yaml:"deposit"`
+	Withdraw     *AmountStep    `yaml:"withdraw"`
+	Transfer     *TransferStep  `yaml:"transfer"`
+	Book         *TransferStep  `yaml:"book"`
+	Interest     *AccountStep   `yaml:"interest"`
+	SetRate      *RateStep      `yaml:"set_rate"`
+	SetReference *ReferenceStep `yaml:"set_reference"`
+	Close        *AccountStep   `yaml:"close"`
+	Advance      string         `yaml:"advance"`
+	Expect       *ExpectStep    `yaml:"expect"`
+	Reserves     *ReservesStep  `yaml:"reserves"`
+}
+
+// OpenStep opens an account. Kind is savings, checking, loan or plain. A
+// savings account earns Rate, or Reference plus Spread when Reference is
+// set. A loan of Principal at Rate over TermMonths opens without paying it
+// out: withdraw it to pay it away, or transfer it to an account. Currency
+// is that of the account, the bank's when empty.
+type OpenStep struct {
+	Account    string     `yaml:"account"`
+	Kind       string     `yaml:"kind"`
+	Balance    float64    `yaml:"balance"`
+	Rate       money.Rate `yaml:"rate"`
+	Reference  string     `yaml:"reference"`
+	Spread     money.Rate `yaml:"spread"`
+	Overdraft  float64    `yaml:"overdraft"`
+	Principal  float64    `yaml:"principal"`
+	TermMonths int        `yaml:"term_months"`
+	Currency   string     `yaml:"currency"`
+}
+
+type AmountStep struct {
+	Account      string  `yaml:"account"`
+	Amount       float64 `yaml:"amount"`
+	Counterparty string  `yaml:"counterparty"`
+	Category     string  `yaml:"category"`
+	Description  string  `yaml:"description"`
+}
+
+// TransferStep moves money now, or for book, settles Days business days
+// later.
+type TransferStep struct {
+	From   string  `yaml:"from"`
+	To     string  `yaml:"to"`
+	Amount float64 `yaml:"amount"`
+	Days   int     `yaml:"days"`
+}
+
+type AccountStep struct {
+	Account string `yaml:"account"`
+}
+
+type RateStep struct {
+	Account string     `yaml:"account"`
+	Rate    money.Rate `yaml:"rate"`
+}
+
+type ReferenceStep struct {
+	Name string     `yaml:"name"`
+	Rate money.Rate `yaml:"rate"`
+}
+
+type ExpectStep struct {
+	Account string  `yaml:"account"`
+	Balance float64 `yaml:"balance"`
+}
+
+// ReservesStep records the reserve report, under Label.
+type ReservesStep struct {
+	Label string `yaml:"label"`
+}
+
+func (r Ratios) ratios() models.ReserveRatios {
+	return models.ReserveRatios{Reserve: r.Reserve, Capital: r.Capital, LoanRiskWeight: r.LoanRiskWeight, PaidInCapital: r.PaidInCapital}
+}
+
+// Load reads a scenario file.
+func Load(path string) (Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return Scenario{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Report is the outcome of a run. Failures lists the expectations that did
+// not hold and the steps that failed.
+type Report struct {
+	Name     string
+	Events   []models.Event
+	Balances []Balance
+	Reserves []Reserves
+	Failures []string
+}
+
+// Reserves is the reserve report a reserves step recorded, one per
+// currency of the books.
+type Reserves struct {
+	Time    time.Time
+	Label   string
+	Reports []models.ReserveReport
+}
+
+type Balance struct {
+	Account string
+	Balance float64
+}
+
+// Passed reports whether every step succeeded and every expectation held.
+func (r Report) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Run plays a scenario on a fresh bank. Steps that fail are recorded in the
+// report and the run goes on, as a real bank would; a malformed scenario is
+// an error.
+func Run(s Scenario) (Report, error) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if s.Start != "" {
+		var err error
+		if start, err = time.Parse(time.DateOnly, s.Start); err != nil {
+			return Report{}, fmt.Errorf("start: %q is not a date", s.Start)
+		}
+	}
+	clock := NewClock(start)
+	rates := models.StaticRates{}
+	for name, rate := range s.Rates {
+		rates[name] = rate
+	}
+
+	bank := models.NewBank()
+	bank.Clock = clock
+	bank.Rates = rates
+	bank.IDs = &models.DeterministicIDs{}
+	if s.Rounding != "" {
+		r, err := money.ParseRounding(s.Rounding)
+		if err != nil {
+			return Report{}, err
+		}
+		bank.Rounding = r
+	}
+	for name, on := range s.Features {
+		bank.Flags.Set(models.Feature(name), on)
+	}
+	if r := s.Ratios; r != nil {
+		bank.Reserves = r.ratios()
+	}
+
+	report := Report{Name: s.Name}
+	bank.Events.Subscribe(func(e models.Event) {
+		report.Events = append(report.Events, e)
+	})
+	for i, step := range s.Steps {
+		fail, err := apply(bank, clock, rates, step)
+		if err != nil {
+			return Report{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if step.Reserves != nil {
+			report.Reserves = append(report.Reserves, Reserves{Time: clock.Now(), Label: step.Reserves.Label, Reports: bank.ReserveReport(time.Time{})})
+		}
+		if fail != "" {
+			report.Failures = append(report.Failures, fmt.Sprintf("step %d: %s", i+1, fail))
+		}
+	}
+	for _, account := range bank.Accounts() {
+		report.Balances = append(report.Balances, Balance{account.Number(), account.CheckBalance()})
+	}
+	return report, nil
+}
+
+// apply runs one step. It returns a failure for steps the bank rejected or
+// expectations that did not hold, and an error for a malformed step.
+func apply(bank *models.Bank, clock *Clock, rates models.StaticRates, step Step) (string, error) {
+	var err error
+	switch set := actions(step); {
+	case len(set) != 1:
+		return "", fmt.Errorf("want exactly one action, got %v", set)
+	case step.Open != nil:
+		err = open(bank, *step.Open)
+	case step.Deposit != nil:
+		err = bank.Deposit(step.Deposit.Account, step.Deposit.Amount, step.Deposit.options()...)
+	case step.Withdraw != nil:
+		err = bank.Withdraw(step.Withdraw.Account, step.Withdraw.Amount, step.Withdraw.options()...)
+	case step.Transfer != nil:
+		err = bank.Transfer(step.Transfer.From, step.Transfer.To, step.Transfer.Amount)
+	case step.Book != nil:
+		_, err = bank.BookTransfer(step.Book.From, step.Book.To, step.Book.Amount, step.Book.Days)
+	case step.Interest != nil:
+		err = bank.ApplyInterest(step.Interest.Account)
+	case step.SetRate != nil:
+		err = setRate(bank, *step.SetRate)
+	case step.SetReference != nil:
+		rates[step.SetReference.Name] = step.SetReference.Rate
+	case step.Close != nil:
+		err = bank.CloseAccount(step.Close.Account)
+	case step.Advance != "":
+		d, perr := time.ParseDuration(step.Advance)
+		if perr != nil || d < 0 {
+			return "", fmt.Errorf("advance: %q is not a positive duration", step.Advance)
+		}
+		clock.Advance(d)
+		_, err = bank.Settle(clock.Now())
+	case step.Reserves != nil:
+		// Run records the report.
+	case step.Expect != nil:
+		balance, berr := bank.Balance(step.Expect.Account)
+		if berr != nil {
+			return berr.Error(), nil
+		}
+		if math.Abs(balance-step.Expect.Balance) >= 0.005 {
+			return fmt.Sprintf("account %s has balance %.2f, want %.2f", step.Expect.Account, balance, step.Expect.Balance), nil
+		}
+	}
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+func actions(step Step) []string {
+	var set []string
+	for name, ok := range map[string]bool{
+		"open": step.Open != nil, "deposit": step.Deposit != nil, "withdraw": step.Withdraw != nil,
+		"transfer": step.Transfer != nil, "book": step.Book != nil, "interest": step.Interest != nil,
+		"set_rate": step.SetRate != nil, "set_reference": step.SetReference != nil, "close": step.Close != nil,
+		"advance": step.Advance != "", "expect": step.Expect != nil, "reserves": step.Reserves != nil,
+	} {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+func (s AmountStep) options() []models.TxOption {
+	return []models.TxOption{models.WithCounterparty(s.Counterparty), models.WithCategory(s.Category), models.WithDescription(s.Description)}
+}
+
+func open(bank *models.Bank, s OpenStep) error {
+	base := models.Account{AccountNumber: s.Account, Balance: s.Balance, Currency: s.Currency}
+	switch s.Kind {
+	case "savings":
+		account := &models.SavingsAccount{Account: base, InterestRate: s.Rate}
+		if s.Reference != "" {
+			account.Variable = &models.VariableRate{Reference: s.Reference, Spread: s.Spread}
+		}
+		return bank.Open(account)
+	case "checking":
+		return bank.Open(&models.CheckingAccount{Account: base, OverdraftLimit: s.Overdraft})
+	case "loan":
+		return bank.Open(&models.LoanAccount{Account: base, Principal: s.Principal, InterestRate: s.Rate, TermMonths: s.TermMonths})
+	case "", "plain":
+		return bank.Open(&base)
+	default:
+		return fmt.Errorf("open: unknown kind %q", s.Kind)
+	}
+}
+
+func setRate(bank *models.Bank, s RateStep) error {
+	account, err := bank.Account(s.Account)
+	if err != nil {
+		return err
+	}
+	savings, ok := account.(*models.SavingsAccount)
+	if !ok {
+		return fmt.Errorf("account %s does not earn interest", s.Account)
+	}
+	savings.InterestRate = s.Rate
+	return nil
+}
+
+// WriteText writes the report with one line per event and per balance.
+func (r Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if r.Name != "" {
+		fmt.Fprintf(&b, "scenario: %s\n", r.Name)
+	}
+	b.WriteString("\nevents:\n")
+	for _, e := range r.Events {
+		fmt.Fprintf(&b, "  %s %-20s %-8s", e.Time.UTC().Format(time.RFC3339), e.Type, e.AccountNumber)
+		if tx := e.Transaction; tx != nil {
+			fmt.Fprintf(&b, " #%d %s %.2f", tx.Sequence, tx.Type, tx.Amount)
+			if tx.Counterparty != "" {
+				fmt.Fprintf(&b, " %s", tx.Counterparty)
+			}
+		} else if e.Message != "" {
+			fmt.Fprintf(&b, " %s", e.Message)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nbalances:\n")
+	for _, bal := range r.Balances {
+		fmt.Fprintf(&b, "  %-8s %12.2f\n", bal.Account, bal.Balance)
+	}
+	if len(r.Reserves) > 0 {
+		b.WriteString("\nreserves:\n")
+	}
+	for _, res := range r.Reserves {
+		fmt.Fprintf(&b, "  %s %s\n", res.Time.UTC().Format(time.RFC3339), res.Label)
+		for _, rep := range res.Reports {
+			fmt.Fprintf(&b, "    deposits %.2f reserves %.2f required %.2f excess %.2f\n",
+				rep.Deposits, rep.Reserves, rep.RequiredReserves, rep.ExcessReserves)
+			fmt.Fprintf(&b, "    loans %.2f capital %.2f required %.2f capital ratio %s leverage %s\n",
+				rep.Loans, rep.Capital, rep.RequiredCapital, rep.CapitalRatio, rep.LeverageRatio)
+			fmt.Fprintf(&b, "    can lend %.2f", rep.LendingCapacity)
+			if rep.ReserveShortfall {
+				b.WriteString(", short of reserves")
+			}
+			if rep.CapitalShortfall {
+				b.WriteString(", short of capital")
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(r.Failures) > 0 {
+		b.WriteString("\nfailures:\n")
+		for _, f := range r.Failures {
+			fmt.Fprintf(&b, "  %s\n", f)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,118 @@
+package sim
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gsolano/banking/golden"
+	"gsolano/banking/models"
+)
+
+// TestScenarioGolden runs the example scenarios and compares their reports
+// with testdata.
+func TestScenarioGolden(t *testing.T) {
+	models.SetOutput(io.Discard)
+	for _, name := range []string{"savings", "reserves"} {
+		t.Run(name, func(t *testing.T) {
+			scenario, err := Load("scenarios/" + name + ".yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := Run(scenario)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Passed() {
+				t.Errorf("failures: %v", report.Failures)
+			}
+			var out bytes.Buffer
+			if err := report.WriteText(&out); err != nil {
+				t.Fatal(err)
+			}
+			golden.Assert(t, name+".txt", out.Bytes())
+		})
+	}
+}
+
+// TestStressGolden plays the example stress test on an empty bank and
+// compares its report with testdata.
+func TestStressGolden(t *testing.T) {
+	models.SetOutput(io.Discard)
+	test, err := LoadStressTest("scenarios/stress.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank := models.NewBank()
+	bank.Currency = "USD"
+	report, err := Stress(bank, test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bank.Accounts()) != 0 {
+		t.Errorf("the stress test opened %d accounts in the bank it copied", len(bank.Accounts()))
+	}
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	golden.Assert(t, "stress.txt", out.Bytes())
+}
+
+// TestRunRecordsFailures runs a scenario twice, checking that the runs
+// agree to the event and that rejected steps and expectations that do not
+// hold are recorded while the run goes on, and that malformed scenarios
+// are errors.
+func TestRunRecordsFailures(t *testing.T) {
+	models.SetOutput(io.Discard)
+	s := Scenario{
+		Start: "2026-03-02",
+		Steps: []Step{
+			{Open: &OpenStep{Account: "C1", Kind: "checking", Balance: 100}},
+			{Withdraw: &AmountStep{Account: "C1", Amount: 500}},
+			{Advance: "24h"},
+			{Deposit: &AmountStep{Account: "C1", Amount: 50}},
+			{Expect: &ExpectStep{Account: "C1", Balance: 100}},
+			{Expect: &ExpectStep{Account: "C9"}},
+		},
+	}
+	first, err := Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("runs differ:\n%+v\n%+v", first, second)
+	}
+	var steps []string
+	for _, f := range first.Failures {
+		steps = append(steps, strings.SplitN(f, ":", 2)[0])
+	}
+	if want := []string{"step 2", "step 5", "step 6"}; !slices.Equal(steps, want) || first.Passed() {
+		t.Errorf("failures %q, want steps %v", first.Failures, want)
+	}
+	if want := []Balance{{"C1", 150}}; !slices.Equal(first.Balances, want) {
+		t.Errorf("balances %v, want %v", first.Balances, want)
+	}
+	if last := first.Events[len(first.Events)-1]; !last.Time.Equal(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("last event at %s, want a day after the start", last.Time)
+	}
+
+	for _, bad := range []Scenario{
+		{Start: "March"},
+		{Steps: []Step{{}}},
+		{Steps: []Step{{Advance: "-1h"}}},
+		{Steps: []Step{{Advance: "1h", Expect: &ExpectStep{Account: "C1"}}}},
+	} {
+		if _, err := Run(bad); err == nil {
+			t.Errorf("running %+v succeeded", bad)
+		}
+	}
+}
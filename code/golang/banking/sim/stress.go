@@ -0,0 +1,413 @@
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"gsolano/banking/fx"
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// StressTest is a set of shocks to play on a copy of a bank, day by day for
+// Days days from Start, the bank's clock when empty. Rates and FX are the
+// reference and exchange rates the copy starts from, and Ratios, when set,
+// replace the bank's reserve and capital ratios. Setup steps, as in a
+// scenario, run on the copy first, such as to open the accounts of a demo.
+// Stress tests are YAML:
+//
+//	name: bank run
+//	days: 60
+//	rates: {base: 4%}
+//	fx: {EUR/USD: 1.08}
+//	shocks:
+//	  - {day: 1, withdraw: {share: 30%, kinds: [checking]}}
+//	  - {day: 10, rate: {reference: base, by: 3%, fixed: true}}
+//	  - {day: 20, fx: {pair: EUR/USD, by: -20%}}
+//
+// Interest is applied to every savings account and loan every 30 days.
+type StressTest struct {
+	Name   string                `yaml:"name"`
+	Start  string                `yaml:"start"`
+	Days   int                   `yaml:"days"`
+	Rates  map[string]money.Rate `yaml:"rates"`
+	FX     map[string]float64    `yaml:"fx"`
+	Ratios *Ratios               `yaml:"ratios"`
+	Setup  []Step                `yaml:"setup"`
+	Shocks []Shock               `yaml:"shocks"`
+}
+
+// Shock is what happens on Day of a stress test; exactly one field is set.
+type Shock struct {
+	Day      int            `yaml:"day"`
+	Withdraw *WithdrawShock `yaml:"withdraw"`
+	Rate     *RateShock     `yaml:"rate"`
+	FX       *FXShock       `yaml:"fx"`
+}
+
+// WithdrawShock is a run on the bank: the customers of every deposit
+// account, or of those of Kinds (savings, checking), withdraw Share of its
+// balance.
+type WithdrawShock struct {
+	Share money.Rate `yaml:"share"`
+	Kinds []string   `yaml:"kinds"`
+}
+
+// RateShock moves the reference rate Reference, or every reference rate
+// when empty, by By. With Fixed it moves the rates of fixed-rate savings
+// accounts and of loans by as much.
+type RateShock struct {
+	Reference string     `yaml:"reference"`
+	By        money.Rate `yaml:"by"`
+	Fixed     bool       `yaml:"fixed"`
+}
+
+// FXShock moves the exchange rate of Pair, such as EUR/USD, by By.
+type FXShock struct {
+	Pair string     `yaml:"pair"`
+	By   money.Rate `yaml:"by"`
+}
+
+// LoadStressTest reads a stress test file.
+func LoadStressTest(path string) (StressTest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return StressTest{}, err
+	}
+	var t StressTest
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return StressTest{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// StressReport is the outcome of a stress test: the bank's liquidity at the
+// end of every day and each time an account breached a limit.
+type StressReport struct {
+	Name     string
+	Days     []StressDay
+	Breaches []Breach
+}
+
+// StressDay is the bank at the end of a day of a stress test. Liquidity is
+// its cash in its own currency, other currencies converted at the day's
+// rates, and Withdrawn what the day's withdrawal shocks took out of it, the
+// same way; Reserves has the reserve report of each currency.
+type StressDay struct {
+	Day       int
+	Time      time.Time
+	Withdrawn float64
+	Liquidity float64
+	Reserves  []models.ReserveReport
+}
+
+// Breach is an operation of a stress test the bank refused, such as a
+// withdrawal over an account's limits, or an account that ended a day past
+// its overdraft or credit limit.
+type Breach struct {
+	Day     int
+	Account string
+	Reason  string
+}
+
+// Stress plays a stress test on a copy of bank, taken with Snapshot, which
+// it leaves untouched. The copy has the bank's currency, rounding, products,
+// flags, calendar and ratios, but not its middleware, such as policy rules.
+func Stress(bank *models.Bank, t StressTest) (StressReport, error) {
+	start := time.Now()
+	if bank.Clock != nil {
+		start = bank.Clock.Now()
+	}
+	if t.Start != "" {
+		var err error
+		if start, err = time.Parse(time.DateOnly, t.Start); err != nil {
+			return StressReport{}, fmt.Errorf("start: %q is not a date", t.Start)
+		}
+	}
+	if t.Days <= 0 {
+		return StressReport{}, errors.New("days: must be positive")
+	}
+	for i, s := range t.Shocks {
+		if n := len(s.actions()); n != 1 || s.Day < 1 || s.Day > t.Days {
+			return StressReport{}, fmt.Errorf("shock %d: want exactly one action on a day from 1 to %d", i+1, t.Days)
+		}
+	}
+
+	clock := NewClock(start)
+	rates := models.StaticRates{}
+	for name, rate := range t.Rates {
+		rates[name] = rate
+	}
+	table := fx.Table{}
+	for pair, rate := range t.FX {
+		if _, _, err := fx.ParsePair(pair); err != nil {
+			return StressReport{}, fmt.Errorf("fx: %w", err)
+		}
+		table[pair] = rate
+	}
+	copied, err := copyBank(bank, clock, rates, table)
+	if err != nil {
+		return StressReport{}, err
+	}
+	if r := t.Ratios; r != nil {
+		copied.Reserves = r.ratios()
+	}
+	for i, step := range t.Setup {
+		fail, err := apply(copied, clock, rates, step)
+		if err != nil {
+			return StressReport{}, fmt.Errorf("setup step %d: %w", i+1, err)
+		}
+		if fail != "" {
+			return StressReport{}, fmt.Errorf("setup step %d: %s", i+1, fail)
+		}
+	}
+
+	report := StressReport{Name: t.Name}
+	over := make(map[string]bool)
+	for day := 1; day <= t.Days; day++ {
+		clock.Advance(24 * time.Hour)
+		if _, err := copied.Settle(clock.Now()); err != nil {
+			report.Breaches = append(report.Breaches, Breach{Day: day, Reason: "settlement: " + err.Error()})
+		}
+		today := StressDay{Day: day, Time: clock.Now()}
+		for _, s := range t.Shocks {
+			if s.Day != day {
+				continue
+			}
+			switch {
+			case s.Withdraw != nil:
+				withdrawn, breaches := runOn(copied, *s.Withdraw, table, day)
+				today.Withdrawn += withdrawn
+				report.Breaches = append(report.Breaches, breaches...)
+			case s.Rate != nil:
+				shockRates(copied, rates, *s.Rate)
+			case s.FX != nil:
+				if err := shockFX(table, *s.FX); err != nil {
+					return StressReport{}, fmt.Errorf("day %d: %w", day, err)
+				}
+			}
+		}
+		if day%30 == 0 {
+			report.Breaches = append(report.Breaches, accrue(copied, day)...)
+		}
+		report.Breaches = append(report.Breaches, overLimit(copied, day, over)...)
+		today.Reserves = copied.ReserveReport(time.Time{})
+		for _, r := range today.Reserves {
+			today.Liquidity += inCurrency(copied, table, r.Reserves, r.Currency)
+		}
+		today.Liquidity = money.Round(today.Liquidity, copied.Currency, copied.Rounding)
+		report.Days = append(report.Days, today)
+	}
+	return report, nil
+}
+
+func (s Shock) actions() []string {
+	var set []string
+	for name, ok := range map[string]bool{"withdraw": s.Withdraw != nil, "rate": s.Rate != nil, "fx": s.FX != nil} {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	return set
+}
+
+// copyBank restores a snapshot of bank into a new bank on clock.
+func copyBank(bank *models.Bank, clock *Clock, rates models.StaticRates, table fx.Table) (*models.Bank, error) {
+	var snapshot bytes.Buffer
+	if err := bank.Snapshot(&snapshot); err != nil {
+		return nil, err
+	}
+	c := models.NewBank()
+	c.Clock, c.Rates, c.FX, c.IDs = clock, rates, table, &models.DeterministicIDs{}
+	c.Currency, c.Rounding, c.Products, c.Flags, c.Calendar = bank.Currency, bank.Rounding, bank.Products, bank.Flags, bank.Calendar
+	c.Reserves, c.DelinquencyPolicy, c.Allocation = bank.Reserves, bank.DelinquencyPolicy, bank.Allocation
+	if err := c.Restore(&snapshot); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// runOn withdraws the share of a withdrawal shock from every deposit account
+// it applies to, by account number, while the bank has the cash to pay it
+// out. It returns what was withdrawn in the bank's currency.
+func runOn(bank *models.Bank, s WithdrawShock, table fx.Table, day int) (float64, []Breach) {
+	cash := make(map[string]float64)
+	for _, r := range bank.ReserveReport(time.Time{}) {
+		cash[r.Currency] = r.Reserves
+	}
+	var withdrawn float64
+	var breaches []Breach
+	for _, account := range sortedAccounts(bank) {
+		var kind string
+		switch account.(type) {
+		case *models.SavingsAccount:
+			kind = "savings"
+		case *models.CheckingAccount:
+			kind = "checking"
+		default:
+			continue
+		}
+		if len(s.Kinds) > 0 && !slices.Contains(s.Kinds, kind) {
+			continue
+		}
+		currency := bank.AccountCurrency(account)
+		balance := account.CheckBalance()
+		amount := money.Round(balance*s.Share.Percent()/100, currency, bank.Rounding)
+		if balance <= 0 || amount <= 0 {
+			continue
+		}
+		if amount > cash[currency] {
+			breaches = append(breaches, Breach{Day: day, Account: account.Number(),
+				Reason: fmt.Sprintf("withdrawal of %.2f refused: the bank has %.2f %s left", amount, math.Max(cash[currency], 0), currency)})
+			continue
+		}
+		if err := bank.Withdraw(account.Number(), amount, models.WithDescription("stress test withdrawal")); err != nil {
+			breaches = append(breaches, Breach{Day: day, Account: account.Number(), Reason: err.Error()})
+			continue
+		}
+		cash[currency] -= amount
+		withdrawn += inCurrency(bank, table, amount, currency)
+	}
+	return money.Round(withdrawn, bank.Currency, bank.Rounding), breaches
+}
+
+// inCurrency converts amount in currency into the bank's currency at the
+// rates of table, or leaves it out when there is no rate.
+func inCurrency(bank *models.Bank, table fx.Table, amount float64, currency string) float64 {
+	if currency == bank.Currency || bank.Currency == "" {
+		return amount
+	}
+	rate, err := table.ExchangeRate(currency, bank.Currency)
+	if err != nil {
+		return 0
+	}
+	return amount * rate.Rate
+}
+
+// shockRates moves reference rates, and with Fixed the rates of fixed-rate
+// savings accounts and loans.
+func shockRates(bank *models.Bank, rates models.StaticRates, s RateShock) {
+	for name := range rates {
+		if s.Reference == "" || name == s.Reference {
+			rates[name] += s.By
+		}
+	}
+	if s.Reference != "" {
+		if _, ok := rates[s.Reference]; !ok {
+			rates[s.Reference] = s.By
+		}
+	}
+	if !s.Fixed {
+		return
+	}
+	for _, account := range bank.Accounts() {
+		switch a := account.(type) {
+		case *models.SavingsAccount:
+			if a.Variable == nil {
+				a.InterestRate += s.By
+			}
+		case *models.LoanAccount:
+			a.InterestRate += s.By
+		}
+	}
+}
+
+func shockFX(table fx.Table, s FXShock) error {
+	rate, ok := table[s.Pair]
+	if !ok {
+		return fmt.Errorf("fx: no rate for %s to shock", s.Pair)
+	}
+	table[s.Pair] = rate * (1 + s.By.Percent()/100)
+	return nil
+}
+
+// accrue applies interest to every savings account and loan.
+func accrue(bank *models.Bank, day int) []Breach {
+	var breaches []Breach
+	for _, account := range sortedAccounts(bank) {
+		switch account.(type) {
+		case *models.SavingsAccount, *models.LoanAccount:
+			if err := bank.ApplyInterest(account.Number()); err != nil {
+				breaches = append(breaches, Breach{Day: day, Account: account.Number(), Reason: "interest: " + err.Error()})
+			}
+		}
+	}
+	return breaches
+}
+
+// overLimit reports the accounts that went past their overdraft or credit
+// limit, once each time they do.
+func overLimit(bank *models.Bank, day int, over map[string]bool) []Breach {
+	var breaches []Breach
+	for _, account := range sortedAccounts(bank) {
+		limit := math.Inf(1)
+		switch a := account.(type) {
+		case *models.CheckingAccount:
+			limit = a.OverdraftLimit
+		case *models.LoanAccount:
+			limit = a.Principal
+		case *models.CreditCardAccount:
+			limit = a.CreditLimit
+		}
+		number, past := account.Number(), account.CheckBalance() < -limit-0.005
+		if past && !over[number] {
+			breaches = append(breaches, Breach{Day: day, Account: number,
+				Reason: fmt.Sprintf("balance %.2f is past its limit of %.2f", account.CheckBalance(), limit)})
+		}
+		over[number] = past
+	}
+	return breaches
+}
+
+func sortedAccounts(bank *models.Bank) []models.BankAccount {
+	accounts := bank.Accounts()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number() < accounts[j].Number() })
+	return accounts
+}
+
+// WriteText writes the report with one line per day and per breach.
+func (r StressReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if r.Name != "" {
+		fmt.Fprintf(&b, "stress test: %s\n", r.Name)
+	}
+	// Days on which nothing changed are left out, but for the last.
+	b.WriteString("\ndays:\n")
+	var last string
+	for i, d := range r.Days {
+		var line strings.Builder
+		fmt.Fprintf(&line, " withdrawn %10.2f liquidity %10.2f", d.Withdrawn, d.Liquidity)
+		for _, res := range d.Reserves {
+			fmt.Fprintf(&line, " | %s excess %.2f capital ratio %s", res.Currency, res.ExcessReserves, res.CapitalRatio)
+			if res.ReserveShortfall {
+				line.WriteString(" short of reserves")
+			}
+			if res.CapitalShortfall {
+				line.WriteString(" short of capital")
+			}
+		}
+		if line.String() == last && i < len(r.Days)-1 {
+			continue
+		}
+		last = line.String()
+		fmt.Fprintf(&b, "  %3d %s%s\n", d.Day, d.Time.UTC().Format(time.DateOnly), last)
+	}
+	if len(r.Breaches) > 0 {
+		b.WriteString("\nbreaches:\n")
+		for _, br := range r.Breaches {
+			fmt.Fprintf(&b, "  day %3d %-8s %s\n", br.Day, br.Account, br.Reason)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
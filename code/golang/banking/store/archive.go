@@ -0,0 +1,54 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gsolano/banking/models"
+)
+
+// DirArchive is a models.Archive that keeps each archived account in its own
+// file, named after the account number, in a directory.
+type DirArchive struct {
+	dir string
+}
+
+// NewDirArchive returns an archive in dir, creating the directory if needed.
+func NewDirArchive(dir string) (*DirArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirArchive{dir: dir}, nil
+}
+
+func (a *DirArchive) path(number string) string {
+	return filepath.Join(a.dir, filepath.Base(number)+".json.gz")
+}
+
+// Put writes the blob to a temporary file that then replaces the account's
+// file, so a reader never sees a partial blob.
+func (a *DirArchive) Put(number string, blob []byte) error {
+	tmp, err := os.CreateTemp(a.dir, ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(blob); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), a.path(number))
+}
+
+func (a *DirArchive) Get(number string) ([]byte, error) {
+	blob, err := os.ReadFile(a.path(number))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, models.ErrNotArchived
+	}
+	return blob, err
+}
@@ -0,0 +1,23 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gsolano/banking/store"
+	"gsolano/banking/store/storetest"
+)
+
+func TestJSONConformance(t *testing.T) {
+	storetest.RunRepositoryTests(t, func(t *testing.T) storetest.Opener {
+		path := filepath.Join(t.TempDir(), "bank.json")
+		return func() (store.Store, error) { return store.OpenJSON(path) }
+	})
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storetest.RunRepositoryTests(t, func(t *testing.T) storetest.Opener {
+		path := filepath.Join(t.TempDir(), "bank.db")
+		return func() (store.Store, error) { return store.OpenSQLite(path, store.WithBatchSize(2)) }
+	})
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gsolano/banking/models"
+)
+
+// JSONStore keeps a bank in a single file in the format of Bank.Snapshot.
+// The file is read when the store is opened and rewritten by Close.
+type JSONStore struct {
+	path string
+
+	mu         sync.Mutex
+	customers  map[string]*models.Customer
+	accounts   map[string]models.BankAccount
+	lifecycles map[string]models.Lifecycle
+	redirects  []models.Redirect
+	snapshot   []byte
+	dirty      bool
+}
+
+// OpenJSON opens the JSON store at path. A missing file is an empty store.
+func OpenJSON(path string) (*JSONStore, error) {
+	s := &JSONStore{path: path, customers: make(map[string]*models.Customer), accounts: make(map[string]models.BankAccount),
+		lifecycles: make(map[string]models.Lifecycle)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	bank := models.NewBank()
+	if err := bank.Restore(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	s.snapshot = data
+	for _, c := range bank.Customers() {
+		s.customers[c.ID] = c
+	}
+	for _, a := range bank.Accounts() {
+		s.accounts[a.Number()] = a
+	}
+	s.lifecycles = lifecycles(bank)
+	s.redirects = bank.Redirects()
+	return s, nil
+}
+
+func (s *JSONStore) Customers() ([]*models.Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	customers := make([]*models.Customer, 0, len(s.customers))
+	for _, c := range s.customers {
+		customers = append(customers, c)
+	}
+	sort.Slice(customers, func(i, j int) bool { return customers[i].ID < customers[j].ID })
+	return customers, nil
+}
+
+func (s *JSONStore) SaveCustomer(customer *models.Customer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.customers[customer.ID] = customer
+	s.dirty = true
+	return nil
+}
+
+func (s *JSONStore) EachAccount(fn func(models.BankAccount) error) error {
+	s.mu.Lock()
+	accounts := make([]models.BankAccount, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		accounts = append(accounts, a)
+	}
+	s.mu.Unlock()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number() < accounts[j].Number() })
+	for _, a := range accounts {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *JSONStore) SaveAccount(account models.BankAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[account.Number()] = account
+	s.dirty = true
+	return nil
+}
+
+func (s *JSONStore) Lifecycles() (map[string]models.Lifecycle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lifecycles := make(map[string]models.Lifecycle, len(s.lifecycles))
+	for number, l := range s.lifecycles {
+		lifecycles[number] = l
+	}
+	return lifecycles, nil
+}
+
+func (s *JSONStore) SaveLifecycles(lifecycles map[string]models.Lifecycle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lifecycles = make(map[string]models.Lifecycle, len(lifecycles))
+	for number, l := range lifecycles {
+		s.lifecycles[number] = l
+	}
+	s.dirty = true
+	return nil
+}
+
+func (s *JSONStore) Redirects() ([]models.Redirect, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Redirect(nil), s.redirects...), nil
+}
+
+// SaveRedirects drops the accounts saved under old numbers, which Save then
+// saves under their new ones; the file is only written whole, by Close.
+func (s *JSONStore) SaveRedirects(redirects []models.Redirect) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redirects = append([]models.Redirect(nil), redirects...)
+	for _, r := range redirects {
+		delete(s.accounts, r.From)
+	}
+	s.dirty = true
+	return nil
+}
+
+// Snapshot returns the file as it was opened, or the snapshot saved since.
+func (s *JSONStore) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot, nil
+}
+
+func (s *JSONStore) SaveSnapshot(snapshot []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = append([]byte(nil), snapshot...)
+	s.dirty = true
+	return nil
+}
+
+// Close writes the file if anything was saved. What was saved of customers,
+// accounts, lifecycles and redirects replaces what the snapshot says. The
+// new contents go to a temporary file first, which then replaces the old
+// one, so a crash never leaves a half-written store behind.
+func (s *JSONStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	bank := models.NewBank()
+	if s.snapshot != nil {
+		accounts := make([]models.BankAccount, 0, len(s.accounts))
+		for _, a := range s.accounts {
+			accounts = append(accounts, a)
+		}
+		customers := make([]*models.Customer, 0, len(s.customers))
+		for _, c := range s.customers {
+			customers = append(customers, c)
+		}
+		if err := bank.RestoreWith(bytes.NewReader(s.snapshot), accounts, customers); err != nil {
+			return err
+		}
+	} else {
+		for _, a := range s.accounts {
+			if err := bank.Open(a); err != nil {
+				return err
+			}
+		}
+		for _, c := range s.customers {
+			if err := bank.AddCustomer(c); err != nil {
+				return err
+			}
+		}
+	}
+	for number, l := range s.lifecycles {
+		// Lifecycles of accounts no longer saved are dropped.
+		if _, ok := s.accounts[number]; ok {
+			if err := bank.SetLifecycle(number, l); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range s.redirects {
+		if err := bank.AddRedirect(r); err != nil {
+			return err
+		}
+	}
+	return s.write(bank)
+}
+
+// write replaces the file with a snapshot of bank. The caller holds s.mu.
+func (s *JSONStore) write(bank *models.Bank) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := bank.Snapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
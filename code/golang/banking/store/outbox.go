@@ -0,0 +1,76 @@
+package store
+
+import "gsolano/banking/models"
+
+// OutboxEvent is the event of a ledger entry, written to the outbox of a
+// store in the database transaction that saved the entry. IDs increase in
+// the order events were written; a consumer that may see an event twice,
+// after a relay failed between publishing and marking it, drops repeats by
+// ID.
+type OutboxEvent struct {
+	ID          int64              `json:"id"`
+	Type        models.EventType   `json:"type"`
+	Account     string             `json:"account"`
+	Transaction models.Transaction `json:"transaction"`
+}
+
+// Outbox is implemented by stores that keep an outbox, the SQL stores with
+// WithOutbox.
+//
+// An entry saved for the first time adds an event to the outbox in the
+// same database transaction, so an event exists exactly when its entry was
+// committed: none is lost if the process dies after the save, and none is
+// published for a save that rolled back. Entries that were in the store
+// before the outbox was enabled do not add events.
+type Outbox interface {
+	// PendingEvents returns up to limit events not yet marked published,
+	// oldest first.
+	PendingEvents(limit int) ([]OutboxEvent, error)
+	// MarkPublished removes events from the outbox.
+	MarkPublished(ids ...int64) error
+}
+
+// WithOutbox makes a SQL store write an event to its outbox for every
+// ledger entry it saves for the first time. Other stores ignore it.
+func WithOutbox() Option {
+	return func(o *Options) { o.Outbox = true }
+}
+
+// NewEvents returns the events of the entries of account after the one
+// numbered saved, the last the store holds, for a store to write to its
+// outbox. Their IDs are left for the store to assign.
+func NewEvents(account models.BankAccount, saved int) []OutboxEvent {
+	var events []OutboxEvent
+	for _, t := range account.History() {
+		if t.Sequence > saved {
+			events = append(events, OutboxEvent{Type: models.EventTransactionPosted, Account: account.Number(), Transaction: t})
+		}
+	}
+	return events
+}
+
+// relayBatch is how many events Relay reads from the outbox at a time.
+const relayBatch = 100
+
+// Relay publishes the events pending in o, oldest first, and returns how
+// many it published. An event is marked published only once publish has
+// returned nil for it; Relay stops at the first that fails, so the next
+// call retries it and later events are never published ahead of it.
+func Relay(o Outbox, publish func(OutboxEvent) error) (int, error) {
+	published := 0
+	for {
+		events, err := o.PendingEvents(relayBatch)
+		if err != nil || len(events) == 0 {
+			return published, err
+		}
+		for _, e := range events {
+			if err := publish(e); err != nil {
+				return published, err
+			}
+			if err := o.MarkPublished(e.ID); err != nil {
+				return published, err
+			}
+			published++
+		}
+	}
+}
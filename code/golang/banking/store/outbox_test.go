@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// flakyOutbox fails to mark the event numbered failMark published once.
+type flakyOutbox struct {
+	Outbox
+	failMark int64
+}
+
+func (o *flakyOutbox) MarkPublished(ids ...int64) error {
+	for _, id := range ids {
+		if id == o.failMark {
+			o.failMark = 0
+			return errors.New("connection lost")
+		}
+	}
+	return o.Outbox.MarkPublished(ids...)
+}
+
+// TestRelay saves more entries than Relay reads at a time to an outbox and
+// checks that each is delivered in order, that a delivery that fails is
+// retried by the next relay before any later event, and that an event that
+// could not be marked published is delivered again rather than lost.
+func TestRelay(t *testing.T) {
+	bank := ledgerBank(t, 3, 40)
+	st, err := OpenSQLite(filepath.Join(t.TempDir(), "bank.db"), WithOutbox())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	for i := 0; i < 2; i++ {
+		if err := Save(st, bank); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pending, err := st.PendingEvents(1000); err != nil || len(pending) != 120 {
+		t.Fatalf("%d events pending after saving twice, %v; want one per entry", len(pending), err)
+	}
+
+	var delivered []int64
+	failAt := int64(0)
+	publish := func(e OutboxEvent) error {
+		if e.ID == failAt {
+			failAt = 0
+			return errors.New("receiver unavailable")
+		}
+		delivered = append(delivered, e.ID)
+		return nil
+	}
+	failAt = 3
+	if n, err := Relay(st, publish); n != 2 || err == nil {
+		t.Fatalf("relay failing at event 3 published %d, %v; want 2 and the error", n, err)
+	}
+	if pending, _ := st.PendingEvents(1); len(pending) != 1 || pending[0].ID != 3 {
+		t.Fatalf("pending after the failure %+v, want event 3 first", pending)
+	}
+
+	o := &flakyOutbox{Outbox: st, failMark: 5}
+	if n, err := Relay(o, publish); n != 2 || err == nil {
+		t.Fatalf("relay failing to mark event 5 published %d, %v; want 2 and the error", n, err)
+	}
+	if n, err := Relay(o, publish); n != 116 || err != nil {
+		t.Fatalf("last relay published %d, %v; want the other 116", n, err)
+	}
+	if pending, _ := st.PendingEvents(1); len(pending) != 0 {
+		t.Errorf("events still pending: %+v", pending)
+	}
+
+	seen := make(map[int64]int)
+	for i, id := range delivered {
+		seen[id]++
+		if i > 0 && id < delivered[i-1] {
+			t.Errorf("event %d delivered after %d", id, delivered[i-1])
+		}
+	}
+	if len(seen) != 120 || seen[5] != 2 || len(delivered) != 121 {
+		t.Errorf("delivered %d events, %d distinct, event 5 %d times; want each of the 120 once and event 5 twice", len(delivered), len(seen), seen[5])
+	}
+
+	number := bank.Accounts()[0].Number()
+	if err := bank.Deposit(number, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(st, bank); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := st.PendingEvents(10)
+	if len(pending) != 1 || pending[0].Account != number || pending[0].ID <= 120 || pending[0].Transaction.Amount != 1 {
+		t.Errorf("pending after a new deposit %+v, want its event alone", pending)
+	}
+}
@@ -0,0 +1,790 @@
+// Package postgres keeps a bank in a PostgreSQL database, for bank servers
+// that run as several replicas or need a database server's durability.
+// Importing it registers the "postgres" backend with store.Open, which
+// takes a connection string such as
+//
+//	postgres://bank:secret@db:5432/bank?sslmode=require
+//
+// Writes to an account, whether a save or an op through WriteThrough, hold
+// a transaction level advisory lock on its number, so processes sharing a
+// database serialize their writes account by account instead of
+// overwriting each other's rows half way. Writes add the ledger entries the
+// database does not have yet rather than rewriting ledgers.
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+	"gsolano/banking/store"
+)
+
+func init() {
+	store.Register("postgres", func(dsn string, o store.Options) (store.Store, error) {
+		return Open(context.Background(), dsn, o)
+	})
+}
+
+// migrations bring the database up to date. Entry i moves it from version
+// i to i+1; new databases run them all.
+var migrations = []string{
+	`CREATE TABLE customers (
+		id   TEXT PRIMARY KEY,
+		name TEXT NOT NULL
+	);
+	CREATE TABLE customer_accounts (
+		customer_id TEXT NOT NULL REFERENCES customers ON DELETE CASCADE,
+		position    INTEGER NOT NULL,
+		number      TEXT NOT NULL,
+		PRIMARY KEY (customer_id, position)
+	);
+	CREATE TABLE accounts (
+		number          TEXT PRIMARY KEY,
+		kind            TEXT NOT NULL,
+		balance         DOUBLE PRECISION NOT NULL,
+		interest_rate   BIGINT NOT NULL DEFAULT 0,
+		variable_rate   JSONB,
+		rate_resets     JSONB,
+		overdraft_limit DOUBLE PRECISION NOT NULL DEFAULT 0,
+		terms           JSONB
+	);
+	CREATE TABLE transactions (
+		account      TEXT NOT NULL REFERENCES accounts ON DELETE CASCADE,
+		sequence     INTEGER NOT NULL,
+		type         TEXT NOT NULL,
+		amount       DOUBLE PRECISION NOT NULL,
+		counterparty TEXT NOT NULL DEFAULT '',
+		category     TEXT NOT NULL DEFAULT '',
+		description  TEXT NOT NULL DEFAULT '',
+		tags         JSONB,
+		rate         BIGINT NOT NULL DEFAULT 0,
+		metadata     JSONB,
+		time         TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (account, sequence)
+	)`,
+	// outbox_marks holds the last entry of each account that was saved, so
+	// that only entries saved after it add outbox events.
+	`CREATE TABLE outbox (
+		id    BIGSERIAL PRIMARY KEY,
+		event JSONB NOT NULL
+	);
+	CREATE TABLE outbox_marks (
+		account  TEXT PRIMARY KEY,
+		sequence INTEGER NOT NULL
+	);
+	INSERT INTO outbox_marks (account, sequence) SELECT account, MAX(sequence) FROM transactions GROUP BY account`,
+	`CREATE TABLE account_lifecycles (
+		number  TEXT PRIMARY KEY,
+		closed  TIMESTAMPTZ,
+		deleted TIMESTAMPTZ
+	)`,
+	`ALTER TABLE accounts ADD COLUMN product TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE accounts ADD COLUMN currency TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE transactions ADD COLUMN id TEXT NOT NULL DEFAULT ''`,
+	// account_redirects maps the old numbers of renumbered accounts to
+	// their new ones.
+	`CREATE TABLE account_redirects (
+		number   TEXT PRIMARY KEY,
+		moved_to TEXT NOT NULL,
+		time     TIMESTAMPTZ NOT NULL
+	)`,
+	// bank_snapshot holds the one snapshot of the bank saved last, for the
+	// state that has no table of its own.
+	`CREATE TABLE bank_snapshot (
+		id       INTEGER PRIMARY KEY CHECK (id = 1),
+		snapshot TEXT NOT NULL
+	)`,
+}
+
+// migrationLock is the advisory lock held while migrating, so replicas
+// starting together do not run the migrations twice.
+const migrationLock = 0x62616e6b // "bank"
+
+// Store is a bank in a PostgreSQL database, reached through a pool of
+// connections.
+type Store struct {
+	pool      *pgxpool.Pool
+	batchSize int
+	outbox    bool
+}
+
+// Open connects to the database at dsn, with a pool sized by o.Pool, and
+// migrates it to the current schema.
+func Open(ctx context.Context, dsn string, o store.Options) (*Store, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if o.Pool.MaxConns > 0 {
+		cfg.MaxConns = int32(o.Pool.MaxConns)
+	}
+	if o.Pool.MinConns > 0 {
+		cfg.MinConns = int32(o.Pool.MinConns)
+	}
+	if o.Pool.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = o.Pool.MaxConnIdleTime
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(ctx, pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	batchSize := o.BatchSize
+	if batchSize < 1 {
+		batchSize = store.DefaultBatchSize
+	}
+	return &Store{pool: pool, batchSize: batchSize, outbox: o.Outbox}, nil
+}
+
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	switch err := tx.QueryRow(ctx, `SELECT version FROM schema_version`).Scan(&version); {
+	case errors.Is(err, pgx.ErrNoRows):
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_version (version) VALUES (0)`); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+	for _, m := range migrations[version:] {
+		if _, err := tx.Exec(ctx, m); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE schema_version SET version = $1`, len(migrations)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Close waits for the connections in use to be released and closes them.
+func (s *Store) Close() error {
+	s.pool.Close()
+	return nil
+}
+
+func (s *Store) Customers() ([]*models.Customer, error) {
+	ctx := context.Background()
+	rows, err := s.pool.Query(ctx, `SELECT id, name FROM customers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	var customers []*models.Customer
+	byID := make(map[string]*models.Customer)
+	for rows.Next() {
+		c := &models.Customer{}
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		customers = append(customers, c)
+		byID[c.ID] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.pool.Query(ctx, `SELECT customer_id, number FROM customer_accounts ORDER BY customer_id, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, number string
+		if err := rows.Scan(&id, &number); err != nil {
+			return nil, err
+		}
+		if c := byID[id]; c != nil {
+			c.Accounts = append(c.Accounts, number)
+		}
+	}
+	return customers, rows.Err()
+}
+
+func (s *Store) SaveCustomer(customer *models.Customer) error {
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM customers WHERE id = $1`, customer.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO customers (id, name) VALUES ($1, $2)`, customer.ID, customer.Name); err != nil {
+		return err
+	}
+	rows := make([][]any, len(customer.Accounts))
+	for i, number := range customer.Accounts {
+		rows[i] = []any{customer.ID, i, number}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"customer_accounts"}, []string{"customer_id", "position", "number"}, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// accountRow is a row of the accounts table.
+type accountRow struct {
+	number, kind         string
+	product              string
+	currency             string
+	balance, overdraft   float64
+	rate                 int64
+	variable, rateResets []byte
+	terms                []byte
+}
+
+// EachAccount reads the accounts and then every ledger in one query, so
+// loading a bank takes two round trips however many accounts it has.
+func (s *Store) EachAccount(fn func(models.BankAccount) error) error {
+	ctx := context.Background()
+	rows, err := s.pool.Query(ctx, `SELECT number, kind, balance, interest_rate, variable_rate, rate_resets, overdraft_limit, terms, product, currency FROM accounts ORDER BY number`)
+	if err != nil {
+		return err
+	}
+	var accounts []accountRow
+	for rows.Next() {
+		var r accountRow
+		if err := rows.Scan(&r.number, &r.kind, &r.balance, &r.rate, &r.variable, &r.rateResets, &r.overdraft, &r.terms, &r.product, &r.currency); err != nil {
+			rows.Close()
+			return err
+		}
+		accounts = append(accounts, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	ledgers, err := s.ledgers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range accounts {
+		account, err := loadAccount(r, ledgers[r.number])
+		if err != nil {
+			return err
+		}
+		if err := fn(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadAccount(r accountRow, history []models.Transaction) (models.BankAccount, error) {
+	base := models.Account{AccountNumber: r.number, Balance: r.balance, Transactions: history, Product: r.product, Currency: r.currency}
+	switch r.kind {
+	case "Savings":
+		sa := &models.SavingsAccount{Account: base, InterestRate: money.Rate(r.rate)}
+		if err := unmarshalNull(r.variable, &sa.Variable); err != nil {
+			return nil, err
+		}
+		if err := unmarshalNull(r.rateResets, &sa.RateResets); err != nil {
+			return nil, err
+		}
+		return sa, nil
+	case "Checking":
+		return &models.CheckingAccount{Account: base, OverdraftLimit: r.overdraft}, nil
+	case "Loan":
+		var terms models.LoanTerms
+		if err := unmarshalNull(r.terms, &terms); err != nil {
+			return nil, err
+		}
+		loan := &models.LoanAccount{Account: base}
+		loan.SetTerms(terms)
+		return loan, nil
+	case "CreditCard":
+		var terms models.CardTerms
+		if err := unmarshalNull(r.terms, &terms); err != nil {
+			return nil, err
+		}
+		card := &models.CreditCardAccount{Account: base}
+		card.SetTerms(terms)
+		return card, nil
+	case "Account":
+		return &base, nil
+	default:
+		return models.RestoreCustom(r.kind, base, r.terms)
+	}
+}
+
+// ledgers returns the entries of every account by account number.
+func (s *Store) ledgers(ctx context.Context) (map[string][]models.Transaction, error) {
+	rows, err := s.pool.Query(ctx, `SELECT account, id, sequence, type, amount, counterparty, category, description, tags, rate, metadata, time
+		FROM transactions ORDER BY account, sequence`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ledgers := make(map[string][]models.Transaction)
+	for rows.Next() {
+		var tx models.Transaction
+		var account, kind string
+		var tags, metadata []byte
+		var rate int64
+		if err := rows.Scan(&account, &tx.ID, &tx.Sequence, &kind, &tx.Amount, &tx.Counterparty, &tx.Category, &tx.Description, &tags, &rate, &metadata, &tx.Time); err != nil {
+			return nil, err
+		}
+		tx.Type = models.TransactionType(kind)
+		tx.Rate = money.Rate(rate)
+		tx.Time = tx.Time.UTC()
+		if err := unmarshalNull(tags, &tx.Tags); err != nil {
+			return nil, err
+		}
+		if err := unmarshalNull(metadata, &tx.Metadata); err != nil {
+			return nil, err
+		}
+		ledgers[account] = append(ledgers[account], tx)
+	}
+	return ledgers, rows.Err()
+}
+
+func (s *Store) SaveAccount(account models.BankAccount) error {
+	return s.SaveAccounts([]models.BankAccount{account})
+}
+
+// SaveAccounts saves BatchSize accounts per database transaction,
+// upserting their rows and copying in the ledger entries not yet saved.
+func (s *Store) SaveAccounts(accounts []models.BankAccount) error {
+	ctx := context.Background()
+	for start := 0; start < len(accounts); start += s.batchSize {
+		batch := accounts[start:min(start+s.batchSize, len(accounts))]
+		err := s.inTx(ctx, accountNumbers(batch), func(tx pgx.Tx) error {
+			return s.writeAccounts(ctx, tx, batch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteThrough returns middleware that carries out each deposit,
+// withdrawal and transfer of bank in a database transaction of its own. It
+// takes the advisory locks of the op's accounts, carries the op out and
+// writes the entries it posted before it commits, so a write of either
+// account by another process waits for it. An op is refused if the
+// transaction cannot be started. If the write fails after the op was
+// posted, the op stands, the error goes to failed, and the next save of
+// the accounts writes the entries. Dry runs are not written.
+func (s *Store) WriteThrough(bank *models.Bank, failed func(error)) models.Middleware {
+	return func(next models.Operation) models.Operation {
+		return func(op models.Op) error {
+			if op.DryRun {
+				return next(op)
+			}
+			numbers := []string{op.Account}
+			if op.Kind == models.OpTransfer {
+				numbers = append(numbers, op.To)
+			}
+			ctx := context.Background()
+			ran := false
+			var opErr error
+			err := s.inTx(ctx, numbers, func(tx pgx.Tx) error {
+				ran = true
+				if opErr = next(op); opErr != nil {
+					return opErr
+				}
+				accounts := make([]models.BankAccount, 0, len(numbers))
+				for _, number := range numbers {
+					account, err := bank.Account(number)
+					if err != nil {
+						return err
+					}
+					accounts = append(accounts, account)
+				}
+				return s.writeAccounts(ctx, tx, accounts)
+			})
+			switch {
+			case !ran:
+				return fmt.Errorf("%s of %s: %w", op.Kind, op.Account, err)
+			case opErr != nil:
+				return opErr
+			case err != nil && failed != nil:
+				failed(fmt.Errorf("writing the %s of %s: %w", op.Kind, op.Account, err))
+			}
+			return nil
+		}
+	}
+}
+
+// inTx runs fn in a database transaction that first takes the advisory
+// locks of numbers, in sorted order so that two transactions locking the
+// same accounts cannot deadlock, and commits it if fn succeeds. The locks
+// are released when the transaction ends.
+func (s *Store) inTx(ctx context.Context, numbers []string, fn func(pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	numbers = append([]string(nil), numbers...)
+	sort.Strings(numbers)
+	for i, number := range numbers {
+		if i > 0 && number == numbers[i-1] {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey(number)); err != nil {
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockKey is the advisory lock of an account number. Two numbers may share
+// a key, which only makes their writes wait for each other.
+func lockKey(number string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(number))
+	return int64(h.Sum64())
+}
+
+func accountNumbers(accounts []models.BankAccount) []string {
+	numbers := make([]string, len(accounts))
+	for i, account := range accounts {
+		numbers[i] = account.Number()
+	}
+	return numbers
+}
+
+// writeAccounts replaces the rows of accounts in tx and adds the entries of
+// their ledgers after the last one saved, and their events to the outbox.
+// A ledger whose last entry saved is not that of the account is written
+// again whole.
+func (s *Store) writeAccounts(ctx context.Context, tx pgx.Tx, accounts []models.BankAccount) error {
+	numbers := accountNumbers(accounts)
+	marks, err := outboxMarks(ctx, tx, numbers)
+	if err != nil {
+		return err
+	}
+	saved, err := lastEntries(ctx, tx, numbers)
+	if err != nil {
+		return err
+	}
+	upserts := &pgx.Batch{}
+	var entries, events [][]any
+	var marked []string
+	var lastSaved []int
+	for _, account := range accounts {
+		row := accountRow{number: account.Number(), kind: models.KindOf(account), product: models.ProductCodeOf(account), currency: models.CurrencyOf(account), balance: account.CheckBalance()}
+		switch a := account.(type) {
+		case *models.SavingsAccount:
+			row.rate = int64(a.InterestRate)
+			row.variable = marshalNull(a.Variable, a.Variable == nil)
+			row.rateResets = marshalNull(a.RateResets, len(a.RateResets) == 0)
+		case *models.CheckingAccount:
+			row.overdraft = a.OverdraftLimit
+		case *models.LoanAccount:
+			row.terms = marshalNull(a.Terms(), false)
+		case *models.CreditCardAccount:
+			row.terms = marshalNull(a.Terms(), false)
+		case models.Custom:
+			// Custom accounts keep their state in terms.
+			state, err := a.MarshalState()
+			if err != nil {
+				return fmt.Errorf("account %s: %w", row.number, err)
+			}
+			if len(state) > 0 {
+				row.terms = state
+			}
+		}
+		upserts.Queue(`INSERT INTO accounts (number, kind, balance, interest_rate, variable_rate, rate_resets, overdraft_limit, terms, product, currency)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (number) DO UPDATE SET kind = excluded.kind, balance = excluded.balance, interest_rate = excluded.interest_rate,
+				variable_rate = excluded.variable_rate, rate_resets = excluded.rate_resets, overdraft_limit = excluded.overdraft_limit,
+				terms = excluded.terms, product = excluded.product, currency = excluded.currency`,
+			row.number, row.kind, row.balance, row.rate, row.variable, row.rateResets, row.overdraft, row.terms, row.product, row.currency)
+		history := account.History()
+		last := saved[row.number]
+		if i := last.sequence - 1; i >= 0 && (i >= len(history) || history[i].Sequence != last.sequence || history[i].ID != last.id) {
+			upserts.Queue(`DELETE FROM transactions WHERE account = $1`, row.number)
+			last.sequence = 0
+		}
+		for _, t := range history {
+			if t.Sequence <= last.sequence {
+				continue
+			}
+			entries = append(entries, []any{row.number, t.ID, t.Sequence, string(t.Type), t.Amount, t.Counterparty, t.Category, t.Description,
+				marshalNull(t.Tags, len(t.Tags) == 0), int64(t.Rate), marshalNull(t.Metadata, len(t.Metadata) == 0), t.Time.UTC()})
+		}
+		if s.outbox {
+			for _, e := range store.NewEvents(account, marks[row.number]) {
+				events = append(events, []any{marshalNull(e, false)})
+			}
+		}
+		if history := account.History(); len(history) > 0 && history[len(history)-1].Sequence > marks[row.number] {
+			marked = append(marked, row.number)
+			lastSaved = append(lastSaved, history[len(history)-1].Sequence)
+		}
+	}
+	if err := tx.SendBatch(ctx, upserts).Close(); err != nil {
+		return err
+	}
+	columns := []string{"account", "id", "sequence", "type", "amount", "counterparty", "category", "description", "tags", "rate", "metadata", "time"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"transactions"}, columns, pgx.CopyFromRows(entries)); err != nil {
+		return err
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"outbox"}, []string{"event"}, pgx.CopyFromRows(events)); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO outbox_marks (account, sequence) SELECT * FROM unnest($1::TEXT[], $2::INTEGER[])
+		ON CONFLICT (account) DO UPDATE SET sequence = excluded.sequence`, marked, lastSaved)
+	return err
+}
+
+// savedEntry is the last ledger entry of an account in the database.
+type savedEntry struct {
+	sequence int
+	id       string
+}
+
+// lastEntries returns the last entry saved of each of the accounts numbered
+// numbers that has any.
+func lastEntries(ctx context.Context, tx pgx.Tx, numbers []string) (map[string]savedEntry, error) {
+	rows, err := tx.Query(ctx, `SELECT DISTINCT ON (account) account, sequence, id FROM transactions
+		WHERE account = ANY($1) ORDER BY account, sequence DESC`, numbers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	last := make(map[string]savedEntry)
+	for rows.Next() {
+		var account string
+		var e savedEntry
+		if err := rows.Scan(&account, &e.sequence, &e.id); err != nil {
+			return nil, err
+		}
+		last[account] = e
+	}
+	return last, rows.Err()
+}
+
+// outboxMarks returns the last entry saved of each of the accounts
+// numbered numbers that has any.
+func outboxMarks(ctx context.Context, tx pgx.Tx, numbers []string) (map[string]int, error) {
+	rows, err := tx.Query(ctx, `SELECT account, sequence FROM outbox_marks WHERE account = ANY($1)`, numbers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	marks := make(map[string]int)
+	for rows.Next() {
+		var account string
+		var sequence int
+		if err := rows.Scan(&account, &sequence); err != nil {
+			return nil, err
+		}
+		marks[account] = sequence
+	}
+	return marks, rows.Err()
+}
+
+// Lifecycles returns when the accounts that were closed or deleted were.
+func (s *Store) Lifecycles() (map[string]models.Lifecycle, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT number, closed, deleted FROM account_lifecycles`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lifecycles := make(map[string]models.Lifecycle)
+	for rows.Next() {
+		var number string
+		var closed, deleted *time.Time
+		if err := rows.Scan(&number, &closed, &deleted); err != nil {
+			return nil, err
+		}
+		var l models.Lifecycle
+		if closed != nil {
+			l.Closed = closed.UTC()
+		}
+		if deleted != nil {
+			l.Deleted = deleted.UTC()
+		}
+		lifecycles[number] = l
+	}
+	return lifecycles, rows.Err()
+}
+
+func (s *Store) SaveLifecycles(lifecycles map[string]models.Lifecycle) error {
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM account_lifecycles`); err != nil {
+		return err
+	}
+	rows := make([][]any, 0, len(lifecycles))
+	for number, l := range lifecycles {
+		rows = append(rows, []any{number, nullTime(l.Closed), nullTime(l.Deleted)})
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"account_lifecycles"}, []string{"number", "closed", "deleted"}, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Snapshot returns the snapshot saved last, nil if none was.
+func (s *Store) Snapshot() ([]byte, error) {
+	var snapshot string
+	err := s.pool.QueryRow(context.Background(), `SELECT snapshot FROM bank_snapshot`).Scan(&snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(snapshot), nil
+}
+
+func (s *Store) SaveSnapshot(snapshot []byte) error {
+	_, err := s.pool.Exec(context.Background(), `INSERT INTO bank_snapshot (id, snapshot) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET snapshot = excluded.snapshot`, string(snapshot))
+	return err
+}
+
+// Redirects returns the redirects of renumbered accounts.
+func (s *Store) Redirects() ([]models.Redirect, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT number, moved_to, time FROM account_redirects ORDER BY number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var redirects []models.Redirect
+	for rows.Next() {
+		var r models.Redirect
+		if err := rows.Scan(&r.From, &r.To, &r.Time); err != nil {
+			return nil, err
+		}
+		r.Time = r.Time.UTC()
+		redirects = append(redirects, r)
+	}
+	return redirects, rows.Err()
+}
+
+// SaveRedirects replaces the redirects kept and, in the same database
+// transaction, moves each account still saved under its old number to its
+// new one. Ledger entries reference their account, so the account is copied
+// under the new number before they move to it.
+func (s *Store) SaveRedirects(redirects []models.Redirect) error {
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM account_redirects`); err != nil {
+		return err
+	}
+	rows := make([][]any, 0, len(redirects))
+	for _, r := range redirects {
+		rows = append(rows, []any{r.From, r.To, r.Time})
+		var moved bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE number = $1)`, r.To).Scan(&moved); err != nil {
+			return err
+		}
+		if moved {
+			continue
+		}
+		for _, stmt := range []string{
+			`INSERT INTO accounts (number, kind, balance, interest_rate, variable_rate, rate_resets, overdraft_limit, terms, product, currency)
+				SELECT $1, kind, balance, interest_rate, variable_rate, rate_resets, overdraft_limit, terms, product, currency FROM accounts WHERE number = $2`,
+			`UPDATE transactions SET account = $1 WHERE account = $2`,
+			`DELETE FROM accounts WHERE number = $2 AND $1 <> $2`,
+			`UPDATE account_lifecycles SET number = $1 WHERE number = $2`,
+			`UPDATE outbox_marks SET account = $1 WHERE account = $2`,
+		} {
+			if _, err := tx.Exec(ctx, stmt, r.To, r.From); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"account_redirects"}, []string{"number", "moved_to", "time"}, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// PendingEvents returns the oldest events of the outbox.
+func (s *Store) PendingEvents(limit int) ([]store.OutboxEvent, error) {
+	rows, err := s.pool.Query(context.Background(), `SELECT id, event FROM outbox ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []store.OutboxEvent
+	for rows.Next() {
+		var e store.OutboxEvent
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, err
+		}
+		e.ID = id
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *Store) MarkPublished(ids ...int64) error {
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM outbox WHERE id = ANY($1)`, ids)
+	return err
+}
+
+// marshalNull encodes v as JSON, or as SQL NULL when null is set.
+func marshalNull(v any, null bool) []byte {
+	if null {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func unmarshalNull(data []byte, v any) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
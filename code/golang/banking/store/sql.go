@@ -0,0 +1,778 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"gsolano/banking/models"
+	"gsolano/banking/money"
+)
+
+// schema creates the tables of SQLStore. Tags and metadata of transactions,
+// the variable rate settings of savings accounts and the terms of loans and
+// cards are stored as JSON.
+const schema = `
+CREATE TABLE IF NOT EXISTS customers (
+	id   TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS customer_accounts (
+	customer_id TEXT NOT NULL,
+	position    INTEGER NOT NULL,
+	number      TEXT NOT NULL,
+	PRIMARY KEY (customer_id, position)
+);
+CREATE TABLE IF NOT EXISTS accounts (
+	number          TEXT PRIMARY KEY,
+	kind            TEXT NOT NULL,
+	balance         REAL NOT NULL,
+	interest_rate   INTEGER NOT NULL DEFAULT 0,
+	variable_rate   TEXT,
+	rate_resets     TEXT,
+	overdraft_limit REAL NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS transactions (
+	account      TEXT NOT NULL,
+	sequence     INTEGER NOT NULL,
+	type         TEXT NOT NULL,
+	amount       REAL NOT NULL,
+	counterparty TEXT NOT NULL DEFAULT '',
+	category     TEXT NOT NULL DEFAULT '',
+	description  TEXT NOT NULL DEFAULT '',
+	tags         TEXT,
+	rate         INTEGER NOT NULL DEFAULT 0,
+	metadata     TEXT,
+	time         TEXT NOT NULL,
+	PRIMARY KEY (account, sequence)
+);
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL
+);
+`
+
+// migrations bring databases created by older versions up to date. Entry i
+// moves a database from version i to i+1; new databases run them all too.
+var migrations = []string{
+	`ALTER TABLE accounts ADD COLUMN terms TEXT`,
+	// outbox_marks holds the last entry of each account that was saved, so
+	// that only entries saved after it add outbox events.
+	`CREATE TABLE outbox (
+		id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		event TEXT NOT NULL
+	);
+	CREATE TABLE outbox_marks (
+		account  TEXT PRIMARY KEY,
+		sequence INTEGER NOT NULL
+	);
+	INSERT INTO outbox_marks (account, sequence) SELECT account, MAX(sequence) FROM transactions GROUP BY account`,
+	`CREATE TABLE account_lifecycles (
+		number  TEXT PRIMARY KEY,
+		closed  TEXT,
+		deleted TEXT
+	)`,
+	`ALTER TABLE accounts ADD COLUMN product TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE accounts ADD COLUMN currency TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE transactions ADD COLUMN id TEXT NOT NULL DEFAULT ''`,
+	// account_redirects maps the old numbers of renumbered accounts to
+	// their new ones.
+	`CREATE TABLE account_redirects (
+		number   TEXT PRIMARY KEY,
+		moved_to TEXT NOT NULL,
+		time     TEXT NOT NULL
+	)`,
+	// bank_snapshot holds the one snapshot of the bank saved last, for the
+	// state that has no table of its own.
+	`CREATE TABLE bank_snapshot (
+		id       INTEGER PRIMARY KEY CHECK (id = 1),
+		snapshot TEXT NOT NULL
+	)`,
+}
+
+// SQLStore keeps a bank in a SQL database, one row per customer, account
+// and ledger entry, and the rest of it as a snapshot.
+type SQLStore struct {
+	db        *sql.DB
+	batchSize int
+	outbox    bool
+}
+
+// OpenSQLite opens, and creates if needed, the SQLite database at path.
+func OpenSQLite(path string, opts ...Option) (*SQLStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer; a single connection avoids "database is
+	// locked" errors between our own statements.
+	db.SetMaxOpenConns(1)
+	s, err := NewSQL(db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQL returns a store on db, creating its tables if they do not exist.
+func NewSQL(db *sql.DB, opts ...Option) (*SQLStore, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	o := NewOptions(opts...)
+	return &SQLStore{db: db, batchSize: o.BatchSize, outbox: o.Outbox}, nil
+}
+
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var version int
+	switch err := tx.QueryRow(`SELECT version FROM schema_version`).Scan(&version); {
+	case errors.Is(err, sql.ErrNoRows):
+		if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (0)`); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+	for _, m := range migrations[version:] {
+		if _, err := tx.Exec(m); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE schema_version SET version = ?`, len(migrations)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Customers() ([]*models.Customer, error) {
+	rows, err := s.db.Query(`SELECT id, name FROM customers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	var customers []*models.Customer
+	byID := make(map[string]*models.Customer)
+	for rows.Next() {
+		c := &models.Customer{}
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		customers = append(customers, c)
+		byID[c.ID] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT customer_id, number FROM customer_accounts ORDER BY customer_id, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, number string
+		if err := rows.Scan(&id, &number); err != nil {
+			return nil, err
+		}
+		if c := byID[id]; c != nil {
+			c.Accounts = append(c.Accounts, number)
+		}
+	}
+	return customers, rows.Err()
+}
+
+func (s *SQLStore) SaveCustomer(customer *models.Customer) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM customers WHERE id = ?`, customer.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM customer_accounts WHERE customer_id = ?`, customer.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO customers (id, name) VALUES (?, ?)`, customer.ID, customer.Name); err != nil {
+		return err
+	}
+	for i, number := range customer.Accounts {
+		if _, err := tx.Exec(`INSERT INTO customer_accounts (customer_id, position, number) VALUES (?, ?, ?)`, customer.ID, i, number); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// accountRow is a row of the accounts table.
+type accountRow struct {
+	number, kind         string
+	product              string
+	currency             string
+	balance, overdraft   float64
+	rate                 int64
+	variable, rateResets sql.NullString
+	terms                sql.NullString
+}
+
+func (s *SQLStore) EachAccount(fn func(models.BankAccount) error) error {
+	rows, err := s.db.Query(`SELECT number, kind, balance, interest_rate, variable_rate, rate_resets, overdraft_limit, terms, product, currency FROM accounts ORDER BY number`)
+	if err != nil {
+		return err
+	}
+	var accounts []accountRow
+	for rows.Next() {
+		var r accountRow
+		if err := rows.Scan(&r.number, &r.kind, &r.balance, &r.rate, &r.variable, &r.rateResets, &r.overdraft, &r.terms, &r.product, &r.currency); err != nil {
+			rows.Close()
+			return err
+		}
+		accounts = append(accounts, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range accounts {
+		account, err := s.loadAccount(r)
+		if err != nil {
+			return err
+		}
+		if err := fn(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) loadAccount(r accountRow) (models.BankAccount, error) {
+	history, err := s.transactions(r.number)
+	if err != nil {
+		return nil, err
+	}
+	base := models.Account{AccountNumber: r.number, Balance: r.balance, Transactions: history, Product: r.product, Currency: r.currency}
+	switch r.kind {
+	case "Savings":
+		sa := &models.SavingsAccount{Account: base, InterestRate: money.Rate(r.rate)}
+		if err := unmarshalNull(r.variable, &sa.Variable); err != nil {
+			return nil, err
+		}
+		if err := unmarshalNull(r.rateResets, &sa.RateResets); err != nil {
+			return nil, err
+		}
+		return sa, nil
+	case "Checking":
+		return &models.CheckingAccount{Account: base, OverdraftLimit: r.overdraft}, nil
+	case "Loan":
+		var terms models.LoanTerms
+		if err := unmarshalNull(r.terms, &terms); err != nil {
+			return nil, err
+		}
+		loan := &models.LoanAccount{Account: base}
+		loan.SetTerms(terms)
+		return loan, nil
+	case "CreditCard":
+		var terms models.CardTerms
+		if err := unmarshalNull(r.terms, &terms); err != nil {
+			return nil, err
+		}
+		card := &models.CreditCardAccount{Account: base}
+		card.SetTerms(terms)
+		return card, nil
+	case "Account":
+		return &base, nil
+	default:
+		return models.RestoreCustom(r.kind, base, []byte(r.terms.String))
+	}
+}
+
+func (s *SQLStore) transactions(number string) ([]models.Transaction, error) {
+	rows, err := s.db.Query(`SELECT id, sequence, type, amount, counterparty, category, description, tags, rate, metadata, time
+		FROM transactions WHERE account = ? ORDER BY sequence`, number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []models.Transaction
+	for rows.Next() {
+		var tx models.Transaction
+		var tags, metadata sql.NullString
+		var rate int64
+		var at string
+		if err := rows.Scan(&tx.ID, &tx.Sequence, &tx.Type, &tx.Amount, &tx.Counterparty, &tx.Category, &tx.Description, &tags, &rate, &metadata, &at); err != nil {
+			return nil, err
+		}
+		tx.Rate = money.Rate(rate)
+		if tx.Time, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, err
+		}
+		if err := unmarshalNull(tags, &tx.Tags); err != nil {
+			return nil, err
+		}
+		if err := unmarshalNull(metadata, &tx.Metadata); err != nil {
+			return nil, err
+		}
+		history = append(history, tx)
+	}
+	return history, rows.Err()
+}
+
+func (s *SQLStore) SaveAccount(account models.BankAccount) error {
+	return s.saveBatch([]models.BankAccount{account})
+}
+
+// SaveAccounts saves many accounts with few round trips: one database
+// transaction for every BatchSize accounts, and INSERT statements of up to
+// BatchSize rows each, prepared once per transaction. Only the ledger
+// entries posted since the last save are written.
+func (s *SQLStore) SaveAccounts(accounts []models.BankAccount) error {
+	for start := 0; start < len(accounts); start += s.batchSize {
+		if err := s.saveBatch(accounts[start:min(start+s.batchSize, len(accounts))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) saveBatch(accounts []models.BankAccount) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	numbers := make([]any, len(accounts))
+	for i, account := range accounts {
+		numbers[i] = account.Number()
+	}
+	marks, err := s.outboxMarks(tx, numbers)
+	if err != nil {
+		return err
+	}
+	saved, err := s.lastEntries(tx, numbers)
+	if err != nil {
+		return err
+	}
+	// Ledgers are only appended to: a ledger whose last entry saved is not
+	// that of the account is written again whole, the others from there.
+	var rewritten []any
+	for _, account := range accounts {
+		history, last := account.History(), saved[account.Number()]
+		if i := last.sequence - 1; i >= 0 && (i >= len(history) || history[i].Sequence != last.sequence || history[i].ID != last.id) {
+			rewritten = append(rewritten, account.Number())
+			delete(saved, account.Number())
+		}
+	}
+	for _, d := range []struct {
+		table   string
+		numbers []any
+	}{{"accounts WHERE number", numbers}, {"transactions WHERE account", rewritten}} {
+		w := &batchWriter{tx: tx, prefix: "DELETE FROM " + d.table + " IN ", columns: 1, list: true, size: s.rowsPerStatement(1)}
+		for _, number := range d.numbers {
+			if err := w.add(number); err != nil {
+				return err
+			}
+		}
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+
+	rows := &batchWriter{tx: tx, prefix: `INSERT INTO accounts (number, kind, balance, interest_rate, variable_rate, rate_resets, overdraft_limit, terms, product, currency) VALUES `, columns: 10, size: s.rowsPerStatement(10)}
+	entries := &batchWriter{tx: tx, prefix: `INSERT INTO transactions (account, id, sequence, type, amount, counterparty, category, description, tags, rate, metadata, time) VALUES `, columns: 12, size: s.rowsPerStatement(12)}
+	events := &batchWriter{tx: tx, prefix: `INSERT INTO outbox (event) VALUES `, columns: 1, size: s.rowsPerStatement(1)}
+	newMarks := &batchWriter{tx: tx, prefix: `INSERT OR REPLACE INTO outbox_marks (account, sequence) VALUES `, columns: 2, size: s.rowsPerStatement(2)}
+	for _, account := range accounts {
+		row := accountRow{number: account.Number(), kind: models.KindOf(account), product: models.ProductCodeOf(account), currency: models.CurrencyOf(account), balance: account.CheckBalance()}
+		switch a := account.(type) {
+		case *models.SavingsAccount:
+			row.rate = int64(a.InterestRate)
+			row.variable = marshalNull(a.Variable, a.Variable == nil)
+			row.rateResets = marshalNull(a.RateResets, len(a.RateResets) == 0)
+		case *models.CheckingAccount:
+			row.overdraft = a.OverdraftLimit
+		case *models.LoanAccount:
+			row.terms = marshalNull(a.Terms(), false)
+		case *models.CreditCardAccount:
+			row.terms = marshalNull(a.Terms(), false)
+		case models.Custom:
+			// Custom accounts keep their state in terms.
+			state, err := a.MarshalState()
+			if err != nil {
+				return fmt.Errorf("account %s: %w", row.number, err)
+			}
+			row.terms = sql.NullString{String: string(state), Valid: len(state) > 0}
+		}
+		if err := rows.add(row.number, row.kind, row.balance, row.rate, row.variable, row.rateResets, row.overdraft, row.terms, row.product, row.currency); err != nil {
+			return err
+		}
+		for _, t := range account.History() {
+			if t.Sequence <= saved[row.number].sequence {
+				continue
+			}
+			err := entries.add(row.number, t.ID, t.Sequence, t.Type, t.Amount, t.Counterparty, t.Category, t.Description,
+				marshalNull(t.Tags, len(t.Tags) == 0), int64(t.Rate), marshalNull(t.Metadata, len(t.Metadata) == 0), t.Time.Format(time.RFC3339Nano))
+			if err != nil {
+				return err
+			}
+		}
+		if s.outbox {
+			for _, e := range NewEvents(account, marks[row.number]) {
+				data, _ := json.Marshal(e)
+				if err := events.add(string(data)); err != nil {
+					return err
+				}
+			}
+		}
+		if history := account.History(); len(history) > 0 && history[len(history)-1].Sequence > marks[row.number] {
+			if err := newMarks.add(row.number, history[len(history)-1].Sequence); err != nil {
+				return err
+			}
+		}
+	}
+	for _, w := range []*batchWriter{rows, entries, events, newMarks} {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Lifecycles returns when the accounts that were closed or deleted were.
+func (s *SQLStore) Lifecycles() (map[string]models.Lifecycle, error) {
+	rows, err := s.db.Query(`SELECT number, closed, deleted FROM account_lifecycles`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lifecycles := make(map[string]models.Lifecycle)
+	for rows.Next() {
+		var number string
+		var closed, deleted sql.NullString
+		if err := rows.Scan(&number, &closed, &deleted); err != nil {
+			return nil, err
+		}
+		var l models.Lifecycle
+		if l.Closed, err = parseNullTime(closed); err != nil {
+			return nil, err
+		}
+		if l.Deleted, err = parseNullTime(deleted); err != nil {
+			return nil, err
+		}
+		lifecycles[number] = l
+	}
+	return lifecycles, rows.Err()
+}
+
+func (s *SQLStore) SaveLifecycles(lifecycles map[string]models.Lifecycle) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM account_lifecycles`); err != nil {
+		return err
+	}
+	w := &batchWriter{tx: tx, prefix: `INSERT INTO account_lifecycles (number, closed, deleted) VALUES `, columns: 3, size: s.rowsPerStatement(3)}
+	for number, l := range lifecycles {
+		if err := w.add(number, formatNullTime(l.Closed), formatNullTime(l.Deleted)); err != nil {
+			return err
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Snapshot returns the snapshot saved last, nil if none was.
+func (s *SQLStore) Snapshot() ([]byte, error) {
+	var snapshot []byte
+	err := s.db.QueryRow(`SELECT snapshot FROM bank_snapshot`).Scan(&snapshot)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return snapshot, err
+}
+
+func (s *SQLStore) SaveSnapshot(snapshot []byte) error {
+	_, err := s.db.Exec(`INSERT INTO bank_snapshot (id, snapshot) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET snapshot = excluded.snapshot`, string(snapshot))
+	return err
+}
+
+// Redirects returns the redirects of renumbered accounts.
+func (s *SQLStore) Redirects() ([]models.Redirect, error) {
+	rows, err := s.db.Query(`SELECT number, moved_to, time FROM account_redirects ORDER BY number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var redirects []models.Redirect
+	for rows.Next() {
+		var r models.Redirect
+		var at string
+		if err := rows.Scan(&r.From, &r.To, &at); err != nil {
+			return nil, err
+		}
+		if r.Time, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, r)
+	}
+	return redirects, rows.Err()
+}
+
+// SaveRedirects replaces the redirects kept and, in the same database
+// transaction, moves the rows of each account still saved under its old
+// number, ledger, lifecycle and outbox mark included, to its new one.
+func (s *SQLStore) SaveRedirects(redirects []models.Redirect) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM account_redirects`); err != nil {
+		return err
+	}
+	w := &batchWriter{tx: tx, prefix: `INSERT INTO account_redirects (number, moved_to, time) VALUES `, columns: 3, size: s.rowsPerStatement(3)}
+	for _, r := range redirects {
+		if err := w.add(r.From, r.To, r.Time.Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+		var moved int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE number = ?`, r.To).Scan(&moved); err != nil {
+			return err
+		}
+		if moved > 0 {
+			continue
+		}
+		for _, stmt := range []string{
+			`UPDATE accounts SET number = ? WHERE number = ?`,
+			`UPDATE transactions SET account = ? WHERE account = ?`,
+			`UPDATE account_lifecycles SET number = ? WHERE number = ?`,
+			`UPDATE outbox_marks SET account = ? WHERE account = ?`,
+		} {
+			if _, err := tx.Exec(stmt, r.To, r.From); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func formatNullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
+
+func parseNullTime(s sql.NullString) (time.Time, error) {
+	if !s.Valid {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s.String)
+}
+
+// savedEntry is the last ledger entry of an account in the database.
+type savedEntry struct {
+	sequence int
+	id       string
+}
+
+// lastEntries returns the last entry saved of each of the accounts
+// numbered numbers that has any.
+func (s *SQLStore) lastEntries(tx *sql.Tx, numbers []any) (map[string]savedEntry, error) {
+	last := make(map[string]savedEntry)
+	size := s.rowsPerStatement(1)
+	for start := 0; start < len(numbers); start += size {
+		chunk := numbers[start:min(start+size, len(numbers))]
+		rows, err := tx.Query(`SELECT t.account, t.sequence, t.id FROM transactions t
+			JOIN (SELECT account, MAX(sequence) AS sequence FROM transactions WHERE account IN (`+strings.Repeat("?, ", len(chunk)-1)+`?) GROUP BY account) m
+			ON t.account = m.account AND t.sequence = m.sequence`, chunk...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var account string
+			var e savedEntry
+			if err := rows.Scan(&account, &e.sequence, &e.id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			last[account] = e
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return last, nil
+}
+
+// outboxMarks returns the last entry saved of each of the accounts
+// numbered numbers that has any.
+func (s *SQLStore) outboxMarks(tx *sql.Tx, numbers []any) (map[string]int, error) {
+	marks := make(map[string]int)
+	size := s.rowsPerStatement(1)
+	for start := 0; start < len(numbers); start += size {
+		chunk := numbers[start:min(start+size, len(numbers))]
+		rows, err := tx.Query(`SELECT account, sequence FROM outbox_marks WHERE account IN (`+strings.Repeat("?, ", len(chunk)-1)+`?)`, chunk...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var account string
+			var sequence int
+			if err := rows.Scan(&account, &sequence); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			marks[account] = sequence
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return marks, nil
+}
+
+// PendingEvents returns the oldest events of the outbox.
+func (s *SQLStore) PendingEvents(limit int) ([]OutboxEvent, error) {
+	rows, err := s.db.Query(`SELECT id, event FROM outbox ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, err
+		}
+		e.ID = id
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLStore) MarkPublished(ids ...int64) error {
+	for start := 0; start < len(ids); start += s.rowsPerStatement(1) {
+		chunk := ids[start:min(start+s.rowsPerStatement(1), len(ids))]
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		if _, err := s.db.Exec(`DELETE FROM outbox WHERE id IN (`+strings.Repeat("?, ", len(args)-1)+`?)`, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxParameters is how many values SQLite binds to one statement.
+const maxParameters = 32766
+
+// rowsPerStatement is how many rows of columns values a statement of a
+// batch writes: BatchSize, within what SQLite can bind.
+func (s *SQLStore) rowsPerStatement(columns int) int {
+	return max(1, min(s.batchSize, maxParameters/columns))
+}
+
+// batchWriter runs a statement for up to size rows at a time: its prefix
+// followed by a group of placeholders for each row, or with list set a
+// single list of them, as for IN. The statement for a full batch is
+// prepared once and reused.
+type batchWriter struct {
+	tx      *sql.Tx
+	prefix  string
+	columns int
+	list    bool
+	size    int
+	full    *sql.Stmt
+	values  []any
+}
+
+func (w *batchWriter) add(values ...any) error {
+	w.values = append(w.values, values...)
+	if len(w.values) < w.size*w.columns {
+		return nil
+	}
+	if w.full == nil {
+		var err error
+		if w.full, err = w.tx.Prepare(w.statement(w.size)); err != nil {
+			return err
+		}
+	}
+	_, err := w.full.Exec(w.values...)
+	w.values = w.values[:0]
+	return err
+}
+
+// close writes the rows left over and releases the prepared statement.
+func (w *batchWriter) close() error {
+	var err error
+	if n := len(w.values) / w.columns; n > 0 {
+		_, err = w.tx.Exec(w.statement(n), w.values...)
+		w.values = w.values[:0]
+	}
+	if w.full != nil {
+		w.full.Close()
+	}
+	return err
+}
+
+func (w *batchWriter) statement(rows int) string {
+	if w.list {
+		return w.prefix + "(" + strings.Repeat("?, ", rows-1) + "?)"
+	}
+	group := "(" + strings.Repeat("?, ", w.columns-1) + "?)"
+	return w.prefix + strings.Repeat(group+", ", rows-1) + group
+}
+
+func marshalNull(v any, null bool) sql.NullString {
+	if null {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(v)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+func unmarshalNull(s sql.NullString, v any) error {
+	if !s.Valid {
+		return nil
+	}
+	return json.Unmarshal([]byte(s.String), v)
+}
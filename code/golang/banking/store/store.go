@@ -0,0 +1,406 @@
+// Package store persists the customers and accounts of a bank, with their
+// ledgers, in one of several backends and moves them between backends.
+// Backends that keep a snapshot of the bank besides keep the rest of its
+// state too, from pending transfers and budgets to sessions and API keys.
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"gsolano/banking"
+	"gsolano/banking/models"
+)
+
+var (
+	ErrUnknownBackend = banking.New(banking.CodeInvalidArgument, "unknown store backend")
+	ErrNotEmpty       = banking.New(banking.CodeConflict, "target store is not empty")
+	ErrVerification   = banking.New(banking.CodeInternal, "migrated data does not match the source")
+)
+
+// Store is a repository backend. Accounts are saved together with their
+// ledger; saving an account that exists replaces it.
+type Store interface {
+	Customers() ([]*models.Customer, error)
+	SaveCustomer(customer *models.Customer) error
+	// EachAccount calls fn for every account, in account number order,
+	// stopping at the first error.
+	EachAccount(fn func(models.BankAccount) error) error
+	SaveAccount(account models.BankAccount) error
+	// Close flushes what was saved and releases the backend.
+	Close() error
+}
+
+// batchSaver is implemented by stores that save many accounts faster than
+// one at a time.
+type batchSaver interface {
+	SaveAccounts(accounts []models.BankAccount) error
+}
+
+// lifecycleStore is implemented by stores that keep when accounts were
+// closed and soft-deleted.
+type lifecycleStore interface {
+	Lifecycles() (map[string]models.Lifecycle, error)
+	// SaveLifecycles replaces every lifecycle kept with lifecycles.
+	SaveLifecycles(lifecycles map[string]models.Lifecycle) error
+}
+
+// redirectStore is implemented by stores that keep the redirects of
+// renumbered accounts.
+type redirectStore interface {
+	Redirects() ([]models.Redirect, error)
+	// SaveRedirects replaces every redirect kept with redirects, moving
+	// the accounts saved under the old number of one to its new number.
+	SaveRedirects(redirects []models.Redirect) error
+}
+
+// snapshotStore is implemented by stores that keep the whole of a bank as
+// written by Bank.Snapshot, besides its customers and accounts. Load takes
+// accounts from the store's own rows, which may be newer, and the rest of
+// the bank from the snapshot.
+type snapshotStore interface {
+	// Snapshot returns the snapshot saved last, nil if none was.
+	Snapshot() ([]byte, error)
+	SaveSnapshot(snapshot []byte) error
+}
+
+// WriteThrough is implemented by stores that can write each deposit,
+// withdrawal and transfer as it is made, rather than on the next save.
+type WriteThrough interface {
+	// WriteThrough returns middleware for bank that writes the entries of
+	// each op it lets through, passing failed writes to failed.
+	WriteThrough(bank *models.Bank, failed func(error)) models.Middleware
+}
+
+// DefaultBatchSize is how many accounts the SQL store saves per database
+// transaction, and ledger entries per INSERT, unless WithBatchSize says.
+const DefaultBatchSize = 500
+
+// Options are the settings of a store, made from the Options passed to
+// Open. Backends registered from other packages read them.
+type Options struct {
+	BatchSize int
+	Pool      Pool
+	Outbox    bool
+}
+
+// Pool sizes the connection pool of a database server backend. Zero fields
+// keep the driver's defaults.
+type Pool struct {
+	MaxConns        int
+	MinConns        int
+	MaxConnIdleTime time.Duration
+}
+
+// Option tunes a store.
+type Option func(*Options)
+
+// NewOptions applies opts to the defaults.
+func NewOptions(opts ...Option) Options {
+	o := Options{BatchSize: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithBatchSize sets how many accounts a SQL store saves per database
+// transaction and how many rows it inserts per statement. Sizes below one
+// keep the default. Other stores ignore it.
+func WithBatchSize(n int) Option {
+	return func(o *Options) {
+		if n > 0 {
+			o.BatchSize = n
+		}
+	}
+}
+
+// WithPool sizes the connection pool of a database server. Stores that
+// keep files ignore it.
+func WithPool(p Pool) Option {
+	return func(o *Options) { o.Pool = p }
+}
+
+// Opener opens the store of a registered backend at dsn.
+type Opener func(dsn string, o Options) (Store, error)
+
+var openers = make(map[string]Opener)
+
+// Register makes a backend available to Open. Packages of backends that
+// need a driver of their own, such as store/postgres, register themselves
+// when imported.
+func Register(backend string, open Opener) {
+	if _, ok := openers[backend]; ok || backend == "json" || backend == "sqlite" {
+		panic("store: backend " + backend + " registered twice")
+	}
+	openers[backend] = open
+	Backends = append(Backends, backend)
+}
+
+// Backends lists the names accepted by Open.
+var Backends = []string{"json", "sqlite"}
+
+// Open opens the store of the named backend at path: a JSON file for "json"
+// and a database file for "sqlite". Both are created when missing. Other
+// backends take a data source name of their own.
+func Open(backend, path string, opts ...Option) (Store, error) {
+	switch backend {
+	case "json":
+		return OpenJSON(path)
+	case "sqlite":
+		return OpenSQLite(path, opts...)
+	}
+	if open, ok := openers[backend]; ok {
+		return open(path, NewOptions(opts...))
+	}
+	return nil, fmt.Errorf("%w %q (want one of %v)", ErrUnknownBackend, backend, Backends)
+}
+
+// Load opens every account and adds every customer in s to bank, with the
+// accounts closed or deleted and the redirects of renumbered accounts as
+// they were saved. From a store with a snapshot, it restores the whole
+// bank, replacing its state, with the customers and accounts of s.
+func Load(s Store, bank *models.Bank) error {
+	var snapshot []byte
+	if ss, ok := s.(snapshotStore); ok {
+		var err error
+		if snapshot, err = ss.Snapshot(); err != nil {
+			return err
+		}
+	}
+	customers, err := s.Customers()
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		var accounts []models.BankAccount
+		err = s.EachAccount(func(account models.BankAccount) error {
+			accounts = append(accounts, account)
+			return nil
+		})
+		if err == nil {
+			err = bank.RestoreWith(bytes.NewReader(snapshot), accounts, customers)
+		}
+	} else {
+		err = s.EachAccount(func(account models.BankAccount) error {
+			return bank.Open(account)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if ls, ok := s.(lifecycleStore); ok {
+		lifecycles, err := ls.Lifecycles()
+		if err != nil {
+			return err
+		}
+		for number, l := range lifecycles {
+			if err := bank.SetLifecycle(number, l); err != nil {
+				return fmt.Errorf("account %s: %w", number, err)
+			}
+		}
+	}
+	if rs, ok := s.(redirectStore); ok {
+		redirects, err := rs.Redirects()
+		if err != nil {
+			return err
+		}
+		for _, r := range redirects {
+			if err := bank.AddRedirect(r); err != nil {
+				return fmt.Errorf("redirect from %s: %w", r.From, err)
+			}
+		}
+	}
+	if snapshot != nil {
+		return nil
+	}
+	for _, c := range customers {
+		if err := bank.AddCustomer(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes every customer and account of bank to s, and the snapshot
+// too if s keeps one. It works on a snapshot, so the bank may keep serving
+// while it is saved.
+func Save(s Store, bank *models.Bank) error {
+	var buf bytes.Buffer
+	if err := bank.Snapshot(&buf); err != nil {
+		return err
+	}
+	snapshot := buf.Bytes()
+	bank = models.NewBank()
+	if err := bank.Restore(bytes.NewReader(snapshot)); err != nil {
+		return err
+	}
+	// Renumbered accounts move first, so they are then saved over rather
+	// than saved twice.
+	if rs, ok := s.(redirectStore); ok {
+		if err := rs.SaveRedirects(bank.Redirects()); err != nil {
+			return err
+		}
+	}
+	if err := saveAccounts(s, bank.Accounts()); err != nil {
+		return err
+	}
+	if ls, ok := s.(lifecycleStore); ok {
+		if err := ls.SaveLifecycles(lifecycles(bank)); err != nil {
+			return err
+		}
+	}
+	for _, c := range bank.Customers() {
+		if err := s.SaveCustomer(c); err != nil {
+			return err
+		}
+	}
+	// Last, so that a snapshot never names accounts that were not saved.
+	if ss, ok := s.(snapshotStore); ok {
+		return ss.SaveSnapshot(snapshot)
+	}
+	return nil
+}
+
+// lifecycles returns the lifecycle of every account of bank that was
+// closed or deleted.
+func lifecycles(bank *models.Bank) map[string]models.Lifecycle {
+	lifecycles := make(map[string]models.Lifecycle)
+	for _, account := range bank.Accounts() {
+		if l := bank.Lifecycle(account.Number()); l != (models.Lifecycle{}) {
+			lifecycles[account.Number()] = l
+		}
+	}
+	return lifecycles
+}
+
+// saveAccounts saves accounts to s, in batches when s can.
+func saveAccounts(s Store, accounts []models.BankAccount) error {
+	if b, ok := s.(batchSaver); ok {
+		return b.SaveAccounts(accounts)
+	}
+	for _, account := range accounts {
+		if err := s.SaveAccount(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrationReport counts what Migrate copied.
+type MigrationReport struct {
+	Customers    int
+	Accounts     int
+	Transactions int
+}
+
+// Migrate copies every customer and account from one store to another, one
+// account at a time, and the snapshot when both keep one, and then verifies that the target holds the same
+// customers and, for every account, the same balance and number of ledger
+// entries. The target must be empty.
+func Migrate(from, to Store) (MigrationReport, error) {
+	var report MigrationReport
+	existing, err := to.Customers()
+	if err != nil {
+		return report, err
+	}
+	empty := len(existing) == 0
+	if empty {
+		err = to.EachAccount(func(models.BankAccount) error {
+			empty = false
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	if !empty {
+		return report, ErrNotEmpty
+	}
+
+	type summary struct {
+		balance float64
+		entries int
+	}
+	want := make(map[string]summary)
+	err = from.EachAccount(func(account models.BankAccount) error {
+		if err := to.SaveAccount(account); err != nil {
+			return fmt.Errorf("account %s: %w", account.Number(), err)
+		}
+		want[account.Number()] = summary{account.CheckBalance(), len(account.History())}
+		report.Accounts++
+		report.Transactions += len(account.History())
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	customers, err := from.Customers()
+	if err != nil {
+		return report, err
+	}
+	for _, c := range customers {
+		if err := to.SaveCustomer(c); err != nil {
+			return report, fmt.Errorf("customer %s: %w", c.ID, err)
+		}
+		report.Customers++
+	}
+	if src, ok := from.(lifecycleStore); ok {
+		if dst, ok := to.(lifecycleStore); ok {
+			lifecycles, err := src.Lifecycles()
+			if err == nil {
+				err = dst.SaveLifecycles(lifecycles)
+			}
+			if err != nil {
+				return report, fmt.Errorf("closed and deleted accounts: %w", err)
+			}
+		}
+	}
+	if src, ok := from.(snapshotStore); ok {
+		if dst, ok := to.(snapshotStore); ok {
+			snapshot, err := src.Snapshot()
+			if err == nil && snapshot != nil {
+				err = dst.SaveSnapshot(snapshot)
+			}
+			if err != nil {
+				return report, fmt.Errorf("snapshot: %w", err)
+			}
+		}
+	}
+
+	var problems []string
+	seen := 0
+	err = to.EachAccount(func(account models.BankAccount) error {
+		seen++
+		w, ok := want[account.Number()]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("unexpected account %s", account.Number()))
+		case account.CheckBalance() != w.balance:
+			problems = append(problems, fmt.Sprintf("account %s has balance %.2f, want %.2f", account.Number(), account.CheckBalance(), w.balance))
+		case len(account.History()) != w.entries:
+			problems = append(problems, fmt.Sprintf("account %s has %d transactions, want %d", account.Number(), len(account.History()), w.entries))
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if seen != len(want) {
+		problems = append(problems, fmt.Sprintf("%d accounts, want %d", seen, len(want)))
+	}
+	migrated, err := to.Customers()
+	if err != nil {
+		return report, err
+	}
+	if len(migrated) != len(customers) {
+		problems = append(problems, fmt.Sprintf("%d customers, want %d", len(migrated), len(customers)))
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return report, fmt.Errorf("%w: %v", ErrVerification, problems)
+	}
+	return report, nil
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gsolano/banking/models"
+)
+
+// ledgerBank returns a bank of checking, savings and card accounts with
+// entries ledger entries each.
+func ledgerBank(t testing.TB, accounts, entries int) *models.Bank {
+	models.SetOutput(io.Discard)
+	bank := models.NewBank()
+	for i := 0; i < accounts; i++ {
+		number := fmt.Sprintf("L%05d", i+1)
+		var account models.BankAccount
+		switch i % 3 {
+		case 0:
+			account = &models.CheckingAccount{Account: models.Account{AccountNumber: number}, OverdraftLimit: 100}
+		case 1:
+			account = &models.SavingsAccount{Account: models.Account{AccountNumber: number}}
+		default:
+			account = &models.CreditCardAccount{Account: models.Account{AccountNumber: number}, CreditLimit: 1e6}
+		}
+		if err := bank.Open(account); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < entries; j++ {
+			opts := []models.TxOption{models.WithDescription(fmt.Sprintf("entry %d", j+1)), models.WithMetadata("batch", "yes")}
+			if err := bank.Withdraw(number, float64(j+1), opts...); err != nil {
+				if err := bank.Deposit(number, float64(j+1), opts...); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	return bank
+}
+
+// TestSaveAccountsInBatches saves a bank with a batch size that leaves
+// partial batches of accounts and of ledger rows, and checks that it loads
+// back entry for entry.
+func TestSaveAccountsInBatches(t *testing.T) {
+	bank := ledgerBank(t, 10, 7)
+	st, err := OpenSQLite(filepath.Join(t.TempDir(), "bank.db"), WithBatchSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	// Saving twice leaves what the first save wrote.
+	for i := 0; i < 2; i++ {
+		if err := Save(st, bank); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded := models.NewBank()
+	if err := Load(st, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(loaded.Accounts()), 10; got != want {
+		t.Fatalf("loaded %d accounts, want %d", got, want)
+	}
+	for _, account := range bank.Accounts() {
+		got, err := loaded.Account(account.Number())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.CheckBalance() != account.CheckBalance() {
+			t.Errorf("%s: balance %.2f, want %.2f", account.Number(), got.CheckBalance(), account.CheckBalance())
+		}
+		history, want := utc(got.History()), utc(account.History())
+		if !reflect.DeepEqual(history, want) {
+			t.Errorf("%s: ledger differs:\n got %+v\nwant %+v", account.Number(), history, want)
+		}
+	}
+}
+
+// TestSaveAppendsLedgers checks that saving again writes only the entries
+// posted since, leaving those saved before alone, and that a ledger that no
+// longer ends with the last entry saved is written again whole.
+func TestSaveAppendsLedgers(t *testing.T) {
+	bank := ledgerBank(t, 2, 3)
+	st, err := OpenSQLite(filepath.Join(t.TempDir(), "bank.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if err := Save(st, bank); err != nil {
+		t.Fatal(err)
+	}
+	// A mark on a saved entry survives only if it is not written again.
+	if _, err := st.db.Exec(`UPDATE transactions SET description = 'kept' WHERE account = 'L00001' AND sequence = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.Deposit("L00001", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(st, bank); err != nil {
+		t.Fatal(err)
+	}
+	history, err := st.transactions("L00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || history[0].Description != "kept" || history[3].Amount != 5 {
+		t.Fatalf("ledger after saving again: %+v", history)
+	}
+
+	restored := ledgerBank(t, 2, 2)
+	if err := Save(st, restored); err != nil {
+		t.Fatal(err)
+	}
+	history, err = st.transactions("L00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ := restored.Account("L00001")
+	if want := utc(account.History()); !reflect.DeepEqual(utc(history), want) {
+		t.Errorf("shorter ledger saved as %+v, want %+v", history, want)
+	}
+}
+
+// utc returns a copy of history with times in UTC without a monotonic
+// clock reading, as they are stored.
+func utc(history []models.Transaction) []models.Transaction {
+	history = append([]models.Transaction(nil), history...)
+	for i := range history {
+		history[i].Time = history[i].Time.UTC().Round(0)
+	}
+	return history
+}
+
+// BenchmarkSave measures saving a bank of 1000 accounts of 20 entries to
+// SQLite one account and one row at a time and in batches.
+func BenchmarkSave(b *testing.B) {
+	bank := ledgerBank(b, 1000, 20)
+	for _, size := range []int{1, 100, DefaultBatchSize} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			st, err := OpenSQLite(filepath.Join(b.TempDir(), "bank.db"), WithBatchSize(size))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer st.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := Save(st, bank); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*1000*20)/b.Elapsed().Seconds(), "entries/s")
+		})
+	}
+}
+
+// BenchmarkTransfer measures transfers a second between random pairs of
+// accounts from many goroutines at once, on a few hot accounts that every
+// goroutine contends for and on many that rarely collide. On the memory
+// backend a transfer only posts to the bank; on sqlite it also writes both
+// accounts through to the database, as a bank that persists every transfer
+// would. Run it with -cpu to vary the goroutines:
+//
+//	go test ./store -run XXX -bench Transfer -cpu 1,4,16
+func BenchmarkTransfer(b *testing.B) {
+	models.SetOutput(io.Discard)
+	for _, backend := range []string{"memory", "sqlite"} {
+		for _, accounts := range []int{2, 16, 1024} {
+			b.Run(fmt.Sprintf("%s/accounts=%d", backend, accounts), func(b *testing.B) {
+				benchmarkTransfer(b, backend, accounts)
+			})
+		}
+	}
+}
+
+func benchmarkTransfer(b *testing.B, backend string, accounts int) {
+	bank := models.NewBank()
+	numbers := make([]string, accounts)
+	for i := range numbers {
+		numbers[i] = fmt.Sprintf("B%05d", i+1)
+		account := &models.CheckingAccount{Account: models.Account{AccountNumber: numbers[i], Balance: 1e9}}
+		if err := bank.Open(account); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	transfer := func(from, to string) error { return bank.Transfer(from, to, 1) }
+	if backend != "memory" {
+		st, err := Open(backend, filepath.Join(b.TempDir(), "bank.db"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer st.Close()
+		if err := Save(st, bank); err != nil {
+			b.Fatal(err)
+		}
+		// SQLite has one writer, so a transfer holds the store from posting
+		// until both accounts are saved; that also keeps the ledgers still
+		// while they are read.
+		var mu sync.Mutex
+		transfer = func(from, to string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := bank.Transfer(from, to, 1); err != nil {
+				return err
+			}
+			for _, number := range []string{from, to} {
+				account, err := bank.Account(number)
+				if err != nil {
+					return err
+				}
+				if err := st.SaveAccount(account); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			from := r.Intn(accounts)
+			to := (from + 1 + r.Intn(accounts-1)) % accounts
+			if err := transfer(numbers[from], numbers[to]); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "transfers/s")
+}
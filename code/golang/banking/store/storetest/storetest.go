@@ -0,0 +1,485 @@
+// Package storetest is a conformance suite for store backends. Every
+// backend runs it from its tests, so that a bank behaves the same whichever
+// one keeps it:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.RunRepositoryTests(t, func(t *testing.T) storetest.Opener {
+//			path := filepath.Join(t.TempDir(), "bank.db")
+//			return func() (store.Store, error) { return store.OpenSQLite(path) }
+//		})
+//	}
+//
+// It is meant to be imported by tests only.
+package storetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"gsolano/banking/models"
+	"gsolano/banking/store"
+)
+
+// Opener opens one store. Each call opens the same data; what was saved
+// before Close must be there when it is opened again.
+type Opener func() (store.Store, error)
+
+// Factory returns the Opener of a new, empty store for the test t, cleaning
+// it up when t ends.
+type Factory func(t *testing.T) Opener
+
+// lifecycleStore is implemented by stores that keep when accounts were
+// closed and deleted, as store.Load and store.Save use them.
+type lifecycleStore interface {
+	Lifecycles() (map[string]models.Lifecycle, error)
+	SaveLifecycles(lifecycles map[string]models.Lifecycle) error
+}
+
+// RunRepositoryTests runs the suite against the stores factory makes, each
+// case as a subtest of t on a store of its own.
+func RunRepositoryTests(t *testing.T, factory Factory) {
+	models.SetOutput(io.Discard)
+	cases := []struct {
+		name string
+		test func(t *testing.T, open Opener)
+	}{
+		{"Empty", testEmpty},
+		{"RoundTrip", testRoundTrip},
+		{"Ordering", testOrdering},
+		{"SaveReplaces", testSaveReplaces},
+		{"EachAccountStops", testEachAccountStops},
+		{"Lifecycles", testLifecycles},
+		{"Renumber", testRenumber},
+		{"BankState", testBankState},
+		{"Migrate", testMigrate},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.test(t, factory(t))
+		})
+	}
+}
+
+func mustOpen(t *testing.T, open Opener) store.Store {
+	t.Helper()
+	st, err := open()
+	if err != nil {
+		t.Fatalf("opening the store: %v", err)
+	}
+	return st
+}
+
+func mustClose(t *testing.T, st store.Store) {
+	t.Helper()
+	if err := st.Close(); err != nil {
+		t.Fatalf("closing the store: %v", err)
+	}
+}
+
+// reopen closes st and opens its data again.
+func reopen(t *testing.T, st store.Store, open Opener) store.Store {
+	t.Helper()
+	mustClose(t, st)
+	st = mustOpen(t, open)
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+// demoBank is a bank of a checking, savings and card account of entries
+// ledger entries each, with descriptions and metadata, and customers
+// owning them.
+func demoBank(t *testing.T, entries int) *models.Bank {
+	t.Helper()
+	bank := models.NewBank()
+	clock := &clock{now: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	bank.Clock = clock
+	accounts := []models.BankAccount{
+		&models.CheckingAccount{Account: models.Account{AccountNumber: "C0001"}, OverdraftLimit: 100},
+		&models.SavingsAccount{Account: models.Account{AccountNumber: "S0001", Balance: 250}},
+		&models.CreditCardAccount{Account: models.Account{AccountNumber: "V0001"}, CreditLimit: 5000},
+	}
+	for _, account := range accounts {
+		if err := bank.Open(account); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < entries; j++ {
+			clock.now = clock.now.Add(time.Hour)
+			opts := []models.TxOption{models.WithDescription(fmt.Sprintf("entry %d", j+1)), models.WithMetadata("conformance", "yes")}
+			if err := bank.Withdraw(account.Number(), float64(j+1), opts...); err != nil {
+				if err := bank.Deposit(account.Number(), float64(j+1), opts...); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	for _, c := range []*models.Customer{
+		{ID: "c2", Name: "Second Customer", Accounts: []string{"V0001"}},
+		{ID: "c1", Name: "First Customer", Accounts: []string{"C0001", "S0001"}},
+	} {
+		if err := bank.AddCustomer(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bank
+}
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+// accounts returns the accounts of st in the order EachAccount calls them.
+func accounts(t *testing.T, st store.Store) []models.BankAccount {
+	t.Helper()
+	var accounts []models.BankAccount
+	if err := st.EachAccount(func(a models.BankAccount) error {
+		accounts = append(accounts, a)
+		return nil
+	}); err != nil {
+		t.Fatalf("EachAccount: %v", err)
+	}
+	return accounts
+}
+
+func customers(t *testing.T, st store.Store) []*models.Customer {
+	t.Helper()
+	customers, err := st.Customers()
+	if err != nil {
+		t.Fatalf("Customers: %v", err)
+	}
+	return customers
+}
+
+// utc returns a copy of history with times in UTC without a monotonic
+// clock reading, as stores keep them.
+func utc(history []models.Transaction) []models.Transaction {
+	history = append([]models.Transaction(nil), history...)
+	for i := range history {
+		history[i].Time = history[i].Time.UTC().Round(0)
+	}
+	return history
+}
+
+// sameAccount checks that got is want as saved: the same kind, balance and
+// ledger, entry for entry.
+func sameAccount(t *testing.T, got, want models.BankAccount) {
+	t.Helper()
+	if reflect.TypeOf(got) != reflect.TypeOf(want) {
+		t.Errorf("%s: loaded as %T, saved as %T", want.Number(), got, want)
+		return
+	}
+	if got.CheckBalance() != want.CheckBalance() {
+		t.Errorf("%s: balance %.2f, want %.2f", want.Number(), got.CheckBalance(), want.CheckBalance())
+	}
+	if g, w := utc(got.History()), utc(want.History()); !reflect.DeepEqual(g, w) {
+		t.Errorf("%s: ledger differs:\n got %+v\nwant %+v", want.Number(), g, w)
+	}
+}
+
+// testEmpty checks a new store holds nothing.
+func testEmpty(t *testing.T, open Opener) {
+	st := mustOpen(t, open)
+	defer st.Close()
+	if got := customers(t, st); len(got) != 0 {
+		t.Errorf("new store has customers %v", got)
+	}
+	if got := accounts(t, st); len(got) != 0 {
+		t.Errorf("new store has %d accounts", len(got))
+	}
+	if ls, ok := st.(lifecycleStore); ok {
+		lifecycles, err := ls.Lifecycles()
+		if err != nil || len(lifecycles) != 0 {
+			t.Errorf("new store has lifecycles %v, %v", lifecycles, err)
+		}
+	}
+}
+
+// testRoundTrip saves a bank and checks it loads back as it was, once the
+// store is closed and opened again.
+func testRoundTrip(t *testing.T, open Opener) {
+	bank := demoBank(t, 7)
+	st := mustOpen(t, open)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	st = reopen(t, st, open)
+
+	loaded := models.NewBank()
+	if err := store.Load(st, loaded); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := bank.Accounts()
+	got := loaded.Accounts()
+	if len(got) != len(want) {
+		t.Fatalf("%d accounts loaded, want %d", len(got), len(want))
+	}
+	for _, w := range want {
+		g, err := loaded.Account(w.Number())
+		if err != nil {
+			t.Errorf("%s: %v", w.Number(), err)
+			continue
+		}
+		sameAccount(t, g, w)
+	}
+	for _, w := range bank.Customers() {
+		g, err := loaded.Customer(w.ID)
+		if err != nil {
+			t.Errorf("customer %s: %v", w.ID, err)
+			continue
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("customer %s is %+v, want %+v", w.ID, g, w)
+		}
+	}
+}
+
+// testBankState saves a bank with state beyond its customers and accounts,
+// such as pending transfers, budgets, aliases, controls, messages and API
+// keys, and checks that it loads back whole from a store that keeps a
+// snapshot.
+func testBankState(t *testing.T, open Opener) {
+	bank := demoBank(t, 3)
+	steps := []error{
+		bank.SetAlias("C0001", models.Alias{Nickname: "Bills", Emoji: "🧾"}),
+		bank.SetControls("V0001", models.Controls{ATMDaily: 200, Countries: []string{"US"}}),
+		bank.SetTimeZone("S0001", "Europe/Madrid"),
+	}
+	bank.Budgets.Set("C0001", models.Budget{Category: "groceries", Limit: 400, Enforce: true})
+	_, err := bank.BookTransfer("S0001", "C0001", 50, 2, models.WithDescription("rent"))
+	steps = append(steps, err)
+	_, err = bank.SendMessage(models.Message{Customer: "c1", Kind: models.MessageNotice, Subject: "Welcome"})
+	steps = append(steps, err)
+	_, _, err = bank.IssueAPIKey(models.APIKey{Name: "ledger sync", Scope: models.PermissionView})
+	steps = append(steps, err)
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := mustOpen(t, open)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	st = reopen(t, st, open)
+	if _, ok := st.(interface{ Snapshot() ([]byte, error) }); !ok {
+		t.Skip("the store keeps no snapshot")
+	}
+	loaded := models.NewBank()
+	loaded.Clock = bank.Clock
+	if err := store.Load(st, loaded); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var want, got bytes.Buffer
+	if err := bank.Snapshot(&want); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Snapshot(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("loaded bank differs:\n got %s\nwant %s", &got, &want)
+	}
+}
+
+// testOrdering saves accounts and customers out of order and checks they
+// come back in account number and customer ID order.
+func testOrdering(t *testing.T, open Opener) {
+	st := mustOpen(t, open)
+	defer st.Close()
+	numbers := []string{"M0300", "A0100", "Z0900", "B0200", "A0050"}
+	for _, number := range numbers {
+		if err := st.SaveAccount(&models.SavingsAccount{Account: models.Account{AccountNumber: number, Balance: 10}}); err != nil {
+			t.Fatalf("SaveAccount %s: %v", number, err)
+		}
+	}
+	for _, id := range []string{"c9", "c10", "c1"} {
+		if err := st.SaveCustomer(&models.Customer{ID: id, Name: "Customer " + id}); err != nil {
+			t.Fatalf("SaveCustomer %s: %v", id, err)
+		}
+	}
+	var got []string
+	for _, a := range accounts(t, st) {
+		got = append(got, a.Number())
+	}
+	if want := []string{"A0050", "A0100", "B0200", "M0300", "Z0900"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EachAccount order %v, want %v", got, want)
+	}
+	var ids []string
+	for _, c := range customers(t, st) {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"c1", "c10", "c9"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Customers order %v, want %v", ids, want)
+	}
+}
+
+// testSaveReplaces saves accounts and customers that are already in the
+// store and checks the later save wins, without duplicating entries.
+func testSaveReplaces(t *testing.T, open Opener) {
+	bank := demoBank(t, 3)
+	st := mustOpen(t, open)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := bank.Deposit("C0001", 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	account, _ := bank.Account("C0001")
+	for i := 0; i < 2; i++ {
+		if err := st.SaveAccount(account); err != nil {
+			t.Fatalf("saving again: %v", err)
+		}
+	}
+	if err := st.SaveCustomer(&models.Customer{ID: "c1", Name: "Renamed Customer", Accounts: []string{"S0001"}}); err != nil {
+		t.Fatalf("saving the customer again: %v", err)
+	}
+	st = reopen(t, st, open)
+
+	loaded := accounts(t, st)
+	if len(loaded) != 3 {
+		t.Fatalf("%d accounts after saving one again, want 3", len(loaded))
+	}
+	for _, a := range loaded {
+		if a.Number() == "C0001" {
+			sameAccount(t, a, account)
+		}
+	}
+	got := customers(t, st)
+	if len(got) != 2 {
+		t.Fatalf("%d customers after saving one again, want 2", len(got))
+	}
+	if want := (&models.Customer{ID: "c1", Name: "Renamed Customer", Accounts: []string{"S0001"}}); !reflect.DeepEqual(got[0], want) {
+		t.Errorf("customer saved again is %+v, want %+v", got[0], want)
+	}
+}
+
+// testEachAccountStops checks EachAccount stops at the first error of fn
+// and returns it.
+func testEachAccountStops(t *testing.T, open Opener) {
+	st := mustOpen(t, open)
+	defer st.Close()
+	if err := store.Save(st, demoBank(t, 1)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err := st.EachAccount(func(models.BankAccount) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("EachAccount returned %v, want the error of fn", err)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times after failing, want once", calls)
+	}
+}
+
+// testLifecycles checks stores that keep lifecycles return those saved,
+// none for other accounts, and replace them all on every save.
+func testLifecycles(t *testing.T, open Opener) {
+	st := mustOpen(t, open)
+	ls, ok := st.(lifecycleStore)
+	if !ok {
+		st.Close()
+		t.Skip("the store keeps no lifecycles")
+	}
+	bank := demoBank(t, 1)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	closed := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	first := map[string]models.Lifecycle{
+		"C0001": {Closed: closed},
+		"S0001": {Closed: closed, Deleted: closed.Add(24 * time.Hour)},
+	}
+	if err := ls.SaveLifecycles(first); err != nil {
+		t.Fatalf("SaveLifecycles: %v", err)
+	}
+	second := map[string]models.Lifecycle{"S0001": first["S0001"]}
+	if err := ls.SaveLifecycles(second); err != nil {
+		t.Fatalf("SaveLifecycles: %v", err)
+	}
+	st = reopen(t, st, open)
+	got, err := st.(lifecycleStore).Lifecycles()
+	if err != nil {
+		t.Fatalf("Lifecycles: %v", err)
+	}
+	for number, l := range got {
+		got[number] = models.Lifecycle{Closed: l.Closed.UTC(), Deleted: l.Deleted.UTC()}
+	}
+	if !reflect.DeepEqual(got, second) {
+		t.Errorf("lifecycles %v, want %v", got, second)
+	}
+}
+
+// testRenumber saves a bank with an account renumbered after it was first
+// saved, and checks the store loads it under its new number alone, owned as
+// before, with its old number resolving to it.
+func testRenumber(t *testing.T, open Opener) {
+	st := mustOpen(t, open)
+	bank := demoBank(t, 2)
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := bank.RenumberAccount("C0001", "C0009"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(st, bank); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	st = reopen(t, st, open)
+	loaded := models.NewBank()
+	if err := store.Load(st, loaded); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var numbers []string
+	for _, account := range loaded.ListAccounts(true) {
+		numbers = append(numbers, account.Number())
+	}
+	if want := []string{"C0009", "S0001", "V0001"}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("loaded accounts %v, want %v", numbers, want)
+	}
+	if history, err := loaded.History("C0009"); err != nil || len(history) != 2 {
+		t.Errorf("history of the new number %d entries, %v, want 2", len(history), err)
+	}
+	if c, err := loaded.Customer("c1"); err != nil || c.Accounts[0] != "C0009" {
+		t.Errorf("customer c1 %+v, %v, want C0009 first", c, err)
+	}
+	if number, err := loaded.Resolve("C0001"); err != nil || number != "C0009" {
+		t.Errorf("old number resolves to %q, %v, want C0009", number, err)
+	}
+}
+
+// testMigrate migrates a bank into the store, and checks a store that is
+// not empty is refused as a target.
+func testMigrate(t *testing.T, open Opener) {
+	source, err := store.OpenJSON(t.TempDir() + "/source.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	bank := demoBank(t, 4)
+	if err := store.Save(source, bank); err != nil {
+		t.Fatal(err)
+	}
+	st := mustOpen(t, open)
+	defer st.Close()
+	report, err := store.Migrate(source, st)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if report.Accounts != 3 || report.Customers != 2 || report.Transactions != 12 {
+		t.Errorf("migrated %+v, want 3 accounts, 2 customers and 12 transactions", report)
+	}
+	if _, err := store.Migrate(source, st); !errors.Is(err, store.ErrNotEmpty) {
+		t.Errorf("migrating into a store that is not empty: %v, want %v", err, store.ErrNotEmpty)
+	}
+}
@@ -0,0 +1,79 @@
+// Package totp makes and checks the time-based one-time passwords of
+// authenticator apps (RFC 6238): six digits from the HMAC-SHA1 of a shared
+// secret and the number of 30-second steps since the Unix epoch. Secrets
+// are given to the app base32, usually as an otpauth URL in a QR code.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Period is how long a code is valid, and Digits how many it has.
+const (
+	Period = 30 * time.Second
+	Digits = 6
+)
+
+// Skew is how many steps before and after the current one Verify accepts,
+// for clocks that drift apart and codes typed slowly.
+const Skew = 1
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random secret of 160 bits, as RFC 4226 recommends.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Encode returns a secret as authenticator apps take it, unpadded base32.
+func Encode(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URL is the otpauth URL that adds a secret to an authenticator app, as an
+// account of issuer.
+func URL(issuer, account string, secret []byte) string {
+	v := url.Values{"secret": {Encode(secret)}, "issuer": {issuer}, "algorithm": {"SHA1"},
+		"digits": {fmt.Sprint(Digits)}, "period": {fmt.Sprint(int(Period / time.Second))}}
+	return (&url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + issuer + ":" + account, RawQuery: v.Encode()}).String()
+}
+
+// Step is the time step of t.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code is the code of a secret in a time step.
+func Code(secret []byte, step int64) string {
+	mac := hmac.New(sha1.New, secret)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, n%1000000)
+}
+
+// Verify checks a code against a secret at t, within Skew steps, and
+// returns the step it is the code of. Callers remember the step to refuse
+// the code, and those of earlier steps, the next time.
+func Verify(secret []byte, code string, t time.Time) (int64, bool) {
+	now := Step(t)
+	for step := now - Skew; step <= now+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(Code(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
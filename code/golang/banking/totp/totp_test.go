@@ -0,0 +1,25 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCode checks codes against the SHA-1 test vectors of RFC 6238, of
+// which they are the last six digits, and that Verify takes them within
+// Skew steps only.
+func TestCode(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		at := time.Unix(unix, 0)
+		if got := Code(secret, Step(at)); got != want {
+			t.Errorf("code at %d %s, want %s", unix, got, want)
+		}
+		if step, ok := Verify(secret, want, at.Add(Period)); !ok || step != Step(at) {
+			t.Errorf("code at %d a step later: step %d, %v", unix, step, ok)
+		}
+		if _, ok := Verify(secret, want, at.Add(3*Period)); ok {
+			t.Errorf("code at %d verifies three steps later", unix)
+		}
+	}
+}
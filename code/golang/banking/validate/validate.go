@@ -0,0 +1,124 @@
+// Package validate checks the inputs of the bank field by field and reports
+// every problem at once, so a client fixes a request in one go. The domain,
+// the HTTP API and the CLI check the same fields with the same rules.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gsolano/banking"
+	"gsolano/banking/money"
+)
+
+const (
+	// MaxAmount is the largest amount a single entry may move, well within
+	// what money counts exactly in minor units.
+	MaxAmount = 1e15
+	// MaxMemo is the longest description an entry may have, in characters.
+	MaxMemo = 140
+	// MaxAccountNumber is the longest account number, that of an IBAN.
+	MaxAccountNumber = 34
+)
+
+var (
+	ErrInvalidAmount = banking.New(banking.CodeInvalidArgument, "amount must be positive")
+	ErrPrecision     = banking.New(banking.CodeInvalidArgument, "amount is finer than the currency's minor unit")
+	ErrRequired      = banking.New(banking.CodeInvalidArgument, "value is required")
+	ErrMemoTooLong   = banking.New(banking.CodeInvalidArgument, "memo is too long")
+	ErrAccountNumber = banking.New(banking.CodeInvalidArgument, "invalid account number")
+)
+
+// Validator collects what is wrong with the fields of one input. The zero
+// value is ready to use.
+type Validator struct {
+	fields []banking.FieldError
+	causes []error
+}
+
+// Add records a problem with a field, matched by errors.Is against cause
+// if it is not nil.
+func (v *Validator) Add(field, message string, cause error) {
+	v.fields = append(v.fields, banking.FieldError{Field: field, Message: message})
+	if cause != nil {
+		v.causes = append(v.causes, cause)
+	}
+}
+
+// Check adds a problem with field unless ok.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.Add(field, message, nil)
+	}
+}
+
+// Required checks that a field, such as a reference, is not blank.
+func (v *Validator) Required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, "is required", ErrRequired)
+		return false
+	}
+	return true
+}
+
+// Amount checks that an amount of currency is positive, finite, at most
+// MaxAmount and has no more decimals than the currency's minor unit.
+func (v *Validator) Amount(field string, amount float64, currency string) {
+	switch {
+	case !(amount > 0 && amount <= MaxAmount):
+		v.Add(field, "must be positive and at most 1e15", ErrInvalidAmount)
+	case money.Round(amount, currency, money.HalfEven) != amount:
+		v.Add(field, fmt.Sprintf("must have at most %d decimals", money.MinorUnits(currency)), ErrPrecision)
+	}
+}
+
+// Memo checks that a free-text memo, such as a description, is at most
+// MaxMemo characters.
+func (v *Validator) Memo(field, memo string) {
+	if utf8.RuneCountInString(memo) > MaxMemo {
+		v.Add(field, "must be at most 140 characters", ErrMemoTooLong)
+	}
+}
+
+// AccountNumber checks that number is 1 to MaxAccountNumber ASCII letters,
+// digits or hyphens, not starting or ending with a hyphen.
+func (v *Validator) AccountNumber(field, number string) {
+	if !v.Required(field, number) {
+		return
+	}
+	ok := len(number) <= MaxAccountNumber && number[0] != '-' && number[len(number)-1] != '-'
+	for _, c := range number {
+		ok = ok && (c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '-')
+	}
+	if !ok {
+		v.Add(field, "must be 1 to 34 letters, digits or inner hyphens", ErrAccountNumber)
+	}
+}
+
+// Err returns nil if no problem was added, and otherwise an invalid
+// argument error listing every field error. It matches the cause of each
+// problem with errors.Is.
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	messages := make([]string, len(v.fields))
+	for i, f := range v.fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	err := banking.New(banking.CodeInvalidArgument, strings.Join(messages, "; "))
+	err.Fields = v.fields
+	return &invalid{err: err, causes: v.causes}
+}
+
+// invalid is the error of Err: the Error with its fields first, so it sets
+// the code, then the causes.
+type invalid struct {
+	err    *banking.Error
+	causes []error
+}
+
+func (e *invalid) Error() string { return e.err.Error() }
+
+func (e *invalid) Unwrap() []error { return append([]error{e.err}, e.causes...) }
@@ -0,0 +1,85 @@
+package validate
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"gsolano/banking"
+)
+
+// TestValidator checks each rule at and just past its limits, and the error
+// cause it reports.
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(*Validator)
+		want  error
+	}{
+		{"amount", func(v *Validator) { v.Amount("amount", 12.34, "USD") }, nil},
+		{"max amount", func(v *Validator) { v.Amount("amount", MaxAmount, "USD") }, nil},
+		{"above max amount", func(v *Validator) { v.Amount("amount", MaxAmount+1, "USD") }, ErrInvalidAmount},
+		{"zero amount", func(v *Validator) { v.Amount("amount", 0, "USD") }, ErrInvalidAmount},
+		{"negative amount", func(v *Validator) { v.Amount("amount", -5, "USD") }, ErrInvalidAmount},
+		{"NaN amount", func(v *Validator) { v.Amount("amount", math.NaN(), "USD") }, ErrInvalidAmount},
+		{"infinite amount", func(v *Validator) { v.Amount("amount", math.Inf(1), "USD") }, ErrInvalidAmount},
+		{"sub-cent amount", func(v *Validator) { v.Amount("amount", 1.005, "USD") }, ErrPrecision},
+		{"yen with decimals", func(v *Validator) { v.Amount("amount", 100.5, "JPY") }, ErrPrecision},
+		{"dinar in fils", func(v *Validator) { v.Amount("amount", 1.125, "KWD") }, nil},
+		{"required", func(v *Validator) { v.Required("reference", "R-1") }, nil},
+		{"blank", func(v *Validator) { v.Required("reference", "  \t") }, ErrRequired},
+		{"memo at max", func(v *Validator) { v.Memo("description", strings.Repeat("é", MaxMemo)) }, nil},
+		{"memo too long", func(v *Validator) { v.Memo("description", strings.Repeat("a", MaxMemo+1)) }, ErrMemoTooLong},
+		{"account number", func(v *Validator) { v.AccountNumber("to", "GB82-WEST-1234") }, nil},
+		{"IBAN length", func(v *Validator) { v.AccountNumber("to", strings.Repeat("9", MaxAccountNumber)) }, nil},
+		{"too long", func(v *Validator) { v.AccountNumber("to", strings.Repeat("9", MaxAccountNumber+1)) }, ErrAccountNumber},
+		{"leading hyphen", func(v *Validator) { v.AccountNumber("to", "-C1") }, ErrAccountNumber},
+		{"trailing hyphen", func(v *Validator) { v.AccountNumber("to", "C1-") }, ErrAccountNumber},
+		{"not ASCII", func(v *Validator) { v.AccountNumber("to", "CÜ1") }, ErrAccountNumber},
+		{"empty account", func(v *Validator) { v.AccountNumber("to", "") }, ErrRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Validator
+			tt.check(&v)
+			err := v.Err()
+			switch {
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Errorf("Err() = %v, want %v", err, tt.want)
+			case tt.want == nil && err != nil:
+				t.Errorf("Err() = %v, want nil", err)
+			case err != nil && banking.CodeOf(err) != banking.CodeInvalidArgument:
+				t.Errorf("code = %v, want invalid argument", banking.CodeOf(err))
+			}
+		})
+	}
+}
+
+// TestErr checks that every problem is reported at once, by field.
+func TestErr(t *testing.T) {
+	var v Validator
+	if err := v.Err(); err != nil {
+		t.Fatalf("zero Validator: %v", err)
+	}
+	v.Amount("amount", -1, "USD")
+	v.Memo("description", strings.Repeat("a", MaxMemo+1))
+	v.Check(false, "currency", "is not supported")
+	err := v.Err()
+
+	for _, cause := range []error{ErrInvalidAmount, ErrMemoTooLong} {
+		if !errors.Is(err, cause) {
+			t.Errorf("Err() does not match %v", cause)
+		}
+	}
+	var fields []string
+	for _, f := range banking.FieldsOf(err) {
+		fields = append(fields, f.Field)
+	}
+	if got := strings.Join(fields, ","); got != "amount,description,currency" {
+		t.Errorf("fields = %s", got)
+	}
+	if !strings.Contains(err.Error(), "currency: is not supported") {
+		t.Errorf("Error() = %q", err)
+	}
+}
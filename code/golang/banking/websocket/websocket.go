@@ -0,0 +1,233 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455) on top of net/http, enough to push JSON messages to browsers and
+// other clients. Fragmented messages, extensions and subprotocols are not
+// supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes of the frames a Conn deals with.
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// Close status codes.
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	ClosePolicy        = 1008
+	CloseTooBig        = 1009
+	CloseTryAgainLater = 1013
+)
+
+// maxMessageSize bounds what a client may send; the bank only expects small
+// control messages.
+const maxMessageSize = 64 << 10
+
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is an upgraded WebSocket connection. Writes are safe for concurrent
+// use; reads must happen from a single goroutine.
+type Conn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// Upgrade performs the opening handshake and takes over the connection.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteText sends a text message, failing if it takes longer than timeout.
+func (c *Conn) WriteText(data []byte, timeout time.Duration) error {
+	return c.writeFrame(opText, data, timeout)
+}
+
+// Ping sends a ping frame; the client's pong is consumed by ReadMessage.
+func (c *Conn) Ping(timeout time.Duration) error {
+	return c.writeFrame(opPing, nil, timeout)
+}
+
+// Close sends a close frame with code and reason and closes the connection.
+func (c *Conn) Close(code int, reason string) error {
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	c.writeFrame(opClose, payload, time.Second)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.closed = true
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte, timeout time.Duration) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// ReadMessage returns the next text or binary message from the client. Pings
+// are answered and pongs skipped; a close frame is acknowledged and reported
+// as ErrClosed.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opText, opBinary:
+			return payload, nil
+		case opPing:
+			c.writeFrame(opPong, payload, time.Second)
+		case opPong:
+		case opClose:
+			c.Close(CloseNormal, "")
+			return nil, ErrClosed
+		default:
+			c.Close(CloseProtocolError, "unsupported frame")
+			return nil, ErrClosed
+		}
+	}
+}
+
+func (c *Conn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	fin, opcode := head[0]&0x80 != 0, head[0]&0x0F
+	masked, length := head[1]&0x80 != 0, uint64(head[1]&0x7F)
+	if !fin {
+		c.Close(CloseProtocolError, "fragmented messages are not supported")
+		return 0, nil, ErrClosed
+	}
+	if !masked {
+		c.Close(CloseProtocolError, "client frames must be masked")
+		return 0, nil, ErrClosed
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		c.Close(CloseTooBig, "message too big")
+		return 0, nil, ErrClosed
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
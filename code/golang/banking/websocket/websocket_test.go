@@ -0,0 +1,231 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer upgrades every request and echoes the messages it reads until
+// the client goes away.
+func echoServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteText(msg, time.Second)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// client is the client end of a connection, speaking raw frames.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dial opens a connection to srv and sends the opening handshake with
+// headers, returning the client and the server's response.
+func dial(t *testing.T, srv *httptest.Server, headers map[string]string) (*client, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{conn: conn, r: r}, resp
+}
+
+var upgrade = map[string]string{
+	"Connection":            "keep-alive, Upgrade",
+	"Upgrade":               "websocket",
+	"Sec-WebSocket-Version": "13",
+	"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+}
+
+// write sends a frame, masked as clients must unless masked is false.
+func (c *client) write(t *testing.T, opcode byte, payload []byte, masked bool) {
+	t.Helper()
+	frame := []byte{0x80 | opcode, byte(len(payload))}
+	if len(payload) >= 126 {
+		frame = append(frame[:1], 126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	if masked {
+		frame[1] |= 0x80
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read returns the next frame from the server, which never masks.
+func (c *client) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+// readClose reads the next frame and returns its close code.
+func (c *client) readClose(t *testing.T) int {
+	t.Helper()
+	opcode, payload := c.read(t)
+	if opcode != opClose || len(payload) < 2 {
+		t.Fatalf("got frame %#x %q, want a close frame", opcode, payload)
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+// TestHandshake checks the accept key of RFC 6455's example and that
+// requests that are not upgrades are refused before the hijack.
+func TestHandshake(t *testing.T) {
+	srv := echoServer(t)
+	tests := []struct {
+		name   string
+		change map[string]string
+		want   int
+	}{
+		{"upgrade", nil, http.StatusSwitchingProtocols},
+		{"plain request", map[string]string{"Upgrade": "", "Connection": "close"}, http.StatusUpgradeRequired},
+		{"old version", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusBadRequest},
+		{"no key", map[string]string{"Sec-WebSocket-Key": ""}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := make(map[string]string)
+			for name, value := range upgrade {
+				headers[name] = value
+			}
+			for name, value := range tt.change {
+				headers[name] = value
+			}
+			_, resp := dial(t, srv, headers)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusSwitchingProtocols {
+				if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+					t.Errorf("Sec-WebSocket-Accept = %q", got)
+				}
+			}
+		})
+	}
+}
+
+// TestMessages checks that masked messages of each length encoding are
+// read and written back, and that pings are answered.
+func TestMessages(t *testing.T) {
+	c, _ := dial(t, echoServer(t), upgrade)
+	for _, msg := range []string{"", "hello", strings.Repeat("x", 300)} {
+		c.write(t, opText, []byte(msg), true)
+		opcode, payload := c.read(t)
+		if opcode != opText || string(payload) != msg {
+			t.Errorf("echo of %d bytes = %#x %d bytes", len(msg), opcode, len(payload))
+		}
+	}
+
+	c.write(t, opPing, []byte("are you there"), true)
+	if opcode, payload := c.read(t); opcode != opPong || string(payload) != "are you there" {
+		t.Errorf("ping answered with %#x %q", opcode, payload)
+	}
+
+	c.write(t, opClose, nil, true)
+	if code := c.readClose(t); code != CloseNormal {
+		t.Errorf("close acknowledged with %d, want %d", code, CloseNormal)
+	}
+}
+
+// TestProtocolErrors checks that frames a server must refuse close the
+// connection with the matching code.
+func TestProtocolErrors(t *testing.T) {
+	srv := echoServer(t)
+	tests := []struct {
+		name string
+		send func(*client)
+		want int
+	}{
+		{"unmasked", func(c *client) { c.write(t, opText, []byte("hi"), false) }, CloseProtocolError},
+		{"fragmented", func(c *client) { c.conn.Write([]byte{opText, 0x80, 0, 0, 0, 0}) }, CloseProtocolError},
+		{"too big", func(c *client) {
+			frame := []byte{0x80 | opBinary, 0x80 | 127}
+			c.conn.Write(binary.BigEndian.AppendUint64(frame, maxMessageSize+1))
+		}, CloseTooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := dial(t, srv, upgrade)
+			tt.send(c)
+			if code := c.readClose(t); code != tt.want {
+				t.Errorf("closed with %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteAfterClose checks that a closed connection refuses writes.
+func TestWriteAfterClose(t *testing.T) {
+	done := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+		conn.Close(CloseGoingAway, "bye")
+		done <- conn.WriteText([]byte("late"), time.Second)
+	}))
+	defer srv.Close()
+	c, _ := dial(t, srv, upgrade)
+	if code := c.readClose(t); code != CloseGoingAway {
+		t.Errorf("closed with %d, want %d", code, CloseGoingAway)
+	}
+	if err := <-done; err != ErrClosed {
+		t.Errorf("WriteText after Close = %v, want ErrClosed", err)
+	}
+}